@@ -2,10 +2,16 @@ package auth
 
 import (
 	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
 	"net/http"
 	"strings"
 
+	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
+	"golang.org/x/crypto/bcrypt"
 )
 
 type contextKey string
@@ -19,20 +25,60 @@ type User struct {
 	Token string `json:"-"`
 }
 
-// TokenEntry represents a configured token
+// TokenEntry represents a configured token.
+// Either Token (plaintext, deprecated) or Hash ("sha256:<hex>", "bcrypt:<id>:<hash>" or a bare bcrypt hash) must be set.
 type TokenEntry struct {
 	Token string `mapstructure:"token"`
+	Hash  string `mapstructure:"hash"`
 	Name  string `mapstructure:"name"`
 	Role  string `mapstructure:"role"`
 }
 
-// APIKeyEntry represents a configured API key
+// APIKeyEntry represents a configured API key.
+// Either Key (plaintext, deprecated) or Hash ("sha256:<hex>", "bcrypt:<id>:<hash>" or a bare bcrypt hash) must be set.
 type APIKeyEntry struct {
 	Key  string `mapstructure:"key"`
+	Hash string `mapstructure:"hash"`
 	Name string `mapstructure:"name"`
 	Role string `mapstructure:"role"`
 }
 
+const (
+	HashAlgoSHA256 = "sha256"
+	HashAlgoBcrypt = "bcrypt"
+
+	sha256HashPrefix = "sha256:"
+	bcryptHashPrefix = "bcrypt:"
+
+	// lookupIDLen is the length of the lookup id of a bcrypt credential:
+	// hex digits of the secret's sha256, enough to tell the configured
+	// credentials apart, too few to help guess the secret.
+	lookupIDLen = 4
+)
+
+// credential is a hashed secret bound to a user. Plaintext secrets from
+// config are converted to sha256 digests at load time. A bcrypt credential
+// with a lookup id is only compared to secrets with the same id, so a
+// request runs bcrypt against at most one hash.
+type credential struct {
+	sha256 []byte
+	bcrypt []byte
+	lookup string // bcrypt only; empty for bare bcrypt hashes
+	user   *User
+}
+
+// lookupID returns the lookup id of the secret with sha256 digest.
+func lookupID(digest []byte) string {
+	return hex.EncodeToString(digest)[:lookupIDLen]
+}
+
+func (c credential) matches(secret string, digest []byte) bool {
+	if c.sha256 != nil {
+		return subtle.ConstantTimeCompare(c.sha256, digest) == 1
+	}
+	return bcrypt.CompareHashAndPassword(c.bcrypt, []byte(secret)) == nil
+}
+
 // Config holds authentication configuration
 type Config struct {
 	Enabled bool          `mapstructure:"enabled"`
@@ -41,9 +87,10 @@ type Config struct {
 	Header  string        `mapstructure:"header"` // for apikey mode
 	Keys    []APIKeyEntry `mapstructure:"keys"`
 
-	// internal lookup maps
-	tokenMap  map[string]*User
-	apiKeyMap map[string]*User
+	// internal hashed credential lists
+	tokenCreds  []credential
+	apiKeyCreds []credential
+	invalid     []string // credentials LoadConfig could not use
 }
 
 // LoadConfig loads auth configuration from viper
@@ -69,28 +116,146 @@ func LoadConfig(cfg *viper.Viper) *Config {
 		c.Keys = keys
 	}
 
-	// Build lookup maps
-	c.tokenMap = make(map[string]*User)
-	for _, t := range c.Tokens {
-		role := t.Role
-		if role == "" {
-			role = "reader"
+	// Build hashed credential lists; plaintext secrets are dropped once hashed
+	for i, t := range c.Tokens {
+		c.Tokens[i].Token = ""
+		cred, err := newCredential(t.Token, t.Hash, t.Name, t.Role)
+		if err != nil {
+			c.invalid = append(c.invalid, fmt.Sprintf("auth token %q: %s", t.Name, err.Error()))
+			continue
 		}
-		c.tokenMap[t.Token] = &User{Name: t.Name, Role: role, Token: t.Token}
+		c.tokenCreds = append(c.tokenCreds, cred)
 	}
 
-	c.apiKeyMap = make(map[string]*User)
-	for _, k := range c.Keys {
-		role := k.Role
-		if role == "" {
-			role = "reader"
+	for i, k := range c.Keys {
+		c.Keys[i].Key = ""
+		cred, err := newCredential(k.Key, k.Hash, k.Name, k.Role)
+		if err != nil {
+			c.invalid = append(c.invalid, fmt.Sprintf("auth api key %q: %s", k.Name, err.Error()))
+			continue
 		}
-		c.apiKeyMap[k.Key] = &User{Name: k.Name, Role: role, Token: k.Key}
+		c.apiKeyCreds = append(c.apiKeyCreds, cred)
 	}
 
 	return c
 }
 
+// Err reports the credentials LoadConfig could not use, such as a hash
+// left at a placeholder, when auth is enabled. The server refuses to start
+// on it rather than run without them.
+func (c *Config) Err() error {
+	if !c.Enabled || len(c.invalid) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%s", strings.Join(c.invalid, "; "))
+}
+
+// Problems lists the settings that make the auth config reject requests
+// it was probably meant to accept. Credentials LoadConfig could not use
+// are reported by Err and not repeated.
+func (c *Config) Problems() []string {
+	if !c.Enabled {
+		return nil
+	}
+	var problems []string
+	creds, kind := c.tokenCreds, "mcp.auth.tokens"
+	switch c.Type {
+	case "", "token":
+	case "apikey":
+		creds, kind = c.apiKeyCreds, "mcp.auth.keys"
+	default:
+		problems = append(problems, fmt.Sprintf("mcp.auth.type %q is unknown (supported: token, apikey); falling back to token", c.Type))
+	}
+	if len(creds) == 0 {
+		problems = append(problems, fmt.Sprintf("mcp.auth.enabled is true but %s has no usable entries; every request will be rejected", kind))
+	}
+	for _, cred := range creds {
+		if _, ok := rolePermissions[cred.user.Role]; !ok {
+			problems = append(problems, fmt.Sprintf("auth credential %q has unknown role %q (supported: admin, trader, reader); it can call no tools", cred.user.Name, cred.user.Role))
+		}
+	}
+	return problems
+}
+
+func newCredential(plain, hash, name, role string) (credential, error) {
+	if role == "" {
+		role = "reader"
+	}
+	cred := credential{user: &User{Name: name, Role: role}}
+	switch {
+	case hash != "":
+		if strings.HasPrefix(hash, sha256HashPrefix) {
+			digest, err := hex.DecodeString(strings.TrimPrefix(hash, sha256HashPrefix))
+			if err != nil || len(digest) != sha256.Size {
+				return credential{}, fmt.Errorf("invalid sha256 hash")
+			}
+			cred.sha256 = digest
+		} else if rest, ok := strings.CutPrefix(hash, bcryptHashPrefix); ok {
+			id, h, _ := strings.Cut(rest, ":")
+			if _, err := hex.DecodeString(id); err != nil || len(id) != lookupIDLen {
+				return credential{}, fmt.Errorf("invalid bcrypt lookup id")
+			}
+			if _, err := bcrypt.Cost([]byte(h)); err != nil {
+				return credential{}, fmt.Errorf("invalid bcrypt hash")
+			}
+			cred.bcrypt, cred.lookup = []byte(h), id
+		} else if _, err := bcrypt.Cost([]byte(hash)); err == nil {
+			log.Warnf("auth credential %q is a bcrypt hash without lookup id and is checked on every request; regenerate it with --hash-token --hash-algo bcrypt", name)
+			cred.bcrypt = []byte(hash)
+		} else {
+			return credential{}, fmt.Errorf("unsupported hash format (expected sha256:<hex> or bcrypt)")
+		}
+	case plain != "":
+		log.Warnf("auth credential %q is stored in plaintext; this is deprecated, replace it with a hash generated by --hash-token", name)
+		digest := sha256.Sum256([]byte(plain))
+		cred.sha256 = digest[:]
+	default:
+		return credential{}, fmt.Errorf("neither secret nor hash configured")
+	}
+	return cred, nil
+}
+
+// HashToken hashes a secret for storage in the auth config.
+// algo is "sha256" (default) or "bcrypt"; bcrypt hashes are prefixed with
+// the secret's lookup id.
+func HashToken(secret, algo string) (string, error) {
+	if secret == "" {
+		return "", fmt.Errorf("secret is empty")
+	}
+	switch algo {
+	case "", HashAlgoSHA256:
+		digest := sha256.Sum256([]byte(secret))
+		return sha256HashPrefix + hex.EncodeToString(digest[:]), nil
+	case HashAlgoBcrypt:
+		hash, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+		if err != nil {
+			return "", err
+		}
+		digest := sha256.Sum256([]byte(secret))
+		return bcryptHashPrefix + lookupID(digest[:]) + ":" + string(hash), nil
+	default:
+		return "", fmt.Errorf("unsupported hash algorithm %q", algo)
+	}
+}
+
+// lookupCredential compares the presented secret against every sha256
+// credential, so that timing does not depend on which entry matches, and
+// against the bcrypt credentials whose lookup id it has, until one matches.
+func lookupCredential(creds []credential, secret string) *User {
+	digest := sha256.Sum256([]byte(secret))
+	id := lookupID(digest[:])
+	var found *User
+	for _, c := range creds {
+		if c.bcrypt != nil && (found != nil || (c.lookup != "" && c.lookup != id)) {
+			continue
+		}
+		if c.matches(secret, digest[:]) && found == nil {
+			found = c.user
+		}
+	}
+	return found
+}
+
 // Authenticate validates credentials from an HTTP request
 func (c *Config) Authenticate(r *http.Request) *User {
 	if !c.Enabled {
@@ -116,7 +281,7 @@ func (c *Config) authenticateToken(r *http.Request) *User {
 	if token == auth {
 		return nil // no "Bearer " prefix
 	}
-	return c.tokenMap[token]
+	return lookupCredential(c.tokenCreds, token)
 }
 
 func (c *Config) authenticateAPIKey(r *http.Request) *User {
@@ -129,7 +294,7 @@ func (c *Config) authenticateAPIKey(r *http.Request) *User {
 	if key == "" {
 		return nil
 	}
-	return c.apiKeyMap[key]
+	return lookupCredential(c.apiKeyCreds, key)
 }
 
 // UserFromContext extracts User from context
@@ -138,6 +303,17 @@ func UserFromContext(ctx context.Context) *User {
 	return u
 }
 
+// ScopedUser returns the name of the authenticated user when the caller is
+// limited to its own data, or "" for admins and callers without an
+// authenticated user (stdio), who see everyone's.
+func ScopedUser(ctx context.Context) string {
+	user := UserFromContext(ctx)
+	if user == nil || user.Role == "admin" {
+		return ""
+	}
+	return user.Name
+}
+
 // ContextWithUser returns a new context with user info
 func ContextWithUser(ctx context.Context, user *User) context.Context {
 	return context.WithValue(ctx, userContextKey, user)
@@ -146,40 +322,151 @@ func ContextWithUser(ctx context.Context, user *User) context.Context {
 // role permission definitions
 var rolePermissions = map[string]map[string]bool{
 	"admin": {
-		"list_data":           true,
-		"query_kline":         true,
-		"download_kline":      true,
-		"run_backtest":        true,
-		"run_python_research": true,
-		"build_strategy":      true,
-		"create_strategy":     true,
-		"start_trade":         true,
-		"stop_trade":          true,
-		"trade_status":        true,
+		"list_data":                 true,
+		"query_kline":               true,
+		"correlation_matrix":        true,
+		"fetch_depth":               true,
+		"funding_rate_history":      true,
+		"download_kline":            true,
+		"run_backtest":              true,
+		"run_python_research":       true,
+		"build_strategy":            true,
+		"create_strategy":           true,
+		"start_trade":               true,
+		"stop_trade":                true,
+		"trade_status":              true,
+		"list_config":               true,
+		"add_exchange":              true,
+		"param_sensitivity":         true,
+		"get_equity_curve":          true,
+		"get_open_orders":           true,
+		"export_backtest_report":    true,
+		"hard_delete_strategy":      true,
+		"attach_trade":              true,
+		"benchmark_strategy":        true,
+		"notify_test":               true,
+		"validate_strategy":         true,
+		"trade_distribution":        true,
+		"get_experiment":            true,
+		"reconcile_trade":           true,
+		"get_strategy_dependencies": true,
+		"portfolio_backtest":        true,
+		"recompute_performance":     true,
+		"get_quota":                 true,
+		"bulk_update_strategy_meta": true,
+		"list_indicators":           true,
+		"rolling_risk":              true,
+		"estimate_warmup":           true,
+		"get_exchange_fees":         true,
+		"retry_task":                true,
+		"server_status":             true,
+		"apply_param_set":           true,
+		"compare_live_backtest":     true,
+		"query_kline_multi":         true,
+		"get_run_manifest":          true,
+		"get_symbol_info":           true,
+		"get_best_params":           true,
+		"list_build_artifacts":      true,
+		"cleanup_build_artifacts":   true,
+		"validate_param_grid":       true,
 	},
 	"trader": {
-		"list_data":           true,
-		"query_kline":         true,
-		"download_kline":      true,
-		"run_backtest":        true,
-		"run_python_research": true,
-		"build_strategy":      true,
-		"create_strategy":     true,
-		"start_trade":         true,
-		"stop_trade":          true,
-		"trade_status":        true,
+		"list_data":                 true,
+		"query_kline":               true,
+		"correlation_matrix":        true,
+		"fetch_depth":               true,
+		"funding_rate_history":      true,
+		"download_kline":            true,
+		"run_backtest":              true,
+		"run_python_research":       true,
+		"build_strategy":            true,
+		"create_strategy":           true,
+		"start_trade":               true,
+		"stop_trade":                true,
+		"trade_status":              true,
+		"list_config":               false,
+		"add_exchange":              false,
+		"param_sensitivity":         true,
+		"get_equity_curve":          true,
+		"get_open_orders":           true,
+		"export_backtest_report":    true,
+		"hard_delete_strategy":      false,
+		"attach_trade":              true,
+		"benchmark_strategy":        true,
+		"notify_test":               true,
+		"validate_strategy":         true,
+		"trade_distribution":        true,
+		"get_experiment":            true,
+		"reconcile_trade":           true,
+		"get_strategy_dependencies": true,
+		"portfolio_backtest":        true,
+		"recompute_performance":     true,
+		"get_quota":                 true,
+		"bulk_update_strategy_meta": true,
+		"list_indicators":           true,
+		"rolling_risk":              true,
+		"estimate_warmup":           true,
+		"get_exchange_fees":         true,
+		"retry_task":                true,
+		"server_status":             true,
+		"apply_param_set":           true,
+		"compare_live_backtest":     true,
+		"query_kline_multi":         true,
+		"get_run_manifest":          true,
+		"get_symbol_info":           true,
+		"get_best_params":           true,
+		"list_build_artifacts":      true,
+		"cleanup_build_artifacts":   false,
+		"validate_param_grid":       true,
 	},
 	"reader": {
-		"list_data":           true,
-		"query_kline":         true,
-		"download_kline":      false,
-		"run_backtest":        true,
-		"run_python_research": true,
-		"build_strategy":      false,
-		"create_strategy":     true,
-		"start_trade":         false,
-		"stop_trade":          false,
-		"trade_status":        true,
+		"list_data":                 true,
+		"query_kline":               true,
+		"correlation_matrix":        true,
+		"fetch_depth":               true,
+		"funding_rate_history":      true,
+		"download_kline":            false,
+		"run_backtest":              true,
+		"run_python_research":       true,
+		"build_strategy":            false,
+		"create_strategy":           true,
+		"start_trade":               false,
+		"stop_trade":                false,
+		"trade_status":              true,
+		"list_config":               false,
+		"add_exchange":              false,
+		"param_sensitivity":         true,
+		"get_equity_curve":          true,
+		"get_open_orders":           false,
+		"export_backtest_report":    true,
+		"hard_delete_strategy":      false,
+		"attach_trade":              false,
+		"benchmark_strategy":        true,
+		"notify_test":               false,
+		"validate_strategy":         false,
+		"trade_distribution":        true,
+		"get_experiment":            true,
+		"reconcile_trade":           false,
+		"get_strategy_dependencies": true,
+		"portfolio_backtest":        true,
+		"recompute_performance":     false,
+		"get_quota":                 true,
+		"bulk_update_strategy_meta": false,
+		"list_indicators":           true,
+		"rolling_risk":              true,
+		"estimate_warmup":           true,
+		"get_exchange_fees":         false,
+		"retry_task":                false,
+		"server_status":             true,
+		"apply_param_set":           false,
+		"compare_live_backtest":     true,
+		"query_kline_multi":         true,
+		"get_run_manifest":          true,
+		"get_symbol_info":           true,
+		"get_best_params":           true,
+		"list_build_artifacts":      false,
+		"cleanup_build_artifacts":   false,
+		"validate_param_grid":       true,
 	},
 }
 
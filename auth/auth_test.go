@@ -0,0 +1,164 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestHashToken(t *testing.T) {
+	h, err := HashToken("secret", "")
+	if err != nil {
+		t.Fatalf("hash sha256: %v", err)
+	}
+	if h != "sha256:2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b" {
+		t.Fatalf("unexpected sha256 hash: %s", h)
+	}
+	if _, err := HashToken("secret", "md5"); err == nil {
+		t.Fatalf("expected unsupported algorithm error")
+	}
+	if _, err := HashToken("", HashAlgoSHA256); err == nil {
+		t.Fatalf("expected empty secret error")
+	}
+}
+
+func TestAuthenticateHashedCredentials(t *testing.T) {
+	shaHash, _ := HashToken("tok-sha", HashAlgoSHA256)
+	bcryptHash, err := HashToken("tok-bcrypt", HashAlgoBcrypt)
+	if err != nil {
+		t.Fatalf("hash bcrypt: %v", err)
+	}
+
+	v := viper.New()
+	v.Set("mcp.auth.enabled", true)
+	v.Set("mcp.auth.type", "token")
+	v.Set("mcp.auth.tokens", []map[string]interface{}{
+		{"hash": shaHash, "name": "sha", "role": "admin"},
+		{"hash": bcryptHash, "name": "bcrypt", "role": "trader"},
+		{"token": "tok-plain", "name": "plain"},
+		{"hash": "sha256:nothex", "name": "broken"},
+	})
+	cfg := LoadConfig(v)
+
+	for _, tok := range cfg.Tokens {
+		if tok.Token != "" {
+			t.Fatalf("plaintext token kept in config for %s", tok.Name)
+		}
+	}
+
+	cases := []struct {
+		token string
+		name  string
+		role  string
+	}{
+		{"tok-sha", "sha", "admin"},
+		{"tok-bcrypt", "bcrypt", "trader"},
+		{"tok-plain", "plain", "reader"},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest("GET", "/mcp", nil)
+		r.Header.Set("Authorization", "Bearer "+c.token)
+		u := cfg.Authenticate(r)
+		if u == nil || u.Name != c.name || u.Role != c.role {
+			t.Fatalf("token %s: got %+v", c.token, u)
+		}
+	}
+
+	r := httptest.NewRequest("GET", "/mcp", nil)
+	r.Header.Set("Authorization", "Bearer "+shaHash)
+	if u := cfg.Authenticate(r); u != nil {
+		t.Fatalf("hash itself must not authenticate, got %+v", u)
+	}
+}
+
+func TestBcryptLookupID(t *testing.T) {
+	h, err := HashToken("tok-bcrypt", HashAlgoBcrypt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256([]byte("tok-bcrypt"))
+	if !strings.HasPrefix(h, "bcrypt:"+hex.EncodeToString(digest[:])[:4]+":$2") {
+		t.Fatalf("bcrypt hash without lookup id: %s", h)
+	}
+	cred, err := newCredential("", h, "bot", "trader")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bare, err := newCredential("", strings.SplitN(h, ":", 3)[2], "bare", "reader")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u := lookupCredential([]credential{cred}, "tok-bcrypt"); u == nil || u.Name != "bot" {
+		t.Fatalf("keyed bcrypt: %+v", u)
+	}
+	if u := lookupCredential([]credential{bare}, "tok-bcrypt"); u == nil || u.Name != "bare" {
+		t.Fatalf("bare bcrypt: %+v", u)
+	}
+
+	// a credential under another lookup id is never compared
+	cred.lookup = "zzzz"
+	if u := lookupCredential([]credential{cred}, "tok-bcrypt"); u != nil {
+		t.Fatalf("bcrypt compared under another lookup id: %+v", u)
+	}
+	for _, bad := range []string{"bcrypt:xyz:" + string(cred.bcrypt), "bcrypt:abcd:nothash"} {
+		if _, err := newCredential("", bad, "bad", ""); err == nil {
+			t.Errorf("%s accepted", bad)
+		}
+	}
+}
+
+func TestAuthenticateHashedAPIKey(t *testing.T) {
+	h, _ := HashToken("key-1", HashAlgoSHA256)
+	v := viper.New()
+	v.Set("mcp.auth.enabled", true)
+	v.Set("mcp.auth.type", "apikey")
+	v.Set("mcp.auth.keys", []map[string]interface{}{
+		{"hash": h, "name": "bot", "role": "trader"},
+	})
+	cfg := LoadConfig(v)
+
+	r := httptest.NewRequest("GET", "/mcp?api_key=key-1", nil)
+	if u := cfg.Authenticate(r); u == nil || u.Name != "bot" {
+		t.Fatalf("api key via query: got %+v", u)
+	}
+	r = httptest.NewRequest("GET", "/mcp", nil)
+	r.Header.Set("X-API-Key", "key-2")
+	if u := cfg.Authenticate(r); u != nil {
+		t.Fatalf("wrong api key authenticated: %+v", u)
+	}
+}
+
+func TestConfigProblems(t *testing.T) {
+	h, _ := HashToken("key-1", HashAlgoSHA256)
+	v := viper.New()
+	v.Set("mcp.auth.enabled", true)
+	v.Set("mcp.auth.type", "apikey")
+	v.Set("mcp.auth.tokens", []map[string]interface{}{{"hash": h, "name": "tok"}})
+	if problems := LoadConfig(v).Problems(); len(problems) != 1 || !strings.Contains(problems[0], "mcp.auth.keys") {
+		t.Fatalf("tokens only in apikey mode: %v", problems)
+	}
+
+	v.Set("mcp.auth.keys", []map[string]interface{}{{"hash": h, "name": "bot", "role": "operator"}})
+	if problems := LoadConfig(v).Problems(); len(problems) != 1 || !strings.Contains(problems[0], `"operator"`) {
+		t.Fatalf("unknown role: %v", problems)
+	}
+
+	v.Set("mcp.auth.enabled", false)
+	if problems := LoadConfig(v).Problems(); len(problems) != 0 {
+		t.Fatalf("disabled: %v", problems)
+	}
+
+	v.Set("mcp.auth.enabled", true)
+	v.Set("mcp.auth.keys", []map[string]interface{}{{"hash": h, "name": "bot"}, {"hash": "sha256:change-me", "name": "placeholder"}})
+	if err := LoadConfig(v).Err(); err == nil || !strings.Contains(err.Error(), `"placeholder"`) {
+		t.Fatalf("malformed hash: %v", err)
+	}
+	v.Set("mcp.auth.keys", []map[string]interface{}{{"hash": h, "name": "bot"}})
+	if err := LoadConfig(v).Err(); err != nil {
+		t.Fatalf("valid keys: %v", err)
+	}
+}
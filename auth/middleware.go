@@ -2,6 +2,7 @@ package auth
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 
@@ -58,16 +59,24 @@ func HTTPContextFunc(cfg *Config) func(ctx context.Context, r *http.Request) con
 	}
 }
 
+// permissionDenied returns a permission_denied tool error result, shaped
+// like the errors the tools return so clients can branch on the code.
+func permissionDenied(message string) *mcp.CallToolResult {
+	data, _ := json.Marshal(map[string]map[string]string{"error": {"code": "permission_denied", "message": message}})
+	return mcp.NewToolResultError(string(data))
+}
+
 // ToolAuthMiddleware returns an mcp-go tool middleware that checks RBAC permissions.
+// Denials are tool error results, not protocol errors.
 func ToolAuthMiddleware(cfg *Config) server.ToolHandlerMiddleware {
 	return func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
 		return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 			user := UserFromContext(ctx)
 			if user == nil && cfg.Enabled {
-				return nil, fmt.Errorf("authentication required")
+				return permissionDenied("authentication required"), nil
 			}
 			if user != nil && !HasPermission(user.Role, req.Params.Name) {
-				return nil, fmt.Errorf("permission denied: role '%s' cannot use tool '%s'", user.Role, req.Params.Name)
+				return permissionDenied(fmt.Sprintf("permission denied: role '%s' cannot use tool '%s'", user.Role, req.Params.Name)), nil
 			}
 			return next(ctx, req)
 		}
@@ -0,0 +1,38 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestToolAuthMiddleware(t *testing.T) {
+	handler := ToolAuthMiddleware(&Config{Enabled: true})(func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText("ok"), nil
+	})
+	call := func(ctx context.Context, tool string) *mcp.CallToolResult {
+		var req mcp.CallToolRequest
+		req.Params.Name = tool
+		res, err := handler(ctx, req)
+		if err != nil {
+			t.Fatalf("%s: denial returned as a Go error: %v", tool, err)
+		}
+		return res
+	}
+	text := func(res *mcp.CallToolResult) string {
+		return res.Content[0].(mcp.TextContent).Text
+	}
+
+	if res := call(context.Background(), "list_data"); !res.IsError || !strings.Contains(text(res), `"code":"permission_denied"`) {
+		t.Fatalf("anonymous call: %+v", res)
+	}
+	reader := ContextWithUser(context.Background(), &User{Name: "r", Role: "reader"})
+	if res := call(reader, "start_trade"); !res.IsError || !strings.Contains(text(res), "cannot use tool 'start_trade'") {
+		t.Fatalf("reader starting a trade: %+v", res)
+	}
+	if res := call(reader, "list_data"); res.IsError || text(res) != "ok" {
+		t.Fatalf("reader listing data: %+v", res)
+	}
+}
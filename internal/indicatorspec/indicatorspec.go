@@ -0,0 +1,225 @@
+// Package indicatorspec describes the arguments of the indicators accepted
+// by Engine.AddIndicator, shared by the indicator resource, the engine doc
+// the create_strategy template and list_indicators. Arities and outputs are probed from the
+// indicator library, so the description cannot drift from what it accepts.
+package indicatorspec
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ztrade/indicator"
+)
+
+// Arg is one integer argument of an indicator.
+type Arg struct {
+	Name    string `json:"name"` // lowerCamel, used for template params
+	Meaning string `json:"meaning"`
+	Default int    `json:"default"`
+	Min     int    `json:"min"` // sensible range, not enforced by the engine
+	Max     int    `json:"max"`
+}
+
+// Form is one accepted argument count and the values the indicator then
+// reports in Indicator().
+type Form struct {
+	Args    int      `json:"args"`
+	Outputs []string `json:"outputs"`
+}
+
+// Spec describes one indicator.
+type Spec struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Args        []Arg  `json:"args,omitempty"`
+	Forms       []Form `json:"forms,omitempty"` // probed; empty when no form is accepted
+	Example     string `json:"example,omitempty"`
+}
+
+// known lists the argument meanings and ranges of the library's built-in
+// indicators; Catalog probes which argument counts are accepted.
+var known = []Spec{
+	{Name: "EMA", Description: "Exponential moving average; two periods give a fast/slow pair with cross signals", Args: []Arg{
+		{"fast", "period of the (fast) line", 9, 3, 50},
+		{"slow", "period of the slow line", 26, 10, 200},
+	}},
+	{Name: "SMA", Description: "Simple moving average; two periods give a fast/slow pair with cross signals", Args: []Arg{
+		{"fast", "period of the (fast) line", 20, 3, 50},
+		{"slow", "period of the slow line", 50, 10, 200},
+	}},
+	{Name: "SMMA", Description: "Smoothed moving average; two periods give a fast/slow pair with cross signals", Args: []Arg{
+		{"fast", "period of the (fast) line", 9, 3, 50},
+		{"slow", "period of the slow line", 26, 10, 200},
+	}},
+	{Name: "MACD", Description: "Moving average convergence divergence on EMAs", Args: []Arg{
+		{"fast", "fast EMA period", 12, 5, 20},
+		{"slow", "slow EMA period", 26, 15, 50},
+		{"signal", "signal (DEA) period", 9, 5, 15},
+	}},
+	{Name: "SMAMACD", Description: "MACD computed on simple moving averages", Args: []Arg{
+		{"fast", "fast SMA period", 12, 5, 20},
+		{"slow", "slow SMA period", 26, 15, 50},
+		{"signal", "signal (DEA) period", 9, 5, 15},
+	}},
+	{Name: "RSI", Description: "Relative strength index; two periods give a fast/slow pair with cross signals", Args: []Arg{
+		{"period", "RSI window (the fast one with two periods)", 14, 5, 30},
+		{"slow", "window of the slow RSI", 28, 10, 60},
+	}},
+	{Name: "STOCHRSI", Description: "Stochastic oscillator applied to RSI", Args: []Arg{
+		{"stochPeriod", "stochastic window", 14, 5, 30},
+		{"rsiPeriod", "RSI window", 14, 5, 30},
+		{"k", "%K smoothing", 3, 1, 5},
+		{"d", "%D smoothing", 3, 1, 5},
+	}},
+	{Name: "BOLL", Description: "Bollinger bands: middle band with upper/lower bands k standard deviations away", Args: []Arg{
+		{"period", "moving average window", 20, 10, 50},
+		{"multiplier", "band width in standard deviations (integer)", 2, 1, 3},
+	}},
+	{Name: "ATR", Description: "Average true range, needs OHLC candles", Args: []Arg{
+		{"period", "averaging window", 14, 5, 30},
+	}},
+	{Name: "ADX", Description: "Average directional index, needs OHLC candles", Args: []Arg{
+		{"period", "smoothing window", 14, 7, 30},
+	}},
+}
+
+// Catalog returns the known indicators with the argument counts the
+// library accepts, followed by any indicators registered with
+// indicator.RegisterIndicator, whose arguments are unknown.
+func Catalog() []Spec {
+	specs := make([]Spec, 0, len(known)+len(indicator.ExtraIndicators))
+	for _, s := range known {
+		defaults := make([]int, len(s.Args))
+		for i, a := range s.Args {
+			defaults[i] = a.Default
+		}
+		for n := 1; n <= len(defaults); n++ {
+			ind, err := indicator.NewCommonIndicator(s.Name, defaults[:n]...)
+			if err != nil {
+				continue
+			}
+			outputs := make([]string, 0, 4)
+			for k := range ind.Indicator() {
+				outputs = append(outputs, k)
+			}
+			sort.Strings(outputs)
+			s.Forms = append(s.Forms, Form{Args: n, Outputs: outputs})
+		}
+		if len(s.Forms) > 0 {
+			n := s.Forms[len(s.Forms)-1].Args
+			args := make([]string, n)
+			for i := range args {
+				args[i] = strconv.Itoa(defaults[i])
+			}
+			s.Example = fmt.Sprintf("AddIndicator(%q, %s)", s.Name, strings.Join(args, ", "))
+		}
+		specs = append(specs, s)
+	}
+
+	var extra []string
+	for name := range indicator.ExtraIndicators {
+		if Lookup(name) == nil {
+			extra = append(extra, name)
+		}
+	}
+	sort.Strings(extra)
+	for _, name := range extra {
+		specs = append(specs, Spec{Name: name, Description: "registered indicator, arguments not described"})
+	}
+	return specs
+}
+
+// Lookup returns the description of a known indicator by case-insensitive
+// name, without probing, or nil.
+func Lookup(name string) *Spec {
+	name = strings.ToUpper(name)
+	for i := range known {
+		if known[i].Name == name {
+			return &known[i]
+		}
+	}
+	return nil
+}
+
+// Check returns an error unless Engine.AddIndicator accepts name with args
+// arguments. Indicators registered without a description accept any count
+// the library does not reject at runtime.
+func Check(name string, args int) error {
+	upper := strings.ToUpper(name)
+	for _, s := range Catalog() {
+		if s.Name != upper {
+			continue
+		}
+		if len(s.Args) == 0 {
+			return nil
+		}
+		counts := make([]string, 0, len(s.Forms))
+		for _, f := range s.Forms {
+			if f.Args == args {
+				return nil
+			}
+			counts = append(counts, strconv.Itoa(f.Args))
+		}
+		return fmt.Errorf("%s takes %s arguments, got %d", upper, strings.Join(counts, " or "), args)
+	}
+	names := make([]string, 0, len(known))
+	for _, s := range Catalog() {
+		names = append(names, s.Name)
+	}
+	return fmt.Errorf("unknown indicator %q, supported: %s", name, strings.Join(names, ", "))
+}
+
+// Lookback returns how many input bars the indicator name needs with args
+// before its values are meaningful: the longest window, or the chained
+// windows for indicators built on another one (MACD's signal line on the
+// slow average, STOCHRSI's stochastic on RSI). Unknown indicators are
+// assumed to need their largest argument.
+func Lookback(name string, args []int) int {
+	arg := func(i int) int {
+		if i < len(args) {
+			return args[i]
+		}
+		return 0
+	}
+	longest := 0
+	for _, a := range args {
+		longest = max(longest, a)
+	}
+	switch strings.ToUpper(name) {
+	case "MACD", "SMAMACD":
+		if len(args) >= 3 {
+			return max(arg(0), arg(1)) + arg(2)
+		}
+	case "STOCHRSI":
+		return arg(0) + arg(1) + max(arg(2), arg(3))
+	case "BOLL", "ATR":
+		return arg(0)
+	case "ADX":
+		// the directional movement is smoothed, then averaged again
+		return 2 * arg(0)
+	}
+	return longest
+}
+
+// Markdown renders the catalog as the indicator table of the engine doc.
+func Markdown() string {
+	var b strings.Builder
+	b.WriteString("| 指标 | 参数个数 | 参数（默认值，建议范围） | 示例 | 输出 |\n")
+	b.WriteString("|------|----------|--------------------------|------|------|\n")
+	for _, s := range Catalog() {
+		var counts, outputs []string
+		for _, f := range s.Forms {
+			counts = append(counts, strconv.Itoa(f.Args))
+			outputs = append(outputs, fmt.Sprintf("%d: %s", f.Args, strings.Join(f.Outputs, "/")))
+		}
+		var args []string
+		for _, a := range s.Args {
+			args = append(args, fmt.Sprintf("%s: %s (%d, %d-%d)", a.Name, a.Meaning, a.Default, a.Min, a.Max))
+		}
+		fmt.Fprintf(&b, "| %s | %s | %s | %s | %s |\n", s.Name, strings.Join(counts, " 或 "),
+			strings.Join(args, "; "), s.Example, strings.Join(outputs, "; "))
+	}
+	return b.String()
+}
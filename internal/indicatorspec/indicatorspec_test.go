@@ -0,0 +1,96 @@
+package indicatorspec
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCatalogMatchesLibrary(t *testing.T) {
+	for _, s := range Catalog() {
+		if len(s.Args) == 0 {
+			continue
+		}
+		if len(s.Forms) == 0 {
+			t.Errorf("%s: not accepted by the indicator library", s.Name)
+			continue
+		}
+		// the last described argument must be used, or it is not one
+		if last := s.Forms[len(s.Forms)-1]; last.Args != len(s.Args) {
+			t.Errorf("%s: describes %d args, library accepts at most %d", s.Name, len(s.Args), last.Args)
+		}
+		for _, a := range s.Args {
+			if a.Default < a.Min || a.Default > a.Max || a.Min <= 0 {
+				t.Errorf("%s %s: default %d outside range %d-%d", s.Name, a.Name, a.Default, a.Min, a.Max)
+			}
+		}
+	}
+}
+
+func TestCatalogForms(t *testing.T) {
+	ema := find(t, "EMA")
+	if len(ema.Forms) != 2 || strings.Join(ema.Forms[0].Outputs, ",") != "result" ||
+		strings.Join(ema.Forms[1].Outputs, ",") != "crossDown,crossUp,fast,slow" {
+		t.Errorf("EMA forms: %+v", ema.Forms)
+	}
+	boll := find(t, "BOLL")
+	if len(boll.Forms) != 1 || boll.Forms[0].Args != 2 || boll.Example != `AddIndicator("BOLL", 20, 2)` {
+		t.Errorf("BOLL: %+v", boll)
+	}
+	if Lookup("ssma") != nil || Lookup("macd") == nil {
+		t.Error("Lookup")
+	}
+	if md := Markdown(); !strings.Contains(md, "| STOCHRSI | 4 |") {
+		t.Errorf("markdown:\n%s", md)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		args int
+		ok   bool
+	}{
+		{"ema", 1, true},
+		{"EMA", 2, true},
+		{"EMA", 3, false},
+		{"MACD", 2, false},
+		{"STOCHRSI", 4, true},
+		{"BOLL", 0, false},
+		{"NOPE", 1, false},
+	} {
+		if err := Check(c.name, c.args); (err == nil) != c.ok {
+			t.Errorf("Check(%s, %d) = %v", c.name, c.args, err)
+		}
+	}
+}
+
+func TestLookback(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		args []int
+		want int
+	}{
+		{"EMA", []int{9, 26}, 26},
+		{"macd", []int{12, 26, 9}, 35},
+		{"STOCHRSI", []int{14, 14, 3, 3}, 31},
+		{"BOLL", []int{20, 2}, 20},
+		{"ADX", []int{14}, 28},
+		{"CUSTOM", []int{5, 40}, 40},
+		{"EMA", nil, 0},
+	} {
+		if got := Lookback(c.name, c.args); got != c.want {
+			t.Errorf("Lookback(%s, %v) = %d, want %d", c.name, c.args, got, c.want)
+		}
+	}
+}
+
+func find(t *testing.T, name string) Spec {
+	t.Helper()
+	for _, s := range Catalog() {
+		if s.Name == name {
+			return s
+		}
+	}
+	t.Fatalf("%s not in catalog", name)
+	return Spec{}
+}
@@ -0,0 +1,98 @@
+// Package logging configures logrus from the mcp.log config section and
+// tags tool-related log lines with the tool name and calling user.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+
+	"github.com/ztrade/ztrade-mcp/auth"
+)
+
+type contextKey string
+
+const toolContextKey contextKey = "logging.tool"
+
+// Setup applies mcp.log.format (text/json) and mcp.log.level to the
+// standard logrus logger. debug forces the debug level.
+func Setup(cfg *viper.Viper, debug bool) error {
+	format := strings.ToLower(strings.TrimSpace(cfg.GetString("mcp.log.format")))
+	switch format {
+	case "", "text":
+		log.SetFormatter(&log.TextFormatter{})
+	case "json":
+		log.SetFormatter(&log.JSONFormatter{})
+	default:
+		return fmt.Errorf("unknown log format %q (supported: text, json)", format)
+	}
+
+	level := log.InfoLevel
+	if s := strings.TrimSpace(cfg.GetString("mcp.log.level")); s != "" {
+		l, err := log.ParseLevel(s)
+		if err != nil {
+			return err
+		}
+		level = l
+	}
+	if debug {
+		level = log.DebugLevel
+	}
+	log.SetLevel(level)
+	log.AddHook(contextHook{})
+	return nil
+}
+
+// ContextWithTool returns a context carrying the tool name for log fields.
+func ContextWithTool(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, toolContextKey, name)
+}
+
+// contextHook adds tool and user fields to entries logged with
+// log.WithContext(ctx).
+type contextHook struct{}
+
+func (contextHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (contextHook) Fire(entry *log.Entry) error {
+	if entry.Context == nil {
+		return nil
+	}
+	if tool, ok := entry.Context.Value(toolContextKey).(string); ok && tool != "" {
+		entry.Data["tool"] = tool
+	}
+	if user := auth.UserFromContext(entry.Context); user != nil {
+		entry.Data["user"] = user.Name
+	}
+	return nil
+}
+
+// ToolMiddleware puts the tool name into the request context and logs each
+// tool call with its duration and outcome.
+func ToolMiddleware() server.ToolHandlerMiddleware {
+	return func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+		return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			ctx = ContextWithTool(ctx, req.Params.Name)
+			start := time.Now()
+			result, err := next(ctx, req)
+			entry := log.WithContext(ctx).WithField("duration", time.Since(start).String())
+			switch {
+			case err != nil:
+				entry.WithError(err).Warn("tool call failed")
+			case result != nil && result.IsError:
+				entry.Info("tool call returned error")
+			default:
+				entry.Debug("tool call completed")
+			}
+			return result, err
+		}
+	}
+}
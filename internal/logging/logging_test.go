@@ -0,0 +1,42 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/ztrade/ztrade-mcp/auth"
+)
+
+func TestContextHookAddsToolAndUser(t *testing.T) {
+	var buf bytes.Buffer
+	logger := log.New()
+	logger.SetOutput(&buf)
+	logger.SetFormatter(&log.JSONFormatter{})
+	logger.AddHook(contextHook{})
+
+	ctx := ContextWithTool(context.Background(), "run_backtest")
+	ctx = auth.ContextWithUser(ctx, &auth.User{Name: "alice", Role: "trader"})
+	logger.WithContext(ctx).Info("hello")
+
+	var line map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("invalid json log line %q: %s", buf.String(), err)
+	}
+	if line["tool"] != "run_backtest" || line["user"] != "alice" {
+		t.Fatalf("unexpected fields: %v", line)
+	}
+
+	buf.Reset()
+	logger.Info("plain")
+	line = nil
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("invalid json log line: %s", err)
+	}
+	if _, ok := line["tool"]; ok {
+		t.Fatalf("tool field should be absent without context: %v", line)
+	}
+}
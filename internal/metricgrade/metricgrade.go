@@ -0,0 +1,104 @@
+// Package metricgrade holds the evaluation thresholds for backtest metrics
+// shared by the analyze_backtest prompt and generated reports.
+package metricgrade
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Grades from worst to best.
+const (
+	Poor       = "poor"
+	Acceptable = "acceptable"
+	Good       = "good"
+	Excellent  = "excellent"
+)
+
+// Threshold defines the boundaries between grades of one metric. For
+// metrics where lower is better the boundaries decrease.
+type Threshold struct {
+	Key            string // BacktestRecord JSON field
+	Name           string
+	HigherIsBetter bool
+	Percent        bool // value is a fraction shown as percent
+	Acceptable     float64
+	Good           float64
+	Excellent      float64
+}
+
+// Thresholds is the evaluation table, in display order.
+var Thresholds = []Threshold{
+	{Key: "sharpeRatio", Name: "Sharpe Ratio", HigherIsBetter: true, Acceptable: 0.5, Good: 1, Excellent: 2},
+	{Key: "maxDrawdown", Name: "Max Drawdown", Percent: true, Acceptable: 0.3, Good: 0.2, Excellent: 0.1},
+	{Key: "winRate", Name: "Win Rate", HigherIsBetter: true, Percent: true, Acceptable: 0.3, Good: 0.45, Excellent: 0.6},
+	{Key: "profitFactor", Name: "Profit Factor", HigherIsBetter: true, Acceptable: 1, Good: 1.5, Excellent: 2.5},
+	{Key: "calmarRatio", Name: "Calmar Ratio", HigherIsBetter: true, Acceptable: 0.5, Good: 1, Excellent: 3},
+	{Key: "ulcerIndex", Name: "Ulcer Index", Percent: true, Acceptable: 0.15, Good: 0.1, Excellent: 0.05},
+	{Key: "martinRatio", Name: "Martin Ratio", HigherIsBetter: true, Acceptable: 1, Good: 2, Excellent: 5},
+}
+
+// Lookup returns the threshold for a metric key.
+func Lookup(key string) (Threshold, bool) {
+	for _, t := range Thresholds {
+		if t.Key == key {
+			return t, true
+		}
+	}
+	return Threshold{}, false
+}
+
+// Grade returns the grade of v.
+func (t Threshold) Grade(v float64) string {
+	if !t.HigherIsBetter {
+		v, t.Acceptable, t.Good, t.Excellent = -v, -t.Acceptable, -t.Good, -t.Excellent
+	}
+	switch {
+	case v < t.Acceptable:
+		return Poor
+	case v < t.Good:
+		return Acceptable
+	case v <= t.Excellent:
+		return Good
+	default:
+		return Excellent
+	}
+}
+
+// Grade returns the grade of a metric value, or "" for metrics without
+// thresholds.
+func Grade(key string, v float64) string {
+	t, ok := Lookup(key)
+	if !ok {
+		return ""
+	}
+	return t.Grade(v)
+}
+
+func (t Threshold) format(v float64) string {
+	if t.Percent {
+		return strconv.FormatFloat(v*100, 'f', -1, 64)
+	}
+	return strconv.FormatFloat(v, 'f', 1, 64)
+}
+
+// MarkdownTable renders the thresholds as the evaluation guidelines table.
+func MarkdownTable() string {
+	var b strings.Builder
+	b.WriteString("| Metric | Poor | Acceptable | Good | Excellent |\n")
+	b.WriteString("|--------|------|-----------|------|-----------|\n")
+	for _, t := range Thresholds {
+		unit := ""
+		if t.Percent {
+			unit = "%"
+		}
+		a, g, e := t.format(t.Acceptable), t.format(t.Good), t.format(t.Excellent)
+		if t.HigherIsBetter {
+			fmt.Fprintf(&b, "| %s | <%s%s | %s-%s%s | %s-%s%s | >%s%s |\n", t.Name, a, unit, a, g, unit, g, e, unit, e, unit)
+		} else {
+			fmt.Fprintf(&b, "| %s | >%s%s | %s-%s%s | %s-%s%s | <%s%s |\n", t.Name, a, unit, g, a, unit, e, g, unit, e, unit)
+		}
+	}
+	return b.String()
+}
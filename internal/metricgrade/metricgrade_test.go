@@ -0,0 +1,42 @@
+package metricgrade
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGrade(t *testing.T) {
+	cases := []struct {
+		key  string
+		v    float64
+		want string
+	}{
+		{"sharpeRatio", 0.2, Poor},
+		{"sharpeRatio", 0.7, Acceptable},
+		{"sharpeRatio", 1.5, Good},
+		{"sharpeRatio", 2.5, Excellent},
+		{"maxDrawdown", 0.35, Poor},
+		{"maxDrawdown", 0.25, Acceptable},
+		{"maxDrawdown", 0.15, Good},
+		{"maxDrawdown", 0.05, Excellent},
+		{"totalReturn", 1, ""},
+	}
+	for _, c := range cases {
+		if got := Grade(c.key, c.v); got != c.want {
+			t.Fatalf("Grade(%s, %v) = %q, want %q", c.key, c.v, got, c.want)
+		}
+	}
+}
+
+func TestMarkdownTable(t *testing.T) {
+	table := MarkdownTable()
+	for _, row := range []string{
+		"| Sharpe Ratio | <0.5 | 0.5-1.0 | 1.0-2.0 | >2.0 |",
+		"| Max Drawdown | >30% | 20-30% | 10-20% | <10% |",
+		"| Win Rate | <30% | 30-45% | 45-60% | >60% |",
+	} {
+		if !strings.Contains(table, row) {
+			t.Fatalf("missing row %q in:\n%s", row, table)
+		}
+	}
+}
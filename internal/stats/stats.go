@@ -0,0 +1,132 @@
+package stats
+
+import "math"
+
+// Mean returns the arithmetic mean of xs, or 0 for an empty slice.
+func Mean(xs []float64) float64 {
+	if len(xs) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, x := range xs {
+		sum += x
+	}
+	return sum / float64(len(xs))
+}
+
+// StdDev returns the sample standard deviation of xs, or 0 when fewer than
+// two values are given.
+func StdDev(xs []float64) float64 {
+	if len(xs) < 2 {
+		return 0
+	}
+	m := Mean(xs)
+	var ss float64
+	for _, x := range xs {
+		d := x - m
+		ss += d * d
+	}
+	return math.Sqrt(ss / float64(len(xs)-1))
+}
+
+// Pearson returns the Pearson correlation coefficient of xs and ys. ok is
+// false when the slices differ in length, have fewer than two values or
+// either series is constant.
+func Pearson(xs, ys []float64) (r float64, ok bool) {
+	if len(xs) != len(ys) || len(xs) < 2 {
+		return 0, false
+	}
+	mx, my := Mean(xs), Mean(ys)
+	var sxy, sxx, syy float64
+	for i := range xs {
+		dx, dy := xs[i]-mx, ys[i]-my
+		sxy += dx * dy
+		sxx += dx * dx
+		syy += dy * dy
+	}
+	if sxx == 0 || syy == 0 {
+		return 0, false
+	}
+	return sxy / math.Sqrt(sxx*syy), true
+}
+
+// Returns converts a price series to simple returns p[i]/p[i-1]-1. Pairs
+// with a non-positive previous price are skipped.
+func Returns(prices []float64) []float64 {
+	if len(prices) < 2 {
+		return nil
+	}
+	ret := make([]float64, 0, len(prices)-1)
+	for i := 1; i < len(prices); i++ {
+		if prices[i-1] <= 0 {
+			continue
+		}
+		ret = append(ret, prices[i]/prices[i-1]-1)
+	}
+	return ret
+}
+
+// UlcerIndex returns the root mean square of the percentage drawdowns from
+// the running peak of an equity curve, as a fraction (0.05 = 5%). Unlike the
+// maximum drawdown it also reflects how deep and how long the curve stays
+// underwater.
+func UlcerIndex(equity []float64) float64 {
+	if len(equity) == 0 {
+		return 0
+	}
+	var peak, ss float64
+	for _, v := range equity {
+		if v > peak {
+			peak = v
+		}
+		if peak <= 0 {
+			continue
+		}
+		dd := (peak - v) / peak
+		ss += dd * dd
+	}
+	return math.Sqrt(ss / float64(len(equity)))
+}
+
+// moments returns the second, third and fourth central moments of xs
+// (population definitions, dividing by n).
+func moments(xs []float64) (m2, m3, m4 float64) {
+	m := Mean(xs)
+	for _, x := range xs {
+		d := x - m
+		d2 := d * d
+		m2 += d2
+		m3 += d2 * d
+		m4 += d2 * d2
+	}
+	n := float64(len(xs))
+	return m2 / n, m3 / n, m4 / n
+}
+
+// Skewness returns the moment coefficient of skewness of xs. It is negative
+// when the left tail is longer, e.g. a few large losses among many small
+// wins, and 0 when fewer than three values are given or xs is constant.
+func Skewness(xs []float64) float64 {
+	if len(xs) < 3 {
+		return 0
+	}
+	m2, m3, _ := moments(xs)
+	if m2 == 0 {
+		return 0
+	}
+	return m3 / math.Pow(m2, 1.5)
+}
+
+// ExcessKurtosis returns the kurtosis of xs minus 3, the kurtosis of a
+// normal distribution; positive values mean fatter tails. It is 0 when fewer
+// than four values are given or xs is constant.
+func ExcessKurtosis(xs []float64) float64 {
+	if len(xs) < 4 {
+		return 0
+	}
+	m2, _, m4 := moments(xs)
+	if m2 == 0 {
+		return 0
+	}
+	return m4/(m2*m2) - 3
+}
@@ -0,0 +1,76 @@
+package stats
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPearson(t *testing.T) {
+	cases := []struct {
+		name string
+		xs   []float64
+		ys   []float64
+		want float64
+		ok   bool
+	}{
+		{"perfect", []float64{1, 2, 3, 4}, []float64{2, 4, 6, 8}, 1, true},
+		{"inverse", []float64{1, 2, 3, 4}, []float64{8, 6, 4, 2}, -1, true},
+		{"constant", []float64{1, 1, 1}, []float64{1, 2, 3}, 0, false},
+		{"length mismatch", []float64{1, 2}, []float64{1}, 0, false},
+	}
+	for _, c := range cases {
+		got, ok := Pearson(c.xs, c.ys)
+		if ok != c.ok || math.Abs(got-c.want) > 1e-12 {
+			t.Fatalf("%s: got (%v, %v), want (%v, %v)", c.name, got, ok, c.want, c.ok)
+		}
+	}
+}
+
+func TestMeanStdDevReturns(t *testing.T) {
+	if m := Mean([]float64{1, 2, 3}); m != 2 {
+		t.Fatalf("mean = %v", m)
+	}
+	if s := StdDev([]float64{2, 4, 4, 4, 5, 5, 7, 9}); math.Abs(s-2.138089935299395) > 1e-12 {
+		t.Fatalf("stddev = %v", s)
+	}
+	r := Returns([]float64{100, 110, 99})
+	if len(r) != 2 || math.Abs(r[0]-0.1) > 1e-12 || math.Abs(r[1]+0.1) > 1e-12 {
+		t.Fatalf("returns = %v", r)
+	}
+}
+
+func TestUlcerIndex(t *testing.T) {
+	if u := UlcerIndex([]float64{100, 110, 120}); u != 0 {
+		t.Fatalf("monotonic curve ulcer = %v", u)
+	}
+	// drawdowns: 0, 0, 0.1, 0.2, 0 -> sqrt((0.01+0.04)/5)
+	u := UlcerIndex([]float64{100, 100, 90, 80, 100})
+	if math.Abs(u-math.Sqrt(0.05/5)) > 1e-12 {
+		t.Fatalf("ulcer = %v", u)
+	}
+	if u := UlcerIndex(nil); u != 0 {
+		t.Fatalf("empty ulcer = %v", u)
+	}
+}
+
+func TestSkewnessKurtosis(t *testing.T) {
+	symmetric := []float64{-2, -1, 0, 1, 2}
+	if s := Skewness(symmetric); math.Abs(s) > 1e-12 {
+		t.Fatalf("symmetric skewness = %v", s)
+	}
+	// m2 = 2, m4 = 6.8 -> 6.8/4 - 3
+	if k := ExcessKurtosis([]float64{-2, -1, 0, 1, 2}); math.Abs(k+1.3) > 1e-12 {
+		t.Fatalf("kurtosis = %v", k)
+	}
+	// many small wins and one large loss
+	tail := []float64{1, 1, 1, 1, 1, 1, 1, 1, 1, -20}
+	if s := Skewness(tail); s >= -2 {
+		t.Fatalf("left tail skewness = %v", s)
+	}
+	if k := ExcessKurtosis(tail); k <= 3 {
+		t.Fatalf("fat tail kurtosis = %v", k)
+	}
+	if Skewness([]float64{1, 1, 1}) != 0 || ExcessKurtosis([]float64{1, 2}) != 0 {
+		t.Fatal("degenerate input not 0")
+	}
+}
@@ -0,0 +1,97 @@
+package strategysrc
+
+import (
+	"go/parser"
+	"go/token"
+	"strconv"
+	"strings"
+)
+
+// DefaultAllowedImports are the packages a strategy is expected to import:
+// the ztrade data model and indicators, and the pure computation parts of
+// the standard library. Anything that reaches the file system, the network,
+// other processes or the runtime (os, net, os/exec, syscall, unsafe,
+// plugin, reflect, ...) is left out, because a strategy runs inside the
+// server process. So are the other ztrade packages: they reach files,
+// commands, exchange clients and the databases.
+var DefaultAllowedImports = []string{
+	"github.com/ztrade/trademodel",
+	"github.com/ztrade/indicator",
+	"math/...",
+	"time",
+	"fmt",
+	"strings",
+	"strconv",
+	"sort",
+	"slices",
+	"maps",
+	"cmp",
+	"errors",
+	"sync",
+	"sync/atomic",
+	"bytes",
+	"unicode/...",
+	"container/...",
+	"encoding/json",
+	"regexp",
+}
+
+// Import is one import declaration of a strategy source.
+type Import struct {
+	Path    string `json:"path"`
+	Name    string `json:"name,omitempty"` // explicit name, "." or "_"
+	Line    int    `json:"line"`
+	Stdlib  bool   `json:"stdlib"`
+	Allowed bool   `json:"allowed"`
+}
+
+// Imports parses Go strategy source and returns its imports in source
+// order, each marked against allowlist (see ImportAllowed).
+func Imports(content string, allowlist []string) ([]Import, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "strategy.go", content, parser.ImportsOnly)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]Import, 0, len(f.Imports))
+	for _, spec := range f.Imports {
+		path, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			path = spec.Path.Value
+		}
+		imp := Import{
+			Path:    path,
+			Line:    fset.Position(spec.Pos()).Line,
+			Stdlib:  IsStdlib(path),
+			Allowed: ImportAllowed(path, allowlist),
+		}
+		if spec.Name != nil {
+			imp.Name = spec.Name.Name
+		}
+		ret = append(ret, imp)
+	}
+	return ret, nil
+}
+
+// ImportAllowed reports whether path matches an allowlist entry. An entry
+// ending in "/..." matches the package and everything below it, like the
+// go command's patterns.
+func ImportAllowed(path string, allowlist []string) bool {
+	for _, pattern := range allowlist {
+		if prefix, ok := strings.CutSuffix(pattern, "/..."); ok {
+			if path == prefix || strings.HasPrefix(path, prefix+"/") {
+				return true
+			}
+		} else if path == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// IsStdlib reports whether path looks like a standard library package: its
+// first element has no dot, unlike module paths such as github.com/x/y.
+func IsStdlib(path string) bool {
+	first, _, _ := strings.Cut(path, "/")
+	return !strings.Contains(first, ".") && path != "C"
+}
@@ -0,0 +1,62 @@
+package strategysrc
+
+import "testing"
+
+const importsSrc = `package strategy
+
+import (
+	"math"
+	"os/exec"
+	ta "github.com/ztrade/indicator"
+	. "github.com/ztrade/trademodel"
+	"github.com/evil/exfil"
+)
+`
+
+func TestImports(t *testing.T) {
+	imports, err := Imports(importsSrc, DefaultAllowedImports)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(imports) != 5 {
+		t.Fatalf("imports: %+v", imports)
+	}
+	want := []struct {
+		path            string
+		name            string
+		stdlib, allowed bool
+	}{
+		{"math", "", true, true},
+		{"os/exec", "", true, false},
+		{"github.com/ztrade/indicator", "ta", false, true},
+		{"github.com/ztrade/trademodel", ".", false, true},
+		{"github.com/evil/exfil", "", false, false},
+	}
+	for i, w := range want {
+		got := imports[i]
+		if got.Path != w.path || got.Name != w.name || got.Stdlib != w.stdlib || got.Allowed != w.allowed {
+			t.Errorf("import %d = %+v, want %+v", i, got, w)
+		}
+	}
+	if imports[0].Line != 4 {
+		t.Errorf("line = %d", imports[0].Line)
+	}
+	if _, err := Imports("package x\nimport (", nil); err == nil {
+		t.Error("syntax error accepted")
+	}
+}
+
+func TestImportAllowed(t *testing.T) {
+	allow := []string{"math/...", "time"}
+	for path, want := range map[string]bool{
+		"math":        true,
+		"math/rand":   true,
+		"mathx":       false,
+		"time":        true,
+		"time/tzdata": false,
+	} {
+		if got := ImportAllowed(path, allow); got != want {
+			t.Errorf("ImportAllowed(%q) = %v", path, got)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package strategysrc
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+)
+
+// paramTypes maps the parameter constructors to the type of their value.
+var paramTypes = map[string]string{
+	"IntParam":    "int",
+	"FloatParam":  "float",
+	"StringParam": "string",
+	"BoolParam":   "bool",
+}
+
+// ParamSpec is one parameter a strategy declares with an XxxParam call.
+// Default and Enums hold literal values only; Enums is empty when the
+// parameter has none or one of them is not a literal. Positive is set when
+// the bound field is an indicator argument, a period or length that must be
+// above zero.
+type ParamSpec struct {
+	Name     string        `json:"name"`
+	Type     string        `json:"type"` // int, float, string or bool
+	Field    string        `json:"field,omitempty"`
+	Default  interface{}   `json:"default,omitempty"`
+	Enums    []interface{} `json:"enums,omitempty"`
+	Positive bool          `json:"positive,omitempty"`
+}
+
+// Params parses Go strategy source and returns the parameters of its
+// XxxParam calls in source order. Calls whose key is not a string literal
+// are skipped.
+func Params(content string) ([]ParamSpec, error) {
+	f, err := parser.ParseFile(token.NewFileSet(), "strategy.go", content, parser.SkipObjectResolution)
+	if err != nil {
+		return nil, err
+	}
+
+	var specs []ParamSpec
+	indicatorArgs := map[string]bool{}
+	ast.Inspect(f, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		switch name := calleeName(call.Fun); name {
+		case "AddIndicator", "NewCommonIndicator":
+			for _, arg := range call.Args {
+				switch arg.(type) {
+				case *ast.Ident, *ast.SelectorExpr:
+					indicatorArgs[calleeName(arg)] = true
+				}
+			}
+		default:
+			typ, ok := paramTypes[name]
+			if !ok || len(call.Args) < 5 {
+				return true
+			}
+			key, ok := literal(call.Args[0])
+			if _, isStr := key.(string); !ok || !isStr {
+				return true
+			}
+			spec := ParamSpec{Name: key.(string), Type: typ}
+			if ref, ok := call.Args[4].(*ast.UnaryExpr); ok && ref.Op == token.AND {
+				spec.Field = calleeName(ref.X)
+			}
+			spec.Default, _ = constValue(call.Args[3])
+			for _, arg := range call.Args[5:] {
+				v, ok := entryValue(arg)
+				if !ok {
+					spec.Enums = nil
+					break
+				}
+				spec.Enums = append(spec.Enums, v)
+			}
+			specs = append(specs, spec)
+		}
+		return true
+	})
+	for i := range specs {
+		specs[i].Positive = specs[i].Field != "" && indicatorArgs[specs[i].Field] &&
+			(specs[i].Type == "int" || specs[i].Type == "float")
+	}
+	return specs, nil
+}
+
+// constValue is literal extended to negative numbers and true/false.
+func constValue(x ast.Expr) (interface{}, bool) {
+	switch e := x.(type) {
+	case *ast.UnaryExpr:
+		if e.Op != token.SUB {
+			return nil, false
+		}
+		switch v, _ := literal(e.X); n := v.(type) {
+		case int64:
+			return -n, true
+		case float64:
+			return -n, true
+		}
+		return nil, false
+	case *ast.Ident:
+		switch e.Name {
+		case "true":
+			return true, true
+		case "false":
+			return false, true
+		}
+		return nil, false
+	}
+	return literal(x)
+}
+
+// entryValue returns the Value of an Entry composite literal, given by key
+// or as the first element.
+func entryValue(x ast.Expr) (interface{}, bool) {
+	lit, ok := x.(*ast.CompositeLit)
+	if !ok || len(lit.Elts) == 0 {
+		return nil, false
+	}
+	for _, elt := range lit.Elts {
+		if kv, ok := elt.(*ast.KeyValueExpr); ok {
+			if calleeName(kv.Key) == "Value" {
+				return constValue(kv.Value)
+			}
+			continue
+		}
+		return constValue(elt)
+	}
+	return nil, false
+}
@@ -0,0 +1,54 @@
+package strategysrc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParams(t *testing.T) {
+	src := `package strategy
+
+import . "github.com/ztrade/trademodel"
+
+type S struct {
+	period, mode string
+	fast         int
+	ratio        float64
+	short        bool
+}
+
+func (s *S) Param() (paramInfo []Param) {
+	paramInfo = []Param{
+		StringParam("period", "周期", "", "1h", &s.period, Entry{Value: "15m", Label: "15m"}, Entry{Value: "1h", Label: "1h"}),
+		IntParam("fast", "快线", "", 12, &s.fast),
+		FloatParam("ratio", "比例", "", -0.5, &s.ratio),
+		BoolParam("short", "做空", "", true, &s.short),
+		StringParam("mode", "模式", "", "a", &s.mode, Entry{"a", "A"}, Entry{pick(), "B"}),
+		IntParam(key(), "", "", 1, &s.fast),
+	}
+	return
+}
+
+func (s *S) Init(engine Engine, params ParamData) (err error) {
+	engine.AddIndicator("EMA", s.fast, 26)
+	return
+}
+`
+	specs, err := Params(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ParamSpec{
+		{Name: "period", Type: "string", Field: "period", Default: "1h", Enums: []interface{}{"15m", "1h"}},
+		{Name: "fast", Type: "int", Field: "fast", Default: int64(12), Positive: true},
+		{Name: "ratio", Type: "float", Field: "ratio", Default: -0.5},
+		{Name: "short", Type: "bool", Field: "short", Default: true},
+		{Name: "mode", Type: "string", Field: "mode", Default: "a"},
+	}
+	if !reflect.DeepEqual(specs, want) {
+		t.Fatalf("Params =\n%+v\nwant\n%+v", specs, want)
+	}
+	if _, err := Params("not go"); err == nil {
+		t.Fatal("invalid source accepted")
+	}
+}
@@ -0,0 +1,106 @@
+package strategysrc
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strings"
+)
+
+const (
+	// TagStopLoss and TagNoStopLoss are the auto-tags maintained for
+	// strategies depending on detected risk controls.
+	TagStopLoss   = "stop-loss"
+	TagNoStopLoss = "no-stop-loss"
+)
+
+// stopLossCalls are engine methods that place or manage protective orders.
+var stopLossCalls = map[string]bool{
+	"StopLong":       true,
+	"StopShort":      true,
+	"CancelAllOrder": true,
+}
+
+// stopLossTradeTypes are trademodel TradeType constants that, passed to
+// DoOrder, place a stop order.
+var stopLossTradeTypes = map[string]bool{
+	"StopLong":  true,
+	"StopShort": true,
+}
+
+// RiskControls describes the risk controls detected in a strategy source.
+type RiskControls struct {
+	HasStopLoss bool     `json:"hasStopLoss"`
+	StopCalls   []string `json:"stopCalls,omitempty"`
+}
+
+// AnalyzeRiskControls parses Go strategy source and reports which stop-loss
+// related engine calls it makes. Only real call expressions are counted, so
+// occurrences in strings and comments are ignored.
+func AnalyzeRiskControls(content string) (RiskControls, error) {
+	var rc RiskControls
+	f, err := parser.ParseFile(token.NewFileSet(), "strategy.go", content, parser.SkipObjectResolution)
+	if err != nil {
+		return rc, err
+	}
+	found := map[string]bool{}
+	ast.Inspect(f, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		name := calleeName(call.Fun)
+		if stopLossCalls[name] {
+			found[name] = true
+		} else if name == "DoOrder" && len(call.Args) > 0 && stopLossTradeTypes[calleeName(call.Args[0])] {
+			found["DoOrder("+calleeName(call.Args[0])+")"] = true
+		}
+		return true
+	})
+	for name := range found {
+		rc.StopCalls = append(rc.StopCalls, name)
+	}
+	sort.Strings(rc.StopCalls)
+	rc.HasStopLoss = len(rc.StopCalls) > 0
+	return rc, nil
+}
+
+// calleeName returns the trailing identifier of x, e.g. "StopLong" for
+// s.engine.StopLong or a dot-imported StopLong.
+func calleeName(x ast.Expr) string {
+	switch e := x.(type) {
+	case *ast.Ident:
+		return e.Name
+	case *ast.SelectorExpr:
+		return e.Sel.Name
+	}
+	return ""
+}
+
+// ApplyRiskTags returns the comma-separated tags with the stop-loss auto-tag
+// set according to hasStopLoss, preserving user tags and their order.
+func ApplyRiskTags(tags string, hasStopLoss bool) string {
+	want := TagNoStopLoss
+	if hasStopLoss {
+		want = TagStopLoss
+	}
+	if tags = ClearRiskTags(tags); tags != "" {
+		return tags + "," + want
+	}
+	return want
+}
+
+// ClearRiskTags returns the comma-separated tags without the stop-loss
+// auto-tags, for sources whose stop-loss usage is unknown.
+func ClearRiskTags(tags string) string {
+	var out []string
+	for _, t := range strings.Split(tags, ",") {
+		t = strings.TrimSpace(t)
+		if t == "" || t == TagStopLoss || t == TagNoStopLoss {
+			continue
+		}
+		out = append(out, t)
+	}
+	return strings.Join(out, ",")
+}
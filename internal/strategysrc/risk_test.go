@@ -0,0 +1,63 @@
+package strategysrc
+
+import (
+	"reflect"
+	"testing"
+)
+
+const riskSrc = `package strategy
+
+import . "github.com/ztrade/trademodel"
+
+// StopLong is mentioned here only in a comment
+type S struct{ engine Engine }
+
+func (s *S) OnCandle(c *Candle) {
+	s.engine.Log("StopShort in a string")
+	s.engine.OpenLong(c.Close, 1)
+	s.engine.StopLong(c.Close*0.98, 1)
+	s.engine.DoOrder(StopShort, c.Close*1.02, 1)
+}
+`
+
+const noRiskSrc = `package strategy
+
+type S struct{ engine Engine }
+
+// call StopLong when price drops
+func (s *S) OnCandle(c *Candle) {
+	s.engine.Log("StopLong", "CancelAllOrder")
+	s.engine.OpenLong(c.Close, 1)
+}
+`
+
+func TestAnalyzeRiskControls(t *testing.T) {
+	rc, err := AnalyzeRiskControls(riskSrc)
+	if err != nil {
+		t.Fatalf("analyze: %v", err)
+	}
+	if !rc.HasStopLoss || !reflect.DeepEqual(rc.StopCalls, []string{"DoOrder(StopShort)", "StopLong"}) {
+		t.Fatalf("unexpected result: %+v", rc)
+	}
+
+	rc, err = AnalyzeRiskControls(noRiskSrc)
+	if err != nil {
+		t.Fatalf("analyze: %v", err)
+	}
+	if rc.HasStopLoss {
+		t.Fatalf("comment/string occurrences must not count: %+v", rc)
+	}
+
+	if _, err := AnalyzeRiskControls("not go code"); err == nil {
+		t.Fatalf("expected parse error")
+	}
+}
+
+func TestApplyRiskTags(t *testing.T) {
+	if got := ApplyRiskTags("trend, ema,no-stop-loss", true); got != "trend,ema,stop-loss" {
+		t.Fatalf("got %q", got)
+	}
+	if got := ApplyRiskTags("", false); got != "no-stop-loss" {
+		t.Fatalf("got %q", got)
+	}
+}
@@ -0,0 +1,177 @@
+package strategysrc
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+)
+
+// paramCalls are the trademodel constructors of strategy parameters; the
+// default value is their fourth argument and the bound field the fifth.
+var paramCalls = map[string]bool{
+	"IntParam":    true,
+	"FloatParam":  true,
+	"StringParam": true,
+	"BoolParam":   true,
+}
+
+// IndicatorCall is one AddIndicator (or indicator.NewCommonIndicator) call
+// with its arguments resolved to integers. Arguments that could not be
+// resolved are listed as source in Unresolved and left out of Args.
+type IndicatorCall struct {
+	Name       string   `json:"name"`
+	Args       []int    `json:"args"`
+	Unresolved []string `json:"unresolved,omitempty"`
+}
+
+// MergeCall is one Merge call; Source and Period are its source and
+// destination bin sizes, each empty when it could not be resolved, in which
+// case Unresolved holds the source code of the destination.
+type MergeCall struct {
+	Source     string `json:"source,omitempty"`
+	Period     string `json:"period,omitempty"`
+	Unresolved string `json:"unresolved,omitempty"`
+}
+
+// WarmupCalls are the calls of a strategy source that determine how much
+// history it needs before its indicators are meaningful.
+type WarmupCalls struct {
+	Indicators []IndicatorCall `json:"indicators"`
+	Merges     []MergeCall     `json:"merges"`
+}
+
+// AnalyzeWarmup parses Go strategy source and returns its indicator and
+// Merge calls. Arguments are resolved from literals and from fields bound
+// to a parameter in Param(), taking the value from params (keyed by
+// parameter name, as passed to backtests) or else the parameter default.
+func AnalyzeWarmup(content string, params map[string]interface{}) (WarmupCalls, error) {
+	var wc WarmupCalls
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "strategy.go", content, parser.SkipObjectResolution)
+	if err != nil {
+		return wc, err
+	}
+
+	// field name -> parameter value, from the XxxParam(key, _, _, default, &s.field) calls
+	fields := map[string]interface{}{}
+	ast.Inspect(f, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || !paramCalls[calleeName(call.Fun)] || len(call.Args) < 5 {
+			return true
+		}
+		ref, ok := call.Args[4].(*ast.UnaryExpr)
+		if !ok || ref.Op != token.AND {
+			return true
+		}
+		field := calleeName(ref.X)
+		if key, ok := literal(call.Args[0]); ok {
+			if v, ok := params[fmt.Sprint(key)]; ok {
+				fields[field] = v
+				return true
+			}
+		}
+		if v, ok := literal(call.Args[3]); ok {
+			fields[field] = v
+		}
+		return true
+	})
+	resolve := func(x ast.Expr) (interface{}, bool) {
+		if v, ok := literal(x); ok {
+			return v, true
+		}
+		switch x.(type) {
+		case *ast.Ident, *ast.SelectorExpr:
+			v, ok := fields[calleeName(x)]
+			return v, ok
+		}
+		return nil, false
+	}
+	source := func(x ast.Expr) string {
+		return string(content[fset.Position(x.Pos()).Offset:fset.Position(x.End()).Offset])
+	}
+
+	ast.Inspect(f, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		switch calleeName(call.Fun) {
+		case "AddIndicator", "NewCommonIndicator":
+			if len(call.Args) == 0 {
+				return true
+			}
+			ic := IndicatorCall{Args: []int{}}
+			if v, ok := resolve(call.Args[0]); ok {
+				ic.Name = fmt.Sprint(v)
+			} else {
+				ic.Name = source(call.Args[0])
+			}
+			for i, arg := range call.Args[1:] {
+				v, ok := resolve(arg)
+				n, isNum := toInt(v)
+				if !ok || !isNum || (call.Ellipsis.IsValid() && i == len(call.Args)-2) {
+					ic.Unresolved = append(ic.Unresolved, source(arg))
+					continue
+				}
+				ic.Args = append(ic.Args, n)
+			}
+			wc.Indicators = append(wc.Indicators, ic)
+		case "Merge":
+			if len(call.Args) != 3 {
+				return true
+			}
+			var mc MergeCall
+			if v, ok := resolve(call.Args[0]); ok {
+				mc.Source, _ = v.(string)
+			}
+			if v, ok := resolve(call.Args[1]); ok {
+				if s, isStr := v.(string); isStr {
+					mc.Period = s
+					wc.Merges = append(wc.Merges, mc)
+					return true
+				}
+			}
+			mc.Unresolved = source(call.Args[1])
+			wc.Merges = append(wc.Merges, mc)
+		}
+		return true
+	})
+	return wc, nil
+}
+
+// literal returns the value of a basic literal: a string, an int64 or a
+// float64.
+func literal(x ast.Expr) (interface{}, bool) {
+	lit, ok := x.(*ast.BasicLit)
+	if !ok {
+		return nil, false
+	}
+	switch lit.Kind {
+	case token.STRING:
+		s, err := strconv.Unquote(lit.Value)
+		return s, err == nil
+	case token.INT:
+		n, err := strconv.ParseInt(lit.Value, 0, 64)
+		return n, err == nil
+	case token.FLOAT:
+		f, err := strconv.ParseFloat(lit.Value, 64)
+		return f, err == nil
+	}
+	return nil, false
+}
+
+// toInt converts a resolved argument to an int; parameter values from JSON
+// arrive as float64.
+func toInt(v interface{}) (int, bool) {
+	switch n := v.(type) {
+	case int64:
+		return int(n), true
+	case float64:
+		return int(n), n == float64(int(n))
+	case int:
+		return n, true
+	}
+	return 0, false
+}
@@ -0,0 +1,67 @@
+package strategysrc
+
+import (
+	"reflect"
+	"testing"
+)
+
+const warmupSrc = `package strategy
+
+import . "github.com/ztrade/trademodel"
+
+type S struct {
+	engine Engine
+	period string
+	fast   int
+	slow   int
+	lens   []int
+}
+
+func (s *S) Param() (paramInfo []Param) {
+	paramInfo = []Param{
+		StringParam("period", "周期", "", "1h", &s.period),
+		IntParam("fast", "快线", "", 12, &s.fast),
+		IntParam("slow", "慢线", "", 26, &s.slow),
+	}
+	return
+}
+
+func (s *S) Init(engine Engine, params ParamData) (err error) {
+	engine.AddIndicator("EMA", s.fast, s.slow)
+	engine.AddIndicator("MACD", 12, 26, 9)
+	engine.AddIndicator("SMA", s.lens...)
+	engine.Merge("1m", s.period, s.OnCandlePeriod)
+	engine.Merge("1m", "15m", s.OnCandle15m)
+	engine.Merge("1m", pick(), s.OnCandle15m)
+	return
+}
+`
+
+func TestAnalyzeWarmup(t *testing.T) {
+	wc, err := AnalyzeWarmup(warmupSrc, map[string]interface{}{"slow": 50.0, "period": "4h"})
+	if err != nil {
+		t.Fatalf("analyze: %v", err)
+	}
+	want := []IndicatorCall{
+		{Name: "EMA", Args: []int{12, 50}},
+		{Name: "MACD", Args: []int{12, 26, 9}},
+		{Name: "SMA", Args: []int{}, Unresolved: []string{"s.lens"}},
+	}
+	if !reflect.DeepEqual(wc.Indicators, want) {
+		t.Fatalf("indicators = %+v", wc.Indicators)
+	}
+	wantMerges := []MergeCall{{Source: "1m", Period: "4h"}, {Source: "1m", Period: "15m"}, {Source: "1m", Unresolved: "pick()"}}
+	if !reflect.DeepEqual(wc.Merges, wantMerges) {
+		t.Fatalf("merges = %+v", wc.Merges)
+	}
+
+	// without params the defaults apply
+	wc, _ = AnalyzeWarmup(warmupSrc, nil)
+	if wc.Indicators[0].Args[1] != 26 || wc.Merges[0].Period != "1h" {
+		t.Fatalf("defaults: %+v", wc)
+	}
+
+	if _, err := AnalyzeWarmup("not go code", nil); err == nil {
+		t.Fatalf("expected parse error")
+	}
+}
@@ -1,12 +1,16 @@
 package main
 
 import (
+	"database/sql"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
 
 	"github.com/mark3labs/mcp-go/server"
 	log "github.com/sirupsen/logrus"
@@ -16,6 +20,7 @@ import (
 	"github.com/ztrade/ztrade/pkg/process/dbstore"
 
 	"github.com/ztrade/ztrade-mcp/auth"
+	"github.com/ztrade/ztrade-mcp/internal/logging"
 	"github.com/ztrade/ztrade-mcp/prompts"
 	"github.com/ztrade/ztrade-mcp/resources"
 	"github.com/ztrade/ztrade-mcp/store"
@@ -31,14 +36,41 @@ func main() {
 	transport := flag.String("transport", "stdio", "transport mode: stdio, http")
 	listen := flag.String("listen", ":8080", "listen address for http transport")
 	debug := flag.Bool("debug", false, "enable debug logging")
+	strict := flag.Bool("strict", false, "refuse to start when the config has problems (also mcp.strictConfig)")
+	hashToken := flag.String("hash-token", "", "print the hash of a token/api key for the auth config and exit")
+	hashAlgo := flag.String("hash-algo", auth.HashAlgoSHA256, "hash algorithm for --hash-token: sha256, bcrypt")
 	flag.Parse()
 
-	if *debug {
-		log.SetLevel(log.DebugLevel)
+	if *hashToken != "" {
+		hash, err := auth.HashToken(*hashToken, *hashAlgo)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "hash token failed: %s\n", err.Error())
+			os.Exit(1)
+		}
+		fmt.Println(hash)
+		return
 	}
 
 	// Load config
-	cfg := loadConfig(*cfgFile)
+	cfg, cfgErr := loadConfig(*cfgFile)
+
+	if err := logging.Setup(cfg, *debug); err != nil {
+		log.Fatalf("invalid log config: %s", err.Error())
+	}
+
+	// Load auth config
+	authCfg := auth.LoadConfig(cfg)
+	if err := authCfg.Err(); err != nil {
+		log.Fatalf("invalid auth config: %s", err.Error())
+	}
+
+	problems := validateConfig(cfg, cfgErr, authCfg)
+	for _, p := range problems {
+		log.Warn("config: " + p)
+	}
+	if len(problems) > 0 && (*strict || cfg.GetBool("mcp.strictConfig")) {
+		log.Fatalf("refusing to start in strict mode: %d config problem(s)", len(problems))
+	}
 
 	// Init DB
 	db, err := dbstore.LoadDB(cfg)
@@ -56,15 +88,15 @@ func main() {
 		log.Warnf("init script store failed: %s (script management tools may not work)", err.Error())
 	}
 
-	// Load auth config
-	authCfg := auth.LoadConfig(cfg)
-
 	// Build server options
 	serverOpts := []server.ServerOption{
 		server.WithToolCapabilities(true),
 		server.WithResourceCapabilities(true, true),
 		server.WithPromptCapabilities(true),
 		server.WithRecovery(),
+		server.WithToolHandlerMiddleware(logging.ToolMiddleware()),
+		server.WithToolHandlerMiddleware(tools.ResultSizeMiddleware(cfg)),
+		server.WithToolHandlerMiddleware(tools.ToolTimeoutMiddleware(cfg)),
 	}
 
 	// Add auth middleware if enabled
@@ -79,7 +111,7 @@ func main() {
 	tools.RegisterAll(mcpServer, db, cfg, scriptStore)
 
 	// Register resources
-	resources.RegisterAll(mcpServer)
+	resources.RegisterAll(mcpServer, scriptStore)
 
 	// Register prompts
 	prompts.RegisterAll(mcpServer)
@@ -138,7 +170,9 @@ func main() {
 	}
 }
 
-func loadConfig(cfgFile string) *viper.Viper {
+// loadConfig reads the config file given or found on the default paths.
+// The error is nil when no file was given and none was found.
+func loadConfig(cfgFile string) (*viper.Viper, error) {
 	v := viper.New()
 	if cfgFile != "" {
 		v.SetConfigFile(cfgFile)
@@ -156,8 +190,58 @@ func loadConfig(cfgFile string) *viper.Viper {
 		v.SetConfigName("ztrade")
 	}
 	v.AutomaticEnv()
-	if err := v.ReadInConfig(); err == nil {
+	err := v.ReadInConfig()
+	if err == nil {
 		fmt.Fprintln(os.Stderr, "Using config file:", v.ConfigFileUsed())
 	}
-	return v
+	if _, ok := err.(viper.ConfigFileNotFoundError); ok {
+		err = nil
+	}
+	return v, err
+}
+
+// validateConfig lists the settings that are missing or inconsistent in a
+// way that would otherwise only show up as a tool failing mid-call.
+func validateConfig(cfg *viper.Viper, readErr error, authCfg *auth.Config) []string {
+	var problems []string
+	if readErr != nil {
+		problems = append(problems, fmt.Sprintf("failed to read config file: %s; running on defaults and environment", readErr.Error()))
+	}
+
+	dbType, dbURI := cfg.GetString("db.type"), cfg.GetString("db.uri")
+	switch {
+	case dbType == "" || dbURI == "":
+		problems = append(problems, "db.type and db.uri must both be set; kline data, backtests and strategy management will not work")
+	case !slices.Contains(sql.Drivers(), dbType):
+		problems = append(problems, fmt.Sprintf("db.type %q has no database driver (available: %s)", dbType, strings.Join(sql.Drivers(), ", ")))
+	}
+
+	var names []string
+	for name := range cfg.GetStringMap("exchanges") {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		prefix := "exchanges." + name
+		typ := cfg.GetString(prefix + ".type")
+		if typ == "" {
+			problems = append(problems, fmt.Sprintf("%s has no type (supported: binance, okx, ctp); tools cannot use it", prefix))
+			continue
+		}
+		if typ == "ctp" {
+			continue
+		}
+		key, secret := cfg.GetString(prefix+".key"), cfg.GetString(prefix+".secret")
+		if key == "" || key == "YOUR_API_KEY_HERE" || secret == "" || secret == "YOUR_API_SECRET_HERE" {
+			problems = append(problems, fmt.Sprintf("%s (%s) has no key/secret; market data works but positions, open orders and trading will fail", prefix, typ))
+		} else if typ == "okx" && cfg.GetString(prefix+".pwd") == "" {
+			problems = append(problems, fmt.Sprintf("%s (okx) has no pwd (API passphrase); authenticated requests will fail", prefix))
+		}
+	}
+
+	if cfg.GetBool("mcp.enableLiveTrade") && len(names) == 0 {
+		problems = append(problems, "mcp.enableLiveTrade is true but no exchanges are configured")
+	}
+
+	return append(problems, authCfg.Problems()...)
 }
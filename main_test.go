@@ -0,0 +1,39 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+
+	"github.com/ztrade/ztrade-mcp/auth"
+)
+
+func TestValidateConfig(t *testing.T) {
+	v := viper.New()
+	v.Set("db.type", "sqlite")
+	v.Set("db.uri", "ztrade.db")
+	v.Set("exchanges.binance", map[string]interface{}{"type": "binance", "key": "k", "secret": "s"})
+	if problems := validateConfig(v, nil, auth.LoadConfig(v)); len(problems) != 0 {
+		t.Fatalf("valid config: %v", problems)
+	}
+
+	v = viper.New()
+	v.Set("db.type", "oracle")
+	v.Set("db.uri", "x")
+	v.Set("exchanges.a", map[string]interface{}{"key": "k"})
+	v.Set("exchanges.b", map[string]interface{}{"type": "binance", "key": "YOUR_API_KEY_HERE", "secret": "s"})
+	v.Set("exchanges.c", map[string]interface{}{"type": "okx", "key": "k", "secret": "s"})
+	v.Set("mcp.auth.enabled", true)
+	problems := validateConfig(v, errors.New("bad yaml"), auth.LoadConfig(v))
+	want := []string{"failed to read config file", "db.type \"oracle\"", "exchanges.a has no type", "exchanges.b (binance) has no key/secret", "exchanges.c (okx) has no pwd", "mcp.auth.tokens has no usable entries"}
+	if len(problems) != len(want) {
+		t.Fatalf("problems: %v", problems)
+	}
+	for i, w := range want {
+		if !strings.Contains(problems[i], w) {
+			t.Errorf("problem %d = %q, want %q", i, problems[i], w)
+		}
+	}
+}
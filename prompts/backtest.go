@@ -5,6 +5,8 @@ import (
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
+
+	"github.com/ztrade/ztrade-mcp/internal/metricgrade"
 )
 
 func registerBacktestPrompt(s *server.MCPServer) {
@@ -36,11 +38,13 @@ func registerBacktestPrompt(s *server.MCPServer) {
 - **MaxDrawdownValue**: Maximum drawdown in absolute value
 - **MaxLose**: Largest single-trade loss percentage
 - **Volatility**: Annualized volatility
+- **UlcerIndex**: RMS of drawdowns from the running peak over the equity curve — captures how deep and how long the strategy stays underwater, not just the worst dip (<5% good, >15% painful)
 
 ### Risk-Adjusted Metrics
 - **SharpeRatio**: Risk-adjusted return (>1 good, >2 excellent, >3 exceptional)
 - **SortinoRatio**: Downside-risk-adjusted return (better than Sharpe for asymmetric returns)
 - **CalmarRatio**: Annual return / max drawdown (>1 good, >3 excellent)
+- **MartinRatio**: Annual return / Ulcer Index — a Calmar variant that penalizes prolonged drawdowns (>2 good, >5 excellent)
 - **ProfitFactor**: Gross profit / gross loss (>1.5 good, >2 excellent)
 
 ### Trade Statistics
@@ -56,14 +60,7 @@ func registerBacktestPrompt(s *server.MCPServer) {
 
 ## Evaluation Guidelines
 
-| Metric | Poor | Acceptable | Good | Excellent |
-|--------|------|-----------|------|-----------|
-| Sharpe Ratio | <0.5 | 0.5-1.0 | 1.0-2.0 | >2.0 |
-| Max Drawdown | >30% | 20-30% | 10-20% | <10% |
-| Win Rate | <30% | 30-45% | 45-60% | >60% |
-| Profit Factor | <1.0 | 1.0-1.5 | 1.5-2.5 | >2.5 |
-| Calmar Ratio | <0.5 | 0.5-1.0 | 1.0-3.0 | >3.0 |
-
+` + metricgrade.MarkdownTable() + `
 ## Common Optimization Suggestions
 1. High drawdown → Add stop-loss, reduce position size, add risk management
 2. Low win rate but profitable → Improve entry timing, consider trend filters
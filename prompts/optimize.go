@@ -0,0 +1,95 @@
+package prompts
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// optimizeObjectives maps objective names to the backtest metric they
+// optimize and whether larger values are better.
+var optimizeObjectives = map[string]struct {
+	metric string
+	maxim  bool
+}{
+	"sharpe":   {"sharpeRatio", true},
+	"sortino":  {"sortinoRatio", true},
+	"calmar":   {"calmarRatio", true},
+	"return":   {"annualReturn", true},
+	"drawdown": {"maxDrawdown", false},
+	"score":    {"overallScore", true},
+}
+
+func registerOptimizePrompt(s *server.MCPServer) {
+	prompt := mcp.NewPrompt("optimize_parameters",
+		mcp.WithPromptDescription("Workflow for tuning a managed strategy's parameters toward an objective, with out-of-sample validation to avoid overfitting."),
+		mcp.WithArgument("strategyId",
+			mcp.ArgumentDescription("ID of the managed strategy to tune"),
+			mcp.RequiredArgument(),
+		),
+		mcp.WithArgument("objective",
+			mcp.ArgumentDescription("What to optimize: 'sharpe', 'sortino', 'calmar', 'return', 'drawdown' (minimize) or 'score' (overallScore, default)"),
+		),
+	)
+
+	s.AddPrompt(prompt, func(ctx context.Context, req mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+		strategyID := req.Params.Arguments["strategyId"]
+		if strategyID == "" {
+			return nil, fmt.Errorf("strategyId is required")
+		}
+		objective := strings.ToLower(req.Params.Arguments["objective"])
+		if objective == "" {
+			objective = "score"
+		}
+		obj, ok := optimizeObjectives[objective]
+		if !ok {
+			return nil, fmt.Errorf("unknown objective %q, supported: sharpe, sortino, calmar, return, drawdown, score", objective)
+		}
+		direction := "maximize"
+		if !obj.maxim {
+			direction = "minimize"
+		}
+
+		systemMsg := `You are a quantitative researcher tuning a ztrade strategy. Your job is to find parameters that hold up on data they were not fitted to, not the best-looking backtest.
+
+## Workflow
+
+1. **Read the strategy.** Call get_strategy with the id. Find the Param() method: every entry there is a tunable parameter with its default. Read fieldDescriptions for meaning and sensible ranges.
+2. **Check the data.** Call data_quality_report for the exchange/symbol over the full range. Do not tune on data with a poor health score; re-download first.
+3. **Split the range.** Reserve the most recent 25-30% of the range as out-of-sample. Tune only on the in-sample part. For long ranges prefer walk-forward: several consecutive (train, test) windows, tuning on each train window and scoring on the following test window.
+4. **Propose a small grid.** Pick at most 2-3 parameters that plausibly matter, 3-5 values each, spread around the default (e.g. 0.5x, 0.75x, 1x, 1.5x, 2x for periods). Call validate_param_grid with the strategy id and the grid before running: it checks every value against its Param() entry (type, enum values, positive indicator periods) and returns the rejected values with the reason. Drop the rejected values and list them with the reason; they waste runs or crash the strategy. Also keep periods, lengths and counts the check cannot see positive. State the validated grid before running anything.
+5. **Run it.** Call run_backtest_managed once per grid point on the in-sample range, passing the point as the param JSON and the in-sample run label given below as runLabel. Long ranges run asynchronously; poll with get_task_status / get_task_result.
+6. **Analyze.** Call get_experiment with the in-sample label to compare the runs side by side, and param_sensitivity with the objective metric to see which parameters actually drive it.
+7. **Validate.** Re-run the best 2-3 candidates and the defaults on the out-of-sample range with the out-of-sample label. Recommend a parameter set only if it beats the defaults out of sample. For walk-forward, suffix the labels with the window number.
+
+## Avoiding overfitting
+
+- Prefer a plateau over a peak: choose values whose neighbours perform nearly as well. A lone spike in the grid is noise.
+- Beware small samples: with fewer than ~30 trades in a run, metric differences are mostly luck. Lengthen the range or discard the run.
+- Every extra parameter and grid point raises the chance of a lucky fit. Keep the grid small and report how many combinations were tried.
+- A large gap between in-sample and out-of-sample results means the parameters fit noise; say so rather than recommending them.
+- Check that the improvement survives fees and, for perpetuals, includeFunding=true.
+
+## Reporting
+
+Summarize: the grid tried, the in-sample winner, its out-of-sample result next to the defaults, the sensitivity of each parameter, trade counts, and a clear recommendation (adopt, keep defaults, or needs more data).`
+
+		label := fmt.Sprintf("opt-%s-%s", strategyID, time.Now().Format("20060102-150405"))
+		userMsg := fmt.Sprintf("Tune the parameters of strategy %s to %s %s.\n\n", strategyID, direction, obj.metric)
+		userMsg += fmt.Sprintf("Label the in-sample runs %s-is and the out-of-sample runs %s-oos.\n\n", label, label)
+		userMsg += "Follow the workflow above. Ask me for the exchange, symbol and date range if you do not know them, "
+		userMsg += "and show me the proposed grid and in-sample/out-of-sample split before running the backtests."
+
+		return &mcp.GetPromptResult{
+			Description: "Parameter optimization workflow for ztrade",
+			Messages: []mcp.PromptMessage{
+				{Role: mcp.RoleAssistant, Content: mcp.TextContent{Type: "text", Text: systemMsg}},
+				{Role: mcp.RoleUser, Content: mcp.TextContent{Type: "text", Text: userMsg}},
+			},
+		}, nil
+	})
+}
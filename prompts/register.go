@@ -8,4 +8,5 @@ import (
 func RegisterAll(s *server.MCPServer) {
 	registerStrategyPrompt(s)
 	registerBacktestPrompt(s)
+	registerOptimizePrompt(s)
 }
@@ -5,6 +5,7 @@ import (
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
+	"github.com/ztrade/ztrade-mcp/internal/indicatorspec"
 )
 
 func registerStrategyPrompt(s *server.MCPServer) {
@@ -56,20 +57,10 @@ func registerStrategyPrompt(s *server.MCPServer) {
 - Log()/SendNotify()/UpdateStatus()/Watch()
 
 ## 内置指标
-| 指标      | 参数                | 示例                              | 说明         |
-|-----------|---------------------|-----------------------------------|--------------|
-| EMA       | 1或2: 单线/交叉     | AddIndicator("EMA", 9, 26)        | 指数均线      |
-| SMA       | 1或2: 单线/交叉     | AddIndicator("SMA", 20)           | 简单均线      |
-| SSMA      | 1或2: 单线/交叉     | AddIndicator("SSMA", 9, 26)       | 平滑均线      |
-| MACD      | 3:快/慢/DEA         | AddIndicator("MACD", 12,26,9)     | MACD         |
-| SMAMACD   | 3:快/慢/DEA         | AddIndicator("SMAMACD",12,26,9)   | SMA版MACD     |
-| BOLL      | 长度、倍数          | AddIndicator("BOLL", 20, 2)       | 布林带        |
-| RSI       | 1或2: 单线/交叉     | AddIndicator("RSI", 14)           | 相对强弱      |
-| STOCHRSI  | 4:窗口/平滑         | AddIndicator("STOCHRSI",14,14,3,3)| 随机RSI       |
-
+` + indicatorspec.Markdown() + `
 ### 指标返回值
-- ind.Result() 当前值（双线时为快线）
-- ind.Indicator() map[string]float64 详细值（fast/slow/crossUp/crossDown/top/bottom等）
+- ind.Result() 单线指标的值（双线形式取 fast/slow）
+- ind.Indicator() map[string]float64 详细值，见上表"输出"列
 
 ## 重要说明
 1. 数据基础：所有数据以1m为基础，合成大周期用 engine.Merge("1m", "15m", cb)
@@ -83,6 +74,7 @@ func registerStrategyPrompt(s *server.MCPServer) {
 ## 可用工具
 - create_strategy: 生成策略代码模板
 - build_strategy: 编译 .go 为 .so 插件
+- validate_strategy: 校验策略是否实现引擎要求的方法，列出缺失或签名不符的方法
 - run_backtest: 回测（大于30天自动异步）
 - run_backtest_managed: 托管策略回测并自动记录（大于30天自动异步）
 - download_kline: 下载K线（大于30天或auto自动异步）
@@ -0,0 +1,162 @@
+package resources
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/ztrade/ztrade-mcp/auth"
+	"github.com/ztrade/ztrade-mcp/store"
+)
+
+// strategyBacktestsLimit bounds the runs listed by ztrade://strategy/{id}/backtests.
+const strategyBacktestsLimit = 200
+
+// visibleScript gets a strategy and checks that the reader may see it.
+func visibleScript(ctx context.Context, st *store.Store, id int64) (*store.Script, error) {
+	script, err := st.GetScript(id)
+	if err != nil {
+		return nil, err
+	}
+	if viewer := auth.ScopedUser(ctx); viewer != "" {
+		if err := store.CheckScriptAccess(script, viewer, false); err != nil {
+			return nil, err
+		}
+	}
+	return script, nil
+}
+
+// backtestMarkdown formats a backtest record with its settings and metrics.
+func backtestMarkdown(r *store.BacktestRecord, strategyName string) string {
+	const timeFmt = "2006-01-02 15:04:05"
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Backtest %d — %s v%d\n\n", r.ID, strategyName, r.ScriptVersion)
+	fmt.Fprintf(&b, "Strategy: `ztrade://strategy/%d` · Run at %s\n\n", r.ScriptID, r.CreatedAt.Format(timeFmt))
+
+	b.WriteString("## Settings\n\n| Setting | Value |\n|---|---|\n")
+	row := func(k string, v interface{}) { fmt.Fprintf(&b, "| %s | %v |\n", k, v) }
+	row("Exchange", r.Exchange)
+	row("Symbol", r.Symbol)
+	row("Range", r.StartTime.Format(timeFmt)+" → "+r.EndTime.Format(timeFmt))
+	row("Initial balance", r.InitBalance)
+	row("Fee", r.Fee)
+	row("Leverage", r.Lever)
+	row("Risk-free rate", r.RiskFreeRate)
+	if r.WarmupBars > 0 {
+		row("Warmup bars", r.WarmupBars)
+	}
+	if r.LimitOrderModel {
+		row("Limit order model", fmt.Sprintf("on, expiry %d bars", r.LimitOrderExpiry))
+	}
+	if r.Param != "" {
+		fmt.Fprintf(&b, "\nParameters:\n\n```json\n%s\n```\n", r.Param)
+	}
+
+	b.WriteString("\n## Metrics\n\n| Metric | Value |\n|---|---|\n")
+	row("Total return", fmt.Sprintf("%.2f%%", r.TotalReturn*100))
+	row("Annual return", fmt.Sprintf("%.2f%%", r.AnnualReturn*100))
+	row("Total profit", r.TotalProfit)
+	row("Max drawdown", fmt.Sprintf("%.2f%%", r.MaxDrawdown*100))
+	row("Sharpe ratio", r.SharpeRatio)
+	row("Sortino ratio", r.SortinoRatio)
+	row("Calmar ratio", r.CalmarRatio)
+	row("Martin ratio", r.MartinRatio)
+	row("Ulcer index", r.UlcerIndex)
+	row("Volatility", r.Volatility)
+	row("Win rate", fmt.Sprintf("%.2f%%", r.WinRate*100))
+	row("Profit factor", r.ProfitFactor)
+	row("Trades (long / short)", fmt.Sprintf("%d (%d / %d)", r.TotalActions, r.LongTrades, r.ShortTrades))
+	row("Total fee", r.TotalFee)
+	if r.TotalFunding != 0 {
+		row("Total funding", r.TotalFunding)
+	}
+	row("Balance (start → end)", fmt.Sprintf("%v → %v", r.StartBalance, r.EndBalance))
+	row("Overall score", r.OverallScore)
+	return b.String()
+}
+
+func registerBacktests(s *server.MCPServer, st *store.Store) {
+	record := mcp.NewResourceTemplate(
+		"ztrade://backtest/{recordId}",
+		"Backtest Record",
+		mcp.WithTemplateDescription("A saved backtest run formatted as markdown: settings, strategy parameters and metrics."),
+		mcp.WithTemplateMIMEType("text/markdown"),
+	)
+	s.AddResourceTemplate(record, func(ctx context.Context, req mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		if st == nil {
+			return nil, errNoStore
+		}
+		id, err := templateID(req, "recordId")
+		if err != nil {
+			return nil, err
+		}
+		r, err := st.GetBacktestRecord(id)
+		if err != nil {
+			return nil, err
+		}
+		name := fmt.Sprintf("strategy %d", r.ScriptID)
+		script, err := visibleScript(ctx, st, r.ScriptID)
+		switch {
+		case err == nil:
+			name = script.Name
+		case auth.ScopedUser(ctx) != "" || !errors.Is(err, store.ErrNotFound):
+			return nil, err
+		}
+		return []mcp.ResourceContents{
+			mcp.TextResourceContents{URI: req.Params.URI, MIMEType: "text/markdown", Text: backtestMarkdown(r, name)},
+		}, nil
+	})
+
+	list := mcp.NewResourceTemplate(
+		"ztrade://strategy/{id}/backtests",
+		"Strategy Backtests",
+		mcp.WithTemplateDescription(fmt.Sprintf("The %d most recent saved backtest runs of a strategy with their key metrics, newest first. Read ztrade://backtest/{recordId} for details.", strategyBacktestsLimit)),
+		mcp.WithTemplateMIMEType("application/json"),
+	)
+	s.AddResourceTemplate(list, func(ctx context.Context, req mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		if st == nil {
+			return nil, errNoStore
+		}
+		id, err := templateID(req, "id")
+		if err != nil {
+			return nil, err
+		}
+		if _, err := visibleScript(ctx, st, id); err != nil {
+			return nil, err
+		}
+		records, err := st.ListBacktestRecords(id, strategyBacktestsLimit)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list backtests: %w", err)
+		}
+		type runEntry struct {
+			ID            int64   `json:"id"`
+			URI           string  `json:"uri"`
+			ScriptVersion int     `json:"scriptVersion"`
+			Exchange      string  `json:"exchange"`
+			Symbol        string  `json:"symbol"`
+			StartTime     string  `json:"startTime"`
+			EndTime       string  `json:"endTime"`
+			Param         string  `json:"param,omitempty"`
+			TotalReturn   float64 `json:"totalReturn"`
+			SharpeRatio   float64 `json:"sharpeRatio"`
+			MaxDrawdown   float64 `json:"maxDrawdown"`
+			OverallScore  float64 `json:"overallScore"`
+			CreatedAt     string  `json:"createdAt"`
+		}
+		entries := make([]runEntry, 0, len(records))
+		for _, r := range records {
+			entries = append(entries, runEntry{
+				ID: r.ID, URI: fmt.Sprintf("ztrade://backtest/%d", r.ID), ScriptVersion: r.ScriptVersion,
+				Exchange: r.Exchange, Symbol: r.Symbol,
+				StartTime: r.StartTime.Format("2006-01-02 15:04:05"), EndTime: r.EndTime.Format("2006-01-02 15:04:05"),
+				Param: r.Param, TotalReturn: r.TotalReturn, SharpeRatio: r.SharpeRatio,
+				MaxDrawdown: r.MaxDrawdown, OverallScore: r.OverallScore,
+				CreatedAt: r.CreatedAt.Format("2006-01-02 15:04:05"),
+			})
+		}
+		return jsonContents(req.Params.URI, entries)
+	})
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
+	"github.com/ztrade/ztrade-mcp/internal/indicatorspec"
 )
 
 const engineDocContent = `# ztrade Engine API 参考
@@ -39,7 +40,7 @@ Engine 是策略与引擎交互的核心接口。在 Init() 中通过参数获
 | RemoveMerge(vmID string) | 移除合成 |
 
 - src: 源周期 (固定为 "1m")
-- dst: 目标周期 ("5m", "15m", "30m", "1h", "4h", "1d" 等)
+- dst: 目标周期，任意整分钟的 Go duration 写法，如 "3m"、"15m"、"90m"、"2h"、"4h"；日线、周线写作 "24h"、"168h"（不支持 "1d"、"1w"，也不支持 "90s" 这类非整分钟周期），K 线起点按 Unix 纪元对齐。编译与回测前会检查，不合法时报错
 - fn: 回调函数 func(candle *Candle)
 
 ### 指标管理
@@ -56,56 +57,22 @@ Engine 是策略与引擎交互的核心接口。在 Init() 中通过参数获
 | Watch(watchType string) | 添加订阅事件 |
 
 ## 内置指标
-| 指标      | 参数                | 示例                              | 说明         |
-|-----------|---------------------|-----------------------------------|--------------|
-| EMA       | 1或2: 单线/交叉     | AddIndicator("EMA", 9, 26)        | 指数均线      |
-| SMA       | 1或2: 单线/交叉     | AddIndicator("SMA", 20)           | 简单均线      |
-| SSMA      | 1或2: 单线/交叉     | AddIndicator("SSMA", 9, 26)       | 平滑均线      |
-| MACD      | 3:快/慢/DEA         | AddIndicator("MACD", 12,26,9)     | MACD         |
-| SMAMACD   | 3:快/慢/DEA         | AddIndicator("SMAMACD",12,26,9)   | SMA版MACD     |
-| BOLL      | 长度、倍数          | AddIndicator("BOLL", 20, 2)       | 布林带        |
-| RSI       | 1或2: 单线/交叉     | AddIndicator("RSI", 14)           | 相对强弱      |
-| STOCHRSI  | 4:窗口/平滑         | AddIndicator("STOCHRSI",14,14,3,3)| 随机RSI       |
-
-### 指标返回值
-CommonIndicator 接口:
-- Result() float64 — 当前值(双线时返回快线值)
-- Indicator() map[string]float64 — 详细值
-
-双线指标 (EMA/SMA/SSMA/RSI 双参数):
-- result, fast, slow — 线值
-- crossUp (1=金叉), crossDown (1=死叉)
-
-BOLL指标:
-- result — 中轨
-- top — 上轨
-- bottom — 下轨
-| Watch(watchType string) | 添加订阅事件 |
-
-## 内置指标
+`
 
-| 指标 | 参数 | 示例 | 说明 |
-|------|------|------|------|
-| EMA | 1个: 单线; 2个: 交叉 | AddIndicator("EMA", 9, 26) | 指数移动平均 |
-| SMA | 1个: 单线; 2个: 交叉 | AddIndicator("SMA", 20) | 简单移动平均 |
-| SSMA | 1个: 单线; 2个: 交叉 | AddIndicator("SSMA", 9, 26) | 平滑移动平均 |
-| MACD | 快线、慢线、DEA | AddIndicator("MACD", 12, 26, 9) | MACD |
-| SMAMACD | 快线、慢线、DEA | AddIndicator("SMAMACD", 12, 26, 9) | SMA计算的MACD |
-| BOLL | 长度、倍数 | AddIndicator("BOLL", 20, 2) | 布林带 |
-| RSI | 1个: 单线; 2个: 交叉 | AddIndicator("RSI", 14) | 相对强弱指数 |
-| STOCHRSI | STOCH窗口、RSI窗口、K平滑、D平滑 | AddIndicator("STOCHRSI", 14, 14, 3, 3) | 随机RSI |
+// engineIndicatorNotes follows the generated indicator table.
+const engineIndicatorNotes = `
+参数个数以外的多余参数会被忽略；参数须为正整数。建议范围仅供调参参考，引擎不做限制。完整结构见 ztrade://doc/indicators。
 
 ### 指标返回值
-
 CommonIndicator 接口:
-- Result() float64 — 当前值(双线时返回快线值)
-- Indicator() map[string]float64 — 详细值
+- Result() float64 — 单线指标的值；双线形式（两个周期）没有单独的 result，取 fast/slow
+- Indicator() map[string]float64 — 上表"输出"列中的各项
 
-双线指标 (EMA/SMA/SSMA/RSI 双参数):
-- result, fast, slow — 线值
+双线形式:
+- fast, slow — 快线、慢线
 - crossUp (1=金叉), crossDown (1=死叉)
 
-BOLL指标:
+BOLL:
 - result — 中轨
 - top — 上轨
 - bottom — 下轨
@@ -124,7 +91,7 @@ func registerEngineDoc(s *server.MCPServer) {
 			mcp.TextResourceContents{
 				URI:      "ztrade://doc/engine",
 				MIMEType: "text/markdown",
-				Text:     engineDocContent,
+				Text:     engineDocContent + "\n" + indicatorspec.Markdown() + engineIndicatorNotes,
 			},
 		}, nil
 	})
@@ -0,0 +1,21 @@
+package resources
+
+import (
+	"context"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/ztrade/ztrade-mcp/internal/indicatorspec"
+)
+
+func registerIndicators(s *server.MCPServer) {
+	resource := mcp.NewResource(
+		"ztrade://doc/indicators",
+		"Indicator Parameters",
+		mcp.WithResourceDescription("Indicators accepted by Engine.AddIndicator: argument counts and outputs probed from the indicator library, plus the meaning, default and sensible range of each argument."),
+		mcp.WithMIMEType("application/json"),
+	)
+	s.AddResource(resource, func(ctx context.Context, req mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		return jsonContents("ztrade://doc/indicators", indicatorspec.Catalog())
+	})
+}
@@ -2,10 +2,15 @@ package resources
 
 import (
 	"github.com/mark3labs/mcp-go/server"
+	"github.com/ztrade/ztrade-mcp/store"
 )
 
 // RegisterAll registers all MCP resources on the server.
-func RegisterAll(s *server.MCPServer) {
+func RegisterAll(s *server.MCPServer, st *store.Store) {
 	registerStrategyDoc(s)
 	registerEngineDoc(s)
+	registerIndicators(s)
+	registerStrategies(s, st)
+	registerBacktests(s, st)
+	registerToolCatalog(s)
 }
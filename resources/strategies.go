@@ -0,0 +1,113 @@
+package resources
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/ztrade/ztrade-mcp/auth"
+	"github.com/ztrade/ztrade-mcp/store"
+)
+
+var errNoStore = errors.New("script store not initialized (check database config)")
+
+// templateArg returns the value a resource template variable matched.
+func templateArg(req mcp.ReadResourceRequest, name string) string {
+	switch v := req.Params.Arguments[name].(type) {
+	case string:
+		return v
+	case []string:
+		if len(v) > 0 {
+			return v[0]
+		}
+	}
+	return ""
+}
+
+// templateID parses a numeric resource template variable.
+func templateID(req mcp.ReadResourceRequest, name string) (int64, error) {
+	raw := templateArg(req, name)
+	id, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("invalid %s %q in %s", name, raw, req.Params.URI)
+	}
+	return id, nil
+}
+
+func jsonContents(uri string, v interface{}) ([]mcp.ResourceContents, error) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return []mcp.ResourceContents{
+		mcp.TextResourceContents{URI: uri, MIMEType: "application/json", Text: string(data)},
+	}, nil
+}
+
+func registerStrategies(s *server.MCPServer, st *store.Store) {
+	list := mcp.NewResource(
+		"ztrade://strategies",
+		"Strategies",
+		mcp.WithResourceDescription("Strategies in the database visible to the caller (id, name, description, lifecycleStatus), most recently updated first. Read ztrade://strategy/{id} for the source."),
+		mcp.WithMIMEType("application/json"),
+	)
+	s.AddResource(list, func(ctx context.Context, req mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		if st == nil {
+			return nil, errNoStore
+		}
+		scripts, _, err := st.ListScripts(store.ScriptFilter{VisibleTo: auth.ScopedUser(ctx)})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list strategies: %w", err)
+		}
+		type strategyEntry struct {
+			ID              int64  `json:"id"`
+			Name            string `json:"name"`
+			Description     string `json:"description"`
+			LifecycleStatus string `json:"lifecycleStatus"`
+			Version         int    `json:"version"`
+			URI             string `json:"uri"`
+		}
+		entries := make([]strategyEntry, 0, len(scripts))
+		for _, sc := range scripts {
+			entries = append(entries, strategyEntry{
+				ID: sc.ID, Name: sc.Name, Description: sc.Description,
+				LifecycleStatus: sc.LifecycleStatus, Version: sc.Version,
+				URI: fmt.Sprintf("ztrade://strategy/%d", sc.ID),
+			})
+		}
+		return jsonContents(req.Params.URI, entries)
+	})
+
+	source := mcp.NewResourceTemplate(
+		"ztrade://strategy/{id}",
+		"Strategy Source",
+		mcp.WithTemplateDescription("Current Go source of the strategy with the given id."),
+		mcp.WithTemplateMIMEType("text/x-go"),
+	)
+	s.AddResourceTemplate(source, func(ctx context.Context, req mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		if st == nil {
+			return nil, errNoStore
+		}
+		id, err := templateID(req, "id")
+		if err != nil {
+			return nil, err
+		}
+		script, err := st.GetScript(id)
+		if err == nil && script.Status == "deleted" {
+			err = fmt.Errorf("strategy %d is deleted", id)
+		}
+		if viewer := auth.ScopedUser(ctx); err == nil && viewer != "" {
+			err = store.CheckScriptAccess(script, viewer, false)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return []mcp.ResourceContents{
+			mcp.TextResourceContents{URI: req.Params.URI, MIMEType: "text/x-go", Text: script.Content},
+		}, nil
+	})
+}
@@ -87,7 +87,7 @@ func (s *MyStrategy) OnPosition(pos, price float64) {
 详见 "ztrade://doc/engine"，支持下单、合成K线、添加指标、日志、通知等。
 
 ## 指标用法
-见 Engine API 文档，支持 EMA/SMA/SSMA/MACD/BOLL/RSI/STOCHRSI 等。
+见 Engine API 文档，支持 EMA/SMA/SMMA/MACD/SMAMACD/BOLL/RSI/STOCHRSI/ATR/ADX；各指标的参数个数、含义与建议范围见 ztrade://doc/indicators。
 
 ## 运行方式
 ztrade build --script my_strategy.go --output my_strategy.so
@@ -0,0 +1,54 @@
+package resources
+
+import (
+	"context"
+	"sort"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/ztrade/ztrade-mcp/auth"
+)
+
+func registerToolCatalog(s *server.MCPServer) {
+	resource := mcp.NewResource(
+		"ztrade://tools",
+		"Tool Catalog",
+		mcp.WithResourceDescription("All registered tools with their descriptions and whether the caller's role may call each (allowed), so a client can hide the tools it would be denied."),
+		mcp.WithMIMEType("application/json"),
+	)
+	s.AddResource(resource, func(ctx context.Context, req mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		type toolEntry struct {
+			Name        string `json:"name"`
+			Description string `json:"description"`
+			Allowed     bool   `json:"allowed"`
+		}
+		// without an authenticated user, as over stdio, nothing is checked
+		user := auth.UserFromContext(ctx)
+		entries := []toolEntry{}
+		for name, t := range s.ListTools() {
+			entries = append(entries, toolEntry{
+				Name:        name,
+				Description: t.Tool.Description,
+				Allowed:     user == nil || auth.HasPermission(user.Role, name),
+			})
+		}
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+
+		allowed := 0
+		for _, e := range entries {
+			if e.Allowed {
+				allowed++
+			}
+		}
+		result := map[string]interface{}{
+			"total":   len(entries),
+			"allowed": allowed,
+			"tools":   entries,
+		}
+		if user != nil {
+			result["user"] = user.Name
+			result["role"] = user.Role
+		}
+		return jsonContents("ztrade://tools", result)
+	})
+}
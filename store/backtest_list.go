@@ -0,0 +1,152 @@
+package store
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"xorm.io/xorm"
+)
+
+// BacktestFilter selects backtest records across all strategies. Zero
+// values do not filter.
+type BacktestFilter struct {
+	Exchange string
+	Symbol   string
+	Since    time.Time // records created at or after
+	Until    time.Time // records created before
+	MinScore *float64
+	Tag      string // strategy tag, matched against whole comma separated entries
+	RunLabel string
+	// VisibleTo limits the records to those of scripts the named user may
+	// see (their own, shared or unowned ones); empty means all scripts.
+	VisibleTo string
+	Offset    int
+	Limit     int
+}
+
+// BacktestListItem is a backtest record with the name of its strategy.
+type BacktestListItem struct {
+	BacktestRecord
+	ScriptName string `json:"scriptName"`
+}
+
+// applyBacktestFilter adds the conditions of filter to sess; tagged are
+// the IDs of the scripts carrying filter.Tag.
+func (s *Store) applyBacktestFilter(sess *xorm.Session, filter BacktestFilter, tagged []int64) *xorm.Session {
+	if filter.Exchange != "" {
+		sess = sess.Where(s.col("Exchange")+" = ?", filter.Exchange)
+	}
+	if filter.Symbol != "" {
+		sess = sess.Where(s.col("Symbol")+" = ?", filter.Symbol)
+	}
+	if !filter.Since.IsZero() {
+		sess = sess.Where(s.col("CreatedAt")+" >= ?", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		sess = sess.Where(s.col("CreatedAt")+" < ?", filter.Until)
+	}
+	if filter.MinScore != nil {
+		sess = sess.Where(s.col("OverallScore")+" >= ?", *filter.MinScore)
+	}
+	if filter.Tag != "" {
+		sess = sess.In(s.col("ScriptID"), tagged)
+	}
+	if filter.RunLabel != "" {
+		sess = sess.Where(s.col("RunLabel")+" = ?", filter.RunLabel)
+	}
+	if filter.VisibleTo != "" {
+		sess = sess.Where(s.visibleRecords(), filter.VisibleTo, "", true)
+	}
+	return sess
+}
+
+// visibleRecords is the condition that a backtest record belongs to a
+// script visible to a user, taking the user, "" and true as arguments like
+// the VisibleTo condition of ListScripts.
+func (s *Store) visibleRecords() string {
+	return fmt.Sprintf("%s IN (SELECT %s FROM %s WHERE %s = ? OR %s = ? OR %s = ?)",
+		s.col("ScriptID"), s.col("ID"), Script{}.TableName(), s.col("Owner"), s.col("Owner"), s.col("Shared"))
+}
+
+// scriptsWithTag returns the IDs of the scripts carrying tag.
+func (s *Store) scriptsWithTag(tag string) ([]int64, error) {
+	var scripts []Script
+	err := s.engine.Cols(s.col("ID"), s.col("Tags")).
+		Where(s.col("Tags")+" LIKE ?", "%"+tag+"%").Find(&scripts)
+	if err != nil {
+		return nil, err
+	}
+	var ids []int64
+	for _, sc := range scripts {
+		for _, t := range strings.Split(sc.Tags, ",") {
+			if strings.TrimSpace(t) == tag {
+				ids = append(ids, sc.ID)
+				break
+			}
+		}
+	}
+	return ids, nil
+}
+
+// ListAllBacktests lists backtest records of every strategy, newest first,
+// and the number of records matching the filter.
+func (s *Store) ListAllBacktests(filter BacktestFilter) ([]BacktestListItem, int64, error) {
+	var tagged []int64
+	if filter.Tag != "" {
+		var err error
+		if tagged, err = s.scriptsWithTag(filter.Tag); err != nil {
+			return nil, 0, err
+		}
+		if len(tagged) == 0 {
+			return nil, 0, nil
+		}
+	}
+
+	countSess := s.engine.NewSession()
+	defer countSess.Close()
+	total, err := s.applyBacktestFilter(countSess, filter, tagged).Count(new(BacktestRecord))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	sess := s.engine.NewSession()
+	defer sess.Close()
+	sess = s.applyBacktestFilter(sess, filter, tagged).
+		OrderBy(s.col("CreatedAt") + " DESC, " + s.col("ID") + " DESC")
+	if filter.Limit > 0 || filter.Offset > 0 {
+		limit := filter.Limit
+		if limit <= 0 {
+			limit = math.MaxInt32
+		}
+		sess = sess.Limit(limit, filter.Offset)
+	}
+	var records []BacktestRecord
+	if err := sess.Find(&records); err != nil {
+		return nil, 0, err
+	}
+
+	names := make(map[int64]string)
+	var ids []int64
+	for _, r := range records {
+		if _, ok := names[r.ScriptID]; !ok {
+			names[r.ScriptID] = ""
+			ids = append(ids, r.ScriptID)
+		}
+	}
+	if len(ids) > 0 {
+		var scripts []Script
+		if err := s.engine.Cols(s.col("ID"), s.col("Name")).In(s.col("ID"), ids).Find(&scripts); err != nil {
+			return nil, 0, err
+		}
+		for _, sc := range scripts {
+			names[sc.ID] = sc.Name
+		}
+	}
+	items := make([]BacktestListItem, len(records))
+	for i, r := range records {
+		items[i] = BacktestListItem{BacktestRecord: r, ScriptName: names[r.ScriptID]}
+	}
+	return items, total, nil
+}
@@ -0,0 +1,46 @@
+package store
+
+import "testing"
+
+func TestListAllBacktests(t *testing.T) {
+	s := newTestStore(t)
+	trend := &Script{Name: "trend", Content: "package main", Tags: "trend"}
+	other := &Script{Name: "other", Content: "package main", Tags: "trendy"}
+	for _, sc := range []*Script{trend, other} {
+		if err := s.CreateScript(sc); err != nil {
+			t.Fatalf("CreateScript: %v", err)
+		}
+	}
+	for _, r := range []*BacktestRecord{
+		{ScriptID: trend.ID, Exchange: "binance", Symbol: "ETHUSDT", OverallScore: 60},
+		{ScriptID: trend.ID, Exchange: "binance", Symbol: "BTCUSDT", OverallScore: 80},
+		{ScriptID: other.ID, Exchange: "binance", Symbol: "ETHUSDT", OverallScore: 90},
+	} {
+		if err := s.SaveBacktestRecord(r); err != nil {
+			t.Fatalf("SaveBacktestRecord: %v", err)
+		}
+	}
+
+	items, total, err := s.ListAllBacktests(BacktestFilter{Symbol: "ETHUSDT"})
+	if err != nil || total != 2 || len(items) != 2 {
+		t.Fatalf("symbol filter: %d/%d, %v", len(items), total, err)
+	}
+	if items[0].ScriptName != "other" || items[1].ScriptName != "trend" {
+		t.Errorf("want newest first with names, got %s, %s", items[0].ScriptName, items[1].ScriptName)
+	}
+
+	min := 70.0
+	items, total, err = s.ListAllBacktests(BacktestFilter{Tag: "trend", MinScore: &min})
+	if err != nil || total != 1 || items[0].Symbol != "BTCUSDT" {
+		t.Fatalf("tag and score filter: %+v, %d, %v", items, total, err)
+	}
+
+	items, total, err = s.ListAllBacktests(BacktestFilter{Offset: 1, Limit: 1})
+	if err != nil || total != 3 || len(items) != 1 || items[0].Symbol != "BTCUSDT" {
+		t.Fatalf("page: %+v, %d, %v", items, total, err)
+	}
+
+	if _, total, _ := s.ListAllBacktests(BacktestFilter{Tag: "missing"}); total != 0 {
+		t.Errorf("unknown tag matched %d records", total)
+	}
+}
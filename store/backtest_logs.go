@@ -1,6 +1,12 @@
 package store
 
-import "fmt"
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"xorm.io/xorm"
+)
 
 // SaveBacktestLogs persists captured engine.Log lines for a backtest record.
 func (s *Store) SaveBacktestLogs(recordID int64, lines []string) error {
@@ -23,8 +29,23 @@ func (s *Store) SaveBacktestLogs(recordID int64, lines []string) error {
 	return err
 }
 
+// BacktestLogFilter selects captured log lines. Contains is matched with
+// SQL LIKE (so case sensitivity follows the database); Pattern is applied
+// to the remaining lines in Go, as regular expressions are not portable
+// across databases.
+type BacktestLogFilter struct {
+	Contains string
+	Pattern  *regexp.Regexp
+}
+
 // ListBacktestLogs returns paginated captured logs for one backtest record.
 func (s *Store) ListBacktestLogs(recordID int64, offset, limit int) ([]BacktestLog, int64, error) {
+	return s.FilterBacktestLogs(recordID, BacktestLogFilter{}, offset, limit)
+}
+
+// FilterBacktestLogs returns the captured log lines of a backtest record
+// that match filter, paginated, and the number of matching lines.
+func (s *Store) FilterBacktestLogs(recordID int64, filter BacktestLogFilter, offset, limit int) ([]BacktestLog, int64, error) {
 	if recordID <= 0 {
 		return nil, 0, fmt.Errorf("invalid record id %d", recordID)
 	}
@@ -38,15 +59,50 @@ func (s *Store) ListBacktestLogs(recordID int64, offset, limit int) ([]BacktestL
 		limit = 2000
 	}
 
-	total, err := s.engine.Where("record_id = ?", recordID).Count(new(BacktestLog))
+	sess := func() *xorm.Session {
+		sess := s.engine.Where(s.col("RecordID")+" = ?", recordID)
+		if filter.Contains != "" {
+			sess = sess.And(s.col("Content")+" LIKE ? ESCAPE '!'", "%"+escapeLike(filter.Contains)+"%")
+		}
+		return sess
+	}
+
+	if filter.Pattern != nil {
+		var all []BacktestLog
+		if err := sess().Asc(s.col("LineNo")).Find(&all); err != nil {
+			return nil, 0, err
+		}
+		matched := all[:0]
+		for _, l := range all {
+			if filter.Pattern.MatchString(l.Content) {
+				matched = append(matched, l)
+			}
+		}
+		total := int64(len(matched))
+		if offset >= len(matched) {
+			return []BacktestLog{}, total, nil
+		}
+		matched = matched[offset:]
+		if len(matched) > limit {
+			matched = matched[:limit]
+		}
+		return matched, total, nil
+	}
+
+	total, err := sess().Count(&BacktestLog{})
 	if err != nil {
 		return nil, 0, err
 	}
 
 	var logs []BacktestLog
-	err = s.engine.Where("record_id = ?", recordID).Asc("line_no").Limit(limit, offset).Find(&logs)
+	err = sess().Asc(s.col("LineNo")).Limit(limit, offset).Find(&logs)
 	if err != nil {
 		return nil, 0, err
 	}
 	return logs, total, nil
 }
+
+// escapeLike escapes the LIKE wildcards in s for use with ESCAPE '!'.
+func escapeLike(s string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(s)
+}
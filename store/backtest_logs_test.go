@@ -1,6 +1,9 @@
 package store
 
-import "testing"
+import (
+	"regexp"
+	"testing"
+)
 
 func TestBacktestLogsInvalidRecordID(t *testing.T) {
 	s := &Store{}
@@ -11,3 +14,51 @@ func TestBacktestLogsInvalidRecordID(t *testing.T) {
 		t.Fatalf("expected error for invalid record id")
 	}
 }
+
+func TestBacktestEquityInvalidRecordID(t *testing.T) {
+	s := &Store{}
+	if err := s.SaveBacktestEquity(0, []EquityPoint{{Equity: 1}}); err == nil {
+		t.Fatalf("expected error for invalid record id")
+	}
+	if _, err := s.ListBacktestEquity(0); err == nil {
+		t.Fatalf("expected error for invalid record id")
+	}
+}
+
+func TestFilterBacktestLogs(t *testing.T) {
+	s := newTestStore(t)
+	lines := []string{"WARN spread 5%", "open long", "WARN stop hit", "close long", "spread_5 ok"}
+	if err := s.SaveBacktestLogs(1, lines); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SaveBacktestLogs(2, []string{"WARN other record"}); err != nil {
+		t.Fatal(err)
+	}
+
+	logs, total, err := s.FilterBacktestLogs(1, BacktestLogFilter{Contains: "WARN"}, 1, 10)
+	if err != nil || total != 2 || len(logs) != 1 || logs[0].LineNo != 3 {
+		t.Fatalf("contains: %+v, %d, %v", logs, total, err)
+	}
+	// LIKE wildcards in the text are literal
+	if logs, total, _ := s.FilterBacktestLogs(1, BacktestLogFilter{Contains: "5%"}, 0, 10); total != 1 || logs[0].LineNo != 1 {
+		t.Errorf("escaped %%: %+v", logs)
+	}
+	if _, total, _ := s.FilterBacktestLogs(1, BacktestLogFilter{Contains: "d_5"}, 0, 10); total != 1 {
+		t.Errorf("escaped _: %d", total)
+	}
+
+	logs, total, err = s.FilterBacktestLogs(1, BacktestLogFilter{Pattern: regexp.MustCompile(`long$`)}, 0, 1)
+	if err != nil || total != 2 || len(logs) != 1 || logs[0].LineNo != 2 {
+		t.Fatalf("regex: %+v, %d, %v", logs, total, err)
+	}
+	logs, total, _ = s.FilterBacktestLogs(1, BacktestLogFilter{Contains: "WARN", Pattern: regexp.MustCompile(`stop`)}, 0, 10)
+	if total != 1 || logs[0].LineNo != 3 {
+		t.Errorf("both: %+v", logs)
+	}
+	if logs, total, _ := s.FilterBacktestLogs(1, BacktestLogFilter{Pattern: regexp.MustCompile(`long`)}, 5, 10); total != 2 || len(logs) != 0 {
+		t.Errorf("offset past matches: %+v, %d", logs, total)
+	}
+	if _, total, _ := s.ListBacktestLogs(1, 0, 10); total != 5 {
+		t.Errorf("unfiltered total = %d", total)
+	}
+}
@@ -0,0 +1,78 @@
+package store
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Groupings accepted by GetBacktestSummaryGroups.
+const (
+	BacktestGroupNone   = "none"
+	BacktestGroupYear   = "year"
+	BacktestGroupSymbol = "symbol"
+	BacktestGroupRegime = "regime"
+)
+
+// unclassifiedRegime is the group of records without a market regime,
+// such as those saved before regimes were recorded.
+const unclassifiedRegime = "unclassified"
+
+// IsValidBacktestGroup reports whether groupBy is accepted by
+// GetBacktestSummaryGroups.
+func IsValidBacktestGroup(groupBy string) bool {
+	switch groupBy {
+	case "", BacktestGroupNone, BacktestGroupYear, BacktestGroupSymbol, BacktestGroupRegime:
+		return true
+	}
+	return false
+}
+
+// GetBacktestSummaryGroups returns the GetBacktestSummary stats of a
+// script's backtests split by the calendar year of their start time, by
+// symbol or by market regime, one map per group in ascending order with the group under
+// "group". It returns nil for BacktestGroupNone.
+func (s *Store) GetBacktestSummaryGroups(scriptID int64, groupBy string) ([]map[string]interface{}, error) {
+	var orderCol string
+	var key func(r *BacktestRecord) string
+	switch groupBy {
+	case "", BacktestGroupNone:
+		return nil, nil
+	case BacktestGroupYear:
+		orderCol = s.col("StartTime")
+		key = func(r *BacktestRecord) string { return strconv.Itoa(r.StartTime.Year()) }
+	case BacktestGroupSymbol:
+		orderCol = s.col("Symbol")
+		key = func(r *BacktestRecord) string { return r.Symbol }
+	case BacktestGroupRegime:
+		orderCol = s.col("Regime")
+		key = func(r *BacktestRecord) string {
+			if r.Regime == "" {
+				return unclassifiedRegime
+			}
+			return r.Regime
+		}
+	default:
+		return nil, fmt.Errorf("invalid groupBy %s", groupBy)
+	}
+
+	// the database sorts the records so each group is a contiguous run; the
+	// year is taken in Go as extracting it differs between SQL dialects
+	var records []BacktestRecord
+	err := s.engine.Where(s.col("ScriptID")+" = ?", scriptID).And(s.wholeRuns()).Asc(orderCol, s.col("ID")).Find(&records)
+	if err != nil {
+		return nil, err
+	}
+	var groups []map[string]interface{}
+	for start := 0; start < len(records); {
+		k := key(&records[start])
+		end := start + 1
+		for end < len(records) && key(&records[end]) == k {
+			end++
+		}
+		group := summarizeBacktests(records[start:end])
+		group["group"] = k
+		groups = append(groups, group)
+		start = end
+	}
+	return groups, nil
+}
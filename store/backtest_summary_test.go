@@ -0,0 +1,144 @@
+package store
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBacktestSummaryGroups(t *testing.T) {
+	s := newTestStore(t)
+	sc := &Script{Name: "trend", Content: "package main"}
+	if err := s.CreateScript(sc); err != nil {
+		t.Fatalf("CreateScript: %v", err)
+	}
+	year := func(y int) time.Time { return time.Date(y, 3, 1, 0, 0, 0, 0, time.Local) }
+	for _, r := range []*BacktestRecord{
+		{ScriptID: sc.ID, Symbol: "ETHUSDT", StartTime: year(2022), OverallScore: 20, Regime: "ranging", SharpeRatio: -0.3},
+		{ScriptID: sc.ID, Symbol: "BTCUSDT", StartTime: year(2021), OverallScore: 80, Regime: "trending", SharpeRatio: 2.5},
+		{ScriptID: sc.ID, Symbol: "BTCUSDT", StartTime: year(2022), OverallScore: 30},
+		{ScriptID: sc.ID, Symbol: "BTCUSDT", StartTime: year(2021), OverallScore: 60, Regime: "trending", SharpeRatio: 1.5},
+	} {
+		r.EndTime = r.StartTime.AddDate(0, 1, 0)
+		if err := s.SaveBacktestRecord(r); err != nil {
+			t.Fatalf("SaveBacktestRecord: %v", err)
+		}
+	}
+
+	groups, err := s.GetBacktestSummaryGroups(sc.ID, BacktestGroupYear)
+	if err != nil || len(groups) != 2 {
+		t.Fatalf("by year: %+v, %v", groups, err)
+	}
+	if groups[0]["group"] != "2021" || groups[0]["totalRuns"] != 2 || groups[0]["avgScore"] != 70.0 || groups[0]["worstScore"] != 60.0 {
+		t.Errorf("2021: %+v", groups[0])
+	}
+	if groups[1]["group"] != "2022" || groups[1]["bestScore"] != 30.0 {
+		t.Errorf("2022: %+v", groups[1])
+	}
+
+	groups, err = s.GetBacktestSummaryGroups(sc.ID, BacktestGroupSymbol)
+	if err != nil || len(groups) != 2 || groups[0]["group"] != "BTCUSDT" || groups[0]["totalRuns"] != 3 || groups[1]["totalRuns"] != 1 {
+		t.Fatalf("by symbol: %+v, %v", groups, err)
+	}
+
+	groups, err = s.GetBacktestSummaryGroups(sc.ID, BacktestGroupRegime)
+	if err != nil || len(groups) != 3 {
+		t.Fatalf("by regime: %+v, %v", groups, err)
+	}
+	if groups[0]["group"] != "unclassified" || groups[1]["group"] != "ranging" || groups[2]["group"] != "trending" || groups[2]["avgSharpe"] != 2.0 {
+		t.Errorf("by regime: %+v", groups)
+	}
+
+	if groups, err := s.GetBacktestSummaryGroups(sc.ID, BacktestGroupNone); err != nil || groups != nil {
+		t.Errorf("none: %+v, %v", groups, err)
+	}
+	if _, err := s.GetBacktestSummaryGroups(sc.ID, "month"); err == nil {
+		t.Error("invalid groupBy accepted")
+	}
+}
+
+func TestBacktestSummaryMatchesRecords(t *testing.T) {
+	s := newTestStore(t)
+	if _, err := s.GetBacktestSummary(1); err == nil {
+		t.Fatal("summary without records")
+	}
+	for _, scores := range [][]float64{{40, 75, -10, 75, 12}, {-5, -20}} {
+		sc := &Script{Name: fmt.Sprintf("s%d", len(scores)), Content: "package main"}
+		if err := s.CreateScript(sc); err != nil {
+			t.Fatal(err)
+		}
+		for i, score := range scores {
+			r := &BacktestRecord{
+				ScriptID: sc.ID, ScriptVersion: i + 1, Exchange: "binance", Symbol: "BTCUSDT", Param: fmt.Sprintf(`{"n":%d}`, i),
+				OverallScore: score, SharpeRatio: score / 30, WinRate: 0.4 + float64(i)/20,
+				UlcerIndex: 0.01 * float64(i+1), MartinRatio: score / 10,
+			}
+			if err := s.SaveBacktestRecord(r); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		got, err := s.GetBacktestSummary(sc.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		records, err := s.ListBacktestRecords(sc.ID, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := summarizeBacktests(records)
+		if len(got) != len(want) {
+			t.Fatalf("%v: keys %v, want %v", scores, got, want)
+		}
+		for k, w := range want {
+			switch w := w.(type) {
+			case float64:
+				if g, ok := got[k].(float64); !ok || math.Abs(g-w) > 1e-9 {
+					t.Errorf("%v: %s = %v, want %v", scores, k, got[k], w)
+				}
+			default:
+				if !reflect.DeepEqual(got[k], w) {
+					t.Errorf("%v: %s = %v, want %v", scores, k, got[k], w)
+				}
+			}
+		}
+	}
+}
+
+func TestChunkRecordsExcluded(t *testing.T) {
+	s := newTestStore(t)
+	sc := &Script{Name: "chunked", Content: "package main"}
+	if err := s.CreateScript(sc); err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range []*BacktestRecord{
+		{ScriptID: sc.ID, Symbol: "BTCUSDT", OverallScore: 90, Chunk: true},
+		{ScriptID: sc.ID, Symbol: "BTCUSDT", OverallScore: -40, Chunk: true},
+		{ScriptID: sc.ID, Symbol: "BTCUSDT", OverallScore: 25},
+	} {
+		if err := s.SaveBacktestRecord(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if records, err := s.ListBacktestRecords(sc.ID, 0); err != nil || len(records) != 3 {
+		t.Fatalf("ListBacktestRecords: %d records, %v", len(records), err)
+	}
+	records, err := s.ListRunRecords(sc.ID)
+	if err != nil || len(records) != 1 || records[0].Chunk {
+		t.Fatalf("ListRunRecords: %+v, %v", records, err)
+	}
+	summary, err := s.GetBacktestSummary(sc.ID)
+	if err != nil || summary["totalRuns"] != 1 || summary["bestScore"] != 25.0 {
+		t.Fatalf("summary: %+v, %v", summary, err)
+	}
+	groups, err := s.GetBacktestSummaryGroups(sc.ID, BacktestGroupSymbol)
+	if err != nil || len(groups) != 1 || groups[0]["totalRuns"] != 1 {
+		t.Fatalf("groups: %+v, %v", groups, err)
+	}
+	if best, err := s.BestScores([]int64{sc.ID}); err != nil || best[sc.ID] != 25 {
+		t.Fatalf("BestScores: %v, %v", best, err)
+	}
+}
@@ -0,0 +1,59 @@
+package store
+
+import (
+	"fmt"
+	"time"
+)
+
+// BacktestTrade is one trade of a backtest run, as reported by the engine.
+type BacktestTrade struct {
+	ID       int64     `xorm:"pk autoincr" json:"-"`
+	RecordID int64     `xorm:"notnull index" json:"-"`
+	Seq      int       `xorm:"notnull" json:"-"`
+	Time     time.Time `xorm:"notnull" json:"time"`
+	Action   string    `xorm:"varchar(20) notnull" json:"action"`
+	Price    float64   `json:"price"`
+	Amount   float64   `json:"amount"`
+	Profit   float64   `json:"profit"` // realized profit, zero for opening trades
+	Fee      float64   `json:"fee"`
+	Equity   float64   `json:"equity"` // account equity after the trade
+	// MAE and MFE are the maximum adverse and favorable excursion of the
+	// position before this trade reduced it, in percent of its average
+	// entry price; zero for opening trades.
+	MAE float64 `json:"mae"`
+	MFE float64 `json:"mfe"`
+}
+
+func (BacktestTrade) TableName() string {
+	return "mcp_backtest_trades"
+}
+
+// SaveBacktestTrades persists the trades of a backtest record.
+func (s *Store) SaveBacktestTrades(recordID int64, trades []BacktestTrade) error {
+	if recordID <= 0 {
+		return fmt.Errorf("invalid record id %d", recordID)
+	}
+	if len(trades) == 0 {
+		return nil
+	}
+	rows := make([]BacktestTrade, len(trades))
+	for i, t := range trades {
+		t.ID = 0
+		t.RecordID = recordID
+		t.Seq = i
+		rows[i] = t
+	}
+	_, err := s.engine.Insert(&rows)
+	return err
+}
+
+// ListBacktestTrades returns the stored trades of a backtest record in time
+// order.
+func (s *Store) ListBacktestTrades(recordID int64) ([]BacktestTrade, error) {
+	if recordID <= 0 {
+		return nil, fmt.Errorf("invalid record id %d", recordID)
+	}
+	var trades []BacktestTrade
+	err := s.engine.Asc(s.col("Seq")).Find(&trades, &BacktestTrade{RecordID: recordID})
+	return trades, err
+}
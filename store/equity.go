@@ -0,0 +1,46 @@
+package store
+
+import (
+	"fmt"
+	"time"
+)
+
+// EquityPoint is one point of a backtest equity curve.
+type EquityPoint struct {
+	ID       int64     `xorm:"pk autoincr" json:"-"`
+	RecordID int64     `xorm:"notnull index" json:"-"`
+	Seq      int       `xorm:"notnull" json:"-"`
+	Time     time.Time `xorm:"notnull" json:"time"`
+	Equity   float64   `json:"equity"`
+}
+
+func (EquityPoint) TableName() string {
+	return "mcp_backtest_equity"
+}
+
+// SaveBacktestEquity persists the equity curve of a backtest record.
+func (s *Store) SaveBacktestEquity(recordID int64, points []EquityPoint) error {
+	if recordID <= 0 {
+		return fmt.Errorf("invalid record id %d", recordID)
+	}
+	if len(points) == 0 {
+		return nil
+	}
+	rows := make([]EquityPoint, len(points))
+	for i, p := range points {
+		rows[i] = EquityPoint{RecordID: recordID, Seq: i, Time: p.Time, Equity: p.Equity}
+	}
+	_, err := s.engine.Insert(&rows)
+	return err
+}
+
+// ListBacktestEquity returns the stored equity curve of a backtest record in
+// time order.
+func (s *Store) ListBacktestEquity(recordID int64) ([]EquityPoint, error) {
+	if recordID <= 0 {
+		return nil, fmt.Errorf("invalid record id %d", recordID)
+	}
+	var points []EquityPoint
+	err := s.engine.Asc(s.col("Seq")).Find(&points, &EquityPoint{RecordID: recordID})
+	return points, err
+}
@@ -0,0 +1,18 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNotFound matches, via errors.Is, every lookup that finds nothing.
+var ErrNotFound = errors.New("not found")
+
+type notFoundError struct{ msg string }
+
+func (e notFoundError) Error() string        { return e.msg }
+func (e notFoundError) Is(target error) bool { return target == ErrNotFound }
+
+func notFoundf(format string, args ...interface{}) error {
+	return notFoundError{msg: fmt.Sprintf(format, args...)}
+}
@@ -0,0 +1,20 @@
+package store
+
+// GetExperiment returns the backtest records labeled runLabel, of any
+// strategy visibleTo may see (all when empty), in the order they were run,
+// with the GetBacktestSummary stats over all of them.
+func (s *Store) GetExperiment(runLabel, visibleTo string) ([]BacktestRecord, map[string]interface{}, error) {
+	var records []BacktestRecord
+	sess := s.engine.Where(s.col("RunLabel")+" = ?", runLabel)
+	if visibleTo != "" {
+		sess = sess.And(s.visibleRecords(), visibleTo, "", true)
+	}
+	err := sess.Asc(s.col("ID")).Find(&records)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil, notFoundf("no backtest records labeled %q", runLabel)
+	}
+	return records, summarizeBacktests(records), nil
+}
@@ -0,0 +1,81 @@
+package store
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestGetExperiment(t *testing.T) {
+	s := newTestStore(t)
+	for _, r := range []*BacktestRecord{
+		{ScriptID: 1, Symbol: "BTCUSDT", RunLabel: "sweep-1", OverallScore: 40},
+		{ScriptID: 1, Symbol: "BTCUSDT", OverallScore: 90},
+		{ScriptID: 2, Symbol: "BTCUSDT", RunLabel: "sweep-1", OverallScore: 70},
+		{ScriptID: 1, Symbol: "ETHUSDT", RunLabel: "sweep-2", OverallScore: 10},
+	} {
+		if err := s.SaveBacktestRecord(r); err != nil {
+			t.Fatalf("SaveBacktestRecord: %v", err)
+		}
+	}
+
+	records, summary, err := s.GetExperiment("sweep-1", "")
+	if err != nil || len(records) != 2 || records[0].ID != 1 || records[1].ID != 3 {
+		t.Fatalf("GetExperiment: %+v, %v", records, err)
+	}
+	if summary["totalRuns"] != 2 || summary["bestScore"] != 70.0 {
+		t.Errorf("summary: %+v", summary)
+	}
+	if _, _, err := s.GetExperiment("nope", ""); !errors.Is(err, ErrNotFound) {
+		t.Errorf("unknown label: %v", err)
+	}
+
+	if records, _ := s.ListBacktestRecordsByLabel(1, "sweep-2", 0); len(records) != 1 || records[0].ID != 4 {
+		t.Errorf("ListBacktestRecordsByLabel: %+v", records)
+	}
+	if items, total, _ := s.ListAllBacktests(BacktestFilter{RunLabel: "sweep-1"}); total != 2 || len(items) != 2 {
+		t.Errorf("ListAllBacktests by label: %d of %d", len(items), total)
+	}
+}
+
+func TestBacktestsVisibleTo(t *testing.T) {
+	s := newTestStore(t)
+	private := &Script{Name: "private", Content: "package main", Owner: "alice"}
+	shared := &Script{Name: "shared", Content: "package main", Owner: "alice", Shared: true}
+	legacy := &Script{Name: "legacy", Content: "package main"}
+	for _, sc := range []*Script{private, shared, legacy} {
+		if err := s.CreateScript(sc); err != nil {
+			t.Fatalf("CreateScript: %v", err)
+		}
+	}
+	for _, sc := range []*Script{private, shared, legacy} {
+		if err := s.SaveBacktestRecord(&BacktestRecord{ScriptID: sc.ID, RunLabel: "sweep"}); err != nil {
+			t.Fatalf("SaveBacktestRecord: %v", err)
+		}
+	}
+
+	items, total, err := s.ListAllBacktests(BacktestFilter{VisibleTo: "bob"})
+	if err != nil || total != 2 || len(items) != 2 {
+		t.Fatalf("bob lists: %d of %d, %v", len(items), total, err)
+	}
+	for _, it := range items {
+		if it.ScriptID == private.ID {
+			t.Fatalf("bob sees alice's unshared record: %+v", it)
+		}
+	}
+	if _, total, _ := s.ListAllBacktests(BacktestFilter{VisibleTo: "alice"}); total != 3 {
+		t.Errorf("alice lists %d records, want 3", total)
+	}
+
+	records, _, err := s.GetExperiment("sweep", "bob")
+	if err != nil || len(records) != 2 {
+		t.Fatalf("bob's experiment: %+v, %v", records, err)
+	}
+	for _, r := range records {
+		if r.ScriptID == private.ID {
+			t.Fatalf("bob's experiment includes alice's unshared record: %+v", r)
+		}
+	}
+	if records, _, _ := s.GetExperiment("sweep", ""); len(records) != 3 {
+		t.Errorf("unscoped experiment: %d records, want 3", len(records))
+	}
+}
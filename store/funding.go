@@ -0,0 +1,53 @@
+package store
+
+import (
+	"fmt"
+	"time"
+)
+
+// FundingRate is a historical funding rate of a perpetual contract.
+type FundingRate struct {
+	ID          int64     `xorm:"pk autoincr" json:"id"`
+	Exchange    string    `xorm:"varchar(50) notnull unique(funding_rate)" json:"exchange"`
+	Symbol      string    `xorm:"varchar(50) notnull unique(funding_rate)" json:"symbol"`
+	FundingTime time.Time `xorm:"notnull unique(funding_rate)" json:"fundingTime"`
+	Rate        float64   `json:"rate"`
+	MarkPrice   float64   `json:"markPrice"`
+	CreatedAt   time.Time `xorm:"created" json:"createdAt"`
+}
+
+func (FundingRate) TableName() string {
+	return "mcp_funding_rates"
+}
+
+// SaveFundingRates stores funding rates, skipping ones already present.
+// It returns the number of newly inserted rows.
+func (s *Store) SaveFundingRates(rates []FundingRate) (int, error) {
+	inserted := 0
+	for i := range rates {
+		r := rates[i]
+		if r.Exchange == "" || r.Symbol == "" {
+			return inserted, fmt.Errorf("funding rate exchange and symbol are required")
+		}
+		has, err := s.engine.Where("exchange = ? AND symbol = ? AND funding_time = ?", r.Exchange, r.Symbol, r.FundingTime).Exist(new(FundingRate))
+		if err != nil {
+			return inserted, err
+		}
+		if has {
+			continue
+		}
+		if _, err := s.engine.Insert(&r); err != nil {
+			return inserted, err
+		}
+		inserted++
+	}
+	return inserted, nil
+}
+
+// ListFundingRates returns stored funding rates in [start, end] ordered by time.
+func (s *Store) ListFundingRates(exchange, symbol string, start, end time.Time) ([]FundingRate, error) {
+	var rates []FundingRate
+	err := s.engine.Where("exchange = ? AND symbol = ? AND funding_time >= ? AND funding_time <= ?", exchange, symbol, start, end).
+		Asc("funding_time").Find(&rates)
+	return rates, err
+}
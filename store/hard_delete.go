@@ -0,0 +1,135 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+
+	"xorm.io/xorm"
+)
+
+// ErrScriptInUse is returned by HardDeleteScript while a persisted live
+// trade of the script is still running.
+var ErrScriptInUse = errors.New("script in use")
+
+// HardDeleteScript permanently removes a script together with its versions,
+// backtest records and the logs, equity points, trades and positions of
+// those records, the portfolio records with a leg of it, and its stopped
+// live trades with their fills. Everything is deleted in one transaction;
+// it fails with ErrScriptInUse while a live trade of the script is
+// running. It returns the number of rows removed per table.
+func (s *Store) HardDeleteScript(id int64) (map[string]int64, error) {
+	if id <= 0 {
+		return nil, fmt.Errorf("invalid script id %d", id)
+	}
+	sess := s.engine.NewSession()
+	defer sess.Close()
+	if err := sess.Begin(); err != nil {
+		return nil, err
+	}
+	counts, err := hardDeleteScript(sess, id)
+	if err != nil {
+		sess.Rollback()
+		return nil, err
+	}
+	if err := sess.Commit(); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// hardDeleteScript does the deletes of HardDeleteScript. Conditions are
+// given as beans so they follow the engine's column mapping.
+func hardDeleteScript(sess *xorm.Session, id int64) (map[string]int64, error) {
+	has, err := sess.Exist(&Script{ID: id})
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		return nil, notFoundf("script with id %d not found", id)
+	}
+
+	var liveTrades []LiveTrade
+	if err := sess.Find(&liveTrades, &LiveTrade{ScriptID: id}); err != nil {
+		return nil, err
+	}
+	for _, lt := range liveTrades {
+		if lt.Status == LiveTradeRunning {
+			return nil, fmt.Errorf("%w: live trade %s of script %d is running; stop it first", ErrScriptInUse, lt.TradeID, id)
+		}
+	}
+
+	var records []BacktestRecord
+	if err := sess.Find(&records, &BacktestRecord{ScriptID: id}); err != nil {
+		return nil, err
+	}
+	// legs are stored as JSON, so portfolios are matched here
+	var portfolios []PortfolioRecord
+	if err := sess.Find(&portfolios); err != nil {
+		return nil, err
+	}
+	counts := map[string]int64{
+		PortfolioRecord{}.TableName(): 0,
+		BacktestLog{}.TableName():     0,
+		EquityPoint{}.TableName():     0,
+		BacktestTrade{}.TableName():   0,
+		PositionPoint{}.TableName():   0,
+		BacktestRecord{}.TableName():  0,
+		LiveFill{}.TableName():        0,
+		LiveTrade{}.TableName():       0,
+	}
+	del := func(table string, bean interface{}) error {
+		n, err := sess.Delete(bean)
+		if err != nil {
+			return fmt.Errorf("delete from %s failed: %w", table, err)
+		}
+		counts[table] += n
+		return nil
+	}
+	for _, p := range portfolios {
+		for _, leg := range p.Legs {
+			if leg.StrategyID != id {
+				continue
+			}
+			if err := del(PortfolioRecord{}.TableName(), &PortfolioRecord{ID: p.ID}); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+	for _, r := range records {
+		// children first, while the record still exists
+		if err := del(BacktestLog{}.TableName(), &BacktestLog{RecordID: r.ID}); err != nil {
+			return nil, err
+		}
+		if err := del(EquityPoint{}.TableName(), &EquityPoint{RecordID: r.ID}); err != nil {
+			return nil, err
+		}
+		if err := del(BacktestTrade{}.TableName(), &BacktestTrade{RecordID: r.ID}); err != nil {
+			return nil, err
+		}
+		if err := del(PositionPoint{}.TableName(), &PositionPoint{RecordID: r.ID}); err != nil {
+			return nil, err
+		}
+		if err := del(BacktestRecord{}.TableName(), &BacktestRecord{ID: r.ID}); err != nil {
+			return nil, err
+		}
+	}
+	for _, lt := range liveTrades {
+		if err := del(LiveFill{}.TableName(), &LiveFill{TradeID: lt.TradeID}); err != nil {
+			return nil, err
+		}
+		if err := del(LiveTrade{}.TableName(), &LiveTrade{ID: lt.ID}); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := sess.ID(id).Delete(&PerformanceSummary{}); err != nil {
+		return nil, fmt.Errorf("delete from %s failed: %w", PerformanceSummary{}.TableName(), err)
+	}
+	if err := del(ScriptVersion{}.TableName(), &ScriptVersion{ScriptID: id}); err != nil {
+		return nil, err
+	}
+	if err := del(Script{}.TableName(), &Script{ID: id}); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
@@ -0,0 +1,124 @@
+package store
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestHardDeleteScript(t *testing.T) {
+	s := newTestStore(t)
+	keep := &Script{Name: "keep", Content: "package main"}
+	drop := &Script{Name: "drop", Content: "package main"}
+	for _, sc := range []*Script{keep, drop} {
+		if err := s.CreateScript(sc); err != nil {
+			t.Fatalf("CreateScript: %v", err)
+		}
+	}
+	var recordIDs []int64
+	for _, sc := range []*Script{keep, drop} {
+		rec := &BacktestRecord{ScriptID: sc.ID, Symbol: "BTCUSDT"}
+		if err := s.SaveBacktestRecord(rec); err != nil {
+			t.Fatalf("SaveBacktestRecord: %v", err)
+		}
+		recordIDs = append(recordIDs, rec.ID)
+		if err := s.SaveBacktestLogs(rec.ID, []string{"a", "b"}); err != nil {
+			t.Fatalf("SaveBacktestLogs: %v", err)
+		}
+		if err := s.SaveBacktestTrades(rec.ID, []BacktestTrade{{Action: "OpenLong"}}); err != nil {
+			t.Fatalf("SaveBacktestTrades: %v", err)
+		}
+		if err := s.SaveBacktestPositions(rec.ID, []PositionPoint{{Position: 1, AvgPrice: 100}}); err != nil {
+			t.Fatalf("SaveBacktestPositions: %v", err)
+		}
+	}
+
+	// a portfolio with a leg of the dropped script goes with it
+	for _, legs := range [][]PortfolioLeg{
+		{{StrategyID: keep.ID, RecordID: recordIDs[0]}},
+		{{StrategyID: keep.ID, RecordID: recordIDs[0]}, {StrategyID: drop.ID, RecordID: recordIDs[1]}},
+	} {
+		if err := s.SavePortfolioRecord(&PortfolioRecord{Exchange: "binance", StartTime: time.Now(), EndTime: time.Now(), Legs: legs}); err != nil {
+			t.Fatalf("SavePortfolioRecord: %v", err)
+		}
+	}
+
+	counts, err := s.HardDeleteScript(drop.ID)
+	if err != nil {
+		t.Fatalf("HardDeleteScript: %v", err)
+	}
+	want := map[string]int64{
+		"mcp_portfolio_records":  1,
+		"mcp_scripts":            1,
+		"mcp_script_versions":    1,
+		"mcp_backtest_records":   1,
+		"mcp_backtest_logs":      2,
+		"mcp_backtest_trades":    1,
+		"mcp_backtest_equity":    0,
+		"mcp_backtest_positions": 1,
+	}
+	for table, n := range want {
+		if counts[table] != n {
+			t.Fatalf("%s: deleted %d rows, want %d (all: %v)", table, counts[table], n, counts)
+		}
+	}
+
+	if _, err := s.GetScript(drop.ID); err == nil {
+		t.Fatalf("dropped script still exists")
+	}
+	if _, err := s.GetScript(keep.ID); err != nil {
+		t.Fatalf("kept script missing: %v", err)
+	}
+	if n, err := s.engine.Count(&BacktestLog{RecordID: recordIDs[0]}); err != nil || n != 2 {
+		t.Fatalf("logs of kept script: %d %v", n, err)
+	}
+	if _, err := s.GetPortfolioRecord(1); err != nil {
+		t.Fatalf("portfolio of the kept script: %v", err)
+	}
+	if _, err := s.GetPortfolioRecord(2); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("portfolio with a dropped leg: %v", err)
+	}
+	if _, err := s.HardDeleteScript(drop.ID); err == nil {
+		t.Fatalf("expected error deleting a missing script")
+	}
+}
+
+func TestHardDeleteScriptLiveTrades(t *testing.T) {
+	s := newTestStore(t)
+	sc := &Script{Name: "live", Content: "package main"}
+	if err := s.CreateScript(sc); err != nil {
+		t.Fatalf("CreateScript: %v", err)
+	}
+	for _, id := range []string{"binance_BTCUSDT_1", "binance_BTCUSDT_2"} {
+		if err := s.SaveLiveTrade(&LiveTrade{TradeID: id, Exchange: "binance", Symbol: "BTCUSDT", Script: "a.so", ScriptID: sc.ID}); err != nil {
+			t.Fatalf("SaveLiveTrade: %v", err)
+		}
+		if err := s.SaveLiveFill(&LiveFill{TradeID: id, Time: time.Now(), Action: "OpenLong", Price: 1, Amount: 1}); err != nil {
+			t.Fatalf("SaveLiveFill: %v", err)
+		}
+	}
+	if err := s.MarkLiveTradeStopped("binance_BTCUSDT_1"); err != nil {
+		t.Fatalf("MarkLiveTradeStopped: %v", err)
+	}
+
+	if _, err := s.HardDeleteScript(sc.ID); !errors.Is(err, ErrScriptInUse) {
+		t.Fatalf("delete with a running live trade: %v", err)
+	}
+	if _, err := s.GetScript(sc.ID); err != nil {
+		t.Fatalf("refused delete removed the script: %v", err)
+	}
+
+	if err := s.MarkLiveTradeStopped("binance_BTCUSDT_2"); err != nil {
+		t.Fatalf("MarkLiveTradeStopped: %v", err)
+	}
+	counts, err := s.HardDeleteScript(sc.ID)
+	if err != nil {
+		t.Fatalf("HardDeleteScript: %v", err)
+	}
+	if counts["mcp_live_trades"] != 2 || counts["mcp_live_fills"] != 2 {
+		t.Fatalf("live rows deleted: %v", counts)
+	}
+	if trades, err := s.ListLiveTrades(""); err != nil || len(trades) != 0 {
+		t.Fatalf("live trades left: %+v %v", trades, err)
+	}
+}
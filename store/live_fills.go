@@ -0,0 +1,52 @@
+package store
+
+import (
+	"fmt"
+	"time"
+)
+
+// LiveFill is a fill of a live trade as reported by the exchange, with the
+// PnL it realized. Fills are kept after the trade stops so live results can
+// be compared with backtests.
+type LiveFill struct {
+	ID      int64     `xorm:"pk autoincr" json:"-"`
+	TradeID string    `xorm:"varchar(200) notnull index" json:"tradeId"`
+	Time    time.Time `xorm:"notnull index" json:"time"`
+	Action  string    `xorm:"varchar(20) notnull" json:"action"`
+	Price   float64   `json:"price"`
+	Amount  float64   `json:"amount"`
+	// Closed is the part of Amount that reduced the position, zero for
+	// opening fills. EntryPrice is the average entry price of that position
+	// and Profit the PnL realized on it; both are zero when the entry is
+	// unknown, for positions held before a re-attach.
+	Closed     float64 `json:"closed"`
+	EntryPrice float64 `json:"entryPrice"`
+	Profit     float64 `json:"profit"`
+}
+
+func (LiveFill) TableName() string {
+	return "mcp_live_fills"
+}
+
+// SaveLiveFill records a fill of a live trade.
+func (s *Store) SaveLiveFill(f *LiveFill) error {
+	if f == nil || f.TradeID == "" {
+		return fmt.Errorf("live trade id is required")
+	}
+	_, err := s.engine.Insert(f)
+	return err
+}
+
+// ListLiveFills returns the fills of the given live trades in [start, end)
+// in time order.
+func (s *Store) ListLiveFills(tradeIDs []string, start, end time.Time) ([]LiveFill, error) {
+	var fills []LiveFill
+	if len(tradeIDs) == 0 {
+		return fills, nil
+	}
+	timeCol := s.col("Time")
+	err := s.engine.In(s.col("TradeID"), tradeIDs).
+		Where(timeCol+" >= ? AND "+timeCol+" < ?", start, end).
+		Asc(timeCol, s.col("ID")).Find(&fills)
+	return fills, err
+}
@@ -0,0 +1,36 @@
+package store
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLiveFills(t *testing.T) {
+	s := newTestStore(t)
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	fills := []LiveFill{
+		{TradeID: "a", Time: base, Action: "OpenLong", Price: 100, Amount: 1},
+		{TradeID: "b", Time: base.Add(time.Hour), Action: "OpenShort", Price: 100, Amount: 1},
+		{TradeID: "a", Time: base.Add(2 * time.Hour), Action: "CloseLong", Price: 110, Amount: 1, Closed: 1, EntryPrice: 100, Profit: 10},
+		{TradeID: "c", Time: base.Add(2 * time.Hour), Action: "OpenLong", Price: 100, Amount: 1},
+	}
+	for i := range fills {
+		if err := s.SaveLiveFill(&fills[i]); err != nil {
+			t.Fatalf("SaveLiveFill: %v", err)
+		}
+	}
+	if err := s.SaveLiveFill(&LiveFill{}); err == nil {
+		t.Error("fill without trade id accepted")
+	}
+
+	got, err := s.ListLiveFills([]string{"a", "b"}, base.Add(time.Minute), base.Add(3*time.Hour))
+	if err != nil {
+		t.Fatalf("ListLiveFills: %v", err)
+	}
+	if len(got) != 2 || got[0].TradeID != "b" || got[1].Profit != 10 || got[1].EntryPrice != 100 {
+		t.Fatalf("unexpected fills %+v", got)
+	}
+	if got, err := s.ListLiveFills(nil, base, base.Add(time.Hour)); err != nil || len(got) != 0 {
+		t.Fatalf("no trades: %+v, %v", got, err)
+	}
+}
@@ -0,0 +1,101 @@
+package store
+
+import (
+	"fmt"
+	"time"
+)
+
+// Live trade statuses.
+const (
+	LiveTradeRunning = "running"
+	LiveTradeStopped = "stopped"
+)
+
+// LiveTrade is a live trading instance started by start_trade. It is kept
+// after a restart so the instance can be re-attached with attach_trade.
+type LiveTrade struct {
+	ID       int64  `xorm:"pk autoincr" json:"-"`
+	TradeID  string `xorm:"varchar(200) notnull unique" json:"tradeId"`
+	Exchange string `xorm:"varchar(50) notnull" json:"exchange"`
+	Symbol   string `xorm:"varchar(50) notnull" json:"symbol"`
+	Script   string `xorm:"varchar(500) notnull" json:"script"`
+	ScriptID int64  `json:"scriptId,omitempty"` // set when started from a stored strategy
+	// ScriptVersion is the version of the stored strategy the trade runs,
+	// which attach_trade rebuilds; 0 for trades persisted before it was kept.
+	ScriptVersion int       `json:"scriptVersion,omitempty"`
+	Param         string    `xorm:"text" json:"param,omitempty"`
+	RecentDays    int       `json:"recentDays"`
+	Status        string    `xorm:"varchar(20) notnull index" json:"status"`
+	Position      float64   `json:"position"` // net position from the strategy's fills, long positive
+	StartedAt     time.Time `json:"startedAt"`
+	StoppedAt     time.Time `json:"stoppedAt,omitempty"`
+	UpdatedAt     time.Time `xorm:"updated" json:"updatedAt"`
+}
+
+func (LiveTrade) TableName() string {
+	return "mcp_live_trades"
+}
+
+// SaveLiveTrade records a newly started live trade.
+func (s *Store) SaveLiveTrade(t *LiveTrade) error {
+	if t == nil || t.TradeID == "" {
+		return fmt.Errorf("live trade id is required")
+	}
+	if t.Status == "" {
+		t.Status = LiveTradeRunning
+	}
+	_, err := s.engine.Insert(t)
+	return err
+}
+
+// GetLiveTrade returns a live trade by its trade ID.
+func (s *Store) GetLiveTrade(tradeID string) (*LiveTrade, error) {
+	if tradeID == "" {
+		return nil, fmt.Errorf("live trade id is required")
+	}
+	t := &LiveTrade{TradeID: tradeID}
+	has, err := s.engine.Get(t)
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		return nil, notFoundf("live trade %s not found", tradeID)
+	}
+	return t, nil
+}
+
+// ListLiveTrades lists live trades with the given status, or all when
+// status is empty, newest first.
+func (s *Store) ListLiveTrades(status string) ([]LiveTrade, error) {
+	var trades []LiveTrade
+	err := s.engine.Desc(s.col("StartedAt")).Find(&trades, &LiveTrade{Status: status})
+	return trades, err
+}
+
+// UpdateLiveTradePosition stores the net position of a live trade.
+func (s *Store) UpdateLiveTradePosition(tradeID string, position float64) error {
+	if tradeID == "" {
+		return fmt.Errorf("live trade id is required")
+	}
+	n, err := s.engine.Cols(s.col("Position")).Update(&LiveTrade{Position: position}, &LiveTrade{TradeID: tradeID})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		// xorm counts matched rows on sqlite but changed rows on mysql
+		if _, err := s.GetLiveTrade(tradeID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MarkLiveTradeStopped marks a live trade as stopped.
+func (s *Store) MarkLiveTradeStopped(tradeID string) error {
+	if tradeID == "" {
+		return fmt.Errorf("live trade id is required")
+	}
+	_, err := s.engine.Cols(s.col("Status"), s.col("StoppedAt")).
+		Update(&LiveTrade{Status: LiveTradeStopped, StoppedAt: time.Now()}, &LiveTrade{TradeID: tradeID})
+	return err
+}
@@ -0,0 +1,52 @@
+package store
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestLiveTrades(t *testing.T) {
+	s := newTestStore(t)
+	for _, id := range []string{"binance_BTCUSDT_1", "binance_ETHUSDT_2"} {
+		if err := s.SaveLiveTrade(&LiveTrade{TradeID: id, Exchange: "binance", Symbol: "BTCUSDT", Script: "a.so"}); err != nil {
+			t.Fatalf("SaveLiveTrade: %v", err)
+		}
+	}
+	if err := s.UpdateLiveTradePosition("binance_BTCUSDT_1", 0.5); err != nil {
+		t.Fatalf("UpdateLiveTradePosition: %v", err)
+	}
+	if err := s.UpdateLiveTradePosition("binance_BTCUSDT_9", 1); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("position of a missing trade: %v", err)
+	}
+	if err := s.MarkLiveTradeStopped("binance_ETHUSDT_2"); err != nil {
+		t.Fatalf("MarkLiveTradeStopped: %v", err)
+	}
+
+	lt, err := s.GetLiveTrade("binance_BTCUSDT_1")
+	if err != nil {
+		t.Fatalf("GetLiveTrade: %v", err)
+	}
+	if lt.Status != LiveTradeRunning || lt.Position != 0.5 {
+		t.Fatalf("unexpected live trade %+v", lt)
+	}
+	running, err := s.ListLiveTrades(LiveTradeRunning)
+	if err != nil {
+		t.Fatalf("ListLiveTrades: %v", err)
+	}
+	if len(running) != 1 || running[0].TradeID != "binance_BTCUSDT_1" {
+		t.Fatalf("unexpected running trades %+v", running)
+	}
+	all, err := s.ListLiveTrades("")
+	if err != nil || len(all) != 2 {
+		t.Fatalf("ListLiveTrades all: %d %v", len(all), err)
+	}
+	if err := s.SaveLiveTrade(&LiveTrade{TradeID: "binance_BTCUSDT_3", Exchange: "binance", Symbol: "BTCUSDT", Script: "b.so", ScriptID: 7, ScriptVersion: 3}); err != nil {
+		t.Fatalf("SaveLiveTrade: %v", err)
+	}
+	if lt, err := s.GetLiveTrade("binance_BTCUSDT_3"); err != nil || lt.ScriptID != 7 || lt.ScriptVersion != 3 {
+		t.Fatalf("script version not persisted: %+v %v", lt, err)
+	}
+	if _, err := s.GetLiveTrade("missing"); err == nil {
+		t.Fatalf("expected error for missing live trade")
+	}
+}
@@ -0,0 +1,34 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNotOwner matches, via errors.Is, a script that the user may not see or
+// modify.
+var ErrNotOwner = errors.New("not the owner")
+
+// VisibleTo reports whether user may see the script: its owner, anyone when
+// it is shared, and anyone for scripts created without an owner.
+func (sc *Script) VisibleTo(user string) bool {
+	return sc.Owner == "" || sc.Owner == user || sc.Shared
+}
+
+// EditableBy reports whether user may modify the script. Sharing a script
+// does not let others modify it.
+func (sc *Script) EditableBy(user string) bool {
+	return sc.Owner == "" || sc.Owner == user
+}
+
+// CheckScriptAccess returns an error matching ErrNotOwner when user may not
+// see the script or, with write, modify it.
+func CheckScriptAccess(sc *Script, user string, write bool) error {
+	if write && !sc.EditableBy(user) {
+		return fmt.Errorf("%w: strategy %d belongs to %s", ErrNotOwner, sc.ID, sc.Owner)
+	}
+	if !sc.VisibleTo(user) {
+		return fmt.Errorf("%w: strategy %d belongs to %s and is not shared", ErrNotOwner, sc.ID, sc.Owner)
+	}
+	return nil
+}
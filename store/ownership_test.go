@@ -0,0 +1,58 @@
+package store
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestScriptVisibility(t *testing.T) {
+	s := newTestStore(t)
+	for _, sc := range []*Script{
+		{Name: "mine", Content: "package main", Owner: "alice"},
+		{Name: "theirs", Content: "package main", Owner: "bob"},
+		{Name: "shared", Content: "package main", Owner: "bob", Shared: true},
+		{Name: "legacy", Content: "package main"},
+	} {
+		if err := s.CreateScript(sc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	scripts, total, err := s.ListScripts(ScriptFilter{VisibleTo: "alice", SortBy: ScriptSortName, Asc: true})
+	if err != nil || total != 3 {
+		t.Fatalf("total = %d, %v", total, err)
+	}
+	var names []string
+	for _, sc := range scripts {
+		names = append(names, sc.Name)
+	}
+	if len(names) != 3 || names[0] != "legacy" || names[1] != "mine" || names[2] != "shared" {
+		t.Fatalf("visible to alice: %v", names)
+	}
+	if _, total, _ := s.ListScripts(ScriptFilter{}); total != 4 {
+		t.Fatalf("unfiltered total = %d", total)
+	}
+
+	theirs, _ := s.GetScriptByName("theirs")
+	if err := CheckScriptAccess(theirs, "alice", false); !errors.Is(err, ErrNotOwner) {
+		t.Fatalf("read private: %v", err)
+	}
+	shared, _ := s.GetScriptByName("shared")
+	if err := CheckScriptAccess(shared, "alice", false); err != nil {
+		t.Fatalf("read shared: %v", err)
+	}
+	if err := CheckScriptAccess(shared, "alice", true); !errors.Is(err, ErrNotOwner) {
+		t.Fatalf("write shared: %v", err)
+	}
+	if err := CheckScriptAccess(shared, "bob", true); err != nil {
+		t.Fatalf("owner write: %v", err)
+	}
+
+	// sharing is a metadata update
+	if err := s.UpdateScriptMeta(theirs.ID, map[string]interface{}{"shared": true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, total, _ := s.ListScripts(ScriptFilter{VisibleTo: "alice"}); total != 4 {
+		t.Fatalf("after sharing total = %d", total)
+	}
+}
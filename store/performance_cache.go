@@ -0,0 +1,95 @@
+package store
+
+import (
+	"fmt"
+	"time"
+)
+
+// PerformanceSummary is a materialized GetBacktestSummary of a script.
+// Runs and LastRecordID describe the records it was computed from; a cached
+// summary whose script no longer has exactly those is stale and ignored.
+type PerformanceSummary struct {
+	ScriptID     int64                  `xorm:"pk" json:"scriptId"`
+	Summary      map[string]interface{} `xorm:"text json" json:"summary"`
+	Runs         int64                  `json:"runs"`
+	LastRecordID int64                  `json:"lastRecordId"`
+	ComputedAt   time.Time              `json:"computedAt"`
+}
+
+func (PerformanceSummary) TableName() string {
+	return "mcp_performance_summaries"
+}
+
+// backtestRecordStats returns the number of backtest records of a script
+// and the highest record ID.
+func (s *Store) backtestRecordStats(scriptID int64) (runs, lastID int64, err error) {
+	var row struct {
+		Runs   int64
+		LastID int64
+	}
+	_, err = s.engine.SQL(fmt.Sprintf("SELECT COUNT(*) AS runs, COALESCE(MAX(%s), 0) AS last_id FROM %s WHERE %s = ?",
+		s.col("ID"), BacktestRecord{}.TableName(), s.col("ScriptID")), scriptID).Get(&row)
+	return row.Runs, row.LastID, err
+}
+
+// GetCachedBacktestSummary returns the materialized summary of a script and
+// whether there is one that still matches its backtest records.
+func (s *Store) GetCachedBacktestSummary(scriptID int64) (*PerformanceSummary, bool, error) {
+	cached := &PerformanceSummary{}
+	has, err := s.engine.ID(scriptID).Get(cached)
+	if err != nil || !has {
+		return nil, false, err
+	}
+	runs, lastID, err := s.backtestRecordStats(scriptID)
+	if err != nil {
+		return nil, false, err
+	}
+	if runs != cached.Runs || lastID != cached.LastRecordID {
+		return nil, false, nil
+	}
+	return cached, true, nil
+}
+
+// RecomputeBacktestSummary computes GetBacktestSummary of a script afresh
+// and, when materialize is set, stores it as the script's cached summary.
+// A script without backtest records has its cached summary removed.
+func (s *Store) RecomputeBacktestSummary(scriptID int64, materialize bool) (*PerformanceSummary, error) {
+	runs, lastID, err := s.backtestRecordStats(scriptID)
+	if err != nil {
+		return nil, err
+	}
+	if runs == 0 {
+		if err := s.InvalidateBacktestSummary(scriptID); err != nil {
+			return nil, err
+		}
+		return nil, notFoundf("no backtest records found for script %d", scriptID)
+	}
+	summary, err := s.GetBacktestSummary(scriptID)
+	if err != nil {
+		return nil, err
+	}
+	ret := &PerformanceSummary{ScriptID: scriptID, Summary: summary, Runs: runs, LastRecordID: lastID, ComputedAt: time.Now()}
+	if !materialize {
+		return ret, nil
+	}
+	sess := s.engine.NewSession()
+	defer sess.Close()
+	if err := sess.Begin(); err != nil {
+		return nil, err
+	}
+	if _, err := sess.ID(scriptID).Delete(&PerformanceSummary{}); err != nil {
+		sess.Rollback()
+		return nil, err
+	}
+	if _, err := sess.Insert(ret); err != nil {
+		sess.Rollback()
+		return nil, err
+	}
+	return ret, sess.Commit()
+}
+
+// InvalidateBacktestSummary drops the cached summary of a script.
+func (s *Store) InvalidateBacktestSummary(scriptID int64) error {
+	_, err := s.engine.ID(scriptID).Delete(&PerformanceSummary{})
+	return err
+}
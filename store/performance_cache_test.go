@@ -0,0 +1,70 @@
+package store
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPerformanceSummaryCache(t *testing.T) {
+	s := newTestStore(t)
+	sc := &Script{Name: "carry", Content: "package main"}
+	if err := s.CreateScript(sc); err != nil {
+		t.Fatalf("CreateScript: %v", err)
+	}
+	save := func(score float64) *BacktestRecord {
+		r := &BacktestRecord{ScriptID: sc.ID, Symbol: "BTCUSDT", StartTime: time.Now(), EndTime: time.Now(), OverallScore: score}
+		if err := s.SaveBacktestRecord(r); err != nil {
+			t.Fatalf("SaveBacktestRecord: %v", err)
+		}
+		return r
+	}
+	save(40)
+	first := save(60)
+
+	if _, ok, err := s.GetCachedBacktestSummary(sc.ID); ok || err != nil {
+		t.Fatalf("cache before recompute: %v, %v", ok, err)
+	}
+	if _, err := s.RecomputeBacktestSummary(sc.ID, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := s.GetCachedBacktestSummary(sc.ID); ok {
+		t.Fatal("materialize=false stored a summary")
+	}
+	perf, err := s.RecomputeBacktestSummary(sc.ID, true)
+	if err != nil || perf.Runs != 2 {
+		t.Fatalf("recompute: %+v, %v", perf, err)
+	}
+	cached, ok, err := s.GetCachedBacktestSummary(sc.ID)
+	if err != nil || !ok || cached.Summary["avgScore"] != 50.0 {
+		t.Fatalf("cached: %+v, %v, %v", cached, ok, err)
+	}
+
+	// a new record invalidates the cache
+	save(90)
+	if _, ok, _ := s.GetCachedBacktestSummary(sc.ID); ok {
+		t.Fatal("cache survived a new record")
+	}
+	if _, err := s.RecomputeBacktestSummary(sc.ID, true); err != nil {
+		t.Fatal(err)
+	}
+	// so does a record removed behind the store's back
+	if _, err := s.engine.ID(first.ID).Delete(&BacktestRecord{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := s.GetCachedBacktestSummary(sc.ID); ok {
+		t.Fatal("cache survived a deleted record")
+	}
+	perf, err = s.RecomputeBacktestSummary(sc.ID, true)
+	if err != nil || perf.Runs != 2 || perf.Summary["avgScore"] != 65.0 {
+		t.Fatalf("after delete: %+v, %v", perf, err)
+	}
+
+	other := &Script{Name: "empty", Content: "package main"}
+	if err := s.CreateScript(other); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.RecomputeBacktestSummary(other.ID, true); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("no records: %v", err)
+	}
+}
@@ -0,0 +1,64 @@
+package store
+
+import (
+	"fmt"
+	"time"
+)
+
+// PortfolioLeg is one strategy of a portfolio backtest. Each leg is run
+// with its share of the capital and saved as a backtest record.
+type PortfolioLeg struct {
+	StrategyID int64   `json:"strategyId"`
+	Version    int     `json:"version"`
+	Symbol     string  `json:"symbol"`
+	Param      string  `json:"param,omitempty"`
+	Weight     float64 `json:"weight"`     // normalized, the weights sum to 1
+	Allocation float64 `json:"allocation"` // initial capital of the leg
+	RecordID   int64   `json:"recordId"`   // backtest record of the leg
+}
+
+// PortfolioRecord is a saved portfolio backtest: several strategies sharing
+// one capital pool, combined into one equity curve.
+type PortfolioRecord struct {
+	ID           int64          `xorm:"pk autoincr" json:"id"`
+	Exchange     string         `xorm:"varchar(50) notnull" json:"exchange"`
+	StartTime    time.Time      `xorm:"notnull" json:"startTime"`
+	EndTime      time.Time      `xorm:"notnull" json:"endTime"`
+	InitBalance  float64        `json:"initBalance"`
+	Legs         []PortfolioLeg `xorm:"text json" json:"legs"`
+	EndBalance   float64        `json:"endBalance"`
+	TotalReturn  float64        `json:"totalReturn"`
+	AnnualReturn float64        `json:"annualReturn"`
+	MaxDrawdown  float64        `json:"maxDrawdown"`
+	SharpeRatio  float64        `json:"sharpeRatio"`
+	SortinoRatio float64        `json:"sortinoRatio"`
+	UlcerIndex   float64        `json:"ulcerIndex"`
+	RunLabel     string         `xorm:"varchar(100) index" json:"runLabel,omitempty"` // shared with the leg records
+	CreatedAt    time.Time      `xorm:"created" json:"createdAt"`
+}
+
+func (PortfolioRecord) TableName() string {
+	return "mcp_portfolio_records"
+}
+
+// SavePortfolioRecord inserts a portfolio backtest record.
+func (s *Store) SavePortfolioRecord(record *PortfolioRecord) error {
+	if len(record.Legs) == 0 {
+		return fmt.Errorf("portfolio record has no legs")
+	}
+	_, err := s.engine.Insert(record)
+	return err
+}
+
+// GetPortfolioRecord returns a portfolio backtest record by ID.
+func (s *Store) GetPortfolioRecord(id int64) (*PortfolioRecord, error) {
+	record := &PortfolioRecord{}
+	has, err := s.engine.ID(id).Get(record)
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		return nil, notFoundf("portfolio record with id %d not found", id)
+	}
+	return record, nil
+}
@@ -0,0 +1,37 @@
+package store
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPortfolioRecord(t *testing.T) {
+	s := newTestStore(t)
+	if err := s.SavePortfolioRecord(&PortfolioRecord{Exchange: "binance"}); err == nil {
+		t.Fatal("record without legs saved")
+	}
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	rec := &PortfolioRecord{
+		Exchange: "binance", StartTime: start, EndTime: start.Add(24 * time.Hour), InitBalance: 1000,
+		Legs: []PortfolioLeg{
+			{StrategyID: 1, Version: 2, Symbol: "BTCUSDT", Weight: 0.75, Allocation: 750, RecordID: 10},
+			{StrategyID: 3, Version: 1, Symbol: "ETHUSDT", Weight: 0.25, Allocation: 250, RecordID: 11},
+		},
+		TotalReturn: 0.1, RunLabel: "portfolio-x",
+	}
+	if err := s.SavePortfolioRecord(rec); err != nil || rec.ID <= 0 {
+		t.Fatalf("save: %v, id %d", err, rec.ID)
+	}
+	got, err := s.GetPortfolioRecord(rec.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Legs) != 2 || got.Legs[1].Symbol != "ETHUSDT" || got.Legs[0].RecordID != 10 || got.TotalReturn != 0.1 {
+		t.Fatalf("got %+v", got)
+	}
+	if _, err := s.GetPortfolioRecord(rec.ID + 1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("missing record: %v", err)
+	}
+}
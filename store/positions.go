@@ -0,0 +1,48 @@
+package store
+
+import (
+	"fmt"
+	"time"
+)
+
+// PositionPoint is the position of a backtest run after a change reported
+// by the virtual exchange.
+type PositionPoint struct {
+	ID       int64     `xorm:"pk autoincr" json:"-"`
+	RecordID int64     `xorm:"notnull index" json:"-"`
+	Seq      int       `xorm:"notnull" json:"-"`
+	Time     time.Time `xorm:"notnull" json:"time"`
+	Position float64   `json:"position"` // signed, negative for short
+	AvgPrice float64   `json:"avgPrice"` // average entry price, 0 when flat
+}
+
+func (PositionPoint) TableName() string {
+	return "mcp_backtest_positions"
+}
+
+// SaveBacktestPositions persists the position history of a backtest record.
+func (s *Store) SaveBacktestPositions(recordID int64, points []PositionPoint) error {
+	if recordID <= 0 {
+		return fmt.Errorf("invalid record id %d", recordID)
+	}
+	if len(points) == 0 {
+		return nil
+	}
+	rows := make([]PositionPoint, len(points))
+	for i, p := range points {
+		rows[i] = PositionPoint{RecordID: recordID, Seq: i, Time: p.Time, Position: p.Position, AvgPrice: p.AvgPrice}
+	}
+	_, err := s.engine.Insert(&rows)
+	return err
+}
+
+// ListBacktestPositions returns the stored position history of a backtest
+// record in time order.
+func (s *Store) ListBacktestPositions(recordID int64) ([]PositionPoint, error) {
+	if recordID <= 0 {
+		return nil, fmt.Errorf("invalid record id %d", recordID)
+	}
+	var points []PositionPoint
+	err := s.engine.Asc(s.col("Seq")).Find(&points, &PositionPoint{RecordID: recordID})
+	return points, err
+}
@@ -0,0 +1,32 @@
+package store
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBacktestPositions(t *testing.T) {
+	s := newTestStore(t)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	points := []PositionPoint{
+		{Time: start, Position: 1, AvgPrice: 100},
+		{Time: start.Add(time.Hour), Position: 2, AvgPrice: 105},
+		{Time: start.Add(2 * time.Hour), Position: 0},
+	}
+	if err := s.SaveBacktestPositions(1, points); err != nil {
+		t.Fatalf("SaveBacktestPositions: %v", err)
+	}
+	if err := s.SaveBacktestPositions(2, points[:1]); err != nil {
+		t.Fatalf("SaveBacktestPositions: %v", err)
+	}
+	got, err := s.ListBacktestPositions(1)
+	if err != nil {
+		t.Fatalf("ListBacktestPositions: %v", err)
+	}
+	if len(got) != 3 || got[1].Position != 2 || got[1].AvgPrice != 105 || !got[2].Time.Equal(points[2].Time) {
+		t.Fatalf("unexpected positions: %+v", got)
+	}
+	if _, err := s.ListBacktestPositions(0); err == nil {
+		t.Fatalf("expected error for invalid record id")
+	}
+}
@@ -0,0 +1,69 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+
+	"xorm.io/xorm"
+)
+
+// ErrQuotaExceeded matches, via errors.Is, a script or version that would
+// take an owner over its ScriptQuota.
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// ScriptQuota limits the strategies of one owner. Zero means unlimited.
+type ScriptQuota struct {
+	MaxScripts  int64 `json:"maxScripts"`  // active (not deleted) scripts
+	MaxVersions int64 `json:"maxVersions"` // versions over those scripts
+}
+
+// ScriptUsage is what an owner holds against its ScriptQuota.
+type ScriptUsage struct {
+	Scripts  int64 `json:"scripts"`
+	Versions int64 `json:"versions"`
+}
+
+// GetScriptUsage counts the scripts of owner that are not deleted and their
+// versions.
+func (s *Store) GetScriptUsage(owner string) (ScriptUsage, error) {
+	return s.scriptUsage(s.engine, owner)
+}
+
+func (s *Store) scriptUsage(db xorm.Interface, owner string) (ScriptUsage, error) {
+	var usage ScriptUsage
+	scripts, err := db.Where("owner = ? AND status != ?", owner, "deleted").Count(&Script{})
+	if err != nil {
+		return usage, err
+	}
+	usage.Scripts = scripts
+	var row struct{ Versions int64 }
+	_, err = db.SQL(fmt.Sprintf("SELECT COUNT(*) AS versions FROM %s v JOIN %s s ON v.%s = s.%s WHERE s.owner = ? AND s.status != ?",
+		ScriptVersion{}.TableName(), Script{}.TableName(), s.col("ScriptID"), s.col("ID")), owner, "deleted").Get(&row)
+	usage.Versions = row.Versions
+	return usage, err
+}
+
+// CheckScriptQuota returns an error matching ErrQuotaExceeded when adding
+// newScripts scripts and newVersions versions would take owner over quota.
+// It only reports; CreateScriptWithQuota and UpdateScriptWithQuota enforce
+// the quota together with the insert.
+func (s *Store) CheckScriptQuota(owner string, quota ScriptQuota, newScripts, newVersions int64) error {
+	return s.checkScriptQuota(s.engine, owner, quota, newScripts, newVersions)
+}
+
+func (s *Store) checkScriptQuota(db xorm.Interface, owner string, quota ScriptQuota, newScripts, newVersions int64) error {
+	if quota.MaxScripts <= 0 && quota.MaxVersions <= 0 {
+		return nil
+	}
+	usage, err := s.scriptUsage(db, owner)
+	if err != nil {
+		return err
+	}
+	if quota.MaxScripts > 0 && newScripts > 0 && usage.Scripts+newScripts > quota.MaxScripts {
+		return fmt.Errorf("%w: %s has %d of %d strategies; delete unused strategies first", ErrQuotaExceeded, owner, usage.Scripts, quota.MaxScripts)
+	}
+	if quota.MaxVersions > 0 && newVersions > 0 && usage.Versions+newVersions > quota.MaxVersions {
+		return fmt.Errorf("%w: %s has %d of %d strategy versions; delete unused strategies first", ErrQuotaExceeded, owner, usage.Versions, quota.MaxVersions)
+	}
+	return nil
+}
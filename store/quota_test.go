@@ -0,0 +1,96 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestScriptQuota(t *testing.T) {
+	s := newTestStore(t)
+	for _, sc := range []*Script{
+		{Name: "a", Content: "package main", Owner: "alice"},
+		{Name: "b", Content: "package main", Owner: "alice"},
+		{Name: "c", Content: "package main", Owner: "bob"},
+	} {
+		if err := s.CreateScript(sc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := s.UpdateScript(1, "package main // v2", "v2"); err != nil {
+		t.Fatal(err)
+	}
+
+	usage, err := s.GetScriptUsage("alice")
+	if err != nil || usage.Scripts != 2 || usage.Versions != 3 {
+		t.Fatalf("usage = %+v, %v", usage, err)
+	}
+	quota := ScriptQuota{MaxScripts: 2, MaxVersions: 4}
+	if err := s.CheckScriptQuota("alice", quota, 1, 1); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("third script: %v", err)
+	}
+	if err := s.CheckScriptQuota("alice", quota, 0, 1); err != nil {
+		t.Fatalf("fourth version: %v", err)
+	}
+	if err := s.CheckScriptQuota("alice", ScriptQuota{MaxVersions: 3}, 0, 1); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("version over quota: %v", err)
+	}
+	if err := s.CheckScriptQuota("bob", quota, 1, 1); err != nil {
+		t.Fatalf("bob: %v", err)
+	}
+
+	// deleted scripts free the quota
+	if err := s.DeleteScript(2); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CheckScriptQuota("alice", quota, 1, 1); err != nil {
+		t.Fatalf("after delete: %v", err)
+	}
+}
+
+func TestScriptQuotaEnforcedOnWrite(t *testing.T) {
+	s := newTestStore(t)
+	quota := ScriptQuota{MaxScripts: 3, MaxVersions: 5}
+
+	var wg sync.WaitGroup
+	errs := make([]error, 6)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = s.CreateScriptWithQuota(&Script{Name: fmt.Sprintf("s%d", i), Content: "package main", Owner: "alice"}, quota)
+		}(i)
+	}
+	wg.Wait()
+	created := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			created++
+		case !errors.Is(err, ErrQuotaExceeded):
+			t.Fatalf("create: %v", err)
+		}
+	}
+	if created != 3 {
+		t.Fatalf("created %d scripts concurrently, quota is 3", created)
+	}
+
+	scripts, _, err := s.ListScripts(ScriptFilter{})
+	if err != nil || len(scripts) != 3 {
+		t.Fatalf("scripts: %d, %v", len(scripts), err)
+	}
+	id := scripts[0].ID
+	if _, err := s.UpdateScriptWithQuota(id, "package main // v2", "v2", quota); err != nil {
+		t.Fatalf("fourth version: %v", err)
+	}
+	if _, err := s.UpdateScriptWithQuota(id, "package main // v3", "v3", quota); err != nil {
+		t.Fatalf("fifth version: %v", err)
+	}
+	if _, err := s.UpdateScriptWithQuota(id, "package main // v4", "v4", quota); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("sixth version: %v", err)
+	}
+	if sc, err := s.GetScript(id); err != nil || sc.Version != 3 {
+		t.Fatalf("rejected writes changed the script: %+v, %v", sc, err)
+	}
+}
@@ -33,6 +33,11 @@ func sanitizeBacktestRecordForInsert(record *BacktestRecord) []string {
 		{name: "profitFactor", ptr: &record.ProfitFactor},
 		{name: "calmarRatio", ptr: &record.CalmarRatio},
 		{name: "overallScore", ptr: &record.OverallScore},
+		{name: "totalFunding", ptr: &record.TotalFunding},
+		{name: "ulcerIndex", ptr: &record.UlcerIndex},
+		{name: "martinRatio", ptr: &record.MartinRatio},
+		{name: "longProfitFactor", ptr: &record.LongProfitFactor},
+		{name: "shortProfitFactor", ptr: &record.ShortProfitFactor},
 	}
 
 	changed := make([]string, 0)
@@ -1,13 +1,19 @@
 package store
 
 import (
+	"context"
 	"fmt"
+	"math"
+	"strconv"
+	"sync"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 	"xorm.io/xorm"
+
+	"github.com/ztrade/ztrade-mcp/internal/strategysrc"
 )
 
 // Script represents a strategy script stored in the database.
@@ -21,7 +27,13 @@ type Script struct {
 	Status            string    `xorm:"varchar(20) default('active')" json:"status"` // active, archived, deleted
 	LifecycleStatus   string    `xorm:"varchar(20) default('research')" json:"lifecycleStatus"`
 	FieldDescriptions string    `xorm:"text" json:"fieldDescriptions"`
+	DefaultParam      string    `xorm:"text" json:"defaultParam,omitempty"`             // param JSON used when a run passes none, set by apply_param_set
+	DefaultParamName  string    `xorm:"varchar(100)" json:"defaultParamName,omitempty"` // label of DefaultParam
+	HasStopLoss       bool      `xorm:"default(0)" json:"hasStopLoss"`                  // auto-detected from content
+	RiskAnalyzed      bool      `xorm:"default(0)" json:"riskAnalyzed"`                 // HasStopLoss was detected; false when the content did not parse
 	Version           int       `xorm:"default(1)" json:"version"`
+	Owner             string    `xorm:"varchar(100) index" json:"owner,omitempty"` // user that created it, empty without auth
+	Shared            bool      `xorm:"default(0)" json:"shared"`                  // visible to users other than Owner
 	CreatedAt         time.Time `xorm:"created" json:"createdAt"`
 	UpdatedAt         time.Time `xorm:"updated" json:"updatedAt"`
 }
@@ -30,6 +42,16 @@ func (Script) TableName() string {
 	return "mcp_scripts"
 }
 
+// StopLoss returns HasStopLoss, or nil when it is unknown because the
+// content could not be analyzed.
+func (sc *Script) StopLoss() *bool {
+	if !sc.RiskAnalyzed {
+		return nil
+	}
+	has := sc.HasStopLoss
+	return &has
+}
+
 // ScriptVersion represents a historical version of a script.
 type ScriptVersion struct {
 	ID        int64     `xorm:"pk autoincr" json:"id"`
@@ -57,6 +79,10 @@ type BacktestRecord struct {
 	Fee              float64   `json:"fee"`
 	Lever            float64   `json:"lever"`
 	Param            string    `xorm:"text" json:"param"`
+	WarmupBars       int       `json:"warmupBars"`                // leading 1m candles with order execution suppressed
+	LimitOrderModel  bool      `json:"limitOrderModel"`           // orders away from the market filled only when traded through
+	LimitOrderExpiry int       `json:"limitOrderExpiry"`          // 1m candles before resting limit orders expire, 0 = never
+	FillOn           string    `xorm:"varchar(10)" json:"fillOn"` // close or nextOpen: when orders at the market fill, empty on older records
 	TotalActions     int       `json:"totalActions"`
 	WinRate          float64   `json:"winRate"`
 	TotalProfit      float64   `json:"totalProfit"`
@@ -77,7 +103,41 @@ type BacktestRecord struct {
 	OverallScore     float64   `json:"overallScore"`
 	LongTrades       int       `json:"longTrades"`
 	ShortTrades      int       `json:"shortTrades"`
-	CreatedAt        time.Time `xorm:"created" json:"createdAt"`
+	// Long* and Short* split the closing trades by the side they close:
+	// win rate, gross profit / gross loss and net profit / InitBalance.
+	LongWinRate       float64 `json:"longWinRate"`
+	LongProfitFactor  float64 `json:"longProfitFactor"`
+	LongNetReturn     float64 `json:"longNetReturn"`
+	ShortWinRate      float64 `json:"shortWinRate"`
+	ShortProfitFactor float64 `json:"shortProfitFactor"`
+	ShortNetReturn    float64 `json:"shortNetReturn"`
+	TotalFunding      float64 `json:"totalFunding"` // funding paid (+) or received (-), only when includeFunding is set
+	UlcerIndex        float64 `json:"ulcerIndex"`   // RMS of drawdowns over the equity curve
+	MartinRatio       float64 `json:"martinRatio"`  // annual return / ulcer index
+	RiskFreeRate      float64 `json:"riskFreeRate"` // annual rate the Sharpe and Sortino ratios are net of
+	// Regime classifies the market over the backtest range: trending,
+	// ranging or volatile, empty when it could not be classified. RegimeADX
+	// and RegimeVolatility are the measures it was derived from.
+	Regime           string  `xorm:"varchar(16) index" json:"regime,omitempty"`
+	RegimeADX        float64 `json:"regimeAdx,omitempty"`
+	RegimeVolatility float64 `json:"regimeVolatility,omitempty"`
+	// RunLabel groups the runs of one experiment, such as a parameter sweep.
+	RunLabel string `xorm:"varchar(100) index" json:"runLabel,omitempty"`
+	// Chunk marks the per-chunk records of a chunked run_backtest_managed;
+	// its combined record covers the whole range. Summaries and rankings
+	// count only whole runs (see ListRunRecords).
+	Chunk bool `json:"chunk,omitempty"`
+	// The run manifest captures what the result depends on beyond the
+	// columns above: the SHA-256 of the strategy source, the row count and
+	// SHA-256 of the 1m candles in the range, the cost model as JSON, the
+	// plugin build flags and the server's build. Empty on older records.
+	SourceHash    string    `xorm:"varchar(64)" json:"sourceHash,omitempty"`
+	DataRows      int       `json:"dataRows,omitempty"`
+	DataHash      string    `xorm:"varchar(64)" json:"dataHash,omitempty"`
+	CostModel     string    `xorm:"text" json:"costModel,omitempty"`
+	BuildFlags    string    `xorm:"varchar(500)" json:"buildFlags,omitempty"`
+	ServerVersion string    `xorm:"text" json:"serverVersion,omitempty"`
+	CreatedAt     time.Time `xorm:"created" json:"createdAt"`
 }
 
 func (BacktestRecord) TableName() string {
@@ -100,6 +160,9 @@ func (BacktestLog) TableName() string {
 // Store provides database operations for script management.
 type Store struct {
 	engine *xorm.Engine
+	// quotaMu serializes the quota-checked inserts of scripts and versions,
+	// so two of them cannot both pass the count for the last free slot.
+	quotaMu sync.Mutex
 }
 
 // NewStore creates a new Store from viper config.
@@ -116,12 +179,16 @@ func NewStore(cfg *viper.Viper) (*Store, error) {
 	}
 
 	// Auto-sync tables
-	if err := engine.Sync2(new(Script), new(ScriptVersion), new(BacktestRecord), new(BacktestLog)); err != nil {
+	if err := engine.Sync2(new(Script), new(ScriptVersion), new(BacktestRecord), new(BacktestLog), new(FundingRate), new(EquityPoint), new(BacktestTrade), new(LiveTrade), new(LiveFill), new(PositionPoint), new(PortfolioRecord), new(PerformanceSummary)); err != nil {
 		return nil, fmt.Errorf("failed to sync tables: %w", err)
 	}
 
+	s := &Store{engine: engine}
+	if err := s.backfillRiskControls(); err != nil {
+		return nil, fmt.Errorf("failed to analyze existing scripts: %w", err)
+	}
 	log.Info("Script store initialized")
-	return &Store{engine: engine}, nil
+	return s, nil
 }
 
 // Close closes the database connection.
@@ -129,10 +196,22 @@ func (s *Store) Close() error {
 	return s.engine.Close()
 }
 
+// Ping checks that the database is reachable.
+func (s *Store) Ping(ctx context.Context) error {
+	return s.engine.PingContext(ctx)
+}
+
 // --- Script CRUD ---
 
 // CreateScript creates a new script and saves its initial version.
 func (s *Store) CreateScript(script *Script) error {
+	return s.CreateScriptWithQuota(script, ScriptQuota{})
+}
+
+// CreateScriptWithQuota is CreateScript failing with ErrQuotaExceeded when
+// the script and its first version would take script.Owner over quota. The
+// check and the inserts run in one transaction.
+func (s *Store) CreateScriptWithQuota(script *Script, quota ScriptQuota) error {
 	if script == nil {
 		return fmt.Errorf("script is nil")
 	}
@@ -144,8 +223,21 @@ func (s *Store) CreateScript(script *Script) error {
 	if !IsValidStrategyLifecycleStatus(script.LifecycleStatus) {
 		return fmt.Errorf("invalid lifecycleStatus %s", script.LifecycleStatus)
 	}
-	_, err := s.engine.Insert(script)
-	if err != nil {
+	applyRiskControls(script)
+
+	s.quotaMu.Lock()
+	defer s.quotaMu.Unlock()
+	sess := s.engine.NewSession()
+	defer sess.Close()
+	if err := sess.Begin(); err != nil {
+		return err
+	}
+	if err := s.checkScriptQuota(sess, script.Owner, quota, 1, 1); err != nil {
+		sess.Rollback()
+		return err
+	}
+	if _, err := sess.Insert(script); err != nil {
+		sess.Rollback()
 		return err
 	}
 	// Save initial version
@@ -155,8 +247,11 @@ func (s *Store) CreateScript(script *Script) error {
 		Content:  script.Content,
 		Message:  "initial version",
 	}
-	_, err = s.engine.Insert(ver)
-	return err
+	if _, err := sess.Insert(ver); err != nil {
+		sess.Rollback()
+		return err
+	}
+	return sess.Commit()
 }
 
 // GetScript retrieves a script by ID.
@@ -167,7 +262,7 @@ func (s *Store) GetScript(id int64) (*Script, error) {
 		return nil, err
 	}
 	if !has {
-		return nil, fmt.Errorf("script with id %d not found", id)
+		return nil, notFoundf("script with id %d not found", id)
 	}
 	return script, nil
 }
@@ -180,50 +275,261 @@ func (s *Store) GetScriptByName(name string) (*Script, error) {
 		return nil, err
 	}
 	if !has {
-		return nil, fmt.Errorf("script '%s' not found", name)
+		return nil, notFoundf("script '%s' not found", name)
 	}
 	return script, nil
 }
 
-// ListScripts lists scripts with optional filters.
-func (s *Store) ListScripts(status, lifecycleStatus, keyword string) ([]Script, error) {
-	var scripts []Script
-	sess := s.engine.NewSession()
-	defer sess.Close()
+// Sort keys accepted by ScriptFilter.SortBy.
+const (
+	ScriptSortName      = "name"
+	ScriptSortCreatedAt = "createdAt"
+	ScriptSortUpdatedAt = "updatedAt"
+	ScriptSortBestScore = "bestScore"
+)
+
+// ScriptFilter holds optional filters for ListScripts. Zero values mean no filter.
+type ScriptFilter struct {
+	Status          string
+	LifecycleStatus string
+	Keyword         string
+	HasStopLoss     *bool
+	VisibleTo       string    // only scripts this user owns, shared or unowned ones
+	IdleSince       time.Time // only scripts without a backtest record created since then
+
+	SortBy string // one of the ScriptSort* keys, default updatedAt
+	Asc    bool   // ascending order, default descending
+	Offset int
+	Limit  int // 0 means no limit
+}
 
-	if status != "" {
-		sess = sess.Where("status = ?", status)
+// IsValidScriptSort reports whether sortBy is accepted by ListScripts.
+func IsValidScriptSort(sortBy string) bool {
+	switch sortBy {
+	case "", ScriptSortName, ScriptSortCreatedAt, ScriptSortUpdatedAt, ScriptSortBestScore:
+		return true
+	}
+	return false
+}
+
+// col returns the column name of a struct field under the engine's mapping.
+func (s *Store) col(field string) string {
+	return s.engine.GetColumnMapper().Obj2Table(field)
+}
+
+func (s *Store) applyScriptFilter(sess *xorm.Session, filter ScriptFilter) (*xorm.Session, error) {
+	if filter.Status != "" {
+		sess = sess.Where("status = ?", filter.Status)
 	} else {
 		sess = sess.Where("status != ?", "deleted")
 	}
-	if lifecycleStatus != "" {
-		if !IsValidStrategyLifecycleStatus(lifecycleStatus) {
-			return nil, fmt.Errorf("invalid lifecycleStatus %s", lifecycleStatus)
+	if filter.LifecycleStatus != "" {
+		if !IsValidStrategyLifecycleStatus(filter.LifecycleStatus) {
+			return nil, fmt.Errorf("invalid lifecycleStatus %s", filter.LifecycleStatus)
 		}
-		sess = sess.Where("lifecycle_status = ?", lifecycleStatus)
+		sess = sess.Where("lifecycle_status = ?", filter.LifecycleStatus)
 	}
-	if keyword != "" {
-		like := "%" + keyword + "%"
+	if filter.Keyword != "" {
+		like := "%" + filter.Keyword + "%"
 		sess = sess.Where("(name LIKE ? OR description LIKE ? OR tags LIKE ?)", like, like, like)
 	}
-	err := sess.OrderBy("updated_at DESC").Find(&scripts)
-	return scripts, err
+	if filter.HasStopLoss != nil {
+		// scripts whose stop-loss usage is unknown match neither value
+		sess = sess.Where(s.col("HasStopLoss")+" = ? AND "+s.col("RiskAnalyzed")+" = ?", *filter.HasStopLoss, true)
+	}
+	if filter.VisibleTo != "" {
+		sess = sess.Where("(owner = ? OR owner = ? OR shared = ?)", filter.VisibleTo, "", true)
+	}
+	if !filter.IdleSince.IsZero() {
+		sess = sess.Where(fmt.Sprintf("NOT EXISTS (SELECT 1 FROM %s r WHERE r.%s = %s.%s AND r.%s >= ?)",
+			BacktestRecord{}.TableName(), s.col("ScriptID"), Script{}.TableName(), s.col("ID"), s.col("CreatedAt")), filter.IdleSince)
+	}
+	return sess, nil
+}
+
+// scriptOrderBy returns the ORDER BY clause for a filter and its arguments.
+// bestScore sorts by the highest overall score among the script's whole
+// runs, like BestScores; scripts without backtests always come last.
+func (s *Store) scriptOrderBy(filter ScriptFilter) (string, []interface{}, error) {
+	dir := " DESC"
+	if filter.Asc {
+		dir = " ASC"
+	}
+	id := s.col("ID")
+	switch filter.SortBy {
+	case ScriptSortName:
+		return "name" + dir + ", " + id, nil, nil
+	case ScriptSortCreatedAt:
+		return "created_at" + dir + ", " + id, nil, nil
+	case "", ScriptSortUpdatedAt:
+		return "updated_at" + dir + ", " + id, nil, nil
+	case ScriptSortBestScore:
+		chunk := "r." + s.col("Chunk")
+		best := fmt.Sprintf("(SELECT MAX(r.%s) FROM %s r WHERE r.%s = %s.%s AND (%s IS NULL OR %s = ?))",
+			s.col("OverallScore"), BacktestRecord{}.TableName(), s.col("ScriptID"), Script{}.TableName(), id, chunk, chunk)
+		return best + " IS NULL, " + best + dir + ", " + id, []interface{}{false, false}, nil
+	}
+	return "", nil, fmt.Errorf("invalid sortBy %s", filter.SortBy)
+}
+
+// ListScripts lists scripts with optional filters, sorting and pagination.
+// It also returns the number of scripts matching the filters.
+func (s *Store) ListScripts(filter ScriptFilter) ([]Script, int64, error) {
+	orderBy, orderArgs, err := s.scriptOrderBy(filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	countSess := s.engine.NewSession()
+	defer countSess.Close()
+	countSess, err = s.applyScriptFilter(countSess, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	total, err := countSess.Count(new(Script))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	sess := s.engine.NewSession()
+	defer sess.Close()
+	sess, err = s.applyScriptFilter(sess, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	sess = sess.OrderBy(orderBy, orderArgs...)
+	if filter.Limit > 0 || filter.Offset > 0 {
+		limit := filter.Limit
+		if limit <= 0 {
+			limit = math.MaxInt32
+		}
+		sess = sess.Limit(limit, filter.Offset)
+	}
+	var scripts []Script
+	err = sess.Find(&scripts)
+	return scripts, total, err
+}
+
+// BestScores returns the highest backtest overall score of each given
+// script, over whole runs. Scripts without backtests are absent from the map.
+func (s *Store) BestScores(scriptIDs []int64) (map[int64]float64, error) {
+	ret := make(map[int64]float64)
+	if len(scriptIDs) == 0 {
+		return ret, nil
+	}
+	scriptCol := s.col("ScriptID")
+	rows, err := s.engine.Table(new(BacktestRecord)).
+		Select(scriptCol+" AS sid, MAX("+s.col("OverallScore")+") AS best").
+		In(scriptCol, scriptIDs).And(s.wholeRuns()).GroupBy(scriptCol).QueryString()
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		id, err := strconv.ParseInt(row["sid"], 10, 64)
+		if err != nil {
+			continue
+		}
+		best, err := strconv.ParseFloat(row["best"], 64)
+		if err != nil {
+			continue
+		}
+		ret[id] = best
+	}
+	return ret, nil
+}
+
+// applyRiskControls detects stop-loss usage in the script content and
+// updates HasStopLoss, RiskAnalyzed and the corresponding auto-tag. Content
+// that does not parse leaves the usage unknown and carries no auto-tag.
+func applyRiskControls(script *Script) {
+	rc, err := strategysrc.AnalyzeRiskControls(script.Content)
+	if err != nil {
+		log.Debugf("analyze risk controls for script %s failed: %s", script.Name, err.Error())
+	}
+	script.HasStopLoss, script.RiskAnalyzed = rc.HasStopLoss, err == nil
+	script.Tags = riskTags(script.Tags, script)
+}
+
+// riskTags returns tags with the stop-loss auto-tag of script.
+func riskTags(tags string, script *Script) string {
+	if !script.RiskAnalyzed {
+		return strategysrc.ClearRiskTags(tags)
+	}
+	return strategysrc.ApplyRiskTags(tags, script.HasStopLoss)
+}
+
+// backfillRiskControls analyzes the scripts saved before stop-loss
+// detection, or whose content did not parse, so the hasStopLoss filter
+// does not take them for scripts without a stop loss.
+func (s *Store) backfillRiskControls() error {
+	var scripts []Script
+	err := s.engine.Cols(s.col("ID"), s.col("Name"), s.col("Content"), s.col("Tags")).
+		Where(s.col("RiskAnalyzed")+" = ?", false).Find(&scripts)
+	if err != nil {
+		return err
+	}
+	for i := range scripts {
+		sc := &scripts[i]
+		applyRiskControls(sc)
+		if !sc.RiskAnalyzed {
+			continue
+		}
+		_, err := s.engine.ID(sc.ID).NoAutoTime().
+			Cols(s.col("HasStopLoss"), s.col("RiskAnalyzed"), s.col("Tags")).Update(sc)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // UpdateScript updates a script's content and bumps the version.
 func (s *Store) UpdateScript(id int64, content, message string) (*Script, error) {
-	script, err := s.GetScript(id)
+	return s.UpdateScriptWithQuota(id, content, message, ScriptQuota{})
+}
+
+// UpdateScriptWithQuota is UpdateScript failing with ErrQuotaExceeded when
+// the new version would take the script's owner over quota. The check and
+// the writes run in one transaction.
+func (s *Store) UpdateScriptWithQuota(id int64, content, message string, quota ScriptQuota) (*Script, error) {
+	s.quotaMu.Lock()
+	defer s.quotaMu.Unlock()
+	sess := s.engine.NewSession()
+	defer sess.Close()
+	if err := sess.Begin(); err != nil {
+		return nil, err
+	}
+	script, err := s.updateScript(sess, id, content, message, quota)
 	if err != nil {
+		sess.Rollback()
 		return nil, err
 	}
+	if err := sess.Commit(); err != nil {
+		return nil, err
+	}
+	return script, nil
+}
+
+func (s *Store) updateScript(sess *xorm.Session, id int64, content, message string, quota ScriptQuota) (*Script, error) {
+	script := &Script{}
+	has, err := sess.ID(id).Get(script)
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		return nil, notFoundf("script with id %d not found", id)
+	}
 	if IsStrategyLockedForEdit(script.LifecycleStatus) {
 		return nil, fmt.Errorf("strategy is stable and locked for edit; set lifecycleStatus to %s/%s/%s before modifying", StrategyLifecycleResearch, StrategyLifecycleDevelopment, StrategyLifecycleTesting)
 	}
+	if err := s.checkScriptQuota(sess, script.Owner, quota, 0, 1); err != nil {
+		return nil, err
+	}
 
 	script.Version++
 	script.Content = content
+	applyRiskControls(script)
 
-	_, err = s.engine.ID(id).Cols("content", "version", "updated_at").Update(script)
+	_, err = sess.ID(id).Cols("content", "version", s.col("HasStopLoss"), s.col("RiskAnalyzed"), "tags", "updated_at").Update(script)
 	if err != nil {
 		return nil, err
 	}
@@ -235,7 +541,7 @@ func (s *Store) UpdateScript(id int64, content, message string) (*Script, error)
 		Content:  content,
 		Message:  message,
 	}
-	_, err = s.engine.Insert(ver)
+	_, err = sess.Insert(ver)
 	if err != nil {
 		return nil, err
 	}
@@ -243,7 +549,8 @@ func (s *Store) UpdateScript(id int64, content, message string) (*Script, error)
 	return script, nil
 }
 
-// UpdateScriptMeta updates script metadata (name, description, tags, status).
+// UpdateScriptMeta updates script metadata (name, description, tags, status,
+// shared, default param).
 func (s *Store) UpdateScriptMeta(id int64, fields map[string]interface{}) error {
 	if len(fields) == 0 {
 		return nil
@@ -262,6 +569,18 @@ func (s *Store) UpdateScriptMeta(id int64, fields map[string]interface{}) error
 			return fmt.Errorf("field_descriptions must be string")
 		}
 	}
+	for _, key := range []string{"default_param", "default_param_name"} {
+		if v, ok := fields[key]; ok {
+			if _, ok := v.(string); !ok {
+				return fmt.Errorf("%s must be string", key)
+			}
+		}
+	}
+	if v, ok := fields["shared"]; ok {
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("shared must be bool")
+		}
+	}
 
 	script, err := s.GetScript(id)
 	if err != nil {
@@ -277,6 +596,14 @@ func (s *Store) UpdateScriptMeta(id int64, fields map[string]interface{}) error
 			return fmt.Errorf("strategy is stable and locked for edit; set lifecycleStatus to %s/%s/%s before modifying other fields", StrategyLifecycleResearch, StrategyLifecycleDevelopment, StrategyLifecycleTesting)
 		}
 	}
+	if v, ok := fields["tags"]; ok {
+		tags, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("tags must be string")
+		}
+		// keep the auto-detected risk tag in sync with user supplied tags
+		fields["tags"] = riskTags(tags, script)
+	}
 
 	_, err = s.engine.Table(new(Script)).ID(id).Update(fields)
 	return err
@@ -293,30 +620,31 @@ func (s *Store) DeleteScript(id int64) error {
 // ListVersions lists all versions of a script.
 func (s *Store) ListVersions(scriptID int64) ([]ScriptVersion, error) {
 	var versions []ScriptVersion
-	err := s.engine.Where("script_id = ?", scriptID).OrderBy("version DESC").Find(&versions)
+	err := s.engine.Where(s.col("ScriptID")+" = ?", scriptID).Desc(s.col("Version")).Find(&versions)
 	return versions, err
 }
 
 // GetVersion retrieves a specific version of a script.
 func (s *Store) GetVersion(scriptID int64, version int) (*ScriptVersion, error) {
 	ver := &ScriptVersion{}
-	has, err := s.engine.Where("script_id = ? AND version = ?", scriptID, version).Get(ver)
+	has, err := s.engine.Where(s.col("ScriptID")+" = ? AND "+s.col("Version")+" = ?", scriptID, version).Get(ver)
 	if err != nil {
 		return nil, err
 	}
 	if !has {
-		return nil, fmt.Errorf("version %d of script %d not found", version, scriptID)
+		return nil, notFoundf("version %d of script %d not found", version, scriptID)
 	}
 	return ver, nil
 }
 
-// RollbackScript reverts a script to a specific version.
-func (s *Store) RollbackScript(scriptID int64, version int) (*Script, error) {
+// RollbackScript reverts a script to a specific version by saving its
+// content as a new version, which must fit quota like UpdateScriptWithQuota.
+func (s *Store) RollbackScript(scriptID int64, version int, quota ScriptQuota) (*Script, error) {
 	ver, err := s.GetVersion(scriptID, version)
 	if err != nil {
 		return nil, err
 	}
-	return s.UpdateScript(scriptID, ver.Content, fmt.Sprintf("rollback to version %d", version))
+	return s.UpdateScriptWithQuota(scriptID, ver.Content, fmt.Sprintf("rollback to version %d", version), quota)
 }
 
 // DiffVersions returns content of two versions for comparison.
@@ -342,14 +670,42 @@ func (s *Store) SaveBacktestRecord(record *BacktestRecord) error {
 	if fields := sanitizeBacktestRecordForInsert(record); len(fields) > 0 {
 		log.WithField("fields", fields).Warn("sanitized non-finite backtest record fields")
 	}
-	_, err := s.engine.Insert(record)
-	return err
+	if _, err := s.engine.Insert(record); err != nil {
+		return err
+	}
+	if err := s.InvalidateBacktestSummary(record.ScriptID); err != nil {
+		log.WithError(err).WithField("scriptId", record.ScriptID).Warn("invalidate cached performance summary failed")
+	}
+	return nil
+}
+
+// GetBacktestRecord returns a backtest record by ID.
+func (s *Store) GetBacktestRecord(id int64) (*BacktestRecord, error) {
+	record := &BacktestRecord{}
+	has, err := s.engine.ID(id).Get(record)
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		return nil, notFoundf("backtest record with id %d not found", id)
+	}
+	return record, nil
 }
 
 // ListBacktestRecords lists backtest records for a script.
 func (s *Store) ListBacktestRecords(scriptID int64, limit int) ([]BacktestRecord, error) {
+	return s.ListBacktestRecordsByLabel(scriptID, "", limit)
+}
+
+// ListBacktestRecordsByLabel is ListBacktestRecords restricted to the runs
+// labeled runLabel; an empty label does not filter.
+func (s *Store) ListBacktestRecordsByLabel(scriptID int64, runLabel string, limit int) ([]BacktestRecord, error) {
 	var records []BacktestRecord
-	sess := s.engine.Where("script_id = ?", scriptID).OrderBy("created_at DESC")
+	sess := s.engine.Where(s.col("ScriptID")+" = ?", scriptID)
+	if runLabel != "" {
+		sess = sess.Where(s.col("RunLabel")+" = ?", runLabel)
+	}
+	sess = sess.Desc(s.col("CreatedAt"), s.col("ID"))
 	if limit > 0 {
 		sess = sess.Limit(limit)
 	}
@@ -357,10 +713,27 @@ func (s *Store) ListBacktestRecords(scriptID int64, limit int) ([]BacktestRecord
 	return records, err
 }
 
+// wholeRuns is the condition excluding chunk records, with its argument.
+// Records saved before the column existed hold NULL.
+func (s *Store) wholeRuns() (string, bool) {
+	chunk := s.col("Chunk")
+	return "(" + chunk + " IS NULL OR " + chunk + " = ?)", false
+}
+
+// ListRunRecords lists a script's backtest records like ListBacktestRecords
+// without the chunk records of chunked runs, whose combined record already
+// covers them. Rankings and quality checks use it.
+func (s *Store) ListRunRecords(scriptID int64) ([]BacktestRecord, error) {
+	var records []BacktestRecord
+	err := s.engine.Where(s.col("ScriptID")+" = ?", scriptID).And(s.wholeRuns()).
+		Desc(s.col("CreatedAt"), s.col("ID")).Find(&records)
+	return records, err
+}
+
 // GetBestBacktest returns the best performing backtest for a script by overall score.
 func (s *Store) GetBestBacktest(scriptID int64) (*BacktestRecord, error) {
 	record := &BacktestRecord{}
-	has, err := s.engine.Where("script_id = ?", scriptID).OrderBy("overall_score DESC").Get(record)
+	has, err := s.engine.Where(s.col("ScriptID")+" = ?", scriptID).And(s.wholeRuns()).Desc(s.col("OverallScore")).Get(record)
 	if err != nil {
 		return nil, err
 	}
@@ -370,24 +743,109 @@ func (s *Store) GetBestBacktest(scriptID int64) (*BacktestRecord, error) {
 	return record, nil
 }
 
-// GetBacktestSummary returns aggregate stats for a script's backtest history.
+// GetBacktestSummary returns aggregate stats for a script's backtest
+// history: the same as summarizeBacktests over its whole runs (see
+// ListRunRecords), computed by aggregate queries so memory does not grow
+// with the history.
 func (s *Store) GetBacktestSummary(scriptID int64) (map[string]interface{}, error) {
-	records, err := s.ListBacktestRecords(scriptID, 0)
-	if err != nil {
+	var agg struct {
+		Runs       int64
+		AvgScore   float64
+		MaxScore   float64
+		MinScore   float64
+		AvgSharpe  float64
+		MaxSharpe  float64
+		MinSharpe  float64
+		MaxWinRate float64
+		MinWinRate float64
+		AvgUlcer   float64
+		MaxMartin  float64
+		MinMartin  float64
+	}
+	score, sharpe, winRate := s.col("OverallScore"), s.col("SharpeRatio"), s.col("WinRate")
+	ulcer, martin := s.col("UlcerIndex"), s.col("MartinRatio")
+	wholeCond, wholeArg := s.wholeRuns()
+	query := fmt.Sprintf("SELECT COUNT(*) AS runs, "+
+		"COALESCE(AVG(%[1]s), 0) AS avg_score, COALESCE(MAX(%[1]s), 0) AS max_score, COALESCE(MIN(%[1]s), 0) AS min_score, "+
+		"COALESCE(AVG(%[2]s), 0) AS avg_sharpe, COALESCE(MAX(%[2]s), 0) AS max_sharpe, COALESCE(MIN(%[2]s), 0) AS min_sharpe, "+
+		"COALESCE(MAX(%[3]s), 0) AS max_win_rate, COALESCE(MIN(%[3]s), 0) AS min_win_rate, "+
+		"COALESCE(AVG(%[4]s), 0) AS avg_ulcer, COALESCE(MAX(%[5]s), 0) AS max_martin, COALESCE(MIN(%[5]s), 0) AS min_martin "+
+		"FROM %[6]s WHERE %[7]s = ? AND %[8]s",
+		score, sharpe, winRate, ulcer, martin, BacktestRecord{}.TableName(), s.col("ScriptID"), wholeCond)
+	if _, err := s.engine.SQL(query, scriptID, wholeArg).Get(&agg); err != nil {
 		return nil, err
 	}
-	if len(records) == 0 {
+	if agg.Runs == 0 {
 		return nil, fmt.Errorf("no backtest records found for script %d", scriptID)
 	}
 
+	// the bests start from zero as in summarizeBacktests, so a history of
+	// only negative values has a best of 0 and no bestRun
+	summary := map[string]interface{}{
+		"totalRuns":        int(agg.Runs),
+		"avgScore":         agg.AvgScore,
+		"bestScore":        max(agg.MaxScore, 0),
+		"worstScore":       agg.MinScore,
+		"avgSharpe":        agg.AvgSharpe,
+		"bestSharpe":       max(agg.MaxSharpe, 0),
+		"worstSharpe":      agg.MinSharpe,
+		"bestWinRate":      max(agg.MaxWinRate, 0),
+		"worstWinRate":     agg.MinWinRate,
+		"avgUlcerIndex":    agg.AvgUlcer,
+		"bestMartinRatio":  max(agg.MaxMartin, 0),
+		"worstMartinRatio": agg.MinMartin,
+	}
+	// ties go to the newest record, the first in ListBacktestRecords order
+	run := func(desc bool) (map[string]interface{}, error) {
+		record := &BacktestRecord{}
+		sess := s.engine.Where(s.col("ScriptID")+" = ?", scriptID).And(wholeCond, wholeArg).
+			Cols(s.col("ID"), s.col("ScriptVersion"), s.col("Exchange"), s.col("Symbol"), s.col("Param"))
+		if desc {
+			sess = sess.Desc(score)
+		} else {
+			sess = sess.Asc(score)
+		}
+		if _, err := sess.Desc(s.col("CreatedAt"), s.col("ID")).Get(record); err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{
+			"id":       record.ID,
+			"version":  record.ScriptVersion,
+			"exchange": record.Exchange,
+			"symbol":   record.Symbol,
+			"param":    record.Param,
+		}, nil
+	}
+	if agg.MaxScore > 0 {
+		best, err := run(true)
+		if err != nil {
+			return nil, err
+		}
+		summary["bestRun"] = best
+	}
+	worst, err := run(false)
+	if err != nil {
+		return nil, err
+	}
+	summary["worstRun"] = worst
+	return summary, nil
+}
+
+// summarizeBacktests computes the aggregate stats of GetBacktestSummary in
+// memory over records, which must not be empty; GetBacktestSummaryGroups
+// and experiments use it on their subsets.
+func summarizeBacktests(records []BacktestRecord) map[string]interface{} {
 	var totalScore, bestScore, worstScore float64
 	var bestSharpe, worstSharpe float64
 	var bestWinRate, worstWinRate float64
+	var totalUlcer, bestMartin, worstMartin float64
+	var totalSharpe float64
 	var bestRecord, worstRecord *BacktestRecord
 
 	worstScore = 1e18
 	worstSharpe = 1e18
 	worstWinRate = 1e18
+	worstMartin = 1e18
 
 	for i := range records {
 		r := &records[i]
@@ -400,6 +858,7 @@ func (s *Store) GetBacktestSummary(scriptID int64) (map[string]interface{}, erro
 			worstScore = r.OverallScore
 			worstRecord = r
 		}
+		totalSharpe += r.SharpeRatio
 		if r.SharpeRatio > bestSharpe {
 			bestSharpe = r.SharpeRatio
 		}
@@ -412,17 +871,28 @@ func (s *Store) GetBacktestSummary(scriptID int64) (map[string]interface{}, erro
 		if r.WinRate < worstWinRate {
 			worstWinRate = r.WinRate
 		}
+		totalUlcer += r.UlcerIndex
+		if r.MartinRatio > bestMartin {
+			bestMartin = r.MartinRatio
+		}
+		if r.MartinRatio < worstMartin {
+			worstMartin = r.MartinRatio
+		}
 	}
 
 	summary := map[string]interface{}{
-		"totalRuns":    len(records),
-		"avgScore":     totalScore / float64(len(records)),
-		"bestScore":    bestScore,
-		"worstScore":   worstScore,
-		"bestSharpe":   bestSharpe,
-		"worstSharpe":  worstSharpe,
-		"bestWinRate":  bestWinRate,
-		"worstWinRate": worstWinRate,
+		"totalRuns":        len(records),
+		"avgScore":         totalScore / float64(len(records)),
+		"bestScore":        bestScore,
+		"worstScore":       worstScore,
+		"avgSharpe":        totalSharpe / float64(len(records)),
+		"bestSharpe":       bestSharpe,
+		"worstSharpe":      worstSharpe,
+		"bestWinRate":      bestWinRate,
+		"worstWinRate":     worstWinRate,
+		"avgUlcerIndex":    totalUlcer / float64(len(records)),
+		"bestMartinRatio":  bestMartin,
+		"worstMartinRatio": worstMartin,
 	}
 	if bestRecord != nil {
 		summary["bestRun"] = map[string]interface{}{
@@ -442,5 +912,5 @@ func (s *Store) GetBacktestSummary(scriptID int64) (map[string]interface{}, erro
 			"param":    worstRecord.Param,
 		}
 	}
-	return summary, nil
+	return summary
 }
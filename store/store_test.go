@@ -0,0 +1,242 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	_ "modernc.org/sqlite"
+)
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	cfg := viper.New()
+	cfg.Set("db.type", "sqlite")
+	cfg.Set("db.uri", filepath.Join(t.TempDir(), "test.db"))
+	s, err := NewStore(cfg)
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestListScriptsSortAndPage(t *testing.T) {
+	s := newTestStore(t)
+	ids := map[string]int64{}
+	for _, name := range []string{"b", "a", "c"} {
+		sc := &Script{Name: name, Content: "package main"}
+		if err := s.CreateScript(sc); err != nil {
+			t.Fatalf("CreateScript: %v", err)
+		}
+		ids[name] = sc.ID
+	}
+	for name, score := range map[string]float64{"a": 10, "b": 50} {
+		if err := s.SaveBacktestRecord(&BacktestRecord{ScriptID: ids[name], OverallScore: score}); err != nil {
+			t.Fatalf("SaveBacktestRecord: %v", err)
+		}
+	}
+	if err := s.SaveBacktestRecord(&BacktestRecord{ScriptID: ids["a"], OverallScore: 70}); err != nil {
+		t.Fatalf("SaveBacktestRecord: %v", err)
+	}
+	// a chunk record of a chunked run does not count towards bestScore
+	if err := s.SaveBacktestRecord(&BacktestRecord{ScriptID: ids["b"], OverallScore: 99, Chunk: true}); err != nil {
+		t.Fatalf("SaveBacktestRecord: %v", err)
+	}
+	if best, err := s.BestScores([]int64{ids["a"], ids["b"]}); err != nil || best[ids["a"]] != 70 || best[ids["b"]] != 50 {
+		t.Fatalf("BestScores: %v, %v", best, err)
+	}
+
+	names := func(filter ScriptFilter) []string {
+		t.Helper()
+		scripts, total, err := s.ListScripts(filter)
+		if err != nil {
+			t.Fatalf("ListScripts(%+v): %v", filter, err)
+		}
+		if total != 3 {
+			t.Fatalf("total = %d, want 3", total)
+		}
+		var ret []string
+		for _, sc := range scripts {
+			ret = append(ret, sc.Name)
+		}
+		return ret
+	}
+	cases := []struct {
+		filter ScriptFilter
+		want   string
+	}{
+		{ScriptFilter{SortBy: ScriptSortName, Asc: true}, "[a b c]"},
+		{ScriptFilter{SortBy: ScriptSortName}, "[c b a]"},
+		{ScriptFilter{SortBy: ScriptSortBestScore}, "[a b c]"},
+		{ScriptFilter{SortBy: ScriptSortBestScore, Asc: true}, "[b a c]"},
+		{ScriptFilter{SortBy: ScriptSortName, Asc: true, Offset: 1, Limit: 1}, "[b]"},
+		{ScriptFilter{SortBy: ScriptSortName, Asc: true, Offset: 2}, "[c]"},
+	}
+	for _, c := range cases {
+		if got := fmt.Sprint(names(c.filter)); got != c.want {
+			t.Fatalf("ListScripts(%+v) = %s, want %s", c.filter, got, c.want)
+		}
+	}
+	if _, _, err := s.ListScripts(ScriptFilter{SortBy: "size"}); err == nil {
+		t.Fatalf("expected error for invalid sortBy")
+	}
+
+	best, err := s.BestScores([]int64{ids["a"], ids["b"], ids["c"]})
+	if err != nil {
+		t.Fatalf("BestScores: %v", err)
+	}
+	if best[ids["a"]] != 70 || best[ids["b"]] != 50 {
+		t.Fatalf("unexpected best scores %v", best)
+	}
+	if _, ok := best[ids["c"]]; ok {
+		t.Fatalf("script without backtests should have no best score")
+	}
+}
+
+func TestNotFoundErrors(t *testing.T) {
+	st := newTestStore(t)
+	if _, err := st.GetScript(42); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetScript: %v is not ErrNotFound", err)
+	}
+	if _, err := st.GetLiveTrade("nope"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetLiveTrade: %v is not ErrNotFound", err)
+	}
+}
+
+func TestListBacktestRecords(t *testing.T) {
+	s := newTestStore(t)
+	for _, scriptID := range []int64{1, 2, 1} {
+		if err := s.SaveBacktestRecord(&BacktestRecord{ScriptID: scriptID, Symbol: "BTCUSDT"}); err != nil {
+			t.Fatalf("SaveBacktestRecord: %v", err)
+		}
+	}
+	records, err := s.ListBacktestRecords(1, 0)
+	if err != nil {
+		t.Fatalf("ListBacktestRecords: %v", err)
+	}
+	if len(records) != 2 || records[0].ID != 3 || records[1].ID != 1 {
+		t.Fatalf("want records 3, 1 newest first, got %+v", records)
+	}
+	if records, _ := s.ListBacktestRecords(1, 1); len(records) != 1 {
+		t.Fatalf("limit ignored: %d records", len(records))
+	}
+}
+
+func TestListScriptsIdleSince(t *testing.T) {
+	s := newTestStore(t)
+	ids := map[string]int64{}
+	for _, name := range []string{"recent", "stale", "never"} {
+		sc := &Script{Name: name, Content: "package main"}
+		if err := s.CreateScript(sc); err != nil {
+			t.Fatal(err)
+		}
+		ids[name] = sc.ID
+	}
+	for _, name := range []string{"recent", "stale"} {
+		if err := s.SaveBacktestRecord(&BacktestRecord{ScriptID: ids[name]}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := time.Now().AddDate(0, -3, 0)
+	if _, err := s.engine.Table(new(BacktestRecord)).Where(s.col("ScriptID")+" = ?", ids["stale"]).Update(map[string]interface{}{s.col("CreatedAt"): old}); err != nil {
+		t.Fatal(err)
+	}
+
+	scripts, total, err := s.ListScripts(ScriptFilter{IdleSince: time.Now().AddDate(0, -1, 0), SortBy: ScriptSortName, Asc: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2 || len(scripts) != 2 || scripts[0].Name != "never" || scripts[1].Name != "stale" {
+		t.Fatalf("idle scripts = %+v (total %d)", scripts, total)
+	}
+}
+
+func TestVersionsAndBestBacktest(t *testing.T) {
+	s := newTestStore(t)
+	sc := &Script{Name: "versions", Content: "package main // v1"}
+	if err := s.CreateScript(sc); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.UpdateScript(sc.ID, "package main // v2", "v2"); err != nil {
+		t.Fatal(err)
+	}
+
+	versions, err := s.ListVersions(sc.ID)
+	if err != nil || len(versions) != 2 || versions[0].Version != 2 || versions[1].Version != 1 {
+		t.Fatalf("ListVersions: %+v, %v", versions, err)
+	}
+	ver, err := s.GetVersion(sc.ID, 1)
+	if err != nil || ver.Content != "package main // v1" {
+		t.Fatalf("GetVersion: %+v, %v", ver, err)
+	}
+	if _, err := s.GetVersion(sc.ID, 9); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("missing version: %v", err)
+	}
+	rolled, err := s.RollbackScript(sc.ID, 1, ScriptQuota{})
+	if err != nil || rolled.Version != 3 || rolled.Content != "package main // v1" {
+		t.Fatalf("RollbackScript: %+v, %v", rolled, err)
+	}
+	if _, err := s.RollbackScript(sc.ID, 1, ScriptQuota{MaxVersions: 3}); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("rollback over quota: %v", err)
+	}
+
+	if _, err := s.GetBestBacktest(sc.ID); err == nil {
+		t.Fatal("best backtest without records")
+	}
+	for _, score := range []float64{10, 70, 40} {
+		if err := s.SaveBacktestRecord(&BacktestRecord{ScriptID: sc.ID, OverallScore: score}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	best, err := s.GetBestBacktest(sc.ID)
+	if err != nil || best.OverallScore != 70 {
+		t.Fatalf("GetBestBacktest: %+v, %v", best, err)
+	}
+}
+
+func TestRiskControlsBackfill(t *testing.T) {
+	cfg := viper.New()
+	cfg.Set("db.type", "sqlite")
+	cfg.Set("db.uri", filepath.Join(t.TempDir(), "test.db"))
+	s, err := NewStore(cfg)
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	stop := &Script{Name: "stop", Content: "package main\n\nfunc (s *S) OnCandle() { s.engine.StopLong(1, 1) }\n"}
+	broken := &Script{Name: "broken", Content: "package main\nfunc ("}
+	for _, sc := range []*Script{stop, broken} {
+		if err := s.CreateScript(sc); err != nil {
+			t.Fatalf("CreateScript: %v", err)
+		}
+	}
+	if broken.StopLoss() != nil || strings.Contains(broken.Tags, "stop-loss") {
+		t.Fatalf("unparsable script: analyzed %v, tags %q", broken.RiskAnalyzed, broken.Tags)
+	}
+	noStop := false
+	if scripts, _, err := s.ListScripts(ScriptFilter{HasStopLoss: &noStop}); err != nil || len(scripts) != 0 {
+		t.Fatalf("hasStopLoss=false: %+v, %v", scripts, err)
+	}
+
+	// a script saved before detection reads as not analyzed
+	_, err = s.engine.ID(stop.ID).Cols(s.col("HasStopLoss"), s.col("RiskAnalyzed"), s.col("Tags")).Update(&Script{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+	if s, err = NewStore(cfg); err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	defer s.Close()
+	sc, err := s.GetScript(stop.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := sc.StopLoss(); p == nil || !*p || sc.Tags != "stop-loss" {
+		t.Fatalf("backfilled script: %+v", sc)
+	}
+}
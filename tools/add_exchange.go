@@ -0,0 +1,212 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// configMu serializes add_exchange calls, so the config file and the
+// exchange registry are updated by one writer at a time.
+var configMu sync.Mutex
+
+// setYAMLExchange sets exchanges.<name> in a YAML document to entry, leaving
+// every other node (and its comments) untouched.
+func setYAMLExchange(data []byte, name string, entry map[string]interface{}) ([]byte, error) {
+	var doc yaml.Node
+	if len(bytes.TrimSpace(data)) > 0 {
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("parse config file: %w", err)
+		}
+	}
+	if doc.Kind == 0 {
+		doc.Kind = yaml.DocumentNode
+		doc.Content = []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("config file root is not a mapping")
+	}
+
+	var entryNode yaml.Node
+	if err := entryNode.Encode(entry); err != nil {
+		return nil, err
+	}
+	exchanges := yamlMapChild(doc.Content[0], "exchanges")
+	if exchanges.Kind != yaml.MappingNode {
+		exchanges.Kind = yaml.MappingNode
+		exchanges.Tag = "!!map"
+		exchanges.Value = ""
+		exchanges.Content = nil
+	}
+	*yamlMapChild(exchanges, name) = entryNode
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// yamlMapChild returns the value node for key in mapping m, appending an
+// empty one if the key does not exist.
+func yamlMapChild(m *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i+1]
+		}
+	}
+	k := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}
+	v := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	m.Content = append(m.Content, k, v)
+	return v
+}
+
+// persistExchangeConfig writes the exchange entry into the config file via a
+// temp file and rename so readers never see a partial file.
+func persistExchangeConfig(path, name string, entry map[string]interface{}) error {
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext != ".yaml" && ext != ".yml" {
+		return fmt.Errorf("persisting is only supported for YAML config files, got %s", path)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	out, err := setYAMLExchange(data, name, entry)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(out); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(info.Mode().Perm()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// validateExchangeConfig creates a client for the entry using a scratch
+// config and performs an authenticated call (Start) to check credentials.
+func validateExchangeConfig(cfg *viper.Viper, name string, entry map[string]interface{}) error {
+	scratch := viper.New()
+	scratch.Set("proxy", cfg.GetString("proxy"))
+	scratch.Set("exchanges."+name, entry)
+	ex, err := newExchangeClient(scratch, name)
+	if err != nil {
+		return err
+	}
+	defer ex.Stop()
+	if _, err := ex.Symbols(); err != nil {
+		return fmt.Errorf("public API check failed: %s", err.Error())
+	}
+	if err := ex.Start(); err != nil {
+		return fmt.Errorf("credential check failed: %s", err.Error())
+	}
+	return nil
+}
+
+func registerAddExchange(s *server.MCPServer, cfg *viper.Viper) {
+	tool := mcp.NewTool("add_exchange",
+		mcp.WithDescription("Add or replace an exchange configuration at runtime. Credentials are validated with a test call, then the entry is applied to the running config "+
+			"(visible to list_exchanges/fetch_kline immediately) and written to the YAML config file without touching other sections. Admin only."),
+		mcp.WithString("name", mcp.Required(), mcp.Description("Exchange config name used by other tools (e.g., binance)")),
+		mcp.WithString("type", mcp.Required(), mcp.Description("Exchange type: binance, okx, ctp")),
+		mcp.WithString("kind", mcp.Description("Market kind, e.g. futures or spot (binance)")),
+		mcp.WithString("key", mcp.Description("API key")),
+		mcp.WithString("secret", mcp.Description("API secret")),
+		mcp.WithString("pwd", mcp.Description("API passphrase (okx)")),
+		mcp.WithString("timeout", mcp.Description("Request timeout, e.g. 30s")),
+		mcp.WithBoolean("isTest", mcp.Description("Use the exchange testnet. Default: false")),
+		mcp.WithBoolean("validate", mcp.Description("Validate credentials with a test call before saving. Default: true")),
+		mcp.WithBoolean("overwrite", mcp.Description("Replace an existing exchange with the same name. Default: false")),
+		mcp.WithBoolean("persist", mcp.Description("Write the entry to the config file. Default: true")),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		name := strings.TrimSpace(req.GetString("name", ""))
+		exType := strings.TrimSpace(req.GetString("type", ""))
+		if name == "" || exType == "" {
+			return toolError(codeInvalidArgument, "name and type are required"), nil
+		}
+		if strings.ContainsAny(name, ". \t") {
+			return toolError(codeInvalidArgument, "name must not contain dots or whitespace"), nil
+		}
+
+		entry := map[string]interface{}{"type": exType}
+		for _, k := range []string{"kind", "key", "secret", "pwd", "timeout"} {
+			if v := req.GetString(k, ""); v != "" {
+				entry[k] = v
+			}
+		}
+		if req.GetBool("isTest", false) {
+			entry["isTest"] = true
+		}
+
+		configMu.Lock()
+		defer configMu.Unlock()
+
+		if exchangeConfig(cfg).IsSet("exchanges."+name) && !req.GetBool("overwrite", false) {
+			return toolErrorf(codeFailedPrecondition, "exchange '%s' already exists; set overwrite=true to replace it", name), nil
+		}
+
+		if req.GetBool("validate", true) {
+			if err := validateExchangeConfig(cfg, name, entry); err != nil {
+				return toolErrorf(codeUpstream, "exchange validation failed: %s", err.Error()), nil
+			}
+		}
+
+		result := map[string]interface{}{
+			"status":    "added",
+			"name":      name,
+			"type":      exType,
+			"validated": req.GetBool("validate", true),
+			"persisted": false,
+		}
+		if req.GetBool("persist", true) {
+			configFile := cfg.ConfigFileUsed()
+			if configFile == "" {
+				result["warning"] = "no config file in use; the exchange is only kept in memory until restart"
+			} else if err := persistExchangeConfig(configFile, name, entry); err != nil {
+				return toolErrorf(codeInternal, "failed to write config file: %s", err.Error()), nil
+			} else {
+				result["persisted"] = true
+				result["configFile"] = configFile
+			}
+		}
+
+		exchangeConfigs.set(cfg, name, entry)
+		exchangeSymbols.invalidate(name)
+
+		data, _ := json.MarshalIndent(result, "", "  ")
+		return mcp.NewToolResultText(string(data)), nil
+	})
+}
@@ -0,0 +1,40 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSetYAMLExchange(t *testing.T) {
+	src := `# main config
+exchanges:
+  binance:
+    type: binance # futures account
+    kind: futures
+db:
+  type: mysql
+`
+	out, err := setYAMLExchange([]byte(src), "okx", map[string]interface{}{"type": "okx", "key": "k"})
+	if err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	got := string(out)
+	for _, want := range []string{"# main config", "type: binance # futures account", "okx:", "key: k", "db:"} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("missing %q in output:\n%s", want, got)
+		}
+	}
+
+	out, err = setYAMLExchange(out, "binance", map[string]interface{}{"type": "binance", "kind": "spot"})
+	if err != nil {
+		t.Fatalf("replace: %v", err)
+	}
+	if strings.Contains(string(out), "kind: futures") || !strings.Contains(string(out), "kind: spot") {
+		t.Fatalf("binance entry not replaced:\n%s", out)
+	}
+
+	out, err = setYAMLExchange(nil, "binance", map[string]interface{}{"type": "binance"})
+	if err != nil || !strings.Contains(string(out), "exchanges:") {
+		t.Fatalf("empty file: %v\n%s", err, out)
+	}
+}
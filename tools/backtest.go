@@ -7,48 +7,80 @@ import (
 	"time"
 
 	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 	"github.com/ztrade/ztrade-mcp/store"
-	"github.com/ztrade/ztrade/pkg/ctl"
 	"github.com/ztrade/ztrade/pkg/process/dbstore"
 	"github.com/ztrade/ztrade/pkg/report"
 )
 
-// runBacktestCore executes the actual backtest logic and returns the result map or error.
-func runBacktestCore(db *dbstore.DBStore, script, exchangeName, symbol, param string, start, end time.Time, balanceF, feeF, leverF float64) (result map[string]interface{}, err error) {
+// backtestRun is what executeBacktest returns: the report plus what the
+// gated pipeline recorded.
+type backtestRun struct {
+	Result        report.ReportResult
+	Logs          []string
+	LogsTruncated bool
+	Gate          gateStats
+	Positions     []store.PositionPoint
+	Excursions    []tradeExcursion
+	Coverage      klineCoverage
+}
+
+// executeBacktest runs script over [start, end) with the given costs and
+// gate. It is shared by run_backtest, run_backtest_managed and
+// benchmark_strategy.
+func executeBacktest(ctx context.Context, db *dbstore.DBStore, script, exchangeName, symbol, param string, start, end time.Time, balance float64, costs TradingCostModel, gate backtestGate) (run *backtestRun, err error) {
 	defer func() {
 		if r := recover(); r != nil {
 			err = fmt.Errorf("panic in backtest: %v", r)
-			result = nil
+			run = nil
 		}
 	}()
-	bt, err := ctl.NewBacktest(db, exchangeName, symbol, param, start, end)
+	coverage, err := backtestCoverage(db, exchangeName, symbol, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check kline coverage: %s", err.Error())
+	}
+	if !coverage.DataAvailable {
+		return nil, noKlineDataError(db, exchangeName, symbol, start, end)
+	}
+	if !coverage.Complete {
+		log.WithContext(ctx).WithFields(log.Fields{
+			"coveredStart": coverage.Start,
+			"coveredEnd":   coverage.End,
+		}).Warn("kline data covers only part of the backtest range")
+	}
+
+	bt, err := newBacktest(ctx, db, exchangeName, symbol, param, start, end, costs, gate)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create backtest: %s", err.Error())
 	}
 
+	if err := checkPluginToolchain(script); err != nil {
+		return nil, err
+	}
 	bt.SetScript(script)
-	bt.SetBalanceInit(balanceF, feeF)
-	bt.SetLever(leverF)
+	bt.SetBalanceInit(balance, costs.TakerFee)
+	bt.SetLever(costs.Lever)
 
 	rpt := report.NewReportSimple()
 	rpt.SetTimeRange(start, end)
-	rpt.SetFee(feeF)
-	rpt.SetLever(leverF)
+	rpt.SetFee(costs.TakerFee)
+	rpt.SetLever(costs.Lever)
 	bt.SetReporter(rpt)
 
 	err = suppressStdout(func() error {
 		return bt.Run()
 	})
 	if err != nil {
-		return nil, fmt.Errorf("backtest failed: %s", err.Error())
+		return nil, fmt.Errorf("backtest failed: %w", explainPluginError(err))
 	}
 
-	logs, logsTruncated := truncateLinesByBytes(bt.GetLog(), maxBacktestLogBytes)
-	if logsTruncated {
-		log.WithField("limitBytes", maxBacktestLogBytes).Warn("backtest logs were truncated")
+	run = &backtestRun{Coverage: coverage}
+	run.Logs, run.LogsTruncated = truncateLinesByBytes(bt.GetLog(), maxBacktestLogBytes)
+	if run.LogsTruncated {
+		log.WithContext(ctx).WithField("limitBytes", maxBacktestLogBytes).Warn("backtest logs were truncated")
 	}
 
 	rawResult, err := bt.Result()
@@ -61,12 +93,32 @@ func runBacktestCore(db *dbstore.DBStore, script, exchangeName, symbol, param st
 		return nil, fmt.Errorf("unexpected result type")
 	}
 	if fields := sanitizeBacktestMetrics(&resultData); len(fields) > 0 {
-		log.WithField("fields", fields).Warn("sanitized non-finite backtest metrics")
+		log.WithContext(ctx).WithField("fields", fields).Warn("sanitized non-finite backtest metrics")
+	}
+	run.Result = resultData
+	run.Gate = backtestGateStats(bt)
+	if run.Gate.OrdersRounded > 0 || run.Gate.OrdersRejected > 0 {
+		log.WithContext(ctx).WithFields(log.Fields{
+			"rounded":  run.Gate.OrdersRounded,
+			"rejected": run.Gate.OrdersRejected,
+		}).Info("orders adjusted to min notional / lot size")
+	}
+	run.Positions = backtestPositions(bt)
+	run.Excursions = backtestExcursions(bt)
+	return run, nil
+}
+
+// runBacktestCore executes the actual backtest logic and returns the result map or error.
+func runBacktestCore(ctx context.Context, db *dbstore.DBStore, script, exchangeName, symbol, param string, start, end time.Time, balance float64, costs TradingCostModel, gate backtestGate) (map[string]interface{}, error) {
+	run, err := executeBacktest(ctx, db, script, exchangeName, symbol, param, start, end, balance, costs, gate)
+	if err != nil {
+		return nil, err
 	}
+	resultData := run.Result
 
-	result = map[string]interface{}{
-		"logs":             logs,
-		"logsTruncated":    logsTruncated,
+	result := map[string]interface{}{
+		"logs":             run.Logs,
+		"logsTruncated":    run.LogsTruncated,
 		"param":            param,
 		"totalActions":     resultData.TotalAction,
 		"winRate":          resultData.WinRate,
@@ -88,11 +140,26 @@ func runBacktestCore(db *dbstore.DBStore, script, exchangeName, symbol, param st
 		"overallScore":     resultData.OverallScore,
 		"longTrades":       resultData.LongTrades,
 		"shortTrades":      resultData.ShortTrades,
+		"dataCoverage":     run.Coverage,
+	}
+	if gate.WarmupBars > 0 {
+		result["warmupBars"] = gate.WarmupBars
+		result["warmupDroppedOrders"] = run.Gate.WarmupDropped
+	}
+	if costs.adjustsFills() {
+		result["slippage"] = costs.Slippage
+		result["makerFee"] = costs.MakerFee
+	}
+	if costs.checksOrders() {
+		result["minNotional"] = costs.MinNotional
+		result["lotSize"] = costs.AmountStep
+		result["ordersRounded"] = run.Gate.OrdersRounded
+		result["ordersRejected"] = run.Gate.OrdersRejected
 	}
 	return result, nil
 }
 
-func registerRunBacktest(s *server.MCPServer, db *dbstore.DBStore, tm *TaskManager) {
+func registerRunBacktest(s *server.MCPServer, db *dbstore.DBStore, cfg *viper.Viper, tm *TaskManager) {
 	tool := mcp.NewTool("run_backtest",
 		mcp.WithDescription("Run a backtest with a strategy script on historical data. Returns structured results including profit, win rate, sharpe ratio, max drawdown, etc. Captures engine.Log output as 'logs' in the response. When the time range exceeds 30 days the task runs asynchronously — a task ID is returned immediately and you can poll progress with get_task_status / get_task_result."),
 		mcp.WithString("script", mcp.Required(), mcp.Description("Strategy file path (.go or .so)")),
@@ -100,15 +167,18 @@ func registerRunBacktest(s *server.MCPServer, db *dbstore.DBStore, tm *TaskManag
 		mcp.WithString("symbol", mcp.Required(), mcp.Description("Trading pair (e.g., BTCUSDT)")),
 		mcp.WithString("start", mcp.Required(), mcp.Description("Backtest start time in format '2006-01-02 15:04:05'")),
 		mcp.WithString("end", mcp.Required(), mcp.Description("Backtest end time in format '2006-01-02 15:04:05'")),
-		mcp.WithNumber("balance", mcp.Description("Initial balance. Default: 100000")),
-		mcp.WithNumber("fee", mcp.Description("Trading fee rate. Default: 0.0005")),
-		mcp.WithNumber("lever", mcp.Description("Leverage multiplier. Default: 1")),
+		mcp.WithNumber("balance", mcp.Description("Initial balance. Default: mcp.backtest.defaults for the exchange, else 100000")),
 		mcp.WithString("param", mcp.Description("Strategy parameters as JSON string, passed to strategy Param/Init parser")),
+		mcp.WithNumber("warmupBars", mcp.Description("Number of leading 1m candles fed to the strategy with order execution suppressed, so indicators can initialize. Default: 0")),
 	)
+	for _, opt := range costModelOptions() {
+		opt(&tool)
+	}
+	idempotencyKeyOption()(&tool)
 
 	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		if db == nil {
-			return mcp.NewToolResultError("database not initialized"), nil
+			return toolError(codeUnavailable, "database not initialized"), nil
 		}
 
 		script := req.GetString("script", "")
@@ -117,9 +187,16 @@ func registerRunBacktest(s *server.MCPServer, db *dbstore.DBStore, tm *TaskManag
 		startStr := req.GetString("start", "")
 		endStr := req.GetString("end", "")
 		balanceF := req.GetFloat("balance", 0)
-		feeF := req.GetFloat("fee", 0)
-		leverF := req.GetFloat("lever", 0)
 		param := req.GetString("param", "")
+		warmupBars := int(req.GetFloat("warmupBars", 0))
+		if warmupBars < 0 {
+			return toolError(codeInvalidArgument, "warmupBars must not be negative"), nil
+		}
+		defaultBalance, baseCosts := backtestDefaults(cfg, exchangeName)
+		costs, err := costModelFromRequest(req, baseCosts)
+		if err != nil {
+			return toolError(codeInvalidArgument, err.Error()), nil
+		}
 
 		// --- 自动从数据库读取策略并编译为so ---
 		var soPath string
@@ -128,81 +205,77 @@ func registerRunBacktest(s *server.MCPServer, db *dbstore.DBStore, tm *TaskManag
 		st := getStoreFromContext(ctx)
 		if st != nil && script != "" && (isLikelyID(script) || isLikelyName(script)) {
 			// 允许 script 传入策略ID或名称
-			var s *store.Script
-			var err error
-			if isLikelyID(script) {
-				id, _ := parseID(script)
-				s, err = st.GetScript(id)
-			} else {
-				s, err = st.GetScriptByName(script)
-			}
+			s, err := accessibleScriptRef(ctx, st, script, false)
 			if err != nil {
-				return mcp.NewToolResultError("strategy not found: " + err.Error()), nil
+				return toolErrorf(storeErrorCode(err), "strategy not found: %s", err.Error()), nil
 			}
 			goPath = fmt.Sprintf("/tmp/ztrade_plugins/%s_v%d.go", s.Name, s.Version)
 			soPath = fmt.Sprintf("/tmp/ztrade_plugins/%s_v%d.so", s.Name, s.Version)
 			// 写入go文件
 			if err := writeFile(goPath, s.Content); err != nil {
-				return mcp.NewToolResultError("failed to write temp go file: " + err.Error()), nil
+				return toolError(codeInternal, "failed to write temp go file: "+err.Error()), nil
 			}
 			// 编译so
-			builder := ctl.NewBuilder(goPath, soPath)
-			if err := builder.Build(); err != nil {
-				return mcp.NewToolResultError("build failed: " + err.Error()), nil
+			if err := buildPlugin(goPath, soPath, pluginBuildFlags{}); err != nil {
+				return toolError(codeBuildFailed, "build failed: "+err.Error()), nil
 			}
 			script = soPath
 		}
 
-		script, err := ensurePluginScript(script)
+		script, err = ensurePluginScript(script)
 		if err != nil {
-			return mcp.NewToolResultError(err.Error()), nil
+			return toolError(codeBuildFailed, err.Error()), nil
 		}
 
 		start, err := time.Parse("2006-01-02 15:04:05", startStr)
 		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("invalid start time: %s", err.Error())), nil
+			return toolErrorf(codeInvalidArgument, "invalid start time: %s", err.Error()), nil
 		}
 		end, err := time.Parse("2006-01-02 15:04:05", endStr)
 		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("invalid end time: %s", err.Error())), nil
+			return toolErrorf(codeInvalidArgument, "invalid end time: %s", err.Error()), nil
 		}
 
 		if balanceF <= 0 {
-			balanceF = 100000
+			balanceF = defaultBalance
 		}
-		if feeF <= 0 {
-			feeF = 0.0005
-		}
-		if leverF <= 0 {
-			leverF = 1
+		if err := loadSymbolRules(ctx, cfg, req, &costs, exchangeName, symbol); err != nil {
+			return toolErrorf(codeUpstream, "symbolRules: %s", err.Error()), nil
 		}
 
 		// If time range > threshold, run asynchronously
 		if ShouldRunAsync(start, end) {
-			taskID := tm.CreateTask("backtest", map[string]string{
+			if err := preflightPluginScript(script); err != nil {
+				return toolError(codeBuildFailed, err.Error()), nil
+			}
+			taskID, created := tm.CreateTaskOnce("backtest", map[string]string{
 				"script":   script,
 				"exchange": exchangeName,
 				"symbol":   symbol,
 				"start":    startStr,
 				"end":      endStr,
-			})
+			}, taskDedupeKey(ctx, "backtest", req))
+			if !created {
+				return dedupedTaskResult(taskID), nil
+			}
 
 			go func() {
 				tm.StartTask(taskID)
 				doneCh := tm.ProgressEstimator(taskID, "backtest", start, end)
 
-				result, err := runBacktestCore(db, script, exchangeName, symbol, param, start, end, balanceF, feeF, leverF)
+				// the task outlives the request that started it
+				result, err := runBacktestCore(context.WithoutCancel(ctx), db, script, exchangeName, symbol, param, start, end, balanceF, costs, backtestGate{WarmupBars: warmupBars})
 				close(doneCh)
 
 				if err != nil {
-					log.Errorf("async backtest task %s failed: %s", taskID, err.Error())
+					log.WithContext(ctx).Errorf("async backtest task %s failed: %s", taskID, err.Error())
 					tm.FailTask(taskID, err.Error())
 					return
 				}
 
 				data, _ := json.MarshalIndent(result, "", "  ")
 				tm.CompleteTask(taskID, string(data))
-				log.Infof("async backtest task %s completed", taskID)
+				log.WithContext(ctx).Infof("async backtest task %s completed", taskID)
 			}()
 
 			asyncResult := map[string]interface{}{
@@ -215,9 +288,9 @@ func registerRunBacktest(s *server.MCPServer, db *dbstore.DBStore, tm *TaskManag
 		}
 
 		// Synchronous execution for short time ranges
-		result, err := runBacktestCore(db, script, exchangeName, symbol, param, start, end, balanceF, feeF, leverF)
+		result, err := runBacktestCore(ctx, db, script, exchangeName, symbol, param, start, end, balanceF, costs, backtestGate{WarmupBars: warmupBars})
 		if err != nil {
-			return mcp.NewToolResultError(err.Error()), nil
+			return toolError(backtestErrorCode(err), err.Error()), nil
 		}
 
 		data, _ := json.MarshalIndent(result, "", "  ")
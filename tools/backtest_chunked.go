@@ -0,0 +1,253 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/ztrade/trademodel"
+	"github.com/ztrade/ztrade-mcp/store"
+	"github.com/ztrade/ztrade/pkg/process/dbstore"
+	"github.com/ztrade/ztrade/pkg/report"
+)
+
+// backtestChunkMonths are the chunk sizes of run_backtest_managed's chunk
+// argument, in calendar months.
+var backtestChunkMonths = map[string]int{"month": 1, "quarter": 3}
+
+// splitTimeRange splits [start, end) at the first of every months-th
+// calendar month, counted from January, so quarters start in January,
+// April, July and October.
+func splitTimeRange(start, end time.Time, months int) []timeRange {
+	var ret []timeRange
+	y, m, _ := start.Date()
+	m -= (m - 1) % time.Month(months)
+	cursor := start
+	for cursor.Before(end) {
+		m += time.Month(months)
+		next := time.Date(y, m, 1, 0, 0, 0, 0, start.Location())
+		if next.After(end) {
+			next = end
+		}
+		ret = append(ret, timeRange{Start: cursor, End: next})
+		cursor = next
+	}
+	return ret
+}
+
+// chunkedBacktest runs a managed backtest as a sequence of sub-backtests,
+// one per chunk, each saved as its own backtest record under runLabel as
+// soon as it finishes and marked as a chunk, so only the combined record
+// counts in summaries and rankings. The balance is carried forward: a chunk
+// starts with the end balance of the one before. The trades of all chunks
+// are replayed into one report for the combined record.
+//
+// Every chunk starts flat with fresh strategy state, so a position still
+// open at a chunk boundary is dropped like at the end of any backtest, and
+// indicators warm up again (see warmupBars). Its unclosed trailing entries
+// are left out of the replay (see closedTrades).
+type chunkedBacktest struct {
+	strategyID   int64
+	version      int
+	plugin       string
+	exchange     string
+	symbol       string
+	param        string
+	start, end   time.Time
+	balance      float64
+	costs        TradingCostModel
+	gate         backtestGate
+	months       int
+	runLabel     string
+	riskFreeRate float64
+	recompute    bool // recompute the Sharpe and Sortino ratios with riskFreeRate
+	fundingRates []store.FundingRate
+	manifest     runManifest
+	// progress, when set, is called after every chunk.
+	progress func(progress string, percent int)
+}
+
+// chunkKey identifies the record of a chunk when resuming: everything the
+// result depends on, the strategy version, market, param, range, cost model
+// and order gate, so a rerun with other settings recomputes the chunk.
+func chunkKey(r *store.BacktestRecord) string {
+	return fmt.Sprintf("%t/%d/%d/%s/%s/%s/%d/%d/%s/%d/%t/%d/%s", r.Chunk, r.ScriptID, r.ScriptVersion, r.Exchange, r.Symbol, r.Param,
+		r.StartTime.Unix(), r.EndTime.Unix(), r.CostModel, r.WarmupBars, r.LimitOrderModel, r.LimitOrderExpiry, r.FillOn)
+}
+
+// rangeKey is the chunkKey of the chunk record, or with chunk false the
+// combined record, this run saves for r.
+func (c *chunkedBacktest) rangeKey(r timeRange, chunk bool) string {
+	record := newBacktestRecord(c.strategyID, c.version, c.exchange, c.symbol, c.param, r.Start, r.End, c.balance, c.costs, c.gate, report.ReportResult{})
+	record.CostModel, record.Chunk = c.manifest.CostModel, chunk
+	return chunkKey(record)
+}
+
+// closedTrades returns trades up to the last one that leaves the position
+// flat. Chunks end with their position dropped, so the trailing entries
+// would otherwise be paired with the next chunk's trades when the chunks
+// are replayed into one report.
+func closedTrades(trades []trademodel.Trade) []trademodel.Trade {
+	pos, flat := 0.0, 0
+	for i, t := range trades {
+		pos += positionDelta(t.Action, t.Amount)
+		if math.Abs(pos) <= positionTolerance {
+			pos, flat = 0, i+1
+		}
+	}
+	return trades[:flat]
+}
+
+// savedChunks returns the records already saved under runLabel by an
+// earlier attempt, by chunkKey.
+func (c *chunkedBacktest) savedChunks(st *store.Store) (map[string]store.BacktestRecord, error) {
+	records, _, err := st.GetExperiment(c.runLabel, "")
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	ret := make(map[string]store.BacktestRecord, len(records))
+	for _, r := range records {
+		ret[chunkKey(&r)] = r
+	}
+	return ret, nil
+}
+
+// chunkRecord fills in what run_backtest_managed adds to a record beyond
+// newBacktestRecord and returns the record's equity curve.
+func (c *chunkedBacktest) chunkRecord(r timeRange, balance float64, res report.ReportResult) (*store.BacktestRecord, []store.EquityPoint) {
+	record := newBacktestRecord(c.strategyID, c.version, c.exchange, c.symbol, c.param, r.Start, r.End, balance, c.costs, c.gate, res)
+	equity := buildEquityCurve(r.Start, balance, res.Actions)
+	record.UlcerIndex, record.MartinRatio = ulcerMetrics(equity, res.AnnualReturn)
+	record.RiskFreeRate = c.riskFreeRate
+	record.RunLabel = c.runLabel
+	if c.recompute {
+		record.SharpeRatio, record.SortinoRatio = excessReturnRatios(equity, r.Start, r.End, c.riskFreeRate)
+	}
+	return record, equity
+}
+
+// run executes the chunks not saved yet and saves the combined record. A
+// failing chunk returns an error; the chunks before it stay saved and a
+// rerun with the same runLabel resumes after them.
+func (c *chunkedBacktest) run(ctx context.Context, db *dbstore.DBStore, st *store.Store) (map[string]interface{}, error) {
+	ranges := splitTimeRange(c.start, c.end, c.months)
+	saved, err := c.savedChunks(st)
+	if err != nil {
+		return nil, fmt.Errorf("load saved chunks: %w", err)
+	}
+	if rec, ok := saved[c.rangeKey(timeRange{Start: c.start, End: c.end}, false)]; ok {
+		return map[string]interface{}{
+			"recordId": rec.ID, "strategyId": c.strategyID, "strategyVersion": c.version, "runLabel": c.runLabel,
+			"alreadyComplete": true, "totalReturn": rec.TotalReturn, "endBalance": rec.EndBalance,
+		}, nil
+	}
+
+	balance := c.balance
+	var trades []trademodel.Trade
+	var positions []store.PositionPoint
+	chunks := make([]map[string]interface{}, 0, len(ranges))
+	resumed := 0
+	for i, r := range ranges {
+		var record *store.BacktestRecord
+		var chunkTrades []trademodel.Trade
+		if rec, ok := saved[c.rangeKey(r, true)]; ok && math.Abs(rec.InitBalance-balance) <= 1e-6*math.Max(1, balance) {
+			stored, err := st.ListBacktestTrades(rec.ID)
+			if err != nil {
+				return nil, fmt.Errorf("load trades of chunk record %d: %w", rec.ID, err)
+			}
+			for _, t := range stored {
+				action, err := trademodel.NewTradeType(t.Action)
+				if err != nil {
+					return nil, fmt.Errorf("chunk record %d: %w", rec.ID, err)
+				}
+				chunkTrades = append(chunkTrades, trademodel.Trade{Action: action, Time: t.Time, Price: t.Price, Amount: t.Amount})
+			}
+			points, err := st.ListBacktestPositions(rec.ID)
+			if err != nil {
+				return nil, fmt.Errorf("load positions of chunk record %d: %w", rec.ID, err)
+			}
+			positions = append(positions, points...)
+			record = &rec
+			resumed++
+		} else {
+			run, err := executeBacktest(ctx, db, c.plugin, c.exchange, c.symbol, c.param, r.Start, r.End, balance, c.costs, c.gate)
+			if err != nil {
+				return nil, fmt.Errorf("chunk %d/%d [%s, %s): %w; the %d chunks before it are saved under runLabel %q, rerun with the same arguments to resume",
+					i+1, len(ranges), r.Start.Format("2006-01-02 15:04:05"), r.End.Format("2006-01-02 15:04:05"), err, i, c.runLabel)
+			}
+			var equity []store.EquityPoint
+			record, equity = c.chunkRecord(r, balance, run.Result)
+			record.Chunk = true
+			tagMarketRegime(ctx, db, record)
+			c.manifest.stamp(ctx, db, record)
+			saveBacktestRun(ctx, st, record, run, equity)
+			for _, act := range run.Result.Actions {
+				chunkTrades = append(chunkTrades, act.Trade)
+			}
+			positions = append(positions, run.Positions...)
+		}
+		trades = append(trades, closedTrades(chunkTrades)...)
+		chunks = append(chunks, map[string]interface{}{
+			"recordId":     record.ID,
+			"start":        r.Start.Format("2006-01-02 15:04:05"),
+			"end":          r.End.Format("2006-01-02 15:04:05"),
+			"startBalance": balance,
+			"endBalance":   record.EndBalance,
+			"totalActions": record.TotalActions,
+			"totalReturn":  record.TotalReturn,
+			"regime":       record.Regime,
+		})
+		// a chunk without closed trades reports no end balance
+		if record.TotalActions > 0 && record.EndBalance > 0 {
+			balance = record.EndBalance
+		}
+		log.WithContext(ctx).WithFields(log.Fields{"chunk": i + 1, "chunks": len(ranges), "recordId": record.ID}).Info("backtest chunk done")
+		if c.progress != nil {
+			c.progress(fmt.Sprintf("chunk %d/%d done", i+1, len(ranges)), (i+1)*95/len(ranges))
+		}
+	}
+
+	rep := report.NewReport(trades, c.balance)
+	rep.SetTimeRange(c.start, c.end)
+	rep.SetFee(c.costs.TakerFee)
+	rep.SetLever(c.costs.Lever)
+	res, err := rep.GetResult()
+	if err != nil {
+		return nil, fmt.Errorf("combine chunks: %w", err)
+	}
+	if fields := sanitizeBacktestMetrics(&res); len(fields) > 0 {
+		log.WithContext(ctx).WithField("fields", fields).Warn("sanitized non-finite backtest metrics")
+	}
+	record, equity := c.chunkRecord(timeRange{Start: c.start, End: c.end}, c.balance, res)
+	if c.costs.IncludeFunding {
+		record.TotalFunding, _ = calcFundingCost(trades, c.fundingRates)
+	}
+	tagMarketRegime(ctx, db, record)
+	c.manifest.stamp(ctx, db, record)
+	saveBacktestRun(ctx, st, record, &backtestRun{Result: res, Positions: positions}, equity)
+
+	result := map[string]interface{}{
+		"recordId": record.ID, "strategyId": c.strategyID, "strategyVersion": c.version, "param": c.param,
+		"exchange": c.exchange, "symbol": c.symbol, "runLabel": c.runLabel,
+		"chunks": chunks, "resumedChunks": resumed, "regime": record.Regime,
+		"totalActions": res.TotalAction, "winRate": res.WinRate,
+		"totalProfit": res.TotalProfit, "maxDrawdown": res.MaxDrawdown,
+		"startBalance": c.balance, "endBalance": res.EndBalance,
+		"totalReturn": res.TotalReturn, "annualReturn": res.AnnualReturn,
+		"sharpeRatio": record.SharpeRatio, "sortinoRatio": record.SortinoRatio, "riskFreeRate": c.riskFreeRate,
+		"profitFactor": res.ProfitFactor, "calmarRatio": res.CalmarRatio, "overallScore": res.OverallScore,
+		"ulcerIndex": record.UlcerIndex, "martinRatio": record.MartinRatio,
+		"fillOn": record.FillOn,
+		"note":   "each chunk starts flat with fresh strategy state; positions open at a chunk boundary are dropped and their entries left out of the combined record",
+	}
+	if c.costs.IncludeFunding {
+		result["totalFunding"] = record.TotalFunding
+	}
+	return result, nil
+}
@@ -0,0 +1,83 @@
+package tools
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ztrade/trademodel"
+	"github.com/ztrade/ztrade/pkg/report"
+)
+
+func TestSplitTimeRange(t *testing.T) {
+	d := func(y int, m time.Month, day int) time.Time { return time.Date(y, m, day, 0, 0, 0, 0, time.UTC) }
+	ranges := splitTimeRange(d(2023, 2, 15), d(2023, 8, 10), 3)
+	want := []timeRange{
+		{d(2023, 2, 15), d(2023, 4, 1)},
+		{d(2023, 4, 1), d(2023, 7, 1)},
+		{d(2023, 7, 1), d(2023, 8, 10)},
+	}
+	if len(ranges) != len(want) {
+		t.Fatalf("quarters: %v", ranges)
+	}
+	for i := range want {
+		if !ranges[i].Start.Equal(want[i].Start) || !ranges[i].End.Equal(want[i].End) {
+			t.Fatalf("quarter %d = %v, want %v", i, ranges[i], want[i])
+		}
+	}
+
+	ranges = splitTimeRange(d(2023, 11, 1), d(2024, 2, 1), 1)
+	if len(ranges) != 3 || !ranges[1].Start.Equal(d(2023, 12, 1)) || !ranges[2].Start.Equal(d(2024, 1, 1)) || !ranges[2].End.Equal(d(2024, 2, 1)) {
+		t.Fatalf("months across a year end: %v", ranges)
+	}
+	if ranges := splitTimeRange(d(2023, 1, 5), d(2023, 1, 20), 1); len(ranges) != 1 {
+		t.Fatalf("range within one month: %v", ranges)
+	}
+}
+
+func TestClosedTrades(t *testing.T) {
+	trades := []trademodel.Trade{
+		{Action: trademodel.OpenLong, Amount: 2},
+		{Action: trademodel.CloseLong, Amount: 1},
+		{Action: trademodel.CloseLong, Amount: 1},
+		{Action: trademodel.OpenShort, Amount: 1},
+		{Action: trademodel.CloseShort, Amount: 1},
+		{Action: trademodel.OpenLong, Amount: 1},
+	}
+	if got := closedTrades(trades); len(got) != 5 {
+		t.Fatalf("kept %d trades, want the 5 up to the last flat position", len(got))
+	}
+	if got := closedTrades(trades[:1]); len(got) != 0 {
+		t.Fatalf("an unclosed entry was kept: %v", got)
+	}
+}
+
+func TestChunkKeyCoversSettings(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	r := timeRange{Start: start, End: start.AddDate(0, 1, 0)}
+	base := TradingCostModel{TakerFee: 0.0005, Lever: 1}
+	c := &chunkedBacktest{strategyID: 1, version: 2, exchange: "binance", symbol: "BTCUSDT", balance: 1000, costs: base}
+	c.manifest.CostModel = costModelManifest(base)
+	key := c.rangeKey(r, true)
+
+	record := newBacktestRecord(1, 2, "binance", "BTCUSDT", "", r.Start, r.End, 1000, base, backtestGate{}, report.ReportResult{})
+	record.CostModel, record.Chunk = costModelManifest(base), true
+	if chunkKey(record) != key {
+		t.Fatal("a saved chunk record does not match its range key")
+	}
+	if c.rangeKey(r, false) == key {
+		t.Fatal("a combined record matches a chunk range key")
+	}
+
+	slipped := base
+	slipped.Slippage = 0.001
+	other := *c
+	other.costs, other.manifest.CostModel = slipped, costModelManifest(slipped)
+	if other.rangeKey(r, true) == key {
+		t.Fatal("slippage not part of the chunk key")
+	}
+	other = *c
+	other.gate = backtestGate{LimitOrders: true}
+	if other.rangeKey(r, true) == key {
+		t.Fatal("gate settings not part of the chunk key")
+	}
+}
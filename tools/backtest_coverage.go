@@ -0,0 +1,76 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ztrade/trademodel"
+	"github.com/ztrade/ztrade/pkg/process/dbstore"
+)
+
+// errNoKlineData is returned by executeBacktest when the local database has
+// no 1m candles in the backtest range. Without the check such a run
+// completes with zero trades and all-zero metrics, which reads like a flat
+// strategy rather than missing data.
+var errNoKlineData = errors.New("no kline data")
+
+// klineCoverage is the part of a backtest range the local 1m candles cover.
+// It is taken from the first candle in the range and the newest stored
+// candle; holes inside it are reported by data_quality_report.
+type klineCoverage struct {
+	DataAvailable bool   `json:"dataAvailable"`
+	Complete      bool   `json:"complete"` // the covered range spans the whole requested range
+	Start         string `json:"coveredStart,omitempty"`
+	End           string `json:"coveredEnd,omitempty"`
+}
+
+// backtestCoverage returns the coverage of [start, end) by the stored 1m
+// candles of exchange/symbol.
+func backtestCoverage(db *dbstore.DBStore, exchangeName, symbol string, start, end time.Time) (klineCoverage, error) {
+	tbl := db.GetKlineTbl(exchangeName, symbol, "1m")
+	datas, err := tbl.GetDatas(start, end, 1)
+	if err != nil {
+		return klineCoverage{}, err
+	}
+	if len(datas) == 0 {
+		return klineCoverage{}, nil
+	}
+	first, ok := datas[0].(*trademodel.Candle)
+	if !ok {
+		return klineCoverage{}, fmt.Errorf("unexpected kline type %T", datas[0])
+	}
+	covStart, covEnd := time.Unix(first.Start, 0), end
+	if last := tbl.GetNewest().Add(time.Minute); last.Before(end) {
+		covEnd = last
+	}
+	return klineCoverage{
+		DataAvailable: true,
+		Complete:      covStart.Sub(start) < time.Minute && !covEnd.Before(end),
+		Start:         covStart.Format("2006-01-02 15:04:05"),
+		End:           covEnd.Format("2006-01-02 15:04:05"),
+	}, nil
+}
+
+// noKlineDataError describes a backtest range without candles, with the
+// range the database does hold so the caller knows what to download.
+func noKlineDataError(db *dbstore.DBStore, exchangeName, symbol string, start, end time.Time) error {
+	tbl := db.GetKlineTbl(exchangeName, symbol, "1m")
+	if tbl.IsEmpty() {
+		return fmt.Errorf("%w for %s %s 1m: the local database has none; download it with download_kline", errNoKlineData, exchangeName, symbol)
+	}
+	return fmt.Errorf("%w for %s %s 1m in [%s, %s): stored data covers %s to %s; download the range with download_kline",
+		errNoKlineData, exchangeName, symbol, start.Format("2006-01-02 15:04:05"), end.Format("2006-01-02 15:04:05"),
+		tbl.GetOldest().Format("2006-01-02 15:04:05"), tbl.GetNewest().Format("2006-01-02 15:04:05"))
+}
+
+// backtestErrorCode is the code for an error of a backtest run.
+func backtestErrorCode(err error) string {
+	if errors.Is(err, errNoKlineData) {
+		return codeFailedPrecondition
+	}
+	if errors.Is(err, errPluginMismatch) {
+		return codeBuildFailed
+	}
+	return codeInternal
+}
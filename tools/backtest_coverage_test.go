@@ -0,0 +1,49 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ztrade/trademodel"
+	"github.com/ztrade/ztrade/pkg/process/dbstore"
+)
+
+func TestBacktestCoverage(t *testing.T) {
+	db, err := dbstore.NewDBStore("sqlite", filepath.Join(t.TempDir(), "kline.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var candles []*trademodel.Candle
+	for i := 0; i < 60; i++ {
+		candles = append(candles, &trademodel.Candle{Start: base.Add(time.Duration(i) * time.Minute).Unix(), Open: 1, High: 1, Low: 1, Close: 1})
+	}
+	if _, err := saveFetchedKlines(db, "binance", "BTCUSDT", "1m", candles); err != nil {
+		t.Fatal(err)
+	}
+
+	cov, err := backtestCoverage(db, "binance", "BTCUSDT", base, base.Add(time.Hour))
+	if err != nil || !cov.DataAvailable || !cov.Complete {
+		t.Fatalf("full range: %+v, %v", cov, err)
+	}
+	cov, err = backtestCoverage(db, "binance", "BTCUSDT", base.Add(-time.Hour), base.Add(2*time.Hour))
+	if err != nil || !cov.DataAvailable || cov.Complete {
+		t.Fatalf("partial range: %+v, %v", cov, err)
+	}
+	if want := base.Add(time.Hour).Local().Format("2006-01-02 15:04:05"); cov.End != want {
+		t.Fatalf("coveredEnd = %s, want %s", cov.End, want)
+	}
+	cov, err = backtestCoverage(db, "binance", "BTCUSDT", base.Add(24*time.Hour), base.Add(25*time.Hour))
+	if err != nil || cov.DataAvailable {
+		t.Fatalf("empty range: %+v, %v", cov, err)
+	}
+
+	// a range without candles fails before the engine runs
+	_, err = executeBacktest(context.Background(), db, "missing.so", "binance", "BTCUSDT", "", base.Add(24*time.Hour), base.Add(25*time.Hour), 1000, TradingCostModel{}, backtestGate{})
+	if !errors.Is(err, errNoKlineData) || backtestErrorCode(err) != codeFailedPrecondition {
+		t.Fatalf("executeBacktest error = %v", err)
+	}
+}
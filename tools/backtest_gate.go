@@ -0,0 +1,530 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"path/filepath"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/ztrade/base/common"
+	"github.com/ztrade/trademodel"
+	"github.com/ztrade/ztrade-mcp/store"
+	"github.com/ztrade/ztrade/pkg/core"
+	"github.com/ztrade/ztrade/pkg/ctl"
+	"github.com/ztrade/ztrade/pkg/event"
+	"github.com/ztrade/ztrade/pkg/process/dbstore"
+	"github.com/ztrade/ztrade/pkg/process/goscript"
+	"github.com/ztrade/ztrade/pkg/process/rpt"
+	"github.com/ztrade/ztrade/pkg/process/vex"
+)
+
+// backtestRunner is the part of ctl.Backtest used by the backtest tools.
+type backtestRunner interface {
+	SetScript(scriptFile string)
+	SetBalanceInit(balanceInit, fee float64)
+	SetLever(lever float64)
+	SetReporter(rpt rpt.Reporter)
+	Run() error
+	GetLog() []string
+	Result() (any, error)
+}
+
+// backtestGate changes how strategy orders reach the virtual exchange and
+// what is recorded of its responses.
+type backtestGate struct {
+	// WarmupBars drops orders sent during the first N 1m candles, while
+	// the strategy still sees them so indicators initialize.
+	WarmupBars int
+	// LimitOrders enables the limit order model: an order priced away from
+	// the market rests until a candle trades through its price, an order
+	// at or through the market fills at the next candle's open. Without it
+	// the virtual exchange fills any order whose price the candle touches.
+	LimitOrders bool
+	// LimitExpiryBars cancels resting limit orders after N 1m candles;
+	// 0 keeps them until filled or canceled.
+	LimitExpiryBars int
+	// NextOpenFills fills orders at or through the market at the next 1m
+	// candle's open, as the limit order model does. Without it they reach
+	// the exchange at their own price, usually the close of the candle the
+	// strategy decided on, and fill as soon as a candle touches it.
+	NextOpenFills bool
+	// RecordPositions keeps the position after every change the virtual
+	// exchange reports and the excursion of every trade; see
+	// backtestPositions and backtestExcursions.
+	RecordPositions bool
+}
+
+func (c backtestGate) enabled() bool {
+	return c.WarmupBars > 0 || c.LimitOrders || c.NextOpenFills || c.RecordPositions
+}
+
+// Fill price sources of run_backtest_managed's fillOn argument.
+const (
+	fillOnClose    = "close"
+	fillOnNextOpen = "nextOpen"
+)
+
+// fillOn returns when orders at or through the market fill under c.
+func (c backtestGate) fillOn() string {
+	if c.LimitOrders || c.NextOpenFills {
+		return fillOnNextOpen
+	}
+	return fillOnClose
+}
+
+// gateStats counts what an orderGate did during a backtest.
+type gateStats struct {
+	WarmupDropped  int `json:"warmupDroppedOrders"`
+	LimitExpired   int `json:"limitOrdersExpired"`
+	OrdersRounded  int `json:"ordersRounded"`
+	OrdersRejected int `json:"ordersRejected"`
+}
+
+// newBacktest returns a ctl.Backtest, or a gatedBacktest when gate or the
+// cost model changes anything or ctx can be cancelled: ctl.Backtest runs to
+// the end once started.
+func newBacktest(ctx context.Context, db *dbstore.DBStore, exchangeName, symbol, param string, start, end time.Time, costs TradingCostModel, gate backtestGate) (backtestRunner, error) {
+	if ctx.Done() == nil && !gate.enabled() && !costs.adjustsFills() {
+		return ctl.NewBacktest(db, exchangeName, symbol, param, start, end)
+	}
+	return &gatedBacktest{
+		ctx: ctx, db: db, exchange: exchangeName, symbol: symbol, param: param,
+		start: start, end: end, gateCfg: gate, costs: costs, balanceInit: 100000,
+	}, nil
+}
+
+// backtestGateStats returns the gate counters of bt, zero for ctl.Backtest.
+func backtestGateStats(bt backtestRunner) gateStats {
+	if g, ok := bt.(*gatedBacktest); ok && g.gate != nil {
+		return g.gate.stats
+	}
+	return gateStats{}
+}
+
+// backtestPositions returns the recorded position history of bt, nil unless
+// it ran with RecordPositions.
+func backtestPositions(bt backtestRunner) []store.PositionPoint {
+	if g, ok := bt.(*gatedBacktest); ok && g.gate != nil {
+		return g.gate.positions
+	}
+	return nil
+}
+
+// backtestExcursions returns the excursion of every trade of bt, in trade
+// order, nil unless it ran with RecordPositions.
+func backtestExcursions(bt backtestRunner) []tradeExcursion {
+	if g, ok := bt.(*gatedBacktest); ok && g.gate != nil {
+		return g.gate.excursions
+	}
+	return nil
+}
+
+// gatedBacktest runs the same pipeline as ctl.Backtest.Run with the virtual
+// exchange behind an orderGate.
+type gatedBacktest struct {
+	ctx         context.Context
+	db          *dbstore.DBStore
+	exchange    string
+	symbol      string
+	param       string
+	start, end  time.Time
+	gateCfg     backtestGate
+	costs       TradingCostModel
+	script      string
+	balanceInit float64
+	fee         float64
+	lever       float64
+	rpt         rpt.Reporter
+	engine      *goscript.GoEngine
+	gate        *orderGate
+}
+
+func (b *gatedBacktest) SetScript(scriptFile string) { b.script = scriptFile }
+func (b *gatedBacktest) SetBalanceInit(balanceInit, fee float64) {
+	b.balanceInit, b.fee = balanceInit, fee
+}
+func (b *gatedBacktest) SetLever(lever float64)     { b.lever = lever }
+func (b *gatedBacktest) SetReporter(r rpt.Reporter) { b.rpt = r }
+
+func (b *gatedBacktest) GetLog() []string {
+	if b.engine == nil {
+		return nil
+	}
+	return b.engine.GetLog()
+}
+
+func (b *gatedBacktest) Result() (any, error) {
+	if b.rpt == nil {
+		return nil, errors.New("no reporter set")
+	}
+	provider, ok := b.rpt.(rpt.ResultProvider)
+	if !ok {
+		return nil, errors.New("reporter does not implement ResultProvider")
+	}
+	return provider.ProvideResult()
+}
+
+func (b *gatedBacktest) Run() (err error) {
+	const binSize = "1m"
+	param := event.NewBaseProcesser("param")
+
+	tbl := b.db.NewKlineTbl(b.exchange, b.symbol, binSize)
+	tbl.SetLoadOnce(50000)
+	// cancelled when the run stops, so the source stops replaying too
+	ctx, cancel := context.WithCancel(b.ctx)
+	defer cancel()
+	src := &candleSource{BaseProcesser: *event.NewBaseProcesser("candles"), ctx: ctx, tbl: tbl, done: make(chan error, 1)}
+
+	gEngine, err := goscript.NewGoEngine(b.symbol)
+	if err != nil {
+		return err
+	}
+	b.engine = gEngine
+	if err = gEngine.AddScript(filepath.Base(b.script), b.script, b.param); err != nil {
+		return err
+	}
+	b.gate = newOrderGate(vex.NewVExchange(b.symbol), b.gateCfg)
+	b.gate.costs = b.costs
+
+	processers := event.NewSyncProcessers()
+	processers.Add(param)
+	processers.Add(src)
+	processers.Add(b.gate)
+	processers.Add(gEngine)
+	processers.Add(rpt.NewRpt(b.rpt))
+
+	var stopOnce sync.Once
+	// buffered: the callback runs on the bus and must not wait for Run
+	errorCh := make(chan bool, 1)
+	processers.SetErrorCallback(func(err error) {
+		if errors.Is(err, common.ErrNoBalance) {
+			stopOnce.Do(func() {
+				log.Errorf("got error: %s, just exit", err.Error())
+				cancel()
+				processers.Stop()
+				errorCh <- true
+			})
+		}
+	})
+	if err = processers.Start(); err != nil {
+		return err
+	}
+
+	param.Send("balance_init", core.EventBalanceInit, &core.BalanceInfo{Balance: b.balanceInit, Fee: b.fee})
+	param.Send("risk_init", core.EventRiskLimit, &core.RiskLimit{Lever: b.lever})
+	go src.emit(b.start, b.end, binSize)
+	select {
+	case err = <-src.done:
+	case <-errorCh:
+	}
+	if len(errorCh) > 0 {
+		// stopped for lack of balance; the source only saw the cancel
+		err = nil
+	}
+	processers.WaitClose(time.Second * 10)
+	return err
+}
+
+// candleSource replays stored candles like the dbstore.KlineTbl of
+// ctl.Backtest, but stops before the next candle once ctx is done.
+type candleSource struct {
+	event.BaseProcesser
+	ctx  context.Context
+	tbl  *dbstore.KlineTbl
+	done chan error
+}
+
+func (c *candleSource) emit(start, end time.Time, binSize string) {
+	candles, err := c.tbl.DataChan(start, end, binSize)
+	if err != nil {
+		c.done <- err
+		return
+	}
+	for batch := range candles {
+		for _, v := range batch {
+			if err := c.ctx.Err(); err != nil {
+				c.done <- fmt.Errorf("cancelled: %w", err)
+				return
+			}
+			c.Bus.WaitEmpty(time.Minute)
+			c.SendWithExtra("candle", core.EventCandle, v.(*trademodel.Candle), binSize)
+		}
+	}
+	c.done <- nil
+}
+
+// restingOrder is an order held back by the limit order model.
+type restingOrder struct {
+	act        trademodel.TradeAction
+	marketable bool // priced at or through the market when sent
+	age        int  // 1m candles seen since it was sent
+}
+
+// orderGate wraps the virtual exchange with a bus of its own. Events the
+// exchange consumes are copied onto that bus, orders only once the gate
+// lets them through and at the price the cost model gives them; what the
+// exchange emits is copied back.
+type orderGate struct {
+	event.BaseProcesser
+	ex        *vex.VExchange
+	inner     *event.Bus
+	cfg       backtestGate
+	costs     TradingCostModel
+	seen      int
+	lastClose float64
+	resting   []*restingOrder
+	stats     gateStats
+
+	// position tracking for RecordPositions
+	hold      float64
+	avgPrice  float64
+	lastTrade time.Time
+	positions []store.PositionPoint
+
+	// price range seen since the position was opened
+	low, high  float64
+	excursions []tradeExcursion
+}
+
+// tradeExcursion is how far the position went against (MAE) and in favor
+// of (MFE) its average entry price before a trade reduced it, in percent of
+// that price. Both are zero for trades that open or add to a position.
+type tradeExcursion struct {
+	MAE float64
+	MFE float64
+}
+
+func newOrderGate(ex *vex.VExchange, cfg backtestGate) *orderGate {
+	g := &orderGate{ex: ex, inner: event.NewSyncBus(), cfg: cfg}
+	g.Name = "OrderGate"
+	return g
+}
+
+func (g *orderGate) Init(bus *event.Bus) error {
+	g.BaseProcesser.Init(bus)
+	if err := g.ex.Init(g.inner); err != nil {
+		return err
+	}
+	g.Subscribe(core.EventCandle, g.onCandle)
+	g.Subscribe(core.EventOrder, g.onOrder)
+	g.Subscribe(core.EventBalanceInit, g.forwardIn)
+	g.Subscribe(core.EventRiskLimit, g.forwardIn)
+	for _, typ := range []string{core.EventTrade, core.EventPosition, core.EventBalance} {
+		g.inner.Subscribe(g.Name, typ, g.forwardOut)
+	}
+	g.inner.Subscribe(g.Name, core.EventError, g.forwardError)
+	return nil
+}
+
+func (g *orderGate) Start() error { return g.ex.Start() }
+func (g *orderGate) Stop() error  { return g.ex.Stop() }
+
+func (g *orderGate) onCandle(e *event.Event) error {
+	candle, ok := e.GetData().(*trademodel.Candle)
+	if binSize, _ := e.GetExtra().(string); !ok || binSize != "1m" {
+		return g.forwardIn(e)
+	}
+	g.seen++
+	// orders released now are matched by the exchange against this candle
+	g.releaseResting(candle)
+	g.lastClose = candle.Close
+	if err := g.forwardIn(e); err != nil {
+		return err
+	}
+	// after the exchange matched this candle, so a position opened on it
+	// sees its whole range and one closed on it none of it
+	if g.hold != 0 {
+		g.low = math.Min(g.low, candle.Low)
+		g.high = math.Max(g.high, candle.High)
+	}
+	return nil
+}
+
+// releaseResting sends the resting orders that fill on candle to the
+// exchange and drops the expired ones.
+func (g *orderGate) releaseResting(candle *trademodel.Candle) {
+	kept := g.resting[:0]
+	for _, o := range g.resting {
+		o.age++
+		act := o.act
+		switch {
+		case o.marketable:
+			act.Price = g.costs.takerPrice(candle.Open, act.Action.IsLong())
+		case act.Action.IsLong() && candle.Low < act.Price,
+			!act.Action.IsLong() && candle.High > act.Price:
+			act.Price = g.costs.makerPrice(act.Price, act.Action.IsLong(), candle.Low, candle.High)
+		default:
+			if g.cfg.LimitExpiryBars > 0 && o.age >= g.cfg.LimitExpiryBars {
+				g.stats.LimitExpired++
+			} else {
+				kept = append(kept, o)
+			}
+			continue
+		}
+		g.sendOrder(&act)
+	}
+	g.resting = kept
+}
+
+func (g *orderGate) onOrder(e *event.Event) error {
+	if g.seen <= g.cfg.WarmupBars {
+		g.stats.WarmupDropped++
+		return nil
+	}
+	act, ok := e.GetData().(*trademodel.TradeAction)
+	if !ok || act == nil {
+		return g.forwardIn(e)
+	}
+	switch act.Action {
+	case trademodel.CancelAll:
+		g.resting = nil
+		return g.forwardIn(e)
+	case trademodel.CancelOne:
+		for i, o := range g.resting {
+			if o.act.ID == act.ID {
+				g.resting = append(g.resting[:i], g.resting[i+1:]...)
+				return nil
+			}
+		}
+		return g.forwardIn(e)
+	}
+	order := *act
+	if g.costs.checksOrders() {
+		price := order.Price
+		if price <= 0 {
+			price = g.lastClose
+		}
+		amount, ok := g.costs.fitAmount(order.Amount, price, order.Action.IsOpen())
+		if !ok {
+			g.stats.OrdersRejected++
+			return nil
+		}
+		if amount != order.Amount {
+			g.stats.OrdersRounded++
+			order.Amount = amount
+		}
+	}
+	if order.Action.IsStop() {
+		return g.sendChanged(e, act, &order)
+	}
+	// an order without a price is a market order, like in the cost check
+	marketable := order.Price >= g.lastClose
+	if !order.Action.IsLong() {
+		marketable = order.Price <= g.lastClose
+	}
+	marketable = marketable || order.Price <= 0 || g.lastClose == 0
+	if !g.cfg.LimitOrders {
+		if marketable && g.cfg.NextOpenFills {
+			g.resting = append(g.resting, &restingOrder{act: order, marketable: true})
+			return nil
+		}
+		if marketable {
+			price := order.Price
+			if price <= 0 {
+				price = g.lastClose
+			}
+			order.Price = g.costs.takerPrice(price, order.Action.IsLong())
+		}
+		return g.sendChanged(e, act, &order)
+	}
+	g.resting = append(g.resting, &restingOrder{act: order, marketable: marketable})
+	return nil
+}
+
+// sendChanged forwards e unless the gate changed its order.
+func (g *orderGate) sendChanged(e *event.Event, orig, order *trademodel.TradeAction) error {
+	if *order == *orig {
+		return g.forwardIn(e)
+	}
+	g.sendOrder(order)
+	return nil
+}
+
+func (g *orderGate) sendOrder(act *trademodel.TradeAction) {
+	g.inner.Send(event.NewEvent("order", core.EventOrder, g.Name, act, nil))
+}
+
+// Events are pooled and released after dispatch, so each bus gets a copy.
+func (g *orderGate) forwardIn(e *event.Event) error {
+	return g.inner.Send(event.NewEvent(e.Name, e.GetType(), e.From, e.GetData(), e.GetExtra()))
+}
+
+func (g *orderGate) forwardOut(e *event.Event) error {
+	if g.cfg.RecordPositions {
+		switch data := e.GetData().(type) {
+		case *trademodel.Trade:
+			g.trackTrade(data)
+		case *trademodel.Position:
+			g.recordPosition(data)
+		}
+	}
+	return g.Bus.Send(event.NewEvent(e.Name, e.GetType(), e.From, e.GetData(), e.GetExtra()))
+}
+
+// trackTrade updates the average entry price with a fill. Adding to the
+// position averages the price in, reducing keeps it, flipping restarts it at
+// the fill price. It also records the excursion of the trade.
+func (g *orderGate) trackTrade(tr *trademodel.Trade) {
+	delta := tr.Amount
+	if !tr.Action.IsLong() {
+		delta = -delta
+	}
+	next := g.hold + delta
+	var exc tradeExcursion
+	if g.hold != 0 && (g.hold > 0) != (delta > 0) {
+		exc = g.excursion(tr.Price)
+	}
+	g.excursions = append(g.excursions, exc)
+	if g.hold == 0 || (next != 0 && (g.hold > 0) != (next > 0)) {
+		g.low, g.high = tr.Price, tr.Price
+	}
+	switch {
+	case math.Abs(next) < 1e-12:
+		next, g.avgPrice = 0, 0
+	case g.hold == 0 || (g.hold > 0) == (delta > 0):
+		g.avgPrice = (g.avgPrice*math.Abs(g.hold) + tr.Price*tr.Amount) / math.Abs(next)
+	case (g.hold > 0) != (next > 0):
+		g.avgPrice = tr.Price
+	}
+	g.hold = next
+	g.lastTrade = tr.Time
+}
+
+// excursion returns the excursion of the open position up to a fill at
+// price.
+func (g *orderGate) excursion(price float64) tradeExcursion {
+	if g.avgPrice <= 0 {
+		return tradeExcursion{}
+	}
+	low, high := math.Min(g.low, price), math.Max(g.high, price)
+	adverse, favorable := g.avgPrice-low, high-g.avgPrice
+	if g.hold < 0 {
+		adverse, favorable = high-g.avgPrice, g.avgPrice-low
+	}
+	return tradeExcursion{
+		MAE: math.Max(adverse, 0) / g.avgPrice * 100,
+		MFE: math.Max(favorable, 0) / g.avgPrice * 100,
+	}
+}
+
+// recordPosition stores a position event at the time of the fill that
+// caused it. The exchange's hold is authoritative.
+func (g *orderGate) recordPosition(pos *trademodel.Position) {
+	g.hold = pos.Hold
+	if pos.Hold == 0 {
+		g.avgPrice = 0
+	}
+	g.positions = append(g.positions, store.PositionPoint{Time: g.lastTrade, Position: pos.Hold, AvgPrice: g.avgPrice})
+}
+
+func (g *orderGate) forwardError(e *event.Event) error {
+	err, ok := e.GetData().(error)
+	if !ok {
+		return nil
+	}
+	return g.Bus.Send(event.NewErrorEvent(e.From, e.Name, err))
+}
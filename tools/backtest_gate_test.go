@@ -0,0 +1,250 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ztrade/trademodel"
+	"github.com/ztrade/ztrade/pkg/core"
+	"github.com/ztrade/ztrade/pkg/event"
+	"github.com/ztrade/ztrade/pkg/process/dbstore"
+	"github.com/ztrade/ztrade/pkg/process/vex"
+)
+
+// gateHarness feeds candles and orders through an orderGate and records
+// the trades the virtual exchange reports.
+type gateHarness struct {
+	t      *testing.T
+	gate   *orderGate
+	src    *event.BaseProcesser
+	trades []trademodel.Trade
+	bars   int
+}
+
+func newGateHarness(t *testing.T, cfg backtestGate) *gateHarness {
+	h := &gateHarness{t: t, gate: newOrderGate(vex.NewVExchange("BTCUSDT"), cfg), src: event.NewBaseProcesser("src")}
+	sink := event.NewBaseProcesser("sink")
+	processers := event.NewSyncProcessers()
+	processers.Add(h.src)
+	processers.Add(h.gate)
+	processers.Add(sink)
+	if err := processers.Start(); err != nil {
+		t.Fatal(err)
+	}
+	sink.Subscribe(core.EventTrade, func(e *event.Event) error {
+		h.trades = append(h.trades, *e.GetData().(*trademodel.Trade))
+		return nil
+	})
+	h.src.Send("balance_init", core.EventBalanceInit, &core.BalanceInfo{Balance: 100000})
+	h.src.Send("risk_init", core.EventRiskLimit, &core.RiskLimit{Lever: 1})
+	return h
+}
+
+func (h *gateHarness) candle(open, high, low, close float64) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).Unix() + int64(h.bars*60)
+	h.bars++
+	c := &trademodel.Candle{Start: start, Open: open, High: high, Low: low, Close: close}
+	h.src.SendWithExtra("candle", core.EventCandle, c, "1m")
+}
+
+func (h *gateHarness) order(action trademodel.TradeType, price float64) {
+	h.src.Send("order", core.EventOrder, &trademodel.TradeAction{Action: action, Amount: 1, Price: price})
+}
+
+func TestOrderGateWarmup(t *testing.T) {
+	h := newGateHarness(t, backtestGate{WarmupBars: 2})
+	h.candle(100, 101, 99, 100)
+	h.order(trademodel.OpenLong, 100)
+	h.candle(100, 101, 99, 100)
+	h.order(trademodel.OpenLong, 100)
+	h.candle(100, 101, 99, 100)
+	if len(h.trades) != 0 || h.gate.stats.WarmupDropped != 2 {
+		t.Fatalf("during warmup: %d trades, %d dropped", len(h.trades), h.gate.stats.WarmupDropped)
+	}
+	h.order(trademodel.OpenLong, 100)
+	h.candle(100, 101, 99, 100)
+	if len(h.trades) != 1 {
+		t.Fatalf("after warmup: %d trades, want 1", len(h.trades))
+	}
+}
+
+func TestOrderGateLimitOrders(t *testing.T) {
+	h := newGateHarness(t, backtestGate{LimitOrders: true, LimitExpiryBars: 3})
+	h.candle(100, 101, 99, 100)
+
+	// a limit buy below the market fills only once price trades through it
+	h.order(trademodel.OpenLong, 98)
+	h.candle(100, 101, 98, 99)
+	if len(h.trades) != 0 {
+		t.Fatalf("touched limit filled: %+v", h.trades)
+	}
+	h.candle(99, 99.5, 97.5, 98)
+	if len(h.trades) != 1 || h.trades[0].Price != 98 {
+		t.Fatalf("traded-through limit: %+v", h.trades)
+	}
+
+	// a sell at the market fills at the next open
+	h.order(trademodel.CloseLong, 97)
+	h.candle(98.5, 99, 97.8, 98)
+	if len(h.trades) != 2 || h.trades[1].Price != 98.5 {
+		t.Fatalf("marketable order: %+v", h.trades)
+	}
+
+	// an unreachable limit expires
+	h.order(trademodel.OpenLong, 90)
+	for i := 0; i < 3; i++ {
+		h.candle(98, 99, 97, 98)
+	}
+	if len(h.gate.resting) != 0 || h.gate.stats.LimitExpired != 1 {
+		t.Fatalf("expiry: %d resting, %d expired", len(h.gate.resting), h.gate.stats.LimitExpired)
+	}
+}
+
+func TestOrderGateNextOpenFills(t *testing.T) {
+	for _, c := range []struct {
+		gate backtestGate
+		want float64
+	}{
+		{backtestGate{}, 100},                    // at the signal candle's close
+		{backtestGate{NextOpenFills: true}, 102}, // at the next candle's open
+	} {
+		h := newGateHarness(t, c.gate)
+		h.candle(99, 100.5, 98.5, 100)
+		h.order(trademodel.OpenLong, 100)
+		h.candle(102, 103, 99.5, 102.5)
+		if len(h.trades) != 1 || h.trades[0].Price != c.want {
+			t.Fatalf("%+v: trades %+v, want a fill at %v", c.gate, h.trades, c.want)
+		}
+	}
+	if (backtestGate{}).fillOn() != fillOnClose || (backtestGate{LimitOrders: true}).fillOn() != fillOnNextOpen {
+		t.Fatal("limit order model should report nextOpen fills")
+	}
+
+	// a limit below the market still waits for its price
+	h := newGateHarness(t, backtestGate{NextOpenFills: true})
+	h.candle(100, 101, 99, 100)
+	h.order(trademodel.OpenLong, 98)
+	h.candle(100, 101, 97, 99)
+	if len(h.trades) != 1 || h.trades[0].Price != 98 {
+		t.Fatalf("limit with NextOpenFills: %+v", h.trades)
+	}
+}
+
+func TestOrderGateMarketOrders(t *testing.T) {
+	// an order without a price fills at the next open under limit orders
+	h := newGateHarness(t, backtestGate{LimitOrders: true})
+	h.candle(100, 101, 99, 100)
+	h.order(trademodel.OpenLong, 0)
+	h.candle(102, 103, 101, 102.5)
+	if len(h.trades) != 1 || h.trades[0].Price != 102 {
+		t.Fatalf("limit model: %+v", h.trades)
+	}
+
+	// and at the signal candle's close otherwise
+	h = newGateHarness(t, backtestGate{})
+	h.candle(99, 100.5, 98.5, 100)
+	h.order(trademodel.OpenLong, 0)
+	h.candle(100, 101, 99.5, 100.5)
+	if len(h.trades) != 1 || h.trades[0].Price != 100 {
+		t.Fatalf("default model: %+v", h.trades)
+	}
+}
+
+func TestOrderGateRecordPositions(t *testing.T) {
+	h := newGateHarness(t, backtestGate{RecordPositions: true})
+	h.candle(100, 101, 99, 100)
+	h.order(trademodel.OpenLong, 100)
+	h.candle(100, 101, 99, 100)
+	h.order(trademodel.OpenLong, 110)
+	h.candle(110, 111, 109, 110)
+	h.order(trademodel.CloseLong, 110)
+	h.candle(110, 111, 109, 110)
+	h.order(trademodel.CloseLong, 110)
+	h.candle(110, 111, 109, 110)
+
+	got := h.gate.positions
+	want := []struct{ pos, avg float64 }{{1, 100}, {2, 105}, {1, 105}, {0, 0}}
+	if len(got) != len(want) {
+		t.Fatalf("positions: %+v", got)
+	}
+	for i, w := range want {
+		if got[i].Position != w.pos || got[i].AvgPrice != w.avg {
+			t.Fatalf("position %d = %+v, want %v", i, got[i], w)
+		}
+	}
+	if !got[1].Time.After(got[0].Time) {
+		t.Fatalf("position times not increasing: %v, %v", got[0].Time, got[1].Time)
+	}
+}
+
+func TestOrderGateExcursions(t *testing.T) {
+	h := newGateHarness(t, backtestGate{RecordPositions: true})
+	h.candle(100, 101, 99, 100)
+	h.order(trademodel.OpenLong, 100)
+	h.candle(100, 102, 97, 101)
+	h.order(trademodel.CloseLong, 104)
+	h.candle(101, 105, 100, 104) // the range after the close does not count
+	h.order(trademodel.OpenShort, 104)
+	h.candle(104, 106, 103, 105)
+	h.order(trademodel.CloseShort, 103)
+	h.candle(104, 104, 102, 103)
+
+	got := h.gate.excursions
+	want := []tradeExcursion{{}, {MAE: 3, MFE: 4}, {}, {MAE: 200.0 / 104, MFE: 100.0 / 104}}
+	if len(got) != len(want) {
+		t.Fatalf("excursions: %+v", got)
+	}
+	for i, w := range want {
+		if math.Abs(got[i].MAE-w.MAE) > 1e-9 || math.Abs(got[i].MFE-w.MFE) > 1e-9 {
+			t.Fatalf("excursion %d = %+v, want %+v", i, got[i], w)
+		}
+	}
+}
+
+func TestCandleSourceCancel(t *testing.T) {
+	db, err := dbstore.NewDBStore("sqlite", filepath.Join(t.TempDir(), "kline.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var candles []*trademodel.Candle
+	for i := 0; i < 10; i++ {
+		candles = append(candles, &trademodel.Candle{Start: start.Unix() + int64(i*60), Open: 1, High: 1, Low: 1, Close: 1})
+	}
+	if _, err := saveFetchedKlines(db, "binance", "BTCUSDT", "1m", candles); err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(ctx context.Context, stopAfter int) (int, error) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		src := &candleSource{BaseProcesser: *event.NewBaseProcesser("candles"), ctx: ctx, tbl: db.NewKlineTbl("binance", "BTCUSDT", "1m"), done: make(chan error, 1)}
+		sink := event.NewBaseProcesser("sink")
+		processers := event.NewSyncProcessers()
+		processers.Add(src)
+		processers.Add(sink)
+		if err := processers.Start(); err != nil {
+			t.Fatal(err)
+		}
+		seen := 0
+		sink.Subscribe(core.EventCandle, func(e *event.Event) error {
+			if seen++; seen == stopAfter {
+				cancel()
+			}
+			return nil
+		})
+		go src.emit(start, start.Add(time.Hour), "1m")
+		return seen, <-src.done
+	}
+
+	if n, err := run(context.Background(), 0); err != nil || n != 10 {
+		t.Fatalf("full run: %d candles, %v", n, err)
+	}
+	if n, err := run(context.Background(), 3); !errors.Is(err, context.Canceled) || n != 3 {
+		t.Fatalf("cancelled run: %d candles, %v", n, err)
+	}
+}
@@ -0,0 +1,132 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/ztrade/ztrade-mcp/auth"
+	"github.com/ztrade/ztrade-mcp/store"
+)
+
+// parseDateTime accepts '2006-01-02 15:04:05' or a bare '2006-01-02'.
+func parseDateTime(s string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02 15:04:05", s)
+}
+
+func registerListAllBacktests(s *server.MCPServer, st *store.Store) {
+	tool := mcp.NewTool("list_all_backtests",
+		mcp.WithDescription("List saved backtest runs across all strategies, newest first, each with its strategy name. Filter by exchange, symbol, when the run was made, minimum overall score, strategy tag and run label; paginate with offset/limit."),
+		mcp.WithString("exchange", mcp.Description("Only runs on this exchange")),
+		mcp.WithString("symbol", mcp.Description("Only runs on this symbol (e.g., ETHUSDT)")),
+		mcp.WithString("since", mcp.Description("Only runs made at or after this time, '2006-01-02' or '2006-01-02 15:04:05'")),
+		mcp.WithString("until", mcp.Description("Only runs made before this time, '2006-01-02' or '2006-01-02 15:04:05'")),
+		mcp.WithNumber("minScore", mcp.Description("Only runs with overallScore at least this")),
+		mcp.WithString("tag", mcp.Description("Only runs of strategies carrying this tag")),
+		mcp.WithString("runLabel", mcp.Description("Only runs with this run label (see run_backtest_managed)")),
+		mcp.WithNumber("offset", mcp.Description("Number of runs to skip. Default: 0")),
+		mcp.WithNumber("limit", mcp.Description("Maximum number of runs to return. Default: 50, Max: 500")),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if st == nil {
+			return toolError(codeUnavailable, "script store not initialized (check database config)"), nil
+		}
+
+		filter := store.BacktestFilter{
+			Exchange:  req.GetString("exchange", ""),
+			Symbol:    req.GetString("symbol", ""),
+			Tag:       req.GetString("tag", ""),
+			RunLabel:  req.GetString("runLabel", ""),
+			VisibleTo: auth.ScopedUser(ctx),
+			Offset:    int(req.GetFloat("offset", 0)),
+			Limit:     int(req.GetFloat("limit", 0)),
+		}
+		if v := req.GetString("since", ""); v != "" {
+			t, err := parseDateTime(v)
+			if err != nil {
+				return toolErrorf(codeInvalidArgument, "invalid since time: %s", err.Error()), nil
+			}
+			filter.Since = t
+		}
+		if v := req.GetString("until", ""); v != "" {
+			t, err := parseDateTime(v)
+			if err != nil {
+				return toolErrorf(codeInvalidArgument, "invalid until time: %s", err.Error()), nil
+			}
+			filter.Until = t
+		}
+		if args := req.GetArguments(); args["minScore"] != nil {
+			minScore := req.GetFloat("minScore", 0)
+			filter.MinScore = &minScore
+		}
+		if filter.Offset < 0 || filter.Limit < 0 {
+			return toolError(codeInvalidArgument, "offset and limit must not be negative"), nil
+		}
+		if filter.Limit == 0 {
+			filter.Limit = 50
+		}
+		if filter.Limit > 500 {
+			filter.Limit = 500
+		}
+
+		items, total, err := st.ListAllBacktests(filter)
+		if err != nil {
+			return toolErrorf(codeInternal, "failed to list backtests: %s", err.Error()), nil
+		}
+
+		type runSummary struct {
+			ID            int64   `json:"id"`
+			StrategyID    int64   `json:"strategyId"`
+			StrategyName  string  `json:"strategyName"`
+			ScriptVersion int     `json:"scriptVersion"`
+			Exchange      string  `json:"exchange"`
+			Symbol        string  `json:"symbol"`
+			StartTime     string  `json:"startTime"`
+			EndTime       string  `json:"endTime"`
+			Param         string  `json:"param,omitempty"`
+			WinRate       float64 `json:"winRate"`
+			TotalReturn   float64 `json:"totalReturn"`
+			SharpeRatio   float64 `json:"sharpeRatio"`
+			MaxDrawdown   float64 `json:"maxDrawdown"`
+			OverallScore  float64 `json:"overallScore"`
+			RunLabel      string  `json:"runLabel,omitempty"`
+			CreatedAt     string  `json:"createdAt"`
+		}
+		runs := make([]runSummary, 0, len(items))
+		for _, r := range items {
+			runs = append(runs, runSummary{
+				ID:            r.ID,
+				StrategyID:    r.ScriptID,
+				StrategyName:  r.ScriptName,
+				ScriptVersion: r.ScriptVersion,
+				Exchange:      r.Exchange,
+				Symbol:        r.Symbol,
+				StartTime:     r.StartTime.Format("2006-01-02 15:04:05"),
+				EndTime:       r.EndTime.Format("2006-01-02 15:04:05"),
+				Param:         r.Param,
+				WinRate:       r.WinRate,
+				TotalReturn:   r.TotalReturn,
+				SharpeRatio:   r.SharpeRatio,
+				MaxDrawdown:   r.MaxDrawdown,
+				OverallScore:  r.OverallScore,
+				RunLabel:      r.RunLabel,
+				CreatedAt:     r.CreatedAt.Format("2006-01-02 15:04:05"),
+			})
+		}
+
+		result := map[string]interface{}{
+			"total":    total,
+			"offset":   filter.Offset,
+			"returned": len(runs),
+			"runs":     runs,
+		}
+		data, _ := json.MarshalIndent(result, "", "  ")
+		return mcp.NewToolResultText(string(data)), nil
+	})
+}
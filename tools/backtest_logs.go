@@ -3,7 +3,7 @@ package tools
 import (
 	"context"
 	"encoding/json"
-	"fmt"
+	"regexp"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
@@ -16,25 +16,41 @@ func registerGetBacktestLogs(s *server.MCPServer, st *store.Store) {
 		mcp.WithNumber("recordId", mcp.Required(), mcp.Description("Backtest record ID")),
 		mcp.WithNumber("offset", mcp.Description("Pagination offset (default: 0)")),
 		mcp.WithNumber("limit", mcp.Description("Max lines to return (default: 200, max: 2000)")),
+		mcp.WithString("contains", mcp.Description("Only return lines containing this text (matched by the database; case-insensitive on SQLite and MySQL). Log lines have no level, so filter on a marker the strategy logs, e.g. 'WARN'")),
+		mcp.WithString("regex", mcp.Description("Only return lines matching this Go regular expression, e.g. '(?i)stop|cancel'. Combined with contains, both must match")),
 	)
 
 	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		if st == nil {
-			return mcp.NewToolResultError("script store not initialized (check database config)"), nil
+			return toolError(codeUnavailable, "script store not initialized (check database config)"), nil
 		}
 
 		recordID := int64(req.GetFloat("recordId", 0))
+		if _, _, err := accessibleRecord(ctx, st, recordID); err != nil {
+			return toolErrorf(storeErrorCode(err), "failed to get record: %s", err.Error()), nil
+		}
 		offset := int(req.GetFloat("offset", 0))
 		limit := int(req.GetFloat("limit", 0))
+		filter := store.BacktestLogFilter{Contains: req.GetString("contains", "")}
+		if expr := req.GetString("regex", ""); expr != "" {
+			re, err := regexp.Compile(expr)
+			if err != nil {
+				return toolErrorf(codeInvalidArgument, "invalid regex: %s", err.Error()), nil
+			}
+			filter.Pattern = re
+		}
+		filtered := filter.Contains != "" || filter.Pattern != nil
 
-		logs, total, err := st.ListBacktestLogs(recordID, offset, limit)
+		logs, total, err := st.FilterBacktestLogs(recordID, filter, offset, limit)
 		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("failed to list backtest logs: %s", err.Error())), nil
+			return toolErrorf(codeInternal, "failed to list backtest logs: %s", err.Error()), nil
 		}
 
 		lines := make([]string, 0, len(logs))
+		lineNos := make([]int, 0, len(logs))
 		for _, l := range logs {
 			lines = append(lines, l.Content)
+			lineNos = append(lineNos, l.LineNo)
 		}
 
 		result := map[string]interface{}{
@@ -44,6 +60,10 @@ func registerGetBacktestLogs(s *server.MCPServer, st *store.Store) {
 			"limit":    limit,
 			"lines":    lines,
 		}
+		if filtered {
+			// total counts matching lines; lineNos locate them in the full log
+			result["lineNos"] = lineNos
+		}
 		data, _ := json.MarshalIndent(result, "", "  ")
 		return mcp.NewToolResultText(string(data)), nil
 	})
@@ -0,0 +1,291 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/spf13/viper"
+	"github.com/ztrade/ztrade-mcp/internal/metricgrade"
+	"github.com/ztrade/ztrade-mcp/store"
+)
+
+const (
+	reportSVGWidth  = 900
+	reportSVGHeight = 280
+	// reportMaxTrades bounds the trade table; the summary covers all trades.
+	reportMaxTrades = 500
+)
+
+type reportMetric struct {
+	Name  string
+	Value string
+	Grade string
+}
+
+type reportTradeSummary struct {
+	Total      int
+	Closed     int
+	Wins       int
+	Losses     int
+	WinRate    string
+	TotalFee   string
+	BestTrade  string
+	WorstTrade string
+}
+
+type reportData struct {
+	Title       string
+	Record      *store.BacktestRecord
+	StrategyTag string
+	Generated   string
+	Metrics     []reportMetric
+	EquitySVG   template.HTML
+	Summary     reportTradeSummary
+	Trades      []store.BacktestTrade
+	TradesShown int
+}
+
+var backtestReportTmpl = template.Must(template.New("report").Funcs(template.FuncMap{
+	"fmtTime":  func(t time.Time) string { return t.Format("2006-01-02 15:04:05") },
+	"fmtFloat": func(v float64) string { return strconv.FormatFloat(v, 'f', 4, 64) },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 24px; color: #222; }
+h1 { font-size: 22px; margin-bottom: 4px; }
+.sub { color: #666; margin-bottom: 20px; }
+table { border-collapse: collapse; margin-bottom: 24px; }
+th, td { border: 1px solid #ddd; padding: 4px 10px; text-align: right; }
+th { background: #f5f5f5; }
+td.name { text-align: left; }
+.poor { background: #fde2e2; }
+.acceptable { background: #fff4d6; }
+.good { background: #e3f4e1; }
+.excellent { background: #c8ecc3; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<div class="sub">{{.StrategyTag}} · {{.Record.Exchange}} {{.Record.Symbol}} · {{fmtTime .Record.StartTime}} → {{fmtTime .Record.EndTime}} · generated {{.Generated}}</div>
+{{if .Record.Param}}<div class="sub">param: <code>{{.Record.Param}}</code></div>{{end}}
+
+<h2>Key Metrics</h2>
+<table>
+<tr><th>Metric</th><th>Value</th><th>Grade</th></tr>
+{{range .Metrics}}<tr class="{{.Grade}}"><td class="name">{{.Name}}</td><td>{{.Value}}</td><td>{{.Grade}}</td></tr>
+{{end}}</table>
+
+<h2>Equity Curve</h2>
+{{.EquitySVG}}
+
+<h2>Trade Summary</h2>
+<table>
+<tr><th>Trades</th><th>Closed</th><th>Wins</th><th>Losses</th><th>Win Rate</th><th>Total Fee</th><th>Best</th><th>Worst</th></tr>
+<tr><td>{{.Summary.Total}}</td><td>{{.Summary.Closed}}</td><td>{{.Summary.Wins}}</td><td>{{.Summary.Losses}}</td><td>{{.Summary.WinRate}}</td><td>{{.Summary.TotalFee}}</td><td>{{.Summary.BestTrade}}</td><td>{{.Summary.WorstTrade}}</td></tr>
+</table>
+
+{{if .Trades}}<h2>Trades{{if lt .TradesShown .Summary.Total}} (first {{.TradesShown}} of {{.Summary.Total}}){{end}}</h2>
+<table>
+<tr><th>Time</th><th>Action</th><th>Price</th><th>Amount</th><th>Profit</th><th>Fee</th><th>Equity</th></tr>
+{{range .Trades}}<tr><td>{{fmtTime .Time}}</td><td class="name">{{.Action}}</td><td>{{fmtFloat .Price}}</td><td>{{fmtFloat .Amount}}</td><td>{{fmtFloat .Profit}}</td><td>{{fmtFloat .Fee}}</td><td>{{fmtFloat .Equity}}</td></tr>
+{{end}}</table>{{end}}
+</body>
+</html>
+`))
+
+// equitySVG renders the equity curve as an inline SVG line chart.
+func equitySVG(points []store.EquityPoint, width, height int) template.HTML {
+	if len(points) < 2 {
+		return template.HTML(`<p>No equity curve stored for this record.</p>`)
+	}
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, p := range points {
+		lo = math.Min(lo, p.Equity)
+		hi = math.Max(hi, p.Equity)
+	}
+	if hi == lo {
+		hi = lo + 1
+	}
+	const pad = 30.0
+	w, h := float64(width)-2*pad, float64(height)-2*pad
+	t0 := points[0].Time
+	span := points[len(points)-1].Time.Sub(t0).Seconds()
+
+	var poly strings.Builder
+	for i, p := range points {
+		x := pad + w*float64(i)/float64(len(points)-1)
+		if span > 0 {
+			x = pad + w*p.Time.Sub(t0).Seconds()/span
+		}
+		y := pad + h*(hi-p.Equity)/(hi-lo)
+		if i > 0 {
+			poly.WriteByte(' ')
+		}
+		fmt.Fprintf(&poly, "%.1f,%.1f", x, y)
+	}
+	svg := fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+
+		`<rect x="0" y="0" width="%d" height="%d" fill="#fff" stroke="#ddd"/>`+
+		`<polyline fill="none" stroke="#2b6cb0" stroke-width="1.5" points="%s"/>`+
+		`<text x="4" y="%.0f" font-size="11" fill="#666">%s</text>`+
+		`<text x="4" y="%.0f" font-size="11" fill="#666">%s</text>`+
+		`</svg>`,
+		width, height, width, height, width, height, poly.String(),
+		pad-8, template.HTMLEscapeString(strconv.FormatFloat(hi, 'f', 2, 64)),
+		pad+h+14, template.HTMLEscapeString(strconv.FormatFloat(lo, 'f', 2, 64)))
+	return template.HTML(svg)
+}
+
+func summarizeTrades(trades []store.BacktestTrade) reportTradeSummary {
+	sum := reportTradeSummary{Total: len(trades)}
+	var fee, best, worst float64
+	for _, t := range trades {
+		fee += t.Fee
+		if t.Profit == 0 {
+			continue
+		}
+		if sum.Closed == 0 || t.Profit > best {
+			best = t.Profit
+		}
+		if sum.Closed == 0 || t.Profit < worst {
+			worst = t.Profit
+		}
+		sum.Closed++
+		if t.Profit > 0 {
+			sum.Wins++
+		} else {
+			sum.Losses++
+		}
+	}
+	sum.TotalFee = strconv.FormatFloat(fee, 'f', 4, 64)
+	sum.BestTrade = strconv.FormatFloat(best, 'f', 4, 64)
+	sum.WorstTrade = strconv.FormatFloat(worst, 'f', 4, 64)
+	if sum.Closed > 0 {
+		sum.WinRate = strconv.FormatFloat(float64(sum.Wins)/float64(sum.Closed)*100, 'f', 2, 64) + "%"
+	} else {
+		sum.WinRate = "-"
+	}
+	return sum
+}
+
+func reportMetrics(r *store.BacktestRecord) []reportMetric {
+	pct := func(v float64) string { return strconv.FormatFloat(v*100, 'f', 2, 64) + "%" }
+	num := func(v float64) string { return strconv.FormatFloat(v, 'f', 4, 64) }
+	rows := []struct {
+		key, name, value string
+		v                float64
+	}{
+		{"totalReturn", "Total Return", pct(r.TotalReturn), r.TotalReturn},
+		{"annualReturn", "Annual Return", pct(r.AnnualReturn), r.AnnualReturn},
+		{"totalProfit", "Total Profit", num(r.TotalProfit), r.TotalProfit},
+		{"sharpeRatio", "Sharpe Ratio", num(r.SharpeRatio), r.SharpeRatio},
+		{"sortinoRatio", "Sortino Ratio", num(r.SortinoRatio), r.SortinoRatio},
+		{"calmarRatio", "Calmar Ratio", num(r.CalmarRatio), r.CalmarRatio},
+		{"martinRatio", "Martin Ratio", num(r.MartinRatio), r.MartinRatio},
+		{"maxDrawdown", "Max Drawdown", pct(r.MaxDrawdown), r.MaxDrawdown},
+		{"ulcerIndex", "Ulcer Index", pct(r.UlcerIndex), r.UlcerIndex},
+		{"volatility", "Volatility", pct(r.Volatility), r.Volatility},
+		{"winRate", "Win Rate", pct(r.WinRate), r.WinRate},
+		{"profitFactor", "Profit Factor", num(r.ProfitFactor), r.ProfitFactor},
+		{"totalFee", "Total Fee", num(r.TotalFee), r.TotalFee},
+		{"overallScore", "Overall Score", num(r.OverallScore), r.OverallScore},
+	}
+	metrics := make([]reportMetric, 0, len(rows))
+	for _, row := range rows {
+		metrics = append(metrics, reportMetric{Name: row.name, Value: row.value, Grade: metricgrade.Grade(row.key, row.v)})
+	}
+	return metrics
+}
+
+// renderBacktestReport renders a self-contained HTML report of a record.
+func renderBacktestReport(record *store.BacktestRecord, strategyName string, points []store.EquityPoint, trades []store.BacktestTrade) (string, error) {
+	data := reportData{
+		Title:       fmt.Sprintf("Backtest Report #%d", record.ID),
+		Record:      record,
+		StrategyTag: fmt.Sprintf("%s v%d", strategyName, record.ScriptVersion),
+		Generated:   time.Now().Format("2006-01-02 15:04:05"),
+		Metrics:     reportMetrics(record),
+		EquitySVG:   equitySVG(points, reportSVGWidth, reportSVGHeight),
+		Summary:     summarizeTrades(trades),
+		Trades:      trades,
+	}
+	if len(data.Trades) > reportMaxTrades {
+		data.Trades = data.Trades[:reportMaxTrades]
+	}
+	data.TradesShown = len(data.Trades)
+
+	var buf bytes.Buffer
+	if err := backtestReportTmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func registerExportBacktestReport(s *server.MCPServer, cfg *viper.Viper, st *store.Store) {
+	tool := mcp.NewTool("export_backtest_report",
+		mcp.WithDescription("Render a self-contained HTML report of a saved backtest record (color-coded key metrics, equity curve as inline SVG, trade summary) into mcp.workDir and return its path."),
+		mcp.WithNumber("recordId", mcp.Required(), mcp.Description("Backtest record ID")),
+		mcp.WithString("outputPath", mcp.Description("Output file, relative to or inside mcp.workDir. Default: reports/backtest_<recordId>.html")),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if st == nil {
+			return toolError(codeUnavailable, "script store not initialized (check database config)"), nil
+		}
+
+		recordID := int64(req.GetFloat("recordId", 0))
+		record, script, err := accessibleRecord(ctx, st, recordID)
+		if err != nil {
+			return toolErrorf(storeErrorCode(err), "failed to get record: %s", err.Error()), nil
+		}
+		strategyName := fmt.Sprintf("strategy %d", record.ScriptID)
+		if script != nil {
+			strategyName = script.Name
+		}
+		points, err := st.ListBacktestEquity(recordID)
+		if err != nil {
+			return toolErrorf(storeErrorCode(err), "failed to get equity curve: %s", err.Error()), nil
+		}
+		trades, err := st.ListBacktestTrades(recordID)
+		if err != nil {
+			return toolErrorf(storeErrorCode(err), "failed to get trades: %s", err.Error()), nil
+		}
+
+		html, err := renderBacktestReport(record, strategyName, points, trades)
+		if err != nil {
+			return toolErrorf(codeInternal, "failed to render report: %s", err.Error()), nil
+		}
+		outputPath := req.GetString("outputPath", "")
+		if outputPath == "" {
+			outputPath = fmt.Sprintf("reports/backtest_%d.html", recordID)
+		}
+		path, err := writeWorkDirFile(cfg, outputPath, html)
+		if err != nil {
+			return toolErrorf(codeInternal, "failed to write report: %s", err.Error()), nil
+		}
+
+		result := map[string]interface{}{
+			"recordId":     recordID,
+			"outputPath":   path,
+			"bytes":        len(html),
+			"equityPoints": len(points),
+			"trades":       len(trades),
+		}
+		if len(points) == 0 {
+			result["warning"] = "no equity curve stored for this record (records created before equity persistence have none)"
+		}
+		data, _ := json.MarshalIndent(result, "", "  ")
+		return mcp.NewToolResultText(string(data)), nil
+	})
+}
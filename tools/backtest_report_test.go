@@ -0,0 +1,47 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ztrade/ztrade-mcp/store"
+)
+
+func TestRenderBacktestReport(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	record := &store.BacktestRecord{
+		ID: 7, ScriptVersion: 2, Exchange: "binance", Symbol: "BTCUSDT",
+		StartTime: start, EndTime: start.Add(48 * time.Hour),
+		Param: `{"fast":5}`, SharpeRatio: 2.5, MaxDrawdown: 0.35,
+	}
+	points := []store.EquityPoint{
+		{Time: start, Equity: 1000},
+		{Time: start.Add(time.Hour), Equity: 900},
+		{Time: start.Add(2 * time.Hour), Equity: 1100},
+	}
+	trades := []store.BacktestTrade{
+		{Time: start.Add(time.Hour), Action: "OpenLong", Price: 100, Amount: 1, Fee: 0.1},
+		{Time: start.Add(2 * time.Hour), Action: "CloseLong", Price: 110, Amount: 1, Profit: 10, Fee: 0.1},
+		{Time: start.Add(3 * time.Hour), Action: "CloseShort", Price: 110, Amount: 1, Profit: -4, Fee: 0.1},
+	}
+	html, err := renderBacktestReport(record, "EmaCross<script>", points, trades)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"Backtest Report #7",
+		"EmaCross&lt;script&gt; v2",
+		"<polyline",
+		`<tr class="excellent"><td class="name">Sharpe Ratio</td>`,
+		`<tr class="poor"><td class="name">Max Drawdown</td>`,
+		"<td>3</td><td>2</td><td>1</td><td>1</td><td>50.00%</td>",
+	} {
+		if !strings.Contains(html, want) {
+			t.Fatalf("missing %q in report:\n%s", want, html)
+		}
+	}
+	if strings.Contains(html, "<script>") {
+		t.Fatalf("strategy name was not escaped")
+	}
+}
@@ -0,0 +1,263 @@
+package tools
+
+import (
+	"context"
+	"crypto/sha1"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"github.com/ztrade/ztrade-mcp/store"
+	"github.com/ztrade/ztrade/pkg/process/dbstore"
+)
+
+// baselineSources holds the built-in baseline strategies. They are kept as
+// templates so the Go toolchain does not compile them into this package.
+//
+//go:embed baselines/*.go.tmpl
+var baselineSources embed.FS
+
+// builtinBaselines maps baseline names to their embedded source.
+var builtinBaselines = map[string]string{
+	"buy_and_hold": "baselines/buy_and_hold.go.tmpl",
+	"ema_cross":    "baselines/ema_cross.go.tmpl",
+}
+
+// benchmarkMetrics are compared between the strategy and the baseline.
+var benchmarkMetrics = []string{
+	"totalReturn", "annualReturn", "totalProfit", "sharpeRatio", "sortinoRatio",
+	"calmarRatio", "maxDrawdown", "winRate", "profitFactor", "overallScore", "totalActions",
+}
+
+func baselineNames() []string {
+	names := make([]string, 0, len(builtinBaselines))
+	for name := range builtinBaselines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ensureBaselinePlugin compiles a built-in baseline into a plugin, reusing
+// a previous build of the same source.
+func ensureBaselinePlugin(name string) (string, error) {
+	file, ok := builtinBaselines[name]
+	if !ok {
+		return "", fmt.Errorf("unknown baseline %q, supported: %s", name, strings.Join(baselineNames(), ", "))
+	}
+	src, err := baselineSources.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	sum := sha1.Sum(src)
+	base := filepath.Join("/tmp/ztrade_plugins", fmt.Sprintf("baseline_%s_%x", name, sum[:6]))
+	soPath := base + ".so"
+	if _, err := os.Stat(soPath); err == nil {
+		return soPath, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(base), 0755); err != nil {
+		return "", fmt.Errorf("failed to create plugin temp dir: %w", err)
+	}
+	if err := writeFile(base+".go", string(src)); err != nil {
+		return "", fmt.Errorf("failed to write baseline source: %w", err)
+	}
+	if err := buildPlugin(base+".go", soPath, pluginBuildFlags{}); err != nil {
+		return "", fmt.Errorf("failed to build baseline %s: %w", name, err)
+	}
+	return soPath, nil
+}
+
+// compareBacktestMetrics returns the benchmark metrics of both runs and
+// the strategy-minus-baseline deltas.
+func compareBacktestMetrics(strategy, baseline map[string]interface{}) map[string]map[string]float64 {
+	ret := make(map[string]map[string]float64, len(benchmarkMetrics))
+	for _, key := range benchmarkMetrics {
+		sv, sok := toFloat(strategy[key])
+		bv, bok := toFloat(baseline[key])
+		if !sok || !bok {
+			continue
+		}
+		ret[key] = map[string]float64{"strategy": sv, "baseline": bv, "delta": sv - bv}
+	}
+	return ret
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+func registerBenchmarkStrategy(s *server.MCPServer, db *dbstore.DBStore, cfg *viper.Viper, st *store.Store, tm *TaskManager) {
+	tool := mcp.NewTool("benchmark_strategy",
+		mcp.WithDescription("Backtest a managed strategy and a built-in baseline (buy_and_hold or ema_cross) on the same exchange, symbol, range, balance, fee and leverage, and return their metrics side by side with strategy-minus-baseline deltas. The baselines ship with the server; results are not saved. Long ranges run asynchronously like run_backtest."),
+		mcp.WithNumber("strategyId", mcp.Required(), mcp.Description("Strategy ID in the database")),
+		mcp.WithString("baseline", mcp.Description("Baseline strategy: buy_and_hold (default) or ema_cross")),
+		mcp.WithString("exchange", mcp.Required(), mcp.Description("Exchange name (e.g., binance)")),
+		mcp.WithString("symbol", mcp.Required(), mcp.Description("Trading pair (e.g., BTCUSDT)")),
+		mcp.WithString("start", mcp.Required(), mcp.Description("Backtest start time in format '2006-01-02 15:04:05'")),
+		mcp.WithString("end", mcp.Required(), mcp.Description("Backtest end time in format '2006-01-02 15:04:05'")),
+		mcp.WithNumber("balance", mcp.Description("Initial balance. Default: mcp.backtest.defaults for the exchange, else 100000")),
+		mcp.WithString("param", mcp.Description("Strategy parameters as JSON string")),
+		mcp.WithString("baselineParam", mcp.Description("Baseline parameters as JSON string. buy_and_hold: ratio; ema_cross: period (default 1h), fast (12), slow (26), ratio")),
+		mcp.WithNumber("version", mcp.Description("Strategy version to use. Default: latest version.")),
+	)
+	for _, opt := range costModelOptions() {
+		opt(&tool)
+	}
+	idempotencyKeyOption()(&tool)
+
+	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if db == nil {
+			return toolError(codeUnavailable, "database not initialized"), nil
+		}
+		if st == nil {
+			return toolError(codeUnavailable, "script store not initialized (check database config)"), nil
+		}
+
+		strategyID := int64(req.GetFloat("strategyId", 0))
+		baseline := req.GetString("baseline", "buy_and_hold")
+		exchangeName := req.GetString("exchange", "")
+		symbol := req.GetString("symbol", "")
+		startStr := req.GetString("start", "")
+		endStr := req.GetString("end", "")
+		balanceF := req.GetFloat("balance", 0)
+		param := req.GetString("param", "")
+		baselineParam := req.GetString("baselineParam", "")
+		versionF := req.GetFloat("version", 0)
+
+		if _, ok := builtinBaselines[baseline]; !ok {
+			return toolErrorf(codeInvalidArgument, "unknown baseline %q, supported: %s", baseline, strings.Join(baselineNames(), ", ")), nil
+		}
+		defaultBalance, baseCosts := backtestDefaults(cfg, exchangeName)
+		costs, err := costModelFromRequest(req, baseCosts)
+		if err != nil {
+			return toolError(codeInvalidArgument, err.Error()), nil
+		}
+
+		script, err := accessibleScript(ctx, st, strategyID, false)
+		if err != nil {
+			return toolErrorf(storeErrorCode(err), "failed to get script: %s", err.Error()), nil
+		}
+		if versionF > 0 {
+			ver, err := st.GetVersion(strategyID, int(versionF))
+			if err != nil {
+				return toolErrorf(storeErrorCode(err), "failed to get version: %s", err.Error()), nil
+			}
+			script.Content, script.Version = ver.Content, ver.Version
+		}
+
+		start, err := time.Parse("2006-01-02 15:04:05", startStr)
+		if err != nil {
+			return toolErrorf(codeInvalidArgument, "invalid start time: %s", err.Error()), nil
+		}
+		end, err := time.Parse("2006-01-02 15:04:05", endStr)
+		if err != nil {
+			return toolErrorf(codeInvalidArgument, "invalid end time: %s", err.Error()), nil
+		}
+
+		if balanceF <= 0 {
+			balanceF = defaultBalance
+		}
+		if err := loadSymbolRules(ctx, cfg, req, &costs, exchangeName, symbol); err != nil {
+			return toolErrorf(codeUpstream, "symbolRules: %s", err.Error()), nil
+		}
+
+		strategySo, err := buildStoredScript(script)
+		if err != nil {
+			return toolError(codeBuildFailed, err.Error()), nil
+		}
+		baselineSo, err := ensureBaselinePlugin(baseline)
+		if err != nil {
+			return toolError(codeBuildFailed, err.Error()), nil
+		}
+
+		runBenchmark := func(ctx context.Context) (map[string]interface{}, error) {
+			strategyResult, err := runBacktestCore(ctx, db, strategySo, exchangeName, symbol, param, start, end, balanceF, costs, backtestGate{})
+			if err != nil {
+				return nil, fmt.Errorf("strategy backtest: %w", err)
+			}
+			baselineResult, err := runBacktestCore(ctx, db, baselineSo, exchangeName, symbol, baselineParam, start, end, balanceF, costs, backtestGate{})
+			if err != nil {
+				return nil, fmt.Errorf("baseline backtest: %w", err)
+			}
+			return map[string]interface{}{
+				"strategyId":      strategyID,
+				"strategyName":    script.Name,
+				"strategyVersion": script.Version,
+				"baseline":        baseline,
+				"exchange":        exchangeName,
+				"symbol":          symbol,
+				"start":           startStr,
+				"end":             endStr,
+				"metrics":         compareBacktestMetrics(strategyResult, baselineResult),
+				"dataCoverage":    strategyResult["dataCoverage"],
+			}, nil
+		}
+
+		if ShouldRunAsync(start, end) {
+			taskID, created := tm.CreateTaskOnce("benchmark", map[string]string{
+				"strategyId": fmt.Sprintf("%d", strategyID),
+				"baseline":   baseline,
+				"exchange":   exchangeName,
+				"symbol":     symbol,
+				"start":      startStr,
+				"end":        endStr,
+			}, taskDedupeKey(ctx, "benchmark", req))
+			if !created {
+				return dedupedTaskResult(taskID), nil
+			}
+
+			go func() {
+				tm.StartTask(taskID)
+				doneCh := tm.ProgressEstimator(taskID, "benchmark", start, end)
+
+				// the task outlives the request that started it
+				result, err := runBenchmark(context.WithoutCancel(ctx))
+				close(doneCh)
+
+				if err != nil {
+					log.WithContext(ctx).Errorf("async benchmark task %s failed: %s", taskID, err.Error())
+					tm.FailTask(taskID, err.Error())
+					return
+				}
+
+				data, _ := json.MarshalIndent(result, "", "  ")
+				tm.CompleteTask(taskID, string(data))
+				log.WithContext(ctx).Infof("async benchmark task %s completed", taskID)
+			}()
+
+			asyncResult := map[string]interface{}{
+				"async":   true,
+				"taskId":  taskID,
+				"message": fmt.Sprintf("Backtest time range exceeds %d days, running asynchronously. Use get_task_status with taskId '%s' to check progress, or get_task_result to retrieve the final result.", AsyncThresholdDays, taskID),
+			}
+			data, _ := json.MarshalIndent(asyncResult, "", "  ")
+			return mcp.NewToolResultText(string(data)), nil
+		}
+
+		result, err := runBenchmark(ctx)
+		if err != nil {
+			return toolError(backtestErrorCode(err), err.Error()), nil
+		}
+		data, _ := json.MarshalIndent(result, "", "  ")
+		return mcp.NewToolResultText(string(data)), nil
+	})
+}
@@ -0,0 +1,37 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuiltinBaselinesEmbedded(t *testing.T) {
+	for name, file := range builtinBaselines {
+		src, err := baselineSources.ReadFile(file)
+		if err != nil {
+			t.Fatalf("baseline %s: %v", name, err)
+		}
+		if !strings.Contains(string(src), "func (s *") || !strings.HasPrefix(string(src), "package strategy") {
+			t.Fatalf("baseline %s does not look like a strategy", name)
+		}
+	}
+	if _, err := ensureBaselinePlugin("random_walk"); err == nil {
+		t.Fatalf("expected error for unknown baseline")
+	}
+}
+
+func TestCompareBacktestMetrics(t *testing.T) {
+	got := compareBacktestMetrics(
+		map[string]interface{}{"totalReturn": 0.3, "totalActions": 10, "maxDrawdown": 0.1},
+		map[string]interface{}{"totalReturn": 0.1, "totalActions": 1},
+	)
+	if got["totalReturn"]["delta"] < 0.199 || got["totalReturn"]["delta"] > 0.201 {
+		t.Fatalf("unexpected totalReturn comparison %v", got["totalReturn"])
+	}
+	if got["totalActions"]["strategy"] != 10 || got["totalActions"]["delta"] != 9 {
+		t.Fatalf("unexpected totalActions comparison %v", got["totalActions"])
+	}
+	if _, ok := got["maxDrawdown"]; ok {
+		t.Fatalf("metrics missing on one side should be skipped")
+	}
+}
@@ -0,0 +1,110 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/ztrade/ztrade-mcp/store"
+)
+
+// lowerIsBetterMetrics are the backtestMetricGetters where the smallest
+// value wins.
+var lowerIsBetterMetrics = map[string]bool{
+	"maxDrawdown": true,
+	"ulcerIndex":  true,
+}
+
+// bestRecord returns the record with the best value of metric, the first on
+// ties, so the newest when records are listed newest first.
+func bestRecord(records []store.BacktestRecord, metric string) *store.BacktestRecord {
+	get := backtestMetricGetters[metric]
+	var best *store.BacktestRecord
+	for i := range records {
+		r := &records[i]
+		if best == nil {
+			best = r
+			continue
+		}
+		if v, b := get(r), get(best); (lowerIsBetterMetrics[metric] && v < b) || (!lowerIsBetterMetrics[metric] && v > b) {
+			best = r
+		}
+	}
+	return best
+}
+
+func registerGetBestParams(s *server.MCPServer, st *store.Store) {
+	tool := mcp.NewTool("get_best_params",
+		mcp.WithDescription("Get the parameter set of a strategy's best backtest run by a metric: the param JSON of the winning run_backtest_managed record, ready to pass as param to run_backtest_managed or start_trade (or store it as the default with apply_param_set and the returned recordId), with the record id, range and key metrics. maxDrawdown and ulcerIndex are minimized, the other metrics maximized; ties go to the newest run."),
+		mcp.WithNumber("strategyId", mcp.Required(), mcp.Description("Strategy ID")),
+		mcp.WithString("metric", mcp.Description("Metric to rank by: "+strings.Join(backtestMetricNames(), ", ")+". Default: overallScore")),
+		mcp.WithString("symbol", mcp.Description("Only consider backtests on this symbol. Default: all symbols")),
+		mcp.WithNumber("version", mcp.Description("Only consider backtests of this strategy version. Default: all versions")),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if st == nil {
+			return toolError(codeUnavailable, "script store not initialized (check database config)"), nil
+		}
+
+		strategyID := int64(req.GetFloat("strategyId", 0))
+		metric := req.GetString("metric", "overallScore")
+		symbol := req.GetString("symbol", "")
+		version := int(req.GetFloat("version", 0))
+		if _, ok := backtestMetricGetters[metric]; !ok {
+			return toolErrorf(codeInvalidArgument, "unknown metric %q, supported: %s", metric, strings.Join(backtestMetricNames(), ", ")), nil
+		}
+		if _, err := accessibleScript(ctx, st, strategyID, false); err != nil {
+			return toolErrorf(storeErrorCode(err), "failed to get script: %s", err.Error()), nil
+		}
+
+		records, err := st.ListRunRecords(strategyID)
+		if err != nil {
+			return toolErrorf(codeInternal, "failed to list records: %s", err.Error()), nil
+		}
+		filtered := records[:0]
+		for _, r := range records {
+			if version > 0 && r.ScriptVersion != version {
+				continue
+			}
+			if symbol != "" && r.Symbol != symbol {
+				continue
+			}
+			filtered = append(filtered, r)
+		}
+		if len(filtered) == 0 {
+			return toolErrorf(codeNotFound, "no backtest records found for strategy %d with the given filters", strategyID), nil
+		}
+
+		best := bestRecord(filtered, metric)
+		result := map[string]interface{}{
+			"strategyId":  strategyID,
+			"metric":      metric,
+			"metricValue": backtestMetricGetters[metric](best),
+			"candidates":  len(filtered),
+			"recordId":    best.ID,
+			"version":     best.ScriptVersion,
+			"exchange":    best.Exchange,
+			"symbol":      best.Symbol,
+			"start":       best.StartTime.Format("2006-01-02 15:04:05"),
+			"end":         best.EndTime.Format("2006-01-02 15:04:05"),
+			"param":       best.Param,
+			"metrics": map[string]interface{}{
+				"overallScore": best.OverallScore,
+				"sharpeRatio":  best.SharpeRatio,
+				"totalReturn":  best.TotalReturn,
+				"maxDrawdown":  best.MaxDrawdown,
+				"winRate":      best.WinRate,
+				"profitFactor": best.ProfitFactor,
+				"totalActions": best.TotalActions,
+			},
+		}
+		if best.Param == "" {
+			result["note"] = "the best run used the strategy's parameter defaults"
+		}
+		data, _ := json.MarshalIndent(result, "", "  ")
+		return mcp.NewToolResultText(string(data)), nil
+	})
+}
@@ -0,0 +1,28 @@
+package tools
+
+import (
+	"testing"
+
+	"github.com/ztrade/ztrade-mcp/store"
+)
+
+func TestBestRecord(t *testing.T) {
+	// newest first, as ListBacktestRecords returns them
+	records := []store.BacktestRecord{
+		{ID: 3, SharpeRatio: 1.5, MaxDrawdown: 0.2, OverallScore: 60},
+		{ID: 2, SharpeRatio: 1.5, MaxDrawdown: 0.1, OverallScore: 70},
+		{ID: 1, SharpeRatio: 0.5, MaxDrawdown: 0.3, OverallScore: 80},
+	}
+	for metric, want := range map[string]int64{
+		"overallScore": 1,
+		"sharpeRatio":  3, // tie goes to the newest
+		"maxDrawdown":  2, // minimized
+	} {
+		if got := bestRecord(records, metric); got.ID != want {
+			t.Errorf("%s: record %d, want %d", metric, got.ID, want)
+		}
+	}
+	if bestRecord(nil, "overallScore") != nil {
+		t.Error("best of no records")
+	}
+}
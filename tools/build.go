@@ -3,12 +3,11 @@ package tools
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
-	"github.com/ztrade/ztrade-mcp/store"
-	"github.com/ztrade/ztrade/pkg/ctl"
 )
 
 func registerBuildStrategy(s *server.MCPServer) {
@@ -16,32 +15,31 @@ func registerBuildStrategy(s *server.MCPServer) {
 		mcp.WithDescription("Compile a Go strategy source file (.go) into a plugin (.so) that can be used for backtesting and live trading."),
 		mcp.WithString("script", mcp.Required(), mcp.Description("Strategy source file path (.go)")),
 		mcp.WithString("output", mcp.Description("Output file path (.so). Default: same name with .so extension")),
+		mcp.WithString("buildTags", mcp.Description("Build tags for conditional compilation, separated by commas, e.g. 'prod,fast'")),
+		mcp.WithString("ldflags", mcp.Description("Linker flags; only -s, -w and -X importpath.name=value (plain value, e.g. for version stamping) are accepted")),
 	)
 
 	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		script := req.GetString("script", "")
 		output := req.GetString("output", "")
+		flags, err := parsePluginBuildFlags(req.GetString("buildTags", ""), req.GetString("ldflags", ""))
+		if err != nil {
+			return toolError(codeInvalidArgument, err.Error()), nil
+		}
 
 		// --- 支持从数据库查找策略 ---
 		var goPath string
 		var soPath string
 		st := getStoreFromContext(ctx)
 		if st != nil && script != "" && (isLikelyID(script) || isLikelyName(script)) {
-			var s *store.Script
-			var err error
-			if isLikelyID(script) {
-				id, _ := parseID(script)
-				s, err = st.GetScript(id)
-			} else {
-				s, err = st.GetScriptByName(script)
-			}
+			s, err := accessibleScriptRef(ctx, st, script, false)
 			if err != nil {
-				return mcp.NewToolResultError("strategy not found: " + err.Error()), nil
+				return toolErrorf(storeErrorCode(err), "strategy not found: %s", err.Error()), nil
 			}
 			goPath = fmt.Sprintf("/tmp/ztrade_plugins/%s_v%d.go", s.Name, s.Version)
 			soPath = fmt.Sprintf("/tmp/ztrade_plugins/%s_v%d.so", s.Name, s.Version)
 			if err := writeFile(goPath, s.Content); err != nil {
-				return mcp.NewToolResultError("failed to write temp go file: " + err.Error()), nil
+				return toolError(codeInternal, "failed to write temp go file: "+err.Error()), nil
 			}
 			script = goPath
 			if output == "" {
@@ -49,10 +47,11 @@ func registerBuildStrategy(s *server.MCPServer) {
 			}
 		}
 
-		builder := ctl.NewBuilder(script, output)
-		err := builder.Build()
-		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("build failed: %s", err.Error())), nil
+		if err := buildPlugin(script, output, flags); err != nil {
+			if errors.Is(err, errDisallowedImports) {
+				return toolError(codeInvalidArgument, err.Error()), nil
+			}
+			return toolErrorf(codeBuildFailed, "build failed: %s", err.Error()), nil
 		}
 
 		result := map[string]interface{}{
@@ -60,6 +59,12 @@ func registerBuildStrategy(s *server.MCPServer) {
 			"script": script,
 			"output": output,
 		}
+		if len(flags.Tags) > 0 {
+			result["buildTags"] = flags.Tags
+		}
+		if len(flags.LDFlags) > 0 {
+			result["ldflags"] = flags.LDFlags
+		}
 		if output == "" {
 			result["output"] = script[:len(script)-3] + ".so"
 		}
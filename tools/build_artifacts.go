@@ -0,0 +1,212 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	log "github.com/sirupsen/logrus"
+)
+
+// buildArtifactPatterns match the files strategy builds leave behind: the
+// plugin cache of backtests, trades and validation, and the sources and
+// plugins of run_backtest_managed.
+var buildArtifactPatterns = []string{
+	"/tmp/ztrade_plugins/*",
+	"/tmp/ztrade_script_*",
+}
+
+// defaultArtifactMaxAgeDays is how old an artifact must be before
+// cleanup_build_artifacts removes it when olderThanDays is not given.
+const defaultArtifactMaxAgeDays = 7
+
+type buildArtifact struct {
+	Path    string    `json:"path"`
+	Kind    string    `json:"kind"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"-"`
+	InUse   bool      `json:"inUse,omitempty"`
+}
+
+// artifactStem is the path of the plugin or source an artifact belongs to
+// without its extension, so x.go, x.so and x.so.sum share one stem.
+func artifactStem(path string) string {
+	path = strings.TrimSuffix(path, pluginSumSuffix)
+	return strings.TrimSuffix(path, filepath.Ext(path))
+}
+
+// artifactKind names an artifact by its extension: plugin, source, sum or
+// other.
+func artifactKind(path string) string {
+	switch {
+	case strings.HasSuffix(path, pluginSumSuffix):
+		return "sum"
+	case filepath.Ext(path) == ".so":
+		return "plugin"
+	case filepath.Ext(path) == ".go":
+		return "source"
+	}
+	return "other"
+}
+
+// scanBuildArtifacts lists the regular files matching patterns, newest
+// first. Files whose stem is in inUse are marked in use.
+func scanBuildArtifacts(patterns []string, inUse map[string]bool) ([]buildArtifact, error) {
+	var artifacts []buildArtifact
+	for _, pattern := range patterns {
+		paths, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range paths {
+			fi, err := os.Lstat(p)
+			if err != nil || !fi.Mode().IsRegular() {
+				continue
+			}
+			artifacts = append(artifacts, buildArtifact{
+				Path:    p,
+				Kind:    artifactKind(p),
+				Size:    fi.Size(),
+				ModTime: fi.ModTime(),
+				InUse:   inUse[artifactStem(p)],
+			})
+		}
+	}
+	sort.Slice(artifacts, func(i, j int) bool { return artifacts[i].ModTime.After(artifacts[j].ModTime) })
+	return artifacts, nil
+}
+
+// inUseArtifactStems returns the stems of the plugins running trades were
+// started from.
+func (m *tradeManager) inUseArtifactStems() map[string]bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	stems := make(map[string]bool, len(m.trades))
+	for _, inst := range m.trades {
+		stems[artifactStem(inst.Script)] = true
+	}
+	return stems
+}
+
+// removeBuildArtifacts deletes the artifacts last modified before cutoff
+// that are not in use, or only reports them when dryRun is set. It holds
+// the build lock so a plugin being built is not removed under the
+// compiler. It returns the removed artifacts and the paths that failed.
+func removeBuildArtifacts(artifacts []buildArtifact, cutoff time.Time, dryRun bool) (removed []buildArtifact, failed map[string]string) {
+	buildMu.Lock()
+	defer buildMu.Unlock()
+	for _, a := range artifacts {
+		if a.InUse || !a.ModTime.Before(cutoff) {
+			continue
+		}
+		if !dryRun {
+			if err := os.Remove(a.Path); err != nil && !os.IsNotExist(err) {
+				if failed == nil {
+					failed = make(map[string]string)
+				}
+				failed[a.Path] = err.Error()
+				continue
+			}
+		}
+		removed = append(removed, a)
+	}
+	return removed, failed
+}
+
+func artifactEntries(artifacts []buildArtifact) ([]map[string]interface{}, int64) {
+	entries := make([]map[string]interface{}, 0, len(artifacts))
+	var total int64
+	for _, a := range artifacts {
+		total += a.Size
+		e := map[string]interface{}{
+			"path":    a.Path,
+			"kind":    a.Kind,
+			"size":    a.Size,
+			"modTime": a.ModTime.Format("2006-01-02 15:04:05"),
+		}
+		if a.InUse {
+			e["inUse"] = true
+		}
+		entries = append(entries, e)
+	}
+	return entries, total
+}
+
+func registerListBuildArtifacts(s *server.MCPServer) {
+	tool := mcp.NewTool("list_build_artifacts",
+		mcp.WithDescription("List the files strategy builds leave in /tmp/ztrade_plugins and /tmp/ztrade_script_*: plugins (.so), sources (.go) and build hashes (.sum), newest first, with size, modification time and whether a running trade uses them, plus totals. Use cleanup_build_artifacts to remove stale ones."),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		artifacts, err := scanBuildArtifacts(buildArtifactPatterns, manager.inUseArtifactStems())
+		if err != nil {
+			return toolErrorf(codeInternal, "failed to list build artifacts: %s", err.Error()), nil
+		}
+		entries, total := artifactEntries(artifacts)
+		byKind := map[string]int{}
+		for _, a := range artifacts {
+			byKind[a.Kind]++
+		}
+		result := map[string]interface{}{
+			"count":      len(artifacts),
+			"totalBytes": total,
+			"byKind":     byKind,
+			"artifacts":  entries,
+		}
+		data, _ := json.MarshalIndent(result, "", "  ")
+		return mcp.NewToolResultText(string(data)), nil
+	})
+}
+
+func registerCleanupBuildArtifacts(s *server.MCPServer) {
+	tool := mcp.NewTool("cleanup_build_artifacts",
+		mcp.WithDescription("Remove build artifacts (see list_build_artifacts) last modified more than olderThanDays ago. Plugins of running trades and their sources are kept. Removed plugins are rebuilt from the stored strategy on their next use."),
+		mcp.WithNumber("olderThanDays", mcp.Description("Only remove artifacts last modified more than this many days ago (fractions allowed). Default: 7")),
+		mcp.WithBoolean("dryRun", mcp.Description("Only list what would be removed. Default: false")),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		days := req.GetFloat("olderThanDays", defaultArtifactMaxAgeDays)
+		dryRun := req.GetBool("dryRun", false)
+		if days < 0 {
+			return toolError(codeInvalidArgument, "olderThanDays must not be negative"), nil
+		}
+		cutoff := time.Now().Add(-time.Duration(days * float64(24*time.Hour)))
+
+		artifacts, err := scanBuildArtifacts(buildArtifactPatterns, manager.inUseArtifactStems())
+		if err != nil {
+			return toolErrorf(codeInternal, "failed to list build artifacts: %s", err.Error()), nil
+		}
+		removed, failed := removeBuildArtifacts(artifacts, cutoff, dryRun)
+		entries, freed := artifactEntries(removed)
+		kept := 0
+		for _, a := range artifacts {
+			if a.InUse && a.ModTime.Before(cutoff) {
+				kept++
+			}
+		}
+		result := map[string]interface{}{
+			"dryRun":      dryRun,
+			"cutoff":      cutoff.Format("2006-01-02 15:04:05"),
+			"scanned":     len(artifacts),
+			"removed":     len(removed),
+			"freedBytes":  freed,
+			"keptInUse":   kept,
+			"removedList": entries,
+		}
+		if len(failed) > 0 {
+			result["failed"] = failed
+		}
+		if !dryRun && len(removed) > 0 {
+			log.WithContext(ctx).Infof("removed %d build artifacts (%d bytes) older than %s", len(removed), freed, cutoff.Format("2006-01-02 15:04:05"))
+		}
+		data, _ := json.MarshalIndent(result, "", "  ")
+		return mcp.NewToolResultText(string(data)), nil
+	})
+}
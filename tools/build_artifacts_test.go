@@ -0,0 +1,74 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBuildArtifactsCleanup(t *testing.T) {
+	dir := t.TempDir()
+	old := time.Now().Add(-10 * 24 * time.Hour)
+	write := func(name string, mtime time.Time) string {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(p, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	stale := write("stale_v1.so", old)
+	staleSum := write("stale_v1.so.sum", old)
+	liveSrc := write("live_v2.go", old)
+	liveSo := write("live_v2.so", old)
+	fresh := write("fresh_v1.so", time.Now())
+	if err := os.Mkdir(filepath.Join(dir, "subdir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	inUse := map[string]bool{artifactStem(liveSo): true}
+	artifacts, err := scanBuildArtifacts([]string{filepath.Join(dir, "*")}, inUse)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(artifacts) != 5 {
+		t.Fatalf("scanned %d artifacts, want 5: %+v", len(artifacts), artifacts)
+	}
+	if artifacts[0].Path != fresh {
+		t.Fatalf("newest first: got %s", artifacts[0].Path)
+	}
+	kinds := map[string]string{}
+	for _, a := range artifacts {
+		kinds[a.Path] = a.Kind
+	}
+	if kinds[staleSum] != "sum" || kinds[liveSrc] != "source" || kinds[stale] != "plugin" {
+		t.Fatalf("kinds = %v", kinds)
+	}
+
+	cutoff := time.Now().Add(-7 * 24 * time.Hour)
+	removed, failed := removeBuildArtifacts(artifacts, cutoff, true)
+	if len(removed) != 2 || len(failed) != 0 {
+		t.Fatalf("dry run removed %+v, failed %v", removed, failed)
+	}
+	if _, err := os.Stat(stale); err != nil {
+		t.Fatalf("dry run deleted %s", stale)
+	}
+
+	removed, failed = removeBuildArtifacts(artifacts, cutoff, false)
+	if len(removed) != 2 || len(failed) != 0 {
+		t.Fatalf("removed %+v, failed %v", removed, failed)
+	}
+	for _, p := range []string{stale, staleSum} {
+		if _, err := os.Stat(p); !os.IsNotExist(err) {
+			t.Fatalf("%s not removed", p)
+		}
+	}
+	for _, p := range []string{liveSrc, liveSo, fresh} {
+		if _, err := os.Stat(p); err != nil {
+			t.Fatalf("%s removed: %v", p, err)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/ztrade/ztrade/pkg/ctl"
+)
+
+var (
+	buildTagRe = regexp.MustCompile(`^[A-Za-z0-9_.]+$`)
+	// -X importpath.name=value; the value is limited to characters that need
+	// no quoting so it can be passed through GOFLAGS unchanged.
+	ldflagsXRe = regexp.MustCompile(`^[A-Za-z0-9_./-]+\.[A-Za-z0-9_]+=[A-Za-z0-9_.:+@-]*$`)
+
+	// buildMu serializes plugin builds: extra flags reach the go command
+	// through the process-wide GOFLAGS variable.
+	buildMu sync.Mutex
+)
+
+// pluginBuildFlags are extra go build flags for a strategy plugin.
+type pluginBuildFlags struct {
+	Tags    []string
+	LDFlags []string
+}
+
+// parsePluginBuildFlags validates the buildTags and ldflags arguments of the
+// build tools. Tags are identifiers separated by commas or spaces; ldflags
+// accept only -s, -w and -X importpath.name=value, so nothing reaching the
+// linker can run external commands.
+func parsePluginBuildFlags(tags, ldflags string) (pluginBuildFlags, error) {
+	var f pluginBuildFlags
+	for _, tag := range strings.FieldsFunc(tags, func(r rune) bool { return r == ',' || r == ' ' }) {
+		if !buildTagRe.MatchString(tag) {
+			return f, fmt.Errorf("invalid build tag %q: only letters, digits, '_' and '.' are allowed", tag)
+		}
+		f.Tags = append(f.Tags, tag)
+	}
+	words := strings.Fields(ldflags)
+	for i := 0; i < len(words); i++ {
+		switch w := words[i]; {
+		case w == "-s" || w == "-w":
+			f.LDFlags = append(f.LDFlags, w)
+		case w == "-X":
+			if i+1 == len(words) || !ldflagsXRe.MatchString(words[i+1]) {
+				return f, fmt.Errorf("-X needs importpath.name=value with a plain value")
+			}
+			i++
+			f.LDFlags = append(f.LDFlags, "-X="+words[i])
+		case strings.HasPrefix(w, "-X="):
+			if !ldflagsXRe.MatchString(w[3:]) {
+				return f, fmt.Errorf("invalid ldflag %q: -X needs importpath.name=value with a plain value", w)
+			}
+			f.LDFlags = append(f.LDFlags, w)
+		default:
+			return f, fmt.Errorf("unsupported ldflag %q, allowed: -s, -w, -X importpath.name=value", w)
+		}
+	}
+	return f, nil
+}
+
+// goflags renders the flags as a GOFLAGS value.
+func (f pluginBuildFlags) goflags() string {
+	var parts []string
+	if len(f.Tags) > 0 {
+		parts = append(parts, "-tags="+strings.Join(f.Tags, ","))
+	}
+	if len(f.LDFlags) > 0 {
+		parts = append(parts, `"-ldflags=`+strings.Join(f.LDFlags, " ")+`"`)
+	}
+	return strings.Join(parts, " ")
+}
+
+// buildPlugin compiles a strategy source into a plugin with ctl.Builder,
+// after checking its imports against the allowlist (see
+// checkStrategyImports), so no path loads unchecked code into the server,
+// and its Merge timeframes with the parameter defaults (see
+// mergeTimeframeIssues). ctl.Builder runs go build without extra flags;
+// flags are added to GOFLAGS for the duration of the build. An existing plugin built from the same source
+// and flags (see pluginKey) is reused; any other is rebuilt, so a changed
+// source never runs as stale code under the same name and version.
+func buildPlugin(src, output string, flags pluginBuildFlags) error {
+	if output == "" {
+		output = strings.Replace(src, ".go", ".so", 1)
+	}
+	buildMu.Lock()
+	defer buildMu.Unlock()
+
+	content, err := os.ReadFile(src)
+	if err != nil {
+		return fmt.Errorf("read strategy source: %w", err)
+	}
+	if err := checkStrategyImports(buildConfig, string(content)); err != nil {
+		return err
+	}
+	if err := checkMergeTimeframes(string(content), nil); err != nil {
+		return err
+	}
+	key := pluginKey(content, flags)
+	if pluginUpToDate(output, key) {
+		log.WithField("plugin", output).Debug("plugin is up to date, skipping build")
+		return nil
+	}
+	os.Remove(output + pluginSumSuffix)
+
+	if extra := flags.goflags(); extra != "" {
+		old, had := os.LookupEnv("GOFLAGS")
+		os.Setenv("GOFLAGS", strings.TrimSpace(old+" "+extra))
+		defer func() {
+			if had {
+				os.Setenv("GOFLAGS", old)
+			} else {
+				os.Unsetenv("GOFLAGS")
+			}
+		}()
+	}
+	if err := ctl.NewBuilder(src, output).Build(); err != nil {
+		return err
+	}
+	if err := os.WriteFile(output+pluginSumSuffix, []byte(key), 0644); err != nil {
+		log.WithError(err).WithField("plugin", output).Warn("failed to record plugin source hash")
+	}
+	return nil
+}
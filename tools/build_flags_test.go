@@ -0,0 +1,33 @@
+package tools
+
+import "testing"
+
+func TestParsePluginBuildFlags(t *testing.T) {
+	f, err := parsePluginBuildFlags("prod, fast", "-s -w -X main.version=v1.2.0 -X=github.com/a/b.commit=abc123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Tags) != 2 || f.Tags[1] != "fast" || len(f.LDFlags) != 4 || f.LDFlags[2] != "-X=main.version=v1.2.0" {
+		t.Fatalf("flags: %+v", f)
+	}
+	want := `-tags=prod,fast "-ldflags=-s -w -X=main.version=v1.2.0 -X=github.com/a/b.commit=abc123"`
+	if got := f.goflags(); got != want {
+		t.Errorf("goflags = %s, want %s", got, want)
+	}
+	if f, _ := parsePluginBuildFlags("", ""); f.goflags() != "" {
+		t.Errorf("empty flags: %q", f.goflags())
+	}
+
+	for _, bad := range [][2]string{
+		{"a;rm -rf /", ""},
+		{"", "-extldflags=-Wl,foo"},
+		{"", "-linkmode external"},
+		{"", "-X main.version=$(id)"},
+		{"", "-X"},
+		{"", `-X=main.v="x"`},
+	} {
+		if _, err := parsePluginBuildFlags(bad[0], bad[1]); err == nil {
+			t.Errorf("accepted tags %q ldflags %q", bad[0], bad[1])
+		}
+	}
+}
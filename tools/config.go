@@ -0,0 +1,128 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/spf13/viper"
+)
+
+const redactedValue = "***"
+
+// secretKeyWords mark config keys whose scalar values must never be shown.
+var secretKeyWords = []string{"secret", "key", "token", "password", "passphrase", "hash"}
+
+// dsnPasswordRe matches the password part of user:password@ style DSNs/URLs,
+// after an optional scheme. The password runs to the last @, as in the
+// mysql driver, so one holding @ or / is masked whole.
+var dsnPasswordRe = regexp.MustCompile(`((?:[A-Za-z][A-Za-z0-9+.\-]*://)?[A-Za-z0-9_.\-]+:)(\S+)(@)`)
+
+// dsnParamPasswordRe matches password parameters of DSNs, in a query
+// string (?password=, &passwd=, &_auth_passwd=) or key=value form.
+var dsnParamPasswordRe = regexp.MustCompile(`(?i)((?:^|[?&;\s])\w*(?:password|passwd|pwd)=)([^&;\s]*)`)
+
+// maskDSN masks the passwords embedded in s.
+func maskDSN(s string) string {
+	s = dsnPasswordRe.ReplaceAllString(s, "${1}"+redactedValue+"${3}")
+	return dsnParamPasswordRe.ReplaceAllString(s, "${1}"+redactedValue)
+}
+
+func isSecretConfigKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, w := range secretKeyWords {
+		if strings.Contains(key, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactConfig returns a copy of v with secret values replaced by "***".
+// Maps and lists are walked recursively so non-secret siblings stay visible;
+// passwords embedded in DSNs/URLs are masked as well.
+func redactConfig(key string, v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(t))
+		for k, sub := range t {
+			out[k] = redactConfig(k, sub)
+		}
+		return out
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(t))
+		for k, sub := range t {
+			ks, _ := k.(string)
+			out[ks] = redactConfig(ks, sub)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(t))
+		for i, sub := range t {
+			out[i] = redactConfig(key, sub)
+		}
+		return out
+	case []map[string]interface{}:
+		out := make([]interface{}, len(t))
+		for i, sub := range t {
+			out[i] = redactConfig(key, sub)
+		}
+		return out
+	case nil:
+		return nil
+	default:
+		if isSecretConfigKey(key) {
+			if s, ok := t.(string); ok && s == "" {
+				return s
+			}
+			return redactedValue
+		}
+		if s, ok := t.(string); ok {
+			return maskDSN(s)
+		}
+		return t
+	}
+}
+
+func registerListConfig(s *server.MCPServer, cfg *viper.Viper) {
+	tool := mcp.NewTool("list_config",
+		mcp.WithDescription("Show the effective configuration loaded by the server (merged from config file, defaults and environment) with secrets redacted, plus the config file in use. Admin only."),
+		mcp.WithString("prefix", mcp.Description("Only show keys under this prefix, e.g. 'db' or 'exchanges.binance'. Default: all")),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		prefix := strings.ToLower(strings.TrimSpace(req.GetString("prefix", "")))
+
+		settings := exchangeConfig(cfg).AllSettings()
+		var effective interface{} = redactConfig("", settings)
+		if prefix != "" {
+			var node interface{} = effective
+			for _, part := range strings.Split(prefix, ".") {
+				m, ok := node.(map[string]interface{})
+				if !ok {
+					node = nil
+					break
+				}
+				node = m[part]
+			}
+			if node == nil {
+				return toolError(codeNotFound, "no config found under prefix "+prefix), nil
+			}
+			effective = node
+		}
+
+		configFile := cfg.ConfigFileUsed()
+		result := map[string]interface{}{
+			"configFile": configFile,
+			"config":     effective,
+		}
+		if configFile == "" {
+			result["note"] = "no config file loaded; values come from defaults and environment only"
+		}
+		data, _ := json.MarshalIndent(result, "", "  ")
+		return mcp.NewToolResultText(string(data)), nil
+	})
+}
@@ -0,0 +1,62 @@
+package tools
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRedactConfig(t *testing.T) {
+	in := map[string]interface{}{
+		"db": map[string]interface{}{
+			"type": "mysql",
+			"uri":  "root:s3cret@tcp(mysql:3306)/exchange?parseTime=True",
+		},
+		"exchanges": map[string]interface{}{
+			"binance": map[string]interface{}{"type": "binance_futures", "key": "abc", "secret": "def", "timeout": "10s"},
+		},
+		"mcp": map[string]interface{}{
+			"auth": map[string]interface{}{
+				"tokens": []interface{}{
+					map[string]interface{}{"hash": "sha256:00", "name": "admin", "role": "admin"},
+				},
+			},
+		},
+		"pyrunner": map[string]interface{}{"token": ""},
+	}
+	want := map[string]interface{}{
+		"db": map[string]interface{}{
+			"type": "mysql",
+			"uri":  "root:***@tcp(mysql:3306)/exchange?parseTime=True",
+		},
+		"exchanges": map[string]interface{}{
+			"binance": map[string]interface{}{"type": "binance_futures", "key": "***", "secret": "***", "timeout": "10s"},
+		},
+		"mcp": map[string]interface{}{
+			"auth": map[string]interface{}{
+				"tokens": []interface{}{
+					map[string]interface{}{"hash": "***", "name": "admin", "role": "admin"},
+				},
+			},
+		},
+		"pyrunner": map[string]interface{}{"token": ""},
+	}
+	if got := redactConfig("", in); !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected redaction:\n got %#v\nwant %#v", got, want)
+	}
+}
+
+func TestMaskDSN(t *testing.T) {
+	for in, want := range map[string]string{
+		"root:p@ss/w0rd@tcp(mysql:3306)/exchange?parseTime=True": "root:***@tcp(mysql:3306)/exchange?parseTime=True",
+		"postgres://admin:a/b@c@db:5432/ztrade":                  "postgres://admin:***@db:5432/ztrade",
+		"tcp(mysql:3306)/exchange?user=root&password=s3cret&x=1": "tcp(mysql:3306)/exchange?user=root&password=***&x=1",
+		"file:data.db?_auth_user=a&_auth_passwd=s3cret":          "file:data.db?_auth_user=a&_auth_passwd=***",
+		"host=db user=ztrade password=s3cret dbname=ztrade":      "host=db user=ztrade password=*** dbname=ztrade",
+		"http://proxy:8080":                                      "http://proxy:8080",
+		"10s":                                                    "10s",
+	} {
+		if got := maskDSN(in); got != want {
+			t.Errorf("maskDSN(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/ztrade/trademodel"
+	"github.com/ztrade/ztrade-mcp/internal/stats"
+	"github.com/ztrade/ztrade/pkg/process/dbstore"
+)
+
+const (
+	correlationDefaultLimit = 5000
+	correlationMaxLimit     = 50000
+	// correlationMinReturns is the minimum number of aligned returns needed
+	// before a correlation is considered meaningful.
+	correlationMinReturns = 30
+)
+
+// alignCloses returns the close prices of a and b at the timestamps present
+// in both series, in time order. Both inputs must be sorted by time.
+func alignCloses(a, b []*trademodel.Candle) (xa, xb []float64) {
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i].Start < b[j].Start:
+			i++
+		case a[i].Start > b[j].Start:
+			j++
+		default:
+			xa = append(xa, a[i].Close)
+			xb = append(xb, b[j].Close)
+			i++
+			j++
+		}
+	}
+	return
+}
+
+func registerCorrelationMatrix(s *server.MCPServer, db *dbstore.DBStore) {
+	tool := mcp.NewTool("correlation_matrix",
+		mcp.WithDescription("Compute the pairwise Pearson correlation of close-price returns across symbols from the local database. "+
+			"Series are aligned on candle timestamps; timestamps missing in either symbol are dropped per pair."),
+		mcp.WithString("exchange", mcp.Required(), mcp.Description("Exchange name e.g. binance, okx")),
+		mcp.WithString("symbols", mcp.Required(), mcp.Description("Comma-separated symbols, e.g. BTCUSDT,ETHUSDT,SOLUSDT")),
+		mcp.WithString("binSize", mcp.Description("K-line period 1m/5m/15m/1h/1d. Default: 1h")),
+		mcp.WithString("start", mcp.Required(), mcp.Description("Start time in format 2006-01-02 15:04:05")),
+		mcp.WithString("end", mcp.Required(), mcp.Description("End time in format 2006-01-02 15:04:05")),
+		mcp.WithNumber("limit", mcp.Description("Maximum candles loaded per symbol. Default: 5000, Max: 50000")),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if db == nil {
+			return toolError(codeUnavailable, "database not initialized"), nil
+		}
+
+		exchange := req.GetString("exchange", "")
+		binSize := strings.ToLower(strings.TrimSpace(req.GetString("binSize", "")))
+		if binSize == "" {
+			binSize = "1h"
+		}
+		limit := int(req.GetFloat("limit", 0))
+		if limit <= 0 {
+			limit = correlationDefaultLimit
+		}
+		if limit > correlationMaxLimit {
+			limit = correlationMaxLimit
+		}
+
+		var symbols []string
+		seen := map[string]bool{}
+		for _, sym := range strings.Split(req.GetString("symbols", ""), ",") {
+			sym = strings.TrimSpace(sym)
+			if sym == "" || seen[sym] {
+				continue
+			}
+			seen[sym] = true
+			symbols = append(symbols, sym)
+		}
+		if len(symbols) < 2 {
+			return toolError(codeInvalidArgument, "at least two distinct symbols are required"), nil
+		}
+
+		start, err := time.Parse("2006-01-02 15:04:05", req.GetString("start", ""))
+		if err != nil {
+			return toolErrorf(codeInvalidArgument, "invalid start time: %s", err.Error()), nil
+		}
+		end, err := time.Parse("2006-01-02 15:04:05", req.GetString("end", ""))
+		if err != nil {
+			return toolErrorf(codeInvalidArgument, "invalid end time: %s", err.Error()), nil
+		}
+		if !start.Before(end) {
+			return toolError(codeInvalidArgument, "start must be before end"), nil
+		}
+
+		series := make([][]*trademodel.Candle, len(symbols))
+		counts := make(map[string]int, len(symbols))
+		var warnings []string
+		for i, sym := range symbols {
+			candles, _, _, err := loadCandles(db, exchange, sym, binSize, start, end, limit, false)
+			if err != nil {
+				return toolErrorf(codeInternal, "%s: %s", sym, err.Error()), nil
+			}
+			series[i] = candles
+			counts[sym] = len(candles)
+			if len(candles) <= correlationMinReturns {
+				warnings = append(warnings, fmt.Sprintf("%s has insufficient data (%d candles)", sym, len(candles)))
+			}
+		}
+
+		n := len(symbols)
+		matrix := make([][]*float64, n)
+		samples := make([][]int, n)
+		for i := range matrix {
+			matrix[i] = make([]*float64, n)
+			samples[i] = make([]int, n)
+			one := 1.0
+			matrix[i][i] = &one
+			samples[i][i] = counts[symbols[i]]
+		}
+		for i := 0; i < n; i++ {
+			for j := i + 1; j < n; j++ {
+				xa, xb := alignCloses(series[i], series[j])
+				ra, rb := stats.Returns(xa), stats.Returns(xb)
+				samples[i][j], samples[j][i] = len(ra), len(ra)
+				if len(ra) != len(rb) || len(ra) < correlationMinReturns {
+					warnings = append(warnings, fmt.Sprintf("%s/%s: only %d aligned returns, correlation omitted", symbols[i], symbols[j], len(ra)))
+					continue
+				}
+				r, ok := stats.Pearson(ra, rb)
+				if !ok {
+					warnings = append(warnings, fmt.Sprintf("%s/%s: correlation undefined (constant series)", symbols[i], symbols[j]))
+					continue
+				}
+				matrix[i][j], matrix[j][i] = &r, &r
+			}
+		}
+
+		result := map[string]interface{}{
+			"exchange": exchange,
+			"binSize":  binSize,
+			"start":    start.Format("2006-01-02 15:04:05"),
+			"end":      end.Format("2006-01-02 15:04:05"),
+			"symbols":  symbols,
+			"candles":  counts,
+			"matrix":   matrix,
+			"samples":  samples,
+		}
+		if len(warnings) > 0 {
+			result["warnings"] = warnings
+		}
+		data, _ := json.MarshalIndent(result, "", "  ")
+		return mcp.NewToolResultText(string(data)), nil
+	})
+}
@@ -0,0 +1,20 @@
+package tools
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ztrade/trademodel"
+)
+
+func TestAlignCloses(t *testing.T) {
+	a := []*trademodel.Candle{{Start: 60, Close: 1}, {Start: 120, Close: 2}, {Start: 180, Close: 3}, {Start: 300, Close: 5}}
+	b := []*trademodel.Candle{{Start: 0, Close: 10}, {Start: 120, Close: 20}, {Start: 240, Close: 40}, {Start: 300, Close: 50}}
+	xa, xb := alignCloses(a, b)
+	if !reflect.DeepEqual(xa, []float64{2, 5}) || !reflect.DeepEqual(xb, []float64{20, 50}) {
+		t.Fatalf("unexpected alignment: %v %v", xa, xb)
+	}
+	if xa, xb := alignCloses(a, nil); xa != nil || xb != nil {
+		t.Fatalf("expected empty alignment")
+	}
+}
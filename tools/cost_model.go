@@ -0,0 +1,179 @@
+package tools
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/spf13/viper"
+)
+
+// TradingCostModel holds the trading costs a backtest charges. Tools build it
+// once from their arguments with costModelFromRequest and pass it to
+// runBacktestCore / executeBacktest.
+type TradingCostModel struct {
+	// TakerFee is the commission rate the virtual exchange charges on every
+	// fill; it has a single rate.
+	TakerFee float64
+	// MakerFee applies to resting limit orders filled under the limit order
+	// model. The difference to TakerFee is applied to the fill price.
+	MakerFee float64
+	// Slippage moves the price of orders at or through the market against
+	// the trader, as a fraction of the price.
+	Slippage float64
+	Lever    float64
+	// MinNotional rejects opening orders worth less than this in quote
+	// currency. Closing orders are exempt, like reduce-only orders.
+	MinNotional float64
+	// AmountStep is the lot size order amounts are rounded down to.
+	AmountStep float64
+	// IncludeFunding charges historical funding rates on open positions;
+	// only run_backtest_managed supports it.
+	IncludeFunding bool
+}
+
+// NewDefaultCostModel returns the costs used when neither the tool
+// arguments nor the config give any: 0.05% commission for makers and
+// takers, no slippage, leverage 1.
+func NewDefaultCostModel() TradingCostModel {
+	return TradingCostModel{TakerFee: 0.0005, MakerFee: 0.0005, Lever: 1}
+}
+
+// defaultBacktestBalance is the initial balance when neither the tool
+// arguments nor the config give one.
+const defaultBacktestBalance = 100000
+
+// backtestDefaults returns the initial balance and cost model a backtest on
+// exchangeName starts from. mcp.backtest.defaults.{balance,fee,makerFee,
+// slippage,lever} override the built-in defaults, and
+// mcp.backtest.defaults.exchanges.<exchange>.* override those.
+func backtestDefaults(cfg *viper.Viper, exchangeName string) (float64, TradingCostModel) {
+	balance, m := float64(defaultBacktestBalance), NewDefaultCostModel()
+	if cfg == nil {
+		return balance, m
+	}
+	prefixes := []string{"mcp.backtest.defaults"}
+	if exchangeName != "" {
+		prefixes = append(prefixes, "mcp.backtest.defaults.exchanges."+strings.ToLower(exchangeName))
+	}
+	for _, prefix := range prefixes {
+		if v := cfg.GetFloat64(prefix + ".balance"); v > 0 {
+			balance = v
+		}
+		if v := cfg.GetFloat64(prefix + ".fee"); v > 0 {
+			if m.MakerFee == m.TakerFee {
+				m.MakerFee = v
+			}
+			m.TakerFee = v
+		}
+		if cfg.IsSet(prefix + ".makerFee") {
+			m.MakerFee = cfg.GetFloat64(prefix + ".makerFee")
+		}
+		if cfg.IsSet(prefix + ".slippage") {
+			m.Slippage = cfg.GetFloat64(prefix + ".slippage")
+		}
+		if v := cfg.GetFloat64(prefix + ".lever"); v > 0 {
+			m.Lever = v
+		}
+	}
+	return balance, m
+}
+
+// costModelOptions declares the tool arguments read by costModelFromRequest.
+func costModelOptions() []mcp.ToolOption {
+	return []mcp.ToolOption{
+		mcp.WithNumber("fee", mcp.Description("Trading fee rate (taker). Default: mcp.backtest.defaults for the exchange, else 0.0005")),
+		mcp.WithNumber("makerFee", mcp.Description("Fee rate for resting limit orders filled under limitOrderModel, may be negative for a rebate. Default: the configured makerFee, else same as fee")),
+		mcp.WithNumber("slippage", mcp.Description("Adverse price slippage for orders at or through the market, as a fraction of price (e.g. 0.0005 = 5 bps). Fills stay within the candle's range. Default: configured, else 0")),
+		mcp.WithNumber("lever", mcp.Description("Leverage multiplier. Default: configured, else 1")),
+		mcp.WithNumber("minNotional", mcp.Description("Reject opening orders whose amount x price is below this value in quote currency, as the exchange would. Default: 0 (no minimum)")),
+		mcp.WithNumber("lotSize", mcp.Description("Round order amounts down to a multiple of this step; orders rounded to zero are rejected. Overrides the step loaded by symbolRules. Default: 0 (no rounding)")),
+		mcp.WithBoolean("symbolRules", mcp.Description("Load the symbol's amount step (as shown by list_symbols) from the exchange at backtest start and use it as lotSize. Requires the exchange to be configured. Default: false")),
+	}
+}
+
+// costModelFromRequest builds the cost model from fee, makerFee, slippage,
+// lever, minNotional, lotSize and includeFunding on top of base, usually
+// from backtestDefaults. The maker fee follows fee unless base sets its own.
+// symbolRules is applied by loadSymbolRules.
+func costModelFromRequest(req mcp.CallToolRequest, base TradingCostModel) (TradingCostModel, error) {
+	m := base
+	if v := req.GetFloat("fee", 0); v > 0 {
+		if m.MakerFee == m.TakerFee {
+			m.MakerFee = v
+		}
+		m.TakerFee = v
+	}
+	if req.GetArguments()["makerFee"] != nil {
+		m.MakerFee = req.GetFloat("makerFee", 0)
+	}
+	m.Slippage = req.GetFloat("slippage", m.Slippage)
+	if v := req.GetFloat("lever", 0); v > 0 {
+		m.Lever = v
+	}
+	m.MinNotional = req.GetFloat("minNotional", 0)
+	m.AmountStep = req.GetFloat("lotSize", 0)
+	m.IncludeFunding = req.GetBool("includeFunding", false)
+
+	switch {
+	case m.TakerFee >= 0.1:
+		return m, fmt.Errorf("fee %g is not a rate (use e.g. 0.0005 for 0.05%%)", m.TakerFee)
+	case m.MakerFee <= -0.01 || m.MakerFee >= 0.1:
+		return m, fmt.Errorf("makerFee %g out of range (-0.01, 0.1)", m.MakerFee)
+	case m.Slippage < 0 || m.Slippage >= 0.1:
+		return m, fmt.Errorf("slippage %g out of range [0, 0.1)", m.Slippage)
+	case m.MinNotional < 0 || m.AmountStep < 0:
+		return m, fmt.Errorf("minNotional and lotSize must not be negative")
+	}
+	return m, nil
+}
+
+// adjustsFills reports whether the model changes fill prices or order
+// amounts, which needs the order gate in the backtest pipeline.
+func (m TradingCostModel) adjustsFills() bool {
+	return m.Slippage > 0 || m.MakerFee != m.TakerFee || m.checksOrders()
+}
+
+// checksOrders reports whether orders are checked against min notional or
+// lot size.
+func (m TradingCostModel) checksOrders() bool {
+	return m.MinNotional > 0 || m.AmountStep > 0
+}
+
+// fitAmount rounds amount down to the lot size and reports whether the
+// order is still accepted: it must not round to zero and, when it opens a
+// position, must be worth at least MinNotional at price.
+func (m TradingCostModel) fitAmount(amount, price float64, open bool) (float64, bool) {
+	if m.AmountStep > 0 {
+		// the epsilon keeps amounts already on the step from rounding down
+		amount = math.Floor(amount/m.AmountStep+1e-9) * m.AmountStep
+	}
+	if amount <= 0 {
+		return 0, false
+	}
+	if open && m.MinNotional > 0 && amount*price < m.MinNotional {
+		return amount, false
+	}
+	return amount, true
+}
+
+// takerPrice applies slippage to the price of an order at or through the
+// market.
+func (m TradingCostModel) takerPrice(price float64, buy bool) float64 {
+	if buy {
+		return price * (1 + m.Slippage)
+	}
+	return price * (1 - m.Slippage)
+}
+
+// makerPrice folds the maker/taker fee difference into the price of a
+// resting limit order filled on candle, kept within the candle's range so
+// the virtual exchange still fills it.
+func (m TradingCostModel) makerPrice(price float64, buy bool, low, high float64) float64 {
+	delta := m.MakerFee - m.TakerFee
+	if buy {
+		return math.Min(math.Max(price*(1+delta), low), high)
+	}
+	return math.Max(math.Min(price*(1-delta), high), low)
+}
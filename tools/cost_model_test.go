@@ -0,0 +1,177 @@
+package tools
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/spf13/viper"
+	"github.com/ztrade/trademodel"
+	"github.com/ztrade/ztrade/pkg/core"
+)
+
+func TestCostModelFromRequest(t *testing.T) {
+	cases := []struct {
+		name    string
+		args    map[string]any
+		want    TradingCostModel
+		wantErr bool
+	}{
+		{"defaults", nil, NewDefaultCostModel(), false},
+		{"non-positive fee and lever fall back", map[string]any{"fee": 0.0, "lever": -2.0}, NewDefaultCostModel(), false},
+		{"maker fee follows fee", map[string]any{"fee": 0.001, "lever": 3.0}, TradingCostModel{TakerFee: 0.001, MakerFee: 0.001, Lever: 3}, false},
+		{"maker rebate", map[string]any{"makerFee": -0.0001, "slippage": 0.0005}, TradingCostModel{TakerFee: 0.0005, MakerFee: -0.0001, Slippage: 0.0005, Lever: 1}, false},
+		{"funding", map[string]any{"includeFunding": true}, TradingCostModel{TakerFee: 0.0005, MakerFee: 0.0005, Lever: 1, IncludeFunding: true}, false},
+		{"fee as percent", map[string]any{"fee": 0.5}, TradingCostModel{}, true},
+		{"maker fee out of range", map[string]any{"makerFee": -0.05}, TradingCostModel{}, true},
+		{"negative slippage", map[string]any{"slippage": -0.001}, TradingCostModel{}, true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var req mcp.CallToolRequest
+			req.Params.Arguments = c.args
+			got, err := costModelFromRequest(req, NewDefaultCostModel())
+			if c.wantErr {
+				if err == nil {
+					t.Fatalf("costModelFromRequest(%v) = %+v, want error", c.args, got)
+				}
+				return
+			}
+			if err != nil || got != c.want {
+				t.Fatalf("costModelFromRequest(%v) = %+v, %v, want %+v", c.args, got, err, c.want)
+			}
+		})
+	}
+}
+
+func TestCostModelPrices(t *testing.T) {
+	m := TradingCostModel{TakerFee: 0.001, MakerFee: -0.001, Slippage: 0.01}
+	cases := []struct {
+		name string
+		got  float64
+		want float64
+	}{
+		{"taker buy", m.takerPrice(100, true), 101},
+		{"taker sell", m.takerPrice(100, false), 99},
+		{"maker buy rebate", m.makerPrice(100, true, 99, 101), 99.8},
+		{"maker sell rebate", m.makerPrice(100, false, 99, 101), 100.2},
+		{"maker buy clamped to low", m.makerPrice(99.1, true, 99, 101), 99},
+		{"maker sell clamped to high", m.makerPrice(100.9, false, 99, 101), 101},
+	}
+	for _, c := range cases {
+		if math.Abs(c.got-c.want) > 1e-9 {
+			t.Errorf("%s = %v, want %v", c.name, c.got, c.want)
+		}
+	}
+	if NewDefaultCostModel().adjustsFills() {
+		t.Error("default cost model adjusts fills")
+	}
+}
+
+func TestOrderGateSlippage(t *testing.T) {
+	h := newGateHarness(t, backtestGate{})
+	h.gate.costs = TradingCostModel{TakerFee: 0.0005, MakerFee: 0.0005, Slippage: 0.001}
+	h.candle(100, 101, 99, 100)
+
+	// a market buy pays the slippage
+	h.order(trademodel.OpenLong, 100)
+	h.candle(100, 101, 99, 100)
+	if len(h.trades) != 1 || math.Abs(h.trades[0].Price-100.1) > 1e-9 {
+		t.Fatalf("market buy: %+v", h.trades)
+	}
+
+	// an order away from the market keeps its price
+	h.order(trademodel.CloseLong, 100.5)
+	h.candle(100, 101, 99, 100)
+	if len(h.trades) != 2 || h.trades[1].Price != 100.5 {
+		t.Fatalf("resting sell: %+v", h.trades)
+	}
+}
+
+func TestCostModelFitAmount(t *testing.T) {
+	m := TradingCostModel{MinNotional: 10, AmountStep: 0.001}
+	cases := []struct {
+		name   string
+		amount float64
+		price  float64
+		open   bool
+		want   float64
+		ok     bool
+	}{
+		{"on step", 0.5, 100, true, 0.5, true},
+		{"rounded down", 0.1237, 100, true, 0.123, true},
+		{"rounds to zero", 0.0004, 100, false, 0, false},
+		{"below min notional", 0.05, 100, true, 0.05, false},
+		{"close below min notional", 0.05, 100, false, 0.05, true},
+	}
+	for _, c := range cases {
+		got, ok := m.fitAmount(c.amount, c.price, c.open)
+		if ok != c.ok || math.Abs(got-c.want) > 1e-12 {
+			t.Errorf("%s: fitAmount(%v, %v, %v) = %v, %v, want %v, %v", c.name, c.amount, c.price, c.open, got, ok, c.want, c.ok)
+		}
+	}
+}
+
+func TestOrderGateSymbolRules(t *testing.T) {
+	h := newGateHarness(t, backtestGate{})
+	h.gate.costs = TradingCostModel{TakerFee: 0.0005, MakerFee: 0.0005, MinNotional: 50, AmountStep: 0.3}
+	h.candle(100, 101, 99, 100)
+
+	// 1 rounds down to 0.9, worth 90
+	h.order(trademodel.OpenLong, 100)
+	h.candle(100, 101, 99, 100)
+	if len(h.trades) != 1 || math.Abs(h.trades[0].Amount-0.9) > 1e-9 {
+		t.Fatalf("rounded order: %+v", h.trades)
+	}
+	h.src.Send("order", core.EventOrder, &trademodel.TradeAction{Action: trademodel.OpenLong, Amount: 0.3, Price: 100})
+	h.candle(100, 101, 99, 100)
+	if len(h.trades) != 1 || h.gate.stats.OrdersRejected != 1 || h.gate.stats.OrdersRounded != 1 {
+		t.Fatalf("below min notional: %d trades, stats %+v", len(h.trades), h.gate.stats)
+	}
+}
+
+func TestBacktestDefaults(t *testing.T) {
+	cfg := viper.New()
+	cfg.SetConfigType("yaml")
+	err := cfg.ReadConfig(strings.NewReader(`
+mcp:
+  backtest:
+    defaults:
+      balance: 5000
+      fee: 0.001
+      exchanges:
+        binance:
+          fee: 0.0004
+          makerFee: 0.0002
+          lever: 5
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		exchange string
+		balance  float64
+		want     TradingCostModel
+	}{
+		{"okx", 5000, TradingCostModel{TakerFee: 0.001, MakerFee: 0.001, Lever: 1}},
+		{"Binance", 5000, TradingCostModel{TakerFee: 0.0004, MakerFee: 0.0002, Lever: 5}},
+	}
+	for _, c := range cases {
+		balance, got := backtestDefaults(cfg, c.exchange)
+		if balance != c.balance || got != c.want {
+			t.Errorf("backtestDefaults(%s) = %v, %+v, want %v, %+v", c.exchange, balance, got, c.balance, c.want)
+		}
+	}
+	if balance, got := backtestDefaults(nil, "binance"); balance != defaultBacktestBalance || got != NewDefaultCostModel() {
+		t.Errorf("without config: %v, %+v", balance, got)
+	}
+
+	// an explicit fee keeps a configured maker fee
+	var req mcp.CallToolRequest
+	req.Params.Arguments = map[string]any{"fee": 0.0006}
+	_, base := backtestDefaults(cfg, "binance")
+	if m, err := costModelFromRequest(req, base); err != nil || m.TakerFee != 0.0006 || m.MakerFee != 0.0002 {
+		t.Errorf("fee over config: %+v, %v", m, err)
+	}
+}
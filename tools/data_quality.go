@@ -0,0 +1,249 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	basecommon "github.com/ztrade/base/common"
+	"github.com/ztrade/trademodel"
+	"github.com/ztrade/ztrade/pkg/process/dbstore"
+)
+
+const (
+	qualityDefaultWindow = 60
+	qualityDefaultSigma  = 5.0
+	// qualityMaxListed bounds each issue list in the report; counts are
+	// always complete.
+	qualityMaxListed = 100
+)
+
+// qualityIssue is one problematic candle.
+type qualityIssue struct {
+	Time   string  `json:"time"`
+	Close  float64 `json:"close,omitempty"`
+	Reason string  `json:"reason,omitempty"`
+}
+
+// qualityGap is one missing range.
+type qualityGap struct {
+	Start   string `json:"start"`
+	End     string `json:"end"`
+	Missing int    `json:"missing"`
+}
+
+// klineQuality is the result of assessKlineQuality.
+type klineQuality struct {
+	Expected       int            `json:"expectedCandles"`
+	Stored         int            `json:"storedCandles"`
+	GapCount       int            `json:"gapCount"`
+	Missing        int            `json:"missingCandles"`
+	Gaps           []qualityGap   `json:"gaps"`
+	DuplicateCount int            `json:"duplicateCount"`
+	Duplicates     []qualityIssue `json:"duplicates"`
+	ZeroVolume     int            `json:"zeroVolumeCount"`
+	ZeroVolumeList []qualityIssue `json:"zeroVolume"`
+	BadCount       int            `json:"inconsistentCount"`
+	Bad            []qualityIssue `json:"inconsistent"`
+	OutlierCount   int            `json:"outlierCount"`
+	Outliers       []qualityIssue `json:"outliers"`
+	Score          float64        `json:"healthScore"`
+	Verdict        string         `json:"verdict"`
+}
+
+func formatCandleTime(c *trademodel.Candle) string {
+	return c.Time().UTC().Format("2006-01-02 15:04:05")
+}
+
+// candleInconsistency describes why a candle's OHLC values cannot be real,
+// or returns "" if they are consistent.
+func candleInconsistency(c *trademodel.Candle) string {
+	switch {
+	case c.Open <= 0 || c.High <= 0 || c.Low <= 0 || c.Close <= 0:
+		return "non-positive price"
+	case c.High < c.Low:
+		return "high < low"
+	case c.Open > c.High || c.Open < c.Low:
+		return "open outside high/low"
+	case c.Close > c.High || c.Close < c.Low:
+		return "close outside high/low"
+	case c.Volume < 0:
+		return "negative volume"
+	}
+	return ""
+}
+
+// assessKlineQuality checks candles (sorted by start time) covering
+// [start, end) of dur. A close is an outlier when it is more than sigma
+// standard deviations from the mean of the previous window closes.
+func assessKlineQuality(candles []*trademodel.Candle, dur time.Duration, start, end time.Time, window int, sigma float64) klineQuality {
+	q := klineQuality{
+		Expected:       expectedCandleCount(start, end, dur),
+		Stored:         len(candles),
+		Gaps:           []qualityGap{},
+		Duplicates:     []qualityIssue{},
+		ZeroVolumeList: []qualityIssue{},
+		Bad:            []qualityIssue{},
+		Outliers:       []qualityIssue{},
+	}
+	add := func(list *[]qualityIssue, c *trademodel.Candle, reason string) {
+		if len(*list) < qualityMaxListed {
+			*list = append(*list, qualityIssue{Time: formatCandleTime(c), Close: c.Close, Reason: reason})
+		}
+	}
+
+	for _, g := range findKlineGaps(candles, dur, start, end) {
+		n := expectedCandleCount(g.Start, g.End, dur)
+		q.GapCount++
+		q.Missing += n
+		if len(q.Gaps) < qualityMaxListed {
+			q.Gaps = append(q.Gaps, qualityGap{
+				Start:   g.Start.UTC().Format("2006-01-02 15:04:05"),
+				End:     g.End.UTC().Format("2006-01-02 15:04:05"),
+				Missing: n,
+			})
+		}
+	}
+
+	// running sums over the previous window closes
+	var sum, sumSq float64
+	var closes []float64
+	for i, c := range candles {
+		if i > 0 && c.Start == candles[i-1].Start {
+			q.DuplicateCount++
+			add(&q.Duplicates, c, "")
+		}
+		if c.Volume == 0 {
+			q.ZeroVolume++
+			add(&q.ZeroVolumeList, c, "")
+		}
+		if reason := candleInconsistency(c); reason != "" {
+			q.BadCount++
+			add(&q.Bad, c, reason)
+			continue
+		}
+		if window > 1 && len(closes) >= window {
+			n := float64(window)
+			mean := sum / n
+			std := math.Sqrt(math.Max(sumSq/n-mean*mean, 0))
+			if std > 0 && math.Abs(c.Close-mean) > sigma*std {
+				q.OutlierCount++
+				add(&q.Outliers, c, fmt.Sprintf("%.1f std from rolling mean %.6g", math.Abs(c.Close-mean)/std, mean))
+			}
+			old := closes[len(closes)-window]
+			sum -= old
+			sumSq -= old * old
+		}
+		closes = append(closes, c.Close)
+		sum += c.Close
+		sumSq += c.Close * c.Close
+	}
+
+	q.Score, q.Verdict = qualityScore(q)
+	return q
+}
+
+// qualityScore weighs the issues into a 0-100 score. Missing data costs the
+// most and saturates at 25% missing; inconsistent or duplicate candles and
+// outliers saturate at 10% of the series.
+func qualityScore(q klineQuality) (float64, string) {
+	if q.Expected == 0 || q.Stored == 0 {
+		return 0, "no data"
+	}
+	stored := float64(q.Stored)
+	score := 100 -
+		60*math.Min(1, 4*float64(q.Missing)/float64(q.Expected)) -
+		20*math.Min(1, 10*float64(q.BadCount+q.DuplicateCount)/stored) -
+		10*math.Min(1, 10*float64(q.OutlierCount)/stored) -
+		10*math.Min(1, float64(q.ZeroVolume)/stored)
+	score = math.Round(math.Max(score, 0)*10) / 10
+	switch {
+	case score >= 90:
+		return score, "good"
+	case score >= 70:
+		return score, "fair"
+	}
+	return score, "poor"
+}
+
+func registerDataQualityReport(s *server.MCPServer, db *dbstore.DBStore) {
+	tool := mcp.NewTool("data_quality_report",
+		mcp.WithDescription("Check whether the local K-line data of a symbol is trustworthy before backtesting. Reports gaps, duplicate timestamps, zero-volume candles, "+
+			"inconsistent candles (high < low, open/close outside the range, non-positive prices) and close-price outliers against a rolling mean, "+
+			"with a 0-100 health score. Each issue list shows at most 100 entries; counts are complete."),
+		mcp.WithString("exchange", mcp.Required(), mcp.Description("Exchange name e.g. binance, okx")),
+		mcp.WithString("symbol", mcp.Required(), mcp.Description("Trading pair e.g. BTCUSDT")),
+		mcp.WithString("binSize", mcp.Description("K-line period 1m/5m/15m/1h/1d. Default: 1m")),
+		mcp.WithString("start", mcp.Required(), mcp.Description("Start time in format 2006-01-02 15:04:05")),
+		mcp.WithString("end", mcp.Required(), mcp.Description("End time in format 2006-01-02 15:04:05")),
+		mcp.WithNumber("window", mcp.Description("Rolling window (candles) for outlier detection. Default: 60")),
+		mcp.WithNumber("sigma", mcp.Description("Standard deviations from the rolling mean that make a close an outlier. Default: 5")),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if db == nil {
+			return toolError(codeUnavailable, "database not initialized"), nil
+		}
+
+		exchange := req.GetString("exchange", "")
+		symbol := req.GetString("symbol", "")
+		binSize := strings.ToLower(strings.TrimSpace(req.GetString("binSize", "")))
+		if binSize == "" {
+			binSize = "1m"
+		}
+		window := int(req.GetFloat("window", qualityDefaultWindow))
+		if window < 2 {
+			return toolError(codeInvalidArgument, "window must be at least 2"), nil
+		}
+		sigma := req.GetFloat("sigma", qualityDefaultSigma)
+		if sigma <= 0 {
+			return toolError(codeInvalidArgument, "sigma must be positive"), nil
+		}
+
+		start, err := time.Parse("2006-01-02 15:04:05", req.GetString("start", ""))
+		if err != nil {
+			return toolErrorf(codeInvalidArgument, "invalid start time: %s", err.Error()), nil
+		}
+		end, err := time.Parse("2006-01-02 15:04:05", req.GetString("end", ""))
+		if err != nil {
+			return toolErrorf(codeInvalidArgument, "invalid end time: %s", err.Error()), nil
+		}
+		if !start.Before(end) {
+			return toolError(codeInvalidArgument, "start must be before end"), nil
+		}
+
+		dur, err := basecommon.GetBinSizeDuration(binSize)
+		if err != nil {
+			return toolErrorf(codeInvalidArgument, "invalid binSize %q: %s", binSize, err.Error()), nil
+		}
+		if n := expectedCandleCount(start, end, dur); n >= klineGapScanMax {
+			return toolErrorf(codeInvalidArgument, "range too large to scan (%d candles, max %d)", n, klineGapScanMax), nil
+		}
+		candles, _, err := readStoredCandles(db, exchange, symbol, binSize, start, end)
+		if err != nil {
+			return toolError(codeInternal, err.Error()), nil
+		}
+		q := assessKlineQuality(candles, dur, start, end, window, sigma)
+
+		result := map[string]interface{}{
+			"exchange": exchange,
+			"symbol":   symbol,
+			"binSize":  binSize,
+			"start":    start.Format("2006-01-02 15:04:05"),
+			"end":      end.Format("2006-01-02 15:04:05"),
+			"window":   window,
+			"sigma":    sigma,
+			"report":   q,
+		}
+		if q.Verdict != "good" {
+			result["recommendation"] = "re-download this range with download_kline before trusting backtest results"
+		}
+		data, _ := json.MarshalIndent(result, "", "  ")
+		return mcp.NewToolResultText(string(data)), nil
+	})
+}
@@ -0,0 +1,50 @@
+package tools
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ztrade/trademodel"
+)
+
+func TestAssessKlineQuality(t *testing.T) {
+	start := time.Unix(0, 0)
+	end := start.Add(100 * time.Minute)
+	var candles []*trademodel.Candle
+	for i := 0; i < 100; i++ {
+		if i >= 40 && i < 45 {
+			continue // gap
+		}
+		price := 100 + float64(i%3)
+		candles = append(candles, &trademodel.Candle{Start: int64(i * 60), Open: price, High: price + 1, Low: price - 1, Close: price, Volume: 10})
+	}
+	candles[70].Close, candles[70].High = 200, 200
+	candles[80].High, candles[80].Low = 90, 110
+	candles[85].Volume = 0
+	dup := *candles[90]
+	candles = append(candles[:91], append([]*trademodel.Candle{&dup}, candles[91:]...)...)
+
+	q := assessKlineQuality(candles, time.Minute, start, end, 20, 5)
+	if q.Expected != 100 || q.Stored != 96 {
+		t.Fatalf("counts: expected %d stored %d", q.Expected, q.Stored)
+	}
+	if q.GapCount != 1 || q.Missing != 5 || q.Gaps[0].Missing != 5 {
+		t.Fatalf("gaps: %+v", q.Gaps)
+	}
+	if q.DuplicateCount != 1 || q.ZeroVolume != 1 || q.BadCount != 1 || q.Bad[0].Reason != "high < low" {
+		t.Fatalf("issues: dup %d zero %d bad %+v", q.DuplicateCount, q.ZeroVolume, q.Bad)
+	}
+	if q.OutlierCount != 1 || q.Outliers[0].Close != 200 {
+		t.Fatalf("outliers: %+v", q.Outliers)
+	}
+	if q.Score >= 90 || q.Verdict == "good" {
+		t.Fatalf("score %.1f verdict %s", q.Score, q.Verdict)
+	}
+
+	if q := assessKlineQuality(candles[:30], time.Minute, start, start.Add(30*time.Minute), 20, 5); q.Score != 100 || q.Verdict != "good" {
+		t.Fatalf("clean data: %+v", q)
+	}
+	if q := assessKlineQuality(nil, time.Minute, start, end, 20, 5); q.Score != 0 || q.GapCount != 1 {
+		t.Fatalf("empty data: %+v", q)
+	}
+}
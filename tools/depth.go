@@ -0,0 +1,165 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/spf13/viper"
+	"github.com/ztrade/exchange"
+	"github.com/ztrade/trademodel"
+)
+
+// The exchange clients only stream partial books: 10 levels on binance
+// futures, 20 on binance spot and 5 on okx. Requests for more levels get
+// what the stream carries.
+const (
+	depthDefaultLevels = 10
+	depthMaxLevels     = 20
+	depthWatchTimeout  = 10 * time.Second
+)
+
+type depthLevel struct {
+	Price       float64 `json:"price"`
+	Amount      float64 `json:"amount"`
+	CumAmount   float64 `json:"cumAmount"`
+	CumNotional float64 `json:"cumNotional"`
+}
+
+type depthSummary struct {
+	Bids      []depthLevel `json:"bids"`
+	Asks      []depthLevel `json:"asks"`
+	BestBid   float64      `json:"bestBid"`
+	BestAsk   float64      `json:"bestAsk"`
+	Spread    float64      `json:"spread"`
+	SpreadBps float64      `json:"spreadBps"`
+	MidPrice  float64      `json:"midPrice"`
+}
+
+// summarizeDepth sorts the book (bids descending, asks ascending), keeps at
+// most levels per side and computes cumulative volume, spread and mid price.
+func summarizeDepth(depth *trademodel.Depth, levels int) depthSummary {
+	var sum depthSummary
+	buys := append([]trademodel.DepthInfo(nil), depth.Buys...)
+	sells := append([]trademodel.DepthInfo(nil), depth.Sells...)
+	sort.Slice(buys, func(i, j int) bool { return buys[i].Price > buys[j].Price })
+	sort.Slice(sells, func(i, j int) bool { return sells[i].Price < sells[j].Price })
+	sum.Bids = cumulateDepth(buys, levels)
+	sum.Asks = cumulateDepth(sells, levels)
+	if len(sum.Bids) > 0 && len(sum.Asks) > 0 {
+		sum.BestBid = sum.Bids[0].Price
+		sum.BestAsk = sum.Asks[0].Price
+		sum.Spread = sum.BestAsk - sum.BestBid
+		sum.MidPrice = (sum.BestAsk + sum.BestBid) / 2
+		if sum.MidPrice > 0 {
+			sum.SpreadBps = sum.Spread / sum.MidPrice * 10000
+		}
+	}
+	return sum
+}
+
+func cumulateDepth(infos []trademodel.DepthInfo, levels int) []depthLevel {
+	if len(infos) > levels {
+		infos = infos[:levels]
+	}
+	ret := make([]depthLevel, 0, len(infos))
+	var cumAmount, cumNotional float64
+	for _, d := range infos {
+		cumAmount += d.Amount
+		cumNotional += d.Amount * d.Price
+		ret = append(ret, depthLevel{Price: d.Price, Amount: d.Amount, CumAmount: cumAmount, CumNotional: cumNotional})
+	}
+	return ret
+}
+
+// watchDepthSnapshot subscribes to the depth stream and returns the first
+// order book received.
+func watchDepthSnapshot(ctx context.Context, ex exchange.Exchange, symbol string, timeout time.Duration) (*trademodel.Depth, error) {
+	ch := make(chan *trademodel.Depth, 1)
+	param := exchange.WatchParam{Type: exchange.WatchTypeDepth, Param: map[string]string{"symbol": symbol}}
+	err := ex.Watch(param, func(v interface{}) {
+		var d *trademodel.Depth
+		switch t := v.(type) {
+		case *trademodel.Depth:
+			d = t
+		case trademodel.Depth:
+			d = &t
+		default:
+			return
+		}
+		select {
+		case ch <- d:
+		default:
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("exchange does not provide depth for %s: %s", symbol, err.Error())
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	select {
+	case d := <-ch:
+		return d, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("no depth snapshot received for %s within %s; the exchange may not support depth for this symbol", symbol, timeout)
+	}
+}
+
+func registerFetchDepth(s *server.MCPServer, cfg *viper.Viper) {
+	tool := mcp.NewTool("fetch_depth",
+		mcp.WithDescription("Fetch a current order book (depth) snapshot from an exchange. Returns bids/asks with cumulative volume, best bid/ask, spread and mid price. "+
+			"Takes the first snapshot of the exchange depth stream, which carries a partial book: at most 10 levels per side on binance futures, 20 on binance spot and 5 on okx."),
+		mcp.WithString("exchange", mcp.Required(), mcp.Description("Exchange config name (e.g., binance, okx). Must be configured in the config file.")),
+		mcp.WithString("symbol", mcp.Required(), mcp.Description("Trading pair (e.g., BTCUSDT)")),
+		mcp.WithNumber("levels", mcp.Description("Number of levels per side, capped by what the exchange streams (see above). Default: 10, Max: 20")),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		exchangeName := req.GetString("exchange", "")
+		symbol := req.GetString("symbol", "")
+		levels := int(req.GetFloat("levels", 0))
+		if levels <= 0 {
+			levels = depthDefaultLevels
+		}
+		if levels > depthMaxLevels {
+			levels = depthMaxLevels
+		}
+		if symbol == "" {
+			return toolError(codeInvalidArgument, "symbol is required"), nil
+		}
+
+		ex, err := newExchangeClient(cfg, exchangeName)
+		if err != nil {
+			return toolError(codeInvalidArgument, err.Error()), nil
+		}
+
+		depth, err := watchDepthSnapshot(ctx, ex, symbol, depthWatchTimeout)
+		_ = ex.Stop()
+		if err != nil {
+			return toolErrorf(codeUpstream, "failed to fetch depth: %s", err.Error()), nil
+		}
+		if depth == nil || (len(depth.Buys) == 0 && len(depth.Sells) == 0) {
+			return toolErrorf(codeUpstream, "empty order book returned for %s", symbol), nil
+		}
+
+		summary := summarizeDepth(depth, levels)
+		result := map[string]interface{}{
+			"exchange":        exchangeName,
+			"symbol":          symbol,
+			"source":          "stream",
+			"levelsRequested": levels,
+			"bidLevels":       len(summary.Bids),
+			"askLevels":       len(summary.Asks),
+			"depth":           summary,
+		}
+		if !depth.UpdateTime.IsZero() {
+			result["updateTime"] = depth.UpdateTime.Format("2006-01-02 15:04:05.000")
+		}
+		data, _ := json.MarshalIndent(result, "", "  ")
+		return mcp.NewToolResultText(string(data)), nil
+	})
+}
@@ -0,0 +1,28 @@
+package tools
+
+import (
+	"math"
+	"testing"
+
+	"github.com/ztrade/trademodel"
+)
+
+func TestSummarizeDepth(t *testing.T) {
+	depth := &trademodel.Depth{
+		Buys:  []trademodel.DepthInfo{{Price: 99, Amount: 2}, {Price: 100, Amount: 1}, {Price: 98, Amount: 5}},
+		Sells: []trademodel.DepthInfo{{Price: 102, Amount: 3}, {Price: 101, Amount: 1}},
+	}
+	sum := summarizeDepth(depth, 2)
+	if len(sum.Bids) != 2 || sum.Bids[0].Price != 100 || sum.Bids[1].CumAmount != 3 {
+		t.Fatalf("unexpected bids: %+v", sum.Bids)
+	}
+	if len(sum.Asks) != 2 || sum.Asks[0].Price != 101 || sum.Asks[1].CumNotional != 101+306 {
+		t.Fatalf("unexpected asks: %+v", sum.Asks)
+	}
+	if sum.Spread != 1 || sum.MidPrice != 100.5 || math.Abs(sum.SpreadBps-1/100.5*10000) > 1e-9 {
+		t.Fatalf("unexpected spread/mid: %+v", sum)
+	}
+	if len(depth.Buys) != 3 || depth.Buys[0].Price != 99 {
+		t.Fatalf("input depth must not be modified")
+	}
+}
@@ -11,7 +11,7 @@ import (
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 	"github.com/spf13/viper"
-	"github.com/ztrade/ztrade/pkg/ctl"
+	basecommon "github.com/ztrade/base/common"
 	"github.com/ztrade/ztrade/pkg/process/dbstore"
 )
 
@@ -19,16 +19,18 @@ func registerDownloadKline(s *server.MCPServer, db *dbstore.DBStore, cfg *viper.
 	tool := mcp.NewTool("download_kline",
 		mcp.WithDescription("Download historical K-line data from an exchange to local database. Requires exchange API configuration. When the time range exceeds 30 days the task runs asynchronously — a task ID is returned immediately and you can poll progress with get_task_status / get_task_result."),
 		mcp.WithString("exchange", mcp.Required(), mcp.Description("Exchange name (e.g., binance, okx)")),
-		mcp.WithString("symbol", mcp.Required(), mcp.Description("Trading pair (e.g., BTCUSDT)")),
+		mcp.WithString("symbol", mcp.Required(), mcp.Description("Trading pair in the exchange's native form or any common notation (BTCUSDT, BTC-USDT, BTC/USDT); candles are stored under the native symbol")),
 		mcp.WithString("binSize", mcp.Description("K-line period (1m/5m/15m/1h/1d). Default: 1m")),
 		mcp.WithString("start", mcp.Description("Start time in format '2006-01-02 15:04:05'. Required if auto=false.")),
 		mcp.WithString("end", mcp.Description("End time in format '2006-01-02 15:04:05'. Required if auto=false.")),
 		mcp.WithBoolean("auto", mcp.Description("Auto-continue download from the latest data in DB to now. Default: false")),
+		mcp.WithBoolean("dryRun", mcp.Description("Only report the download plan (effective range, expected candles, missing sub-ranges, async or not) without downloading. Default: false")),
+		idempotencyKeyOption(),
 	)
 
 	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		if db == nil {
-			return mcp.NewToolResultError("database not initialized"), nil
+			return toolError(codeUnavailable, "database not initialized"), nil
 		}
 
 		exchange := req.GetString("exchange", "")
@@ -37,56 +39,41 @@ func registerDownloadKline(s *server.MCPServer, db *dbstore.DBStore, cfg *viper.
 		startStr := req.GetString("start", "")
 		endStr := req.GetString("end", "")
 		auto := req.GetBool("auto", false)
+		dryRun := req.GetBool("dryRun", false)
 
 		if binSize == "" {
 			binSize = "1m"
 		}
+		// candles are stored under the exchange's native symbol
+		requested := symbol
+		symbol = resolveExchangeSymbol(ctx, cfg, exchange, symbol)
+
+		if dryRun {
+			plan, err := planDownload(db, exchange, symbol, binSize, startStr, endStr, auto)
+			if err != nil {
+				return toolError(codeInvalidArgument, err.Error()), nil
+			}
+			if symbol != requested {
+				plan["requestedSymbol"] = requested
+			}
+			data, _ := json.MarshalIndent(plan, "", "  ")
+			return mcp.NewToolResultText(string(data)), nil
+		}
 
 		// For auto mode or manual mode, determine whether to run async
 		if auto {
 			// Auto mode: always run async since time range is unknown and could be large
-			taskID := tm.CreateTask("download", map[string]string{
+			params := map[string]string{
 				"exchange": exchange,
 				"symbol":   symbol,
 				"binSize":  binSize,
 				"mode":     "auto",
-			})
-
-			go func() {
-				tm.StartTask(taskID)
-
-				// 查询本地数据库中该symbol的最新K线时间作为estStart
-				estEnd := time.Now()
-				estStart := estEnd.AddDate(0, -3, 0)
-				if db != nil {
-					latestTime := db.GetKlineTbl(exchange, symbol, binSize).GetNewest()
-					if !latestTime.IsZero() {
-						estStart = latestTime
-					}
-				}
-				doneCh := tm.ProgressEstimator(taskID, "download", estStart, estEnd)
-
-				d := ctl.NewDataDownloadAuto(cfg, db, exchange, symbol, binSize)
-				err := d.Run()
-				close(doneCh)
-
-				if err != nil {
-					log.Errorf("async download task %s failed: %s", taskID, err.Error())
-					tm.FailTask(taskID, fmt.Sprintf("download failed: %s", err.Error()))
-					return
-				}
-
-				result := map[string]interface{}{
-					"status":   "completed",
-					"exchange": exchange,
-					"symbol":   symbol,
-					"binSize":  binSize,
-					"mode":     "auto",
-				}
-				data, _ := json.MarshalIndent(result, "", "  ")
-				tm.CompleteTask(taskID, string(data))
-				log.Infof("async download task %s completed", taskID)
-			}()
+			}
+			taskID, created := tm.CreateTaskOnce("download", params, taskDedupeKey(ctx, "download", req))
+			if !created {
+				return dedupedTaskResult(taskID), nil
+			}
+			go runDownloadTask(context.WithoutCancel(ctx), tm, cfg, db, taskID, params, time.Time{})
 
 			asyncResult := map[string]interface{}{
 				"async":   true,
@@ -99,53 +86,31 @@ func registerDownloadKline(s *server.MCPServer, db *dbstore.DBStore, cfg *viper.
 
 		// Manual mode: parse start/end
 		if startStr == "" || endStr == "" {
-			return mcp.NewToolResultError("start and end time are required when auto=false"), nil
+			return toolError(codeInvalidArgument, "start and end time are required when auto=false"), nil
 		}
 		start, err := time.Parse("2006-01-02 15:04:05", startStr)
 		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("invalid start time: %s", err.Error())), nil
+			return toolErrorf(codeInvalidArgument, "invalid start time: %s", err.Error()), nil
 		}
 		end, err := time.Parse("2006-01-02 15:04:05", endStr)
 		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("invalid end time: %s", err.Error())), nil
+			return toolErrorf(codeInvalidArgument, "invalid end time: %s", err.Error()), nil
 		}
 
 		// If time range > threshold, run asynchronously
 		if ShouldRunAsync(start, end) {
-			taskID := tm.CreateTask("download", map[string]string{
+			params := map[string]string{
 				"exchange": exchange,
 				"symbol":   symbol,
 				"binSize":  binSize,
 				"start":    startStr,
 				"end":      endStr,
-			})
-
-			go func() {
-				tm.StartTask(taskID)
-				doneCh := tm.ProgressEstimator(taskID, "download", start, end)
-
-				d := ctl.NewDataDownload(cfg, db, exchange, symbol, binSize, start, end)
-				err := d.Run()
-				close(doneCh)
-
-				if err != nil {
-					log.Errorf("async download task %s failed: %s", taskID, err.Error())
-					tm.FailTask(taskID, fmt.Sprintf("download failed: %s", err.Error()))
-					return
-				}
-
-				result := map[string]interface{}{
-					"status":   "completed",
-					"exchange": exchange,
-					"symbol":   symbol,
-					"binSize":  binSize,
-					"start":    startStr,
-					"end":      endStr,
-				}
-				data, _ := json.MarshalIndent(result, "", "  ")
-				tm.CompleteTask(taskID, string(data))
-				log.Infof("async download task %s completed", taskID)
-			}()
+			}
+			taskID, created := tm.CreateTaskOnce("download", params, taskDedupeKey(ctx, "download", req))
+			if !created {
+				return dedupedTaskResult(taskID), nil
+			}
+			go runDownloadTask(context.WithoutCancel(ctx), tm, cfg, db, taskID, params, time.Time{})
 
 			asyncResult := map[string]interface{}{
 				"async":   true,
@@ -157,10 +122,9 @@ func registerDownloadKline(s *server.MCPServer, db *dbstore.DBStore, cfg *viper.
 		}
 
 		// Synchronous execution for short time ranges
-		d := ctl.NewDataDownload(cfg, db, exchange, symbol, binSize, start, end)
-		err = d.Run()
+		err = downloadKlines(ctx, cfg, db, exchange, symbol, binSize, start, end)
 		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("download failed: %s", err.Error())), nil
+			return toolErrorf(codeInternal, "download failed: %s", err.Error()), nil
 		}
 
 		result := map[string]interface{}{
@@ -171,8 +135,245 @@ func registerDownloadKline(s *server.MCPServer, db *dbstore.DBStore, cfg *viper.
 			"start":    startStr,
 			"end":      endStr,
 		}
+		if symbol != requested {
+			result["requestedSymbol"] = requested
+		}
+		verifyDownload(db, exchange, symbol, binSize, start, end, result)
 
 		data, _ := json.MarshalIndent(result, "", "  ")
 		return mcp.NewToolResultText(string(data)), nil
 	})
 }
+
+// verifyDownload counts the candles stored in [start, end) after a download
+// and adds expectedCandles, storedCandles and missing to result, with a
+// warning when coverage is incomplete. Exchanges skip candles of halted or
+// not yet listed markets, so missing candles are not always a failure, but
+// the caller should not assume the range is complete.
+func verifyDownload(db *dbstore.DBStore, exchange, symbol, binSize string, start, end time.Time, result map[string]interface{}) {
+	dur, err := basecommon.GetBinSizeDuration(binSize)
+	if err != nil {
+		result["verifyError"] = fmt.Sprintf("invalid binSize %q: %s", binSize, err.Error())
+		return
+	}
+	expected := expectedCandleCount(start, end, dur)
+	stored, gaps, err := scanKlineGaps(db, exchange, symbol, binSize, start, end)
+	if err != nil {
+		result["verifyError"] = err.Error()
+		return
+	}
+	missing := 0
+	for _, g := range gaps {
+		missing += expectedCandleCount(g.Start, g.End, dur)
+	}
+	result["expectedCandles"] = expected
+	result["storedCandles"] = stored
+	result["missing"] = missing
+	if missing > 0 {
+		result["warning"] = fmt.Sprintf("incomplete download: %d of %d candles missing in %d ranges; check them with data_quality_report or download the range again", missing, expected, len(gaps))
+	}
+}
+
+// downloadTaskRange returns the range an async download task fetches:
+// from autoDownloadStart to now in auto mode, else its start and end.
+func downloadTaskRange(db *dbstore.DBStore, params map[string]string) (start, end time.Time, err error) {
+	if params["mode"] == "auto" {
+		start, err = autoDownloadStart(db, params["exchange"], params["symbol"], params["binSize"])
+		return start, time.Now(), err
+	}
+	if start, err = time.Parse("2006-01-02 15:04:05", params["start"]); err != nil {
+		return start, end, fmt.Errorf("invalid start time: %s", err.Error())
+	}
+	if end, err = time.Parse("2006-01-02 15:04:05", params["end"]); err != nil {
+		return start, end, fmt.Errorf("invalid end time: %s", err.Error())
+	}
+	return start, end, nil
+}
+
+// resumeDownloadStart returns where a retried download of [start, end]
+// continues from. downloadKlines writes candles in time order, so like
+// autoDownloadStart it is one minute before the newest stored candle when
+// that lies inside the range; otherwise the range is fetched again from
+// start.
+func resumeDownloadStart(db *dbstore.DBStore, exchange, symbol, binSize string, start, end time.Time) time.Time {
+	newest := db.GetKlineTbl(exchange, symbol, binSize).GetNewest()
+	if newest.After(start) && newest.Before(end) {
+		return newest.Add(-time.Minute)
+	}
+	return start
+}
+
+// runDownloadTask runs the async download task taskID described by params,
+// as created by download_kline, and completes or fails it. A non-zero from
+// replaces the start of a manual download when retry_task resumes it.
+func runDownloadTask(ctx context.Context, tm *TaskManager, cfg *viper.Viper, db *dbstore.DBStore, taskID string, params map[string]string, from time.Time) {
+	tm.StartTask(taskID)
+
+	rangeStart, end, err := downloadTaskRange(db, params)
+	if err == nil {
+		start := rangeStart
+		if !from.IsZero() {
+			start = from
+		}
+		doneCh := tm.ProgressEstimator(taskID, "download", start, end)
+		err = downloadKlines(ctx, cfg, db, params["exchange"], params["symbol"], params["binSize"], start, end)
+		close(doneCh)
+	}
+	if err != nil {
+		log.WithContext(ctx).Errorf("async download task %s failed: %s", taskID, err.Error())
+		tm.FailTask(taskID, fmt.Sprintf("download failed: %s", err.Error()))
+		return
+	}
+
+	result := map[string]interface{}{"status": "completed"}
+	for k, v := range params {
+		result[k] = v
+	}
+	if !from.IsZero() {
+		result["resumedFrom"] = from.Format("2006-01-02 15:04:05")
+	}
+	if params["mode"] == "auto" {
+		// the candle still forming at now may not be returned yet
+		if dur, err := basecommon.GetBinSizeDuration(params["binSize"]); err == nil {
+			end = end.Truncate(dur)
+		}
+	}
+	verifyDownload(db, params["exchange"], params["symbol"], params["binSize"], rangeStart, end, result)
+	data, _ := json.MarshalIndent(result, "", "  ")
+	tm.CompleteTask(taskID, string(data))
+	log.WithContext(ctx).Infof("async download task %s completed", taskID)
+}
+
+func registerRetryTask(s *server.MCPServer, db *dbstore.DBStore, cfg *viper.Viper, tm *TaskManager) {
+	tool := mcp.NewTool("retry_task",
+		mcp.WithDescription("Retry a failed async download task under the same task ID and params. Candles already written are kept: a manual (start/end) download resumes from the newest stored candle inside its range instead of the original start, and an auto download continues from the newest stored candle as usual. Poll the task again with get_task_status / get_task_result."),
+		mcp.WithString("taskId", mcp.Required(), mcp.Description("ID of the failed download task")),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if db == nil {
+			return toolError(codeUnavailable, "database not initialized"), nil
+		}
+
+		taskID := req.GetString("taskId", "")
+		task, err := tm.GetTask(taskID)
+		if err != nil {
+			return toolError(codeNotFound, err.Error()), nil
+		}
+		if task.Type != "download" {
+			return toolErrorf(codeFailedPrecondition, "only download tasks can be retried; start a new %s task with its tool instead", task.Type), nil
+		}
+
+		// Type and Params never change after creation
+		var from time.Time
+		if task.Params["mode"] != "auto" {
+			start, end, err := downloadTaskRange(db, task.Params)
+			if err != nil {
+				return toolError(codeInternal, err.Error()), nil
+			}
+			if resume := resumeDownloadStart(db, task.Params["exchange"], task.Params["symbol"], task.Params["binSize"], start, end); resume.After(start) {
+				from = resume
+			}
+		}
+		retries, err := tm.RetryTask(taskID)
+		if err != nil {
+			return toolError(codeFailedPrecondition, err.Error()), nil
+		}
+		go runDownloadTask(context.WithoutCancel(ctx), tm, cfg, db, taskID, task.Params, from)
+
+		result := map[string]interface{}{
+			"async":   true,
+			"taskId":  taskID,
+			"retries": retries,
+		}
+		if from.IsZero() {
+			result["message"] = fmt.Sprintf("Download task '%s' restarted. Use get_task_status or get_task_result to follow it.", taskID)
+		} else {
+			result["resumedFrom"] = from.Format("2006-01-02 15:04:05")
+			result["message"] = fmt.Sprintf("Download task '%s' resumed from the newest stored candle. Use get_task_status or get_task_result to follow it.", taskID)
+		}
+		data, _ := json.MarshalIndent(result, "", "  ")
+		return mcp.NewToolResultText(string(data)), nil
+	})
+}
+
+// downloadPlanMaxGaps bounds the missing ranges listed in a dry-run plan.
+const downloadPlanMaxGaps = 100
+
+// planDownload computes what download_kline would fetch without contacting
+// the exchange. Auto mode continues from the newest stored candle like
+// downloadKlines does.
+func planDownload(db *dbstore.DBStore, exchange, symbol, binSize, startStr, endStr string, auto bool) (map[string]interface{}, error) {
+	dur, err := basecommon.GetBinSizeDuration(binSize)
+	if err != nil {
+		return nil, fmt.Errorf("invalid binSize %q: %s", binSize, err.Error())
+	}
+
+	var start, end time.Time
+	mode := "manual"
+	if auto {
+		mode = "auto"
+		if start, err = autoDownloadStart(db, exchange, symbol, binSize); err != nil {
+			return nil, err
+		}
+		end = time.Now()
+	} else {
+		if startStr == "" || endStr == "" {
+			return nil, fmt.Errorf("start and end time are required when auto=false")
+		}
+		if start, err = time.Parse("2006-01-02 15:04:05", startStr); err != nil {
+			return nil, fmt.Errorf("invalid start time: %s", err.Error())
+		}
+		if end, err = time.Parse("2006-01-02 15:04:05", endStr); err != nil {
+			return nil, fmt.Errorf("invalid end time: %s", err.Error())
+		}
+	}
+	if !start.Before(end) {
+		return nil, fmt.Errorf("start must be before end")
+	}
+
+	expected := expectedCandleCount(start, end, dur)
+	plan := map[string]interface{}{
+		"dryRun":             true,
+		"mode":               mode,
+		"exchange":           exchange,
+		"symbol":             symbol,
+		"binSize":            binSize,
+		"start":              start.Format("2006-01-02 15:04:05"),
+		"end":                end.Format("2006-01-02 15:04:05"),
+		"expectedCandles":    expected,
+		"async":              auto || ShouldRunAsync(start, end),
+		"asyncThresholdDays": AsyncThresholdDays,
+	}
+
+	existing, gaps, err := scanKlineGaps(db, exchange, symbol, binSize, start, end)
+	if err != nil {
+		plan["gapScanError"] = err.Error()
+		return plan, nil
+	}
+	type gapEntry struct {
+		Start   string `json:"start"`
+		End     string `json:"end"`
+		Candles int    `json:"candles"`
+	}
+	missing := 0
+	entries := make([]gapEntry, 0, minInt(len(gaps), downloadPlanMaxGaps))
+	for _, g := range gaps {
+		n := expectedCandleCount(g.Start, g.End, dur)
+		missing += n
+		if len(entries) < downloadPlanMaxGaps {
+			entries = append(entries, gapEntry{
+				Start:   g.Start.Format("2006-01-02 15:04:05"),
+				End:     g.End.Format("2006-01-02 15:04:05"),
+				Candles: n,
+			})
+		}
+	}
+	plan["existingCandles"] = existing
+	plan["missingCandles"] = missing
+	plan["missingRanges"] = entries
+	if len(gaps) > len(entries) {
+		plan["missingRangesTruncated"] = len(gaps)
+	}
+	return plan, nil
+}
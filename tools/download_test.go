@@ -0,0 +1,76 @@
+package tools
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ztrade/trademodel"
+	"github.com/ztrade/ztrade/pkg/process/dbstore"
+)
+
+func TestResumeDownloadStart(t *testing.T) {
+	db, err := dbstore.NewDBStore("sqlite", filepath.Join(t.TempDir(), "kline.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 3, 0)
+	if got := resumeDownloadStart(db, "binance", "BTCUSDT", "1m", start, end); !got.Equal(start) {
+		t.Fatalf("no data: resumed from %s", got)
+	}
+
+	// a download that failed after writing the first month
+	var candles []*trademodel.Candle
+	for i := 0; i < 31*24; i++ {
+		candles = append(candles, &trademodel.Candle{Start: start.Add(time.Duration(i) * time.Hour).Unix(), Open: 1, High: 1, Low: 1, Close: 1})
+	}
+	if _, err := saveFetchedKlines(db, "binance", "BTCUSDT", "1m", candles); err != nil {
+		t.Fatal(err)
+	}
+	newest := start.Add(time.Duration(len(candles)-1) * time.Hour)
+	if got := resumeDownloadStart(db, "binance", "BTCUSDT", "1m", start, end); !got.Equal(newest.Add(-time.Minute)) {
+		t.Fatalf("resumed from %s, want a minute before %s", got, newest)
+	}
+	// stored data past the range says nothing about the failed download
+	if got := resumeDownloadStart(db, "binance", "BTCUSDT", "1m", start, start.AddDate(0, 0, 7)); !got.Equal(start) {
+		t.Fatalf("data past the range: resumed from %s", got)
+	}
+}
+
+func TestVerifyDownload(t *testing.T) {
+	db, err := dbstore.NewDBStore("sqlite", filepath.Join(t.TempDir(), "kline.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(10 * time.Hour)
+	var candles []*trademodel.Candle
+	for i := 0; i < 10; i++ {
+		if i == 4 || i == 5 {
+			continue
+		}
+		candles = append(candles, &trademodel.Candle{Start: start.Add(time.Duration(i) * time.Hour).Unix(), Open: 1, High: 1, Low: 1, Close: 1})
+	}
+	if _, err := saveFetchedKlines(db, "binance", "BTCUSDT", "1h", candles); err != nil {
+		t.Fatal(err)
+	}
+
+	result := map[string]interface{}{}
+	verifyDownload(db, "binance", "BTCUSDT", "1h", start, end, result)
+	if result["expectedCandles"] != 10 || result["storedCandles"] != 8 || result["missing"] != 2 {
+		t.Fatalf("result = %v", result)
+	}
+	if _, ok := result["warning"]; !ok {
+		t.Fatalf("no warning for incomplete coverage: %v", result)
+	}
+
+	result = map[string]interface{}{}
+	verifyDownload(db, "binance", "BTCUSDT", "1h", start, start.Add(4*time.Hour), result)
+	if result["missing"] != 0 {
+		t.Fatalf("result = %v", result)
+	}
+	if _, ok := result["warning"]; ok {
+		t.Fatalf("warning for a complete range: %v", result)
+	}
+}
@@ -0,0 +1,122 @@
+package tools
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/ztrade/trademodel"
+	"github.com/ztrade/ztrade-mcp/store"
+)
+
+// minDownsamplePoints is the smallest maxPoints accepted: LTTB always keeps
+// the first and last point and needs at least one bucket between them.
+const minDownsamplePoints = 3
+
+// maxPointsArg validates a maxPoints argument; 0 means no downsampling.
+func maxPointsArg(maxPoints int) error {
+	if maxPoints != 0 && maxPoints < minDownsamplePoints {
+		return fmt.Errorf("maxPoints must be 0 (no downsampling) or at least %d", minDownsamplePoints)
+	}
+	return nil
+}
+
+// lttbIndices picks at most n indices of the series (xs, ys) with the
+// largest-triangle-three-buckets algorithm, which keeps the peaks and
+// troughs a chart needs. The first and last points are always kept. All
+// indices are returned when the series already fits.
+func lttbIndices(xs, ys []float64, n int) []int {
+	size := len(xs)
+	if n <= 0 || size <= n || n < minDownsamplePoints {
+		idx := make([]int, size)
+		for i := range idx {
+			idx[i] = i
+		}
+		return idx
+	}
+
+	idx := make([]int, 0, n)
+	idx = append(idx, 0)
+	// the points between the first and the last are split into n-2 buckets
+	every := float64(size-2) / float64(n-2)
+	a := 0
+	for i := 0; i < n-2; i++ {
+		// average of the next bucket, the third vertex of the triangle
+		nextStart := int(math.Floor(float64(i+1)*every)) + 1
+		nextEnd := int(math.Floor(float64(i+2)*every)) + 1
+		if nextEnd > size {
+			nextEnd = size
+		}
+		var avgX, avgY float64
+		for j := nextStart; j < nextEnd; j++ {
+			avgX += xs[j]
+			avgY += ys[j]
+		}
+		if cnt := nextEnd - nextStart; cnt > 0 {
+			avgX /= float64(cnt)
+			avgY /= float64(cnt)
+		} else {
+			avgX, avgY = xs[size-1], ys[size-1]
+		}
+
+		start := int(math.Floor(float64(i)*every)) + 1
+		end := int(math.Floor(float64(i+1)*every)) + 1
+		best, maxArea := start, -1.0
+		for j := start; j < end; j++ {
+			area := math.Abs((xs[a]-avgX)*(ys[j]-ys[a]) - (xs[a]-xs[j])*(avgY-ys[a]))
+			if area > maxArea {
+				best, maxArea = j, area
+			}
+		}
+		idx = append(idx, best)
+		a = best
+	}
+	return append(idx, size-1)
+}
+
+// downsampleCandles merges consecutive candles into at most n buckets of
+// nearly equal size: open of the first, highest high, lowest low, close of
+// the last and summed volume, stamped with the first candle's time. Unlike
+// picking points, this keeps every extreme of the range.
+func downsampleCandles(candles []*trademodel.Candle, n int) []*trademodel.Candle {
+	if n <= 0 || len(candles) <= n {
+		return candles
+	}
+	ret := make([]*trademodel.Candle, 0, n)
+	for i := 0; i < n; i++ {
+		start, end := i*len(candles)/n, (i+1)*len(candles)/n
+		if start == end {
+			continue
+		}
+		merged := *candles[start]
+		for _, c := range candles[start+1 : end] {
+			merged.High = math.Max(merged.High, c.High)
+			merged.Low = math.Min(merged.Low, c.Low)
+			merged.Close = c.Close
+			merged.Volume += c.Volume
+			merged.Turnover += c.Turnover
+			merged.Trades += c.Trades
+		}
+		ret = append(ret, &merged)
+	}
+	return ret
+}
+
+// downsampleEquity reduces an equity curve to at most n points with LTTB,
+// using the point time as x.
+func downsampleEquity(points []store.EquityPoint, n int) []store.EquityPoint {
+	if n <= 0 || len(points) <= n {
+		return points
+	}
+	xs := make([]float64, len(points))
+	ys := make([]float64, len(points))
+	for i, p := range points {
+		xs[i] = float64(p.Time.Unix())
+		ys[i] = p.Equity
+	}
+	idx := lttbIndices(xs, ys, n)
+	ret := make([]store.EquityPoint, len(idx))
+	for i, j := range idx {
+		ret[i] = points[j]
+	}
+	return ret
+}
@@ -0,0 +1,83 @@
+package tools
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ztrade/trademodel"
+	"github.com/ztrade/ztrade-mcp/store"
+)
+
+func TestLTTBIndices(t *testing.T) {
+	xs := make([]float64, 100)
+	ys := make([]float64, 100)
+	for i := range xs {
+		xs[i] = float64(i)
+	}
+	ys[37] = 50 // a spike must survive
+	ys[71] = -20
+
+	idx := lttbIndices(xs, ys, 10)
+	if len(idx) != 10 || idx[0] != 0 || idx[9] != 99 {
+		t.Fatalf("indices: %v", idx)
+	}
+	seen := map[int]bool{}
+	for i, j := range idx {
+		if i > 0 && j <= idx[i-1] {
+			t.Fatalf("not increasing: %v", idx)
+		}
+		seen[j] = true
+	}
+	if !seen[37] || !seen[71] {
+		t.Errorf("extremes dropped: %v", idx)
+	}
+	if idx := lttbIndices(xs[:5], ys[:5], 10); len(idx) != 5 {
+		t.Errorf("short series: %v", idx)
+	}
+}
+
+func TestDownsampleEquity(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	points := make([]store.EquityPoint, 50)
+	for i := range points {
+		points[i] = store.EquityPoint{Time: base.Add(time.Duration(i) * time.Hour), Equity: 1000 + float64(i)}
+	}
+	got := downsampleEquity(points, 5)
+	if len(got) != 5 || !got[0].Time.Equal(base) || got[4].Equity != 1049 {
+		t.Fatalf("downsampled: %+v", got)
+	}
+	if got := downsampleEquity(points, 0); len(got) != 50 {
+		t.Errorf("maxPoints 0 changed the curve: %d points", len(got))
+	}
+}
+
+func TestDownsampleCandles(t *testing.T) {
+	var candles []*trademodel.Candle
+	for i := 0; i < 10; i++ {
+		p := float64(100 + i)
+		candles = append(candles, &trademodel.Candle{Start: int64(i) * 60000, Open: p, High: p + 1, Low: p - 1, Close: p + 0.5, Volume: 1})
+	}
+	candles[4].High = 200
+
+	got := downsampleCandles(candles, 3)
+	if len(got) != 3 {
+		t.Fatalf("got %d candles", len(got))
+	}
+	// buckets of 3, 3 and 4 candles
+	first, second, last := got[0], got[1], got[2]
+	if first.Open != 100 || first.Close != 102.5 || first.High != 103 || first.Low != 99 || first.Volume != 3 {
+		t.Errorf("first bucket: %+v", first)
+	}
+	if second.Start != 3*60000 || second.High != 200 {
+		t.Errorf("second bucket: %+v", second)
+	}
+	if last.Close != 109.5 || last.Volume != 4 {
+		t.Errorf("last bucket: %+v", last)
+	}
+	if candles[0].Close != 100.5 {
+		t.Error("input candles modified")
+	}
+	if err := maxPointsArg(2); err == nil {
+		t.Error("maxPoints 2 accepted")
+	}
+}
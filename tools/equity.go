@@ -0,0 +1,191 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/ztrade/ztrade-mcp/internal/stats"
+	"github.com/ztrade/ztrade-mcp/store"
+	"github.com/ztrade/ztrade/pkg/report"
+)
+
+// buildEquityCurve returns the equity after every report action, starting
+// with the initial balance at the backtest start time. This is the same
+// series the report uses for its drawdown metrics.
+func buildEquityCurve(start time.Time, initBalance float64, actions []*report.RptAct) []store.EquityPoint {
+	points := make([]store.EquityPoint, 0, len(actions)+1)
+	points = append(points, store.EquityPoint{Time: start, Equity: initBalance})
+	for _, act := range actions {
+		if act == nil {
+			continue
+		}
+		points = append(points, store.EquityPoint{Time: act.Time, Equity: act.Total})
+	}
+	return points
+}
+
+// backtestTradesFromActions converts report actions to storable trades.
+// excursions are the gate's per-trade excursions; the report drops trailing
+// opening trades only, so they line up with the actions by index.
+func backtestTradesFromActions(actions []*report.RptAct, excursions []tradeExcursion) []store.BacktestTrade {
+	trades := make([]store.BacktestTrade, 0, len(actions))
+	for i, act := range actions {
+		if act == nil {
+			continue
+		}
+		var exc tradeExcursion
+		if i < len(excursions) {
+			exc = excursions[i]
+		}
+		trades = append(trades, store.BacktestTrade{
+			Time:   act.Time,
+			Action: act.Action.String(),
+			Price:  act.Price,
+			Amount: act.Amount,
+			Profit: act.Profit,
+			Fee:    act.Fee,
+			Equity: act.Total,
+			MAE:    exc.MAE,
+			MFE:    exc.MFE,
+		})
+	}
+	return trades
+}
+
+// excursionSummary averages the excursions of closing trades, split into
+// winners and losers. A winners' MAE well below the losers' suggests a stop
+// between the two would cut losers without stopping out winners.
+func excursionSummary(trades []store.BacktestTrade) map[string]interface{} {
+	var winMAE, winMFE, lossMAE, lossMFE []float64
+	for _, t := range trades {
+		switch {
+		case t.Profit > 0:
+			winMAE = append(winMAE, t.MAE)
+			winMFE = append(winMFE, t.MFE)
+		case t.Profit < 0:
+			lossMAE = append(lossMAE, t.MAE)
+			lossMFE = append(lossMFE, t.MFE)
+		}
+	}
+	return map[string]interface{}{
+		"winners":       len(winMAE),
+		"losers":        len(lossMAE),
+		"avgMAEWinners": stats.Mean(winMAE),
+		"avgMAELosers":  stats.Mean(lossMAE),
+		"avgMFEWinners": stats.Mean(winMFE),
+		"avgMFELosers":  stats.Mean(lossMFE),
+	}
+}
+
+// ulcerMetrics returns the Ulcer Index of the equity curve and the Martin
+// ratio (annual return / ulcer index). The ratio is 0 when the curve never
+// goes underwater.
+func ulcerMetrics(points []store.EquityPoint, annualReturn float64) (ulcer, martin float64) {
+	equity := make([]float64, len(points))
+	for i, p := range points {
+		equity[i] = p.Equity
+	}
+	ulcer = stats.UlcerIndex(equity)
+	if ulcer > 0 {
+		martin = annualReturn / ulcer
+	}
+	return ulcer, martin
+}
+
+// reportRiskFreeRate is the annual risk-free rate ztrade's report assumes
+// for its Sharpe and Sortino ratios.
+const reportRiskFreeRate = 0.02
+
+// excessReturnRatios returns the annualized Sharpe and Sortino ratios of the
+// returns between consecutive equity points in excess of riskFreeRate
+// (annual), converted to the average spacing of the points over
+// [start, end). Both are 0 when there are fewer than two returns or no
+// dispersion.
+func excessReturnRatios(points []store.EquityPoint, start, end time.Time, riskFreeRate float64) (sharpe, sortino float64) {
+	if len(points) < 3 {
+		return 0, 0
+	}
+	years := end.Sub(start).Hours() / (24 * 365.25)
+	if years <= 0 {
+		years = 1
+	}
+	periodsPerYear := float64(len(points)-1) / years
+	periodRate := math.Pow(1+riskFreeRate, 1/periodsPerYear) - 1
+
+	excess := make([]float64, 0, len(points)-1)
+	var downside float64
+	for i := 1; i < len(points); i++ {
+		if points[i-1].Equity == 0 {
+			continue
+		}
+		x := points[i].Equity/points[i-1].Equity - 1 - periodRate
+		excess = append(excess, x)
+		if x < 0 {
+			downside += x * x
+		}
+	}
+	if len(excess) < 2 {
+		return 0, 0
+	}
+	mean := stats.Mean(excess)
+	annualize := math.Sqrt(periodsPerYear)
+	if sd := stats.StdDev(excess); sd > 0 {
+		sharpe = mean / sd * annualize
+	}
+	if dd := math.Sqrt(downside / float64(len(excess))); dd > 0 {
+		sortino = mean / dd * annualize
+	}
+	return sharpe, sortino
+}
+
+func registerGetEquityCurve(s *server.MCPServer, st *store.Store) {
+	tool := mcp.NewTool("get_equity_curve",
+		mcp.WithDescription("Get the stored equity curve (equity after each trade) of a backtest record saved by run_backtest_managed."),
+		mcp.WithNumber("recordId", mcp.Required(), mcp.Description("Backtest record ID")),
+		mcp.WithNumber("maxPoints", mcp.Description("Downsample the curve to at most this many points with LTTB (largest triangle three buckets), which keeps peaks, troughs and the first/last point. originalCount reports the count before downsampling. Default: 0 (no downsampling)")),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if st == nil {
+			return toolError(codeUnavailable, "script store not initialized (check database config)"), nil
+		}
+
+		recordID := int64(req.GetFloat("recordId", 0))
+		maxPoints := int(req.GetFloat("maxPoints", 0))
+		if err := maxPointsArg(maxPoints); err != nil {
+			return toolError(codeInvalidArgument, err.Error()), nil
+		}
+		if _, _, err := accessibleRecord(ctx, st, recordID); err != nil {
+			return toolErrorf(storeErrorCode(err), "failed to get record: %s", err.Error()), nil
+		}
+		points, err := st.ListBacktestEquity(recordID)
+		if err != nil {
+			return toolErrorf(storeErrorCode(err), "failed to get equity curve: %s", err.Error()), nil
+		}
+		originalCount := len(points)
+		points = downsampleEquity(points, maxPoints)
+
+		type equityEntry struct {
+			Time   string  `json:"time"`
+			Equity float64 `json:"equity"`
+		}
+		entries := make([]equityEntry, 0, len(points))
+		for _, p := range points {
+			entries = append(entries, equityEntry{Time: p.Time.Format("2006-01-02 15:04:05"), Equity: p.Equity})
+		}
+		result := map[string]interface{}{
+			"recordId": recordID,
+			"count":    len(entries),
+			"points":   entries,
+		}
+		if maxPoints > 0 {
+			result["originalCount"] = originalCount
+		}
+		data, _ := json.MarshalIndent(result, "", "  ")
+		return mcp.NewToolResultText(string(data)), nil
+	})
+}
@@ -0,0 +1,76 @@
+package tools
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/ztrade/trademodel"
+	"github.com/ztrade/ztrade-mcp/internal/stats"
+	"github.com/ztrade/ztrade-mcp/store"
+	"github.com/ztrade/ztrade/pkg/report"
+)
+
+func TestBuildEquityCurveAndUlcerMetrics(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	actions := []*report.RptAct{
+		{Trade: trademodel.Trade{Time: start.Add(time.Hour)}, Total: 100},
+		{Trade: trademodel.Trade{Time: start.Add(2 * time.Hour)}, Total: 90},
+		{Trade: trademodel.Trade{Time: start.Add(3 * time.Hour)}, Total: 80},
+		{Trade: trademodel.Trade{Time: start.Add(4 * time.Hour)}, Total: 100},
+	}
+	points := buildEquityCurve(start, 100, actions)
+	if len(points) != 5 || !points[0].Time.Equal(start) || points[0].Equity != 100 || points[3].Equity != 80 {
+		t.Fatalf("unexpected equity curve: %+v", points)
+	}
+	ulcer, martin := ulcerMetrics(points, 0.5)
+	want := math.Sqrt(0.05 / 5)
+	if math.Abs(ulcer-want) > 1e-12 || math.Abs(martin-0.5/want) > 1e-9 {
+		t.Fatalf("ulcer=%v martin=%v", ulcer, martin)
+	}
+	if _, martin := ulcerMetrics(points[:2], 0.5); martin != 0 {
+		t.Fatalf("martin should be 0 without drawdown, got %v", martin)
+	}
+}
+
+func TestExcessReturnRatios(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(36525 * 24 * time.Hour / 100) // one year as the report counts it
+	var points []store.EquityPoint
+	equity := 100.0
+	for i, r := range []float64{0, 0.02, -0.01, 0.03, -0.02, 0.01} {
+		equity *= 1 + r
+		points = append(points, store.EquityPoint{Time: start.AddDate(0, 2*i, 0), Equity: equity})
+	}
+	sharpe0, sortino0 := excessReturnRatios(points, start, end, 0)
+	if sharpe0 <= 0 || sortino0 <= sharpe0 {
+		t.Fatalf("rf=0: sharpe %v sortino %v", sharpe0, sortino0)
+	}
+	sharpe, sortino := excessReturnRatios(points, start, end, 0.05)
+	if sharpe >= sharpe0 || sortino >= sortino0 {
+		t.Fatalf("a higher risk-free rate should lower both ratios: %v/%v vs %v/%v", sharpe, sortino, sharpe0, sortino0)
+	}
+
+	// with 5 returns a year the periodic rate is (1.05)^(1/5)-1
+	rp := math.Pow(1.05, 0.2) - 1
+	excess := []float64{0.02 - rp, -0.01 - rp, 0.03 - rp, -0.02 - rp, 0.01 - rp}
+	if want := stats.Mean(excess) / stats.StdDev(excess) * math.Sqrt(5); math.Abs(sharpe-want) > 1e-9 {
+		t.Fatalf("sharpe = %v, want %v", sharpe, want)
+	}
+	if s, so := excessReturnRatios(points[:2], start, end, 0); s != 0 || so != 0 {
+		t.Fatalf("single return should give zero ratios: %v %v", s, so)
+	}
+}
+
+func TestExcursionSummary(t *testing.T) {
+	actions := []*report.RptAct{{}, {Profit: 10}, {}, {Profit: -5}, {}, {Profit: 20}}
+	excursions := []tradeExcursion{{}, {MAE: 1, MFE: 3}, {}, {MAE: 4, MFE: 0.5}, {}, {MAE: 2, MFE: 5}}
+	trades := backtestTradesFromActions(actions, excursions)
+	if trades[3].MAE != 4 || trades[3].MFE != 0.5 {
+		t.Fatalf("trade excursion: %+v", trades[3])
+	}
+	got := excursionSummary(trades)
+	if got["winners"] != 2 || got["losers"] != 1 || got["avgMAEWinners"] != 1.5 || got["avgMAELosers"] != 4.0 || got["avgMFEWinners"] != 4.0 {
+		t.Fatalf("summary: %+v", got)
+	}
+}
@@ -0,0 +1,60 @@
+package tools
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/ztrade/ztrade-mcp/store"
+)
+
+// Tool error codes. Every tool error is returned as
+// {"error": {"code": ..., "message": ...}}; the codes are stable so clients
+// can branch on them, the message is for humans.
+const (
+	codeInvalidArgument    = "invalid_argument"    // bad or missing parameter
+	codeNotFound           = "not_found"           // strategy, record, task, trade or exchange does not exist
+	codeUnavailable        = "unavailable"         // a backing service (database, store) is not configured
+	codePermissionDenied   = "permission_denied"   // the caller's role may not do this
+	codeFailedPrecondition = "failed_precondition" // the current state forbids it, e.g. live trading disabled
+	codeResourceExhausted  = "resource_exhausted"  // a quota is used up
+	codeUpstream           = "upstream_error"      // an exchange or python-runner call failed
+	codeBuildFailed        = "build_failed"        // compiling a strategy failed
+	codeTaskFailed         = "task_failed"         // an async task finished with an error
+	codeDeadlineExceeded   = "deadline_exceeded"   // the call ran past its mcp.toolTimeouts limit
+	codeInternal           = "internal"            // anything else
+)
+
+type toolErrorBody struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// toolError returns an error result carrying code and message.
+func toolError(code, message string) *mcp.CallToolResult {
+	data, _ := json.Marshal(map[string]toolErrorBody{"error": {Code: code, Message: message}})
+	return mcp.NewToolResultError(string(data))
+}
+
+// toolErrorf is toolError with a formatted message.
+func toolErrorf(code, format string, args ...interface{}) *mcp.CallToolResult {
+	return toolError(code, fmt.Sprintf(format, args...))
+}
+
+// storeErrorCode is the code for an error returned by the script store.
+func storeErrorCode(err error) string {
+	if errors.Is(err, store.ErrNotFound) {
+		return codeNotFound
+	}
+	if errors.Is(err, store.ErrQuotaExceeded) {
+		return codeResourceExhausted
+	}
+	if errors.Is(err, store.ErrNotOwner) {
+		return codePermissionDenied
+	}
+	if errors.Is(err, store.ErrScriptInUse) {
+		return codeFailedPrecondition
+	}
+	return codeInternal
+}
@@ -0,0 +1,37 @@
+package tools
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/ztrade/ztrade-mcp/store"
+)
+
+func TestToolError(t *testing.T) {
+	res := toolErrorf(codeInvalidArgument, "invalid start time: %s", "bad")
+	if !res.IsError {
+		t.Fatal("result is not an error")
+	}
+	var body struct {
+		Error toolErrorBody `json:"error"`
+	}
+	text := res.Content[0].(mcp.TextContent).Text
+	if err := json.Unmarshal([]byte(text), &body); err != nil {
+		t.Fatalf("error result is not JSON: %s", text)
+	}
+	if body.Error.Code != codeInvalidArgument || body.Error.Message != "invalid start time: bad" {
+		t.Errorf("unexpected error body %+v", body.Error)
+	}
+}
+
+func TestStoreErrorCode(t *testing.T) {
+	if got := storeErrorCode(fmt.Errorf("load: %w", store.ErrNotFound)); got != codeNotFound {
+		t.Errorf("wrapped ErrNotFound: got %s", got)
+	}
+	if got := storeErrorCode(errors.New("connection refused")); got != codeInternal {
+		t.Errorf("other error: got %s", got)
+	}
+}
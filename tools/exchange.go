@@ -8,14 +8,31 @@ import (
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 	"github.com/spf13/viper"
+	"github.com/ztrade/exchange"
 )
 
+// newExchangeClient creates a client for the exchange configured under
+// exchanges.<name>.
+func newExchangeClient(cfg *viper.Viper, name string) (exchange.Exchange, error) {
+	cfg = exchangeConfig(cfg)
+	exchangeType := cfg.GetString(fmt.Sprintf("exchanges.%s.type", name))
+	if exchangeType == "" {
+		return nil, fmt.Errorf("exchange '%s' not found in config. Use list_exchanges to see configured exchanges.", name)
+	}
+	ex, err := exchange.NewExchange(exchangeType, exchange.WrapViper(cfg), name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create exchange client: %s", err.Error())
+	}
+	return ex, nil
+}
+
 func registerListExchanges(s *server.MCPServer, cfg *viper.Viper) {
 	tool := mcp.NewTool("list_exchanges",
 		mcp.WithDescription("List all configured exchanges from the config file. Returns exchange name, type, kind, and whether API keys are set."),
 	)
 
 	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		cfg := exchangeConfig(cfg)
 		exchangesCfg := cfg.GetStringMap("exchanges")
 		if len(exchangesCfg) == 0 {
 			return mcp.NewToolResultText("No exchanges configured."), nil
@@ -40,7 +57,7 @@ func registerListExchanges(s *server.MCPServer, cfg *viper.Viper) {
 
 		data, err := json.MarshalIndent(result, "", "  ")
 		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("failed to marshal result: %s", err.Error())), nil
+			return toolErrorf(codeInternal, "failed to marshal result: %s", err.Error()), nil
 		}
 		return mcp.NewToolResultText(string(data)), nil
 	})
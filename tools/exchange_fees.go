@@ -0,0 +1,167 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	gobinance "github.com/adshao/go-binance/v2"
+	bfutures "github.com/adshao/go-binance/v2/futures"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/spf13/viper"
+)
+
+// feeCacheTTL is how long a fetched fee schedule is reused. Fees change
+// with the account tier, which moves at most daily.
+const feeCacheTTL = 10 * time.Minute
+
+// errFeesUnsupported is returned for exchanges that cannot be asked for
+// the account's fees.
+var errFeesUnsupported = errors.New("fee lookup not supported")
+
+// exchangeFees are the maker and taker fee rates of an account for a symbol.
+type exchangeFees struct {
+	MakerFee float64
+	TakerFee float64
+	Fetched  time.Time
+}
+
+// feeCache keeps fetched fees per exchange config name and symbol.
+type feeCache struct {
+	mu      sync.Mutex
+	entries map[string]exchangeFees
+	fetch   func(ctx context.Context, cfg *viper.Viper, exchangeName, symbol string) (maker, taker float64, err error)
+	now     func() time.Time
+}
+
+var accountFees = &feeCache{
+	entries: make(map[string]exchangeFees),
+	fetch:   fetchExchangeFees,
+	now:     time.Now,
+}
+
+// get returns the fees of symbol on exchangeName and whether they came from
+// the cache. Failed fetches are not cached.
+func (c *feeCache) get(ctx context.Context, cfg *viper.Viper, exchangeName, symbol string, refresh bool) (exchangeFees, bool, error) {
+	key := exchangeName + "/" + symbol
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && !refresh && c.now().Sub(e.Fetched) < feeCacheTTL {
+		return e, true, nil
+	}
+	maker, taker, err := c.fetch(ctx, cfg, exchangeName, symbol)
+	if err != nil {
+		return exchangeFees{}, false, err
+	}
+	e = exchangeFees{MakerFee: maker, TakerFee: taker, Fetched: c.now()}
+	c.mu.Lock()
+	c.entries[key] = e
+	c.mu.Unlock()
+	return e, false, nil
+}
+
+// fetchExchangeFees asks the exchange configured under exchanges.<name> for
+// the account's commission rates on symbol. Only binance futures and spot
+// expose them; other exchanges, and accounts without an API key, return an
+// error matching errFeesUnsupported.
+func fetchExchangeFees(ctx context.Context, cfg *viper.Viper, exchangeName, symbol string) (maker, taker float64, err error) {
+	cfg = exchangeConfig(cfg)
+	prefix := "exchanges." + exchangeName
+	exchangeType, kind := cfg.GetString(prefix+".type"), cfg.GetString(prefix+".kind")
+	if exchangeType == "" {
+		return 0, 0, fmt.Errorf("exchange '%s' not found in config. Use list_exchanges to see configured exchanges.", exchangeName)
+	}
+	if exchangeType != "binance" || (kind != "futures" && kind != "spot") {
+		return 0, 0, fmt.Errorf("%w for exchange type %s kind %s (supported: binance futures/spot)", errFeesUnsupported, exchangeType, kind)
+	}
+	key, secret := cfg.GetString(prefix+".key"), cfg.GetString(prefix+".secret")
+	if key == "" || secret == "" {
+		return 0, 0, fmt.Errorf("%w: exchange '%s' has no API key/secret configured, and fees depend on the account", errFeesUnsupported, exchangeName)
+	}
+	httpClient, err := binanceHTTPClient(cfg.GetString("proxy"))
+	if err != nil {
+		return 0, 0, err
+	}
+	isTest := cfg.GetBool(prefix + ".isTest")
+	if kind == "futures" {
+		return fetchBinanceFuturesFees(ctx, httpClient, key, secret, isTest, symbol)
+	}
+	return fetchBinanceSpotFees(ctx, httpClient, key, secret, isTest, symbol)
+}
+
+func fetchBinanceFuturesFees(ctx context.Context, httpClient *http.Client, key, secret string, isTest bool, symbol string) (maker, taker float64, err error) {
+	client := bfutures.NewClient(key, secret)
+	if isTest {
+		client.BaseURL = bfutures.BaseApiTestnetUrl
+	}
+	if httpClient != nil {
+		client.HTTPClient = httpClient
+	}
+	rate, err := client.NewCommissionRateService().Symbol(symbol).Do(ctx)
+	if err != nil {
+		return 0, 0, fmt.Errorf("fetch commission rate failed: %w", err)
+	}
+	return parseFloatOrZero(rate.MakerCommissionRate), parseFloatOrZero(rate.TakerCommissionRate), nil
+}
+
+func fetchBinanceSpotFees(ctx context.Context, httpClient *http.Client, key, secret string, isTest bool, symbol string) (maker, taker float64, err error) {
+	client := gobinance.NewClient(key, secret)
+	if isTest {
+		client.BaseURL = gobinance.BaseAPITestnetURL
+	}
+	if httpClient != nil {
+		client.HTTPClient = httpClient
+	}
+	rates, err := client.NewGetCommissionRatesService().Symbol(symbol).Do(ctx)
+	if err != nil {
+		return 0, 0, fmt.Errorf("fetch commission rate failed: %w", err)
+	}
+	return parseFloatOrZero(rates.StandardCommission.Maker), parseFloatOrZero(rates.StandardCommission.Taker), nil
+}
+
+func registerGetExchangeFees(s *server.MCPServer, cfg *viper.Viper) {
+	tool := mcp.NewTool("get_exchange_fees",
+		mcp.WithDescription(fmt.Sprintf("Get the account's current maker/taker fee rates for a symbol from the exchange (binance futures and spot, API key required), to pass as fee and makerFee to the backtest tools. Results are cached for %s. Exchanges that do not expose fees fall back to the configured backtest defaults (mcp.backtest.defaults), marked source=config.", feeCacheTTL)),
+		mcp.WithString("exchange", mcp.Required(), mcp.Description("Exchange config name (e.g., binance)")),
+		mcp.WithString("symbol", mcp.Required(), mcp.Description("Trading pair (e.g., BTCUSDT)")),
+		mcp.WithBoolean("refresh", mcp.Description("Ignore the cached fees and ask the exchange again. Default: false")),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		exchangeName := req.GetString("exchange", "")
+		symbol := req.GetString("symbol", "")
+		if exchangeName == "" || symbol == "" {
+			return toolError(codeInvalidArgument, "exchange and symbol are required"), nil
+		}
+
+		result := map[string]interface{}{
+			"exchange": exchangeName,
+			"symbol":   symbol,
+		}
+		fees, cached, err := accountFees.get(ctx, cfg, exchangeName, symbol, req.GetBool("refresh", false))
+		switch {
+		case errors.Is(err, errFeesUnsupported):
+			_, defaults := backtestDefaults(cfg, exchangeName)
+			fees = exchangeFees{MakerFee: defaults.MakerFee, TakerFee: defaults.TakerFee}
+			result["source"] = "config"
+			result["message"] = err.Error() + "; returning the configured backtest defaults, check the exchange's fee page for your tier"
+		case err != nil:
+			return toolError(codeUpstream, err.Error()), nil
+		default:
+			result["source"] = "exchange"
+			result["cached"] = cached
+			result["fetchedAt"] = fees.Fetched.Format("2006-01-02 15:04:05")
+		}
+		result["makerFee"] = fees.MakerFee
+		result["takerFee"] = fees.TakerFee
+		result["backtestArgs"] = map[string]float64{"fee": fees.TakerFee, "makerFee": fees.MakerFee}
+		data, _ := json.MarshalIndent(result, "", "  ")
+		return mcp.NewToolResultText(string(data)), nil
+	})
+}
@@ -0,0 +1,58 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func TestFeeCache(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	calls := 0
+	c := &feeCache{
+		entries: make(map[string]exchangeFees),
+		now:     func() time.Time { return now },
+		fetch: func(ctx context.Context, cfg *viper.Viper, exchangeName, symbol string) (float64, float64, error) {
+			calls++
+			if symbol == "BAD" {
+				return 0, 0, errors.New("boom")
+			}
+			return 0.0002, 0.0004, nil
+		},
+	}
+	ctx := context.Background()
+	if f, cached, err := c.get(ctx, nil, "binance", "BTCUSDT", false); err != nil || cached || f.TakerFee != 0.0004 || f.MakerFee != 0.0002 {
+		t.Fatalf("first: %+v %v %v", f, cached, err)
+	}
+	if _, cached, _ := c.get(ctx, nil, "binance", "BTCUSDT", false); !cached || calls != 1 {
+		t.Fatalf("second: cached=%v calls=%d", cached, calls)
+	}
+	if _, cached, _ := c.get(ctx, nil, "binance", "BTCUSDT", true); cached || calls != 2 {
+		t.Fatalf("refresh: cached=%v calls=%d", cached, calls)
+	}
+	now = now.Add(feeCacheTTL)
+	if _, cached, _ := c.get(ctx, nil, "binance", "BTCUSDT", false); cached || calls != 3 {
+		t.Fatalf("expired: cached=%v calls=%d", cached, calls)
+	}
+	if _, _, err := c.get(ctx, nil, "binance", "BAD", false); err == nil || len(c.entries) != 1 {
+		t.Fatalf("failed fetch cached: %v %v", err, c.entries)
+	}
+}
+
+func TestFetchExchangeFeesUnsupported(t *testing.T) {
+	cfg := viper.New()
+	cfg.Set("exchanges.okx.type", "okx")
+	cfg.Set("exchanges.bn.type", "binance")
+	cfg.Set("exchanges.bn.kind", "futures")
+	for _, name := range []string{"okx", "bn"} {
+		if _, _, err := fetchExchangeFees(context.Background(), cfg, name, "BTCUSDT"); !errors.Is(err, errFeesUnsupported) {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	if _, _, err := fetchExchangeFees(context.Background(), cfg, "missing", "BTCUSDT"); err == nil || errors.Is(err, errFeesUnsupported) {
+		t.Errorf("missing exchange: %v", err)
+	}
+}
@@ -0,0 +1,70 @@
+package tools
+
+import (
+	"sync"
+
+	"github.com/spf13/viper"
+)
+
+// exchangeRegistry holds the server config as seen by the exchange tools:
+// a copy that is never modified once published. add_exchange builds a new
+// copy with the entry added and swaps it in whole, so readers, and running
+// trades holding an older copy, never race a viper Set.
+type exchangeRegistry struct {
+	mu     sync.RWMutex
+	source *viper.Viper // the server config the copy was made from
+	snap   *viper.Viper
+}
+
+var exchangeConfigs exchangeRegistry
+
+// copyConfig returns a new viper holding settings.
+func copyConfig(settings map[string]interface{}) *viper.Viper {
+	v := viper.New()
+	_ = v.MergeConfigMap(settings)
+	return v
+}
+
+// load registers cfg as the server config and copies its settings.
+func (r *exchangeRegistry) load(cfg *viper.Viper) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.source, r.snap = cfg, copyConfig(cfg.AllSettings())
+}
+
+// config returns the current copy of the server config cfg, or cfg itself
+// for any other config, such as the scratch config of a validation.
+func (r *exchangeRegistry) config(cfg *viper.Viper) *viper.Viper {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.source == nil || r.source != cfg {
+		return cfg
+	}
+	return r.snap
+}
+
+// set publishes a copy of the server config cfg with exchanges.<name>
+// replaced by entry.
+func (r *exchangeRegistry) set(cfg *viper.Viper, name string, entry map[string]interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.source != cfg {
+		r.source, r.snap = cfg, copyConfig(cfg.AllSettings())
+	}
+	settings := r.snap.AllSettings()
+	exchanges := map[string]interface{}{}
+	if all, ok := settings["exchanges"].(map[string]interface{}); ok {
+		for k, v := range all {
+			exchanges[k] = v
+		}
+	}
+	exchanges[name] = entry
+	settings["exchanges"] = exchanges
+	r.snap = copyConfig(settings)
+}
+
+// exchangeConfig returns the config to read exchanges.* (and the settings
+// exchange clients use) from: the latest copy when cfg is the server config.
+func exchangeConfig(cfg *viper.Viper) *viper.Viper {
+	return exchangeConfigs.config(cfg)
+}
@@ -0,0 +1,52 @@
+package tools
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestExchangeRegistry(t *testing.T) {
+	cfg := viper.New()
+	cfg.Set("proxy", "http://127.0.0.1:8080")
+	cfg.Set("exchanges.bn.type", "binance")
+	cfg.Set("exchanges.bn.kind", "futures")
+
+	var r exchangeRegistry
+	if got := r.config(cfg); got != cfg {
+		t.Fatal("unregistered config not returned as is")
+	}
+	r.load(cfg)
+	before := r.config(cfg)
+	if before == cfg || before.GetString("exchanges.bn.kind") != "futures" || before.GetString("proxy") != "http://127.0.0.1:8080" {
+		t.Fatal("registered config not copied")
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				if r.config(cfg).GetString("exchanges.bn.type") != "binance" {
+					t.Error("exchange lost while another was added")
+					return
+				}
+			}
+		}()
+	}
+	r.set(cfg, "ok", map[string]interface{}{"type": "okx", "isTest": true})
+	wg.Wait()
+
+	after := r.config(cfg)
+	if after.GetString("exchanges.ok.type") != "okx" || !after.GetBool("exchanges.ok.isTest") || after.GetString("exchanges.bn.type") != "binance" {
+		t.Fatalf("after set: %v", after.AllSettings())
+	}
+	if len(after.GetStringMap("exchanges")) != 2 {
+		t.Fatalf("exchanges: %v", after.GetStringMap("exchanges"))
+	}
+	if before.IsSet("exchanges.ok") || cfg.IsSet("exchanges.ok") {
+		t.Fatal("set modified a published copy or the source config")
+	}
+}
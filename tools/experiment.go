@@ -0,0 +1,122 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/ztrade/ztrade-mcp/auth"
+	"github.com/ztrade/ztrade-mcp/store"
+)
+
+// maxRunLabelLen is the size of the run label column.
+const maxRunLabelLen = 100
+
+// varyingParams returns the names of the JSON params whose values differ
+// between records, set in some and not in others included. Records whose
+// param is not a JSON object are skipped.
+func varyingParams(records []store.BacktestRecord) []string {
+	var parsed []map[string]interface{}
+	keys := map[string]bool{}
+	for _, r := range records {
+		var params map[string]interface{}
+		if err := json.Unmarshal([]byte(r.Param), &params); err != nil {
+			continue
+		}
+		parsed = append(parsed, params)
+		for k := range params {
+			keys[k] = true
+		}
+	}
+	var names []string
+	for k := range keys {
+		seen := map[string]bool{}
+		for _, params := range parsed {
+			v, ok := params[k]
+			seen[fmt.Sprint(ok, v)] = true
+		}
+		if len(seen) > 1 {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func registerGetExperiment(s *server.MCPServer, st *store.Store) {
+	tool := mcp.NewTool("get_experiment",
+		mcp.WithDescription("Get all backtest records sharing a run label (run_backtest_managed runLabel), across strategies, ranked by overall score, with the strategy_performance summary over the group and the params that vary between runs."),
+		mcp.WithString("runLabel", mcp.Required(), mcp.Description("Run label of the experiment")),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if st == nil {
+			return toolError(codeUnavailable, "script store not initialized (check database config)"), nil
+		}
+
+		runLabel := req.GetString("runLabel", "")
+		if runLabel == "" {
+			return toolError(codeInvalidArgument, "runLabel is required"), nil
+		}
+		records, summary, err := st.GetExperiment(runLabel, auth.ScopedUser(ctx))
+		if err != nil {
+			return toolErrorf(storeErrorCode(err), "failed to get experiment: %s", err.Error()), nil
+		}
+
+		type experimentRun struct {
+			Rank          int     `json:"rank"`
+			ID            int64   `json:"id"`
+			StrategyID    int64   `json:"strategyId"`
+			ScriptVersion int     `json:"scriptVersion"`
+			Exchange      string  `json:"exchange"`
+			Symbol        string  `json:"symbol"`
+			StartTime     string  `json:"startTime"`
+			EndTime       string  `json:"endTime"`
+			Param         string  `json:"param,omitempty"`
+			WinRate       float64 `json:"winRate"`
+			TotalReturn   float64 `json:"totalReturn"`
+			SharpeRatio   float64 `json:"sharpeRatio"`
+			MaxDrawdown   float64 `json:"maxDrawdown"`
+			OverallScore  float64 `json:"overallScore"`
+			CreatedAt     string  `json:"createdAt"`
+		}
+		ranked := append([]store.BacktestRecord(nil), records...)
+		sort.SliceStable(ranked, func(i, j int) bool { return ranked[i].OverallScore > ranked[j].OverallScore })
+		strategies := map[int64]bool{}
+		runs := make([]experimentRun, 0, len(ranked))
+		for i, r := range ranked {
+			strategies[r.ScriptID] = true
+			runs = append(runs, experimentRun{
+				Rank:          i + 1,
+				ID:            r.ID,
+				StrategyID:    r.ScriptID,
+				ScriptVersion: r.ScriptVersion,
+				Exchange:      r.Exchange,
+				Symbol:        r.Symbol,
+				StartTime:     r.StartTime.Format("2006-01-02 15:04:05"),
+				EndTime:       r.EndTime.Format("2006-01-02 15:04:05"),
+				Param:         r.Param,
+				WinRate:       r.WinRate,
+				TotalReturn:   r.TotalReturn,
+				SharpeRatio:   r.SharpeRatio,
+				MaxDrawdown:   r.MaxDrawdown,
+				OverallScore:  r.OverallScore,
+				CreatedAt:     r.CreatedAt.Format("2006-01-02 15:04:05"),
+			})
+		}
+
+		result := map[string]interface{}{
+			"runLabel":      runLabel,
+			"total":         len(runs),
+			"strategies":    len(strategies),
+			"summary":       summary,
+			"varyingParams": varyingParams(records),
+			"runs":          runs,
+		}
+		data, _ := json.MarshalIndent(result, "", "  ")
+		return mcp.NewToolResultText(string(data)), nil
+	})
+}
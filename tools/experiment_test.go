@@ -0,0 +1,23 @@
+package tools
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ztrade/ztrade-mcp/store"
+)
+
+func TestVaryingParams(t *testing.T) {
+	records := []store.BacktestRecord{
+		{Param: `{"fast":5,"slow":20,"symbol":"BTC"}`},
+		{Param: `{"fast":8,"slow":20,"symbol":"BTC"}`},
+		{Param: `{"fast":5,"slow":20,"symbol":"BTC","stop":0.02}`},
+		{Param: ``},
+	}
+	if got := varyingParams(records); !reflect.DeepEqual(got, []string{"fast", "stop"}) {
+		t.Fatalf("varyingParams = %v", got)
+	}
+	if got := varyingParams(records[:1]); got != nil {
+		t.Fatalf("single record: %v", got)
+	}
+}
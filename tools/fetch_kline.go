@@ -10,26 +10,101 @@ import (
 	"github.com/mark3labs/mcp-go/server"
 	"github.com/spf13/viper"
 	"github.com/ztrade/exchange"
+	"github.com/ztrade/trademodel"
+	"github.com/ztrade/ztrade-mcp/auth"
+	"github.com/ztrade/ztrade/pkg/process/dbstore"
 )
 
-func registerFetchKline(s *server.MCPServer, cfg *viper.Viper) {
+// saveFetchedKlines upserts candles into the local database and returns how
+// many of them were not stored before.
+func saveFetchedKlines(db *dbstore.DBStore, exchangeName, symbol, binSize string, candles []*trademodel.Candle) (int64, error) {
+	if len(candles) == 0 {
+		return 0, nil
+	}
+	tbl := db.GetKlineTbl(exchangeName, symbol, binSize)
+	before, err := tbl.Count()
+	if err != nil {
+		return 0, err
+	}
+	datas := make([]interface{}, len(candles))
+	for i, c := range candles {
+		datas[i] = c
+	}
+	if err := tbl.WriteDatas(datas); err != nil {
+		return 0, err
+	}
+	after, err := tbl.Count()
+	if err != nil {
+		return 0, err
+	}
+	return after - before, nil
+}
+
+// localKlineFallback answers a fetch_kline call whose exchange request
+// failed with fetchErr from the local database, through the query_kline
+// path. Without local candles in the range the exchange error is returned.
+func localKlineFallback(db *dbstore.DBStore, exchangeName, symbol, binSize string, start, end time.Time, limit int, fetchErr error) *mcp.CallToolResult {
+	if db == nil {
+		return toolErrorf(codeUpstream, "%s; no local fallback: database not initialized", fetchErr.Error())
+	}
+	q := klineQuery{exchange: exchangeName, binSize: binSize, candleType: candleTypeNormal, start: start, end: end, limit: limit}
+	series, err := q.load(db, symbol)
+	if err != nil {
+		return toolErrorf(codeUpstream, "%s; local fallback failed: %s", fetchErr.Error(), err.Error())
+	}
+	if len(series.candles) == 0 {
+		return toolErrorf(codeUpstream, "%s; no local candles in the range to fall back to", fetchErr.Error())
+	}
+	result := map[string]interface{}{
+		"exchange":       exchangeName,
+		"symbol":         series.symbol,
+		"binSize":        binSize,
+		"sourceBinSize":  series.sourceBinSize,
+		"count":          len(series.candles),
+		"candles":        series.candles,
+		"source":         "local",
+		"fallbackReason": fetchErr.Error(),
+	}
+	if series.symbol != symbol {
+		result["requestedSymbol"] = symbol
+	}
+	data, _ := json.MarshalIndent(result, "", "  ")
+	return mcp.NewToolResultText(string(data))
+}
+
+func registerFetchKline(s *server.MCPServer, cfg *viper.Viper, db *dbstore.DBStore) {
 	tool := mcp.NewTool("fetch_kline",
-		mcp.WithDescription("Fetch K-line (candlestick) data directly from an exchange API without saving to local database. Useful for quick analysis or checking recent market data."),
+		mcp.WithDescription("Fetch K-line (candlestick) data directly from an exchange API. Nothing is saved unless save=true. Useful for quick analysis or checking recent market data."),
 		mcp.WithString("exchange", mcp.Required(), mcp.Description("Exchange config name (e.g., binance, okx). Must be configured in the config file.")),
-		mcp.WithString("symbol", mcp.Required(), mcp.Description("Trading pair (e.g., BTCUSDT)")),
+		mcp.WithString("symbol", mcp.Required(), mcp.Description("Trading pair in the exchange's native form or any common notation (BTCUSDT, BTC-USDT, BTC/USDT), which is mapped to the native symbol")),
 		mcp.WithString("binSize", mcp.Description("K-line period (1m/5m/15m/1h/4h/1d). Default: 1m")),
 		mcp.WithString("start", mcp.Required(), mcp.Description("Start time in format '2006-01-02 15:04:05'")),
 		mcp.WithString("end", mcp.Description("End time in format '2006-01-02 15:04:05'. Default: now")),
 		mcp.WithNumber("limit", mcp.Description("Maximum number of candles to return. Default: 500, Max: 1500")),
+		mcp.WithBoolean("save", mcp.Description("Also upsert the fetched candles into the local database, like download_kline. Default: false")),
+		mcp.WithBoolean("fallbackLocal", mcp.Description("When the exchange call fails, serve the range from the local database as query_kline would (the first limit candles from start) instead of failing. The result's source is then local and fallbackReason holds the exchange error. Default: false")),
 	)
 
 	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		cfg := exchangeConfig(cfg)
 		exchangeName := req.GetString("exchange", "")
 		symbol := req.GetString("symbol", "")
 		binSize := req.GetString("binSize", "")
 		startStr := req.GetString("start", "")
 		endStr := req.GetString("end", "")
 		limitF := req.GetFloat("limit", 0)
+		save := req.GetBool("save", false)
+		fallbackLocal := req.GetBool("fallbackLocal", false)
+
+		if save {
+			if db == nil {
+				return toolError(codeUnavailable, "database not initialized"), nil
+			}
+			// saving is what download_kline does, so it needs the same permission
+			if user := auth.UserFromContext(ctx); user != nil && !auth.HasPermission(user.Role, "download_kline") {
+				return toolErrorf(codePermissionDenied, "permission denied: role '%s' cannot save klines", user.Role), nil
+			}
+		}
 
 		if binSize == "" {
 			binSize = "1m"
@@ -44,14 +119,14 @@ func registerFetchKline(s *server.MCPServer, cfg *viper.Viper) {
 
 		start, err := time.Parse("2006-01-02 15:04:05", startStr)
 		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("invalid start time: %s", err.Error())), nil
+			return toolErrorf(codeInvalidArgument, "invalid start time: %s", err.Error()), nil
 		}
 
 		var end time.Time
 		if endStr != "" {
 			end, err = time.Parse("2006-01-02 15:04:05", endStr)
 			if err != nil {
-				return mcp.NewToolResultError(fmt.Sprintf("invalid end time: %s", err.Error())), nil
+				return toolErrorf(codeInvalidArgument, "invalid end time: %s", err.Error()), nil
 			}
 		} else {
 			end = time.Now()
@@ -60,20 +135,38 @@ func registerFetchKline(s *server.MCPServer, cfg *viper.Viper) {
 		// Get exchange type from config
 		exchangeType := cfg.GetString(fmt.Sprintf("exchanges.%s.type", exchangeName))
 		if exchangeType == "" {
-			return mcp.NewToolResultError(fmt.Sprintf("exchange '%s' not found in config. Use list_exchanges to see configured exchanges.", exchangeName)), nil
+			return toolErrorf(codeNotFound, "exchange '%s' not found in config. Use list_exchanges to see configured exchanges.", exchangeName), nil
 		}
 
+		requested := symbol
+		symbol = resolveExchangeSymbol(ctx, cfg, exchangeName, symbol)
+
 		// Create exchange client
 		exchangeCfg := exchange.WrapViper(cfg)
 		ex, err := exchange.NewExchange(exchangeType, exchangeCfg, exchangeName)
 		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("failed to create exchange client: %s", err.Error())), nil
+			err = fmt.Errorf("failed to create exchange client: %w", err)
+			if fallbackLocal {
+				return localKlineFallback(db, exchangeName, requested, binSize, start, end, limit, err), nil
+			}
+			return toolError(codeUpstream, err.Error()), nil
 		}
 
-		// Fetch kline data from exchange API
-		candles, err := ex.GetKline(symbol, binSize, start, end)
+		// Fetch kline data from exchange API, retrying transient failures
+		candles, err := newRetryExchange(ctx, ex, retryPolicyFromConfig(cfg)).GetKline(symbol, binSize, start, end)
 		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("failed to fetch kline: %s", err.Error())), nil
+			err = fmt.Errorf("failed to fetch kline: %w", err)
+			if fallbackLocal {
+				return localKlineFallback(db, exchangeName, requested, binSize, start, end, limit, err), nil
+			}
+			return toolError(codeUpstream, err.Error()), nil
+		}
+
+		var newlyStored int64
+		if save {
+			if newlyStored, err = saveFetchedKlines(db, exchangeName, symbol, binSize, candles); err != nil {
+				return toolErrorf(codeInternal, "failed to save kline: %s", err.Error()), nil
+			}
 		}
 
 		// Apply limit
@@ -108,6 +201,13 @@ func registerFetchKline(s *server.MCPServer, cfg *viper.Viper) {
 			"binSize":  binSize,
 			"count":    len(entries),
 			"candles":  entries,
+			"source":   "exchange",
+		}
+		if symbol != requested {
+			result["requestedSymbol"] = requested
+		}
+		if save {
+			result["newlyStored"] = newlyStored
 		}
 		data, _ := json.MarshalIndent(result, "", "  ")
 		return mcp.NewToolResultText(string(data)), nil
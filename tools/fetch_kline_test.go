@@ -0,0 +1,81 @@
+package tools
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/ztrade/trademodel"
+	"github.com/ztrade/ztrade/pkg/process/dbstore"
+)
+
+func TestSaveFetchedKlines(t *testing.T) {
+	db, err := dbstore.NewDBStore("sqlite", filepath.Join(t.TempDir(), "kline.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+	mk := func(from, n int) []*trademodel.Candle {
+		var ret []*trademodel.Candle
+		for i := from; i < from+n; i++ {
+			ret = append(ret, &trademodel.Candle{Start: base + int64(i*60), Open: 1, High: 1, Low: 1, Close: 1})
+		}
+		return ret
+	}
+
+	n, err := saveFetchedKlines(db, "binance", "BTCUSDT", "1m", mk(0, 3))
+	if err != nil || n != 3 {
+		t.Fatalf("first save: %d, %v", n, err)
+	}
+	// overlapping candles are replaced, only the new one counts
+	n, err = saveFetchedKlines(db, "binance", "BTCUSDT", "1m", mk(1, 3))
+	if err != nil || n != 1 {
+		t.Fatalf("second save: %d, %v", n, err)
+	}
+}
+
+func TestLocalKlineFallback(t *testing.T) {
+	db, err := dbstore.NewDBStore("sqlite", filepath.Join(t.TempDir(), "kline.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var candles []*trademodel.Candle
+	for i := 0; i < 10; i++ {
+		candles = append(candles, &trademodel.Candle{Start: start.Add(time.Duration(i) * time.Minute).Unix(), Open: 1, High: 2, Low: 1, Close: 2, Volume: 1})
+	}
+	if _, err := saveFetchedKlines(db, "binance", "BTCUSDT", "1m", candles); err != nil {
+		t.Fatal(err)
+	}
+	fetchErr := errors.New("failed to fetch kline: connection refused")
+
+	res := localKlineFallback(db, "binance", "BTC-USDT", "5m", start.Add(-time.Minute), start.Add(10*time.Minute), 500, fetchErr)
+	if res.IsError {
+		t.Fatalf("fallback failed: %+v", res.Content)
+	}
+	var out struct {
+		Symbol          string       `json:"symbol"`
+		RequestedSymbol string       `json:"requestedSymbol"`
+		Count           int          `json:"count"`
+		Source          string       `json:"source"`
+		FallbackReason  string       `json:"fallbackReason"`
+		Candles         []klineEntry `json:"candles"`
+	}
+	if err := json.Unmarshal([]byte(res.Content[0].(mcp.TextContent).Text), &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Source != "local" || out.FallbackReason != fetchErr.Error() || out.Symbol != "BTCUSDT" || out.RequestedSymbol != "BTC-USDT" || out.Count != 2 {
+		t.Fatalf("fallback result %+v", out)
+	}
+
+	// nothing stored in the range: the exchange error stands
+	if res := localKlineFallback(db, "binance", "BTCUSDT", "1m", start.Add(time.Hour), start.Add(2*time.Hour), 500, fetchErr); !res.IsError {
+		t.Fatal("empty range served")
+	}
+	if res := localKlineFallback(nil, "binance", "BTCUSDT", "1m", start, start.Add(time.Hour), 500, fetchErr); !res.IsError {
+		t.Fatal("served without a database")
+	}
+}
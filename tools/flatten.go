@@ -0,0 +1,140 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	bfutures "github.com/adshao/go-binance/v2/futures"
+	"github.com/spf13/viper"
+)
+
+// flattenPolls and flattenPollInterval bound how long flattening waits for
+// the exchange to report the position closed.
+const (
+	flattenPolls        = 10
+	flattenPollInterval = 500 * time.Millisecond
+)
+
+// flattenReport is what closing out the position of a stopped live trade
+// did.
+type flattenReport struct {
+	CanceledOrders bool    `json:"canceledOrders"`
+	Exposure       float64 `json:"exposure"`            // position the trade tracked, long positive
+	ClosedPosition float64 `json:"closedPosition"`      // position the market orders closed, long positive
+	Untracked      float64 `json:"untracked,omitempty"` // position on the symbol the trade did not hold, left open
+	Position       float64 `json:"position"`            // position the exchange reports afterwards
+	Flat           bool    `json:"flat"`                // only the untracked position is left
+}
+
+// binanceFuturesClient returns a client for the binance futures account
+// configured under exchanges.<name>, or an error when the exchange is not
+// binance futures or has no API key. Closing positions is only supported
+// there.
+func binanceFuturesClient(cfg *viper.Viper, exchangeName string) (*bfutures.Client, error) {
+	cfg = exchangeConfig(cfg)
+	prefix := "exchanges." + exchangeName
+	exchangeType, kind := cfg.GetString(prefix+".type"), cfg.GetString(prefix+".kind")
+	if exchangeType == "" {
+		return nil, fmt.Errorf("exchange '%s' not found in config. Use list_exchanges to see configured exchanges.", exchangeName)
+	}
+	if exchangeType != "binance" || kind != "futures" {
+		return nil, fmt.Errorf("closing positions is not supported for exchange type %s kind %s (supported: binance futures)", exchangeType, kind)
+	}
+	key, secret := cfg.GetString(prefix+".key"), cfg.GetString(prefix+".secret")
+	if key == "" || secret == "" {
+		return nil, fmt.Errorf("exchange '%s' has no API key/secret configured", exchangeName)
+	}
+	client := bfutures.NewClient(key, secret)
+	if cfg.GetBool(prefix + ".isTest") {
+		client.BaseURL = bfutures.BaseApiTestnetUrl
+	}
+	httpClient, err := binanceHTTPClient(cfg.GetString("proxy"))
+	if err != nil {
+		return nil, err
+	}
+	if httpClient != nil {
+		client.HTTPClient = httpClient
+	}
+	return client, nil
+}
+
+// flattenBinanceFutures cancels the open orders of symbol, including stop
+// (algo) orders, and closes exposure, the net position the stopped trade
+// tracked (long positive), with a market order. The close is capped at what
+// the exchange holds on that side, so a position the trade did not open is
+// left as it is. In one-way mode the close is reduce-only; in hedge mode it
+// targets the position side. It then polls until the exchange reports only
+// the untracked position.
+func flattenBinanceFutures(ctx context.Context, client *bfutures.Client, symbol string, exposure float64, poll time.Duration) (*flattenReport, error) {
+	report := &flattenReport{Exposure: exposure}
+	if err := client.NewCancelAllOpenOrdersService().Symbol(symbol).Do(ctx); err != nil {
+		return report, fmt.Errorf("cancel open orders: %w", err)
+	}
+	if err := client.NewCancelAllAlgoOpenOrdersService().Symbol(symbol).Do(ctx); err != nil {
+		return report, fmt.Errorf("cancel open stop orders: %w", err)
+	}
+	report.CanceledOrders = true
+
+	risks, err := client.NewGetPositionRiskService().Symbol(symbol).Do(ctx)
+	if err != nil {
+		return report, fmt.Errorf("fetch position failed: %w", err)
+	}
+	var before float64
+	remaining := exposure
+	for _, r := range risks {
+		amount := parseFloatOrZero(r.PositionAmt)
+		if r.Symbol != symbol {
+			continue
+		}
+		before += amount
+		if amount == 0 || math.Abs(remaining) <= positionTolerance || (amount > 0) != (remaining > 0) {
+			continue
+		}
+		side := bfutures.SideTypeSell
+		if amount < 0 {
+			side = bfutures.SideTypeBuy
+		}
+		// the exchange's own figure when closing the whole side, so the
+		// quantity keeps its step size
+		quantity, closed := strings.TrimPrefix(r.PositionAmt, "-"), amount
+		if math.Abs(remaining) < math.Abs(amount)-positionTolerance {
+			quantity, closed = strconv.FormatFloat(math.Round(math.Abs(remaining)*1e8)/1e8, 'f', -1, 64), remaining
+		}
+		order := client.NewCreateOrderService().Symbol(symbol).Side(side).
+			Type(bfutures.OrderTypeMarket).Quantity(quantity)
+		if ps := bfutures.PositionSideType(r.PositionSide); ps == bfutures.PositionSideTypeLong || ps == bfutures.PositionSideTypeShort {
+			order = order.PositionSide(ps)
+		} else {
+			order = order.ReduceOnly(true)
+		}
+		if _, err := order.Do(ctx); err != nil {
+			return report, fmt.Errorf("close position %s: %w", quantity, err)
+		}
+		report.ClosedPosition += closed
+		remaining -= closed
+	}
+	report.Untracked = before - report.ClosedPosition
+	if math.Abs(report.Untracked) <= positionTolerance {
+		report.Untracked = 0
+	}
+
+	for i := 0; i < flattenPolls; i++ {
+		report.Position, err = fetchBinanceFuturesPositionWith(ctx, client, symbol)
+		if err != nil {
+			return report, err
+		}
+		if report.Flat = math.Abs(report.Position-report.Untracked) <= positionTolerance; report.Flat {
+			return report, nil
+		}
+		select {
+		case <-ctx.Done():
+			return report, ctx.Err()
+		case <-time.After(poll):
+		}
+	}
+	return report, nil
+}
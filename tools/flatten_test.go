@@ -0,0 +1,111 @@
+package tools
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	bfutures "github.com/adshao/go-binance/v2/futures"
+)
+
+func TestFlattenBinanceFutures(t *testing.T) {
+	var mu sync.Mutex
+	position := "-0.5"
+	var calls []string
+	var order map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		r.ParseForm()
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		switch r.URL.Path {
+		case "/fapi/v1/allOpenOrders", "/fapi/v1/algoOpenOrders":
+			w.Write([]byte(`{"code":200,"msg":"done"}`))
+		case "/fapi/v2/positionRisk":
+			w.Write([]byte(`[{"symbol":"BTCUSDT","positionAmt":"` + position + `","positionSide":"BOTH"}]`))
+		case "/fapi/v1/order":
+			order = map[string]string{"side": r.Form.Get("side"), "type": r.Form.Get("type"), "quantity": r.Form.Get("quantity"), "reduceOnly": r.Form.Get("reduceOnly")}
+			position = "0"
+			w.Write([]byte(`{"symbol":"BTCUSDT","orderId":1,"status":"FILLED"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	client := bfutures.NewClient("key", "secret")
+	client.BaseURL = srv.URL
+	report, err := flattenBinanceFutures(context.Background(), client, "BTCUSDT", -0.5, 0)
+	if err != nil {
+		t.Fatalf("flatten: %v (calls %v)", err, calls)
+	}
+	if !report.CanceledOrders || report.ClosedPosition != -0.5 || report.Untracked != 0 || !report.Flat {
+		t.Fatalf("report = %+v", report)
+	}
+	if order["side"] != "BUY" || order["type"] != "MARKET" || order["quantity"] != "0.5" || order["reduceOnly"] != "true" {
+		t.Fatalf("close order = %v", order)
+	}
+}
+
+func TestFlattenBinanceFuturesTrackedExposure(t *testing.T) {
+	var mu sync.Mutex
+	position := "-0.8"
+	var orders []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		r.ParseForm()
+		switch r.URL.Path {
+		case "/fapi/v1/allOpenOrders", "/fapi/v1/algoOpenOrders":
+			w.Write([]byte(`{"code":200,"msg":"done"}`))
+		case "/fapi/v2/positionRisk":
+			w.Write([]byte(`[{"symbol":"BTCUSDT","positionAmt":"` + position + `","positionSide":"BOTH"}]`))
+		case "/fapi/v1/order":
+			orders = append(orders, r.Form.Get("side")+" "+r.Form.Get("quantity"))
+			position = "-0.3"
+			w.Write([]byte(`{"symbol":"BTCUSDT","orderId":1,"status":"FILLED"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	client := bfutures.NewClient("key", "secret")
+	client.BaseURL = srv.URL
+	// the trade holds 0.5 of the 0.8 short; the rest is someone else's
+	report, err := flattenBinanceFutures(context.Background(), client, "BTCUSDT", -0.5, 0)
+	if err != nil {
+		t.Fatalf("flatten: %v", err)
+	}
+	if len(orders) != 1 || orders[0] != "BUY 0.5" {
+		t.Fatalf("orders = %v", orders)
+	}
+	if report.ClosedPosition != -0.5 || math.Abs(report.Untracked+0.3) > 1e-9 || !report.Flat {
+		t.Fatalf("report = %+v", report)
+	}
+
+	// a long exposure does not close the short
+	orders = nil
+	report, err = flattenBinanceFutures(context.Background(), client, "BTCUSDT", 0.2, 0)
+	if err != nil || len(orders) != 0 || report.ClosedPosition != 0 || !report.Flat {
+		t.Fatalf("opposite side: %+v, %v, orders %v", report, err, orders)
+	}
+}
+
+func TestSymbolTrades(t *testing.T) {
+	m := &tradeManager{trades: map[string]*tradeInstance{
+		"a": {ID: "a", Exchange: "binance", Symbol: "BTCUSDT"},
+		"b": {ID: "b", Exchange: "binance", Symbol: "BTCUSDT"},
+		"c": {ID: "c", Exchange: "binance", Symbol: "ETHUSDT"},
+		"d": {ID: "d", Exchange: "okx", Symbol: "BTCUSDT"},
+	}}
+	if ids := m.symbolTradesLocked("binance", "BTCUSDT", "a"); len(ids) != 1 || ids[0] != "b" {
+		t.Fatalf("ids = %v", ids)
+	}
+	if ids := m.symbolTradesLocked("binance", "ETHUSDT", "c"); len(ids) != 0 {
+		t.Fatalf("ids = %v", ids)
+	}
+}
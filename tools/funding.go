@@ -0,0 +1,273 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	bfutures "github.com/adshao/go-binance/v2/futures"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"github.com/ztrade/trademodel"
+	"github.com/ztrade/ztrade-mcp/store"
+)
+
+const binanceFundingPageLimit = 1000
+
+// fundingMaxInterval is the longest time between two funding events;
+// stored rates further apart than this leave a gap to fetch.
+const fundingMaxInterval = 8 * time.Hour
+
+// fetchFundingRates downloads historical funding rates for a perpetual
+// contract from the exchange. Only exchanges with a public funding rate
+// history endpoint are supported.
+func fetchFundingRates(ctx context.Context, cfg *viper.Viper, exchangeName, symbol string, start, end time.Time) ([]store.FundingRate, error) {
+	cfg = exchangeConfig(cfg)
+	exchangeType := cfg.GetString(fmt.Sprintf("exchanges.%s.type", exchangeName))
+	if exchangeType == "" {
+		return nil, fmt.Errorf("exchange '%s' not found in config. Use list_exchanges to see configured exchanges.", exchangeName)
+	}
+	kind := cfg.GetString(fmt.Sprintf("exchanges.%s.kind", exchangeName))
+	switch {
+	case exchangeType == "binance" && kind == "futures":
+		return fetchBinanceFundingRates(ctx, cfg.GetString("proxy"), exchangeName, symbol, start, end)
+	default:
+		return nil, fmt.Errorf("funding rate history is not supported for exchange type %s kind %s (perpetual futures only, supported: binance futures)", exchangeType, kind)
+	}
+}
+
+func fetchBinanceFundingRates(ctx context.Context, proxy, exchangeName, symbol string, start, end time.Time) ([]store.FundingRate, error) {
+	client := bfutures.NewClient("", "")
+	httpClient, err := binanceHTTPClient(proxy)
+	if err != nil {
+		return nil, err
+	}
+	if httpClient != nil {
+		client.HTTPClient = httpClient
+	}
+
+	var rates []store.FundingRate
+	from := start.UnixMilli()
+	to := end.UnixMilli()
+	for from <= to {
+		page, err := client.NewFundingRateService().Symbol(symbol).StartTime(from).EndTime(to).Limit(binanceFundingPageLimit).Do(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("fetch funding rate failed: %w", err)
+		}
+		if len(page) == 0 {
+			break
+		}
+		for _, r := range page {
+			rate, err := strconv.ParseFloat(r.FundingRate, 64)
+			if err != nil {
+				log.Warnf("skip funding rate with invalid value %q", r.FundingRate)
+				continue
+			}
+			mark, _ := strconv.ParseFloat(r.MarkPrice, 64)
+			rates = append(rates, store.FundingRate{
+				Exchange:    exchangeName,
+				Symbol:      symbol,
+				FundingTime: time.UnixMilli(r.FundingTime),
+				Rate:        rate,
+				MarkPrice:   mark,
+			})
+		}
+		last := page[len(page)-1].FundingTime
+		if len(page) < binanceFundingPageLimit || last < from {
+			break
+		}
+		from = last + 1
+	}
+	return rates, nil
+}
+
+// fundingGaps returns the spans of [start, end] that the sorted rates do
+// not cover: the edges and the stretches between two rates longer than
+// fundingMaxInterval, or the whole range when there are none.
+func fundingGaps(rates []store.FundingRate, start, end time.Time) []timeRange {
+	if len(rates) == 0 {
+		return []timeRange{{Start: start, End: end}}
+	}
+	var gaps []timeRange
+	from := start
+	for _, r := range rates {
+		if r.FundingTime.Sub(from) > fundingMaxInterval {
+			gaps = append(gaps, timeRange{Start: from, End: r.FundingTime})
+		}
+		from = r.FundingTime
+	}
+	if end.Sub(from) > fundingMaxInterval {
+		gaps = append(gaps, timeRange{Start: from, End: end})
+	}
+	return gaps
+}
+
+// loadFundingRates returns funding rates for the range, preferring rates
+// already stored in the database and fetching only the spans they do not
+// cover from the exchange.
+func loadFundingRates(ctx context.Context, cfg *viper.Viper, st *store.Store, exchangeName, symbol string, start, end time.Time) ([]store.FundingRate, error) {
+	var stored []store.FundingRate
+	if st != nil {
+		var err error
+		stored, err = st.ListFundingRates(exchangeName, symbol, start, end)
+		if err != nil {
+			log.WithContext(ctx).Warnf("load stored funding rates failed: %s", err.Error())
+			stored = nil
+		}
+	}
+	gaps := fundingGaps(stored, start, end)
+	if len(gaps) == 0 {
+		return stored, nil
+	}
+
+	var fetched []store.FundingRate
+	for _, g := range gaps {
+		rates, err := fetchFundingRates(ctx, cfg, exchangeName, symbol, g.Start, g.End)
+		if err != nil {
+			return nil, err
+		}
+		fetched = append(fetched, rates...)
+	}
+	if st != nil && len(fetched) > 0 {
+		if _, err := st.SaveFundingRates(fetched); err != nil {
+			log.WithContext(ctx).Warnf("save funding rates failed: %s", err.Error())
+		}
+	}
+	return mergeFundingRates(stored, fetched), nil
+}
+
+// mergeFundingRates combines two sets of rates of one symbol ordered by
+// time, keeping one rate per funding time.
+func mergeFundingRates(a, b []store.FundingRate) []store.FundingRate {
+	seen := make(map[int64]bool, len(a)+len(b))
+	ret := make([]store.FundingRate, 0, len(a)+len(b))
+	for _, r := range append(append([]store.FundingRate{}, a...), b...) {
+		if seen[r.FundingTime.UnixMilli()] {
+			continue
+		}
+		seen[r.FundingTime.UnixMilli()] = true
+		ret = append(ret, r)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].FundingTime.Before(ret[j].FundingTime) })
+	return ret
+}
+
+// calcFundingCost applies each funding event to the position held at that
+// time, reconstructed from the backtest trades. Positive values mean funding
+// was paid, negative values mean it was received. The mark price of the
+// funding event is used as notional price, falling back to the last trade
+// price when it is unknown.
+func calcFundingCost(trades []trademodel.Trade, rates []store.FundingRate) (total float64, events int) {
+	sorted := append([]trademodel.Trade(nil), trades...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Time.Before(sorted[j].Time) })
+
+	var pos, lastPrice float64
+	i := 0
+	for _, r := range rates {
+		for i < len(sorted) && !sorted[i].Time.After(r.FundingTime) {
+			pos += signedTradeAmount(sorted[i])
+			lastPrice = sorted[i].Price
+			i++
+		}
+		if pos == 0 {
+			continue
+		}
+		price := r.MarkPrice
+		if price <= 0 {
+			price = lastPrice
+		}
+		total += pos * price * r.Rate
+		events++
+	}
+	return total, events
+}
+
+// signedTradeAmount returns the position change of a trade: positive for
+// buys (open long, close/stop short), negative for sells.
+func signedTradeAmount(t trademodel.Trade) float64 {
+	if t.Action.IsLong() {
+		return t.Amount
+	}
+	return -t.Amount
+}
+
+func registerFundingRateHistory(s *server.MCPServer, cfg *viper.Viper, st *store.Store) {
+	tool := mcp.NewTool("funding_rate_history",
+		mcp.WithDescription("Fetch historical funding rates of a perpetual contract from the exchange. Optionally persist them so run_backtest_managed with includeFunding can reuse them."),
+		mcp.WithString("exchange", mcp.Required(), mcp.Description("Exchange config name (e.g., binance). Must be a perpetual futures exchange.")),
+		mcp.WithString("symbol", mcp.Required(), mcp.Description("Perpetual contract symbol (e.g., BTCUSDT)")),
+		mcp.WithString("start", mcp.Required(), mcp.Description("Start time in format '2006-01-02 15:04:05'")),
+		mcp.WithString("end", mcp.Description("End time in format '2006-01-02 15:04:05'. Default: now")),
+		mcp.WithBoolean("persist", mcp.Description("Save fetched rates to the database. Default: false")),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		exchangeName := req.GetString("exchange", "")
+		symbol := req.GetString("symbol", "")
+		persist := req.GetBool("persist", false)
+
+		start, err := time.Parse("2006-01-02 15:04:05", req.GetString("start", ""))
+		if err != nil {
+			return toolErrorf(codeInvalidArgument, "invalid start time: %s", err.Error()), nil
+		}
+		end := time.Now()
+		if endStr := req.GetString("end", ""); endStr != "" {
+			end, err = time.Parse("2006-01-02 15:04:05", endStr)
+			if err != nil {
+				return toolErrorf(codeInvalidArgument, "invalid end time: %s", err.Error()), nil
+			}
+		}
+		if !start.Before(end) {
+			return toolError(codeInvalidArgument, "start must be before end"), nil
+		}
+
+		rates, err := fetchFundingRates(ctx, cfg, exchangeName, symbol, start, end)
+		if err != nil {
+			return toolError(codeUpstream, err.Error()), nil
+		}
+
+		type rateEntry struct {
+			Time      string  `json:"time"`
+			Rate      float64 `json:"rate"`
+			MarkPrice float64 `json:"markPrice,omitempty"`
+		}
+		entries := make([]rateEntry, 0, len(rates))
+		var sum float64
+		for _, r := range rates {
+			sum += r.Rate
+			entries = append(entries, rateEntry{
+				Time:      r.FundingTime.Format("2006-01-02 15:04:05"),
+				Rate:      r.Rate,
+				MarkPrice: r.MarkPrice,
+			})
+		}
+
+		result := map[string]interface{}{
+			"exchange": exchangeName,
+			"symbol":   symbol,
+			"count":    len(entries),
+			"rates":    entries,
+		}
+		if len(entries) > 0 {
+			result["avgRate"] = sum / float64(len(entries))
+			result["cumulativeRate"] = sum
+		}
+		if persist {
+			if st == nil {
+				return toolError(codeUnavailable, "script store not initialized (check database config)"), nil
+			}
+			inserted, err := st.SaveFundingRates(rates)
+			if err != nil {
+				return toolErrorf(codeInternal, "failed to save funding rates: %s", err.Error()), nil
+			}
+			result["persisted"] = inserted
+		}
+		data, _ := json.MarshalIndent(result, "", "  ")
+		return mcp.NewToolResultText(string(data)), nil
+	})
+}
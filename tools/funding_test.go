@@ -0,0 +1,65 @@
+package tools
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/ztrade/trademodel"
+	"github.com/ztrade/ztrade-mcp/store"
+)
+
+func TestCalcFundingCost(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	trades := []trademodel.Trade{
+		{Action: trademodel.OpenLong, Time: t0.Add(time.Hour), Price: 100, Amount: 2},
+		{Action: trademodel.CloseLong, Time: t0.Add(10 * time.Hour), Price: 110, Amount: 2},
+		{Action: trademodel.OpenShort, Time: t0.Add(12 * time.Hour), Price: 120, Amount: 1},
+	}
+	rates := []store.FundingRate{
+		{FundingTime: t0, Rate: 0.01, MarkPrice: 100},                     // flat, ignored
+		{FundingTime: t0.Add(8 * time.Hour), Rate: 0.001, MarkPrice: 105}, // long 2 pays 0.21
+		{FundingTime: t0.Add(16 * time.Hour), Rate: 0.001},                // short 1 receives 0.12 at last trade price
+	}
+	total, events := calcFundingCost(trades, rates)
+	if events != 2 || math.Abs(total-(0.21-0.12)) > 1e-9 {
+		t.Fatalf("got total=%v events=%d", total, events)
+	}
+}
+
+func TestFundingGaps(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(hours ...int) []store.FundingRate {
+		var rates []store.FundingRate
+		for _, h := range hours {
+			rates = append(rates, store.FundingRate{FundingTime: t0.Add(time.Duration(h) * time.Hour)})
+		}
+		return rates
+	}
+	end := t0.Add(48 * time.Hour)
+
+	if gaps := fundingGaps(nil, t0, end); len(gaps) != 1 || !gaps[0].Start.Equal(t0) || !gaps[0].End.Equal(end) {
+		t.Fatalf("no rates: %+v", gaps)
+	}
+	if gaps := fundingGaps(at(0, 8, 16, 24, 32, 40, 48), t0, end); len(gaps) != 0 {
+		t.Fatalf("full coverage: %+v", gaps)
+	}
+	// stored rates only for the second day: the first day is missing
+	gaps := fundingGaps(at(24, 32, 40, 48), t0, end)
+	if len(gaps) != 1 || !gaps[0].Start.Equal(t0) || !gaps[0].End.Equal(t0.Add(24*time.Hour)) {
+		t.Fatalf("missing head: %+v", gaps)
+	}
+	gaps = fundingGaps(at(0, 8, 32), t0, end)
+	if len(gaps) != 2 || !gaps[0].Start.Equal(t0.Add(8*time.Hour)) || !gaps[0].End.Equal(t0.Add(32*time.Hour)) ||
+		!gaps[1].Start.Equal(t0.Add(32*time.Hour)) || !gaps[1].End.Equal(end) {
+		t.Fatalf("inner and tail gaps: %+v", gaps)
+	}
+	if gaps := fundingGaps(at(4, 12, 20, 28, 36, 44), t0, end); len(gaps) != 0 {
+		t.Fatalf("rates within one interval of the edges: %+v", gaps)
+	}
+
+	merged := mergeFundingRates(at(8, 16), at(0, 8))
+	if len(merged) != 3 || !merged[0].FundingTime.Equal(t0) || !merged[2].FundingTime.Equal(t0.Add(16*time.Hour)) {
+		t.Fatalf("merge: %+v", merged)
+	}
+}
@@ -0,0 +1,40 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/ztrade/ztrade-mcp/internal/indicatorspec"
+)
+
+func registerListIndicators(s *server.MCPServer) {
+	tool := mcp.NewTool("list_indicators",
+		mcp.WithDescription("List the indicators accepted by Engine.AddIndicator, generated from the indicator library: each with its argument names, meanings, defaults and sensible ranges, the accepted argument counts ('forms') and, per count, the keys its Indicator() map returns. Same data as the ztrade://doc/indicators resource."),
+		mcp.WithString("name", mcp.Description("Only return this indicator (case-insensitive). Default: all")),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		catalog := indicatorspec.Catalog()
+		if name := strings.ToUpper(strings.TrimSpace(req.GetString("name", ""))); name != "" {
+			var found []indicatorspec.Spec
+			for _, spec := range catalog {
+				if spec.Name == name {
+					found = append(found, spec)
+				}
+			}
+			if len(found) == 0 {
+				return toolErrorf(codeNotFound, "unknown indicator %q; call list_indicators without name for all", name), nil
+			}
+			catalog = found
+		}
+		result := map[string]interface{}{
+			"count":      len(catalog),
+			"indicators": catalog,
+		}
+		data, _ := json.MarshalIndent(result, "", "  ")
+		return mcp.NewToolResultText(string(data)), nil
+	})
+}
@@ -19,6 +19,9 @@ const (
 	queryBaseBinSize    = "1m"
 	queryKlineDefaultN  = 500
 	queryKlineMaxResult = 5000
+
+	candleTypeNormal     = "normal"
+	candleTypeHeikinAshi = "heikinashi"
 )
 
 type klineEntry struct {
@@ -114,6 +117,28 @@ func mergeCandles(candles []*trademodel.Candle, srcDur, dstDur time.Duration, li
 	return merged, nil
 }
 
+// toHeikinAshi transforms candles into Heikin-Ashi candles. The first bar
+// is seeded from the raw candle: its open is the midpoint of the raw open and
+// close. Time and volume are kept.
+func toHeikinAshi(candles []*trademodel.Candle) []*trademodel.Candle {
+	ret := make([]*trademodel.Candle, 0, len(candles))
+	var prev *trademodel.Candle
+	for _, c := range candles {
+		ha := *c
+		ha.Close = (c.Open + c.High + c.Low + c.Close) / 4
+		if prev == nil {
+			ha.Open = (c.Open + c.Close) / 2
+		} else {
+			ha.Open = (prev.Open + prev.Close) / 2
+		}
+		ha.High = math.Max(c.High, math.Max(ha.Open, ha.Close))
+		ha.Low = math.Min(c.Low, math.Min(ha.Open, ha.Close))
+		ret = append(ret, &ha)
+		prev = &ha
+	}
+	return ret
+}
+
 func minInt(a, b int) int {
 	if a < b {
 		return a
@@ -121,110 +146,194 @@ func minInt(a, b int) int {
 	return b
 }
 
+// loadCandles reads up to limit candles of binSize from the local database,
+// merging from 1m candles when binSize is larger. With fillGaps, missing
+// source bars are synthesized before merging (see fillKlineGaps). It returns
+// the candles, the bin size actually read from the database and the number
+// of synthesized source bars.
+func loadCandles(db *dbstore.DBStore, exchange, symbol, binSize string, start, end time.Time, limit int, fillGaps bool) ([]*trademodel.Candle, string, int, error) {
+	srcDur, dstDur, needMerge, err := parseKlineDurations(binSize)
+	if err != nil {
+		return nil, "", 0, err
+	}
+
+	sourceBinSize := binSize
+	sourceLimit := limit
+	if needMerge {
+		sourceBinSize = queryBaseBinSize
+		sourceLimit, err = calcSourceLimit(limit, start, end, srcDur, dstDur)
+		if err != nil {
+			return nil, "", 0, err
+		}
+	}
+
+	tbl := db.GetKlineTbl(exchange, symbol, sourceBinSize)
+	datas, err := tbl.GetDatas(start, end, sourceLimit)
+	if err != nil {
+		return nil, "", 0, fmt.Errorf("query failed: %s", err.Error())
+	}
+
+	candles := make([]*trademodel.Candle, 0, len(datas))
+	for _, d := range datas {
+		candle, ok := d.(*trademodel.Candle)
+		if !ok {
+			continue
+		}
+		candles = append(candles, candle)
+	}
+
+	synthesized := 0
+	if fillGaps {
+		candles, synthesized = fillKlineGaps(candles, srcDur, sourceLimit)
+	}
+
+	if needMerge {
+		candles, err = mergeCandles(candles, srcDur, dstDur, limit)
+		if err != nil {
+			return nil, "", 0, fmt.Errorf("merge failed: %s", err.Error())
+		}
+	} else if len(candles) > limit {
+		candles = candles[:limit]
+	}
+	return candles, sourceBinSize, synthesized, nil
+}
+
+// klineQuery is the part of a query_kline request shared by every symbol.
+type klineQuery struct {
+	exchange, binSize, candleType string
+	start, end                    time.Time
+	limit, maxPoints              int
+	fillGaps                      bool
+}
+
+// klineQueryFromRequest parses the exchange, binSize, time range, limit and
+// candle options of a query_kline style request. On failure it returns the
+// tool error to respond with.
+func klineQueryFromRequest(req mcp.CallToolRequest) (klineQuery, *mcp.CallToolResult) {
+	q := klineQuery{
+		exchange:   req.GetString("exchange", ""),
+		binSize:    strings.ToLower(strings.TrimSpace(req.GetString("binSize", ""))),
+		candleType: strings.ToLower(strings.TrimSpace(req.GetString("candleType", ""))),
+		limit:      int(req.GetFloat("limit", 0)),
+		maxPoints:  int(req.GetFloat("maxPoints", 0)),
+		fillGaps:   req.GetBool("fillGaps", false),
+	}
+	if q.candleType == "" {
+		q.candleType = candleTypeNormal
+	}
+	if q.candleType != candleTypeNormal && q.candleType != candleTypeHeikinAshi {
+		return q, toolErrorf(codeInvalidArgument, "invalid candleType %q, supported: %s, %s", q.candleType, candleTypeNormal, candleTypeHeikinAshi)
+	}
+	if err := maxPointsArg(q.maxPoints); err != nil {
+		return q, toolError(codeInvalidArgument, err.Error())
+	}
+	if q.binSize == "" {
+		q.binSize = queryBaseBinSize
+	}
+	if q.limit <= 0 {
+		q.limit = queryKlineDefaultN
+	}
+	if q.limit > queryKlineMaxResult {
+		q.limit = queryKlineMaxResult
+	}
+
+	var err error
+	q.start, err = time.Parse("2006-01-02 15:04:05", req.GetString("start", ""))
+	if err != nil {
+		return q, toolErrorf(codeInvalidArgument, "invalid start time: %s", err.Error())
+	}
+	q.end, err = time.Parse("2006-01-02 15:04:05", req.GetString("end", ""))
+	if err != nil {
+		return q, toolErrorf(codeInvalidArgument, "invalid end time: %s", err.Error())
+	}
+	if !q.start.Before(q.end) {
+		return q, toolError(codeInvalidArgument, "start must be before end")
+	}
+	return q, nil
+}
+
+// klineSeries is the result of a klineQuery for one symbol.
+type klineSeries struct {
+	symbol        string // the symbol the data is stored under
+	sourceBinSize string
+	candles       []klineEntry
+	synthesized   int // flat bars added by fillGaps
+	originalCount int // candles before downsampling
+}
+
+// load runs the query for symbol, matched to its stored notation: candles
+// merged to binSize, converted to the candle type and downsampled.
+func (q klineQuery) load(db *dbstore.DBStore, symbol string) (klineSeries, error) {
+	symbol = resolveStoredSymbol(db, q.exchange, symbol)
+	candles, sourceBinSize, synthesized, err := loadCandles(db, q.exchange, symbol, q.binSize, q.start, q.end, q.limit, q.fillGaps)
+	if err != nil {
+		return klineSeries{}, err
+	}
+	if q.candleType == candleTypeHeikinAshi {
+		candles = toHeikinAshi(candles)
+	}
+	series := klineSeries{symbol: symbol, sourceBinSize: sourceBinSize, synthesized: synthesized, originalCount: len(candles)}
+	candles = downsampleCandles(candles, q.maxPoints)
+	series.candles = make([]klineEntry, 0, len(candles))
+	for _, candle := range candles {
+		series.candles = append(series.candles, klineEntry{
+			Time:   candle.Time().Format("2006-01-02 15:04:05"),
+			Open:   candle.Open,
+			High:   candle.High,
+			Low:    candle.Low,
+			Close:  candle.Close,
+			Volume: candle.Volume,
+		})
+	}
+	return series, nil
+}
+
 func registerQueryKline(s *server.MCPServer, db *dbstore.DBStore) {
 	tool := mcp.NewTool("query_kline",
 		mcp.WithDescription("Query K-line candlestick data from local database for analysis. If binSize is larger than 1m, data is auto-merged from 1m candles."),
 		mcp.WithString("exchange", mcp.Required(), mcp.Description("Exchange name e.g. binance, okx")),
-		mcp.WithString("symbol", mcp.Required(), mcp.Description("Trading pair e.g. BTCUSDT")),
+		mcp.WithString("symbol", mcp.Required(), mcp.Description("Trading pair e.g. BTCUSDT; other notations (BTC-USDT, BTC/USDT) are matched to the symbol the data is stored under")),
 		mcp.WithString("binSize", mcp.Description("K-line period 1m/5m/15m/1h/1d. Default: 1m")),
 		mcp.WithString("start", mcp.Required(), mcp.Description("Start time in format 2006-01-02 15:04:05")),
 		mcp.WithString("end", mcp.Required(), mcp.Description("End time in format 2006-01-02 15:04:05")),
 		mcp.WithNumber("limit", mcp.Description("Maximum number of candles to return. Default: 500, Max: 5000")),
+		mcp.WithString("candleType", mcp.Description("Candle type: normal or heikinashi (Heikin-Ashi, computed after merging to binSize). Default: normal")),
+		mcp.WithBoolean("fillGaps", mcp.Description("Fill missing 1m bars with flat candles at the previous close (zero volume) before merging, so the series is contiguous. Synthesized bars are counted in the result. Default: false (real data only)")),
+		mcp.WithNumber("maxPoints", mcp.Description("Downsample the result to at most this many candles by merging consecutive candles into equal-count buckets (open/high/low/close/volume merged like a larger bin), so long ranges keep their shape at a bounded size. originalCount reports the count before downsampling. Default: 0 (no downsampling)")),
 	)
 
 	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		if db == nil {
-			return mcp.NewToolResultError("database not initialized"), nil
-		}
-
-		exchange := req.GetString("exchange", "")
-		symbol := req.GetString("symbol", "")
-		binSize := strings.ToLower(strings.TrimSpace(req.GetString("binSize", "")))
-		startStr := req.GetString("start", "")
-		endStr := req.GetString("end", "")
-		limitF := req.GetFloat("limit", 0)
-
-		if binSize == "" {
-			binSize = queryBaseBinSize
-		}
-		limit := int(limitF)
-		if limit <= 0 {
-			limit = queryKlineDefaultN
-		}
-		if limit > queryKlineMaxResult {
-			limit = queryKlineMaxResult
-		}
-
-		start, err := time.Parse("2006-01-02 15:04:05", startStr)
-		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("invalid start time: %s", err.Error())), nil
-		}
-		end, err := time.Parse("2006-01-02 15:04:05", endStr)
-		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("invalid end time: %s", err.Error())), nil
-		}
-		if !start.Before(end) {
-			return mcp.NewToolResultError("start must be before end"), nil
+			return toolError(codeUnavailable, "database not initialized"), nil
 		}
-
-		srcDur, dstDur, needMerge, err := parseKlineDurations(binSize)
-		if err != nil {
-			return mcp.NewToolResultError(err.Error()), nil
+		q, errResult := klineQueryFromRequest(req)
+		if errResult != nil {
+			return errResult, nil
 		}
 
-		sourceBinSize := binSize
-		sourceLimit := limit
-		if needMerge {
-			sourceBinSize = queryBaseBinSize
-			sourceLimit, err = calcSourceLimit(limit, start, end, srcDur, dstDur)
-			if err != nil {
-				return mcp.NewToolResultError(err.Error()), nil
-			}
-		}
-
-		tbl := db.GetKlineTbl(exchange, symbol, sourceBinSize)
-		datas, err := tbl.GetDatas(start, end, sourceLimit)
+		requested := req.GetString("symbol", "")
+		series, err := q.load(db, requested)
 		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("query failed: %s", err.Error())), nil
+			return toolError(codeInternal, err.Error()), nil
 		}
 
-		candles := make([]*trademodel.Candle, 0, len(datas))
-		for _, d := range datas {
-			candle, ok := d.(*trademodel.Candle)
-			if !ok {
-				continue
-			}
-			candles = append(candles, candle)
+		result := map[string]interface{}{
+			"exchange":      q.exchange,
+			"symbol":        series.symbol,
+			"binSize":       q.binSize,
+			"sourceBinSize": series.sourceBinSize,
+			"candleType":    q.candleType,
+			"count":         len(series.candles),
+			"candles":       series.candles,
 		}
-
-		if needMerge {
-			candles, err = mergeCandles(candles, srcDur, dstDur, limit)
-			if err != nil {
-				return mcp.NewToolResultError(fmt.Sprintf("merge failed: %s", err.Error())), nil
-			}
-		} else if len(candles) > limit {
-			candles = candles[:limit]
+		if series.symbol != requested {
+			result["requestedSymbol"] = requested
 		}
-
-		entries := make([]klineEntry, 0, len(candles))
-		for _, candle := range candles {
-			entries = append(entries, klineEntry{
-				Time:   candle.Time().Format("2006-01-02 15:04:05"),
-				Open:   candle.Open,
-				High:   candle.High,
-				Low:    candle.Low,
-				Close:  candle.Close,
-				Volume: candle.Volume,
-			})
+		if q.fillGaps {
+			result["synthesizedBars"] = series.synthesized
 		}
-
-		result := map[string]interface{}{
-			"exchange":      exchange,
-			"symbol":        symbol,
-			"binSize":       binSize,
-			"sourceBinSize": sourceBinSize,
-			"count":         len(entries),
-			"candles":       entries,
+		if q.maxPoints > 0 {
+			result["originalCount"] = series.originalCount
 		}
 		data, _ := json.MarshalIndent(result, "", "  ")
 		return mcp.NewToolResultText(string(data)), nil
@@ -0,0 +1,82 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"github.com/ztrade/exchange"
+	"github.com/ztrade/ztrade/pkg/process/dbstore"
+)
+
+// klineWriteBatch is how many candles are written to the database at once.
+const klineWriteBatch = 1024
+
+// autoDownloadStart returns where an auto download continues from: one
+// minute before the newest stored candle, like ctl.DataDownload.AutoRun.
+func autoDownloadStart(db *dbstore.DBStore, exchangeName, symbol, binSize string) (time.Time, error) {
+	newest := db.GetKlineTbl(exchangeName, symbol, binSize).GetNewest()
+	if newest.IsZero() {
+		return time.Time{}, fmt.Errorf("no %s %s data in db to continue from; auto mode needs existing data, use start/end instead", symbol, binSize)
+	}
+	return newest.Add(-time.Minute), nil
+}
+
+// downloadKlines fetches the candles of [start, end] from the exchange and
+// writes them to the local database. Unlike ctl.DataDownload, exchange
+// calls are retried with backoff per mcp.retry.
+func downloadKlines(ctx context.Context, cfg *viper.Viper, db *dbstore.DBStore, exchangeName, symbol, binSize string, start, end time.Time) error {
+	ex, err := newExchangeClient(cfg, exchangeName)
+	if err != nil {
+		return err
+	}
+	rex := newRetryExchange(ctx, ex, retryPolicyFromConfig(cfg))
+	tbl := db.GetKlineTbl(exchangeName, symbol, binSize)
+	klines, errCh := exchange.KlineChan(rex, symbol, binSize, start, end)
+
+	batch := make([]interface{}, 0, klineWriteBatch)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := tbl.WriteDatas(batch); err != nil {
+			return fmt.Errorf("write candles failed: %w", err)
+		}
+		batch = batch[:0]
+		return nil
+	}
+	// stop drains the feed so its goroutine can exit
+	stop := func(err error) error {
+		go func() {
+			for range klines {
+			}
+		}()
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return stop(fmt.Errorf("download canceled: %w", ctx.Err()))
+		case v, ok := <-klines:
+			if !ok {
+				if err := flush(); err != nil {
+					return err
+				}
+				if err := <-errCh; err != nil {
+					return err
+				}
+				log.WithContext(ctx).WithFields(log.Fields{"exchange": exchangeName, "symbol": symbol, "binSize": binSize}).Info("download finished")
+				return nil
+			}
+			batch = append(batch, v)
+			if len(batch) >= klineWriteBatch {
+				if err := flush(); err != nil {
+					return stop(err)
+				}
+			}
+		}
+	}
+}
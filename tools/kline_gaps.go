@@ -0,0 +1,124 @@
+package tools
+
+import (
+	"fmt"
+	"time"
+
+	basecommon "github.com/ztrade/base/common"
+	"github.com/ztrade/trademodel"
+	"github.com/ztrade/ztrade/pkg/process/dbstore"
+)
+
+// klineGapScanMax bounds how many stored candles are read when scanning a
+// range for gaps.
+const klineGapScanMax = 1000000
+
+// timeRange is a half-open [Start, End) interval.
+type timeRange struct {
+	Start time.Time
+	End   time.Time
+}
+
+// expectedCandleCount returns how many candles of dur fit in [start, end).
+func expectedCandleCount(start, end time.Time, dur time.Duration) int {
+	if dur <= 0 || !start.Before(end) {
+		return 0
+	}
+	n := end.Sub(start) / dur
+	if end.Sub(start)%dur != 0 {
+		n++
+	}
+	return int(n)
+}
+
+// findKlineGaps returns the sub-ranges of [start, end) not covered by the
+// given candles. candles must be sorted by start time; each candle covers
+// [Start, Start+dur).
+func findKlineGaps(candles []*trademodel.Candle, dur time.Duration, start, end time.Time) []timeRange {
+	var gaps []timeRange
+	cursor := start
+	for _, c := range candles {
+		t := time.Unix(c.Start, 0)
+		if !t.Before(end) {
+			break
+		}
+		if t.Sub(cursor) >= dur {
+			gaps = append(gaps, timeRange{Start: cursor, End: t})
+		}
+		if next := t.Add(dur); next.After(cursor) {
+			cursor = next
+		}
+	}
+	if cursor.Before(end) {
+		gaps = append(gaps, timeRange{Start: cursor, End: end})
+	}
+	return gaps
+}
+
+// readStoredCandles returns the stored candles of binSize in [start, end)
+// and the bin duration.
+func readStoredCandles(db *dbstore.DBStore, exchange, symbol, binSize string, start, end time.Time) ([]*trademodel.Candle, time.Duration, error) {
+	dur, err := basecommon.GetBinSizeDuration(binSize)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid binSize %q: %w", binSize, err)
+	}
+	limit := expectedCandleCount(start, end, dur) + 1
+	if limit > klineGapScanMax {
+		return nil, 0, fmt.Errorf("range too large to scan (%d candles, max %d)", limit, klineGapScanMax)
+	}
+	datas, err := db.GetKlineTbl(exchange, symbol, binSize).GetDatas(start, end, limit)
+	if err != nil {
+		return nil, 0, fmt.Errorf("query failed: %s", err.Error())
+	}
+	candles := make([]*trademodel.Candle, 0, len(datas))
+	for _, d := range datas {
+		if c, ok := d.(*trademodel.Candle); ok {
+			candles = append(candles, c)
+		}
+	}
+	return candles, dur, nil
+}
+
+// scanKlineGaps reads the stored candles of binSize in [start, end) and
+// returns how many exist together with the missing sub-ranges.
+func scanKlineGaps(db *dbstore.DBStore, exchange, symbol, binSize string, start, end time.Time) (existing int, gaps []timeRange, err error) {
+	candles, dur, err := readStoredCandles(db, exchange, symbol, binSize, start, end)
+	if err != nil {
+		return 0, nil, err
+	}
+	return len(candles), findKlineGaps(candles, dur, start, end), nil
+}
+
+// fillKlineGaps inserts flat candles at the previous close with zero volume
+// for every missing interval between consecutive candles, so the series is
+// contiguous. Leading and trailing gaps are left alone since there is no
+// bar to carry forward. The result holds at most maxLen candles. It returns
+// the filled series and how many candles were synthesized.
+func fillKlineGaps(candles []*trademodel.Candle, dur time.Duration, maxLen int) ([]*trademodel.Candle, int) {
+	step := int64(dur / time.Second)
+	if step <= 0 || len(candles) < 2 {
+		return candles, 0
+	}
+	ret := make([]*trademodel.Candle, 0, minInt(len(candles), maxLen))
+	synthesized := 0
+	for i, c := range candles {
+		if len(ret) >= maxLen {
+			break
+		}
+		if i > 0 {
+			prev := ret[len(ret)-1]
+			for t := prev.Start + step; t < c.Start && len(ret) < maxLen; t += step {
+				ret = append(ret, &trademodel.Candle{
+					Start: t, Open: prev.Close, High: prev.Close, Low: prev.Close, Close: prev.Close,
+					Table: prev.Table,
+				})
+				synthesized++
+			}
+			if len(ret) >= maxLen {
+				break
+			}
+		}
+		ret = append(ret, c)
+	}
+	return ret, synthesized
+}
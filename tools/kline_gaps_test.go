@@ -0,0 +1,66 @@
+package tools
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ztrade/trademodel"
+)
+
+func TestFindKlineGaps(t *testing.T) {
+	start := time.Unix(0, 0)
+	end := start.Add(10 * time.Minute)
+	at := func(min int) *trademodel.Candle { return &trademodel.Candle{Start: int64(min * 60)} }
+
+	gaps := findKlineGaps([]*trademodel.Candle{at(0), at(1), at(4), at(5), at(7)}, time.Minute, start, end)
+	want := [][2]int{{2, 4}, {6, 7}, {8, 10}}
+	if len(gaps) != len(want) {
+		t.Fatalf("unexpected gaps: %+v", gaps)
+	}
+	for i, g := range gaps {
+		if g.Start != start.Add(time.Duration(want[i][0])*time.Minute) || g.End != start.Add(time.Duration(want[i][1])*time.Minute) {
+			t.Fatalf("gap %d = %v-%v, want %v", i, g.Start.Unix(), g.End.Unix(), want[i])
+		}
+	}
+
+	if gaps := findKlineGaps(nil, time.Minute, start, end); len(gaps) != 1 || gaps[0].Start != start || gaps[0].End != end {
+		t.Fatalf("empty data should be one gap: %+v", gaps)
+	}
+	full := make([]*trademodel.Candle, 0, 10)
+	for i := 0; i < 10; i++ {
+		full = append(full, at(i))
+	}
+	if gaps := findKlineGaps(full, time.Minute, start, end); len(gaps) != 0 {
+		t.Fatalf("complete data should have no gaps: %+v", gaps)
+	}
+	if n := expectedCandleCount(start, end.Add(30*time.Second), time.Minute); n != 11 {
+		t.Fatalf("expected count = %d", n)
+	}
+}
+
+func TestFillKlineGaps(t *testing.T) {
+	candles := []*trademodel.Candle{
+		{Start: 0, Open: 1, High: 2, Low: 1, Close: 2, Volume: 3},
+		{Start: 180, Open: 2, High: 3, Low: 2, Close: 3, Volume: 4},
+		{Start: 240, Open: 3, High: 3, Low: 3, Close: 3, Volume: 1},
+	}
+	filled, n := fillKlineGaps(candles, time.Minute, 100)
+	if n != 2 || len(filled) != 5 {
+		t.Fatalf("expected 2 synthesized of 5, got %d of %d", n, len(filled))
+	}
+	for i, c := range filled {
+		if c.Start != int64(i*60) {
+			t.Fatalf("candle %d start %d, want %d", i, c.Start, i*60)
+		}
+	}
+	for _, c := range filled[1:3] {
+		if c.Open != 2 || c.High != 2 || c.Low != 2 || c.Close != 2 || c.Volume != 0 {
+			t.Fatalf("unexpected synthesized candle %+v", c)
+		}
+	}
+
+	capped, n := fillKlineGaps(candles, time.Minute, 3)
+	if len(capped) != 3 || n != 2 {
+		t.Fatalf("expected capped series of 3 with 2 synthesized, got %d/%d", len(capped), n)
+	}
+}
@@ -80,3 +80,28 @@ func TestParseKlineDurationsRejectSubMinute(t *testing.T) {
 		t.Fatal("expected error for binSize smaller than 1m")
 	}
 }
+
+func TestToHeikinAshi(t *testing.T) {
+	candles := []*trademodel.Candle{
+		{Start: 60, Open: 10, High: 14, Low: 8, Close: 12, Volume: 5},
+		{Start: 120, Open: 12, High: 13, Low: 9, Close: 10, Volume: 7},
+	}
+	ha := toHeikinAshi(candles)
+	if len(ha) != 2 {
+		t.Fatalf("expected 2 candles, got %d", len(ha))
+	}
+	// first bar: open = (10+12)/2, close = (10+14+8+12)/4
+	if ha[0].Open != 11 || ha[0].Close != 11 || ha[0].High != 14 || ha[0].Low != 8 {
+		t.Fatalf("unexpected first HA candle: %+v", ha[0])
+	}
+	// second bar: open = (11+11)/2, close = (12+13+9+10)/4
+	if ha[1].Open != 11 || ha[1].Close != 11 || ha[1].High != 13 || ha[1].Low != 9 {
+		t.Fatalf("unexpected second HA candle: %+v", ha[1])
+	}
+	if ha[1].Start != 120 || ha[1].Volume != 7 {
+		t.Fatalf("time/volume should be kept: %+v", ha[1])
+	}
+	if candles[0].Open != 10 {
+		t.Fatalf("input candles must not be modified")
+	}
+}
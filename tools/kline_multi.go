@@ -0,0 +1,113 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/ztrade/ztrade/pkg/process/dbstore"
+)
+
+const (
+	// queryKlineMultiMaxSymbols caps the symbols of one query_kline_multi call.
+	queryKlineMultiMaxSymbols = 20
+	// queryKlineMultiMaxRows caps the candles of one query_kline_multi call
+	// across all symbols; each symbol gets an equal share.
+	queryKlineMultiMaxRows = 20000
+)
+
+// multiSymbolLimit is the per-symbol candle limit of a query_kline_multi
+// call for n symbols: the requested limit, lowered to an equal share of
+// queryKlineMultiMaxRows.
+func multiSymbolLimit(limit, n int) int {
+	if n > 0 && limit > queryKlineMultiMaxRows/n {
+		return queryKlineMultiMaxRows / n
+	}
+	return limit
+}
+
+func registerQueryKlineMulti(s *server.MCPServer, db *dbstore.DBStore) {
+	tool := mcp.NewTool("query_kline_multi",
+		mcp.WithDescription(fmt.Sprintf("Query K-line data of several symbols of one exchange over the same range and binSize in one call, e.g. for correlation or relative-strength analysis. Works like query_kline per symbol and returns candles keyed by the requested symbol. At most %d symbols and %d candles in total: the per-symbol limit is lowered to an equal share when needed. A symbol that fails is reported under errors without failing the others.", queryKlineMultiMaxSymbols, queryKlineMultiMaxRows)),
+		mcp.WithString("exchange", mcp.Required(), mcp.Description("Exchange name e.g. binance, okx")),
+		mcp.WithString("symbols", mcp.Required(), mcp.Description("Comma-separated trading pairs e.g. BTCUSDT,ETHUSDT; other notations are matched to the stored symbols as in query_kline")),
+		mcp.WithString("binSize", mcp.Description("K-line period 1m/5m/15m/1h/1d. Default: 1m")),
+		mcp.WithString("start", mcp.Required(), mcp.Description("Start time in format 2006-01-02 15:04:05")),
+		mcp.WithString("end", mcp.Required(), mcp.Description("End time in format 2006-01-02 15:04:05")),
+		mcp.WithNumber("limit", mcp.Description(fmt.Sprintf("Maximum number of candles per symbol. Default: %d, Max: %d, lowered so all symbols together stay within %d", queryKlineDefaultN, queryKlineMaxResult, queryKlineMultiMaxRows))),
+		mcp.WithString("candleType", mcp.Description("Candle type: normal or heikinashi. Default: normal")),
+		mcp.WithBoolean("fillGaps", mcp.Description("Fill missing 1m bars with flat candles at the previous close before merging, as in query_kline. Default: false")),
+		mcp.WithNumber("maxPoints", mcp.Description("Downsample each symbol's candles to at most this many, as in query_kline. Default: 0 (no downsampling)")),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if db == nil {
+			return toolError(codeUnavailable, "database not initialized"), nil
+		}
+		q, errResult := klineQueryFromRequest(req)
+		if errResult != nil {
+			return errResult, nil
+		}
+		var symbols []string
+		seen := map[string]bool{}
+		for _, sym := range splitTags(req.GetString("symbols", "")) {
+			if !seen[sym] {
+				seen[sym] = true
+				symbols = append(symbols, sym)
+			}
+		}
+		if len(symbols) == 0 {
+			return toolError(codeInvalidArgument, "symbols is required"), nil
+		}
+		if len(symbols) > queryKlineMultiMaxSymbols {
+			return toolErrorf(codeInvalidArgument, "%d symbols given, at most %d per call", len(symbols), queryKlineMultiMaxSymbols), nil
+		}
+		q.limit = multiSymbolLimit(q.limit, len(symbols))
+
+		candles := map[string][]klineEntry{}
+		details := map[string]map[string]interface{}{}
+		errs := map[string]string{}
+		total := 0
+		for _, sym := range symbols {
+			series, err := q.load(db, sym)
+			if err != nil {
+				errs[sym] = err.Error()
+				continue
+			}
+			candles[sym] = series.candles
+			total += len(series.candles)
+			detail := map[string]interface{}{
+				"symbol":        series.symbol,
+				"sourceBinSize": series.sourceBinSize,
+				"count":         len(series.candles),
+			}
+			if q.fillGaps {
+				detail["synthesizedBars"] = series.synthesized
+			}
+			if q.maxPoints > 0 {
+				detail["originalCount"] = series.originalCount
+			}
+			details[sym] = detail
+		}
+		if len(candles) == 0 {
+			return toolErrorf(codeInternal, "no symbol could be queried: %v", errs), nil
+		}
+
+		result := map[string]interface{}{
+			"exchange":       q.exchange,
+			"binSize":        q.binSize,
+			"candleType":     q.candleType,
+			"perSymbolLimit": q.limit,
+			"totalCount":     total,
+			"candles":        candles,
+			"details":        details,
+		}
+		if len(errs) > 0 {
+			result["errors"] = errs
+		}
+		data, _ := json.MarshalIndent(result, "", "  ")
+		return mcp.NewToolResultText(string(data)), nil
+	})
+}
@@ -0,0 +1,55 @@
+package tools
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ztrade/trademodel"
+	"github.com/ztrade/ztrade/pkg/process/dbstore"
+)
+
+func TestMultiSymbolLimit(t *testing.T) {
+	if got := multiSymbolLimit(500, 3); got != 500 {
+		t.Errorf("within the cap: %d", got)
+	}
+	if got := multiSymbolLimit(queryKlineMaxResult, 8); got != queryKlineMultiMaxRows/8 {
+		t.Errorf("over the cap: %d", got)
+	}
+}
+
+func TestKlineQueryLoad(t *testing.T) {
+	db, err := dbstore.NewDBStore("sqlite", filepath.Join(t.TempDir(), "kline.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, sym := range []string{"BTCUSDT", "ETHUSDT"} {
+		var candles []*trademodel.Candle
+		for i := 0; i < 60; i++ {
+			price := float64(100 + i)
+			candles = append(candles, &trademodel.Candle{Start: base.Add(time.Duration(i) * time.Minute).Unix(), Open: price, High: price + 1, Low: price - 1, Close: price, Volume: 1})
+		}
+		if _, err := saveFetchedKlines(db, "binance", sym, "1m", candles); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	q := klineQuery{exchange: "binance", binSize: "5m", candleType: candleTypeNormal, start: base, end: base.Add(time.Hour), limit: 100}
+	series, err := q.load(db, "BTC-USDT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if series.symbol != "BTCUSDT" || series.sourceBinSize != "1m" || len(series.candles) != 12 || series.originalCount != 12 {
+		t.Fatalf("series: %+v", series)
+	}
+	if c := series.candles[0]; c.Open != 100 || c.Close != 104 || c.High != 105 || c.Volume != 5 {
+		t.Errorf("merged candle: %+v", c)
+	}
+
+	q.limit, q.maxPoints = multiSymbolLimit(queryKlineMaxResult, 2), 10
+	series, err = q.load(db, "ETHUSDT")
+	if err != nil || len(series.candles) != 10 || series.originalCount != 12 {
+		t.Fatalf("downsampled: %+v, %v", series, err)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
@@ -12,33 +13,80 @@ import (
 	"github.com/ztrade/ztrade/pkg/process/dbstore"
 )
 
+// dataSummary is the list_data groupBy=symbol entry of one exchange/symbol.
+type dataSummary struct {
+	Exchange string   `json:"exchange"`
+	Symbol   string   `json:"symbol"`
+	BinSizes []string `json:"binSizes"`
+	Start    string   `json:"start"` // earliest start over the binSizes
+	End      string   `json:"end"`   // latest end over the binSizes
+	Rows     int64    `json:"rows"`
+}
+
+// summarizeDataBySymbol collapses the datasets of each exchange/symbol into
+// one entry. infos are in ListAll order, which keeps each symbol's datasets
+// together and its binSizes from shortest to longest.
+func summarizeDataBySymbol(db *dbstore.DBStore, infos []ctl.LocalDataInfo) ([]dataSummary, error) {
+	var out []dataSummary
+	var start, end time.Time
+	for _, info := range infos {
+		rows, err := db.GetKlineTbl(info.Exchange, info.Symbol, info.BinSize).Count()
+		if err != nil {
+			return nil, fmt.Errorf("count %s %s %s: %s", info.Exchange, info.Symbol, info.BinSize, err.Error())
+		}
+		n := len(out)
+		if n == 0 || out[n-1].Exchange != info.Exchange || out[n-1].Symbol != info.Symbol {
+			out = append(out, dataSummary{Exchange: info.Exchange, Symbol: info.Symbol})
+			start, end = info.Start, info.End
+			n++
+		}
+		e := &out[n-1]
+		e.BinSizes = append(e.BinSizes, info.BinSize)
+		e.Rows += rows
+		if info.Start.Before(start) {
+			start = info.Start
+		}
+		if info.End.After(end) {
+			end = info.End
+		}
+		e.Start = start.Format("2006-01-02 15:04:05")
+		e.End = end.Format("2006-01-02 15:04:05")
+	}
+	return out, nil
+}
+
 func registerListData(s *server.MCPServer, db *dbstore.DBStore) {
 	tool := mcp.NewTool("list_data",
 		mcp.WithDescription("List all available K-line data stored in the local database. Returns exchange, symbol, binSize, start time, and end time for each dataset."),
 		mcp.WithString("exchange", mcp.Description("Filter by exchange name (e.g., binance, okx). Optional.")),
 		mcp.WithString("symbol", mcp.Description("Filter by trading pair (e.g., BTCUSDT). Optional.")),
+		mcp.WithString("groupBy", mcp.Description("'symbol' returns one entry per exchange/symbol with its binSizes, the widest start/end span and the total row count, as a data inventory. Default: one entry per dataset")),
 	)
 
 	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		if db == nil {
-			return mcp.NewToolResultError("database not initialized"), nil
+			return toolError(codeUnavailable, "database not initialized"), nil
 		}
 
 		ld, err := ctl.NewLocalData(db)
 		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("failed to create local data: %s", err.Error())), nil
+			return toolErrorf(codeInternal, "failed to create local data: %s", err.Error()), nil
 		}
 
 		infos, err := ld.ListAll()
 		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("failed to list data: %s", err.Error())), nil
+			return toolErrorf(codeInternal, "failed to list data: %s", err.Error()), nil
 		}
 
 		// Apply filters
 		exchangeFilter := req.GetString("exchange", "")
 		symbolFilter := req.GetString("symbol", "")
+		groupBy := req.GetString("groupBy", "")
+		if groupBy != "" && groupBy != "symbol" {
+			return toolErrorf(codeInvalidArgument, "invalid groupBy %s, supported: symbol", groupBy), nil
+		}
 
-		var filtered []map[string]interface{}
+		var matched []ctl.LocalDataInfo
 		for _, info := range infos {
 			if exchangeFilter != "" && !strings.EqualFold(info.Exchange, exchangeFilter) {
 				continue
@@ -46,6 +94,20 @@ func registerListData(s *server.MCPServer, db *dbstore.DBStore) {
 			if symbolFilter != "" && !strings.EqualFold(info.Symbol, symbolFilter) {
 				continue
 			}
+			matched = append(matched, info)
+		}
+
+		if groupBy == "symbol" {
+			summaries, err := summarizeDataBySymbol(db, matched)
+			if err != nil {
+				return toolErrorf(codeInternal, "failed to summarize data: %s", err.Error()), nil
+			}
+			data, _ := json.MarshalIndent(summaries, "", "  ")
+			return mcp.NewToolResultText(string(data)), nil
+		}
+
+		var filtered []map[string]interface{}
+		for _, info := range matched {
 			filtered = append(filtered, map[string]interface{}{
 				"exchange": info.Exchange,
 				"symbol":   info.Symbol,
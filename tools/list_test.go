@@ -0,0 +1,54 @@
+package tools
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ztrade/trademodel"
+	"github.com/ztrade/ztrade/pkg/ctl"
+	"github.com/ztrade/ztrade/pkg/process/dbstore"
+)
+
+func TestSummarizeDataBySymbol(t *testing.T) {
+	db, err := dbstore.NewDBStore("sqlite", filepath.Join(t.TempDir(), "kline.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	write := func(symbol, binSize string, from time.Time, n int, step time.Duration) {
+		var candles []*trademodel.Candle
+		for i := 0; i < n; i++ {
+			candles = append(candles, &trademodel.Candle{Start: from.Add(time.Duration(i) * step).Unix(), Open: 1, High: 1, Low: 1, Close: 1})
+		}
+		if _, err := saveFetchedKlines(db, "binance", symbol, binSize, candles); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("BTCUSDT", "1m", start.Add(time.Hour), 300, time.Minute)
+	write("BTCUSDT", "1h", start, 5, time.Hour)
+	write("ETHUSDT", "1m", start, 10, time.Minute)
+
+	ld, err := ctl.NewLocalData(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	infos, err := ld.ListAll()
+	if err != nil || len(infos) != 3 {
+		t.Fatalf("ListAll: %+v, %v", infos, err)
+	}
+	got, err := summarizeDataBySymbol(db, infos)
+	if err != nil || len(got) != 2 {
+		t.Fatalf("summary: %+v, %v", got, err)
+	}
+	btc := got[0]
+	if btc.Symbol != "BTCUSDT" || len(btc.BinSizes) != 2 || btc.BinSizes[0] != "1m" || btc.BinSizes[1] != "1h" || btc.Rows != 305 {
+		t.Errorf("BTCUSDT: %+v", btc)
+	}
+	if btc.Start != infos[1].Start.Format("2006-01-02 15:04:05") || btc.End != infos[0].End.Format("2006-01-02 15:04:05") {
+		t.Errorf("BTCUSDT span %s - %s from %+v", btc.Start, btc.End, infos[:2])
+	}
+	if got[1].Symbol != "ETHUSDT" || got[1].Rows != 10 {
+		t.Errorf("ETHUSDT: %+v", got[1])
+	}
+}
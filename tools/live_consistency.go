@@ -0,0 +1,272 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/ztrade/ztrade-mcp/internal/stats"
+	"github.com/ztrade/ztrade-mcp/store"
+)
+
+const (
+	// defaultConsistencyDays is the live period compare_live_backtest looks
+	// back over.
+	defaultConsistencyDays = 30
+	// minConsistencyTrades is how many closing trades each side needs before
+	// the two are tested against each other.
+	minConsistencyTrades = 5
+	// divergenceScore is the |z| (win rate) or |t| (average trade return)
+	// from which live and backtest results diverge significantly, about the
+	// 95% level.
+	divergenceScore = 2.0
+)
+
+// tradeOutcomes summarizes the closing trades of one side of the comparison.
+// Returns are the realized profit in percent of the closed amount at the
+// exit price, so accounts of different sizes compare.
+type tradeOutcomes struct {
+	Trades       int     `json:"trades"`
+	Wins         int     `json:"wins"`
+	WinRate      float64 `json:"winRate"`
+	TotalProfit  float64 `json:"totalProfit"`
+	AvgReturnPct float64 `json:"avgReturnPct"`
+	StdReturnPct float64 `json:"stdReturnPct"`
+	TradesPerDay float64 `json:"tradesPerDay"`
+	returns      []float64
+}
+
+func newTradeOutcomes(profits, notionals []float64, days float64) tradeOutcomes {
+	o := tradeOutcomes{Trades: len(profits)}
+	for i, p := range profits {
+		o.TotalProfit += p
+		if p > 0 {
+			o.Wins++
+		}
+		if notionals[i] > 0 {
+			o.returns = append(o.returns, p/notionals[i]*100)
+		}
+	}
+	if o.Trades > 0 {
+		o.WinRate = float64(o.Wins) / float64(o.Trades)
+	}
+	o.AvgReturnPct = stats.Mean(o.returns)
+	o.StdReturnPct = stats.StdDev(o.returns)
+	if days > 0 {
+		o.TradesPerDay = float64(o.Trades) / days
+	}
+	return o
+}
+
+// liveConsistency is the comparison of live and backtest trade outcomes.
+type liveConsistency struct {
+	WinRateZ  float64  `json:"winRateZ"` // two-proportion z of backtest minus live win rate
+	ReturnT   float64  `json:"returnT"`  // Welch t of backtest minus live average trade return
+	Divergent bool     `json:"divergent"`
+	Findings  []string `json:"findings"`
+}
+
+// compareTradeOutcomes tests whether live results differ significantly from
+// the backtest: a two-proportion z-test on the win rates and a Welch t-test
+// on the average trade returns. Positive scores mean live does worse.
+func compareTradeOutcomes(backtest, live tradeOutcomes) liveConsistency {
+	var c liveConsistency
+	if backtest.Trades < minConsistencyTrades || live.Trades < minConsistencyTrades {
+		c.Findings = append(c.Findings, fmt.Sprintf("too few closing trades to compare (backtest %d, live %d, need %d each)", backtest.Trades, live.Trades, minConsistencyTrades))
+		return c
+	}
+	nb, nl := float64(backtest.Trades), float64(live.Trades)
+	pooled := float64(backtest.Wins+live.Wins) / (nb + nl)
+	if se := math.Sqrt(pooled * (1 - pooled) * (1/nb + 1/nl)); se > 0 {
+		c.WinRateZ = (backtest.WinRate - live.WinRate) / se
+	}
+	if len(backtest.returns) >= 2 && len(live.returns) >= 2 {
+		se := math.Sqrt(backtest.StdReturnPct*backtest.StdReturnPct/float64(len(backtest.returns)) + live.StdReturnPct*live.StdReturnPct/float64(len(live.returns)))
+		if se > 0 {
+			c.ReturnT = (backtest.AvgReturnPct - live.AvgReturnPct) / se
+		}
+	}
+	describe := func(score float64) string {
+		if score > 0 {
+			return "lower live than in the backtest: the edge may have decayed or the backtest is unrealistic (slippage, latency, fees)"
+		}
+		return "higher live than in the backtest: the backtest may be too pessimistic, or live luck"
+	}
+	if math.Abs(c.WinRateZ) >= divergenceScore {
+		c.Findings = append(c.Findings, fmt.Sprintf("win rate %.1f%% vs %.1f%% (z=%.2f) is significantly %s", live.WinRate*100, backtest.WinRate*100, c.WinRateZ, describe(c.WinRateZ)))
+	}
+	if math.Abs(c.ReturnT) >= divergenceScore {
+		c.Findings = append(c.Findings, fmt.Sprintf("average trade return %.3f%% vs %.3f%% (t=%.2f) is significantly %s", live.AvgReturnPct, backtest.AvgReturnPct, c.ReturnT, describe(c.ReturnT)))
+	}
+	c.Divergent = len(c.Findings) > 0
+	if !c.Divergent {
+		c.Findings = append(c.Findings, "live results are consistent with the backtest")
+	}
+	return c
+}
+
+// consistencyRecord picks the backtest record to compare live trading on
+// exchange/symbol pairs with: the one of records overlapping [since, now)
+// the most, the newest on ties.
+func consistencyRecord(records []store.BacktestRecord, markets map[string]bool, since, now time.Time) *store.BacktestRecord {
+	var best *store.BacktestRecord
+	var bestOverlap time.Duration
+	for i := range records {
+		r := &records[i]
+		if !markets[r.Exchange+"/"+r.Symbol] {
+			continue
+		}
+		overlap := minTime(r.EndTime, now).Sub(maxTime(r.StartTime, since))
+		if overlap > bestOverlap {
+			best, bestOverlap = r, overlap
+		}
+	}
+	return best
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+func registerCompareLiveBacktest(s *server.MCPServer, st *store.Store) {
+	tool := mcp.NewTool("compare_live_backtest",
+		mcp.WithDescription("Compare the realized live results of a stored strategy with its backtest over the same recent period: closing trades, win rate, average trade return (profit in percent of the closed amount at the exit price, so account sizes do not matter) and trade frequency, with significance tests flagging divergence. Live fills are recorded while start_trade/attach_trade instances run. The backtest record must cover part of the period; by default the record of the strategy on a live-traded exchange/symbol that overlaps it most is used."),
+		mcp.WithNumber("strategyId", mcp.Required(), mcp.Description("Strategy ID")),
+		mcp.WithNumber("days", mcp.Description(fmt.Sprintf("Compare the last N days. Default: %d", defaultConsistencyDays))),
+		mcp.WithNumber("recordId", mcp.Description("Backtest record to compare with. Default: picked automatically")),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if st == nil {
+			return toolError(codeUnavailable, "script store not initialized (check database config)"), nil
+		}
+
+		strategyID := int64(req.GetFloat("strategyId", 0))
+		days := req.GetFloat("days", defaultConsistencyDays)
+		if days <= 0 {
+			return toolError(codeInvalidArgument, "days must be positive"), nil
+		}
+		recordID := int64(req.GetFloat("recordId", 0))
+		if _, err := accessibleScript(ctx, st, strategyID, false); err != nil {
+			return toolErrorf(storeErrorCode(err), "failed to get script: %s", err.Error()), nil
+		}
+
+		trades, err := st.ListLiveTrades("")
+		if err != nil {
+			return toolErrorf(codeInternal, "failed to list live trades: %s", err.Error()), nil
+		}
+		markets := map[string]bool{}
+		for _, lt := range trades {
+			if lt.ScriptID == strategyID {
+				markets[lt.Exchange+"/"+lt.Symbol] = true
+			}
+		}
+		if len(markets) == 0 {
+			return toolErrorf(codeFailedPrecondition, "strategy %d has no live trades started from the database", strategyID), nil
+		}
+
+		now := time.Now()
+		since := now.Add(-time.Duration(days * float64(24*time.Hour)))
+		var record *store.BacktestRecord
+		if recordID > 0 {
+			if record, _, err = accessibleRecord(ctx, st, recordID); err != nil {
+				return toolErrorf(storeErrorCode(err), "failed to get record: %s", err.Error()), nil
+			}
+			if record.ScriptID != strategyID {
+				return toolErrorf(codeInvalidArgument, "backtest record %d belongs to strategy %d, not %d", recordID, record.ScriptID, strategyID), nil
+			}
+			if !markets[record.Exchange+"/"+record.Symbol] {
+				return toolErrorf(codeFailedPrecondition, "strategy %d was not traded live on %s %s", strategyID, record.Exchange, record.Symbol), nil
+			}
+		} else {
+			records, err := st.ListBacktestRecords(strategyID, 0)
+			if err != nil {
+				return toolErrorf(codeInternal, "failed to list backtest records: %s", err.Error()), nil
+			}
+			record = consistencyRecord(records, markets, since, now)
+		}
+		if record == nil {
+			return toolErrorf(codeFailedPrecondition, "no backtest record of strategy %d on a live-traded symbol covers the last %g days; run run_backtest_managed over the recent period first", strategyID, days), nil
+		}
+		from, to := maxTime(record.StartTime, since), minTime(record.EndTime, now)
+		if !to.After(from) {
+			return toolErrorf(codeFailedPrecondition, "backtest record %d (%s to %s) does not overlap the last %g days; run run_backtest_managed over the recent period first",
+				record.ID, record.StartTime.Format("2006-01-02 15:04:05"), record.EndTime.Format("2006-01-02 15:04:05"), days), nil
+		}
+		windowDays := to.Sub(from).Hours() / 24
+
+		var tradeIDs []string
+		for _, lt := range trades {
+			if lt.ScriptID == strategyID && lt.Exchange == record.Exchange && lt.Symbol == record.Symbol {
+				tradeIDs = append(tradeIDs, lt.TradeID)
+			}
+		}
+		fills, err := st.ListLiveFills(tradeIDs, from, to)
+		if err != nil {
+			return toolErrorf(codeInternal, "failed to list live fills: %s", err.Error()), nil
+		}
+		var liveProfits, liveNotionals []float64
+		unknownEntry := 0
+		for _, f := range fills {
+			switch {
+			case f.Closed == 0:
+			case f.EntryPrice == 0:
+				unknownEntry++
+			default:
+				liveProfits = append(liveProfits, f.Profit)
+				liveNotionals = append(liveNotionals, f.Price*f.Closed)
+			}
+		}
+
+		btTrades, err := st.ListBacktestTrades(record.ID)
+		if err != nil {
+			return toolErrorf(codeInternal, "failed to get backtest trades: %s", err.Error()), nil
+		}
+		if len(btTrades) == 0 && record.TotalActions > 0 {
+			return toolError(codeFailedPrecondition, "no trades stored for this record (records created before trade persistence have none)"), nil
+		}
+		var btProfits, btNotionals []float64
+		for _, t := range btTrades {
+			// trades without profit are openings, as in trade_distribution
+			if t.Profit == 0 || t.Time.Before(from) || !t.Time.Before(to) {
+				continue
+			}
+			btProfits = append(btProfits, t.Profit)
+			btNotionals = append(btNotionals, t.Price*t.Amount)
+		}
+
+		backtest := newTradeOutcomes(btProfits, btNotionals, windowDays)
+		live := newTradeOutcomes(liveProfits, liveNotionals, windowDays)
+		result := map[string]interface{}{
+			"strategyId": strategyID,
+			"recordId":   record.ID,
+			"exchange":   record.Exchange,
+			"symbol":     record.Symbol,
+			"from":       from.Format("2006-01-02 15:04:05"),
+			"to":         to.Format("2006-01-02 15:04:05"),
+			"liveTrades": len(tradeIDs),
+			"backtest":   backtest,
+			"live":       live,
+			"comparison": compareTradeOutcomes(backtest, live),
+		}
+		if unknownEntry > 0 {
+			result["liveClosesWithoutEntry"] = unknownEntry
+		}
+		data, _ := json.MarshalIndent(result, "", "  ")
+		return mcp.NewToolResultText(string(data)), nil
+	})
+}
@@ -0,0 +1,91 @@
+package tools
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/ztrade/trademodel"
+	"github.com/ztrade/ztrade-mcp/store"
+)
+
+func TestCompareTradeOutcomes(t *testing.T) {
+	notionals := func(n int) []float64 {
+		xs := make([]float64, n)
+		for i := range xs {
+			xs[i] = 1000
+		}
+		return xs
+	}
+	// backtest: 8 of 10 win 20, live: 2 of 10 win 20, the rest lose 10
+	bt := []float64{20, 20, 20, 20, 20, 20, 20, 20, -10, -10}
+	lv := []float64{20, 20, -10, -10, -10, -10, -10, -10, -10, -10}
+	backtest := newTradeOutcomes(bt, notionals(10), 10)
+	live := newTradeOutcomes(lv, notionals(10), 10)
+	if backtest.WinRate != 0.8 || live.TotalProfit != -40 || backtest.AvgReturnPct != 1.4 || live.TradesPerDay != 1 {
+		t.Fatalf("outcomes: %+v, %+v", backtest, live)
+	}
+	c := compareTradeOutcomes(backtest, live)
+	if !c.Divergent || c.WinRateZ < divergenceScore || c.ReturnT < divergenceScore || len(c.Findings) != 2 {
+		t.Fatalf("divergent: %+v", c)
+	}
+
+	if c := compareTradeOutcomes(backtest, backtest); c.Divergent || c.WinRateZ != 0 || c.ReturnT != 0 {
+		t.Errorf("identical: %+v", c)
+	}
+	few := newTradeOutcomes(lv[:3], notionals(3), 10)
+	if c := compareTradeOutcomes(backtest, few); c.Divergent || len(c.Findings) != 1 {
+		t.Errorf("too few trades: %+v", c)
+	}
+}
+
+func TestConsistencyRecord(t *testing.T) {
+	now := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	since := now.AddDate(0, 0, -30)
+	records := []store.BacktestRecord{
+		{ID: 3, Exchange: "binance", Symbol: "ETHUSDT", StartTime: since, EndTime: now},
+		{ID: 2, Exchange: "binance", Symbol: "BTCUSDT", StartTime: since.AddDate(0, 0, -60), EndTime: since.AddDate(0, 0, 10)},
+		{ID: 1, Exchange: "binance", Symbol: "BTCUSDT", StartTime: since.AddDate(0, 0, 5), EndTime: now},
+		{ID: 0, Exchange: "binance", Symbol: "BTCUSDT", StartTime: since.AddDate(0, 0, -90), EndTime: since.AddDate(0, 0, -1)},
+	}
+	markets := map[string]bool{"binance/BTCUSDT": true}
+	if r := consistencyRecord(records, markets, since, now); r == nil || r.ID != 1 {
+		t.Fatalf("picked %+v", r)
+	}
+	if r := consistencyRecord(records[3:], markets, since, now); r != nil {
+		t.Errorf("record before the period picked: %+v", r)
+	}
+}
+
+func TestLiveReporterStoresFills(t *testing.T) {
+	cfg := viper.New()
+	cfg.Set("db.type", "sqlite")
+	cfg.Set("db.uri", filepath.Join(t.TempDir(), "mcp.db"))
+	st, err := store.NewStore(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	// re-attached with 1 long of unknown entry
+	r := newLiveReporter(st, "t1", 1)
+	r.OnTrade(trademodel.Trade{Action: trademodel.CloseLong, Amount: 1, Price: 120, Time: start})
+	r.OnTrade(trademodel.Trade{Action: trademodel.OpenShort, Amount: 2, Price: 100, Time: start.Add(time.Hour)})
+	r.OnTrade(trademodel.Trade{Action: trademodel.CloseShort, Amount: 1, Price: 90, Time: start.Add(2 * time.Hour)})
+
+	fills, err := st.ListLiveFills([]string{"t1"}, start.Add(-time.Minute), start.Add(3*time.Hour))
+	if err != nil || len(fills) != 3 {
+		t.Fatalf("fills = %+v, %v", fills, err)
+	}
+	if fills[0].Closed != 1 || fills[0].EntryPrice != 0 || fills[0].Profit != 0 {
+		t.Errorf("close of unknown entry: %+v", fills[0])
+	}
+	if fills[1].Closed != 0 || fills[1].Action != trademodel.OpenShort.String() {
+		t.Errorf("open: %+v", fills[1])
+	}
+	if fills[2].Closed != 1 || fills[2].EntryPrice != 100 || fills[2].Profit != 10 {
+		t.Errorf("close: %+v", fills[2])
+	}
+}
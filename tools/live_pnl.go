@@ -0,0 +1,104 @@
+package tools
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/ztrade/trademodel"
+	"github.com/ztrade/ztrade-mcp/store"
+)
+
+// positionBook follows a net position through its fills with average-cost
+// accounting. The entry price of a starting position is unknown (it comes
+// from a re-attached trade) until the position is flat or reversed.
+type positionBook struct {
+	pos, entry float64
+	entryKnown bool
+}
+
+func newPositionBook(initial float64) positionBook {
+	return positionBook{pos: initial, entryKnown: initial == 0}
+}
+
+// apply books a fill and returns the amount of the position it closed, the
+// average entry price of that position and the PnL realized on it. entry
+// and profit are zero when the entry price is unknown.
+func (b *positionBook) apply(f trademodel.Trade) (closed, entry, profit float64) {
+	delta := positionDelta(f.Action, f.Amount)
+	if b.pos == 0 || (b.pos > 0) == (delta > 0) {
+		if b.entryKnown {
+			b.entry = (b.entry*math.Abs(b.pos) + f.Price*math.Abs(delta)) / math.Abs(b.pos+delta)
+		}
+		b.pos += delta
+		return 0, 0, 0
+	}
+	closed = math.Min(math.Abs(delta), math.Abs(b.pos))
+	if b.entryKnown {
+		entry = b.entry
+		profit = closed * (f.Price - entry) * float64(positionSign(b.pos))
+	}
+	b.pos += delta
+	if math.Abs(b.pos) <= positionTolerance {
+		b.pos, b.entry, b.entryKnown = 0, 0, true
+	} else if (b.pos > 0) == (delta > 0) {
+		// the fill reversed the position: the rest opens at its price
+		b.entry, b.entryKnown = f.Price, true
+	}
+	return closed, entry, profit
+}
+
+// realizedPnl returns the PnL realized by fills on top of a starting
+// position, see positionBook. Closes against a starting position of
+// unknown entry are left out and complete is false.
+func realizedPnl(initial float64, fills []trademodel.Trade) (pnl float64, complete bool) {
+	book := newPositionBook(initial)
+	complete = true
+	for _, f := range fills {
+		closed, entry, profit := book.apply(f)
+		if closed > 0 && entry == 0 {
+			complete = false
+		}
+		pnl += profit
+	}
+	return pnl, complete
+}
+
+// liveTradePnl returns the realized PnL of a live trade since it started
+// in this process, the funding paid on its position over that time (from
+// the funding rate history, see loadFundingRates) and the funding-adjusted
+// net PnL. Funding that cannot be loaded, e.g. for a spot symbol, is
+// reported as fundingError and leaves netPnl out.
+func liveTradePnl(ctx context.Context, cfg *viper.Viper, st *store.Store, inst *tradeInstance, now time.Time) map[string]interface{} {
+	if inst.reporter == nil {
+		return nil
+	}
+	initial, fills := inst.reporter.Fills()
+	pnl, complete := realizedPnl(initial, fills)
+	ret := map[string]interface{}{
+		"position":            inst.reporter.Position(),
+		"fills":               len(fills),
+		"realizedPnl":         pnl,
+		"realizedPnlComplete": complete,
+	}
+
+	rates, err := loadFundingRates(ctx, cfg, st, inst.Exchange, inst.Symbol, inst.Started, now)
+	if err != nil {
+		ret["fundingError"] = err.Error()
+		return ret
+	}
+	held := fills
+	if initial != 0 {
+		open := trademodel.Trade{Action: trademodel.OpenLong, Amount: initial, Time: inst.Started}
+		if initial < 0 {
+			open.Action, open.Amount = trademodel.OpenShort, -initial
+		}
+		held = append([]trademodel.Trade{open}, fills...)
+	}
+	funding, events := calcFundingCost(held, rates)
+	ret["fundingPaid"] = funding
+	ret["fundingEvents"] = events
+	ret["netPnl"] = pnl - funding
+	return ret
+}
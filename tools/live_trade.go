@@ -0,0 +1,239 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"github.com/ztrade/trademodel"
+	"github.com/ztrade/ztrade-mcp/store"
+)
+
+// positionTolerance is the largest difference between the persisted and
+// the exchange position that is still treated as a match.
+const positionTolerance = 1e-8
+
+// liveReporter tracks the net position of a live trade from its fills and
+// persists it so the trade can be re-attached after a restart. The fills of
+// this process are kept for the PnL reported by trade_status, and each fill
+// is stored with the PnL it realized for compare_live_backtest.
+type liveReporter struct {
+	st       *store.Store
+	tradeID  string
+	mu       sync.Mutex
+	position float64
+	initial  float64 // position held when the reporter was created
+	fills    []trademodel.Trade
+	book     positionBook
+}
+
+func newLiveReporter(st *store.Store, tradeID string, position float64) *liveReporter {
+	return &liveReporter{st: st, tradeID: tradeID, position: position, initial: position, book: newPositionBook(position)}
+}
+
+func (r *liveReporter) SetTimeRange(start, end time.Time)              {}
+func (r *liveReporter) OnBalanceInit(balance, fee float64) (err error) { return nil }
+func (r *liveReporter) SetLever(float64)                               {}
+
+func (r *liveReporter) OnTrade(t trademodel.Trade) {
+	r.mu.Lock()
+	r.position += positionDelta(t.Action, t.Amount)
+	r.fills = append(r.fills, t)
+	pos := r.position
+	closed, entry, profit := r.book.apply(t)
+	r.mu.Unlock()
+	r.persist(pos)
+	if t.Time.IsZero() {
+		t.Time = time.Now()
+	}
+	r.persistFill(store.LiveFill{
+		TradeID: r.tradeID, Time: t.Time, Action: t.Action.String(), Price: t.Price, Amount: t.Amount,
+		Closed: closed, EntryPrice: entry, Profit: profit,
+	})
+}
+
+// Position returns the tracked net position.
+func (r *liveReporter) Position() float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.position
+}
+
+// Fills returns the starting position and the fills since.
+func (r *liveReporter) Fills() (initial float64, fills []trademodel.Trade) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.initial, append([]trademodel.Trade(nil), r.fills...)
+}
+
+// SetPosition overwrites the tracked net position, e.g. after reconciling
+// it with the exchange, and persists it.
+func (r *liveReporter) SetPosition(pos float64) {
+	r.mu.Lock()
+	r.position = pos
+	if math.Abs(r.book.pos-pos) > positionTolerance {
+		// the entry price of the reconciled position is unknown
+		r.book = newPositionBook(pos)
+	}
+	r.mu.Unlock()
+	r.persist(pos)
+}
+
+func (r *liveReporter) persist(pos float64) {
+	if r.st == nil {
+		return
+	}
+	if err := r.st.UpdateLiveTradePosition(r.tradeID, pos); err != nil {
+		log.WithError(err).WithField("tradeId", r.tradeID).Error("persist live trade position failed")
+	}
+}
+
+func (r *liveReporter) persistFill(fill store.LiveFill) {
+	if r.st == nil {
+		return
+	}
+	if err := r.st.SaveLiveFill(&fill); err != nil {
+		log.WithError(err).WithField("tradeId", r.tradeID).Error("persist live fill failed")
+	}
+}
+
+// positionDelta is the signed change in net position caused by a fill.
+func positionDelta(action trademodel.TradeType, amount float64) float64 {
+	if action.IsLong() {
+		return amount
+	}
+	return -amount
+}
+
+// orphanedLiveTrades returns the persisted running trades that have no
+// instance in this process. The caller must hold manager.mu.
+func orphanedLiveTrades(st *store.Store) []store.LiveTrade {
+	if st == nil {
+		return nil
+	}
+	running, err := st.ListLiveTrades(store.LiveTradeRunning)
+	if err != nil {
+		log.WithError(err).Warn("list persisted live trades failed")
+		return nil
+	}
+	var ret []store.LiveTrade
+	for _, lt := range running {
+		if _, ok := manager.trades[lt.TradeID]; !ok && !manager.launching[lt.TradeID] {
+			ret = append(ret, lt)
+		}
+	}
+	return ret
+}
+
+func registerAttachTrade(s *server.MCPServer, cfg *viper.Viper, st *store.Store) {
+	tool := mcp.NewTool("attach_trade",
+		mcp.WithDescription("Re-attach a live trade that was running before a restart (listed as orphaned by trade_status). Rebuilds the strategy version the trade was started with (not later edits), reloads recent data and resumes managing the trade under the same trade ID so trade_status/stop_trade work again. The exchange position is compared with the persisted one and a warning is returned on mismatch."),
+		mcp.WithString("tradeId", mcp.Required(), mcp.Description("Trade ID of the orphaned instance")),
+		mcp.WithNumber("recentDays", mcp.Description("Load recent N days of historical data. Default: the value used at start")),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if !cfg.GetBool("mcp.enableLiveTrade") {
+			return toolError(codeFailedPrecondition, "live trading is disabled. Set mcp.enableLiveTrade: true in config to enable"), nil
+		}
+		if st == nil {
+			return toolError(codeUnavailable, "script store not initialized (check database config)"), nil
+		}
+
+		tradeID := req.GetString("tradeId", "")
+		lt, err := st.GetLiveTrade(tradeID)
+		if err != nil {
+			return toolError(codeNotFound, err.Error()), nil
+		}
+		if lt.Status != store.LiveTradeRunning {
+			return toolErrorf(codeFailedPrecondition, "live trade %s is %s, only running trades can be attached", tradeID, lt.Status), nil
+		}
+		manager.mu.RLock()
+		_, attached := manager.trades[tradeID]
+		manager.mu.RUnlock()
+		if attached {
+			return toolErrorf(codeFailedPrecondition, "live trade %s is already managed by this process", tradeID), nil
+		}
+
+		var warnings []string
+		script := lt.Script
+		if lt.ScriptID > 0 {
+			sc, err := accessibleScript(ctx, st, lt.ScriptID, false)
+			if err != nil {
+				return toolErrorf(storeErrorCode(err), "strategy not found: %s", err.Error()), nil
+			}
+			// rebuild the code the trade was started with, not later edits
+			switch {
+			case lt.ScriptVersion == 0:
+				warnings = append(warnings, fmt.Sprintf("the strategy version of this trade was not recorded; rebuilt the current version %d", sc.Version))
+			case lt.ScriptVersion != sc.Version:
+				ver, err := st.GetVersion(lt.ScriptID, lt.ScriptVersion)
+				if err != nil {
+					return toolErrorf(storeErrorCode(err), "failed to get version %d the trade was started with: %s", lt.ScriptVersion, err.Error()), nil
+				}
+				sc.Content, sc.Version = ver.Content, ver.Version
+			}
+			if script, err = buildStoredScript(sc); err != nil {
+				return toolError(codeBuildFailed, err.Error()), nil
+			}
+		} else if _, err := os.Stat(script); err != nil {
+			return toolErrorf(codeNotFound, "strategy file %s is not available: %s", script, err.Error()), nil
+		}
+
+		exchangePos, posErr := fetchExchangePosition(ctx, cfg, lt.Exchange, lt.Symbol)
+		if posErr != nil {
+			warnings = append(warnings, "could not verify exchange position: "+posErr.Error())
+		} else if math.Abs(exchangePos-lt.Position) > positionTolerance {
+			warnings = append(warnings, fmt.Sprintf("position mismatch: persisted %v, exchange %v; the strategy takes its position from the exchange; only the persisted position record is carried over", lt.Position, exchangePos))
+		}
+
+		recentDays := int(req.GetFloat("recentDays", float64(lt.RecentDays)))
+		if recentDays <= 0 {
+			recentDays = 1
+		}
+		instance := &tradeInstance{
+			ID:       tradeID,
+			Exchange: lt.Exchange,
+			Symbol:   lt.Symbol,
+			Script:   script,
+			ScriptID: lt.ScriptID,
+			Started:  time.Now(),
+		}
+		if err := launchTrade(cfg, st, instance, lt.Param, recentDays, lt.Position); err != nil {
+			return toolError(launchErrorCode(err), err.Error()), nil
+		}
+		logger := log.WithContext(ctx).WithField("tradeId", tradeID)
+		for _, w := range warnings {
+			logger.Warn(w)
+		}
+		logger.Info("live trade attached")
+
+		result := map[string]interface{}{
+			"status":            "attached",
+			"tradeId":           tradeID,
+			"exchange":          lt.Exchange,
+			"symbol":            lt.Symbol,
+			"script":            script,
+			"persistedPosition": lt.Position,
+		}
+		if lt.ScriptID > 0 {
+			result["scriptVersion"] = lt.ScriptVersion
+		}
+		if posErr == nil {
+			result["exchangePosition"] = exchangePos
+		}
+		if len(warnings) > 0 {
+			result["warnings"] = warnings
+		}
+		data, _ := json.MarshalIndent(result, "", "  ")
+		return mcp.NewToolResultText(string(data)), nil
+	})
+}
@@ -0,0 +1,115 @@
+package tools
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/ztrade/trademodel"
+)
+
+func TestLiveReporterPosition(t *testing.T) {
+	r := newLiveReporter(nil, "t1", 1)
+	for _, tr := range []trademodel.Trade{
+		{Action: trademodel.OpenLong, Amount: 2},
+		{Action: trademodel.CloseLong, Amount: 3},
+		{Action: trademodel.OpenShort, Amount: 1},
+		{Action: trademodel.StopShort, Amount: 0.5},
+	} {
+		r.OnTrade(tr)
+	}
+	if r.position != -0.5 {
+		t.Fatalf("position = %v, want -0.5", r.position)
+	}
+}
+
+func TestTradesOfScript(t *testing.T) {
+	manager.mu.Lock()
+	manager.trades["a"] = &tradeInstance{ID: "a", ScriptID: 7}
+	manager.trades["b"] = &tradeInstance{ID: "b"}
+	manager.mu.Unlock()
+	defer func() {
+		manager.mu.Lock()
+		delete(manager.trades, "a")
+		delete(manager.trades, "b")
+		manager.mu.Unlock()
+	}()
+	if ids := manager.tradesOfScript(7); len(ids) != 1 || ids[0] != "a" {
+		t.Fatalf("tradesOfScript(7) = %v", ids)
+	}
+	if ids := manager.tradesOfScript(8); len(ids) != 0 {
+		t.Fatalf("tradesOfScript(8) = %v", ids)
+	}
+}
+
+func TestLaunchTradeReservesID(t *testing.T) {
+	if err := manager.reserve("r1"); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		manager.mu.Lock()
+		delete(manager.launching, "r1")
+		delete(manager.trades, "r2")
+		manager.mu.Unlock()
+	}()
+	if err := manager.reserve("r1"); !errors.Is(err, errTradeManaged) {
+		t.Fatalf("second reserve of a launching trade: %v", err)
+	}
+
+	manager.mu.Lock()
+	manager.trades["r2"] = &tradeInstance{ID: "r2"}
+	manager.mu.Unlock()
+	err := launchTrade(nil, nil, &tradeInstance{ID: "r2", Script: "missing.so"}, "", 1, 0)
+	if !errors.Is(err, errTradeManaged) || launchErrorCode(err) != codeFailedPrecondition {
+		t.Fatalf("launch of a running trade: %v", err)
+	}
+	if manager.trades["r2"].Script != "" {
+		t.Fatal("running instance replaced")
+	}
+}
+
+func TestComparePositions(t *testing.T) {
+	if c := comparePositions(1, 1+1e-10); c.Mismatch || c.DirectionMismatch {
+		t.Errorf("within tolerance: %+v", c)
+	}
+	if c := comparePositions(1, 0.5); !c.Mismatch || c.DirectionMismatch || c.Difference != -0.5 {
+		t.Errorf("size: %+v", c)
+	}
+	if c := comparePositions(1, -1); !c.Mismatch || !c.DirectionMismatch {
+		t.Errorf("flipped: %+v", c)
+	}
+	if c := comparePositions(0, 2); !c.DirectionMismatch {
+		t.Errorf("flat vs long: %+v", c)
+	}
+
+	r := newLiveReporter(nil, "t1", 1)
+	r.SetPosition(-2)
+	if r.Position() != -2 {
+		t.Errorf("position after SetPosition = %v", r.Position())
+	}
+}
+
+func TestRealizedPnl(t *testing.T) {
+	pnl, complete := realizedPnl(0, []trademodel.Trade{
+		{Action: trademodel.OpenLong, Amount: 1, Price: 100},
+		{Action: trademodel.OpenLong, Amount: 1, Price: 110},
+		{Action: trademodel.CloseLong, Amount: 1, Price: 120},
+		// closes the remaining long at 100 and opens 1 short
+		{Action: trademodel.OpenShort, Amount: 2, Price: 100},
+		{Action: trademodel.CloseShort, Amount: 1, Price: 90},
+	})
+	// (120-105) + (100-105) + (100-90)
+	if math.Abs(pnl-20) > 1e-9 || !complete {
+		t.Fatalf("pnl = %v, complete = %v", pnl, complete)
+	}
+
+	// closing a re-attached position with an unknown entry is not counted
+	pnl, complete = realizedPnl(1, []trademodel.Trade{
+		{Action: trademodel.CloseLong, Amount: 1, Price: 120},
+		{Action: trademodel.OpenShort, Amount: 1, Price: 100},
+		{Action: trademodel.CloseShort, Amount: 1, Price: 95},
+	})
+	if math.Abs(pnl-5) > 1e-9 || complete {
+		t.Fatalf("re-attached: pnl = %v, complete = %v", pnl, complete)
+	}
+}
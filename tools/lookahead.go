@@ -0,0 +1,65 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ztrade/ztrade/pkg/process/dbstore"
+	"github.com/ztrade/ztrade/pkg/report"
+)
+
+// lookaheadReturnGap is how much more total return (0.05 = 5 percentage
+// points) fills at the signal candle's close may earn than fills at the next
+// open before a strategy is flagged as lookahead-sensitive.
+const lookaheadReturnGap = 0.05
+
+// lookaheadSensitivity compares the same backtest filled at the close of the
+// signal candle and at the next candle's open. Signals computed from a
+// candle's close cannot trade at that close live, so a strategy whose edge
+// shrinks under next-open fills depends on lookahead.
+type lookaheadSensitivity struct {
+	CloseReturn    float64 `json:"closeReturn"`
+	NextOpenReturn float64 `json:"nextOpenReturn"`
+	ReturnGap      float64 `json:"returnGap"` // CloseReturn - NextOpenReturn
+	CloseTrades    int     `json:"closeTrades"`
+	NextOpenTrades int     `json:"nextOpenTrades"`
+	Flagged        bool    `json:"flagged"`
+	Message        string  `json:"message"`
+}
+
+// newLookaheadSensitivity compares the reports of a close-fill and a
+// next-open-fill run.
+func newLookaheadSensitivity(closeRes, nextOpenRes report.ReportResult) lookaheadSensitivity {
+	ls := lookaheadSensitivity{
+		CloseReturn:    closeRes.TotalReturn,
+		NextOpenReturn: nextOpenRes.TotalReturn,
+		ReturnGap:      closeRes.TotalReturn - nextOpenRes.TotalReturn,
+		CloseTrades:    closeRes.TotalAction,
+		NextOpenTrades: nextOpenRes.TotalAction,
+	}
+	ls.Flagged = ls.ReturnGap > lookaheadReturnGap
+	if ls.Flagged {
+		ls.Message = fmt.Sprintf("filling at the signal candle's close returns %.2f%% more than filling at the next open (threshold %.0f%%): the strategy likely trades on prices it could not have acted on; trust the nextOpen result", ls.ReturnGap*100, lookaheadReturnGap*100)
+	} else {
+		ls.Message = "the return barely depends on same-bar fills"
+	}
+	return ls
+}
+
+// checkLookahead reruns the backtest of run_backtest_managed, which ran
+// with gate and produced res, with the other fillOn and compares the two.
+// The rerun is not recorded.
+func checkLookahead(ctx context.Context, db *dbstore.DBStore, plugin, exchangeName, symbol, param string, start, end time.Time, balance float64, costs TradingCostModel, gate backtestGate, res report.ReportResult) (lookaheadSensitivity, error) {
+	other := gate
+	other.NextOpenFills = !gate.NextOpenFills
+	other.RecordPositions = false
+	run, err := executeBacktest(ctx, db, plugin, exchangeName, symbol, param, start, end, balance, costs, other)
+	if err != nil {
+		return lookaheadSensitivity{}, fmt.Errorf("fillOn=%s rerun: %w", other.fillOn(), err)
+	}
+	if gate.NextOpenFills {
+		return newLookaheadSensitivity(run.Result, res), nil
+	}
+	return newLookaheadSensitivity(res, run.Result), nil
+}
@@ -0,0 +1,21 @@
+package tools
+
+import (
+	"testing"
+
+	"github.com/ztrade/ztrade/pkg/report"
+)
+
+func TestNewLookaheadSensitivity(t *testing.T) {
+	ls := newLookaheadSensitivity(report.ReportResult{TotalReturn: 0.40, TotalAction: 120}, report.ReportResult{TotalReturn: 0.05, TotalAction: 118})
+	if !ls.Flagged || ls.ReturnGap < 0.349 || ls.ReturnGap > 0.351 || ls.CloseTrades != 120 || ls.NextOpenTrades != 118 {
+		t.Fatalf("biased strategy: %+v", ls)
+	}
+	if ls := newLookaheadSensitivity(report.ReportResult{TotalReturn: 0.21}, report.ReportResult{TotalReturn: 0.20}); ls.Flagged {
+		t.Fatalf("small gap flagged: %+v", ls)
+	}
+	// next-open fills doing better is not lookahead
+	if ls := newLookaheadSensitivity(report.ReportResult{TotalReturn: -0.10}, report.ReportResult{TotalReturn: 0.10}); ls.Flagged {
+		t.Fatalf("negative gap flagged: %+v", ls)
+	}
+}
@@ -0,0 +1,68 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	basecommon "github.com/ztrade/base/common"
+	"github.com/ztrade/ztrade-mcp/internal/strategysrc"
+)
+
+// mergeTimeframeIssues checks the Merge calls of a strategy source against
+// what the engine can merge. Backtests and live trading feed strategies 1m
+// candles, and the engine parses both bin sizes of a Merge with
+// time.ParseDuration and merges a whole number of sources per candle, so
+// any whole-minute timeframe works (3m, 7m, 2h, 24h) but the source has to
+// be 1m, day and week units (1d, 1w) are not understood and a timeframe
+// like 90s cannot be built from 1m candles. Such calls would otherwise
+// crash the backtest or merge wrong candles. Merged candles start at
+// multiples of the timeframe since the Unix epoch. Arguments bound to
+// parameters are resolved as in estimate_warmup; unresolved ones and
+// sources that do not parse are left to the build.
+func mergeTimeframeIssues(content string, params map[string]interface{}) []string {
+	wc, err := strategysrc.AnalyzeWarmup(content, params)
+	if err != nil {
+		return nil
+	}
+	var issues []string
+	for _, m := range wc.Merges {
+		if m.Source != "" && m.Source != queryBaseBinSize {
+			if d, err := time.ParseDuration(m.Source); err != nil || d != time.Minute {
+				issues = append(issues, fmt.Sprintf("Merge source %q: strategies are fed %s candles, merge from %q", m.Source, queryBaseBinSize, queryBaseBinSize))
+			}
+		}
+		if m.Period == "" {
+			continue
+		}
+		d, err := time.ParseDuration(m.Period)
+		switch {
+		case err != nil:
+			if alt, binErr := basecommon.GetBinSizeDuration(m.Period); binErr == nil && alt%time.Minute == 0 && alt > 0 {
+				issues = append(issues, fmt.Sprintf("Merge timeframe %q: the engine does not understand this unit, write it as %q", m.Period, durationTimeframe(alt)))
+			} else {
+				issues = append(issues, fmt.Sprintf("Merge timeframe %q: not a duration like 3m or 2h", m.Period))
+			}
+		case d < time.Minute || d%time.Minute != 0:
+			issues = append(issues, fmt.Sprintf("Merge timeframe %q: must be a whole multiple of %s", m.Period, queryBaseBinSize))
+		}
+	}
+	return issues
+}
+
+// checkMergeTimeframes is mergeTimeframeIssues as an error.
+func checkMergeTimeframes(content string, params map[string]interface{}) error {
+	if issues := mergeTimeframeIssues(content, params); len(issues) > 0 {
+		return fmt.Errorf("unsupported merge timeframes: %s", strings.Join(issues, "; "))
+	}
+	return nil
+}
+
+// durationTimeframe writes a whole-minute duration the way the engine
+// parses it, in hours when it is a whole number of them: 24h, 90m.
+func durationTimeframe(d time.Duration) string {
+	if d%time.Hour == 0 {
+		return fmt.Sprintf("%dh", d/time.Hour)
+	}
+	return fmt.Sprintf("%dm", d/time.Minute)
+}
@@ -0,0 +1,45 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMergeTimeframeIssues(t *testing.T) {
+	src := func(merges ...string) string {
+		return `package main
+
+func (s *Demo) Param() []Param {
+	return []Param{IntParam("n", "n", "n", 10, &s.n), StringParam("period", "period", "period", "2h", &s.period)}
+}
+
+func (s *Demo) Init(engine Engine, params ParamData) error {
+	` + strings.Join(merges, "\n\t") + `
+	return nil
+}
+`
+	}
+	if issues := mergeTimeframeIssues(src(`engine.Merge("1m", "3m", s.On3m)`, `engine.Merge("1m", "7m", s.On7m)`, `engine.Merge("1m", s.period, s.OnPeriod)`), nil); len(issues) != 0 {
+		t.Fatalf("odd whole-minute timeframes rejected: %v", issues)
+	}
+
+	issues := mergeTimeframeIssues(src(`engine.Merge("1m", "1d", s.OnDay)`, `engine.Merge("1m", "90s", s.On90s)`, `engine.Merge("5m", "15m", s.On15m)`, `engine.Merge("1m", "bogus", s.OnBogus)`), nil)
+	want := []string{`write it as "24h"`, `"90s": must be a whole multiple of 1m`, `Merge source "5m"`, `"bogus": not a duration`}
+	if len(issues) != len(want) {
+		t.Fatalf("issues %v", issues)
+	}
+	for i, w := range want {
+		if !strings.Contains(issues[i], w) {
+			t.Errorf("issue %d = %q, want %q", i, issues[i], w)
+		}
+	}
+
+	// a timeframe bound to a parameter is checked with the given value
+	if err := checkMergeTimeframes(src(`engine.Merge("1m", s.period, s.OnPeriod)`), map[string]interface{}{"period": "1w"}); err == nil || !strings.Contains(err.Error(), `"168h"`) {
+		t.Fatalf("param timeframe: %v", err)
+	}
+	// unparsable sources are left to the build
+	if issues := mergeTimeframeIssues("package main\nfunc {", nil); issues != nil {
+		t.Fatalf("parse error reported: %v", issues)
+	}
+}
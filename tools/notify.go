@@ -0,0 +1,78 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"github.com/ztrade/exchange"
+	"github.com/ztrade/ztrade/pkg/core"
+	"github.com/ztrade/ztrade/pkg/process/notify"
+)
+
+// notifyEndpoint returns scheme://host of a notify URL. Full URLs are not
+// shown because webhook paths often embed tokens (e.g. telegram bot URLs).
+func notifyEndpoint(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return redactedValue
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// sendTestNotify sends one notification through the notify section of cfg,
+// the same channel engine.SendNotify uses in live trading.
+func sendTestNotify(cfg *viper.Viper, title, content string) error {
+	n, err := notify.NewNotify(exchange.WrapViper(cfg))
+	if err != nil {
+		return err
+	}
+	err = n.SendNotify(&core.NotifyEvent{Type: "text", Title: title, Content: content})
+	if err != nil {
+		if raw := cfg.GetString("notify.url"); raw != "" {
+			return &redactedError{msg: strings.ReplaceAll(err.Error(), raw, notifyEndpoint(raw))}
+		}
+	}
+	return err
+}
+
+type redactedError struct{ msg string }
+
+func (e *redactedError) Error() string { return e.msg }
+
+func registerNotifyTest(s *server.MCPServer, cfg *viper.Viper) {
+	tool := mcp.NewTool("notify_test",
+		mcp.WithDescription("Send a test notification through the notify channel configured in ztrade (the webhook engine.SendNotify uses in live trading) and report whether it was accepted. Use it to verify alerts work before starting a live strategy."),
+		mcp.WithString("title", mcp.Description("Notification title. Default: ztrade-mcp notify test")),
+		mcp.WithString("content", mcp.Description("Notification content. Default: a short test message")),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		raw := cfg.GetString("notify.url")
+		if raw == "" {
+			return toolError(codeFailedPrecondition, "notify.url is not configured; add a notify section (url, method, body, headers) to the config"), nil
+		}
+		title := req.GetString("title", "ztrade-mcp notify test")
+		content := req.GetString("content", "If you can read this, ztrade notifications are working.")
+
+		if err := sendTestNotify(cfg, title, content); err != nil {
+			log.WithContext(ctx).WithError(err).Warn("test notification failed")
+			return toolErrorf(codeUpstream, "notification failed: %s", err.Error()), nil
+		}
+		log.WithContext(ctx).Info("test notification sent")
+
+		result := map[string]interface{}{
+			"status":   "sent",
+			"endpoint": notifyEndpoint(raw),
+			"method":   cfg.GetString("notify.method"),
+			"title":    title,
+		}
+		data, _ := json.MarshalIndent(result, "", "  ")
+		return mcp.NewToolResultText(string(data)), nil
+	})
+}
@@ -0,0 +1,44 @@
+package tools
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestSendTestNotify(t *testing.T) {
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got = string(body)
+		if strings.Contains(got, "fail") {
+			http.Error(w, "rejected", http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+
+	cfg := viper.New()
+	cfg.Set("notify.url", srv.URL+"/bot-secret-token/send")
+	cfg.Set("notify.method", "POST")
+	cfg.Set("notify.body", `{"text":"{{.Title}}: {{.Content}}"}`)
+
+	if err := sendTestNotify(cfg, "hello", "world"); err != nil {
+		t.Fatalf("sendTestNotify: %v", err)
+	}
+	if got != `{"text":"hello: world"}` {
+		t.Errorf("unexpected body %q", got)
+	}
+	if err := sendTestNotify(cfg, "hello", "fail"); err == nil || !strings.Contains(err.Error(), "rejected") {
+		t.Errorf("want rejection, got %v", err)
+	}
+
+	cfg.Set("notify.url", "http://127.0.0.1:1/bot-secret-token/send")
+	err := sendTestNotify(cfg, "hello", "world")
+	if err == nil || strings.Contains(err.Error(), "secret-token") {
+		t.Errorf("unreachable endpoint error must not leak the URL path: %v", err)
+	}
+}
@@ -0,0 +1,186 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	gobinance "github.com/adshao/go-binance/v2"
+	bfutures "github.com/adshao/go-binance/v2/futures"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/spf13/viper"
+	"github.com/ztrade/trademodel"
+)
+
+// openOrdersFetcher is implemented by exchange clients that can list the
+// resting orders of a symbol.
+type openOrdersFetcher interface {
+	GetOpenOrders(symbol string) ([]*trademodel.Order, error)
+}
+
+type openOrder struct {
+	ID     string  `json:"id"`
+	Symbol string  `json:"symbol"`
+	Side   string  `json:"side"`
+	Type   string  `json:"type"`
+	Price  float64 `json:"price"`
+	Amount float64 `json:"amount"`
+	Filled float64 `json:"filled"`
+	Status string  `json:"status"`
+	Time   string  `json:"time"`
+}
+
+// binanceHTTPClient returns an HTTP client using the configured proxy, or
+// nil to keep the library default.
+func binanceHTTPClient(proxy string) (*http.Client, error) {
+	if proxy == "" {
+		return nil, nil
+	}
+	proxyURL, err := url.Parse(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy: %w", err)
+	}
+	return &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}, nil
+}
+
+func parseFloatOrZero(s string) float64 {
+	f, _ := strconv.ParseFloat(s, 64)
+	return f
+}
+
+// fetchOpenOrders lists the open orders of symbol on the configured
+// exchange. Binance is queried directly; other exchanges must implement
+// openOrdersFetcher.
+func fetchOpenOrders(ctx context.Context, cfg *viper.Viper, exchangeName, symbol string) ([]openOrder, error) {
+	cfg = exchangeConfig(cfg)
+	prefix := "exchanges." + exchangeName
+	exchangeType := cfg.GetString(prefix + ".type")
+	if exchangeType == "" {
+		return nil, fmt.Errorf("exchange '%s' not found in config. Use list_exchanges to see configured exchanges.", exchangeName)
+	}
+	kind := cfg.GetString(prefix + ".kind")
+	key, secret := cfg.GetString(prefix+".key"), cfg.GetString(prefix+".secret")
+	isTest := cfg.GetBool(prefix + ".isTest")
+
+	if exchangeType == "binance" && (kind == "futures" || kind == "spot") {
+		if key == "" || secret == "" {
+			return nil, fmt.Errorf("exchange '%s' has no API key/secret configured", exchangeName)
+		}
+		httpClient, err := binanceHTTPClient(cfg.GetString("proxy"))
+		if err != nil {
+			return nil, err
+		}
+		if kind == "futures" {
+			return fetchBinanceFuturesOpenOrders(ctx, httpClient, key, secret, isTest, symbol)
+		}
+		return fetchBinanceSpotOpenOrders(ctx, httpClient, key, secret, isTest, symbol)
+	}
+
+	ex, err := newExchangeClient(cfg, exchangeName)
+	if err != nil {
+		return nil, err
+	}
+	fetcher, ok := ex.(openOrdersFetcher)
+	if !ok {
+		return nil, fmt.Errorf("listing open orders is not supported for exchange type %s kind %s (supported: binance futures/spot)", exchangeType, kind)
+	}
+	orders, err := fetcher.GetOpenOrders(symbol)
+	if err != nil {
+		return nil, fmt.Errorf("fetch open orders failed: %w", err)
+	}
+	ret := make([]openOrder, 0, len(orders))
+	for _, o := range orders {
+		ret = append(ret, openOrder{
+			ID: o.OrderID, Symbol: o.Symbol, Side: o.Side,
+			Price: o.Price, Amount: o.Amount, Filled: o.Filled, Status: o.Status,
+			Time: o.Time.Format("2006-01-02 15:04:05"),
+		})
+	}
+	return ret, nil
+}
+
+func fetchBinanceFuturesOpenOrders(ctx context.Context, httpClient *http.Client, key, secret string, isTest bool, symbol string) ([]openOrder, error) {
+	client := bfutures.NewClient(key, secret)
+	if isTest {
+		client.BaseURL = bfutures.BaseApiTestnetUrl
+	}
+	if httpClient != nil {
+		client.HTTPClient = httpClient
+	}
+	orders, err := client.NewListOpenOrdersService().Symbol(symbol).Do(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("fetch open orders failed: %w", err)
+	}
+	ret := make([]openOrder, 0, len(orders))
+	for _, o := range orders {
+		ret = append(ret, openOrder{
+			ID: strconv.FormatInt(o.OrderID, 10), Symbol: o.Symbol, Side: string(o.Side), Type: string(o.Type),
+			Price: parseFloatOrZero(o.Price), Amount: parseFloatOrZero(o.OrigQuantity), Filled: parseFloatOrZero(o.ExecutedQuantity),
+			Status: string(o.Status), Time: time.UnixMilli(o.Time).Format("2006-01-02 15:04:05"),
+		})
+	}
+	return ret, nil
+}
+
+func fetchBinanceSpotOpenOrders(ctx context.Context, httpClient *http.Client, key, secret string, isTest bool, symbol string) ([]openOrder, error) {
+	client := gobinance.NewClient(key, secret)
+	if isTest {
+		client.BaseURL = gobinance.BaseAPITestnetURL
+	}
+	if httpClient != nil {
+		client.HTTPClient = httpClient
+	}
+	orders, err := client.NewListOpenOrdersService().Symbol(symbol).Do(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("fetch open orders failed: %w", err)
+	}
+	ret := make([]openOrder, 0, len(orders))
+	for _, o := range orders {
+		ret = append(ret, openOrder{
+			ID: strconv.FormatInt(o.OrderID, 10), Symbol: o.Symbol, Side: string(o.Side), Type: string(o.Type),
+			Price: parseFloatOrZero(o.Price), Amount: parseFloatOrZero(o.OrigQuantity), Filled: parseFloatOrZero(o.ExecutedQuantity),
+			Status: string(o.Status), Time: time.UnixMilli(o.Time).Format("2006-01-02 15:04:05"),
+		})
+	}
+	return ret, nil
+}
+
+func registerGetOpenOrders(s *server.MCPServer, cfg *viper.Viper) {
+	tool := mcp.NewTool("get_open_orders",
+		mcp.WithDescription("List the open (resting) orders of a symbol on an exchange account, e.g. to see what a live strategy has placed on the book. Requires API credentials in the exchange config."),
+		mcp.WithString("exchange", mcp.Required(), mcp.Description("Exchange config name (e.g., binance)")),
+		mcp.WithString("symbol", mcp.Required(), mcp.Description("Trading pair (e.g., BTCUSDT)")),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		exchangeName := req.GetString("exchange", "")
+		symbol := req.GetString("symbol", "")
+		if exchangeName == "" || symbol == "" {
+			return toolError(codeInvalidArgument, "exchange and symbol are required"), nil
+		}
+
+		orders, err := fetchOpenOrders(ctx, cfg, exchangeName, symbol)
+		if err != nil {
+			return toolError(codeUpstream, err.Error()), nil
+		}
+		sort.Slice(orders, func(i, j int) bool { return orders[i].Time < orders[j].Time })
+
+		result := map[string]interface{}{
+			"exchange": exchangeName,
+			"symbol":   symbol,
+			"count":    len(orders),
+			"orders":   orders,
+		}
+		if len(orders) == 0 {
+			result["message"] = fmt.Sprintf("no open orders on %s for %s", exchangeName, symbol)
+		}
+		data, _ := json.MarshalIndent(result, "", "  ")
+		return mcp.NewToolResultText(string(data)), nil
+	})
+}
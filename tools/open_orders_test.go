@@ -0,0 +1,22 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestFetchOpenOrdersConfigErrors(t *testing.T) {
+	cfg := viper.New()
+	cfg.Set("exchanges", map[string]interface{}{
+		"bn": map[string]interface{}{"type": "binance", "kind": "futures"},
+	})
+	if _, err := fetchOpenOrders(context.Background(), cfg, "missing", "BTCUSDT"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+	if _, err := fetchOpenOrders(context.Background(), cfg, "bn", "BTCUSDT"); err == nil || !strings.Contains(err.Error(), "API key") {
+		t.Fatalf("expected missing credential error, got %v", err)
+	}
+}
@@ -0,0 +1,172 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+const (
+	// defaultMaxResultBytes is the size limit of a tool result's text when
+	// mcp.maxResultBytes is not set.
+	defaultMaxResultBytes = 512 << 10
+	// resultNoticeReserve is kept free of the limit for the truncation notice.
+	resultNoticeReserve = 1024
+)
+
+// maxResultBytes returns the configured size limit of tool results; 0 or
+// less disables it.
+func maxResultBytes(cfg *viper.Viper) int {
+	if cfg != nil && cfg.IsSet("mcp.maxResultBytes") {
+		return cfg.GetInt("mcp.maxResultBytes")
+	}
+	return defaultMaxResultBytes
+}
+
+// ResultSizeMiddleware keeps the text of successful tool results within
+// mcp.maxResultBytes, so a large query cannot silently overflow the client's
+// context. See limitResultText.
+func ResultSizeMiddleware(cfg *viper.Viper) server.ToolHandlerMiddleware {
+	limit := maxResultBytes(cfg)
+	return func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+		return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			result, err := next(ctx, req)
+			if err != nil || result == nil || result.IsError || limit <= 0 {
+				return result, err
+			}
+			for i, c := range result.Content {
+				tc, ok := c.(mcp.TextContent)
+				if !ok || len(tc.Text) <= limit {
+					continue
+				}
+				size := len(tc.Text)
+				tc.Text = limitResultText(tc.Text, limit)
+				result.Content[i] = tc
+				log.WithContext(ctx).Infof("tool result truncated from %d to %d bytes", size, len(tc.Text))
+			}
+			return result, nil
+		}
+	}
+}
+
+// truncatedField reports an array cut by limitResultText.
+type truncatedField struct {
+	Field    string `json:"field"`
+	Total    int    `json:"total"`
+	Returned int    `json:"returned"`
+}
+
+// limitResultText shrinks a tool result text to about limit bytes. A JSON
+// object has its largest arrays cut, largest first, and gains truncated,
+// truncatedFields and truncationNotice; a top-level JSON array becomes the
+// items of such an object. Anything else, or JSON that is still too large
+// without arrays, is cut as text with the notice appended.
+func limitResultText(text string, limit int) string {
+	notice := fmt.Sprintf("The result exceeded %d bytes and was truncated. Narrow the query (shorter time range, smaller limit, offset or filters) to get the rest.", limit)
+	budget := max(limit-resultNoticeReserve, limit/2)
+
+	dec := json.NewDecoder(strings.NewReader(text))
+	dec.UseNumber()
+	var root interface{}
+	if dec.Decode(&root) != nil {
+		return truncateResultString(text, budget, notice)
+	}
+	obj, ok := root.(map[string]interface{})
+	if !ok {
+		arr, isArr := root.([]interface{})
+		if !isArr {
+			return truncateResultString(text, budget, notice)
+		}
+		obj = map[string]interface{}{"items": arr}
+	}
+
+	encode := func() []byte {
+		data, _ := json.MarshalIndent(obj, "", "  ")
+		return data
+	}
+	var fields []truncatedField
+	for len(encode()) > budget {
+		a := largestArray(obj)
+		if a == nil {
+			break
+		}
+		// the whole array does not fit; find the longest prefix that does
+		lo, hi := 0, len(a.items)-1
+		for lo < hi {
+			mid := (lo + hi + 1) / 2
+			a.set(a.items[:mid])
+			if len(encode()) <= budget {
+				lo = mid
+			} else {
+				hi = mid - 1
+			}
+		}
+		a.set(a.items[:lo])
+		fields = append(fields, truncatedField{Field: a.path, Total: len(a.items), Returned: lo})
+	}
+	data := encode()
+	if len(fields) == 0 || len(data) > budget {
+		return truncateResultString(string(data), budget, notice)
+	}
+	obj["truncated"] = true
+	obj["truncatedFields"] = fields
+	obj["truncationNotice"] = notice
+	return string(encode())
+}
+
+// truncateResultString cuts s to maxBytes and appends the notice.
+func truncateResultString(s string, maxBytes int, notice string) string {
+	return truncateStringUTF8(s, maxBytes) + "\n...\n[truncated: " + notice + "]"
+}
+
+// jsonArray is an array inside a decoded JSON value, with the function that
+// replaces it in its parent.
+type jsonArray struct {
+	path  string
+	items []interface{}
+	size  int
+	set   func([]interface{})
+}
+
+// largestArray returns the non-empty array of v with the largest encoding,
+// or nil when v contains none.
+func largestArray(v interface{}) *jsonArray {
+	var best *jsonArray
+	var walk func(v interface{}, path string, set func([]interface{}))
+	walk = func(v interface{}, path string, set func([]interface{})) {
+		switch x := v.(type) {
+		case map[string]interface{}:
+			keys := make([]string, 0, len(x))
+			for k := range x {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				p := k
+				if path != "" {
+					p = path + "." + k
+				}
+				walk(x[k], p, func(items []interface{}) { x[k] = items })
+			}
+		case []interface{}:
+			if len(x) > 0 && set != nil {
+				data, _ := json.Marshal(x)
+				if best == nil || len(data) > best.size {
+					best = &jsonArray{path: path, items: x, size: len(data), set: set}
+				}
+			}
+			for i, e := range x {
+				walk(e, fmt.Sprintf("%s[%d]", path, i), func(items []interface{}) { x[i] = items })
+			}
+		}
+	}
+	walk(v, "", nil)
+	return best
+}
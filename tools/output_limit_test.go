@@ -0,0 +1,88 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/spf13/viper"
+)
+
+func TestLimitResultText(t *testing.T) {
+	candles := make([]map[string]interface{}, 2000)
+	for i := range candles {
+		candles[i] = map[string]interface{}{"start": 1704067200 + i*60, "close": 42000.5}
+	}
+	data, _ := json.MarshalIndent(map[string]interface{}{
+		"symbol":  "BTCUSDT",
+		"count":   len(candles),
+		"candles": candles,
+		"gaps":    []string{"a", "b"},
+		"big":     int64(9007199254740993),
+	}, "", "  ")
+
+	const limit = 20000
+	out := limitResultText(string(data), limit)
+	if len(out) > limit {
+		t.Fatalf("%d bytes, limit %d", len(out), limit)
+	}
+	var got struct {
+		Symbol          string
+		Candles         []map[string]interface{}
+		Gaps            []string
+		Big             json.Number
+		Truncated       bool
+		TruncatedFields []truncatedField
+	}
+	if err := json.Unmarshal([]byte(out), &got); err != nil {
+		t.Fatalf("truncated result is not JSON: %v", err)
+	}
+	if !got.Truncated || len(got.TruncatedFields) != 1 || got.TruncatedFields[0].Field != "candles" || got.TruncatedFields[0].Total != 2000 {
+		t.Fatalf("notice: %+v", got.TruncatedFields)
+	}
+	if n := len(got.Candles); n == 0 || n != got.TruncatedFields[0].Returned {
+		t.Fatalf("%d candles kept, reported %d", n, got.TruncatedFields[0].Returned)
+	}
+	// smaller arrays and other fields are kept as they were
+	if got.Symbol != "BTCUSDT" || len(got.Gaps) != 2 || got.Big != "9007199254740993" {
+		t.Fatalf("other fields changed: %+v", got)
+	}
+
+	// top-level arrays become items
+	arr, _ := json.Marshal(candles)
+	out = limitResultText(string(arr), limit)
+	var wrapped struct {
+		Items     []interface{}
+		Truncated bool
+	}
+	if err := json.Unmarshal([]byte(out), &wrapped); err != nil || !wrapped.Truncated || len(wrapped.Items) == 0 {
+		t.Fatalf("top-level array: %v, %d items", err, len(wrapped.Items))
+	}
+
+	// text is cut with a notice
+	out = limitResultText(strings.Repeat("日志", limit), limit)
+	if len(out) > limit || !strings.Contains(out, "[truncated:") {
+		t.Fatalf("text: %d bytes", len(out))
+	}
+}
+
+func TestResultSizeMiddleware(t *testing.T) {
+	big := strings.Repeat("x", 5000)
+	handler := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText(big), nil
+	}
+	cfg := viper.New()
+	cfg.Set("mcp.maxResultBytes", 4096)
+	res, _ := ResultSizeMiddleware(cfg)(handler)(context.Background(), mcp.CallToolRequest{})
+	if text := res.Content[0].(mcp.TextContent).Text; len(text) > 4096 {
+		t.Fatalf("%d bytes through the middleware", len(text))
+	}
+
+	cfg.Set("mcp.maxResultBytes", 0)
+	res, _ = ResultSizeMiddleware(cfg)(handler)(context.Background(), mcp.CallToolRequest{})
+	if text := res.Content[0].(mcp.TextContent).Text; text != big {
+		t.Fatal("limit 0 does not disable truncation")
+	}
+}
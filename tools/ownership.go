@@ -0,0 +1,73 @@
+package tools
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ztrade/ztrade-mcp/auth"
+	"github.com/ztrade/ztrade-mcp/store"
+)
+
+// checkScriptAccess returns an error matching store.ErrNotOwner when the
+// caller may not see script or, with write, modify it.
+func checkScriptAccess(ctx context.Context, script *store.Script, write bool) error {
+	viewer := auth.ScopedUser(ctx)
+	if viewer == "" {
+		return nil
+	}
+	return store.CheckScriptAccess(script, viewer, write)
+}
+
+// accessibleScript gets a script by ID and checks the caller's access to it.
+func accessibleScript(ctx context.Context, st *store.Store, id int64, write bool) (*store.Script, error) {
+	script, err := st.GetScript(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkScriptAccess(ctx, script, write); err != nil {
+		return nil, err
+	}
+	return script, nil
+}
+
+// accessibleScriptByName gets a script by name and checks the caller's
+// access to it.
+func accessibleScriptByName(ctx context.Context, st *store.Store, name string, write bool) (*store.Script, error) {
+	script, err := st.GetScriptByName(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkScriptAccess(ctx, script, write); err != nil {
+		return nil, err
+	}
+	return script, nil
+}
+
+// accessibleScriptRef resolves the script argument of run_backtest,
+// build_strategy and start_trade, a strategy ID or name, and checks the
+// caller's access to it.
+func accessibleScriptRef(ctx context.Context, st *store.Store, ref string, write bool) (*store.Script, error) {
+	if isLikelyID(ref) {
+		id, _ := parseID(ref)
+		return accessibleScript(ctx, st, id, write)
+	}
+	return accessibleScriptByName(ctx, st, ref, write)
+}
+
+// accessibleRecord gets a backtest record and checks the caller's access to
+// the strategy it belongs to, which it returns too. Without a scoped caller
+// a record whose strategy no longer exists is returned with a nil script.
+func accessibleRecord(ctx context.Context, st *store.Store, id int64) (*store.BacktestRecord, *store.Script, error) {
+	record, err := st.GetBacktestRecord(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	script, err := accessibleScript(ctx, st, record.ScriptID, false)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) && auth.ScopedUser(ctx) == "" {
+			return record, nil, nil
+		}
+		return nil, nil, err
+	}
+	return record, script, nil
+}
@@ -0,0 +1,50 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/ztrade/ztrade-mcp/auth"
+	"github.com/ztrade/ztrade-mcp/store"
+)
+
+func TestAccessibleScriptRefAndRecord(t *testing.T) {
+	cfg := viper.New()
+	cfg.Set("db.type", "sqlite")
+	cfg.Set("db.uri", filepath.Join(t.TempDir(), "mcp.db"))
+	st, err := store.NewStore(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+
+	theirs := &store.Script{Name: "Theirs", Content: "package main", Owner: "bob"}
+	if err := st.CreateScript(theirs); err != nil {
+		t.Fatal(err)
+	}
+	record := &store.BacktestRecord{ScriptID: theirs.ID}
+	if err := st.SaveBacktestRecord(record); err != nil {
+		t.Fatal(err)
+	}
+
+	alice := auth.ContextWithUser(context.Background(), &auth.User{Name: "alice", Role: "trader"})
+	for _, ref := range []string{"Theirs", "1"} {
+		if _, err := accessibleScriptRef(alice, st, ref, false); !errors.Is(err, store.ErrNotOwner) {
+			t.Fatalf("alice reads %s: %v", ref, err)
+		}
+	}
+	if _, _, err := accessibleRecord(alice, st, record.ID); !errors.Is(err, store.ErrNotOwner) {
+		t.Fatalf("alice reads record: %v", err)
+	}
+
+	bob := auth.ContextWithUser(context.Background(), &auth.User{Name: "bob", Role: "trader"})
+	if sc, err := accessibleScriptRef(bob, st, "Theirs", false); err != nil || sc.ID != theirs.ID {
+		t.Fatalf("bob reads by name: %v", err)
+	}
+	if r, sc, err := accessibleRecord(bob, st, record.ID); err != nil || r.ID != record.ID || sc.ID != theirs.ID {
+		t.Fatalf("bob reads record: %v", err)
+	}
+}
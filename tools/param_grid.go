@@ -0,0 +1,152 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/ztrade/ztrade-mcp/internal/strategysrc"
+	"github.com/ztrade/ztrade-mcp/store"
+)
+
+type rejectedParamValue struct {
+	Value  interface{} `json:"value"`
+	Reason string      `json:"reason"`
+}
+
+// paramGridCheck is the outcome of the values proposed for one parameter.
+type paramGridCheck struct {
+	Name     string               `json:"name"`
+	Type     string               `json:"type,omitempty"`
+	Accepted []interface{}        `json:"accepted"`
+	Rejected []rejectedParamValue `json:"rejected,omitempty"`
+}
+
+// checkParamValue returns why v is not a valid value of spec, or "".
+// Numbers arrive from JSON as float64.
+func checkParamValue(spec strategysrc.ParamSpec, v interface{}) string {
+	n, isNum := v.(float64)
+	switch spec.Type {
+	case "int":
+		if !isNum || n != math.Trunc(n) {
+			return "IntParam values must be whole numbers"
+		}
+	case "float":
+		if !isNum {
+			return "FloatParam values must be numbers"
+		}
+	case "string":
+		if _, ok := v.(string); !ok {
+			return "StringParam values must be strings"
+		}
+	case "bool":
+		if _, ok := v.(bool); !ok {
+			return "BoolParam values must be true or false"
+		}
+	}
+	if len(spec.Enums) > 0 {
+		found := false
+		for _, e := range spec.Enums {
+			if fmt.Sprint(e) == fmt.Sprint(v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Sprintf("must be one of %v", spec.Enums)
+		}
+	}
+	if spec.Positive && isNum && n <= 0 {
+		return "must be positive: it is passed to AddIndicator as a period or length"
+	}
+	return ""
+}
+
+// checkParamGrid checks each proposed value against the Param() entry of
+// its parameter. Parameters the strategy does not declare have all values
+// rejected. Checks are ordered by parameter name.
+func checkParamGrid(specs []strategysrc.ParamSpec, grid map[string][]interface{}) []paramGridCheck {
+	byName := make(map[string]strategysrc.ParamSpec, len(specs))
+	known := make([]string, 0, len(specs))
+	for _, spec := range specs {
+		byName[spec.Name] = spec
+		known = append(known, spec.Name)
+	}
+	names := make([]string, 0, len(grid))
+	for name := range grid {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	checks := make([]paramGridCheck, 0, len(names))
+	for _, name := range names {
+		check := paramGridCheck{Name: name, Accepted: []interface{}{}}
+		spec, ok := byName[name]
+		for _, v := range grid[name] {
+			reason := ""
+			if !ok {
+				reason = fmt.Sprintf("not a parameter of the strategy (declared: %s)", strings.Join(known, ", "))
+			} else {
+				reason = checkParamValue(spec, v)
+			}
+			if reason != "" {
+				check.Rejected = append(check.Rejected, rejectedParamValue{Value: v, Reason: reason})
+				continue
+			}
+			check.Accepted = append(check.Accepted, v)
+		}
+		check.Type = spec.Type
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+func registerValidateParamGrid(s *server.MCPServer, st *store.Store) {
+	tool := mcp.NewTool("validate_param_grid",
+		mcp.WithDescription("Check a parameter grid against the strategy's Param() declarations before running it: every value must match the parameter's type (IntParam values whole numbers, StringParam values strings, ...), be one of its enum values when it has any, and be positive when the parameter is an indicator period or length. Returns the accepted values per parameter, the rejected ones with the reason, and the number of combinations left. Run it before a parameter sweep; invalid values waste runs or crash the strategy."),
+		mcp.WithString("content", mcp.Description("Strategy source code to check against")),
+		mcp.WithNumber("id", mcp.Description("ID of a managed strategy. Used if content is not provided.")),
+		mcp.WithString("name", mcp.Description("Name of a managed strategy. Used if content and id are not provided.")),
+		mcp.WithString("grid", mcp.Required(), mcp.Description(`Grid as a JSON object of parameter name to the list of values to try, e.g. {"fast":[5,9,12],"period":["15m","1h"]}`)),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		var grid map[string][]interface{}
+		if err := json.Unmarshal([]byte(req.GetString("grid", "")), &grid); err != nil {
+			return toolErrorf(codeInvalidArgument, "invalid grid JSON, want an object of parameter name to value list: %s", err.Error()), nil
+		}
+		if len(grid) == 0 {
+			return toolError(codeInvalidArgument, "grid must name at least one parameter"), nil
+		}
+		content, errResult := strategySourceFromRequest(ctx, req, st)
+		if errResult != nil {
+			return errResult, nil
+		}
+		specs, err := strategysrc.Params(content)
+		if err != nil {
+			return toolErrorf(codeInvalidArgument, "failed to parse strategy source: %s", err.Error()), nil
+		}
+
+		checks := checkParamGrid(specs, grid)
+		valid, combinations := true, 1
+		for _, c := range checks {
+			if len(c.Rejected) > 0 {
+				valid = false
+			}
+			combinations *= len(c.Accepted)
+		}
+		result := map[string]interface{}{
+			"valid":        valid,
+			"params":       checks,
+			"combinations": combinations,
+			"declared":     specs,
+		}
+		data, _ := json.MarshalIndent(result, "", "  ")
+		return mcp.NewToolResultText(string(data)), nil
+	})
+}
@@ -0,0 +1,43 @@
+package tools
+
+import (
+	"testing"
+
+	"github.com/ztrade/ztrade-mcp/internal/strategysrc"
+)
+
+func TestCheckParamGrid(t *testing.T) {
+	specs := []strategysrc.ParamSpec{
+		{Name: "fast", Type: "int", Field: "fast", Default: int64(12), Positive: true},
+		{Name: "period", Type: "string", Default: "1h", Enums: []interface{}{"15m", "1h"}},
+		{Name: "ratio", Type: "float", Default: 0.5},
+	}
+	grid := map[string][]interface{}{
+		"fast":   {5.0, 9.5, 0.0, "12", 20.0},
+		"period": {"15m", "4h"},
+		"ratio":  {-0.5, 1.0},
+		"slow":   {26.0},
+	}
+	checks := checkParamGrid(specs, grid)
+	if len(checks) != 4 || checks[0].Name != "fast" || checks[3].Name != "slow" {
+		t.Fatalf("checks = %+v", checks)
+	}
+	fast := checks[0]
+	if len(fast.Accepted) != 2 || fast.Accepted[0] != 5.0 || fast.Accepted[1] != 20.0 || len(fast.Rejected) != 3 {
+		t.Fatalf("fast = %+v", fast)
+	}
+	for i, want := range []string{"IntParam values must be whole numbers", "must be positive: it is passed to AddIndicator as a period or length", "IntParam values must be whole numbers"} {
+		if fast.Rejected[i].Reason != want {
+			t.Errorf("fast rejected %v: %q, want %q", fast.Rejected[i].Value, fast.Rejected[i].Reason, want)
+		}
+	}
+	if period := checks[1]; len(period.Accepted) != 1 || len(period.Rejected) != 1 || period.Rejected[0].Value != "4h" {
+		t.Fatalf("period = %+v", period)
+	}
+	if ratio := checks[2]; len(ratio.Accepted) != 2 || len(ratio.Rejected) != 0 {
+		t.Fatalf("negative float without an indicator use rejected: %+v", ratio)
+	}
+	if slow := checks[3]; len(slow.Accepted) != 0 || len(slow.Rejected) != 1 || slow.Type != "" {
+		t.Fatalf("undeclared parameter = %+v", slow)
+	}
+}
@@ -0,0 +1,245 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/ztrade/ztrade-mcp/internal/stats"
+	"github.com/ztrade/ztrade-mcp/store"
+)
+
+// minSensitivitySamples is the minimum number of runs with a numeric value
+// for a parameter before its effect is estimated.
+const minSensitivitySamples = 3
+
+// backtestMetricGetters maps metric names accepted by analysis tools to
+// BacktestRecord fields.
+var backtestMetricGetters = map[string]func(r *store.BacktestRecord) float64{
+	"overallScore":  func(r *store.BacktestRecord) float64 { return r.OverallScore },
+	"sharpeRatio":   func(r *store.BacktestRecord) float64 { return r.SharpeRatio },
+	"sortinoRatio":  func(r *store.BacktestRecord) float64 { return r.SortinoRatio },
+	"calmarRatio":   func(r *store.BacktestRecord) float64 { return r.CalmarRatio },
+	"totalReturn":   func(r *store.BacktestRecord) float64 { return r.TotalReturn },
+	"annualReturn":  func(r *store.BacktestRecord) float64 { return r.AnnualReturn },
+	"winRate":       func(r *store.BacktestRecord) float64 { return r.WinRate },
+	"profitFactor":  func(r *store.BacktestRecord) float64 { return r.ProfitFactor },
+	"maxDrawdown":   func(r *store.BacktestRecord) float64 { return r.MaxDrawdown },
+	"profitPercent": func(r *store.BacktestRecord) float64 { return r.ProfitPercent },
+	"ulcerIndex":    func(r *store.BacktestRecord) float64 { return r.UlcerIndex },
+	"martinRatio":   func(r *store.BacktestRecord) float64 { return r.MartinRatio },
+}
+
+func backtestMetricNames() []string {
+	names := make([]string, 0, len(backtestMetricGetters))
+	for k := range backtestMetricGetters {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+type paramEffect struct {
+	Param       string  `json:"param"`
+	Samples     int     `json:"samples"`
+	Distinct    int     `json:"distinctValues"`
+	Correlation float64 `json:"correlation"`
+	Slope       float64 `json:"slope"`
+	Direction   string  `json:"direction"` // positive, negative, none
+	Strength    string  `json:"strength"`  // strong, moderate, weak
+	BestValue   float64 `json:"bestValue"`
+	BestMetric  float64 `json:"bestMetric"`
+	Min         float64 `json:"min"`
+	Max         float64 `json:"max"`
+	Note        string  `json:"note,omitempty"`
+}
+
+// numericParamValue converts a JSON param value to a number. Booleans map
+// to 0/1 and numeric strings are parsed.
+func numericParamValue(v interface{}) (float64, bool) {
+	switch t := v.(type) {
+	case float64:
+		return t, true
+	case bool:
+		if t {
+			return 1, true
+		}
+		return 0, true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(t), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// analyzeParamSensitivity correlates every numeric parameter found in the
+// records' param JSON with the metric of backtestMetricGetters named
+// metricName. Results are sorted by the absolute correlation, strongest
+// first. It returns the number of records whose param could not be parsed.
+func analyzeParamSensitivity(records []store.BacktestRecord, metricName string) ([]paramEffect, int) {
+	metric := backtestMetricGetters[metricName]
+	lowerIsBetter := lowerIsBetterMetrics[metricName]
+	xs := map[string][]float64{}
+	ys := map[string][]float64{}
+	skipped := 0
+	for i := range records {
+		r := &records[i]
+		if strings.TrimSpace(r.Param) == "" {
+			skipped++
+			continue
+		}
+		var params map[string]interface{}
+		if err := json.Unmarshal([]byte(r.Param), &params); err != nil {
+			skipped++
+			continue
+		}
+		y := metric(r)
+		if math.IsNaN(y) || math.IsInf(y, 0) {
+			continue
+		}
+		for k, v := range params {
+			if x, ok := numericParamValue(v); ok {
+				xs[k] = append(xs[k], x)
+				ys[k] = append(ys[k], y)
+			}
+		}
+	}
+
+	effects := make([]paramEffect, 0, len(xs))
+	for name, x := range xs {
+		y := ys[name]
+		e := paramEffect{Param: name, Samples: len(x), Direction: "none", Strength: "weak"}
+
+		// best value: the one with the best mean metric, the lowest for
+		// lowerIsBetterMetrics
+		sums := map[float64]float64{}
+		counts := map[float64]int{}
+		e.Min, e.Max = x[0], x[0]
+		for i := range x {
+			sums[x[i]] += y[i]
+			counts[x[i]]++
+			e.Min = math.Min(e.Min, x[i])
+			e.Max = math.Max(e.Max, x[i])
+		}
+		e.Distinct = len(counts)
+		first := true
+		for v, sum := range sums {
+			mean := sum / float64(counts[v])
+			better := mean > e.BestMetric
+			if lowerIsBetter {
+				better = mean < e.BestMetric
+			}
+			if first || better || (mean == e.BestMetric && v < e.BestValue) {
+				e.BestValue, e.BestMetric = v, mean
+				first = false
+			}
+		}
+
+		switch {
+		case e.Samples < minSensitivitySamples:
+			e.Note = fmt.Sprintf("need at least %d runs", minSensitivitySamples)
+		case e.Distinct < 2:
+			e.Note = "parameter was never varied"
+		default:
+			r, ok := stats.Pearson(x, y)
+			if !ok {
+				e.Note = "metric did not vary"
+				break
+			}
+			e.Correlation = r
+			e.Slope = r * stats.StdDev(y) / stats.StdDev(x)
+			abs := math.Abs(r)
+			switch {
+			case abs >= 0.5:
+				e.Strength = "strong"
+			case abs >= 0.3:
+				e.Strength = "moderate"
+			}
+			if abs >= 0.1 {
+				if r > 0 {
+					e.Direction = "positive"
+				} else {
+					e.Direction = "negative"
+				}
+			}
+		}
+		effects = append(effects, e)
+	}
+	sort.Slice(effects, func(i, j int) bool {
+		ai, aj := math.Abs(effects[i].Correlation), math.Abs(effects[j].Correlation)
+		if ai != aj {
+			return ai > aj
+		}
+		return effects[i].Param < effects[j].Param
+	})
+	return effects, skipped
+}
+
+func registerParamSensitivity(s *server.MCPServer, st *store.Store) {
+	tool := mcp.NewTool("param_sensitivity",
+		mcp.WithDescription("Analyze which strategy parameters drive performance using existing backtest history (no new runs). "+
+			"Parses each record's param JSON and correlates every numeric parameter with the chosen metric, returning direction, strength, slope and the best observed value per parameter. "+
+			"Correlation is not causation: parameters varied together in the same runs can mask each other."),
+		mcp.WithNumber("strategyId", mcp.Required(), mcp.Description("Strategy ID")),
+		mcp.WithString("metric", mcp.Description("Metric to explain: "+strings.Join(backtestMetricNames(), ", ")+". Default: overallScore")),
+		mcp.WithNumber("version", mcp.Description("Only use backtests of this strategy version. Default: all versions")),
+		mcp.WithString("symbol", mcp.Description("Only use backtests on this symbol. Default: all symbols")),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if st == nil {
+			return toolError(codeUnavailable, "script store not initialized (check database config)"), nil
+		}
+
+		strategyID := int64(req.GetFloat("strategyId", 0))
+		metricName := req.GetString("metric", "overallScore")
+		version := int(req.GetFloat("version", 0))
+		symbol := req.GetString("symbol", "")
+
+		if _, ok := backtestMetricGetters[metricName]; !ok {
+			return toolErrorf(codeInvalidArgument, "unknown metric %q, supported: %s", metricName, strings.Join(backtestMetricNames(), ", ")), nil
+		}
+
+		if _, err := accessibleScript(ctx, st, strategyID, false); err != nil {
+			return toolErrorf(storeErrorCode(err), "failed to get script: %s", err.Error()), nil
+		}
+
+		records, err := st.ListRunRecords(strategyID)
+		if err != nil {
+			return toolErrorf(codeInternal, "failed to list records: %s", err.Error()), nil
+		}
+		filtered := records[:0]
+		for _, r := range records {
+			if version > 0 && r.ScriptVersion != version {
+				continue
+			}
+			if symbol != "" && r.Symbol != symbol {
+				continue
+			}
+			filtered = append(filtered, r)
+		}
+		if len(filtered) == 0 {
+			return toolErrorf(codeNotFound, "no backtest records found for strategy %d with the given filters", strategyID), nil
+		}
+
+		effects, skipped := analyzeParamSensitivity(filtered, metricName)
+		result := map[string]interface{}{
+			"strategyId":    strategyID,
+			"metric":        metricName,
+			"totalRuns":     len(filtered),
+			"runsWithParam": len(filtered) - skipped,
+			"parameters":    effects,
+		}
+		if len(effects) == 0 {
+			result["note"] = "no numeric parameters found in backtest params; run backtests with a param JSON to enable this analysis"
+		}
+		data, _ := json.MarshalIndent(result, "", "  ")
+		return mcp.NewToolResultText(string(data)), nil
+	})
+}
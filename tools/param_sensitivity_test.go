@@ -0,0 +1,59 @@
+package tools
+
+import (
+	"testing"
+
+	"github.com/ztrade/ztrade-mcp/store"
+)
+
+func TestAnalyzeParamSensitivity(t *testing.T) {
+	records := []store.BacktestRecord{
+		{Param: `{"fast": 5, "slow": 30, "useStop": true, "name": "a"}`, OverallScore: 10},
+		{Param: `{"fast": 10, "slow": 30, "useStop": false}`, OverallScore: 20},
+		{Param: `{"fast": "15", "slow": 30}`, OverallScore: 30},
+		{Param: `{"fast": 20, "slow": 30}`, OverallScore: 40},
+		{Param: `not json`, OverallScore: 99},
+		{Param: ``, OverallScore: 99},
+	}
+	effects, skipped := analyzeParamSensitivity(records, "overallScore")
+	if skipped != 2 {
+		t.Fatalf("expected 2 skipped records, got %d", skipped)
+	}
+	byName := map[string]paramEffect{}
+	for _, e := range effects {
+		byName[e.Param] = e
+	}
+	if _, ok := byName["name"]; ok {
+		t.Fatalf("non-numeric param should be ignored")
+	}
+	if effects[0].Param != "fast" {
+		t.Fatalf("expected fast to rank first, got %s", effects[0].Param)
+	}
+	fast := byName["fast"]
+	if fast.Samples != 4 || fast.Direction != "positive" || fast.Strength != "strong" {
+		t.Fatalf("unexpected fast effect: %+v", fast)
+	}
+	if fast.Correlation < 0.999 || fast.Slope < 1.999 || fast.Slope > 2.001 {
+		t.Fatalf("unexpected fast correlation/slope: %+v", fast)
+	}
+	if fast.BestValue != 20 || fast.BestMetric != 40 {
+		t.Fatalf("unexpected best value: %+v", fast)
+	}
+	if slow := byName["slow"]; slow.Direction != "none" || slow.Note == "" {
+		t.Fatalf("constant param should not get a direction: %+v", slow)
+	}
+	if stop := byName["useStop"]; stop.Samples != 2 || stop.Note == "" {
+		t.Fatalf("param with too few samples should be noted: %+v", stop)
+	}
+
+	// the best drawdown is the smallest
+	for i := range records {
+		records[i].MaxDrawdown = records[i].OverallScore / 100
+	}
+	effects, _ = analyzeParamSensitivity(records, "maxDrawdown")
+	for _, e := range effects {
+		if e.Param == "fast" && (e.BestValue != 5 || e.BestMetric != 0.1) {
+			t.Fatalf("unexpected best drawdown value: %+v", e)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/ztrade/ztrade-mcp/store"
+)
+
+// maxParamSetNameLen is the length of the default_param_name column.
+const maxParamSetNameLen = 100
+
+// normalizeParamSet checks that param is a JSON object, the form strategies
+// parse their parameters from, and returns it compacted.
+func normalizeParamSet(param string) (string, error) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(param), &fields); err != nil || fields == nil {
+		return "", fmt.Errorf("param must be a JSON object")
+	}
+	data, _ := json.Marshal(fields)
+	return string(data), nil
+}
+
+// defaultParam returns param, or the strategy's stored default param set
+// when param is empty, and the name of the set used ("" for an explicit
+// param or no default).
+func defaultParam(script *store.Script, param string) (string, string) {
+	if param != "" || script == nil || script.DefaultParam == "" {
+		return param, ""
+	}
+	name := script.DefaultParamName
+	if name == "" {
+		name = "default"
+	}
+	return script.DefaultParam, name
+}
+
+func registerApplyParamSet(s *server.MCPServer, st *store.Store) {
+	tool := mcp.NewTool("apply_param_set",
+		mcp.WithDescription("Store a parameter set on a strategy as its default: run_backtest_managed and start_trade use it whenever they are called without param. Pass the param JSON directly, or recordId to take the param of one of the strategy's backtest records, e.g. the best run of an optimization. clear removes the default. Only the owner (or an admin) may change it, and a stable strategy has to leave stable first."),
+		mcp.WithNumber("strategyId", mcp.Required(), mcp.Description("Strategy ID")),
+		mcp.WithString("param", mcp.Description("Parameter set as JSON object")),
+		mcp.WithNumber("recordId", mcp.Description("Backtest record of this strategy whose param to store, instead of param")),
+		mcp.WithString("name", mcp.Description(fmt.Sprintf("Label of the parameter set, up to %d characters, e.g. the runLabel of the optimization. Default: 'default', or 'record <id>' with recordId", maxParamSetNameLen))),
+		mcp.WithBoolean("clear", mcp.Description("Remove the stored default instead. Default: false")),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if st == nil {
+			return toolError(codeUnavailable, "script store not initialized (check database config)"), nil
+		}
+
+		id := int64(req.GetFloat("strategyId", 0))
+		param := strings.TrimSpace(req.GetString("param", ""))
+		recordID := int64(req.GetFloat("recordId", 0))
+		name := strings.TrimSpace(req.GetString("name", ""))
+		clear := req.GetBool("clear", false)
+		switch {
+		case clear && (param != "" || recordID > 0):
+			return toolError(codeInvalidArgument, "clear cannot be combined with param or recordId"), nil
+		case !clear && (param == "") == (recordID <= 0):
+			return toolError(codeInvalidArgument, "exactly one of param or recordId is required"), nil
+		case len(name) > maxParamSetNameLen:
+			return toolErrorf(codeInvalidArgument, "name is longer than %d characters", maxParamSetNameLen), nil
+		}
+
+		script, err := accessibleScript(ctx, st, id, true)
+		if err != nil {
+			return toolErrorf(storeErrorCode(err), "failed to get script: %s", err.Error()), nil
+		}
+
+		if recordID > 0 {
+			record, err := st.GetBacktestRecord(recordID)
+			if err != nil {
+				return toolErrorf(storeErrorCode(err), "failed to get backtest record: %s", err.Error()), nil
+			}
+			if record.ScriptID != id {
+				return toolErrorf(codeInvalidArgument, "backtest record %d belongs to strategy %d, not %d", recordID, record.ScriptID, id), nil
+			}
+			if record.Param == "" {
+				return toolErrorf(codeFailedPrecondition, "backtest record %d ran without param; there is no parameter set to store", recordID), nil
+			}
+			param = record.Param
+			if name == "" {
+				name = fmt.Sprintf("record %d", recordID)
+			}
+		}
+		if !clear {
+			if param, err = normalizeParamSet(param); err != nil {
+				return toolError(codeInvalidArgument, err.Error()), nil
+			}
+			if name == "" {
+				name = "default"
+			}
+		} else {
+			param, name = "", ""
+		}
+
+		fields := map[string]interface{}{"default_param": param, "default_param_name": name}
+		if msg := stableLockViolation(script, fields); msg != "" {
+			return toolError(codeFailedPrecondition, msg), nil
+		}
+		if err := st.UpdateScriptMeta(id, fields); err != nil {
+			return toolErrorf(codeInternal, "failed to store param set: %s", err.Error()), nil
+		}
+
+		result := map[string]interface{}{
+			"strategyId": id,
+		}
+		if clear {
+			result["status"] = "cleared"
+			result["previous"] = script.DefaultParam
+		} else {
+			result["status"] = "applied"
+			result["name"] = name
+			result["param"] = param
+			if recordID > 0 {
+				result["recordId"] = recordID
+			}
+		}
+		data, _ := json.MarshalIndent(result, "", "  ")
+		return mcp.NewToolResultText(string(data)), nil
+	})
+}
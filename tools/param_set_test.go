@@ -0,0 +1,62 @@
+package tools
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/ztrade/ztrade-mcp/store"
+)
+
+func TestNormalizeParamSet(t *testing.T) {
+	got, err := normalizeParamSet(`{ "fast": 12, "slow": 26 }`)
+	if err != nil || got != `{"fast":12,"slow":26}` {
+		t.Fatalf("normalize = %q, %v", got, err)
+	}
+	for _, bad := range []string{"", "null", "[1,2]", "12", "{fast: 1}"} {
+		if _, err := normalizeParamSet(bad); err == nil {
+			t.Errorf("%q accepted", bad)
+		}
+	}
+}
+
+func TestDefaultParam(t *testing.T) {
+	cfg := viper.New()
+	cfg.Set("db.type", "sqlite")
+	cfg.Set("db.uri", filepath.Join(t.TempDir(), "mcp.db"))
+	st, err := store.NewStore(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+
+	script := &store.Script{Name: "Demo", Content: "package main"}
+	if err := st.CreateScript(script); err != nil {
+		t.Fatal(err)
+	}
+	if param, set := defaultParam(script, ""); param != "" || set != "" {
+		t.Fatalf("without default: %q, %q", param, set)
+	}
+
+	if err := st.UpdateScriptMeta(script.ID, map[string]interface{}{"default_param": `{"fast":12}`, "default_param_name": "sweep-1"}); err != nil {
+		t.Fatal(err)
+	}
+	script, err = st.GetScript(script.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if param, set := defaultParam(script, ""); param != `{"fast":12}` || set != "sweep-1" {
+		t.Errorf("default: %q, %q", param, set)
+	}
+	if param, set := defaultParam(script, `{"fast":5}`); param != `{"fast":5}` || set != "" {
+		t.Errorf("explicit param: %q, %q", param, set)
+	}
+
+	// clearing stores empty strings
+	if err := st.UpdateScriptMeta(script.ID, map[string]interface{}{"default_param": "", "default_param_name": ""}); err != nil {
+		t.Fatal(err)
+	}
+	if script, _ = st.GetScript(script.ID); script.DefaultParam != "" || script.DefaultParamName != "" {
+		t.Errorf("not cleared: %+v", script)
+	}
+}
@@ -0,0 +1,42 @@
+package tools
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"runtime"
+	"runtime/debug"
+)
+
+// pluginSumSuffix names the file next to a built plugin holding the key of
+// the source it was built from.
+const pluginSumSuffix = ".sum"
+
+// pluginKey identifies what a plugin was built from: the strategy source,
+// the extra build flags and the Go and module versions of this server,
+// since a plugin only loads into the binary it was built against. Plugin
+// paths are keyed by strategy name and version only, so the key is what
+// tells a reusable plugin from one built from different content.
+func pluginKey(content []byte, flags pluginBuildFlags) string {
+	h := sha256.New()
+	h.Write(content)
+	h.Write([]byte{0})
+	h.Write([]byte(flags.goflags()))
+	h.Write([]byte{0})
+	h.Write([]byte(runtime.Version()))
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, dep := range info.Deps {
+			h.Write([]byte(dep.Path + "@" + dep.Version + "\n"))
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// pluginUpToDate reports whether output exists and was built from key.
+func pluginUpToDate(output, key string) bool {
+	if _, err := os.Stat(output); err != nil {
+		return false
+	}
+	sum, err := os.ReadFile(output + pluginSumSuffix)
+	return err == nil && string(sum) == key
+}
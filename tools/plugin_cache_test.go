@@ -0,0 +1,54 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPluginKey(t *testing.T) {
+	a := pluginKey([]byte("package strategy // v1"), pluginBuildFlags{})
+	if a != pluginKey([]byte("package strategy // v1"), pluginBuildFlags{}) {
+		t.Fatal("key not deterministic")
+	}
+	if a == pluginKey([]byte("package strategy // v2"), pluginBuildFlags{}) {
+		t.Error("content change kept the key")
+	}
+	if a == pluginKey([]byte("package strategy // v1"), pluginBuildFlags{Tags: []string{"prod"}}) {
+		t.Error("flag change kept the key")
+	}
+}
+
+func TestBuildPluginReusesUpToDatePlugin(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "demo_v1.go")
+	so := filepath.Join(dir, "demo_v1.so")
+	content := []byte("package strategy\n")
+	if err := os.WriteFile(src, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	key := pluginKey(content, pluginBuildFlags{})
+	if pluginUpToDate(so, key) {
+		t.Fatal("missing plugin reported up to date")
+	}
+	if err := os.WriteFile(so, []byte("plugin"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if pluginUpToDate(so, key) {
+		t.Fatal("plugin without a recorded key reported up to date")
+	}
+	if err := os.WriteFile(so+pluginSumSuffix, []byte(key), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if !pluginUpToDate(so, key) {
+		t.Fatal("plugin with matching key not reused")
+	}
+	// same name and version, different content: must not be reused
+	if pluginUpToDate(so, pluginKey([]byte("package strategy\n// edited\n"), pluginBuildFlags{})) {
+		t.Error("stale plugin reported up to date")
+	}
+	// an up-to-date plugin is returned without invoking the go toolchain
+	if err := buildPlugin(src, so, pluginBuildFlags{}); err != nil {
+		t.Errorf("buildPlugin: %v", err)
+	}
+}
@@ -0,0 +1,45 @@
+package tools
+
+import (
+	"debug/buildinfo"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// errPluginMismatch marks a strategy plugin that cannot load into this
+// server because it was built with another Go toolchain or other versions
+// of the packages the two share.
+var errPluginMismatch = errors.New("strategy plugin does not match this server's build")
+
+// pluginRebuildHint tells how to get a plugin that loads.
+func pluginRebuildHint() string {
+	return fmt.Sprintf("Go plugins only load into a server built with the same Go version (this server runs %s) and the same versions of every shared package. Rebuild the strategy from its .go source with build_strategy, or pass the .go file or a managed strategy so it is compiled by this server", runtime.Version())
+}
+
+// checkPluginToolchain compares the Go version recorded in a prebuilt .so
+// with the running server's, so a mismatch is reported before the engine
+// fails to load it with an opaque error. Files without build info are left
+// to the loader.
+func checkPluginToolchain(path string) error {
+	if strings.ToLower(filepath.Ext(path)) != ".so" {
+		return nil
+	}
+	info, err := buildinfo.ReadFile(path)
+	if err != nil || info.GoVersion == runtime.Version() {
+		return nil
+	}
+	return fmt.Errorf("%w: %s was built with %s. %s", errPluginMismatch, path, info.GoVersion, pluginRebuildHint())
+}
+
+// explainPluginError returns err with the rebuild hint when it is the
+// loader's version mismatch error, as returned by plugin.Open for a plugin
+// built against different package versions, and err unchanged otherwise.
+func explainPluginError(err error) error {
+	if err == nil || errors.Is(err, errPluginMismatch) || !strings.Contains(err.Error(), "built with a different version of package") {
+		return err
+	}
+	return fmt.Errorf("%w: %s. %s", errPluginMismatch, err.Error(), pluginRebuildHint())
+}
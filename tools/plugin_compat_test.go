@@ -0,0 +1,58 @@
+package tools
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExplainPluginError(t *testing.T) {
+	loadErr := errors.New(`plugin.Open("/tmp/a.so"): plugin was built with a different version of package internal/goarch`)
+	err := explainPluginError(loadErr)
+	if !errors.Is(err, errPluginMismatch) || !strings.Contains(err.Error(), "internal/goarch") || !strings.Contains(err.Error(), "build_strategy") {
+		t.Fatalf("mismatch: %v", err)
+	}
+	if explainPluginError(err) != err {
+		t.Error("explained twice")
+	}
+	if backtestErrorCode(err) != codeBuildFailed || launchErrorCode(err) != codeBuildFailed {
+		t.Error("mismatch not reported as build_failed")
+	}
+
+	other := errors.New("plugin.Open: no such file")
+	if explainPluginError(other) != other || explainPluginError(nil) != nil {
+		t.Error("unrelated error changed")
+	}
+}
+
+func TestCheckPluginToolchain(t *testing.T) {
+	// not a Go binary: left to the loader
+	junk := filepath.Join(t.TempDir(), "junk.so")
+	if err := os.WriteFile(junk, []byte("not a plugin"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkPluginToolchain(junk); err != nil {
+		t.Errorf("junk: %v", err)
+	}
+	// the test binary is built by the running toolchain
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	self := filepath.Join(t.TempDir(), "self.so")
+	data, err := os.ReadFile(exe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(self, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkPluginToolchain(self); err != nil {
+		t.Errorf("same toolchain: %v", err)
+	}
+	if err := checkPluginToolchain("strategy.go"); err != nil {
+		t.Errorf("source file: %v", err)
+	}
+}
@@ -0,0 +1,383 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"github.com/ztrade/ztrade-mcp/internal/stats"
+	"github.com/ztrade/ztrade-mcp/store"
+	"github.com/ztrade/ztrade/pkg/process/dbstore"
+)
+
+const (
+	maxPortfolioLegs = 10
+	// portfolioCurvePoints bounds the portfolio equity curve in the result.
+	portfolioCurvePoints = 200
+)
+
+// parsePortfolioLegs parses the legs argument and normalizes the weights so
+// they sum to 1.
+func parsePortfolioLegs(s string) ([]store.PortfolioLeg, error) {
+	var legs []store.PortfolioLeg
+	if err := json.Unmarshal([]byte(s), &legs); err != nil {
+		return nil, fmt.Errorf("legs must be a JSON array of {strategyId, symbol, weight}: %s", err.Error())
+	}
+	if len(legs) == 0 || len(legs) > maxPortfolioLegs {
+		return nil, fmt.Errorf("legs must have 1 to %d entries", maxPortfolioLegs)
+	}
+	var total float64
+	for i, leg := range legs {
+		if leg.StrategyID <= 0 || strings.TrimSpace(leg.Symbol) == "" {
+			return nil, fmt.Errorf("leg %d: strategyId and symbol are required", i)
+		}
+		if leg.Weight <= 0 || math.IsInf(leg.Weight, 0) || math.IsNaN(leg.Weight) {
+			return nil, fmt.Errorf("leg %d: weight must be positive", i)
+		}
+		total += leg.Weight
+	}
+	for i := range legs {
+		legs[i].Weight /= total
+		legs[i].RecordID = 0
+	}
+	return legs, nil
+}
+
+// combineEquityCurves sums the equity curves of the legs on the union of
+// their timestamps. A leg's equity changes only at its trades, so between
+// its points it holds its last value; every curve starts at the backtest
+// start with the leg's allocation.
+func combineEquityCurves(curves [][]store.EquityPoint) []store.EquityPoint {
+	var times []time.Time
+	seen := map[int64]bool{}
+	for _, c := range curves {
+		for _, p := range c {
+			if key := p.Time.UnixNano(); !seen[key] {
+				seen[key] = true
+				times = append(times, p.Time)
+			}
+		}
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+
+	combined := make([]store.EquityPoint, len(times))
+	next := make([]int, len(curves))
+	last := make([]float64, len(curves))
+	for i, t := range times {
+		var sum float64
+		for k, c := range curves {
+			for next[k] < len(c) && !c[next[k]].Time.After(t) {
+				last[k] = c[next[k]].Equity
+				next[k]++
+			}
+			sum += last[k]
+		}
+		combined[i] = store.EquityPoint{Time: t, Equity: sum}
+	}
+	return combined
+}
+
+// maxDrawdown is the largest fall from a running peak as a fraction of the
+// peak, as in ztrade's report.
+func maxDrawdown(equity []float64) float64 {
+	var dd, peak float64
+	for i, e := range equity {
+		if i == 0 || e > peak {
+			peak = e
+		}
+		if peak > 0 {
+			dd = math.Max(dd, (peak-e)/peak)
+		}
+	}
+	return dd
+}
+
+// sampleEquity returns the equity of a step curve at each of times.
+func sampleEquity(points []store.EquityPoint, times []time.Time) []float64 {
+	ret := make([]float64, len(times))
+	j := 0
+	var last float64
+	if len(points) > 0 {
+		last = points[0].Equity
+	}
+	for i, t := range times {
+		for j < len(points) && !points[j].Time.After(t) {
+			last = points[j].Equity
+			j++
+		}
+		ret[i] = last
+	}
+	return ret
+}
+
+// legCorrelations returns the pairwise Pearson correlation of the legs'
+// returns, sampled daily, or hourly for ranges under 60 days so there are
+// enough samples. Pairs with too few samples or a flat curve are nil.
+func legCorrelations(curves [][]store.EquityPoint, start, end time.Time) [][]*float64 {
+	step := 24 * time.Hour
+	if end.Sub(start) < 60*step {
+		step = time.Hour
+	}
+	var times []time.Time
+	for t := start; !t.After(end); t = t.Add(step) {
+		times = append(times, t)
+	}
+	returns := make([][]float64, len(curves))
+	for i, c := range curves {
+		returns[i] = stats.Returns(sampleEquity(c, times))
+	}
+
+	n := len(curves)
+	matrix := make([][]*float64, n)
+	for i := range matrix {
+		matrix[i] = make([]*float64, n)
+		one := 1.0
+		matrix[i][i] = &one
+	}
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			if len(returns[i]) < correlationMinReturns {
+				continue
+			}
+			if r, ok := stats.Pearson(returns[i], returns[j]); ok {
+				matrix[i][j], matrix[j][i] = &r, &r
+			}
+		}
+	}
+	return matrix
+}
+
+// portfolioMetrics fills the portfolio-level metrics of record from the
+// combined equity curve.
+func portfolioMetrics(record *store.PortfolioRecord, equity []store.EquityPoint) {
+	values := make([]float64, len(equity))
+	for i, p := range equity {
+		values[i] = p.Equity
+	}
+	if len(values) == 0 || values[0] <= 0 {
+		return
+	}
+	record.EndBalance = values[len(values)-1]
+	record.TotalReturn = record.EndBalance/values[0] - 1
+	years := record.EndTime.Sub(record.StartTime).Hours() / (24 * 365.25)
+	if years <= 0 {
+		years = 1
+	}
+	if record.TotalReturn > -1 {
+		record.AnnualReturn = math.Pow(1+record.TotalReturn, 1/years) - 1
+	} else {
+		record.AnnualReturn = -1
+	}
+	record.MaxDrawdown = maxDrawdown(values)
+	record.SharpeRatio, record.SortinoRatio = excessReturnRatios(equity, record.StartTime, record.EndTime, reportRiskFreeRate)
+	record.UlcerIndex = stats.UlcerIndex(values)
+}
+
+func registerPortfolioBacktest(s *server.MCPServer, db *dbstore.DBStore, cfg *viper.Viper, st *store.Store, tm *TaskManager) {
+	tool := mcp.NewTool("portfolio_backtest",
+		mcp.WithDescription("Backtest a basket of managed strategies sharing one capital pool. The balance is split by weight, each leg is backtested over the same range and saved as a backtest record, and the legs' equity curves are aligned on their timestamps and summed into a portfolio curve. Returns portfolio return, max drawdown, Sharpe/Sortino, the correlation of the legs' returns and the per-leg results, and saves a portfolio record. Long ranges run asynchronously like run_backtest."),
+		mcp.WithString("legs", mcp.Required(), mcp.Description(fmt.Sprintf("JSON array of legs, up to %d: [{\"strategyId\":1,\"symbol\":\"BTCUSDT\",\"weight\":0.6,\"param\":\"{...}\",\"version\":0}]. Weights are normalized to sum to 1; param and version (default latest) are optional", maxPortfolioLegs))),
+		mcp.WithString("exchange", mcp.Required(), mcp.Description("Exchange name (e.g., binance)")),
+		mcp.WithString("start", mcp.Required(), mcp.Description("Backtest start time in format '2006-01-02 15:04:05'")),
+		mcp.WithString("end", mcp.Required(), mcp.Description("Backtest end time in format '2006-01-02 15:04:05'")),
+		mcp.WithNumber("balance", mcp.Description("Total initial balance of the portfolio. Default: mcp.backtest.defaults for the exchange, else 100000")),
+		mcp.WithString("runLabel", mcp.Description(fmt.Sprintf("Label of the portfolio and its leg records, up to %d characters. Default: portfolio-<timestamp>", maxRunLabelLen))),
+	)
+	for _, opt := range costModelOptions() {
+		opt(&tool)
+	}
+	idempotencyKeyOption()(&tool)
+
+	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if db == nil {
+			return toolError(codeUnavailable, "database not initialized"), nil
+		}
+		if st == nil {
+			return toolError(codeUnavailable, "script store not initialized (check database config)"), nil
+		}
+
+		legs, err := parsePortfolioLegs(req.GetString("legs", ""))
+		if err != nil {
+			return toolError(codeInvalidArgument, err.Error()), nil
+		}
+		exchangeName := req.GetString("exchange", "")
+		startStr := req.GetString("start", "")
+		endStr := req.GetString("end", "")
+		balanceF := req.GetFloat("balance", 0)
+		runLabel := strings.TrimSpace(req.GetString("runLabel", ""))
+		if len(runLabel) > maxRunLabelLen {
+			return toolErrorf(codeInvalidArgument, "runLabel is longer than %d characters", maxRunLabelLen), nil
+		}
+		if runLabel == "" {
+			runLabel = fmt.Sprintf("portfolio-%s", time.Now().Format("20060102-150405"))
+		}
+		defaultBalance, baseCosts := backtestDefaults(cfg, exchangeName)
+		costs, err := costModelFromRequest(req, baseCosts)
+		if err != nil {
+			return toolError(codeInvalidArgument, err.Error()), nil
+		}
+
+		start, err := time.Parse("2006-01-02 15:04:05", startStr)
+		if err != nil {
+			return toolErrorf(codeInvalidArgument, "invalid start time: %s", err.Error()), nil
+		}
+		end, err := time.Parse("2006-01-02 15:04:05", endStr)
+		if err != nil {
+			return toolErrorf(codeInvalidArgument, "invalid end time: %s", err.Error()), nil
+		}
+		if !start.Before(end) {
+			return toolError(codeInvalidArgument, "start must be before end"), nil
+		}
+		if balanceF <= 0 {
+			balanceF = defaultBalance
+		}
+
+		// resolve and build every leg before running any of them
+		scripts := make([]*store.Script, len(legs))
+		plugins := make([]string, len(legs))
+		legCosts := make([]TradingCostModel, len(legs))
+		for i := range legs {
+			leg := &legs[i]
+			script, err := accessibleScript(ctx, st, leg.StrategyID, false)
+			if err != nil {
+				return toolErrorf(storeErrorCode(err), "leg %d: failed to get script: %s", i, err.Error()), nil
+			}
+			if leg.Version > 0 {
+				ver, err := st.GetVersion(leg.StrategyID, leg.Version)
+				if err != nil {
+					return toolErrorf(storeErrorCode(err), "leg %d: failed to get version: %s", i, err.Error()), nil
+				}
+				script.Content, script.Version = ver.Content, ver.Version
+			}
+			leg.Version = script.Version
+			leg.Allocation = balanceF * leg.Weight
+			legCosts[i] = costs
+			if err := loadSymbolRules(ctx, cfg, req, &legCosts[i], exchangeName, leg.Symbol); err != nil {
+				return toolErrorf(codeUpstream, "leg %d symbolRules: %s", i, err.Error()), nil
+			}
+			if plugins[i], err = buildStoredScript(script); err != nil {
+				return toolErrorf(codeBuildFailed, "leg %d: %s", i, err.Error()), nil
+			}
+			scripts[i] = script
+		}
+
+		runPortfolio := func(ctx context.Context) (map[string]interface{}, error) {
+			curves := make([][]store.EquityPoint, len(legs))
+			legResults := make([]map[string]interface{}, len(legs))
+			names := make([]string, len(legs))
+			for i := range legs {
+				leg := &legs[i]
+				gate := backtestGate{RecordPositions: true}
+				run, err := executeBacktest(ctx, db, plugins[i], exchangeName, leg.Symbol, leg.Param, start, end, leg.Allocation, legCosts[i], gate)
+				if err != nil {
+					return nil, fmt.Errorf("leg %d (%s %s): %w", i, scripts[i].Name, leg.Symbol, err)
+				}
+				res := run.Result
+				record := newBacktestRecord(leg.StrategyID, leg.Version, exchangeName, leg.Symbol, leg.Param, start, end, leg.Allocation, legCosts[i], gate, res)
+				curves[i] = buildEquityCurve(start, leg.Allocation, res.Actions)
+				record.UlcerIndex, record.MartinRatio = ulcerMetrics(curves[i], res.AnnualReturn)
+				record.RiskFreeRate = reportRiskFreeRate
+				record.RunLabel = runLabel
+				tagMarketRegime(ctx, db, record)
+				newRunManifest(scripts[i].Content, pluginBuildFlags{}, legCosts[i]).stamp(ctx, db, record)
+				saveBacktestRun(ctx, st, record, run, curves[i])
+				leg.RecordID = record.ID
+
+				names[i] = fmt.Sprintf("%s/%s", scripts[i].Name, leg.Symbol)
+				legResults[i] = map[string]interface{}{
+					"name": names[i], "strategyId": leg.StrategyID, "version": leg.Version, "symbol": leg.Symbol,
+					"weight": leg.Weight, "allocation": leg.Allocation, "recordId": record.ID,
+					"endBalance": res.EndBalance, "totalReturn": res.TotalReturn, "maxDrawdown": res.MaxDrawdown,
+					"sharpeRatio": res.SharpeRatio, "totalActions": res.TotalAction, "dataCoverage": run.Coverage,
+				}
+			}
+
+			equity := combineEquityCurves(curves)
+			portfolio := &store.PortfolioRecord{
+				Exchange: exchangeName, StartTime: start, EndTime: end,
+				InitBalance: balanceF, Legs: legs, RunLabel: runLabel,
+			}
+			portfolioMetrics(portfolio, equity)
+			if err := st.SavePortfolioRecord(portfolio); err != nil {
+				log.WithContext(ctx).Warnf("portfolio backtest completed but failed to save record: %s", err.Error())
+			}
+
+			result := map[string]interface{}{
+				"portfolioId":  portfolio.ID,
+				"runLabel":     runLabel,
+				"exchange":     exchangeName,
+				"start":        startStr,
+				"end":          endStr,
+				"startBalance": balanceF,
+				"endBalance":   portfolio.EndBalance,
+				"totalReturn":  portfolio.TotalReturn,
+				"annualReturn": portfolio.AnnualReturn,
+				"maxDrawdown":  portfolio.MaxDrawdown,
+				"sharpeRatio":  portfolio.SharpeRatio,
+				"sortinoRatio": portfolio.SortinoRatio,
+				"ulcerIndex":   portfolio.UlcerIndex,
+				"legs":         legResults,
+				"correlation":  map[string]interface{}{"legs": names, "matrix": legCorrelations(curves, start, end)},
+				"equityPoints": len(equity),
+				"equityCurve":  downsampleEquity(equity, portfolioCurvePoints),
+			}
+			return result, nil
+		}
+
+		if ShouldRunAsync(start, end) {
+			taskID, created := tm.CreateTaskOnce("portfolio_backtest", map[string]string{
+				"legs":     fmt.Sprintf("%d", len(legs)),
+				"exchange": exchangeName,
+				"start":    startStr,
+				"end":      endStr,
+				"runLabel": runLabel,
+			}, taskDedupeKey(ctx, "portfolio_backtest", req))
+			if !created {
+				return dedupedTaskResult(taskID), nil
+			}
+
+			go func() {
+				tm.StartTask(taskID)
+				doneCh := tm.ProgressEstimator(taskID, "portfolio_backtest", start, end)
+
+				// the task outlives the request that started it
+				result, err := runPortfolio(context.WithoutCancel(ctx))
+				close(doneCh)
+
+				if err != nil {
+					log.WithContext(ctx).Errorf("async portfolio backtest task %s failed: %s", taskID, err.Error())
+					tm.FailTask(taskID, err.Error())
+					return
+				}
+
+				data, _ := json.MarshalIndent(result, "", "  ")
+				tm.CompleteTask(taskID, string(data))
+				log.WithContext(ctx).Infof("async portfolio backtest task %s completed", taskID)
+			}()
+
+			asyncResult := map[string]interface{}{
+				"async":    true,
+				"taskId":   taskID,
+				"runLabel": runLabel,
+				"message":  fmt.Sprintf("Backtest time range exceeds %d days, running asynchronously. Use get_task_status with taskId '%s' to check progress, or get_task_result to retrieve the final result.", AsyncThresholdDays, taskID),
+			}
+			data, _ := json.MarshalIndent(asyncResult, "", "  ")
+			return mcp.NewToolResultText(string(data)), nil
+		}
+
+		result, err := runPortfolio(ctx)
+		if err != nil {
+			return toolError(backtestErrorCode(err), err.Error()), nil
+		}
+		data, _ := json.MarshalIndent(result, "", "  ")
+		return mcp.NewToolResultText(string(data)), nil
+	})
+}
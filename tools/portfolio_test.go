@@ -0,0 +1,79 @@
+package tools
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/ztrade/ztrade-mcp/store"
+)
+
+func TestParsePortfolioLegs(t *testing.T) {
+	legs, err := parsePortfolioLegs(`[{"strategyId":1,"symbol":"BTCUSDT","weight":3},{"strategyId":2,"symbol":"ETHUSDT","weight":1,"param":"{}","recordId":9}]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(legs) != 2 || legs[0].Weight != 0.75 || legs[1].Weight != 0.25 || legs[1].Param != "{}" || legs[1].RecordID != 0 {
+		t.Fatalf("legs: %+v", legs)
+	}
+	for _, bad := range []string{
+		`[]`,
+		`{"strategyId":1}`,
+		`[{"strategyId":1,"symbol":"BTCUSDT","weight":0}]`,
+		`[{"strategyId":1,"weight":1}]`,
+	} {
+		if _, err := parsePortfolioLegs(bad); err == nil {
+			t.Errorf("accepted %s", bad)
+		}
+	}
+}
+
+func TestCombineEquityCurves(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(h int) time.Time { return t0.Add(time.Duration(h) * time.Hour) }
+	a := []store.EquityPoint{{Time: at(0), Equity: 600}, {Time: at(2), Equity: 660}, {Time: at(5), Equity: 540}}
+	b := []store.EquityPoint{{Time: at(0), Equity: 400}, {Time: at(3), Equity: 380}}
+
+	got := combineEquityCurves([][]store.EquityPoint{a, b})
+	want := []float64{1000, 1060, 1040, 920}
+	if len(got) != len(want) {
+		t.Fatalf("points: %+v", got)
+	}
+	for i, w := range want {
+		if got[i].Equity != w {
+			t.Errorf("point %d = %+v, want %v", i, got[i], w)
+		}
+	}
+	if !got[2].Time.Equal(at(3)) {
+		t.Errorf("timestamps not merged in order: %+v", got)
+	}
+
+	rec := &store.PortfolioRecord{StartTime: at(0), EndTime: at(6)}
+	portfolioMetrics(rec, got)
+	if rec.EndBalance != 920 || math.Abs(rec.TotalReturn+0.08) > 1e-12 {
+		t.Errorf("return: %+v", rec)
+	}
+	if math.Abs(rec.MaxDrawdown-(1060-920)/1060.0) > 1e-12 {
+		t.Errorf("max drawdown = %v", rec.MaxDrawdown)
+	}
+
+	if s := sampleEquity(a, []time.Time{at(1), at(2), at(9)}); s[0] != 600 || s[1] != 660 || s[2] != 540 {
+		t.Errorf("sampled: %v", s)
+	}
+}
+
+func TestLegCorrelations(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var up, same, opposite []store.EquityPoint
+	for h := 0; h <= 48; h++ {
+		ts := t0.Add(time.Duration(h) * time.Hour)
+		x := 100 + 10*math.Sin(float64(h)/3)
+		up = append(up, store.EquityPoint{Time: ts, Equity: x})
+		same = append(same, store.EquityPoint{Time: ts, Equity: 2 * x})
+		opposite = append(opposite, store.EquityPoint{Time: ts, Equity: 300 - x})
+	}
+	m := legCorrelations([][]store.EquityPoint{up, same, opposite}, t0, t0.Add(48*time.Hour))
+	if m[0][1] == nil || math.Abs(*m[0][1]-1) > 1e-6 || m[0][2] == nil || *m[0][2] > -0.99 {
+		t.Fatalf("matrix: %v %v", m[0][1], m[0][2])
+	}
+}
@@ -0,0 +1,83 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	bfutures "github.com/adshao/go-binance/v2/futures"
+	"github.com/spf13/viper"
+)
+
+// positionFetcher is implemented by exchange clients that can report the
+// net position of a symbol.
+type positionFetcher interface {
+	GetPosition(symbol string) (float64, error)
+}
+
+// fetchExchangePosition returns the net position of symbol on the configured
+// exchange account, long positive. Binance futures is queried directly; other
+// exchanges must implement positionFetcher.
+func fetchExchangePosition(ctx context.Context, cfg *viper.Viper, exchangeName, symbol string) (float64, error) {
+	cfg = exchangeConfig(cfg)
+	prefix := "exchanges." + exchangeName
+	exchangeType := cfg.GetString(prefix + ".type")
+	if exchangeType == "" {
+		return 0, fmt.Errorf("exchange '%s' not found in config. Use list_exchanges to see configured exchanges.", exchangeName)
+	}
+	kind := cfg.GetString(prefix + ".kind")
+
+	if exchangeType == "binance" && kind == "futures" {
+		key, secret := cfg.GetString(prefix+".key"), cfg.GetString(prefix+".secret")
+		if key == "" || secret == "" {
+			return 0, fmt.Errorf("exchange '%s' has no API key/secret configured", exchangeName)
+		}
+		httpClient, err := binanceHTTPClient(cfg.GetString("proxy"))
+		if err != nil {
+			return 0, err
+		}
+		return fetchBinanceFuturesPosition(ctx, httpClient, key, secret, cfg.GetBool(prefix+".isTest"), symbol)
+	}
+
+	ex, err := newExchangeClient(cfg, exchangeName)
+	if err != nil {
+		return 0, err
+	}
+	fetcher, ok := ex.(positionFetcher)
+	if !ok {
+		return 0, fmt.Errorf("querying positions is not supported for exchange type %s kind %s (supported: binance futures)", exchangeType, kind)
+	}
+	pos, err := fetcher.GetPosition(symbol)
+	if err != nil {
+		return 0, fmt.Errorf("fetch position failed: %w", err)
+	}
+	return pos, nil
+}
+
+func fetchBinanceFuturesPosition(ctx context.Context, httpClient *http.Client, key, secret string, isTest bool, symbol string) (float64, error) {
+	client := bfutures.NewClient(key, secret)
+	if isTest {
+		client.BaseURL = bfutures.BaseApiTestnetUrl
+	}
+	if httpClient != nil {
+		client.HTTPClient = httpClient
+	}
+	return fetchBinanceFuturesPositionWith(ctx, client, symbol)
+}
+
+// fetchBinanceFuturesPositionWith returns the net position of symbol over
+// both position sides.
+func fetchBinanceFuturesPositionWith(ctx context.Context, client *bfutures.Client, symbol string) (float64, error) {
+	risks, err := client.NewGetPositionRiskService().Symbol(symbol).Do(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("fetch position failed: %w", err)
+	}
+	// in hedge mode there is one entry per side; positionAmt is signed
+	var pos float64
+	for _, r := range risks {
+		if r.Symbol == symbol {
+			pos += parseFloatOrZero(r.PositionAmt)
+		}
+	}
+	return pos, nil
+}
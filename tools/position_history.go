@@ -0,0 +1,53 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/ztrade/ztrade-mcp/store"
+)
+
+func registerGetPositionHistory(s *server.MCPServer, st *store.Store) {
+	tool := mcp.NewTool("get_position_history",
+		mcp.WithDescription("Get the stored position history of a backtest record saved by run_backtest_managed: the signed position (negative for short) and average entry price after every change, in time order. Also reports the largest absolute position held."),
+		mcp.WithNumber("recordId", mcp.Required(), mcp.Description("Backtest record ID")),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if st == nil {
+			return toolError(codeUnavailable, "script store not initialized (check database config)"), nil
+		}
+
+		recordID := int64(req.GetFloat("recordId", 0))
+		if _, _, err := accessibleRecord(ctx, st, recordID); err != nil {
+			return toolErrorf(storeErrorCode(err), "failed to get record: %s", err.Error()), nil
+		}
+		points, err := st.ListBacktestPositions(recordID)
+		if err != nil {
+			return toolErrorf(storeErrorCode(err), "failed to get position history: %s", err.Error()), nil
+		}
+
+		type positionEntry struct {
+			Time     string  `json:"time"`
+			Position float64 `json:"position"`
+			AvgPrice float64 `json:"avgPrice"`
+		}
+		entries := make([]positionEntry, 0, len(points))
+		var maxAbs float64
+		for _, p := range points {
+			entries = append(entries, positionEntry{Time: p.Time.Format("2006-01-02 15:04:05"), Position: p.Position, AvgPrice: p.AvgPrice})
+			maxAbs = math.Max(maxAbs, math.Abs(p.Position))
+		}
+		result := map[string]interface{}{
+			"recordId":    recordID,
+			"count":       len(entries),
+			"maxPosition": maxAbs,
+			"points":      entries,
+		}
+		data, _ := json.MarshalIndent(result, "", "  ")
+		return mcp.NewToolResultText(string(data)), nil
+	})
+}
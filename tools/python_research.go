@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
-	"fmt"
 	"io"
 	"net/http"
 	"strings"
@@ -144,11 +143,11 @@ func registerRunPythonResearch(s *server.MCPServer, cfg *viper.Viper) {
 
 		start, err := time.Parse("2006-01-02 15:04:05", startStr)
 		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("invalid start time: %s", err.Error())), nil
+			return toolErrorf(codeInvalidArgument, "invalid start time: %s", err.Error()), nil
 		}
 		end, err := time.Parse("2006-01-02 15:04:05", endStr)
 		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("invalid end time: %s", err.Error())), nil
+			return toolErrorf(codeInvalidArgument, "invalid end time: %s", err.Error()), nil
 		}
 
 		payload := pyResearchRequest{
@@ -166,7 +165,7 @@ func registerRunPythonResearch(s *server.MCPServer, cfg *viper.Viper) {
 		httpClient := &http.Client{Timeout: clientTimeout}
 		httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(url, "/")+"/v1/research/run", bytes.NewReader(body))
 		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("failed to build request: %s", err.Error())), nil
+			return toolErrorf(codeInternal, "failed to build request: %s", err.Error()), nil
 		}
 		httpReq.Header.Set("Content-Type", "application/json")
 		if token != "" {
@@ -175,14 +174,14 @@ func registerRunPythonResearch(s *server.MCPServer, cfg *viper.Viper) {
 
 		resp, err := httpClient.Do(httpReq)
 		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("python-runner request failed: %s", err.Error())), nil
+			return toolErrorf(codeUpstream, "python-runner request failed: %s", err.Error()), nil
 		}
 		defer resp.Body.Close()
 		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<20)) // cap tool output to 4MiB
 
 		if resp.StatusCode != http.StatusOK {
-			log.WithField("status", resp.StatusCode).Warn("python-runner returned non-200")
-			return mcp.NewToolResultError(fmt.Sprintf("python-runner error (status=%d): %s", resp.StatusCode, string(respBody))), nil
+			log.WithContext(ctx).WithField("status", resp.StatusCode).Warn("python-runner returned non-200")
+			return toolErrorf(codeUpstream, "python-runner error (status=%d): %s", resp.StatusCode, string(respBody)), nil
 		}
 
 		var runResp pyResearchResponse
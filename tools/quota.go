@@ -0,0 +1,90 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/spf13/viper"
+	"github.com/ztrade/ztrade-mcp/auth"
+	"github.com/ztrade/ztrade-mcp/store"
+)
+
+// scriptQuotaFor returns the strategy quota of a role from
+// mcp.quota.<role>.maxScripts / maxVersions. A role without a section, admin
+// unless one is configured for it, is unlimited.
+func scriptQuotaFor(cfg *viper.Viper, role string) store.ScriptQuota {
+	if cfg == nil || role == "" {
+		return store.ScriptQuota{}
+	}
+	prefix := fmt.Sprintf("mcp.quota.%s.", role)
+	return store.ScriptQuota{
+		MaxScripts:  cfg.GetInt64(prefix + "maxScripts"),
+		MaxVersions: cfg.GetInt64(prefix + "maxVersions"),
+	}
+}
+
+// callerQuota returns the caller's name and quota. Without an authenticated
+// caller, as over stdio, scripts have no owner and no quota applies.
+func callerQuota(ctx context.Context, cfg *viper.Viper) (string, store.ScriptQuota) {
+	user := auth.UserFromContext(ctx)
+	if user == nil {
+		return "", store.ScriptQuota{}
+	}
+	return user.Name, scriptQuotaFor(cfg, user.Role)
+}
+
+// versionQuota returns the quota a new version of script counts against:
+// the caller's when the caller owns it, none otherwise (an admin editing
+// another user's strategy).
+func versionQuota(ctx context.Context, cfg *viper.Viper, script *store.Script) store.ScriptQuota {
+	owner, quota := callerQuota(ctx, cfg)
+	if owner == "" || script.Owner != owner {
+		return store.ScriptQuota{}
+	}
+	return quota
+}
+
+func registerGetQuota(s *server.MCPServer, cfg *viper.Viper, st *store.Store) {
+	tool := mcp.NewTool("get_quota",
+		mcp.WithDescription("Show the caller's strategy quota (mcp.quota.<role>: maximum active strategies and strategy versions, 0 = unlimited) and current usage. create_strategy and update_strategy fail with resource_exhausted once a limit is reached; deleting strategies frees it."),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if st == nil {
+			return toolError(codeUnavailable, "script store not initialized (check database config)"), nil
+		}
+		user := auth.UserFromContext(ctx)
+		if user == nil {
+			data, _ := json.MarshalIndent(map[string]interface{}{
+				"enabled": false,
+				"message": "no authenticated user; strategies have no owner and no quota applies",
+			}, "", "  ")
+			return mcp.NewToolResultText(string(data)), nil
+		}
+		owner, quota := callerQuota(ctx, cfg)
+		usage, err := st.GetScriptUsage(owner)
+		if err != nil {
+			return toolErrorf(codeInternal, "failed to count strategies: %s", err.Error()), nil
+		}
+		remaining := map[string]interface{}{}
+		if quota.MaxScripts > 0 {
+			remaining["scripts"] = max(quota.MaxScripts-usage.Scripts, 0)
+		}
+		if quota.MaxVersions > 0 {
+			remaining["versions"] = max(quota.MaxVersions-usage.Versions, 0)
+		}
+		result := map[string]interface{}{
+			"enabled":   quota.MaxScripts > 0 || quota.MaxVersions > 0,
+			"user":      owner,
+			"role":      user.Role,
+			"quota":     quota,
+			"usage":     usage,
+			"remaining": remaining,
+		}
+		data, _ := json.MarshalIndent(result, "", "  ")
+		return mcp.NewToolResultText(string(data)), nil
+	})
+}
@@ -0,0 +1,98 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"github.com/ztrade/ztrade-mcp/store"
+)
+
+// positionCheck compares the position tracked for a live trade with the one
+// reported by the exchange.
+type positionCheck struct {
+	Tracked           float64
+	Exchange          float64
+	Difference        float64 // exchange - tracked
+	Mismatch          bool
+	DirectionMismatch bool
+}
+
+// comparePositions reports a mismatch when the positions differ by more
+// than positionTolerance, and a direction mismatch when one is long and the
+// other short or flat.
+func comparePositions(tracked, exchange float64) positionCheck {
+	c := positionCheck{Tracked: tracked, Exchange: exchange, Difference: exchange - tracked}
+	c.Mismatch = math.Abs(c.Difference) > positionTolerance
+	c.DirectionMismatch = c.Mismatch && positionSign(tracked) != positionSign(exchange)
+	return c
+}
+
+func positionSign(pos float64) int {
+	switch {
+	case pos > positionTolerance:
+		return 1
+	case pos < -positionTolerance:
+		return -1
+	}
+	return 0
+}
+
+func registerReconcileTrade(s *server.MCPServer, cfg *viper.Viper, st *store.Store) {
+	tool := mcp.NewTool("reconcile_trade",
+		mcp.WithDescription("Compare the position tracked for a running live trade with the real position on the exchange and report any mismatch in size or direction. With correct=true the tracked (and persisted) position is set to the exchange position. The strategy engine itself follows the exchange's position updates and is not changed."),
+		mcp.WithString("tradeId", mcp.Required(), mcp.Description("Trade ID returned by start_trade or attach_trade")),
+		mcp.WithBoolean("correct", mcp.Description("Overwrite the tracked position with the exchange position on mismatch. Default: false")),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if !cfg.GetBool("mcp.enableLiveTrade") {
+			return toolError(codeFailedPrecondition, "live trading is disabled. Set mcp.enableLiveTrade: true in config to enable"), nil
+		}
+
+		tradeID := req.GetString("tradeId", "")
+		correct := req.GetBool("correct", false)
+		manager.mu.RLock()
+		instance, ok := manager.trades[tradeID]
+		manager.mu.RUnlock()
+		if !ok {
+			return toolErrorf(codeNotFound, "trade %s is not running in this process (use attach_trade for orphaned trades)", tradeID), nil
+		}
+		if instance.reporter == nil {
+			return toolErrorf(codeFailedPrecondition, "trade %s has no tracked position", tradeID), nil
+		}
+
+		exchangePos, err := fetchExchangePosition(ctx, cfg, instance.Exchange, instance.Symbol)
+		if err != nil {
+			return toolError(codeUpstream, err.Error()), nil
+		}
+		check := comparePositions(instance.reporter.Position(), exchangePos)
+
+		result := map[string]interface{}{
+			"tradeId":           tradeID,
+			"exchange":          instance.Exchange,
+			"symbol":            instance.Symbol,
+			"trackedPosition":   check.Tracked,
+			"exchangePosition":  check.Exchange,
+			"difference":        check.Difference,
+			"mismatch":          check.Mismatch,
+			"directionMismatch": check.DirectionMismatch,
+			"corrected":         false,
+		}
+		if check.Mismatch {
+			logger := log.WithContext(ctx).WithField("tradeId", tradeID)
+			logger.Warnf("position mismatch: tracked %v, exchange %v", check.Tracked, check.Exchange)
+			if correct {
+				instance.reporter.SetPosition(exchangePos)
+				result["corrected"] = true
+				logger.Infof("tracked position corrected to %v", exchangePos)
+			}
+		}
+		data, _ := json.MarshalIndent(result, "", "  ")
+		return mcp.NewToolResultText(string(data)), nil
+	})
+}
@@ -0,0 +1,174 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	basecommon "github.com/ztrade/base/common"
+	"github.com/ztrade/trademodel"
+	"github.com/ztrade/ztrade-mcp/internal/stats"
+	"github.com/ztrade/ztrade-mcp/store"
+	"github.com/ztrade/ztrade/pkg/process/dbstore"
+)
+
+// Market regimes recorded on backtest records.
+const (
+	regimeTrending = "trending"
+	regimeRanging  = "ranging"
+	regimeVolatile = "volatile"
+)
+
+const (
+	// regimeADXPeriod is the Wilder period of the ADX.
+	regimeADXPeriod = 14
+	// regimeTrendADX is the average ADX from which the market is trending.
+	regimeTrendADX = 25.0
+	// regimeHighVolatility is the annualized volatility of bar returns from
+	// which a market without a trend is volatile rather than ranging.
+	regimeHighVolatility = 0.8
+	// regimeMinBars is how many bars the classification wants; the bar size
+	// is the largest of regimeBarSizes that gives at least that many.
+	regimeMinBars = 60
+)
+
+// regimeBarSizes are the candidate bar sizes of the classification, largest
+// first.
+var regimeBarSizes = []string{"1d", "4h", "1h", "15m"}
+
+// marketRegime is the regime of the market over a time range and the
+// measures it was derived from.
+type marketRegime struct {
+	Regime     string  `json:"regime"`
+	ADX        float64 `json:"adx"`        // average ADX over the range
+	Volatility float64 `json:"volatility"` // annualized volatility of bar returns
+	BarSize    string  `json:"barSize"`
+}
+
+// regimeBarSize returns the largest bar size giving regimeMinBars bars
+// over [start, end), falling back to the smallest candidate.
+func regimeBarSize(start, end time.Time) (string, time.Duration) {
+	var size string
+	var dur time.Duration
+	for _, size = range regimeBarSizes {
+		dur, _ = basecommon.GetBinSizeDuration(size)
+		if end.Sub(start) >= regimeMinBars*dur {
+			break
+		}
+	}
+	return size, dur
+}
+
+// averageADX returns the mean of Wilder's average directional index over
+// candles, or false when there are too few candles for one ADX value.
+func averageADX(candles []*trademodel.Candle, period int) (float64, bool) {
+	if len(candles) < 2*period+1 {
+		return 0, false
+	}
+	var tr, plusDM, minusDM, adx float64
+	var adxs []float64
+	var dxSum float64
+	for i := 1; i < len(candles); i++ {
+		c, prev := candles[i], candles[i-1]
+		up, down := c.High-prev.High, prev.Low-c.Low
+		var pdm, mdm float64
+		if up > down && up > 0 {
+			pdm = up
+		}
+		if down > up && down > 0 {
+			mdm = down
+		}
+		trueRange := math.Max(c.High-c.Low, math.Max(math.Abs(c.High-prev.Close), math.Abs(c.Low-prev.Close)))
+		if i <= period {
+			// the first smoothed values are plain sums
+			tr, plusDM, minusDM = tr+trueRange, plusDM+pdm, minusDM+mdm
+			if i < period {
+				continue
+			}
+		} else {
+			p := float64(period)
+			tr, plusDM, minusDM = tr-tr/p+trueRange, plusDM-plusDM/p+pdm, minusDM-minusDM/p+mdm
+		}
+		var dx float64
+		if tr > 0 {
+			plusDI, minusDI := 100*plusDM/tr, 100*minusDM/tr
+			if sum := plusDI + minusDI; sum > 0 {
+				dx = 100 * math.Abs(plusDI-minusDI) / sum
+			}
+		}
+		// the first ADX averages period DX values, later ones are smoothed
+		n := i - period + 1
+		switch {
+		case n < period:
+			dxSum += dx
+		case n == period:
+			adx = (dxSum + dx) / float64(period)
+			adxs = append(adxs, adx)
+		default:
+			adx = (adx*float64(period-1) + dx) / float64(period)
+			adxs = append(adxs, adx)
+		}
+	}
+	return stats.Mean(adxs), len(adxs) > 0
+}
+
+// classifyRegime classifies candles of barDur: trending when the average
+// ADX reaches regimeTrendADX, otherwise volatile when the annualized
+// volatility of bar returns reaches regimeHighVolatility, otherwise ranging.
+// ok is false when there are too few candles.
+func classifyRegime(candles []*trademodel.Candle, barDur time.Duration) (regime marketRegime, ok bool) {
+	adx, ok := averageADX(candles, regimeADXPeriod)
+	if !ok {
+		return regime, false
+	}
+	closes := make([]float64, len(candles))
+	for i, c := range candles {
+		closes[i] = c.Close
+	}
+	barsPerYear := float64(365*24*time.Hour) / float64(barDur)
+	regime.ADX = adx
+	regime.Volatility = stats.StdDev(stats.Returns(closes)) * math.Sqrt(barsPerYear)
+	switch {
+	case adx >= regimeTrendADX:
+		regime.Regime = regimeTrending
+	case regime.Volatility >= regimeHighVolatility:
+		regime.Regime = regimeVolatile
+	default:
+		regime.Regime = regimeRanging
+	}
+	return regime, true
+}
+
+// marketRegimeOf classifies the market of symbol over [start, end) from the
+// stored 1m klines.
+func marketRegimeOf(db *dbstore.DBStore, exchange, symbol string, start, end time.Time) (marketRegime, error) {
+	size, dur := regimeBarSize(start, end)
+	limit := int(end.Sub(start)/dur) + 1
+	candles, _, _, err := loadCandles(db, exchange, symbol, size, start, end, limit, false)
+	if err != nil {
+		return marketRegime{}, err
+	}
+	regime, ok := classifyRegime(candles, dur)
+	if !ok {
+		return marketRegime{}, fmt.Errorf("%d %s candles, need at least %d", len(candles), size, 2*regimeADXPeriod+1)
+	}
+	regime.BarSize = size
+	return regime, nil
+}
+
+// tagMarketRegime sets the market regime of a backtest record before it is
+// saved. The backtest already succeeded, so a failure only leaves the
+// regime empty.
+func tagMarketRegime(ctx context.Context, db *dbstore.DBStore, record *store.BacktestRecord) {
+	if db == nil {
+		return
+	}
+	regime, err := marketRegimeOf(db, record.Exchange, record.Symbol, record.StartTime, record.EndTime)
+	if err != nil {
+		log.WithContext(ctx).WithError(err).Debug("market regime not classified")
+		return
+	}
+	record.Regime, record.RegimeADX, record.RegimeVolatility = regime.Regime, regime.ADX, regime.Volatility
+}
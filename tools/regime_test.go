@@ -0,0 +1,50 @@
+package tools
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ztrade/trademodel"
+)
+
+func regimeCandles(n int, price func(i int) float64) []*trademodel.Candle {
+	candles := make([]*trademodel.Candle, n)
+	for i := range candles {
+		p := price(i)
+		candles[i] = &trademodel.Candle{Open: p, High: p * 1.001, Low: p * 0.999, Close: p}
+	}
+	return candles
+}
+
+func TestClassifyRegime(t *testing.T) {
+	trend := regimeCandles(80, func(i int) float64 { return 100 + float64(i) })
+	if r, ok := classifyRegime(trend, 24*time.Hour); !ok || r.Regime != regimeTrending || r.ADX < regimeTrendADX {
+		t.Fatalf("trend: %+v, %v", r, ok)
+	}
+
+	zigzag := func(amp float64) []*trademodel.Candle {
+		return regimeCandles(80, func(i int) float64 { return 100 + amp*float64(i%2) })
+	}
+	if r, ok := classifyRegime(zigzag(0.5), 24*time.Hour); !ok || r.Regime != regimeRanging {
+		t.Fatalf("range: %+v, %v", r, ok)
+	}
+	if r, ok := classifyRegime(zigzag(10), 24*time.Hour); !ok || r.Regime != regimeVolatile {
+		t.Fatalf("volatile: %+v, %v", r, ok)
+	}
+
+	if _, ok := classifyRegime(trend[:2*regimeADXPeriod], 24*time.Hour); ok {
+		t.Error("too few candles classified")
+	}
+}
+
+func TestRegimeBarSize(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, c := range []struct {
+		days int
+		want string
+	}{{365, "1d"}, {30, "4h"}, {3, "1h"}, {0, "15m"}} {
+		if got, _ := regimeBarSize(start, start.AddDate(0, 0, c.days)); got != c.want {
+			t.Errorf("%d days: %s, want %s", c.days, got, c.want)
+		}
+	}
+}
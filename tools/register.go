@@ -11,42 +11,84 @@ import (
 func RegisterAll(s *server.MCPServer, db *dbstore.DBStore, cfg *viper.Viper, st *store.Store) {
 	// Create shared task manager for async operations
 	tm := NewTaskManager()
+	buildConfig = cfg
+	exchangeConfigs.load(cfg)
 
 	registerListData(s, db)
 	registerListExchanges(s, cfg)
+	registerListConfig(s, cfg)
+	registerAddExchange(s, cfg)
 	registerListSymbols(s, cfg)
+	registerGetSymbolInfo(s, cfg)
 	registerQueryKline(s, db)
+	registerQueryKlineMulti(s, db)
+	registerCorrelationMatrix(s, db)
+	registerDataQualityReport(s, db)
 	registerRunPythonResearch(s, cfg)
-	registerFetchKline(s, cfg)
+	registerFetchKline(s, cfg, db)
+	registerFetchDepth(s, cfg)
+	registerFundingRateHistory(s, cfg, st)
+	registerGetOpenOrders(s, cfg)
+	registerGetExchangeFees(s, cfg)
 	registerDownloadKline(s, db, cfg, tm)
-	registerRunBacktest(s, db, tm)
+	registerRunBacktest(s, db, cfg, tm)
 	registerBuildStrategy(s)
-	registerCreateStrategy(s, st)
-	registerStartTrade(s, cfg)
-	registerStopTrade(s)
-	registerTradeStatus(s)
+	registerValidateStrategy(s, st)
+	registerListBuildArtifacts(s)
+	registerCleanupBuildArtifacts(s)
+	registerGetStrategyDependencies(s, cfg, st)
+	registerEstimateWarmup(s, st)
+	registerValidateParamGrid(s, st)
+	registerGetQuota(s, cfg, st)
+	registerListIndicators(s)
+	registerCreateStrategy(s, cfg, st)
+	registerStartTrade(s, cfg, st)
+	registerStopTrade(s, cfg, st)
+	registerTradeStatus(s, cfg, st)
+	registerAttachTrade(s, cfg, st)
+	registerCompareLiveBacktest(s, st)
+	registerReconcileTrade(s, cfg, st)
+	registerNotifyTest(s, cfg)
 
 	// Strategy management tools
-	registerGetStrategy(s, st)
+	registerGetStrategy(s, st, cfg)
 	registerListStrategies(s, st)
-	registerUpdateStrategy(s, st)
-	registerUpdateStrategyMeta(s, st)
+	registerUpdateStrategy(s, cfg, st)
+	registerUpdateStrategyMeta(s, cfg, st)
+	registerBulkUpdateStrategyMeta(s, cfg, st)
+	registerApplyParamSet(s, st)
 	registerDeleteStrategy(s, st)
+	registerHardDeleteStrategy(s, st)
 
 	// Strategy version management
 	registerListStrategyVersions(s, st)
-	registerGetStrategyVersion(s, st)
+	registerGetStrategyVersion(s, st, cfg)
 	registerDiffStrategyVersions(s, st)
-	registerRollbackStrategy(s, st)
+	registerRollbackStrategy(s, cfg, st)
 
 	// Strategy performance tracking
-	registerRunBacktestManaged(s, db, st, tm)
+	registerRunBacktestManaged(s, db, cfg, st, tm)
 	registerListBacktestRecords(s, st)
+	registerListAllBacktests(s, st)
 	registerGetBacktestLogs(s, st)
+	registerGetEquityCurve(s, st)
+	registerGetPositionHistory(s, st)
+	registerTradeDistribution(s, st)
+	registerRollingRisk(s, st)
+	registerGetRunManifest(s, db, st)
+	registerExportBacktestReport(s, cfg, st)
 	registerStrategyPerformance(s, st)
+	registerRecomputePerformance(s, st)
+	registerGetExperiment(s, st)
+	registerParamSensitivity(s, st)
+	registerGetBestParams(s, st)
+	registerBenchmarkStrategy(s, db, cfg, st, tm)
+	registerPortfolioBacktest(s, db, cfg, st, tm)
 
 	// Async task management tools
 	registerGetTaskStatus(s, tm)
 	registerGetTaskResult(s, tm)
 	registerListTasks(s, tm)
+	registerRetryTask(s, db, cfg, tm)
+	registerServerStatus(s, db, st, tm)
 }
@@ -0,0 +1,146 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"regexp"
+	"strconv"
+	"time"
+
+	bcommon "github.com/adshao/go-binance/v2/common"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"github.com/ztrade/exchange"
+	"github.com/ztrade/trademodel"
+)
+
+// retryPolicy controls retries of exchange REST calls.
+type retryPolicy struct {
+	MaxAttempts  int
+	InitialDelay time.Duration
+	MaxDelay     time.Duration
+}
+
+// retryPolicyFromConfig reads mcp.retry.{maxAttempts,initialDelay,maxDelay}.
+func retryPolicyFromConfig(cfg *viper.Viper) retryPolicy {
+	p := retryPolicy{MaxAttempts: 5, InitialDelay: time.Second, MaxDelay: 30 * time.Second}
+	if cfg == nil {
+		return p
+	}
+	if v := cfg.GetInt("mcp.retry.maxAttempts"); v > 0 {
+		p.MaxAttempts = v
+	}
+	if v := cfg.GetDuration("mcp.retry.initialDelay"); v > 0 {
+		p.InitialDelay = v
+	}
+	if v := cfg.GetDuration("mcp.retry.maxDelay"); v > 0 {
+		p.MaxDelay = v
+	}
+	return p
+}
+
+// backoff returns the delay before retry number attempt (1-based): the
+// exponential delay capped at MaxDelay, with jitter in its upper half.
+func (p retryPolicy) backoff(attempt int) time.Duration {
+	d := p.InitialDelay
+	for i := 1; i < attempt && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	if d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if d <= 1 {
+		return d
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+var retryAfterRe = regexp.MustCompile(`(?i)retry[- ]after[":= ]+(\d+)`)
+
+// retryAfter extracts a server requested delay from an error, either from
+// a RetryAfter() method or a "Retry-After: N" (seconds) in its message.
+func retryAfter(err error) (time.Duration, bool) {
+	var ra interface{ RetryAfter() time.Duration }
+	if errors.As(err, &ra) {
+		return ra.RetryAfter(), true
+	}
+	if m := retryAfterRe.FindStringSubmatch(err.Error()); m != nil {
+		if secs, convErr := strconv.Atoi(m[1]); convErr == nil {
+			return time.Duration(secs) * time.Second, true
+		}
+	}
+	return 0, false
+}
+
+// binanceRetryableCodes are the Binance API error codes worth retrying:
+// unknown, disconnected, too many requests, timeout and rate limits.
+var binanceRetryableCodes = map[int64]bool{-1000: true, -1001: true, -1003: true, -1007: true, -1015: true}
+
+// isRetryable reports whether err is likely transient. Binance API errors
+// other than rate limits and timeouts are rejections of the request itself.
+func isRetryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var apiErr *bcommon.APIError
+	if errors.As(err, &apiErr) && apiErr.Code != 0 {
+		return binanceRetryableCodes[apiErr.Code]
+	}
+	return true
+}
+
+// withRetry runs fn until it succeeds, fails with a non-retryable error,
+// ctx is done or p.MaxAttempts is reached. The final error reports the
+// number of attempts. fn is not called once ctx is done, so a cancelled
+// task stops calling the exchange, e.g. from the loop of KlineChan.
+func withRetry[T any](ctx context.Context, p retryPolicy, op string, fn func() (T, error)) (T, error) {
+	var zero T
+	attempts := p.MaxAttempts
+	if attempts <= 0 {
+		attempts = 1
+	}
+	for attempt := 1; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return zero, err
+		}
+		ret, err := fn()
+		if err == nil {
+			return ret, nil
+		}
+		if attempt >= attempts || !isRetryable(err) {
+			// not wrapped: callers such as exchange.KlineChan loop forever
+			// on some wrapped errors
+			return zero, fmt.Errorf("%s failed after %d attempt(s): %v", op, attempt, err)
+		}
+		delay := p.backoff(attempt)
+		if ra, ok := retryAfter(err); ok && ra > delay {
+			delay = ra
+		}
+		log.WithContext(ctx).WithError(err).WithFields(log.Fields{"op": op, "attempt": attempt, "delay": delay}).Warn("retrying exchange call")
+		select {
+		case <-ctx.Done():
+			return zero, fmt.Errorf("%s canceled after %d attempt(s): %v", op, attempt, err)
+		case <-time.After(delay):
+		}
+	}
+}
+
+// retryExchange retries GetKline of the wrapped exchange.
+type retryExchange struct {
+	exchange.Exchange
+	ctx    context.Context
+	policy retryPolicy
+}
+
+func newRetryExchange(ctx context.Context, ex exchange.Exchange, p retryPolicy) *retryExchange {
+	return &retryExchange{Exchange: ex, ctx: ctx, policy: p}
+}
+
+func (e *retryExchange) GetKline(symbol, bSize string, start, end time.Time) ([]*trademodel.Candle, error) {
+	return withRetry(e.ctx, e.policy, "get kline", func() ([]*trademodel.Candle, error) {
+		return e.Exchange.GetKline(symbol, bSize, start, end)
+	})
+}
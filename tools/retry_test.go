@@ -0,0 +1,105 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	bcommon "github.com/adshao/go-binance/v2/common"
+)
+
+func TestRetryBackoff(t *testing.T) {
+	p := retryPolicy{MaxAttempts: 5, InitialDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	for attempt, want := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 3: 400 * time.Millisecond, 10: time.Second} {
+		d := p.backoff(attempt)
+		if d < want/2 || d > want {
+			t.Errorf("backoff(%d) = %v, want in [%v, %v]", attempt, d, want/2, want)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	d, ok := retryAfter(errors.New("429 Too Many Requests, Retry-After: 7"))
+	if !ok || d != 7*time.Second {
+		t.Errorf("retryAfter = %v, %v", d, ok)
+	}
+	if _, ok := retryAfter(errors.New("connection reset")); ok {
+		t.Error("retryAfter found a delay in an unrelated error")
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{errors.New("connection reset"), true},
+		{&bcommon.APIError{Code: -1003, Message: "too many requests"}, true},
+		{&bcommon.APIError{Code: -1121, Message: "invalid symbol"}, false},
+		{context.Canceled, false},
+	}
+	for _, c := range cases {
+		if got := isRetryable(c.err); got != c.want {
+			t.Errorf("isRetryable(%v) = %v, want %v", c.err, got, c.want)
+		}
+	}
+}
+
+func TestWithRetry(t *testing.T) {
+	p := retryPolicy{MaxAttempts: 3, InitialDelay: time.Millisecond, MaxDelay: time.Millisecond}
+	ctx := context.Background()
+
+	calls := 0
+	v, err := withRetry(ctx, p, "op", func() (int, error) {
+		calls++
+		if calls < 3 {
+			return 0, errors.New("timeout")
+		}
+		return 42, nil
+	})
+	if err != nil || v != 42 || calls != 3 {
+		t.Fatalf("got %v, %v after %d calls", v, err, calls)
+	}
+
+	calls = 0
+	_, err = withRetry(ctx, p, "op", func() (int, error) {
+		calls++
+		return 0, errors.New("timeout")
+	})
+	if err == nil || calls != 3 || !strings.Contains(err.Error(), "after 3 attempt(s)") {
+		t.Fatalf("exhausted: %v after %d calls", err, calls)
+	}
+
+	calls = 0
+	_, err = withRetry(ctx, p, "op", func() (int, error) {
+		calls++
+		return 0, &bcommon.APIError{Code: -1121, Message: "invalid symbol"}
+	})
+	if err == nil || calls != 1 {
+		t.Fatalf("non-retryable: %v after %d calls", err, calls)
+	}
+
+	// a cancelled context stops the calls, before the first and between retries
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	calls = 0
+	_, err = withRetry(cancelled, p, "op", func() (int, error) {
+		calls++
+		return 0, nil
+	})
+	if !errors.Is(err, context.Canceled) || calls != 0 {
+		t.Fatalf("cancelled before the call: %v after %d calls", err, calls)
+	}
+	cancelled, cancel = context.WithCancel(ctx)
+	calls = 0
+	_, err = withRetry(cancelled, retryPolicy{MaxAttempts: 3}, "op", func() (int, error) {
+		calls++
+		cancel()
+		return 0, errors.New("timeout")
+	})
+	if err == nil || calls != 1 {
+		t.Fatalf("cancelled during the call: %v after %d calls", err, calls)
+	}
+}
@@ -0,0 +1,226 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/ztrade/ztrade-mcp/internal/stats"
+	"github.com/ztrade/ztrade-mcp/store"
+)
+
+const (
+	defaultRollingWindowDays = 30
+	defaultDrawdownPeriods   = 10
+	// daysPerYear annualizes daily returns; crypto markets trade every day.
+	daysPerYear = 365
+)
+
+// dailyEquity samples the equity curve at the end of every UTC day from
+// start to end, carrying the last equity forward over days without trades.
+func dailyEquity(points []store.EquityPoint, start, end time.Time) []store.EquityPoint {
+	if len(points) == 0 {
+		return nil
+	}
+	day := start.UTC().Truncate(24 * time.Hour)
+	var ret []store.EquityPoint
+	i, equity := 0, points[0].Equity
+	for ; !day.After(end); day = day.Add(24 * time.Hour) {
+		dayEnd := day.Add(24 * time.Hour)
+		for i < len(points) && points[i].Time.Before(dayEnd) {
+			equity = points[i].Equity
+			i++
+		}
+		ret = append(ret, store.EquityPoint{Time: day, Equity: equity})
+	}
+	return ret
+}
+
+// rollingSharpePoint is the annualized Sharpe ratio of the window of daily
+// returns ending on Time.
+type rollingSharpePoint struct {
+	Time   string  `json:"time"`
+	Sharpe float64 `json:"sharpe"`
+}
+
+// rollingSharpe returns the annualized Sharpe ratio, in excess of
+// riskFreeRate (annual), of every window of window daily returns. A window
+// without dispersion has a Sharpe of 0, as in excessReturnRatios.
+func rollingSharpe(daily []store.EquityPoint, window int, riskFreeRate float64) []rollingSharpePoint {
+	if window < 2 || len(daily) <= window {
+		return nil
+	}
+	dayRate := math.Pow(1+riskFreeRate, 1.0/daysPerYear) - 1
+	excess := make([]float64, len(daily)-1)
+	for i := 1; i < len(daily); i++ {
+		if daily[i-1].Equity > 0 {
+			excess[i-1] = daily[i].Equity/daily[i-1].Equity - 1 - dayRate
+		}
+	}
+	annualize := math.Sqrt(daysPerYear)
+	ret := make([]rollingSharpePoint, 0, len(excess)-window+1)
+	for i := window; i <= len(excess); i++ {
+		xs := excess[i-window : i]
+		var sharpe float64
+		if sd := stats.StdDev(xs); sd > 0 {
+			sharpe = stats.Mean(xs) / sd * annualize
+		}
+		ret = append(ret, rollingSharpePoint{Time: daily[i].Time.Format("2006-01-02 15:04:05"), Sharpe: sharpe})
+	}
+	return ret
+}
+
+// drawdownPeriod is one stretch of the equity curve below its running peak:
+// from the peak, through the trough, until equity first gets back to the
+// peak. Recovery is empty for a drawdown still open at the end.
+type drawdownPeriod struct {
+	Start        string  `json:"start"`
+	Trough       string  `json:"trough"`
+	Recovery     string  `json:"recovery,omitempty"`
+	Peak         float64 `json:"peak"`
+	TroughEquity float64 `json:"troughEquity"`
+	Depth        float64 `json:"depth"`        // (peak - trough) / peak
+	DurationDays float64 `json:"durationDays"` // start to recovery, or to the end when not recovered
+	RecoveryDays float64 `json:"recoveryDays"` // trough to recovery, or to the end when not recovered
+	Recovered    bool    `json:"recovered"`
+}
+
+// drawdownPeriods splits the equity curve into its drawdown periods, in time
+// order. end closes the duration of a drawdown that has not recovered.
+func drawdownPeriods(points []store.EquityPoint, end time.Time) []drawdownPeriod {
+	days := func(from, to time.Time) float64 { return to.Sub(from).Hours() / 24 }
+	var ret []drawdownPeriod
+	var cur *drawdownPeriod
+	var peak, trough store.EquityPoint
+	closePeriod := func(to time.Time) {
+		cur.Trough = trough.Time.Format("2006-01-02 15:04:05")
+		cur.TroughEquity = trough.Equity
+		cur.Depth = (peak.Equity - trough.Equity) / peak.Equity
+		cur.DurationDays = days(peak.Time, to)
+		cur.RecoveryDays = days(trough.Time, to)
+		ret = append(ret, *cur)
+		cur = nil
+	}
+	for i, p := range points {
+		switch {
+		case i == 0 || (cur == nil && p.Equity >= peak.Equity):
+			peak = p
+		case cur != nil && p.Equity >= peak.Equity:
+			cur.Recovery = p.Time.Format("2006-01-02 15:04:05")
+			cur.Recovered = true
+			closePeriod(p.Time)
+			peak = p
+		case p.Equity < peak.Equity && peak.Equity > 0:
+			if cur == nil {
+				cur = &drawdownPeriod{Start: peak.Time.Format("2006-01-02 15:04:05"), Peak: peak.Equity}
+				trough = p
+			}
+			if p.Equity < trough.Equity {
+				trough = p
+			}
+		}
+	}
+	if cur != nil {
+		if end.Before(trough.Time) {
+			end = trough.Time
+		}
+		closePeriod(end)
+	}
+	return ret
+}
+
+func registerRollingRisk(s *server.MCPServer, st *store.Store) {
+	tool := mcp.NewTool("rolling_risk",
+		mcp.WithDescription("Stability of risk-adjusted performance of a saved backtest record (run_backtest_managed): the rolling Sharpe ratio over a window of daily returns, and the drawdown periods (start, trough, recovery, duration, depth) of the equity curve sorted by depth. Also returns the longest time-to-recovery, which often matters more than the maximum drawdown depth."),
+		mcp.WithNumber("recordId", mcp.Required(), mcp.Description("Backtest record ID")),
+		mcp.WithNumber("windowDays", mcp.Description(fmt.Sprintf("Rolling Sharpe window in days of daily returns. Default: %d", defaultRollingWindowDays))),
+		mcp.WithNumber("maxPeriods", mcp.Description(fmt.Sprintf("Return at most this many drawdown periods, deepest first. 0 returns all. Default: %d", defaultDrawdownPeriods))),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if st == nil {
+			return toolError(codeUnavailable, "script store not initialized (check database config)"), nil
+		}
+
+		recordID := int64(req.GetFloat("recordId", 0))
+		window := int(req.GetFloat("windowDays", defaultRollingWindowDays))
+		if window < 2 {
+			return toolError(codeInvalidArgument, "windowDays must be at least 2"), nil
+		}
+		maxPeriods := int(req.GetFloat("maxPeriods", defaultDrawdownPeriods))
+		if maxPeriods < 0 {
+			return toolError(codeInvalidArgument, "maxPeriods must not be negative"), nil
+		}
+
+		record, _, err := accessibleRecord(ctx, st, recordID)
+		if err != nil {
+			return toolErrorf(storeErrorCode(err), "failed to get record: %s", err.Error()), nil
+		}
+		points, err := st.ListBacktestEquity(recordID)
+		if err != nil {
+			return toolErrorf(storeErrorCode(err), "failed to get equity curve: %s", err.Error()), nil
+		}
+		if len(points) == 0 {
+			return toolError(codeFailedPrecondition, "no equity curve stored for this record (records created before equity persistence have none)"), nil
+		}
+
+		daily := dailyEquity(points, record.StartTime, record.EndTime)
+		sharpe := rollingSharpe(daily, window, reportRiskFreeRate)
+		var sharpes []float64
+		positive := 0
+		for _, p := range sharpe {
+			sharpes = append(sharpes, p.Sharpe)
+			if p.Sharpe > 0 {
+				positive++
+			}
+		}
+		sharpeSummary := map[string]interface{}{"windows": len(sharpe)}
+		if len(sharpes) > 0 {
+			sorted := append([]float64(nil), sharpes...)
+			sort.Float64s(sorted)
+			sharpeSummary["min"] = sorted[0]
+			sharpeSummary["max"] = sorted[len(sorted)-1]
+			sharpeSummary["mean"] = stats.Mean(sharpes)
+			sharpeSummary["stdDev"] = stats.StdDev(sharpes)
+			sharpeSummary["positiveRatio"] = float64(positive) / float64(len(sharpes))
+		}
+
+		periods := drawdownPeriods(points, record.EndTime)
+		var longestRecovery, longestDrawdown *drawdownPeriod
+		for _, p := range periods {
+			if p.Recovered && (longestRecovery == nil || p.RecoveryDays > longestRecovery.RecoveryDays) {
+				longestRecovery = &p
+			}
+			if longestDrawdown == nil || p.DurationDays > longestDrawdown.DurationDays {
+				longestDrawdown = &p
+			}
+		}
+		result := map[string]interface{}{
+			"recordId":        recordID,
+			"windowDays":      window,
+			"rollingSharpe":   sharpe,
+			"sharpeSummary":   sharpeSummary,
+			"drawdownCount":   len(periods),
+			"longestRecovery": longestRecovery,
+			"longestDrawdown": longestDrawdown,
+		}
+		if n := len(periods); n > 0 && !periods[n-1].Recovered {
+			result["currentDrawdown"] = periods[n-1]
+		}
+		sort.SliceStable(periods, func(i, j int) bool { return periods[i].Depth > periods[j].Depth })
+		if maxPeriods > 0 && len(periods) > maxPeriods {
+			periods = periods[:maxPeriods]
+		}
+		result["drawdowns"] = periods
+		if len(sharpe) == 0 {
+			result["warning"] = fmt.Sprintf("the backtest spans %d days, too short for a %d-day rolling window", len(daily)-1, window)
+		}
+		data, _ := json.MarshalIndent(result, "", "  ")
+		return mcp.NewToolResultText(string(data)), nil
+	})
+}
@@ -0,0 +1,56 @@
+package tools
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/ztrade/ztrade-mcp/store"
+)
+
+func TestDrawdownPeriods(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, d) }
+	points := []store.EquityPoint{
+		{Time: day(0), Equity: 100},
+		{Time: day(1), Equity: 90},
+		{Time: day(2), Equity: 95},
+		{Time: day(5), Equity: 101}, // recovers the first drawdown
+		{Time: day(6), Equity: 80},
+		{Time: day(7), Equity: 85},
+	}
+	periods := drawdownPeriods(points, day(10))
+	if len(periods) != 2 {
+		t.Fatalf("periods = %+v", periods)
+	}
+	first, second := periods[0], periods[1]
+	if !first.Recovered || first.Start != "2024-01-01 00:00:00" || first.Trough != "2024-01-02 00:00:00" || first.Recovery != "2024-01-06 00:00:00" {
+		t.Fatalf("first = %+v", first)
+	}
+	if math.Abs(first.Depth-0.1) > 1e-9 || first.DurationDays != 5 || first.RecoveryDays != 4 {
+		t.Fatalf("first = %+v", first)
+	}
+	if second.Recovered || second.Recovery != "" || second.Peak != 101 || second.TroughEquity != 80 || second.DurationDays != 5 || second.RecoveryDays != 4 {
+		t.Fatalf("second = %+v", second)
+	}
+}
+
+func TestRollingSharpe(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	points := []store.EquityPoint{{Time: start, Equity: 100}, {Time: start.AddDate(0, 0, 2), Equity: 110}}
+	daily := dailyEquity(points, start, start.AddDate(0, 0, 4))
+	if len(daily) != 5 || daily[1].Equity != 100 || daily[2].Equity != 110 || daily[4].Equity != 110 {
+		t.Fatalf("daily = %+v", daily)
+	}
+
+	sharpe := rollingSharpe(daily, 2, 0)
+	if len(sharpe) != 3 || sharpe[0].Time != "2024-01-03 00:00:00" || sharpe[0].Sharpe <= 0 {
+		t.Fatalf("sharpe = %+v", sharpe)
+	}
+	// a flat window has no dispersion
+	if sharpe[2].Sharpe != 0 {
+		t.Errorf("flat window sharpe = %v", sharpe[2].Sharpe)
+	}
+	if got := rollingSharpe(daily, 5, 0); got != nil {
+		t.Errorf("window longer than the data: %+v", got)
+	}
+}
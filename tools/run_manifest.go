@@ -0,0 +1,235 @@
+package tools
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	log "github.com/sirupsen/logrus"
+	"github.com/ztrade/trademodel"
+	"github.com/ztrade/ztrade-mcp/store"
+	"github.com/ztrade/ztrade/pkg/process/dbstore"
+)
+
+// manifestPageSize is how many 1m candles klineDataHash reads at a time.
+const manifestPageSize = 50000
+
+// runManifest is what a backtest record needs beyond its own columns to be
+// reproduced: the strategy source, the cost model, the plugin build flags
+// and the server build. stamp adds the hash of the candles it ran on.
+type runManifest struct {
+	SourceHash    string
+	CostModel     string
+	BuildFlags    string
+	ServerVersion string
+}
+
+func newRunManifest(content string, flags pluginBuildFlags, costs TradingCostModel) runManifest {
+	return runManifest{
+		SourceHash:    sourceHash(content),
+		CostModel:     costModelManifest(costs),
+		BuildFlags:    flags.goflags(),
+		ServerVersion: serverBuildVersion(),
+	}
+}
+
+// stamp sets the manifest columns of a backtest record before it is saved.
+// The backtest already succeeded, so failing to hash the candles only leaves
+// the data columns empty.
+func (m runManifest) stamp(ctx context.Context, db *dbstore.DBStore, record *store.BacktestRecord) {
+	record.SourceHash, record.CostModel = m.SourceHash, m.CostModel
+	record.BuildFlags, record.ServerVersion = m.BuildFlags, m.ServerVersion
+	if db == nil {
+		return
+	}
+	digest, rows, err := klineDataHash(db, record.Exchange, record.Symbol, record.StartTime, record.EndTime)
+	if err != nil {
+		log.WithContext(ctx).WithError(err).Warn("backtest data not hashed")
+		return
+	}
+	record.DataHash, record.DataRows = digest, rows
+}
+
+// sourceHash is the hex SHA-256 of a strategy source.
+func sourceHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// costModelManifest renders a cost model as JSON keyed like the backtest
+// tool arguments.
+func costModelManifest(costs TradingCostModel) string {
+	data, _ := json.Marshal(map[string]interface{}{
+		"fee":            costs.TakerFee,
+		"makerFee":       costs.MakerFee,
+		"slippage":       costs.Slippage,
+		"lever":          costs.Lever,
+		"minNotional":    costs.MinNotional,
+		"lotSize":        costs.AmountStep,
+		"includeFunding": costs.IncludeFunding,
+	})
+	return string(data)
+}
+
+// serverBuildVersion describes the build of this server: its module
+// version, the Go toolchain and the versions of the ztrade modules that run
+// the backtests.
+func serverBuildVersion() string {
+	parts := []string{"go " + runtime.Version()}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return parts[0]
+	}
+	parts = append([]string{info.Main.Path + " " + info.Main.Version}, parts...)
+	for _, dep := range info.Deps {
+		if strings.HasPrefix(dep.Path, "github.com/ztrade/") {
+			parts = append(parts, dep.Path+" "+dep.Version)
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+// klineDataHash returns the hex SHA-256 and the count of the stored 1m
+// candles of exchange/symbol in [start, end), so a later re-download that
+// changed them can be detected.
+func klineDataHash(db *dbstore.DBStore, exchange, symbol string, start, end time.Time) (string, int, error) {
+	tbl := db.GetKlineTbl(exchange, symbol, "1m")
+	h := sha256.New()
+	rows := 0
+	for cursor := start; cursor.Before(end); {
+		datas, err := tbl.GetDatas(cursor, end, manifestPageSize)
+		if err != nil {
+			return "", 0, err
+		}
+		var last *trademodel.Candle
+		for _, d := range datas {
+			c, ok := d.(*trademodel.Candle)
+			if !ok || !time.Unix(c.Start, 0).Before(end) {
+				continue
+			}
+			writeCandle(h, c)
+			rows++
+			last = c
+		}
+		if len(datas) < manifestPageSize || last == nil {
+			break
+		}
+		cursor = time.Unix(last.Start, 0).Add(time.Minute)
+	}
+	return hex.EncodeToString(h.Sum(nil)), rows, nil
+}
+
+func writeCandle(h hash.Hash, c *trademodel.Candle) {
+	line := make([]byte, 0, 96)
+	line = strconv.AppendInt(line, c.Start, 10)
+	for _, v := range []float64{c.Open, c.High, c.Low, c.Close, c.Volume} {
+		line = append(line, ',')
+		line = strconv.AppendFloat(line, v, 'g', -1, 64)
+	}
+	h.Write(append(line, '\n'))
+}
+
+func registerGetRunManifest(s *server.MCPServer, db *dbstore.DBStore, st *store.Store) {
+	tool := mcp.NewTool("get_run_manifest",
+		mcp.WithDescription("Get everything needed to reproduce a backtest record of run_backtest_managed: strategy version and source hash, parameters, data range with the row count and hash of the 1m candles, cost model, execution settings, build flags and server build, plus the arguments to rerun it. With verify the current strategy source, stored candles and server build are compared with the recorded ones, so a re-download that changed the data or a toolchain upgrade shows up as a reason the result may not reproduce."),
+		mcp.WithNumber("recordId", mcp.Required(), mcp.Description("Backtest record ID")),
+		mcp.WithBoolean("verify", mcp.Description("Recompute the source and data hashes and compare them and the server build with the recorded ones. Reads all 1m candles of the range. Default: false")),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if st == nil {
+			return toolError(codeUnavailable, "script store not initialized (check database config)"), nil
+		}
+
+		recordID := int64(req.GetFloat("recordId", 0))
+		record, _, err := accessibleRecord(ctx, st, recordID)
+		if err != nil {
+			return toolErrorf(storeErrorCode(err), "failed to get record: %s", err.Error()), nil
+		}
+
+		var costs interface{}
+		if record.CostModel != "" {
+			costs = json.RawMessage(record.CostModel)
+		}
+		rerun := map[string]interface{}{
+			"strategyId": record.ScriptID, "version": record.ScriptVersion,
+			"exchange": record.Exchange, "symbol": record.Symbol,
+			"start": record.StartTime.Format("2006-01-02 15:04:05"), "end": record.EndTime.Format("2006-01-02 15:04:05"),
+			"balance": record.InitBalance, "warmupBars": record.WarmupBars,
+			"limitOrderModel": record.LimitOrderModel, "limitOrderExpiry": record.LimitOrderExpiry,
+			"riskFreeRate": record.RiskFreeRate,
+		}
+		if record.Param != "" {
+			rerun["param"] = record.Param
+		}
+		if record.FillOn != "" {
+			rerun["fillOn"] = record.FillOn
+		}
+		result := map[string]interface{}{
+			"recordId":      record.ID,
+			"createdAt":     record.CreatedAt.Format("2006-01-02 15:04:05"),
+			"strategyId":    record.ScriptID,
+			"version":       record.ScriptVersion,
+			"sourceHash":    record.SourceHash,
+			"exchange":      record.Exchange,
+			"symbol":        record.Symbol,
+			"start":         record.StartTime.Format("2006-01-02 15:04:05"),
+			"end":           record.EndTime.Format("2006-01-02 15:04:05"),
+			"dataRows":      record.DataRows,
+			"dataHash":      record.DataHash,
+			"costModel":     costs,
+			"buildFlags":    record.BuildFlags,
+			"serverVersion": record.ServerVersion,
+			"rerunArgs":     rerun,
+		}
+		if record.SourceHash == "" {
+			result["note"] = "this record was saved before run manifests were recorded; only its own columns are known"
+		}
+
+		if req.GetBool("verify", false) {
+			var issues []string
+			if ver, err := st.GetVersion(record.ScriptID, record.ScriptVersion); err != nil {
+				issues = append(issues, fmt.Sprintf("strategy version %d is not available: %s", record.ScriptVersion, err.Error()))
+			} else {
+				current := sourceHash(ver.Content)
+				result["currentSourceHash"] = current
+				if record.SourceHash != "" && current != record.SourceHash {
+					issues = append(issues, fmt.Sprintf("the stored source of version %d no longer matches the one that ran", record.ScriptVersion))
+				}
+			}
+			if db == nil {
+				issues = append(issues, "kline database not initialized, data not verified")
+			} else if digest, rows, err := klineDataHash(db, record.Exchange, record.Symbol, record.StartTime, record.EndTime); err != nil {
+				issues = append(issues, "data not verified: "+err.Error())
+			} else {
+				result["currentDataRows"] = rows
+				result["currentDataHash"] = digest
+				if record.DataHash != "" && digest != record.DataHash {
+					issues = append(issues, fmt.Sprintf("the stored candles changed since the run (%d rows then, %d now): the data was re-downloaded or edited and the result may not reproduce", record.DataRows, rows))
+				}
+			}
+			current := serverBuildVersion()
+			result["currentServerVersion"] = current
+			if record.ServerVersion != "" && current != record.ServerVersion {
+				issues = append(issues, "the server was built differently (Go toolchain or ztrade module versions); engine changes may alter the result")
+			}
+			if record.SourceHash == "" {
+				issues = append(issues, "no manifest recorded, reproducibility cannot be proven")
+			}
+			result["reproducible"] = len(issues) == 0
+			result["issues"] = issues
+		}
+		data, _ := json.MarshalIndent(result, "", "  ")
+		return mcp.NewToolResultText(string(data)), nil
+	})
+}
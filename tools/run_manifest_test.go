@@ -0,0 +1,71 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ztrade/trademodel"
+	"github.com/ztrade/ztrade-mcp/store"
+	"github.com/ztrade/ztrade/pkg/process/dbstore"
+)
+
+func TestRunManifest(t *testing.T) {
+	db, err := dbstore.NewDBStore("sqlite", filepath.Join(t.TempDir(), "kline.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	save := func(closeOf func(i int) float64) {
+		var candles []*trademodel.Candle
+		for i := 0; i < 30; i++ {
+			candles = append(candles, &trademodel.Candle{Start: base.Add(time.Duration(i) * time.Minute).Unix(), Open: 1, High: 2, Low: 0.5, Close: closeOf(i), Volume: 3})
+		}
+		if _, err := saveFetchedKlines(db, "binance", "BTCUSDT", "1m", candles); err != nil {
+			t.Fatal(err)
+		}
+	}
+	save(func(int) float64 { return 1 })
+
+	costs := TradingCostModel{TakerFee: 0.0004, Lever: 2, IncludeFunding: true}
+	m := newRunManifest("package main", pluginBuildFlags{Tags: []string{"prod"}}, costs)
+	record := &store.BacktestRecord{Exchange: "binance", Symbol: "BTCUSDT", StartTime: base, EndTime: base.Add(20 * time.Minute)}
+	m.stamp(context.Background(), db, record)
+	if record.SourceHash != sourceHash("package main") || len(record.SourceHash) != 64 || record.BuildFlags != "-tags=prod" {
+		t.Fatalf("manifest: %+v", record)
+	}
+	if !strings.Contains(record.ServerVersion, runtime.Version()) {
+		t.Errorf("server version %q", record.ServerVersion)
+	}
+	var cm map[string]interface{}
+	if err := json.Unmarshal([]byte(record.CostModel), &cm); err != nil || cm["fee"] != 0.0004 || cm["lever"] != 2.0 || cm["includeFunding"] != true {
+		t.Errorf("cost model %s: %v", record.CostModel, err)
+	}
+	if record.DataRows != 20 || record.DataHash == "" {
+		t.Fatalf("data: %d rows, hash %q", record.DataRows, record.DataHash)
+	}
+
+	// a candle outside the range does not matter, one inside does
+	save(func(i int) float64 {
+		if i == 25 {
+			return 9
+		}
+		return 1
+	})
+	if digest, rows, err := klineDataHash(db, "binance", "BTCUSDT", record.StartTime, record.EndTime); err != nil || rows != 20 || digest != record.DataHash {
+		t.Fatalf("unchanged range: %s, %d, %v", digest, rows, err)
+	}
+	save(func(i int) float64 {
+		if i == 5 {
+			return 9
+		}
+		return 1
+	})
+	if digest, _, err := klineDataHash(db, "binance", "BTCUSDT", record.StartTime, record.EndTime); err != nil || digest == record.DataHash {
+		t.Fatalf("changed candle not detected: %s, %v", digest, err)
+	}
+}
@@ -6,8 +6,6 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
-
-	"github.com/ztrade/ztrade/pkg/ctl"
 )
 
 // ensurePluginScript compiles a .go strategy into a plugin and returns the runtime path.
@@ -25,10 +23,30 @@ func ensurePluginScript(script string) (string, error) {
 	sum := sha1.Sum([]byte(script))
 	soPath := filepath.Join("/tmp/ztrade_plugins", fmt.Sprintf("%s_%x.so", base, sum[:6]))
 
-	builder := ctl.NewBuilder(script, soPath)
-	if err := builder.Build(); err != nil {
+	if err := buildPlugin(script, soPath, pluginBuildFlags{}); err != nil {
 		return "", fmt.Errorf("failed to build so: %w", err)
 	}
 
 	return soPath, nil
 }
+
+// preflightPluginScript loads the runtime plugin the way the engine will and
+// checks its method set. Callers run it before queuing an async backtest, so
+// a strategy that cannot run fails the request instead of the task.
+func preflightPluginScript(script string) error {
+	if _, err := os.Stat(script); err != nil {
+		return fmt.Errorf("strategy plugin not found: %w", err)
+	}
+	v, err := loadStrategyPlugin(script)
+	if err != nil {
+		return fmt.Errorf("failed to load plugin %s: %w", script, err)
+	}
+	if issues := checkStrategyValue(v); len(issues) > 0 {
+		methods := make([]string, 0, len(issues))
+		for _, is := range issues {
+			methods = append(methods, is.Method+" "+is.Problem)
+		}
+		return fmt.Errorf("plugin %s does not implement the strategy interface: %s (see validate_strategy)", script, strings.Join(methods, ", "))
+	}
+	return nil
+}
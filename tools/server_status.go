@@ -0,0 +1,79 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/ztrade/ztrade-mcp/store"
+	"github.com/ztrade/ztrade/pkg/process/dbstore"
+)
+
+// serverStatusPingTimeout bounds the script store ping of server_status,
+// which dashboards poll.
+const serverStatusPingTimeout = 2 * time.Second
+
+// serverStarted is when the tools were registered, for the uptime.
+var serverStarted = time.Now()
+
+// liveTradeStats summarizes the running live trades.
+type liveTradeStats struct {
+	Active     int            `json:"active"`
+	ByExchange map[string]int `json:"byExchange"`
+	Oldest     string         `json:"oldestStarted,omitempty"`
+}
+
+func (m *tradeManager) stats() liveTradeStats {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	stats := liveTradeStats{Active: len(m.trades), ByExchange: map[string]int{}}
+	var oldest time.Time
+	for _, inst := range m.trades {
+		stats.ByExchange[inst.Exchange]++
+		if oldest.IsZero() || inst.Started.Before(oldest) {
+			oldest = inst.Started
+		}
+	}
+	if !oldest.IsZero() {
+		stats.Oldest = oldest.Format("2006-01-02 15:04:05")
+	}
+	return stats
+}
+
+func registerServerStatus(s *server.MCPServer, db *dbstore.DBStore, st *store.Store, tm *TaskManager) {
+	tool := mcp.NewTool("server_status",
+		mcp.WithDescription("At-a-glance server status for dashboards: async task counts by status, the running tasks with their age, progress and estimated time remaining, the active live trades, and whether the kline database and the script store are available. Aggregated in memory apart from a quick script store ping, so it is cheap to poll."),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		now := time.Now()
+		databases := map[string]interface{}{
+			"kline": map[string]interface{}{"available": db != nil},
+		}
+		scriptStore := map[string]interface{}{"available": st != nil}
+		if st != nil {
+			pingCtx, cancel := context.WithTimeout(ctx, serverStatusPingTimeout)
+			start := time.Now()
+			err := st.Ping(pingCtx)
+			cancel()
+			scriptStore["latency"] = time.Since(start).Truncate(time.Microsecond).String()
+			if err != nil {
+				scriptStore["available"] = false
+				scriptStore["error"] = err.Error()
+			}
+		}
+		databases["scriptStore"] = scriptStore
+
+		result := map[string]interface{}{
+			"time":       now.Format("2006-01-02 15:04:05"),
+			"uptime":     now.Sub(serverStarted).Truncate(time.Second).String(),
+			"tasks":      tm.Stats(now),
+			"liveTrades": manager.stats(),
+			"databases":  databases,
+		}
+		data, _ := json.MarshalIndent(result, "", "  ")
+		return mcp.NewToolResultText(string(data)), nil
+	})
+}
@@ -0,0 +1,96 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/viper"
+	"github.com/ztrade/ztrade-mcp/store"
+)
+
+// stableGate is the quality gate a strategy passes before lifecycleStatus
+// becomes stable: at least one of its backtest records must meet all
+// thresholds. Read from mcp.stableGate.
+type stableGate struct {
+	Enabled     bool
+	MinSharpe   float64 // the Sharpe ratio must exceed it
+	MaxDrawdown float64 // the max drawdown (0.3 = 30%) must stay below it
+	MinTrades   int     // at least this many actions; 0 does not check
+}
+
+// stableGateFromConfig reads mcp.stableGate.{enabled,minSharpe,maxDrawdown,minTrades}.
+// The gate is on by default with Sharpe > 1 and max drawdown < 30%, the
+// acceptable grades of metricgrade.
+func stableGateFromConfig(cfg *viper.Viper) stableGate {
+	g := stableGate{Enabled: true, MinSharpe: 1, MaxDrawdown: 0.3}
+	if cfg == nil {
+		return g
+	}
+	if cfg.IsSet("mcp.stableGate.enabled") {
+		g.Enabled = cfg.GetBool("mcp.stableGate.enabled")
+	}
+	if cfg.IsSet("mcp.stableGate.minSharpe") {
+		g.MinSharpe = cfg.GetFloat64("mcp.stableGate.minSharpe")
+	}
+	if cfg.IsSet("mcp.stableGate.maxDrawdown") {
+		g.MaxDrawdown = cfg.GetFloat64("mcp.stableGate.maxDrawdown")
+	}
+	g.MinTrades = cfg.GetInt("mcp.stableGate.minTrades")
+	return g
+}
+
+// failures lists the thresholds record misses.
+func (g stableGate) failures(record *store.BacktestRecord) []string {
+	var failed []string
+	if record.SharpeRatio <= g.MinSharpe {
+		failed = append(failed, fmt.Sprintf("sharpe %.2f <= %.2f", record.SharpeRatio, g.MinSharpe))
+	}
+	if record.MaxDrawdown >= g.MaxDrawdown {
+		failed = append(failed, fmt.Sprintf("maxDrawdown %.1f%% >= %.1f%%", record.MaxDrawdown*100, g.MaxDrawdown*100))
+	}
+	if record.TotalActions < g.MinTrades {
+		failed = append(failed, fmt.Sprintf("trades %d < %d", record.TotalActions, g.MinTrades))
+	}
+	return failed
+}
+
+// check returns the newest backtest record of the strategy that passes the
+// gate, or why none does. A disabled gate passes without a record.
+func (g stableGate) check(st *store.Store, scriptID int64) (*store.BacktestRecord, string, error) {
+	if !g.Enabled {
+		return nil, "", nil
+	}
+	records, err := st.ListRunRecords(scriptID)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Sprintf("strategy %d has no backtest records; it needs one with %s to become stable, run run_backtest_managed first", scriptID, g.describe()), nil
+	}
+	var best *store.BacktestRecord
+	for i := range records {
+		r := &records[i]
+		if len(g.failures(r)) == 0 {
+			return r, "", nil
+		}
+		if best == nil || r.SharpeRatio > best.SharpeRatio {
+			best = r
+		}
+	}
+	return nil, fmt.Sprintf("none of the %d backtest records of strategy %d meets the stable gate (%s); the best by sharpe, record %d, has %s",
+		len(records), scriptID, g.describe(), best.ID, strings.Join(g.failures(best), ", ")), nil
+}
+
+func (g stableGate) describe() string {
+	parts := []string{fmt.Sprintf("sharpe > %g", g.MinSharpe), fmt.Sprintf("maxDrawdown < %g%%", g.MaxDrawdown*100)}
+	if g.MinTrades > 0 {
+		parts = append(parts, fmt.Sprintf("at least %d trades", g.MinTrades))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// promotesToStable reports whether fields move script to stable.
+func promotesToStable(script *store.Script, fields map[string]interface{}) bool {
+	ls, ok := fields["lifecycle_status"].(string)
+	return ok && ls == store.StrategyLifecycleStable && script.LifecycleStatus != store.StrategyLifecycleStable
+}
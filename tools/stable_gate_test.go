@@ -0,0 +1,66 @@
+package tools
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/ztrade/ztrade-mcp/store"
+)
+
+func TestStableGate(t *testing.T) {
+	cfg := viper.New()
+	cfg.Set("db.type", "sqlite")
+	cfg.Set("db.uri", filepath.Join(t.TempDir(), "mcp.db"))
+	st, err := store.NewStore(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+
+	script := &store.Script{Name: "Demo", Content: "package main"}
+	if err := st.CreateScript(script); err != nil {
+		t.Fatal(err)
+	}
+	gate := stableGateFromConfig(cfg)
+	if !gate.Enabled || gate.MinSharpe != 1 || gate.MaxDrawdown != 0.3 {
+		t.Fatalf("default gate %+v", gate)
+	}
+	if _, msg, err := gate.check(st, script.ID); err != nil || !strings.Contains(msg, "no backtest records") {
+		t.Fatalf("without records: %q, %v", msg, err)
+	}
+
+	weak := &store.BacktestRecord{ScriptID: script.ID, SharpeRatio: 0.8, MaxDrawdown: 0.35, TotalActions: 10}
+	if err := st.SaveBacktestRecord(weak); err != nil {
+		t.Fatal(err)
+	}
+	_, msg, err := gate.check(st, script.ID)
+	if err != nil || !strings.Contains(msg, "sharpe 0.80 <= 1.00") || !strings.Contains(msg, "maxDrawdown 35.0% >= 30.0%") {
+		t.Fatalf("weak record: %q, %v", msg, err)
+	}
+
+	good := &store.BacktestRecord{ScriptID: script.ID, SharpeRatio: 1.5, MaxDrawdown: 0.2, TotalActions: 10}
+	if err := st.SaveBacktestRecord(good); err != nil {
+		t.Fatal(err)
+	}
+	if record, msg, err := gate.check(st, script.ID); err != nil || msg != "" || record == nil || record.ID != good.ID {
+		t.Fatalf("good record: %v, %q, %v", record, msg, err)
+	}
+
+	cfg.Set("mcp.stableGate.minTrades", 20)
+	if _, msg, _ := stableGateFromConfig(cfg).check(st, script.ID); !strings.Contains(msg, "trades 10 < 20") {
+		t.Fatalf("minTrades: %q", msg)
+	}
+	cfg.Set("mcp.stableGate.enabled", false)
+	if _, msg, _ := stableGateFromConfig(cfg).check(st, script.ID); msg != "" {
+		t.Fatalf("disabled gate: %q", msg)
+	}
+
+	if !promotesToStable(script, map[string]interface{}{"lifecycle_status": "stable"}) {
+		t.Error("promotion to stable not detected")
+	}
+	if promotesToStable(script, map[string]interface{}{"lifecycle_status": "testing"}) {
+		t.Error("promotion to testing gated")
+	}
+}
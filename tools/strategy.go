@@ -4,18 +4,26 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"go/format"
+	"strconv"
 	"strings"
 	"text/template"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
+	"github.com/spf13/viper"
+	"github.com/ztrade/ztrade-mcp/internal/indicatorspec"
 	"github.com/ztrade/ztrade-mcp/store"
 )
 
 const strategyTemplate = `package strategy
 
 import (
+{{- if .RiskControls}}
+	"math"
+{{end}}
 	. "github.com/ztrade/trademodel"
 )
 
@@ -24,6 +32,11 @@ type {{.Name}} struct {
 	engine   Engine
 	position float64
 {{range .Fields}}	{{.Name}} {{.Type}}
+{{end}}{{if .RiskControls}}
+	// protective orders placed for the current position
+	entryPrice  float64
+	stopOrder   string
+	profitOrder string
 {{end}}}
 
 func New{{.Name}}() *{{.Name}} {
@@ -51,6 +64,38 @@ func (s *{{.Name}}) OnCandle(candle *Candle) {
 
 func (s *{{.Name}}) OnPosition(pos, price float64) {
 	s.position = pos
+{{- if .RiskControls}}
+	// replace the protective orders for the new position
+	if s.stopOrder != "" {
+		s.engine.CancelOrder(s.stopOrder)
+		s.stopOrder = ""
+	}
+	if s.profitOrder != "" {
+		s.engine.CancelOrder(s.profitOrder)
+		s.profitOrder = ""
+	}
+	if pos == 0 {
+		s.entryPrice = 0
+		return
+	}
+	s.entryPrice = price
+	amount := math.Abs(pos)
+	if pos > 0 {
+{{- if .StopLoss}}
+		s.stopOrder = s.engine.StopLong(price*(1-s.stopLossPercent/100), amount)
+{{- end}}
+{{- if .TakeProfit}}
+		s.profitOrder = s.engine.CloseLong(price*(1+s.takeProfitPercent/100), amount)
+{{- end}}
+	} else {
+{{- if .StopLoss}}
+		s.stopOrder = s.engine.StopShort(price*(1+s.stopLossPercent/100), amount)
+{{- end}}
+{{- if .TakeProfit}}
+		s.profitOrder = s.engine.CloseShort(price*(1-s.takeProfitPercent/100), amount)
+{{- end}}
+	}
+{{- end}}
 }
 
 func (s *{{.Name}}) OnTrade(trade *Trade) {
@@ -75,6 +120,10 @@ type strategyData struct {
 	Params      []paramData
 	Indicators  []indicatorData
 	Merges      []mergeData
+	// StopLoss and TakeProfit add protective orders in OnPosition.
+	StopLoss     bool
+	TakeProfit   bool
+	RiskControls bool
 }
 
 type fieldData struct {
@@ -100,7 +149,7 @@ type mergeData struct {
 	Suffix string
 }
 
-func registerCreateStrategy(s *server.MCPServer, st *store.Store) {
+func registerCreateStrategy(s *server.MCPServer, cfg *viper.Viper, st *store.Store) {
 	tool := mcp.NewTool("create_strategy",
 		mcp.WithDescription("Create and save a strategy script to the database. Two modes: "+
 			"1) Provide 'content' directly to save existing source code. "+
@@ -110,13 +159,23 @@ func registerCreateStrategy(s *server.MCPServer, st *store.Store) {
 		mcp.WithString("content", mcp.Description("Full strategy source code (Go code). If provided, saves directly without template generation.")),
 		mcp.WithString("description", mcp.Description("Brief description of the strategy")),
 		mcp.WithString("tags", mcp.Description("Comma-separated tags (e.g., 'trend,ema,momentum')")),
-		mcp.WithString("lifecycleStatus", mcp.Description("Lifecycle status: research, development, testing, stable. Default: research")),
+		mcp.WithString("lifecycleStatus", mcp.Description("Lifecycle status: research, development, testing, stable. Default: research. stable is refused while the stable gate is on, since a new strategy has no backtest records; promote it with update_strategy_meta")),
 		mcp.WithString("fieldDescriptions", mcp.Description("Detailed field-level descriptions. Suggested JSON object keyed by field/param name.")),
 		mcp.WithString("indicators",
 			mcp.Description("(Template mode only) Comma-separated indicators to include. "+
 				"Format: NAME(params). Examples: EMA(9,26), MACD(12,26,9), BOLL(20,2), RSI(14), STOCHRSI(14,14,3,3)")),
 		mcp.WithString("periods",
 			mcp.Description("(Template mode only) Comma-separated K-line periods to merge. Examples: 5m,15m,1h")),
+		mcp.WithBoolean("tunableIndicators",
+			mcp.Description("(Template mode only) Promote each indicator's integer arguments to IntParam fields (e.g. EMA(9,26) -> emaFast, emaSlow) used in Init, so they can be tuned with the backtest param JSON. Default: false")),
+		mcp.WithNumber("stopLossPercent",
+			mcp.Description("(Template mode only) Add a stop-loss: whenever the position changes, OnPosition places a StopLong/StopShort this many percent beyond the entry price. Becomes the default of a stopLossPercent param.")),
+		mcp.WithNumber("takeProfitPercent",
+			mcp.Description("(Template mode only) Add a take-profit: whenever the position changes, OnPosition places a closing limit order this many percent in profit from the entry price. Becomes the default of a takeProfitPercent param.")),
+		mcp.WithString("onConflict",
+			mcp.Description("What to do when a strategy with this name exists: 'error' (default), 'update' (save the content as a new version of it) or 'skip' (return it unchanged). The result's 'action' is created, updated or skipped.")),
+		mcp.WithBoolean("shared", mcp.Description("Let other users see (but not modify) the new strategy. Strategies are private to the creating user by default.")),
+		mcp.WithBoolean("format", mcp.Description("Run the content through gofmt before saving; content that does not parse is saved as given with a warning. Default: true")),
 	)
 
 	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -128,6 +187,19 @@ func registerCreateStrategy(s *server.MCPServer, st *store.Store) {
 		fieldDescriptions := req.GetString("fieldDescriptions", "")
 		indicators := req.GetString("indicators", "")
 		periods := req.GetString("periods", "")
+		tunable := req.GetBool("tunableIndicators", false)
+		stopLoss := req.GetFloat("stopLossPercent", 0)
+		takeProfit := req.GetFloat("takeProfitPercent", 0)
+		if stopLoss < 0 || stopLoss >= 100 || takeProfit < 0 {
+			return toolError(codeInvalidArgument, "stopLossPercent must be in [0, 100) and takeProfitPercent must not be negative"), nil
+		}
+		if lifecycleStatus == store.StrategyLifecycleStable && stableGateFromConfig(cfg).Enabled {
+			return toolError(codeFailedPrecondition, "a new strategy has no backtest records to pass the stable gate; create it in testing and promote it with update_strategy_meta after run_backtest_managed"), nil
+		}
+		onConflict := req.GetString("onConflict", "error")
+		if onConflict != "error" && onConflict != "update" && onConflict != "skip" {
+			return toolErrorf(codeInvalidArgument, "invalid onConflict %s, supported: error, update, skip", onConflict), nil
+		}
 
 		if description == "" {
 			description = name + " strategy"
@@ -142,15 +214,17 @@ func registerCreateStrategy(s *server.MCPServer, st *store.Store) {
 			}
 
 			// Parse indicators
-			if indicators != "" {
-				for _, ind := range strings.Split(indicators, ",") {
-					ind = strings.TrimSpace(ind)
-					if ind == "" {
-						continue
-					}
-					args := parseIndicator(ind)
-					data.Indicators = append(data.Indicators, indicatorData{Args: args})
+			usedParams := map[string]bool{}
+			for _, ind := range splitIndicators(indicators) {
+				indName, args := splitIndicatorSpec(ind)
+				if err := indicatorspec.Check(indName, len(args)); err != nil {
+					return toolErrorf(codeInvalidArgument, "invalid indicator %s: %s (see list_indicators)", ind, err.Error()), nil
 				}
+				if !tunable {
+					data.Indicators = append(data.Indicators, indicatorData{Args: parseIndicator(ind)})
+					continue
+				}
+				addTunableIndicator(&data, ind, usedParams)
 			}
 
 			// Parse merge periods
@@ -167,17 +241,24 @@ func registerCreateStrategy(s *server.MCPServer, st *store.Store) {
 				}
 			}
 
-			tmpl, err := template.New("strategy").Parse(strategyTemplate)
-			if err != nil {
-				return mcp.NewToolResultError(fmt.Sprintf("template parse error: %s", err.Error())), nil
-			}
+			addRiskControls(&data, stopLoss, takeProfit)
 
-			var buf bytes.Buffer
-			err = tmpl.Execute(&buf, data)
+			var err error
+			content, err = renderStrategyTemplate(data)
 			if err != nil {
-				return mcp.NewToolResultError(fmt.Sprintf("template execution error: %s", err.Error())), nil
+				return toolError(codeInternal, err.Error()), nil
+			}
+			if err := checkMergeTimeframes(content, nil); err != nil {
+				return toolErrorf(codeInvalidArgument, "invalid periods: %s", err.Error()), nil
 			}
-			content = buf.String()
+		}
+
+		var formatWarning string
+		if req.GetBool("format", true) {
+			content, formatWarning = formatStrategySource(content)
+		}
+		if err := checkStrategyImports(cfg, content); err != nil {
+			return toolError(codeInvalidArgument, err.Error()), nil
 		}
 
 		// Save to database
@@ -185,8 +266,11 @@ func registerCreateStrategy(s *server.MCPServer, st *store.Store) {
 			"status": "success",
 			"name":   name,
 		}
+		if formatWarning != "" {
+			result["warning"] = formatWarning
+		}
 		if st == nil {
-			return mcp.NewToolResultError("script store not initialized (check database config)"), nil
+			return toolError(codeUnavailable, "script store not initialized (check database config)"), nil
 		}
 		script := &store.Script{
 			Name:              name,
@@ -197,17 +281,211 @@ func registerCreateStrategy(s *server.MCPServer, st *store.Store) {
 			LifecycleStatus:   lifecycleStatus,
 			FieldDescriptions: fieldDescriptions,
 		}
-		if err := st.CreateScript(script); err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("failed to save script: %s", err.Error())), nil
+		owner, quota := callerQuota(ctx, cfg)
+		script.Owner = owner
+		script.Shared = req.GetBool("shared", false)
+		if onConflict == "update" {
+			existing, err := st.GetScriptByName(name)
+			if err == nil {
+				err = checkScriptAccess(ctx, existing, true)
+			}
+			if err != nil && !errors.Is(err, store.ErrNotFound) {
+				return toolErrorf(storeErrorCode(err), "failed to save script: %s", err.Error()), nil
+			}
 		}
+		action, script, err := saveStrategy(st, script, onConflict, quota)
+		if err != nil {
+			return toolErrorf(strategyConflictCode(err), "failed to save script: %s", err.Error()), nil
+		}
+		result["action"] = action
 		result["id"] = script.ID
 		result["version"] = script.Version
+		result["hasStopLoss"] = script.StopLoss()
+		result["tags"] = script.Tags
 
 		resultJSON, _ := json.MarshalIndent(result, "", "  ")
 		return mcp.NewToolResultText(string(resultJSON)), nil
 	})
 }
 
+// errStrategyExists is returned by saveStrategy for an existing name under
+// onConflict=error.
+var errStrategyExists = errors.New("strategy already exists")
+
+// saveStrategy creates script, or resolves a name clash with an existing
+// strategy as onConflict says: "update" saves the content as a new version
+// of it, "skip" leaves it unchanged, anything else fails with
+// errStrategyExists. It returns created, updated or skipped and the stored
+// script. A new script or version must fit quota of script.Owner.
+func saveStrategy(st *store.Store, script *store.Script, onConflict string, quota store.ScriptQuota) (string, *store.Script, error) {
+	existing, err := st.GetScriptByName(script.Name)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return "", nil, err
+	}
+	if existing == nil {
+		if err := st.CreateScriptWithQuota(script, quota); err != nil {
+			return "", nil, err
+		}
+		return "created", script, nil
+	}
+	switch onConflict {
+	case "skip":
+		return "skipped", existing, nil
+	case "update":
+		if existing.Owner != script.Owner {
+			quota = store.ScriptQuota{}
+		}
+		updated, err := st.UpdateScriptWithQuota(existing.ID, script.Content, "updated by create_strategy", quota)
+		if err != nil {
+			return "", nil, err
+		}
+		return "updated", updated, nil
+	}
+	return "", nil, fmt.Errorf("%w: %s has id %d; pass onConflict=update to save a new version or onConflict=skip to keep it", errStrategyExists, script.Name, existing.ID)
+}
+
+// strategyConflictCode is storeErrorCode that also maps errStrategyExists.
+func strategyConflictCode(err error) string {
+	if errors.Is(err, errStrategyExists) {
+		return codeFailedPrecondition
+	}
+	return storeErrorCode(err)
+}
+
+// addRiskControls adds the stop-loss / take-profit params to data; zero
+// percentages leave them out.
+func addRiskControls(data *strategyData, stopLoss, takeProfit float64) {
+	if stopLoss > 0 {
+		data.StopLoss = true
+		data.Fields = append(data.Fields, fieldData{Name: "stopLossPercent", Type: "float64"})
+		data.Params = append(data.Params, paramData{
+			Key: "stopLossPercent", Label: "Stop loss %", Desc: "Stop-loss distance from the entry price, in percent",
+			Default: fmt.Sprintf("%g", stopLoss), FieldName: "stopLossPercent", ParamFunc: "FloatParam",
+		})
+	}
+	if takeProfit > 0 {
+		data.TakeProfit = true
+		data.Fields = append(data.Fields, fieldData{Name: "takeProfitPercent", Type: "float64"})
+		data.Params = append(data.Params, paramData{
+			Key: "takeProfitPercent", Label: "Take profit %", Desc: "Take-profit distance from the entry price, in percent",
+			Default: fmt.Sprintf("%g", takeProfit), FieldName: "takeProfitPercent", ParamFunc: "FloatParam",
+		})
+	}
+	data.RiskControls = data.StopLoss || data.TakeProfit
+}
+
+// renderStrategyTemplate generates the gofmt-ed strategy skeleton for data.
+func renderStrategyTemplate(data strategyData) (string, error) {
+	tmpl, err := template.New("strategy").Parse(strategyTemplate)
+	if err != nil {
+		return "", fmt.Errorf("template parse error: %s", err.Error())
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("template execution error: %s", err.Error())
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		// leave invalid code (e.g. from odd indicator args) for the user to fix
+		return buf.String(), nil
+	}
+	return string(src), nil
+}
+
+// splitIndicators splits a comma-separated indicator list, keeping the
+// commas inside an indicator's parentheses: "EMA(9,26), RSI(14)" gives
+// "EMA(9,26)" and "RSI(14)".
+func splitIndicators(list string) []string {
+	var out []string
+	depth, start := 0, 0
+	flush := func(end int) {
+		if ind := strings.TrimSpace(list[start:end]); ind != "" {
+			out = append(out, ind)
+		}
+	}
+	for i, r := range list {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				flush(i)
+				start = i + 1
+			}
+		}
+	}
+	flush(len(list))
+	return out
+}
+
+// indicatorParamNames returns the capitalized argument names of a known
+// indicator for its promoted params, or nil; others are numbered.
+func indicatorParamNames(name string) []string {
+	spec := indicatorspec.Lookup(name)
+	if spec == nil {
+		return nil
+	}
+	names := make([]string, len(spec.Args))
+	for i, a := range spec.Args {
+		names[i] = strings.ToUpper(a.Name[:1]) + a.Name[1:]
+	}
+	return names
+}
+
+// addTunableIndicator adds spec (e.g. "EMA(9,26)") to data with each integer
+// argument bound to an IntParam field. used holds the field names taken so
+// far; a repeated name gets a numeric suffix, so EMA(9,26) and EMA(50,200)
+// give emaFast, emaSlow, emaFast2, emaSlow2.
+func addTunableIndicator(data *strategyData, spec string, used map[string]bool) {
+	name, args := splitIndicatorSpec(spec)
+	upper := strings.ToUpper(name)
+	names := indicatorParamNames(upper)
+	if len(args) == 1 && len(names) > 1 {
+		names = []string{"Period"}
+	}
+
+	callArgs := []string{fmt.Sprintf("%q", name)}
+	for i, a := range args {
+		if _, err := strconv.Atoi(a); err != nil {
+			callArgs = append(callArgs, a)
+			continue
+		}
+		suffix := fmt.Sprintf("Arg%d", i+1)
+		if i < len(names) {
+			suffix = names[i]
+		}
+		base := strings.ToLower(upper) + suffix
+		field := base
+		for n := 2; used[field]; n++ {
+			field = fmt.Sprintf("%s%d", base, n)
+		}
+		used[field] = true
+		data.Fields = append(data.Fields, fieldData{Name: field, Type: "int"})
+		data.Params = append(data.Params, paramData{
+			Key: field, Label: upper + " " + strings.ToLower(suffix), Desc: fmt.Sprintf("%s argument %d", upper, i+1),
+			Default: a, FieldName: field, ParamFunc: "IntParam",
+		})
+		callArgs = append(callArgs, "s."+field)
+	}
+	data.Indicators = append(data.Indicators, indicatorData{Args: strings.Join(callArgs, ", ")})
+}
+
+// splitIndicatorSpec splits "EMA(9, 26)" into its name and arguments.
+func splitIndicatorSpec(spec string) (string, []string) {
+	name, args := strings.TrimSpace(spec), []string(nil)
+	if idx := strings.Index(spec, "("); idx != -1 {
+		name = strings.TrimSpace(spec[:idx])
+		for _, a := range strings.Split(strings.TrimSuffix(strings.TrimSpace(spec[idx+1:]), ")"), ",") {
+			if a = strings.TrimSpace(a); a != "" {
+				args = append(args, a)
+			}
+		}
+	}
+	return name, args
+}
+
 // parseIndicator converts "EMA(9,26)" to `"EMA", 9, 26`
 func parseIndicator(s string) string {
 	idx := strings.Index(s, "(")
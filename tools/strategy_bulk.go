@@ -0,0 +1,232 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/spf13/viper"
+	"github.com/ztrade/ztrade-mcp/auth"
+	"github.com/ztrade/ztrade-mcp/store"
+)
+
+// maxBulkStrategies caps the strategies one bulk_update_strategy_meta call
+// may touch.
+const maxBulkStrategies = 500
+
+// splitTags splits a comma-separated tag list, dropping empty entries.
+func splitTags(tags string) []string {
+	var ret []string
+	for _, t := range strings.Split(tags, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			ret = append(ret, t)
+		}
+	}
+	return ret
+}
+
+// editTags adds and removes tags from a comma-separated tag list, keeping
+// the order of the existing ones. It reports whether the list changed.
+func editTags(tags string, add, remove []string) (string, bool) {
+	drop := map[string]bool{}
+	for _, t := range remove {
+		drop[t] = true
+	}
+	var out []string
+	seen := map[string]bool{}
+	for _, t := range append(splitTags(tags), add...) {
+		if drop[t] || seen[t] {
+			continue
+		}
+		seen[t] = true
+		out = append(out, t)
+	}
+	edited := strings.Join(out, ",")
+	return edited, edited != strings.Join(splitTags(tags), ",")
+}
+
+// parseStrategyIDs parses a comma-separated list of strategy IDs.
+func parseStrategyIDs(s string) ([]int64, error) {
+	var ids []int64
+	seen := map[int64]bool{}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(part, 10, 64)
+		if err != nil || id <= 0 {
+			return nil, fmt.Errorf("invalid strategy id %q", part)
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func registerBulkUpdateStrategyMeta(s *server.MCPServer, cfg *viper.Viper, st *store.Store) {
+	tool := mcp.NewTool("bulk_update_strategy_meta",
+		mcp.WithDescription("Apply the same metadata change to many strategies at once: add or remove tags, set status or lifecycleStatus. Strategies are picked by 'ids' or, without ids, by a filter (status, lifecycleStatus, keyword, idleDays). Each strategy is updated on its own like update_strategy_meta, including the stable lock, stable gate and ownership rules, and the result lists success or the error per id. Use dryRun to preview the matches."),
+		mcp.WithString("ids", mcp.Description(fmt.Sprintf("Comma-separated strategy IDs, up to %d. When set, the filter arguments are ignored.", maxBulkStrategies))),
+		mcp.WithString("filterStatus", mcp.Description("Filter: status active or archived. Default: all non-deleted")),
+		mcp.WithString("filterLifecycleStatus", mcp.Description("Filter: lifecycle status research, development, testing or stable")),
+		mcp.WithString("keyword", mcp.Description("Filter: keyword in name, description or tags")),
+		mcp.WithNumber("idleDays", mcp.Description("Filter: only strategies without a backtest run in the last idleDays days (including never backtested ones)")),
+		mcp.WithString("addTags", mcp.Description("Comma-separated tags to add")),
+		mcp.WithString("removeTags", mcp.Description("Comma-separated tags to remove")),
+		mcp.WithString("status", mcp.Description("New status: active, archived")),
+		mcp.WithString("lifecycleStatus", mcp.Description("New lifecycle status: research, development, testing, stable")),
+		mcp.WithBoolean("dryRun", mcp.Description("Only list the matched strategies and the changes, without applying them. Default: false")),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if st == nil {
+			return toolError(codeUnavailable, "script store not initialized (check database config)"), nil
+		}
+
+		addTags := splitTags(req.GetString("addTags", ""))
+		removeTags := splitTags(req.GetString("removeTags", ""))
+		status := req.GetString("status", "")
+		if status != "" && status != "active" && status != "archived" {
+			return toolError(codeInvalidArgument, "status must be 'active' or 'archived'"), nil
+		}
+		lifecycleStatus := req.GetString("lifecycleStatus", "")
+		if lifecycleStatus != "" && !store.IsValidStrategyLifecycleStatus(lifecycleStatus) {
+			return toolError(codeInvalidArgument, "lifecycleStatus must be one of: research, development, testing, stable"), nil
+		}
+		if len(addTags) == 0 && len(removeTags) == 0 && status == "" && lifecycleStatus == "" {
+			return toolError(codeInvalidArgument, "at least one of addTags, removeTags, status, lifecycleStatus must be provided"), nil
+		}
+		dryRun := req.GetBool("dryRun", false)
+
+		var scripts []store.Script
+		results := []map[string]interface{}{}
+		failed := 0
+		ids, err := parseStrategyIDs(req.GetString("ids", ""))
+		if err != nil {
+			return toolError(codeInvalidArgument, err.Error()), nil
+		}
+		if len(ids) > 0 {
+			if len(ids) > maxBulkStrategies {
+				return toolErrorf(codeInvalidArgument, "at most %d ids per call", maxBulkStrategies), nil
+			}
+			for _, id := range ids {
+				script, err := accessibleScript(ctx, st, id, true)
+				if err != nil {
+					results = append(results, map[string]interface{}{"id": id, "status": "failed", "code": storeErrorCode(err), "error": err.Error()})
+					failed++
+					continue
+				}
+				scripts = append(scripts, *script)
+			}
+		} else {
+			filter := store.ScriptFilter{
+				Status:          req.GetString("filterStatus", ""),
+				LifecycleStatus: req.GetString("filterLifecycleStatus", ""),
+				Keyword:         req.GetString("keyword", ""),
+				VisibleTo:       auth.ScopedUser(ctx),
+				SortBy:          store.ScriptSortName,
+				Asc:             true,
+			}
+			if filter.Status != "" && filter.Status != "active" && filter.Status != "archived" {
+				return toolError(codeInvalidArgument, "filterStatus must be 'active' or 'archived'"), nil
+			}
+			if filter.LifecycleStatus != "" && !store.IsValidStrategyLifecycleStatus(filter.LifecycleStatus) {
+				return toolError(codeInvalidArgument, "filterLifecycleStatus must be one of: research, development, testing, stable"), nil
+			}
+			idleDays := req.GetFloat("idleDays", 0)
+			if idleDays < 0 {
+				return toolError(codeInvalidArgument, "idleDays must not be negative"), nil
+			}
+			if idleDays > 0 {
+				filter.IdleSince = time.Now().Add(-time.Duration(idleDays * float64(24*time.Hour)))
+			}
+			if filter.Status == "" && filter.LifecycleStatus == "" && filter.Keyword == "" && filter.IdleSince.IsZero() {
+				return toolError(codeInvalidArgument, "provide ids or at least one filter (filterStatus, filterLifecycleStatus, keyword, idleDays)"), nil
+			}
+			var total int64
+			scripts, total, err = st.ListScripts(filter)
+			if err != nil {
+				return toolErrorf(codeInternal, "failed to list scripts: %s", err.Error()), nil
+			}
+			if total > maxBulkStrategies {
+				return toolErrorf(codeInvalidArgument, "the filter matches %d strategies, more than %d; narrow it down", total, maxBulkStrategies), nil
+			}
+		}
+
+		gate := stableGateFromConfig(cfg)
+		updated := 0
+		for _, sc := range scripts {
+			fields := make(map[string]interface{})
+			if len(addTags) > 0 || len(removeTags) > 0 {
+				if tags, changed := editTags(sc.Tags, addTags, removeTags); changed {
+					fields["tags"] = tags
+				}
+			}
+			if status != "" && status != sc.Status {
+				fields["status"] = status
+			}
+			if lifecycleStatus != "" && lifecycleStatus != sc.LifecycleStatus {
+				fields["lifecycle_status"] = lifecycleStatus
+			}
+			entry := map[string]interface{}{"id": sc.ID, "name": sc.Name}
+			// a filter also matches strategies shared by other users
+			accessErr := checkScriptAccess(ctx, &sc, true)
+			switch {
+			case accessErr != nil:
+				entry["status"], entry["code"], entry["error"] = "failed", storeErrorCode(accessErr), accessErr.Error()
+				failed++
+			case len(fields) == 0:
+				entry["status"] = "unchanged"
+			case stableLockViolation(&sc, fields) != "":
+				entry["status"], entry["code"], entry["error"] = "failed", codeFailedPrecondition, stableLockViolation(&sc, fields)
+				failed++
+			case promotesToStable(&sc, fields) && stableGateFailure(gate, st, sc.ID, entry):
+				failed++
+			case dryRun:
+				entry["status"], entry["changes"] = "would_update", fields
+			default:
+				if err := st.UpdateScriptMeta(sc.ID, fields); err != nil {
+					entry["status"], entry["code"], entry["error"] = "failed", codeInternal, err.Error()
+					failed++
+				} else {
+					entry["status"], entry["changes"] = "updated", fields
+					updated++
+				}
+			}
+			results = append(results, entry)
+		}
+
+		result := map[string]interface{}{
+			"matched": len(scripts),
+			"updated": updated,
+			"failed":  failed,
+			"dryRun":  dryRun,
+			"results": results,
+		}
+		data, _ := json.MarshalIndent(result, "", "  ")
+		return mcp.NewToolResultText(string(data)), nil
+	})
+}
+
+// stableGateFailure checks gate for a bulk entry promoting a strategy to
+// stable and records the failure on entry.
+func stableGateFailure(gate stableGate, st *store.Store, id int64, entry map[string]interface{}) bool {
+	_, msg, err := gate.check(st, id)
+	switch {
+	case err != nil:
+		entry["status"], entry["code"], entry["error"] = "failed", codeInternal, err.Error()
+	case msg != "":
+		entry["status"], entry["code"], entry["error"] = "failed", codeFailedPrecondition, msg
+	default:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,37 @@
+package tools
+
+import (
+	"testing"
+)
+
+func TestEditTags(t *testing.T) {
+	cases := []struct {
+		tags, add, remove string
+		want              string
+		changed           bool
+	}{
+		{"trend,ema", "old", "", "trend,ema,old", true},
+		{"trend, ema", "ema", "", "trend,ema", false},
+		{"trend,ema,old", "", "old,missing", "trend,ema", true},
+		{"", "a,b,a", "", "a,b", true},
+		{"a", "b", "b", "a", false},
+	}
+	for _, c := range cases {
+		got, changed := editTags(c.tags, splitTags(c.add), splitTags(c.remove))
+		if got != c.want || changed != c.changed {
+			t.Errorf("editTags(%q, +%q, -%q) = %q, %v; want %q, %v", c.tags, c.add, c.remove, got, changed, c.want, c.changed)
+		}
+	}
+}
+
+func TestParseStrategyIDs(t *testing.T) {
+	ids, err := parseStrategyIDs(" 3, 1,3,,7 ")
+	if err != nil || len(ids) != 3 || ids[0] != 3 || ids[1] != 1 || ids[2] != 7 {
+		t.Fatalf("ids = %v, %v", ids, err)
+	}
+	for _, bad := range []string{"1,x", "0", "-2"} {
+		if _, err := parseStrategyIDs(bad); err == nil {
+			t.Errorf("%q: expected error", bad)
+		}
+	}
+}
@@ -3,23 +3,26 @@ package tools
 import (
 	"context"
 	"encoding/json"
-	"fmt"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"github.com/ztrade/ztrade-mcp/auth"
 	"github.com/ztrade/ztrade-mcp/store"
 )
 
-func registerGetStrategy(s *server.MCPServer, st *store.Store) {
+func registerGetStrategy(s *server.MCPServer, st *store.Store, cfg *viper.Viper) {
 	tool := mcp.NewTool("get_strategy",
-		mcp.WithDescription("Retrieve a strategy by ID or name. Returns full strategy content and metadata."),
+		mcp.WithDescription("Retrieve a strategy by ID or name. Returns full strategy content and metadata. Non-admin callers can only get their own, shared and ownerless strategies."),
 		mcp.WithNumber("id", mcp.Description("Strategy ID")),
 		mcp.WithString("name", mcp.Description("Strategy name. Used if id is not provided.")),
+		mcp.WithString("outputPath", mcp.Description("Write the raw content to this file (relative to or inside mcp.workDir) and return the path and byte count instead of the content.")),
 	)
 
 	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		if st == nil {
-			return mcp.NewToolResultError("script store not initialized (check database config)"), nil
+			return toolError(codeUnavailable, "script store not initialized (check database config)"), nil
 		}
 
 		idF := req.GetFloat("id", 0)
@@ -33,11 +36,30 @@ func registerGetStrategy(s *server.MCPServer, st *store.Store) {
 		} else if name != "" {
 			script, err = st.GetScriptByName(name)
 		} else {
-			return mcp.NewToolResultError("either 'id' or 'name' must be provided"), nil
+			return toolError(codeInvalidArgument, "either 'id' or 'name' must be provided"), nil
 		}
 
+		if err == nil {
+			err = checkScriptAccess(ctx, script, false)
+		}
 		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("failed to get script: %s", err.Error())), nil
+			return toolErrorf(storeErrorCode(err), "failed to get script: %s", err.Error()), nil
+		}
+
+		if outputPath := req.GetString("outputPath", ""); outputPath != "" {
+			path, err := writeWorkDirFile(cfg, outputPath, script.Content)
+			if err != nil {
+				return toolErrorf(storeErrorCode(err), "failed to export content: %s", err.Error()), nil
+			}
+			exported := *script
+			exported.Content = ""
+			result := map[string]interface{}{
+				"script":     exported,
+				"outputPath": path,
+				"bytes":      len(script.Content),
+			}
+			data, _ := json.MarshalIndent(result, "", "  ")
+			return mcp.NewToolResultText(string(data)), nil
 		}
 
 		data, _ := json.MarshalIndent(script, "", "  ")
@@ -47,42 +69,89 @@ func registerGetStrategy(s *server.MCPServer, st *store.Store) {
 
 func registerListStrategies(s *server.MCPServer, st *store.Store) {
 	tool := mcp.NewTool("list_strategies",
-		mcp.WithDescription("List all strategies in the database with optional filters. Returns strategy metadata (without full content for brevity)."),
+		mcp.WithDescription("List all strategies in the database with optional filters. Returns strategy metadata (without full content for brevity). Non-admin callers see their own strategies plus shared and ownerless ones; admins see all."),
 		mcp.WithString("status", mcp.Description("Filter by status: active, archived, deleted. Default: show all non-deleted.")),
 		mcp.WithString("lifecycleStatus", mcp.Description("Filter by lifecycle status: research, development, testing, stable.")),
 		mcp.WithString("keyword", mcp.Description("Search keyword to filter by name, description, or tags.")),
+		mcp.WithString("hasStopLoss", mcp.Description("Filter by detected stop-loss usage: true or false. Strategies whose source could not be analyzed match neither. Default: no filter.")),
+		mcp.WithString("sortBy", mcp.Description("Sort key: name, createdAt, updatedAt (default) or bestScore (highest backtest overallScore; strategies without backtests last).")),
+		mcp.WithString("order", mcp.Description("Sort order: asc or desc. Default: desc.")),
+		mcp.WithNumber("offset", mcp.Description("Number of strategies to skip. Default: 0")),
+		mcp.WithNumber("limit", mcp.Description("Maximum number of strategies to return. Default: all")),
 	)
 
 	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		if st == nil {
-			return mcp.NewToolResultError("script store not initialized (check database config)"), nil
+			return toolError(codeUnavailable, "script store not initialized (check database config)"), nil
 		}
 
-		status := req.GetString("status", "")
-		lifecycleStatus := req.GetString("lifecycleStatus", "")
-		keyword := req.GetString("keyword", "")
+		filter := store.ScriptFilter{
+			Status:          req.GetString("status", ""),
+			LifecycleStatus: req.GetString("lifecycleStatus", ""),
+			Keyword:         req.GetString("keyword", ""),
+			VisibleTo:       auth.ScopedUser(ctx),
+		}
+		switch v := req.GetString("hasStopLoss", ""); v {
+		case "":
+		case "true", "false":
+			has := v == "true"
+			filter.HasStopLoss = &has
+		default:
+			return toolError(codeInvalidArgument, "hasStopLoss must be 'true' or 'false'"), nil
+		}
+		filter.SortBy = req.GetString("sortBy", "")
+		if !store.IsValidScriptSort(filter.SortBy) {
+			return toolError(codeInvalidArgument, "sortBy must be one of name, createdAt, updatedAt, bestScore"), nil
+		}
+		switch order := req.GetString("order", "desc"); order {
+		case "asc", "desc":
+			filter.Asc = order == "asc"
+		default:
+			return toolError(codeInvalidArgument, "order must be 'asc' or 'desc'"), nil
+		}
+		filter.Offset = int(req.GetFloat("offset", 0))
+		filter.Limit = int(req.GetFloat("limit", 0))
+		if filter.Offset < 0 || filter.Limit < 0 {
+			return toolError(codeInvalidArgument, "offset and limit must not be negative"), nil
+		}
 
-		scripts, err := st.ListScripts(status, lifecycleStatus, keyword)
+		scripts, total, err := st.ListScripts(filter)
+		if err != nil {
+			return toolErrorf(codeInternal, "failed to list scripts: %s", err.Error()), nil
+		}
+		ids := make([]int64, 0, len(scripts))
+		for _, sc := range scripts {
+			ids = append(ids, sc.ID)
+		}
+		bestScores, err := st.BestScores(ids)
 		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("failed to list scripts: %s", err.Error())), nil
+			return toolErrorf(codeInternal, "failed to load best scores: %s", err.Error()), nil
 		}
 
 		// Return metadata only (omit full content for brevity)
 		type scriptSummary struct {
-			ID              int64  `json:"id"`
-			Name            string `json:"name"`
-			Description     string `json:"description"`
-			Tags            string `json:"tags"`
-			Status          string `json:"status"`
-			LifecycleStatus string `json:"lifecycleStatus"`
-			Version         int    `json:"version"`
-			Language        string `json:"language"`
-			CreatedAt       string `json:"createdAt"`
-			UpdatedAt       string `json:"updatedAt"`
+			ID              int64    `json:"id"`
+			Name            string   `json:"name"`
+			Description     string   `json:"description"`
+			Tags            string   `json:"tags"`
+			Status          string   `json:"status"`
+			LifecycleStatus string   `json:"lifecycleStatus"`
+			HasStopLoss     *bool    `json:"hasStopLoss"` // null when the source could not be analyzed
+			Version         int      `json:"version"`
+			Language        string   `json:"language"`
+			Owner           string   `json:"owner,omitempty"`
+			Shared          bool     `json:"shared"`
+			BestScore       *float64 `json:"bestScore"` // null without backtests
+			CreatedAt       string   `json:"createdAt"`
+			UpdatedAt       string   `json:"updatedAt"`
 		}
 
 		var summaries []scriptSummary
 		for _, sc := range scripts {
+			var best *float64
+			if v, ok := bestScores[sc.ID]; ok {
+				best = &v
+			}
 			summaries = append(summaries, scriptSummary{
 				ID:              sc.ID,
 				Name:            sc.Name,
@@ -90,33 +159,40 @@ func registerListStrategies(s *server.MCPServer, st *store.Store) {
 				Tags:            sc.Tags,
 				Status:          sc.Status,
 				LifecycleStatus: sc.LifecycleStatus,
+				HasStopLoss:     sc.StopLoss(),
 				Version:         sc.Version,
 				Language:        sc.Language,
+				Owner:           sc.Owner,
+				Shared:          sc.Shared,
+				BestScore:       best,
 				CreatedAt:       sc.CreatedAt.Format("2006-01-02 15:04:05"),
 				UpdatedAt:       sc.UpdatedAt.Format("2006-01-02 15:04:05"),
 			})
 		}
 
 		result := map[string]interface{}{
-			"total":   len(summaries),
-			"scripts": summaries,
+			"total":    total,
+			"offset":   filter.Offset,
+			"returned": len(summaries),
+			"scripts":  summaries,
 		}
 		data, _ := json.MarshalIndent(result, "", "  ")
 		return mcp.NewToolResultText(string(data)), nil
 	})
 }
 
-func registerUpdateStrategy(s *server.MCPServer, st *store.Store) {
+func registerUpdateStrategy(s *server.MCPServer, cfg *viper.Viper, st *store.Store) {
 	tool := mcp.NewTool("update_strategy",
-		mcp.WithDescription("Update a strategy's content. Automatically creates a new version. Use update_strategy_meta for metadata changes."),
+		mcp.WithDescription("Update a strategy's content. Automatically creates a new version. Use update_strategy_meta for metadata changes. Only the owner (or an admin) may update a strategy."),
 		mcp.WithNumber("id", mcp.Required(), mcp.Description("Strategy ID to update")),
 		mcp.WithString("content", mcp.Required(), mcp.Description("New strategy content (full source code)")),
 		mcp.WithString("message", mcp.Description("Version message describing the change (e.g., 'optimize EMA parameters')")),
+		mcp.WithBoolean("format", mcp.Description("Run the content through gofmt before saving; content that does not parse is saved as given with a warning. Default: true")),
 	)
 
 	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		if st == nil {
-			return mcp.NewToolResultError("script store not initialized (check database config)"), nil
+			return toolError(codeUnavailable, "script store not initialized (check database config)"), nil
 		}
 
 		id := int64(req.GetFloat("id", 0))
@@ -126,27 +202,44 @@ func registerUpdateStrategy(s *server.MCPServer, st *store.Store) {
 		if message == "" {
 			message = "update content"
 		}
+		var formatWarning string
+		if req.GetBool("format", true) {
+			content, formatWarning = formatStrategySource(content)
+		}
+		if err := checkStrategyImports(cfg, content); err != nil {
+			return toolError(codeInvalidArgument, err.Error()), nil
+		}
 
-		script, err := st.UpdateScript(id, content, message)
+		current, err := accessibleScript(ctx, st, id, true)
 		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("failed to update script: %s", err.Error())), nil
+			return toolErrorf(storeErrorCode(err), "failed to get script: %s", err.Error()), nil
+		}
+		// new versions count against the quota of their owner
+		script, err := st.UpdateScriptWithQuota(id, content, message, versionQuota(ctx, cfg, current))
+		if err != nil {
+			return toolErrorf(storeErrorCode(err), "failed to update script: %s", err.Error()), nil
 		}
 
 		result := map[string]interface{}{
-			"status":  "updated",
-			"id":      script.ID,
-			"name":    script.Name,
-			"version": script.Version,
-			"message": message,
+			"status":      "updated",
+			"id":          script.ID,
+			"name":        script.Name,
+			"version":     script.Version,
+			"message":     message,
+			"hasStopLoss": script.StopLoss(),
+			"tags":        script.Tags,
+		}
+		if formatWarning != "" {
+			result["warning"] = formatWarning
 		}
 		data, _ := json.MarshalIndent(result, "", "  ")
 		return mcp.NewToolResultText(string(data)), nil
 	})
 }
 
-func registerUpdateStrategyMeta(s *server.MCPServer, st *store.Store) {
+func registerUpdateStrategyMeta(s *server.MCPServer, cfg *viper.Viper, st *store.Store) {
 	tool := mcp.NewTool("update_strategy_meta",
-		mcp.WithDescription("Update a strategy's metadata (name, description, tags, status, lifecycleStatus, fieldDescriptions) without creating a new version. If a strategy is in lifecycleStatus=stable, you must first change lifecycleStatus to research/development/testing before editing other fields."),
+		mcp.WithDescription("Update a strategy's metadata (name, description, tags, status, lifecycleStatus, fieldDescriptions, shared) without creating a new version. Only the owner (or an admin) may update a strategy. If a strategy is in lifecycleStatus=stable, you must first change lifecycleStatus to research/development/testing before editing other fields. Moving to stable requires a backtest record of the strategy that passes the stable gate (by default sharpe > 1 and maxDrawdown < 30%)."),
 		mcp.WithNumber("id", mcp.Required(), mcp.Description("Strategy ID to update")),
 		mcp.WithString("name", mcp.Description("New strategy name")),
 		mcp.WithString("description", mcp.Description("New description")),
@@ -154,17 +247,18 @@ func registerUpdateStrategyMeta(s *server.MCPServer, st *store.Store) {
 		mcp.WithString("status", mcp.Description("New status: active, archived")),
 		mcp.WithString("lifecycleStatus", mcp.Description("Lifecycle status: research, development, testing, stable")),
 		mcp.WithString("fieldDescriptions", mcp.Description("Detailed field-level descriptions for the strategy. Recommended format: JSON object keyed by field/param name.")),
+		mcp.WithString("shared", mcp.Description("true to let other users see (but not modify) the strategy, false to make it private to its owner")),
 	)
 
 	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		if st == nil {
-			return mcp.NewToolResultError("script store not initialized (check database config)"), nil
+			return toolError(codeUnavailable, "script store not initialized (check database config)"), nil
 		}
 
 		id := int64(req.GetFloat("id", 0))
-		script, err := st.GetScript(id)
+		script, err := accessibleScript(ctx, st, id, true)
 		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("failed to get script: %s", err.Error())), nil
+			return toolErrorf(storeErrorCode(err), "failed to get script: %s", err.Error()), nil
 		}
 
 		fields := make(map[string]interface{})
@@ -179,38 +273,48 @@ func registerUpdateStrategyMeta(s *server.MCPServer, st *store.Store) {
 		}
 		if status := req.GetString("status", ""); status != "" {
 			if status != "active" && status != "archived" {
-				return mcp.NewToolResultError("status must be 'active' or 'archived'"), nil
+				return toolError(codeInvalidArgument, "status must be 'active' or 'archived'"), nil
 			}
 			fields["status"] = status
 		}
 		if lifecycleStatus := req.GetString("lifecycleStatus", ""); lifecycleStatus != "" {
 			if !store.IsValidStrategyLifecycleStatus(lifecycleStatus) {
-				return mcp.NewToolResultError("lifecycleStatus must be one of: research, development, testing, stable"), nil
+				return toolError(codeInvalidArgument, "lifecycleStatus must be one of: research, development, testing, stable"), nil
 			}
 			fields["lifecycle_status"] = lifecycleStatus
 		}
 		if fieldDescriptions := req.GetString("fieldDescriptions", ""); fieldDescriptions != "" {
 			fields["field_descriptions"] = fieldDescriptions
 		}
+		switch v := req.GetString("shared", ""); v {
+		case "":
+		case "true", "false":
+			fields["shared"] = v == "true"
+		default:
+			return toolError(codeInvalidArgument, "shared must be 'true' or 'false'"), nil
+		}
 
 		if len(fields) == 0 {
-			return mcp.NewToolResultError("at least one field must be provided to update"), nil
+			return toolError(codeInvalidArgument, "at least one field must be provided to update"), nil
 		}
 
-		// If strategy is stable, require lifecycle unlock first
-		if store.IsStrategyLockedForEdit(script.LifecycleStatus) {
-			nextLifecycle, hasLifecycle := fields["lifecycle_status"]
-			if !hasLifecycle || len(fields) != 1 {
-				return mcp.NewToolResultError("strategy is stable; update lifecycleStatus first (research/development/testing)"), nil
+		if msg := stableLockViolation(script, fields); msg != "" {
+			return toolError(codeFailedPrecondition, msg), nil
+		}
+		var gateRecord *store.BacktestRecord
+		if promotesToStable(script, fields) {
+			record, msg, err := stableGateFromConfig(cfg).check(st, id)
+			if err != nil {
+				return toolErrorf(codeInternal, "failed to list backtest records: %s", err.Error()), nil
 			}
-			ls, ok := nextLifecycle.(string)
-			if !ok || ls == store.StrategyLifecycleStable {
-				return mcp.NewToolResultError("strategy is stable; set lifecycleStatus to research/development/testing before other edits"), nil
+			if msg != "" {
+				return toolError(codeFailedPrecondition, msg), nil
 			}
+			gateRecord = record
 		}
 
 		if err := st.UpdateScriptMeta(id, fields); err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("failed to update script meta: %s", err.Error())), nil
+			return toolErrorf(codeInternal, "failed to update script meta: %s", err.Error()), nil
 		}
 
 		result := map[string]interface{}{
@@ -218,32 +322,53 @@ func registerUpdateStrategyMeta(s *server.MCPServer, st *store.Store) {
 			"id":      id,
 			"updated": fields,
 		}
+		if gateRecord != nil {
+			result["stableGateRecordId"] = gateRecord.ID
+		}
 		data, _ := json.MarshalIndent(result, "", "  ")
 		return mcp.NewToolResultText(string(data)), nil
 	})
 }
 
+// stableLockViolation returns why fields may not be applied to a stable
+// strategy, or "" when they may: a stable strategy has to be moved back to
+// research/development/testing, on its own, before other edits.
+func stableLockViolation(script *store.Script, fields map[string]interface{}) string {
+	if !store.IsStrategyLockedForEdit(script.LifecycleStatus) {
+		return ""
+	}
+	nextLifecycle, hasLifecycle := fields["lifecycle_status"]
+	if !hasLifecycle || len(fields) != 1 {
+		return "strategy is stable; update lifecycleStatus first (research/development/testing)"
+	}
+	ls, ok := nextLifecycle.(string)
+	if !ok || ls == store.StrategyLifecycleStable {
+		return "strategy is stable; set lifecycleStatus to research/development/testing before other edits"
+	}
+	return ""
+}
+
 func registerDeleteStrategy(s *server.MCPServer, st *store.Store) {
 	tool := mcp.NewTool("delete_strategy",
-		mcp.WithDescription("Soft-delete a strategy. The strategy is marked as 'deleted' but can still be queried if needed. Version history is preserved."),
+		mcp.WithDescription("Soft-delete a strategy. The strategy is marked as 'deleted' but can still be queried if needed. Version history is preserved. Only the owner (or an admin) may delete a strategy."),
 		mcp.WithNumber("id", mcp.Required(), mcp.Description("Strategy ID to delete")),
 	)
 
 	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		if st == nil {
-			return mcp.NewToolResultError("script store not initialized (check database config)"), nil
+			return toolError(codeUnavailable, "script store not initialized (check database config)"), nil
 		}
 
 		id := int64(req.GetFloat("id", 0))
 
 		// Verify the script exists
-		script, err := st.GetScript(id)
+		script, err := accessibleScript(ctx, st, id, true)
 		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("failed to find script: %s", err.Error())), nil
+			return toolErrorf(storeErrorCode(err), "failed to find script: %s", err.Error()), nil
 		}
 
 		if err := st.DeleteScript(id); err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("failed to delete script: %s", err.Error())), nil
+			return toolErrorf(storeErrorCode(err), "failed to delete script: %s", err.Error()), nil
 		}
 
 		result := map[string]interface{}{
@@ -255,3 +380,45 @@ func registerDeleteStrategy(s *server.MCPServer, st *store.Store) {
 		return mcp.NewToolResultText(string(data)), nil
 	})
 }
+
+func registerHardDeleteStrategy(s *server.MCPServer, st *store.Store) {
+	tool := mcp.NewTool("hard_delete_strategy",
+		mcp.WithDescription("Permanently delete a strategy and all of its data: versions, backtest records and their logs, equity curves, trades and position histories, the portfolio backtests with a leg of it, and its stopped live trades with their fills. This cannot be undone; use delete_strategy for a reversible soft delete. Refused while a live trade is running the strategy, in this process or persisted as running."),
+		mcp.WithNumber("id", mcp.Required(), mcp.Description("Strategy ID to delete")),
+		mcp.WithBoolean("confirm", mcp.Required(), mcp.Description("Must be true to confirm the permanent deletion")),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if st == nil {
+			return toolError(codeUnavailable, "script store not initialized (check database config)"), nil
+		}
+
+		id := int64(req.GetFloat("id", 0))
+		if !req.GetBool("confirm", false) {
+			return toolError(codeInvalidArgument, "hard delete is permanent; set confirm=true to proceed"), nil
+		}
+
+		script, err := accessibleScript(ctx, st, id, true)
+		if err != nil {
+			return toolErrorf(storeErrorCode(err), "failed to find script: %s", err.Error()), nil
+		}
+		if running := manager.tradesOfScript(id); len(running) > 0 {
+			return toolErrorf(codeFailedPrecondition, "strategy %d is used by running live trades %v; stop them first", id, running), nil
+		}
+
+		deleted, err := st.HardDeleteScript(id)
+		if err != nil {
+			return toolErrorf(codeInternal, "failed to delete script: %s", err.Error()), nil
+		}
+		log.WithContext(ctx).WithFields(log.Fields{"id": id, "name": script.Name, "deleted": deleted}).Warn("strategy hard deleted")
+
+		result := map[string]interface{}{
+			"status":  "hard_deleted",
+			"id":      id,
+			"name":    script.Name,
+			"deleted": deleted,
+		}
+		data, _ := json.MarshalIndent(result, "", "  ")
+		return mcp.NewToolResultText(string(data)), nil
+	})
+}
@@ -0,0 +1,98 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/spf13/viper"
+	"github.com/ztrade/ztrade-mcp/internal/strategysrc"
+	"github.com/ztrade/ztrade-mcp/store"
+)
+
+// strategyImportAllowlist returns the import patterns strategies may use:
+// mcp.strategyImports.allow, or strategysrc.DefaultAllowedImports.
+func strategyImportAllowlist(cfg *viper.Viper) []string {
+	if cfg != nil && cfg.IsSet("mcp.strategyImports.allow") {
+		return cfg.GetStringSlice("mcp.strategyImports.allow")
+	}
+	return strategysrc.DefaultAllowedImports
+}
+
+// errDisallowedImports marks a strategy rejected by checkStrategyImports.
+var errDisallowedImports = errors.New("strategy imports rejected")
+
+// buildConfig is the server config buildPlugin checks strategy imports
+// against, set by RegisterAll. Nil applies the default allowlist.
+var buildConfig *viper.Viper
+
+// checkStrategyImports rejects strategy source importing packages outside
+// the allowlist, unless mcp.strategyImports.enforce is false. Strategies are
+// compiled into plugins that run inside the server process, so this is what
+// keeps submitted code away from os, net or os/exec. Only the package clause
+// and imports are parsed; source whose imports cannot be read is rejected,
+// other syntax errors are left to the compiler.
+func checkStrategyImports(cfg *viper.Viper, content string) error {
+	if cfg != nil && cfg.IsSet("mcp.strategyImports.enforce") && !cfg.GetBool("mcp.strategyImports.enforce") {
+		return nil
+	}
+	imports, err := strategysrc.Imports(content, strategyImportAllowlist(cfg))
+	if err != nil {
+		return fmt.Errorf("%w: cannot read the imports: %s", errDisallowedImports, err.Error())
+	}
+	var disallowed []string
+	for _, imp := range imports {
+		if !imp.Allowed {
+			disallowed = append(disallowed, fmt.Sprintf("%s (line %d)", imp.Path, imp.Line))
+		}
+	}
+	if len(disallowed) > 0 {
+		return fmt.Errorf("%w: packages outside the allowlist: %s (see mcp.strategyImports.allow)", errDisallowedImports, strings.Join(disallowed, ", "))
+	}
+	return nil
+}
+
+func registerGetStrategyDependencies(s *server.MCPServer, cfg *viper.Viper, st *store.Store) {
+	tool := mcp.NewTool("get_strategy_dependencies",
+		mcp.WithDescription("List the packages a strategy imports, parsed from its source, and flag those outside the import allowlist (mcp.strategyImports.allow; by default the ztrade modules and pure computation parts of the standard library). Strategies are compiled into plugins loaded by the server process, so imports such as os, net or os/exec deserve review."),
+		mcp.WithString("content", mcp.Description("Strategy source code to inspect")),
+		mcp.WithNumber("id", mcp.Description("ID of a managed strategy. Used if content is not provided.")),
+		mcp.WithString("name", mcp.Description("Name of a managed strategy. Used if content and id are not provided.")),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		content, errResult := strategySourceFromRequest(ctx, req, st)
+		if errResult != nil {
+			return errResult, nil
+		}
+		allowlist := strategyImportAllowlist(cfg)
+		imports, err := strategysrc.Imports(content, allowlist)
+		if err != nil {
+			return toolErrorf(codeInvalidArgument, "failed to parse strategy source: %s", err.Error()), nil
+		}
+
+		disallowed := []string{}
+		external := []string{}
+		for _, imp := range imports {
+			if !imp.Allowed {
+				disallowed = append(disallowed, imp.Path)
+			}
+			if !imp.Stdlib {
+				external = append(external, imp.Path)
+			}
+		}
+		result := map[string]interface{}{
+			"imports":    imports,
+			"external":   external,
+			"disallowed": disallowed,
+			"allowed":    len(disallowed) == 0,
+			"allowlist":  allowlist,
+		}
+		data, _ := json.MarshalIndent(result, "", "  ")
+		return mcp.NewToolResultText(string(data)), nil
+	})
+}
@@ -0,0 +1,68 @@
+package tools
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestCheckStrategyImports(t *testing.T) {
+	src, err := renderStrategyTemplate(strategyData{Name: "Demo", Description: "demo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkStrategyImports(nil, src); err != nil {
+		t.Fatalf("template rejected: %v", err)
+	}
+
+	evil := "package strategy\n\nimport (\n\t\"math\"\n\t\"os/exec\"\n)\n"
+	err = checkStrategyImports(nil, evil)
+	if err == nil || !strings.Contains(err.Error(), "os/exec (line 5)") {
+		t.Fatalf("os/exec: %v", err)
+	}
+
+	// ztrade packages beyond the data model and indicators reach files,
+	// commands and the server's own store
+	for _, path := range []string{"github.com/ztrade/base/common", "github.com/ztrade/ztrade-mcp/store"} {
+		src := "package strategy\n\nimport \"" + path + "\"\n"
+		if err := checkStrategyImports(nil, src); !errors.Is(err, errDisallowedImports) {
+			t.Errorf("%s: %v", path, err)
+		}
+	}
+
+	cfg := viper.New()
+	cfg.Set("mcp.strategyImports.allow", []string{"math", "os/exec"})
+	if err := checkStrategyImports(cfg, evil); err != nil {
+		t.Errorf("configured allowlist: %v", err)
+	}
+	cfg = viper.New()
+	cfg.Set("mcp.strategyImports.enforce", false)
+	if err := checkStrategyImports(cfg, evil); err != nil {
+		t.Errorf("enforcement disabled: %v", err)
+	}
+}
+
+func TestBuildPluginChecksImports(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "evil.go")
+	evil := "package strategy\n\nimport \"os/exec\"\n\nfunc init() { exec.Command(\"true\").Run() }\n"
+	if err := os.WriteFile(src, []byte(evil), 0644); err != nil {
+		t.Fatal(err)
+	}
+	err := buildPlugin(src, filepath.Join(dir, "evil.so"), pluginBuildFlags{})
+	if !errors.Is(err, errDisallowedImports) {
+		t.Fatalf("build of os/exec strategy: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "evil.so")); !os.IsNotExist(err) {
+		t.Fatal("plugin built despite disallowed import")
+	}
+
+	// imports that cannot be read fail closed
+	if err := checkStrategyImports(nil, "import \"os/exec\"\n"); !errors.Is(err, errDisallowedImports) {
+		t.Fatalf("unparsable imports: %v", err)
+	}
+}
@@ -0,0 +1,17 @@
+package tools
+
+import (
+	"go/format"
+)
+
+// formatStrategySource runs strategy source through gofmt, so stored
+// versions are canonical and their diffs show logic changes only. Source
+// that does not parse is returned unchanged with a warning; the build
+// reports the error itself.
+func formatStrategySource(content string) (string, string) {
+	src, err := format.Source([]byte(content))
+	if err != nil {
+		return content, "content saved unformatted, gofmt failed: " + err.Error()
+	}
+	return string(src), ""
+}
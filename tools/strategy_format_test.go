@@ -0,0 +1,19 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFormatStrategySource(t *testing.T) {
+	src, warning := formatStrategySource("package main\nfunc  f( )  int {\nreturn 1}\n")
+	if warning != "" || src != "package main\n\nfunc f() int {\n\treturn 1\n}\n" {
+		t.Fatalf("formatted %q, warning %q", src, warning)
+	}
+
+	broken := "package main\nfunc f( {\n"
+	src, warning = formatStrategySource(broken)
+	if src != broken || !strings.Contains(warning, "gofmt failed") {
+		t.Fatalf("unparsable source: %q, warning %q", src, warning)
+	}
+}
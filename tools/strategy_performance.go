@@ -5,18 +5,20 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"github.com/ztrade/trademodel"
 	"github.com/ztrade/ztrade-mcp/store"
-	"github.com/ztrade/ztrade/pkg/ctl"
 	"github.com/ztrade/ztrade/pkg/process/dbstore"
 	"github.com/ztrade/ztrade/pkg/report"
 )
 
-func registerRunBacktestManaged(s *server.MCPServer, db *dbstore.DBStore, st *store.Store, tm *TaskManager) {
+func registerRunBacktestManaged(s *server.MCPServer, db *dbstore.DBStore, cfg *viper.Viper, st *store.Store, tm *TaskManager) {
 	tool := mcp.NewTool("run_backtest_managed",
 		mcp.WithDescription("Run a backtest using a managed strategy from the database. The strategy is extracted from DB, backtested, and results are automatically saved for performance tracking. Captured engine.Log output is stored and can be queried via get_backtest_logs. When the time range exceeds 30 days the task runs asynchronously — a task ID is returned immediately and you can poll progress with get_task_status / get_task_result."),
 		mcp.WithNumber("strategyId", mcp.Required(), mcp.Description("Strategy ID in the database")),
@@ -24,19 +26,32 @@ func registerRunBacktestManaged(s *server.MCPServer, db *dbstore.DBStore, st *st
 		mcp.WithString("symbol", mcp.Required(), mcp.Description("Trading pair (e.g., BTCUSDT)")),
 		mcp.WithString("start", mcp.Required(), mcp.Description("Backtest start time in format '2006-01-02 15:04:05'")),
 		mcp.WithString("end", mcp.Required(), mcp.Description("Backtest end time in format '2006-01-02 15:04:05'")),
-		mcp.WithNumber("balance", mcp.Description("Initial balance. Default: 100000")),
-		mcp.WithNumber("fee", mcp.Description("Trading fee rate. Default: 0.0005")),
-		mcp.WithNumber("lever", mcp.Description("Leverage multiplier. Default: 1")),
-		mcp.WithString("param", mcp.Description("Strategy parameters as JSON string, passed to strategy Param/Init parser")),
+		mcp.WithNumber("balance", mcp.Description("Initial balance. Default: mcp.backtest.defaults for the exchange, else 100000")),
+		mcp.WithString("param", mcp.Description("Strategy parameters as JSON string, passed to strategy Param/Init parser. Default: the parameter set stored with apply_param_set, if any")),
 		mcp.WithNumber("version", mcp.Description("Strategy version to use. Default: latest version.")),
+		mcp.WithBoolean("includeFunding", mcp.Description("Perpetual contracts only: apply historical funding rates to the open position at each funding time and record totalFunding. Default: false")),
+		mcp.WithNumber("warmupBars", mcp.Description("Number of leading 1m candles fed to the strategy with order execution suppressed, so indicators can initialize. Stored with the backtest record. Default: 0")),
+		mcp.WithBoolean("limitOrderModel", mcp.Description("Treat orders priced away from the market as limit orders that fill only when a candle trades through the price; orders at or through the market fill at the next candle's open. Stored with the backtest record. Default: false (any touched price fills)")),
+		mcp.WithNumber("limitOrderExpiry", mcp.Description("With limitOrderModel: cancel resting limit orders left unfilled after this many 1m candles. Default: 0 (never expire)")),
+		mcp.WithString("fillOn", mcp.Description("When orders at or through the market fill: 'nextOpen' (default) at the open of the next 1m candle after the strategy placed them, or 'close' at their own price, typically the close of the signal candle, which is lookahead bias for signals computed from that close. limitOrderModel always fills them at the next open. Stored with the backtest record.")),
+		mcp.WithBoolean("lookaheadCheck", mcp.Description("Also run the backtest with the other fillOn and report lookaheadSensitivity: the total return with fills at the signal candle's close vs at the next open, flagged when same-bar fills earn clearly more, a sign of lookahead-sensitive logic. The extra run is not recorded. Not available with limitOrderModel or chunk. Default: false")),
+		mcp.WithNumber("riskFreeRate", mcp.Description("Annual risk-free rate, e.g. 0.05. When set, sharpeRatio and sortinoRatio are recomputed from the equity-curve returns in excess of this rate; overallScore keeps the report's ratios. Stored with the backtest record. Default: the report's built-in 0.02")),
+		mcp.WithString("runLabel", mcp.Description(fmt.Sprintf("Label grouping this run with related runs, e.g. the runs of a parameter sweep, up to %d characters. Retrieve the group with get_experiment or filter list_backtest_records by it", maxRunLabelLen))),
+		mcp.WithString("chunk", mcp.Description("Split the range into calendar chunks run one after another: 'month' or 'quarter'. Each chunk is saved as a backtest record under runLabel when it finishes, its end balance carried into the next, and a combined record is stitched from all of them; rerunning after a failure resumes after the saved chunks. Each chunk starts flat with fresh strategy state. Default: no chunking")),
+		mcp.WithString("buildTags", mcp.Description("Build tags for compiling the strategy, separated by commas")),
+		mcp.WithString("ldflags", mcp.Description("Linker flags for compiling the strategy; only -s, -w and -X importpath.name=value are accepted")),
 	)
+	for _, opt := range costModelOptions() {
+		opt(&tool)
+	}
+	idempotencyKeyOption()(&tool)
 
 	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		if db == nil {
-			return mcp.NewToolResultError("database not initialized"), nil
+			return toolError(codeUnavailable, "database not initialized"), nil
 		}
 		if st == nil {
-			return mcp.NewToolResultError("script store not initialized (check database config)"), nil
+			return toolError(codeUnavailable, "script store not initialized (check database config)"), nil
 		}
 
 		strategyID := int64(req.GetFloat("strategyId", 0))
@@ -45,16 +60,64 @@ func registerRunBacktestManaged(s *server.MCPServer, db *dbstore.DBStore, st *st
 		startStr := req.GetString("start", "")
 		endStr := req.GetString("end", "")
 		balanceF := req.GetFloat("balance", 0)
-		feeF := req.GetFloat("fee", 0)
-		leverF := req.GetFloat("lever", 0)
 		param := req.GetString("param", "")
 		versionF := req.GetFloat("version", 0)
+		runLabel := strings.TrimSpace(req.GetString("runLabel", ""))
+		if len(runLabel) > maxRunLabelLen {
+			return toolErrorf(codeInvalidArgument, "runLabel is longer than %d characters", maxRunLabelLen), nil
+		}
+		chunk := req.GetString("chunk", "")
+		chunkMonths, ok := backtestChunkMonths[chunk]
+		if chunk != "" && !ok {
+			return toolErrorf(codeInvalidArgument, "invalid chunk %q, expected month or quarter", chunk), nil
+		}
+		defaultBalance, baseCosts := backtestDefaults(cfg, exchangeName)
+		costs, err := costModelFromRequest(req, baseCosts)
+		if err != nil {
+			return toolError(codeInvalidArgument, err.Error()), nil
+		}
+		gate := backtestGate{
+			WarmupBars:      int(req.GetFloat("warmupBars", 0)),
+			LimitOrders:     req.GetBool("limitOrderModel", false),
+			LimitExpiryBars: int(req.GetFloat("limitOrderExpiry", 0)),
+			RecordPositions: true,
+		}
+		if gate.WarmupBars < 0 || gate.LimitExpiryBars < 0 {
+			return toolError(codeInvalidArgument, "warmupBars and limitOrderExpiry must not be negative"), nil
+		}
+		switch fillOn := req.GetString("fillOn", fillOnNextOpen); fillOn {
+		case fillOnNextOpen:
+			gate.NextOpenFills = true
+		case fillOnClose:
+			if gate.LimitOrders {
+				return toolError(codeInvalidArgument, "fillOn=close cannot be combined with limitOrderModel, which fills orders at the market at the next open"), nil
+			}
+		default:
+			return toolErrorf(codeInvalidArgument, "invalid fillOn %q, expected nextOpen or close", fillOn), nil
+		}
+		lookahead := req.GetBool("lookaheadCheck", false)
+		if lookahead && (gate.LimitOrders || chunk != "") {
+			return toolError(codeInvalidArgument, "lookaheadCheck cannot be combined with limitOrderModel or chunk"), nil
+		}
+		buildFlags, err := parsePluginBuildFlags(req.GetString("buildTags", ""), req.GetString("ldflags", ""))
+		if err != nil {
+			return toolError(codeInvalidArgument, err.Error()), nil
+		}
+		riskFreeRate, recomputeRatios := reportRiskFreeRate, req.GetArguments()["riskFreeRate"] != nil
+		if recomputeRatios {
+			riskFreeRate = req.GetFloat("riskFreeRate", 0)
+			if riskFreeRate <= -1 {
+				return toolError(codeInvalidArgument, "riskFreeRate must be greater than -1"), nil
+			}
+		}
 
 		// Get strategy from DB
-		script, err := st.GetScript(strategyID)
+		script, err := accessibleScript(ctx, st, strategyID, false)
 		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("failed to get script: %s", err.Error())), nil
+			return toolErrorf(storeErrorCode(err), "failed to get script: %s", err.Error()), nil
 		}
+		// without param the strategy's default set from apply_param_set runs
+		param, paramSet := defaultParam(script, param)
 
 		// If a specific version is requested, get that version's content
 		scriptContent := script.Content
@@ -62,7 +125,7 @@ func registerRunBacktestManaged(s *server.MCPServer, db *dbstore.DBStore, st *st
 		if versionF > 0 {
 			ver, err := st.GetVersion(strategyID, int(versionF))
 			if err != nil {
-				return mcp.NewToolResultError(fmt.Sprintf("failed to get version: %s", err.Error())), nil
+				return toolErrorf(storeErrorCode(err), "failed to get version: %s", err.Error()), nil
 			}
 			scriptContent = ver.Content
 			scriptVersion = ver.Version
@@ -70,154 +133,201 @@ func registerRunBacktestManaged(s *server.MCPServer, db *dbstore.DBStore, st *st
 
 		start, err := time.Parse("2006-01-02 15:04:05", startStr)
 		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("invalid start time: %s", err.Error())), nil
+			return toolErrorf(codeInvalidArgument, "invalid start time: %s", err.Error()), nil
 		}
 		end, err := time.Parse("2006-01-02 15:04:05", endStr)
 		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("invalid end time: %s", err.Error())), nil
+			return toolErrorf(codeInvalidArgument, "invalid end time: %s", err.Error()), nil
 		}
 
 		if balanceF <= 0 {
-			balanceF = 100000
+			balanceF = defaultBalance
 		}
-		if feeF <= 0 {
-			feeF = 0.0005
+		if err := loadSymbolRules(ctx, cfg, req, &costs, exchangeName, symbol); err != nil {
+			return toolErrorf(codeUpstream, "symbolRules: %s", err.Error()), nil
 		}
-		if leverF <= 0 {
-			leverF = 1
+		manifest := newRunManifest(scriptContent, buildFlags, costs)
+
+		var fundingRates []store.FundingRate
+		if costs.IncludeFunding {
+			fundingRates, err = loadFundingRates(ctx, cfg, st, exchangeName, symbol, start, end)
+			if err != nil {
+				return toolErrorf(codeUpstream, "includeFunding: %s", err.Error()), nil
+			}
+		}
+
+		// a timeframe bound to a parameter may come from param
+		var params map[string]interface{}
+		json.Unmarshal([]byte(param), &params)
+		if err := checkMergeTimeframes(scriptContent, params); err != nil {
+			return toolError(codeInvalidArgument, err.Error()), nil
 		}
 
 		// Write script to temp file for backtesting
 		tmpFile := fmt.Sprintf("/tmp/ztrade_script_%d_v%d.go", strategyID, scriptVersion)
 		if err := writeFile(tmpFile, scriptContent); err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("failed to write temp script: %s", err.Error())), nil
+			return toolErrorf(codeInternal, "failed to write temp script: %s", err.Error()), nil
 		}
 
 		// --- 自动编译为 so ---
 		soFile := fmt.Sprintf("/tmp/ztrade_script_%d_v%d.so", strategyID, scriptVersion)
-		builder := ctl.NewBuilder(tmpFile, soFile)
-		if err := builder.Build(); err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("failed to build so: %s", err.Error())), nil
+		if err := buildPlugin(tmpFile, soFile, buildFlags); err != nil {
+			return toolErrorf(codeBuildFailed, "failed to build so: %s", err.Error()), nil
 		}
 
 		// runManagedBacktest is the core logic shared by sync and async paths
-		runManagedBacktest := func() (ret map[string]interface{}, err error) {
-			defer func() {
-				if r := recover(); r != nil {
-					err = fmt.Errorf("panic in backtest: %v", r)
-					ret = nil
-				}
-			}()
-			bt, err := ctl.NewBacktest(db, exchangeName, symbol, param, start, end)
-			if err != nil {
-				return nil, fmt.Errorf("failed to create backtest: %s", err.Error())
-			}
-
+		runManagedBacktest := func(ctx context.Context) (map[string]interface{}, error) {
 			// In default (non-ixgo) builds, GoEngine only supports plugin files (.so/.dll/.dylib).
 			// Use the compiled plugin instead of the temporary .go source file.
-			bt.SetScript(soFile)
-			bt.SetBalanceInit(balanceF, feeF)
-			bt.SetLever(leverF)
-
-			rpt := report.NewReportSimple()
-			rpt.SetTimeRange(start, end)
-			rpt.SetFee(feeF)
-			rpt.SetLever(leverF)
-			bt.SetReporter(rpt)
-
-			err = suppressStdout(func() error {
-				return bt.Run()
-			})
+			run, err := executeBacktest(ctx, db, soFile, exchangeName, symbol, param, start, end, balanceF, costs, gate)
 			if err != nil {
-				return nil, fmt.Errorf("backtest failed: %s", err.Error())
-			}
-
-			logs, logsTruncated := truncateLinesByBytes(bt.GetLog(), maxBacktestLogBytes)
-			if logsTruncated {
-				log.WithField("limitBytes", maxBacktestLogBytes).Warn("backtest logs were truncated")
-			}
-
-			rawResult, err := bt.Result()
-			if err != nil {
-				return nil, fmt.Errorf("failed to get result: %s", err.Error())
-			}
-
-			resultData, ok := rawResult.(report.ReportResult)
-			if !ok {
-				return nil, fmt.Errorf("unexpected result type")
-			}
-			if fields := sanitizeBacktestMetrics(&resultData); len(fields) > 0 {
-				log.WithField("fields", fields).Warn("sanitized non-finite backtest metrics")
-			}
-
-			// Save backtest record
-			record := &store.BacktestRecord{
-				ScriptID: strategyID, ScriptVersion: scriptVersion,
-				Exchange: exchangeName, Symbol: symbol,
-				StartTime: start, EndTime: end,
-				InitBalance: balanceF, Fee: feeF, Lever: leverF, Param: param,
-				TotalActions: resultData.TotalAction, WinRate: resultData.WinRate,
-				TotalProfit: resultData.TotalProfit, ProfitPercent: resultData.ProfitPercent,
-				MaxDrawdown: resultData.MaxDrawdown, MaxDrawdownValue: resultData.MaxDrawdownValue,
-				MaxLose: resultData.MaxLose, TotalFee: resultData.TotalFee,
-				StartBalance: resultData.StartBalance, EndBalance: resultData.EndBalance,
-				TotalReturn: resultData.TotalReturn, AnnualReturn: resultData.AnnualReturn,
-				SharpeRatio: resultData.SharpeRatio, SortinoRatio: resultData.SortinoRatio,
-				Volatility: resultData.Volatility, ProfitFactor: resultData.ProfitFactor,
-				CalmarRatio: resultData.CalmarRatio, OverallScore: resultData.OverallScore,
-				LongTrades: resultData.LongTrades, ShortTrades: resultData.ShortTrades,
+				return nil, err
 			}
-			if saveErr := st.SaveBacktestRecord(record); saveErr != nil {
-				log.Warnf("backtest completed but failed to save record: %s", saveErr.Error())
+			resultData := run.Result
+
+			record := newBacktestRecord(strategyID, scriptVersion, exchangeName, symbol, param, start, end, balanceF, costs, gate, resultData)
+			equity := buildEquityCurve(start, balanceF, resultData.Actions)
+			record.UlcerIndex, record.MartinRatio = ulcerMetrics(equity, resultData.AnnualReturn)
+			record.RiskFreeRate = riskFreeRate
+			record.RunLabel = runLabel
+			if recomputeRatios {
+				record.SharpeRatio, record.SortinoRatio = excessReturnRatios(equity, start, end, riskFreeRate)
 			}
-			if record.ID > 0 && len(logs) > 0 {
-				if logErr := st.SaveBacktestLogs(record.ID, logs); logErr != nil {
-					log.Warnf("backtest record %d saved but failed to save logs: %s", record.ID, logErr.Error())
+			var fundingEvents int
+			if costs.IncludeFunding {
+				trades := make([]trademodel.Trade, 0, len(resultData.Actions))
+				for _, act := range resultData.Actions {
+					trades = append(trades, act.Trade)
 				}
+				record.TotalFunding, fundingEvents = calcFundingCost(trades, fundingRates)
 			}
+			tagMarketRegime(ctx, db, record)
+			manifest.stamp(ctx, db, record)
+			saveBacktestRun(ctx, st, record, run, equity)
 
 			result := map[string]interface{}{
-				"recordId": record.ID, "strategyId": strategyID, "param": param, "logLines": len(logs), "logsTruncated": logsTruncated,
+				"recordId": record.ID, "strategyId": strategyID, "param": param, "logLines": len(run.Logs), "logsTruncated": run.LogsTruncated,
 				"strategyName": script.Name, "strategyVersion": scriptVersion,
 				"exchange": exchangeName, "symbol": symbol,
 				"totalActions": resultData.TotalAction, "winRate": resultData.WinRate,
 				"totalProfit": resultData.TotalProfit, "profitPercent": resultData.ProfitPercent,
 				"maxDrawdown": resultData.MaxDrawdown, "maxDrawdownValue": resultData.MaxDrawdownValue,
 				"totalReturn": resultData.TotalReturn, "annualReturn": resultData.AnnualReturn,
-				"sharpeRatio": resultData.SharpeRatio, "sortinoRatio": resultData.SortinoRatio,
+				"sharpeRatio": record.SharpeRatio, "sortinoRatio": record.SortinoRatio, "riskFreeRate": riskFreeRate,
 				"volatility": resultData.Volatility, "profitFactor": resultData.ProfitFactor,
 				"calmarRatio": resultData.CalmarRatio, "overallScore": resultData.OverallScore,
 				"longTrades": resultData.LongTrades, "shortTrades": resultData.ShortTrades,
+				"ulcerIndex": record.UlcerIndex, "martinRatio": record.MartinRatio,
+				"positionChanges": len(run.Positions),
+				"dataCoverage":    run.Coverage,
+				"runLabel":        runLabel,
+				"excursions":      excursionSummary(backtestTradesFromActions(resultData.Actions, run.Excursions)),
+			}
+			long, short := sideBreakdown(resultData.Actions, balanceF)
+			result["sides"] = map[string]sideStats{"long": long, "short": short}
+			result["fillOn"] = gate.fillOn()
+			if paramSet != "" {
+				result["paramSet"] = paramSet
+			}
+			if record.Regime != "" {
+				result["regime"] = record.Regime
+			}
+			stats := run.Gate
+			if gate.WarmupBars > 0 {
+				result["warmupBars"] = gate.WarmupBars
+				result["warmupDroppedOrders"] = stats.WarmupDropped
+			}
+			if gate.LimitOrders {
+				result["limitOrderModel"] = true
+				result["limitOrdersExpired"] = stats.LimitExpired
+			}
+			if costs.adjustsFills() {
+				result["slippage"] = costs.Slippage
+				result["makerFee"] = costs.MakerFee
+			}
+			if costs.checksOrders() {
+				result["minNotional"] = costs.MinNotional
+				result["lotSize"] = costs.AmountStep
+				result["ordersRounded"] = stats.OrdersRounded
+				result["ordersRejected"] = stats.OrdersRejected
+			}
+			if lookahead {
+				ls, err := checkLookahead(ctx, db, soFile, exchangeName, symbol, param, start, end, balanceF, costs, gate, resultData)
+				if err != nil {
+					// the backtest itself succeeded and is saved
+					result["lookaheadSensitivityError"] = err.Error()
+				} else {
+					result["lookaheadSensitivity"] = ls
+				}
+			}
+			if costs.IncludeFunding {
+				result["totalFunding"] = record.TotalFunding
+				result["fundingEvents"] = fundingEvents
+				result["fundingRates"] = len(fundingRates)
+				result["totalProfitAfterFunding"] = resultData.TotalProfit - record.TotalFunding
 			}
 			return result, nil
 		}
 
+		// A range spanning several chunks runs as a chunkedBacktest. Without a
+		// runLabel one is derived from the arguments so a rerun resumes.
+		var chunked *chunkedBacktest
+		if chunkMonths > 0 && len(splitTimeRange(start, end, chunkMonths)) > 1 {
+			if runLabel == "" {
+				runLabel = fmt.Sprintf("chunked-%d-v%d-%s-%s-%s", strategyID, scriptVersion, symbol, start.Format("20060102"), end.Format("20060102"))
+			}
+			chunked = &chunkedBacktest{
+				strategyID: strategyID, version: scriptVersion, plugin: soFile,
+				exchange: exchangeName, symbol: symbol, param: param,
+				start: start, end: end, balance: balanceF, costs: costs, gate: gate,
+				months: chunkMonths, runLabel: runLabel,
+				riskFreeRate: riskFreeRate, recompute: recomputeRatios, fundingRates: fundingRates,
+				manifest: manifest,
+			}
+			runManagedBacktest = func(ctx context.Context) (map[string]interface{}, error) {
+				return chunked.run(ctx, db, st)
+			}
+		}
+
 		// If time range > threshold, run asynchronously
 		if ShouldRunAsync(start, end) {
-			taskID := tm.CreateTask("backtest_managed", map[string]string{
+			if err := preflightPluginScript(soFile); err != nil {
+				return toolError(codeBuildFailed, err.Error()), nil
+			}
+			taskID, created := tm.CreateTaskOnce("backtest_managed", map[string]string{
 				"strategyId": fmt.Sprintf("%d", strategyID),
 				"exchange":   exchangeName,
 				"symbol":     symbol,
 				"start":      startStr,
 				"end":        endStr,
-			})
+			}, taskDedupeKey(ctx, "backtest_managed", req))
+			if !created {
+				return dedupedTaskResult(taskID), nil
+			}
 
 			go func() {
 				tm.StartTask(taskID)
-				doneCh := tm.ProgressEstimator(taskID, "backtest_managed", start, end)
+				doneCh := make(chan struct{})
+				if chunked != nil {
+					// chunks report their own progress
+					chunked.progress = func(progress string, percent int) { tm.UpdateProgress(taskID, progress, percent) }
+				} else {
+					doneCh = tm.ProgressEstimator(taskID, "backtest_managed", start, end)
+				}
 
-				result, err := runManagedBacktest()
+				// the task outlives the request that started it
+				result, err := runManagedBacktest(context.WithoutCancel(ctx))
 				close(doneCh)
 
 				if err != nil {
-					log.Errorf("async managed backtest task %s failed: %s", taskID, err.Error())
+					log.WithContext(ctx).Errorf("async managed backtest task %s failed: %s", taskID, err.Error())
 					tm.FailTask(taskID, err.Error())
 					return
 				}
 
 				data, _ := json.MarshalIndent(result, "", "  ")
 				tm.CompleteTask(taskID, string(data))
-				log.Infof("async managed backtest task %s completed", taskID)
+				log.WithContext(ctx).Infof("async managed backtest task %s completed", taskID)
 			}()
 
 			asyncResult := map[string]interface{}{
@@ -230,25 +340,79 @@ func registerRunBacktestManaged(s *server.MCPServer, db *dbstore.DBStore, st *st
 		}
 
 		// Synchronous execution for short time ranges
-		result, err := runManagedBacktest()
+		result, err := runManagedBacktest(ctx)
 		if err != nil {
-			return mcp.NewToolResultError(err.Error()), nil
+			return toolError(backtestErrorCode(err), err.Error()), nil
 		}
 		data, _ := json.MarshalIndent(result, "", "  ")
 		return mcp.NewToolResultText(string(data)), nil
 	})
 }
 
+// newBacktestRecord builds the record run_backtest_managed saves for a run
+// of strategyID from its settings and report.
+func newBacktestRecord(strategyID int64, version int, exchangeName, symbol, param string, start, end time.Time, balance float64, costs TradingCostModel, gate backtestGate, res report.ReportResult) *store.BacktestRecord {
+	long, short := sideBreakdown(res.Actions, balance)
+	return &store.BacktestRecord{
+		ScriptID: strategyID, ScriptVersion: version,
+		Exchange: exchangeName, Symbol: symbol,
+		StartTime: start, EndTime: end,
+		InitBalance: balance, Fee: costs.TakerFee, Lever: costs.Lever, Param: param,
+		WarmupBars: gate.WarmupBars, LimitOrderModel: gate.LimitOrders, LimitOrderExpiry: gate.LimitExpiryBars,
+		FillOn:       gate.fillOn(),
+		TotalActions: res.TotalAction, WinRate: res.WinRate,
+		TotalProfit: res.TotalProfit, ProfitPercent: res.ProfitPercent,
+		MaxDrawdown: res.MaxDrawdown, MaxDrawdownValue: res.MaxDrawdownValue,
+		MaxLose: res.MaxLose, TotalFee: res.TotalFee,
+		StartBalance: res.StartBalance, EndBalance: res.EndBalance,
+		TotalReturn: res.TotalReturn, AnnualReturn: res.AnnualReturn,
+		SharpeRatio: res.SharpeRatio, SortinoRatio: res.SortinoRatio,
+		Volatility: res.Volatility, ProfitFactor: res.ProfitFactor,
+		CalmarRatio: res.CalmarRatio, OverallScore: res.OverallScore,
+		LongTrades: res.LongTrades, ShortTrades: res.ShortTrades,
+		LongWinRate: long.WinRate, LongProfitFactor: long.ProfitFactor, LongNetReturn: long.NetReturn,
+		ShortWinRate: short.WinRate, ShortProfitFactor: short.ProfitFactor, ShortNetReturn: short.NetReturn,
+	}
+}
+
+// saveBacktestRun saves record and, once it has an ID, the run's logs,
+// equity curve, trades and positions. The backtest already succeeded, so
+// failures are logged rather than returned.
+func saveBacktestRun(ctx context.Context, st *store.Store, record *store.BacktestRecord, run *backtestRun, equity []store.EquityPoint) {
+	if err := st.SaveBacktestRecord(record); err != nil {
+		log.WithContext(ctx).Warnf("backtest completed but failed to save record: %s", err.Error())
+		return
+	}
+	if record.ID <= 0 {
+		return
+	}
+	if len(run.Logs) > 0 {
+		if err := st.SaveBacktestLogs(record.ID, run.Logs); err != nil {
+			log.WithContext(ctx).Warnf("backtest record %d saved but failed to save logs: %s", record.ID, err.Error())
+		}
+	}
+	if err := st.SaveBacktestEquity(record.ID, equity); err != nil {
+		log.WithContext(ctx).Warnf("backtest record %d saved but failed to save equity curve: %s", record.ID, err.Error())
+	}
+	if err := st.SaveBacktestTrades(record.ID, backtestTradesFromActions(run.Result.Actions, run.Excursions)); err != nil {
+		log.WithContext(ctx).Warnf("backtest record %d saved but failed to save trades: %s", record.ID, err.Error())
+	}
+	if err := st.SaveBacktestPositions(record.ID, run.Positions); err != nil {
+		log.WithContext(ctx).Warnf("backtest record %d saved but failed to save positions: %s", record.ID, err.Error())
+	}
+}
+
 func registerListBacktestRecords(s *server.MCPServer, st *store.Store) {
 	tool := mcp.NewTool("list_backtest_records",
 		mcp.WithDescription("List backtest history for a strategy. Returns all backtest runs with performance metrics, ordered by most recent first."),
 		mcp.WithNumber("strategyId", mcp.Required(), mcp.Description("Strategy ID")),
 		mcp.WithNumber("limit", mcp.Description("Maximum number of records to return. Default: 20")),
+		mcp.WithString("runLabel", mcp.Description("Only records with this run label (see run_backtest_managed)")),
 	)
 
 	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		if st == nil {
-			return mcp.NewToolResultError("script store not initialized (check database config)"), nil
+			return toolError(codeUnavailable, "script store not initialized (check database config)"), nil
 		}
 
 		strategyID := int64(req.GetFloat("strategyId", 0))
@@ -257,9 +421,9 @@ func registerListBacktestRecords(s *server.MCPServer, st *store.Store) {
 			limit = 20
 		}
 
-		records, err := st.ListBacktestRecords(strategyID, limit)
+		records, err := st.ListBacktestRecordsByLabel(strategyID, req.GetString("runLabel", ""), limit)
 		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("failed to list records: %s", err.Error())), nil
+			return toolErrorf(codeInternal, "failed to list records: %s", err.Error()), nil
 		}
 
 		type recordSummary struct {
@@ -274,7 +438,10 @@ func registerListBacktestRecords(s *server.MCPServer, st *store.Store) {
 			TotalReturn   float64 `json:"totalReturn"`
 			SharpeRatio   float64 `json:"sharpeRatio"`
 			MaxDrawdown   float64 `json:"maxDrawdown"`
+			UlcerIndex    float64 `json:"ulcerIndex"`
 			OverallScore  float64 `json:"overallScore"`
+			RunLabel      string  `json:"runLabel,omitempty"`
+			Chunk         bool    `json:"chunk,omitempty"`
 			CreatedAt     string  `json:"createdAt"`
 		}
 
@@ -292,7 +459,10 @@ func registerListBacktestRecords(s *server.MCPServer, st *store.Store) {
 				TotalReturn:   r.TotalReturn,
 				SharpeRatio:   r.SharpeRatio,
 				MaxDrawdown:   r.MaxDrawdown,
+				UlcerIndex:    r.UlcerIndex,
 				OverallScore:  r.OverallScore,
+				RunLabel:      r.RunLabel,
+				Chunk:         r.Chunk,
 				CreatedAt:     r.CreatedAt.Format("2006-01-02 15:04:05"),
 			})
 		}
@@ -311,35 +481,91 @@ func registerStrategyPerformance(s *server.MCPServer, st *store.Store) {
 	tool := mcp.NewTool("strategy_performance",
 		mcp.WithDescription("Get aggregated performance summary for a strategy across all backtests. Includes best/worst runs, average score, and key metrics ranges."),
 		mcp.WithNumber("strategyId", mcp.Required(), mcp.Description("Strategy ID")),
+		mcp.WithString("groupBy", mcp.Description("Also break the summary down per group: 'year' (calendar year of the backtest start), 'symbol', 'regime' (market regime over the backtest range, recorded by run_backtest_managed: trending, ranging, volatile or unclassified), or 'none'. Default: none")),
 	)
 
 	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		if st == nil {
-			return mcp.NewToolResultError("script store not initialized (check database config)"), nil
+			return toolError(codeUnavailable, "script store not initialized (check database config)"), nil
 		}
 
 		strategyID := int64(req.GetFloat("strategyId", 0))
+		groupBy := req.GetString("groupBy", store.BacktestGroupNone)
+		if !store.IsValidBacktestGroup(groupBy) {
+			return toolErrorf(codeInvalidArgument, "invalid groupBy %s, supported: none, year, symbol, regime", groupBy), nil
+		}
 
 		// Get strategy info
-		script, err := st.GetScript(strategyID)
+		script, err := accessibleScript(ctx, st, strategyID, false)
 		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("failed to get script: %s", err.Error())), nil
+			return toolErrorf(storeErrorCode(err), "failed to get script: %s", err.Error()), nil
 		}
 
-		summary, err := st.GetBacktestSummary(strategyID)
+		var summary map[string]interface{}
+		cached, ok, err := st.GetCachedBacktestSummary(strategyID)
 		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("failed to get performance summary: %s", err.Error())), nil
+			log.WithContext(ctx).WithError(err).Warn("read cached performance summary failed")
+		}
+		if ok {
+			summary = cached.Summary
+			summary["cachedAt"] = cached.ComputedAt.Format("2006-01-02 15:04:05")
+		} else {
+			summary, err = st.GetBacktestSummary(strategyID)
+			if err != nil {
+				return toolErrorf(storeErrorCode(err), "failed to get performance summary: %s", err.Error()), nil
+			}
 		}
 
 		summary["strategyId"] = strategyID
 		summary["strategyName"] = script.Name
 		summary["currentVersion"] = script.Version
+		if groupBy != "" && groupBy != store.BacktestGroupNone {
+			groups, err := st.GetBacktestSummaryGroups(strategyID, groupBy)
+			if err != nil {
+				return toolErrorf(storeErrorCode(err), "failed to group performance summary: %s", err.Error()), nil
+			}
+			summary["groupBy"] = groupBy
+			summary["groups"] = groups
+		}
 
 		data, _ := json.MarshalIndent(summary, "", "  ")
 		return mcp.NewToolResultText(string(data)), nil
 	})
 }
 
+func registerRecomputePerformance(s *server.MCPServer, st *store.Store) {
+	tool := mcp.NewTool("recompute_performance",
+		mcp.WithDescription("Recompute a strategy's aggregate backtest performance (the strategy_performance summary) from its backtest records and, by default, store it as the cached summary that strategy_performance returns while the records are unchanged. Use it to force a fresh summary after records were deleted or rewritten outside the tools."),
+		mcp.WithNumber("strategyId", mcp.Required(), mcp.Description("Strategy ID")),
+		mcp.WithBoolean("materialize", mcp.Description("Store the result as the cached summary. Default: true; false only computes it")),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if st == nil {
+			return toolError(codeUnavailable, "script store not initialized (check database config)"), nil
+		}
+		strategyID := int64(req.GetFloat("strategyId", 0))
+		materialize := req.GetBool("materialize", true)
+		if _, err := accessibleScript(ctx, st, strategyID, false); err != nil {
+			return toolErrorf(storeErrorCode(err), "failed to get script: %s", err.Error()), nil
+		}
+		perf, err := st.RecomputeBacktestSummary(strategyID, materialize)
+		if err != nil {
+			return toolErrorf(storeErrorCode(err), "failed to recompute performance summary: %s", err.Error()), nil
+		}
+		result := map[string]interface{}{
+			"strategyId":   strategyID,
+			"materialized": materialize,
+			"runs":         perf.Runs,
+			"lastRecordId": perf.LastRecordID,
+			"computedAt":   perf.ComputedAt.Format("2006-01-02 15:04:05"),
+			"summary":      perf.Summary,
+		}
+		data, _ := json.MarshalIndent(result, "", "  ")
+		return mcp.NewToolResultText(string(data)), nil
+	})
+}
+
 // writeFile is a helper to write content to a file.
 func writeFile(path, content string) error {
 	f, err := os.Create(path)
@@ -0,0 +1,78 @@
+package tools
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/ztrade/trademodel"
+	"github.com/ztrade/ztrade-mcp/store"
+	"github.com/ztrade/ztrade/pkg/report"
+)
+
+func TestSaveBacktestRun(t *testing.T) {
+	cfg := viper.New()
+	cfg.Set("db.type", "sqlite")
+	cfg.Set("db.uri", filepath.Join(t.TempDir(), "mcp.db"))
+	st, err := store.NewStore(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 1, 0)
+	costs := TradingCostModel{TakerFee: 0.0004, MakerFee: 0.0002, Lever: 3}
+	gate := backtestGate{WarmupBars: 10, LimitOrders: true, LimitExpiryBars: 5}
+	run := &backtestRun{
+		Result: report.ReportResult{
+			TotalAction: 2, WinRate: 1, TotalProfit: 50, StartBalance: 1000, EndBalance: 1050,
+			SharpeRatio: 1.5, LongTrades: 1,
+			Actions: []*report.RptAct{
+				{Trade: trademodel.Trade{ID: "1", Action: trademodel.OpenLong, Time: start.Add(time.Hour), Price: 100, Amount: 1}, Total: 1000},
+				{Trade: trademodel.Trade{ID: "2", Action: trademodel.CloseLong, Time: start.Add(2 * time.Hour), Price: 150, Amount: 1}, Total: 1050, Profit: 50, IsFinish: true},
+			},
+		},
+		Logs:      []string{"open", "close"},
+		Positions: []store.PositionPoint{{Time: start.Add(time.Hour), Position: 1, AvgPrice: 100}, {Time: start.Add(2 * time.Hour)}},
+	}
+
+	record := newBacktestRecord(7, 2, "binance", "BTCUSDT", `{"fast":5}`, start, end, 1000, costs, gate, run.Result)
+	equity := buildEquityCurve(start, 1000, run.Result.Actions)
+	saveBacktestRun(context.Background(), st, record, run, equity)
+	if record.ID <= 0 {
+		t.Fatal("record not saved")
+	}
+
+	got, err := st.GetBacktestRecord(record.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ScriptID != 7 || got.ScriptVersion != 2 || got.Exchange != "binance" || got.Symbol != "BTCUSDT" || got.Param != `{"fast":5}` {
+		t.Errorf("identity fields: %+v", got)
+	}
+	if got.InitBalance != 1000 || got.Fee != 0.0004 || got.Lever != 3 || !got.StartTime.Equal(start) || !got.EndTime.Equal(end) {
+		t.Errorf("settings: %+v", got)
+	}
+	if got.WarmupBars != 10 || !got.LimitOrderModel || got.LimitOrderExpiry != 5 {
+		t.Errorf("gate settings: %+v", got)
+	}
+	if got.TotalActions != 2 || got.TotalProfit != 50 || got.EndBalance != 1050 || got.SharpeRatio != 1.5 || got.LongTrades != 1 {
+		t.Errorf("metrics: %+v", got)
+	}
+
+	if logs, total, err := st.ListBacktestLogs(record.ID, 0, 10); err != nil || total != 2 || len(logs) != 2 {
+		t.Errorf("logs: %d of %d, %v", len(logs), total, err)
+	}
+	if points, err := st.ListBacktestEquity(record.ID); err != nil || len(points) != len(equity) {
+		t.Errorf("equity: %d points, %v", len(points), err)
+	}
+	if trades, err := st.ListBacktestTrades(record.ID); err != nil || len(trades) != 2 {
+		t.Errorf("trades: %d, %v", len(trades), err)
+	}
+	if positions, err := st.ListBacktestPositions(record.ID); err != nil || len(positions) != 2 {
+		t.Errorf("positions: %d, %v", len(positions), err)
+	}
+}
@@ -0,0 +1,123 @@
+package tools
+
+import (
+	"errors"
+	"go/format"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/ztrade/ztrade-mcp/internal/strategysrc"
+	"github.com/ztrade/ztrade-mcp/store"
+)
+
+func TestRenderStrategyTemplateRiskControls(t *testing.T) {
+	cases := []struct {
+		name                 string
+		stopLoss, takeProfit float64
+		wantStop             bool
+		wantParams           []string
+	}{
+		{"none", 0, 0, false, nil},
+		{"stop loss", 2, 0, true, []string{`FloatParam("stopLossPercent", "Stop loss %", "Stop-loss distance from the entry price, in percent", 2, &s.stopLossPercent)`}},
+		{"take profit", 0, 5.5, false, []string{`FloatParam("takeProfitPercent"`}},
+		{"both", 1.5, 3, true, []string{`FloatParam("stopLossPercent"`, `FloatParam("takeProfitPercent"`, "s.engine.CloseShort("}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			data := strategyData{Name: "Demo", Description: "demo", Merges: []mergeData{{Period: "15m", Suffix: "15M"}}}
+			addRiskControls(&data, c.stopLoss, c.takeProfit)
+			src, err := renderStrategyTemplate(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := format.Source([]byte(src)); err != nil {
+				t.Fatalf("generated code does not parse: %v\n%s", err, src)
+			}
+			rc, err := strategysrc.AnalyzeRiskControls(src)
+			if err != nil || rc.HasStopLoss != c.wantStop {
+				t.Fatalf("HasStopLoss = %v (%v), want %v", rc.HasStopLoss, err, c.wantStop)
+			}
+			for _, want := range c.wantParams {
+				if !strings.Contains(src, want) {
+					t.Errorf("missing %q in\n%s", want, src)
+				}
+			}
+			if usesMath := strings.Contains(src, `"math"`); usesMath != (c.stopLoss > 0 || c.takeProfit > 0) {
+				t.Errorf("math imported: %v", usesMath)
+			}
+		})
+	}
+}
+
+func TestSplitIndicators(t *testing.T) {
+	got := splitIndicators(" EMA(9,26), RSI(14),,BOLL(20, 2) ")
+	want := []string{"EMA(9,26)", "RSI(14)", "BOLL(20, 2)"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("splitIndicators = %q, want %q", got, want)
+	}
+}
+
+func TestAddTunableIndicator(t *testing.T) {
+	data := strategyData{Name: "Demo", Description: "demo"}
+	used := map[string]bool{}
+	for _, ind := range splitIndicators("EMA(9,26),EMA(50,200),RSI(14),FOO(3,x)") {
+		addTunableIndicator(&data, ind, used)
+	}
+	wantCalls := []string{
+		`"EMA", s.emaFast, s.emaSlow`,
+		`"EMA", s.emaFast2, s.emaSlow2`,
+		`"RSI", s.rsiPeriod`,
+		`"FOO", s.fooArg1, x`,
+	}
+	for i, want := range wantCalls {
+		if data.Indicators[i].Args != want {
+			t.Errorf("indicator %d args = %s, want %s", i, data.Indicators[i].Args, want)
+		}
+	}
+	if len(data.Params) != 6 || data.Params[3].Key != "emaSlow2" || data.Params[3].Default != "200" || data.Params[3].ParamFunc != "IntParam" {
+		t.Fatalf("params: %+v", data.Params)
+	}
+
+	src, err := renderStrategyTemplate(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !regexp.MustCompile(`\n\temaFast2\s+int\n`).MatchString(src) {
+		t.Errorf("missing emaFast2 field in\n%s", src)
+	}
+	for _, want := range []string{`IntParam("emaFast2", "EMA fast", "EMA argument 1", 50, &s.emaFast2)`, `engine.AddIndicator("RSI", s.rsiPeriod)`} {
+		if !strings.Contains(src, want) {
+			t.Errorf("missing %q in\n%s", want, src)
+		}
+	}
+}
+
+func TestSaveStrategyOnConflict(t *testing.T) {
+	cfg := viper.New()
+	cfg.Set("db.type", "sqlite")
+	cfg.Set("db.uri", filepath.Join(t.TempDir(), "mcp.db"))
+	st, err := store.NewStore(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+
+	action, first, err := saveStrategy(st, &store.Script{Name: "Demo", Content: "package main // v1"}, "error", store.ScriptQuota{})
+	if err != nil || action != "created" || first.Version != 1 {
+		t.Fatalf("create: %s, %+v, %v", action, first, err)
+	}
+	if _, _, err := saveStrategy(st, &store.Script{Name: "Demo", Content: "package main // v2"}, "error", store.ScriptQuota{}); !errors.Is(err, errStrategyExists) {
+		t.Fatalf("duplicate: %v", err)
+	}
+	action, got, err := saveStrategy(st, &store.Script{Name: "Demo", Content: "package main // v2"}, "skip", store.ScriptQuota{})
+	if err != nil || action != "skipped" || got.ID != first.ID || got.Version != 1 || got.Content != "package main // v1" {
+		t.Fatalf("skip: %s, %+v, %v", action, got, err)
+	}
+	action, got, err = saveStrategy(st, &store.Script{Name: "Demo", Content: "package main // v2"}, "update", store.ScriptQuota{})
+	if err != nil || action != "updated" || got.ID != first.ID || got.Version != 2 || got.Content != "package main // v2" {
+		t.Fatalf("update: %s, %+v, %v", action, got, err)
+	}
+}
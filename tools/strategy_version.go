@@ -8,6 +8,7 @@ import (
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
+	"github.com/spf13/viper"
 	"github.com/ztrade/ztrade-mcp/store"
 )
 
@@ -19,20 +20,20 @@ func registerListStrategyVersions(s *server.MCPServer, st *store.Store) {
 
 	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		if st == nil {
-			return mcp.NewToolResultError("script store not initialized (check database config)"), nil
+			return toolError(codeUnavailable, "script store not initialized (check database config)"), nil
 		}
 
 		id := int64(req.GetFloat("id", 0))
 
 		// Get script info
-		script, err := st.GetScript(id)
+		script, err := accessibleScript(ctx, st, id, false)
 		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("failed to get script: %s", err.Error())), nil
+			return toolErrorf(storeErrorCode(err), "failed to get script: %s", err.Error()), nil
 		}
 
 		versions, err := st.ListVersions(id)
 		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("failed to list versions: %s", err.Error())), nil
+			return toolErrorf(codeInternal, "failed to list versions: %s", err.Error()), nil
 		}
 
 		type versionSummary struct {
@@ -62,33 +63,46 @@ func registerListStrategyVersions(s *server.MCPServer, st *store.Store) {
 	})
 }
 
-func registerGetStrategyVersion(s *server.MCPServer, st *store.Store) {
+func registerGetStrategyVersion(s *server.MCPServer, st *store.Store, cfg *viper.Viper) {
 	tool := mcp.NewTool("get_strategy_version",
 		mcp.WithDescription("Get the full content of a specific version of a strategy. Useful for reviewing or comparing historical versions."),
 		mcp.WithNumber("id", mcp.Required(), mcp.Description("Strategy ID")),
 		mcp.WithNumber("version", mcp.Required(), mcp.Description("Version number to retrieve")),
+		mcp.WithString("outputPath", mcp.Description("Write the raw content to this file (relative to or inside mcp.workDir) and return the path and byte count instead of the content.")),
 	)
 
 	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		if st == nil {
-			return mcp.NewToolResultError("script store not initialized (check database config)"), nil
+			return toolError(codeUnavailable, "script store not initialized (check database config)"), nil
 		}
 
 		id := int64(req.GetFloat("id", 0))
 		version := int(req.GetFloat("version", 0))
 
+		if _, err := accessibleScript(ctx, st, id, false); err != nil {
+			return toolErrorf(storeErrorCode(err), "failed to get script: %s", err.Error()), nil
+		}
 		ver, err := st.GetVersion(id, version)
 		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("failed to get version: %s", err.Error())), nil
+			return toolErrorf(storeErrorCode(err), "failed to get version: %s", err.Error()), nil
 		}
 
 		result := map[string]interface{}{
 			"scriptId":  id,
 			"version":   ver.Version,
 			"message":   ver.Message,
-			"content":   ver.Content,
 			"createdAt": ver.CreatedAt.Format("2006-01-02 15:04:05"),
 		}
+		if outputPath := req.GetString("outputPath", ""); outputPath != "" {
+			path, err := writeWorkDirFile(cfg, outputPath, ver.Content)
+			if err != nil {
+				return toolErrorf(storeErrorCode(err), "failed to export content: %s", err.Error()), nil
+			}
+			result["outputPath"] = path
+			result["bytes"] = len(ver.Content)
+		} else {
+			result["content"] = ver.Content
+		}
 		data, _ := json.MarshalIndent(result, "", "  ")
 		return mcp.NewToolResultText(string(data)), nil
 	})
@@ -104,16 +118,19 @@ func registerDiffStrategyVersions(s *server.MCPServer, st *store.Store) {
 
 	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		if st == nil {
-			return mcp.NewToolResultError("script store not initialized (check database config)"), nil
+			return toolError(codeUnavailable, "script store not initialized (check database config)"), nil
 		}
 
 		id := int64(req.GetFloat("id", 0))
 		v1 := int(req.GetFloat("version1", 0))
 		v2 := int(req.GetFloat("version2", 0))
 
+		if _, err := accessibleScript(ctx, st, id, false); err != nil {
+			return toolErrorf(storeErrorCode(err), "failed to get script: %s", err.Error()), nil
+		}
 		ver1, ver2, err := st.DiffVersions(id, v1, v2)
 		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("failed to diff versions: %s", err.Error())), nil
+			return toolErrorf(storeErrorCode(err), "failed to diff versions: %s", err.Error()), nil
 		}
 
 		// Simple line-based diff
@@ -166,7 +183,7 @@ func registerDiffStrategyVersions(s *server.MCPServer, st *store.Store) {
 	})
 }
 
-func registerRollbackStrategy(s *server.MCPServer, st *store.Store) {
+func registerRollbackStrategy(s *server.MCPServer, cfg *viper.Viper, st *store.Store) {
 	tool := mcp.NewTool("rollback_strategy",
 		mcp.WithDescription("Rollback a strategy to a previous version. Creates a new version with the rolled-back content."),
 		mcp.WithNumber("id", mcp.Required(), mcp.Description("Strategy ID")),
@@ -175,15 +192,19 @@ func registerRollbackStrategy(s *server.MCPServer, st *store.Store) {
 
 	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		if st == nil {
-			return mcp.NewToolResultError("script store not initialized (check database config)"), nil
+			return toolError(codeUnavailable, "script store not initialized (check database config)"), nil
 		}
 
 		id := int64(req.GetFloat("id", 0))
 		version := int(req.GetFloat("version", 0))
 
-		script, err := st.RollbackScript(id, version)
+		current, err := accessibleScript(ctx, st, id, true)
+		if err != nil {
+			return toolErrorf(storeErrorCode(err), "failed to get script: %s", err.Error()), nil
+		}
+		script, err := st.RollbackScript(id, version, versionQuota(ctx, cfg, current))
 		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("failed to rollback: %s", err.Error())), nil
+			return toolErrorf(storeErrorCode(err), "failed to rollback: %s", err.Error()), nil
 		}
 
 		result := map[string]interface{}{
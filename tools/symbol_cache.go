@@ -0,0 +1,91 @@
+package tools
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/ztrade/trademodel"
+)
+
+// defaultSymbolCacheTTL is how long an exchange's symbol list is reused
+// unless mcp.symbolCacheTTL says otherwise.
+const defaultSymbolCacheTTL = 5 * time.Minute
+
+// symbolCache keeps the Symbols() result of each configured exchange, keyed
+// by exchange config name, so list_symbols and the symbolRules lookups of
+// the backtest tools share one round trip per TTL.
+type symbolCache struct {
+	mu      sync.Mutex
+	entries map[string]symbolCacheEntry
+	fetch   func(cfg *viper.Viper, exchangeName string) ([]trademodel.Symbol, error)
+	now     func() time.Time
+}
+
+type symbolCacheEntry struct {
+	symbols []trademodel.Symbol
+	fetched time.Time
+}
+
+var exchangeSymbols = &symbolCache{
+	entries: make(map[string]symbolCacheEntry),
+	fetch:   fetchSymbols,
+	now:     time.Now,
+}
+
+// symbolCacheTTL reads mcp.symbolCacheTTL; zero or negative disables the
+// cache.
+func symbolCacheTTL(cfg *viper.Viper) time.Duration {
+	if cfg == nil || !cfg.IsSet("mcp.symbolCacheTTL") {
+		return defaultSymbolCacheTTL
+	}
+	return cfg.GetDuration("mcp.symbolCacheTTL")
+}
+
+// get returns the symbols of exchangeName and when they were fetched. A
+// cached list younger than the TTL is returned unless refresh is set.
+// Failed fetches are not cached.
+func (c *symbolCache) get(cfg *viper.Viper, exchangeName string, refresh bool) ([]trademodel.Symbol, time.Time, error) {
+	ttl := symbolCacheTTL(cfg)
+	c.mu.Lock()
+	e, ok := c.entries[exchangeName]
+	c.mu.Unlock()
+	if ok && !refresh && ttl > 0 && c.now().Sub(e.fetched) < ttl {
+		return e.symbols, e.fetched, nil
+	}
+
+	list, err := c.fetch(cfg, exchangeName)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	e = symbolCacheEntry{symbols: list, fetched: c.now()}
+	if ttl > 0 {
+		c.mu.Lock()
+		c.entries[exchangeName] = e
+		c.mu.Unlock()
+	}
+	return e.symbols, e.fetched, nil
+}
+
+// invalidate drops the cached symbols of exchangeName, for when its config
+// changes.
+func (c *symbolCache) invalidate(exchangeName string) {
+	c.mu.Lock()
+	delete(c.entries, exchangeName)
+	c.mu.Unlock()
+}
+
+// fetchSymbols asks the exchange configured under exchanges.<name> for its
+// symbols.
+func fetchSymbols(cfg *viper.Viper, exchangeName string) ([]trademodel.Symbol, error) {
+	ex, err := newExchangeClient(cfg, exchangeName)
+	if err != nil {
+		return nil, err
+	}
+	list, err := ex.Symbols()
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch symbols: %s", err.Error())
+	}
+	return list, nil
+}
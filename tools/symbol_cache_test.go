@@ -0,0 +1,58 @@
+package tools
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/ztrade/trademodel"
+)
+
+func TestSymbolCache(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	calls := 0
+	var fail error
+	c := &symbolCache{
+		entries: make(map[string]symbolCacheEntry),
+		fetch: func(cfg *viper.Viper, name string) ([]trademodel.Symbol, error) {
+			calls++
+			return []trademodel.Symbol{{Symbol: name}}, fail
+		},
+		now: func() time.Time { return now },
+	}
+	cfg := viper.New()
+
+	c.get(cfg, "binance", false)
+	now = now.Add(time.Minute)
+	if _, fetched, _ := c.get(cfg, "binance", false); calls != 1 || !fetched.Equal(now.Add(-time.Minute)) {
+		t.Fatalf("fresh entry refetched: %d calls, fetched %v", calls, fetched)
+	}
+	if c.get(cfg, "okx", false); calls != 2 {
+		t.Fatalf("exchanges share an entry: %d calls", calls)
+	}
+	if c.get(cfg, "binance", true); calls != 3 {
+		t.Fatalf("refresh served from cache: %d calls", calls)
+	}
+	now = now.Add(defaultSymbolCacheTTL)
+	if c.get(cfg, "binance", false); calls != 4 {
+		t.Fatalf("expired entry served: %d calls", calls)
+	}
+
+	// a failed fetch keeps nothing, the next call tries again
+	fail = errors.New("rate limited")
+	if _, _, err := c.get(cfg, "binance", true); err == nil {
+		t.Fatal("fetch error lost")
+	}
+	fail = nil
+	c.invalidate("binance")
+	if list, _, err := c.get(cfg, "binance", false); err != nil || calls != 6 || list[0].Symbol != "binance" {
+		t.Fatalf("after failure: %v, %d calls, %v", list, calls, err)
+	}
+
+	cfg.Set("mcp.symbolCacheTTL", "0s")
+	c.get(cfg, "bybit", false)
+	if c.get(cfg, "bybit", false); calls != 8 {
+		t.Fatalf("disabled cache served: %d calls", calls)
+	}
+}
@@ -0,0 +1,99 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/spf13/viper"
+	"github.com/ztrade/trademodel"
+)
+
+// lookupSymbol finds symbol among the symbols of one exchange like
+// matchSymbol. When it is not found, candidates lists the native symbols
+// sharing its canonical form, which are several when the notation is
+// ambiguous (e.g. okx spot and swap).
+func lookupSymbol(symbols []trademodel.Symbol, symbol string) (sym *trademodel.Symbol, candidates []string) {
+	natives := make([]string, len(symbols))
+	for i := range symbols {
+		natives[i] = symbols[i].Symbol
+	}
+	if native, ok := matchSymbol(natives, symbol); ok {
+		for i := range symbols {
+			if symbols[i].Symbol == native {
+				return &symbols[i], nil
+			}
+		}
+	}
+	want := canonicalSymbol(symbol)
+	for _, native := range natives {
+		if canonicalSymbol(native) == want {
+			candidates = append(candidates, native)
+		}
+	}
+	return nil, candidates
+}
+
+func registerGetSymbolInfo(s *server.MCPServer, cfg *viper.Viper) {
+	tool := mcp.NewTool("get_symbol_info",
+		mcp.WithDescription("Get the trading rules of one symbol on an exchange, for order sizing: price and amount precision, price and amount step, type, trading session, expiry and supported resolutions. Uses the same cached symbol list as list_symbols, and accepts the same notations (BTCUSDT, BTC-USDT, BTC/USDT). Fails with not_found when the exchange does not list the symbol."),
+		mcp.WithString("exchange", mcp.Required(), mcp.Description("Exchange config name (e.g., binance, okx). Must be configured in the config file.")),
+		mcp.WithString("symbol", mcp.Required(), mcp.Description("Trading pair in the exchange's native form or any common notation")),
+		mcp.WithBoolean("refresh", mcp.Description("Fetch the symbol list from the exchange even if a cached copy is still fresh. Default: false")),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		cfg := exchangeConfig(cfg)
+		exchangeName := req.GetString("exchange", "")
+		symbol := strings.TrimSpace(req.GetString("symbol", ""))
+		if symbol == "" {
+			return toolError(codeInvalidArgument, "symbol is required"), nil
+		}
+
+		exchangeType := cfg.GetString(fmt.Sprintf("exchanges.%s.type", exchangeName))
+		if exchangeType == "" {
+			return toolErrorf(codeNotFound, "exchange '%s' not found in config. Use list_exchanges to see configured exchanges.", exchangeName), nil
+		}
+
+		symbols, fetched, err := exchangeSymbols.get(cfg, exchangeName, req.GetBool("refresh", false))
+		if err != nil {
+			return toolError(codeUpstream, err.Error()), nil
+		}
+		sym, candidates := lookupSymbol(symbols, symbol)
+		if sym == nil {
+			if len(candidates) > 1 {
+				return toolErrorf(codeNotFound, "symbol %s is ambiguous on exchange %s, use one of: %s", symbol, exchangeName, strings.Join(candidates, ", ")), nil
+			}
+			return toolErrorf(codeNotFound, "symbol %s not found on exchange %s. Use list_symbols with a keyword to search.", symbol, exchangeName), nil
+		}
+
+		result := map[string]interface{}{
+			"exchange":        exchangeName,
+			"symbol":          sym.Symbol,
+			"canonical":       canonicalSymbol(sym.Symbol),
+			"name":            sym.Name,
+			"description":     sym.Description,
+			"type":            sym.Type,
+			"session":         sym.Session,
+			"precision":       sym.Precision,
+			"amountPrecision": sym.AmountPrecision,
+			"priceStep":       sym.PriceStep,
+			"amountStep":      sym.AmountStep,
+			"expired":         sym.Expired,
+			"resolutions":     sym.Resolutions,
+			"fetchedAt":       fetched.Format(time.RFC3339),
+		}
+		if sym.Expired {
+			result["expirationDate"] = sym.ExpirationDate.Format("2006-01-02 15:04:05")
+		}
+		if sym.Symbol != symbol {
+			result["requestedSymbol"] = symbol
+		}
+		data, _ := json.MarshalIndent(result, "", "  ")
+		return mcp.NewToolResultText(string(data)), nil
+	})
+}
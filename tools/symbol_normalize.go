@@ -0,0 +1,98 @@
+package tools
+
+import (
+	"context"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"github.com/ztrade/ztrade/pkg/process/dbstore"
+)
+
+// perpetualSuffixes mark perpetual contracts in native symbols, as in okx's
+// BTC-USDT-SWAP; they are not part of the canonical symbol.
+var perpetualSuffixes = []string{"SWAP", "PERP"}
+
+// canonicalSymbol returns the venue-independent form of a symbol: upper
+// case, without the separators of BTC-USDT, BTC/USDT or BTC_USDT and without
+// a perpetual suffix, so all of those and BTC-USDT-SWAP become BTCUSDT.
+func canonicalSymbol(symbol string) string {
+	parts := strings.FieldsFunc(strings.ToUpper(strings.TrimSpace(symbol)), func(r rune) bool {
+		return r == '-' || r == '/' || r == '_' || r == ':'
+	})
+	if n := len(parts); n > 1 {
+		for _, suffix := range perpetualSuffixes {
+			if parts[n-1] == suffix {
+				parts = parts[:n-1]
+				break
+			}
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+// matchSymbol finds symbol among the native symbols of one venue: an exact
+// case-insensitive match first, else the only native symbol with the same
+// canonical form. ok is false when there is none or several.
+func matchSymbol(natives []string, symbol string) (native string, ok bool) {
+	want := canonicalSymbol(symbol)
+	ambiguous := false
+	for _, s := range natives {
+		if strings.EqualFold(s, symbol) {
+			return s, true
+		}
+		if canonicalSymbol(s) != want {
+			continue
+		}
+		if native == "" {
+			native = s
+		} else if native != s {
+			ambiguous = true
+		}
+	}
+	if native == "" || ambiguous {
+		return "", false
+	}
+	return native, true
+}
+
+// resolveExchangeSymbol returns the native form of symbol on exchangeName,
+// matched against the exchange's cached symbol list. When the list cannot
+// be fetched or has no unambiguous match, symbol is returned unchanged and
+// the exchange reports the problem itself.
+func resolveExchangeSymbol(ctx context.Context, cfg *viper.Viper, exchangeName, symbol string) string {
+	symbols, _, err := exchangeSymbols.get(cfg, exchangeName, false)
+	if err != nil {
+		log.WithContext(ctx).WithError(err).Debug("symbol not normalized")
+		return symbol
+	}
+	natives := make([]string, len(symbols))
+	for i, s := range symbols {
+		natives[i] = s.Symbol
+	}
+	if native, ok := matchSymbol(natives, symbol); ok {
+		return native
+	}
+	return symbol
+}
+
+// resolveStoredSymbol returns the symbol the local klines of symbol on
+// exchange are stored under, which is the exchange's native form for data
+// from download_kline or fetch_kline. Without a match symbol is returned
+// unchanged.
+func resolveStoredSymbol(db *dbstore.DBStore, exchange, symbol string) string {
+	tbls, err := db.GetKlineTables()
+	if err != nil {
+		return symbol
+	}
+	var natives []string
+	for _, t := range tbls {
+		if t.Exchange == exchange {
+			natives = append(natives, t.Symbol)
+		}
+	}
+	if native, ok := matchSymbol(natives, symbol); ok {
+		return native
+	}
+	return symbol
+}
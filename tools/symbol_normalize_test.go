@@ -0,0 +1,86 @@
+package tools
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ztrade/trademodel"
+	"github.com/ztrade/ztrade/pkg/process/dbstore"
+)
+
+func TestCanonicalSymbol(t *testing.T) {
+	for in, want := range map[string]string{
+		"BTCUSDT":        "BTCUSDT",
+		"btc-usdt":       "BTCUSDT",
+		"BTC/USDT":       "BTCUSDT",
+		"BTC_USDT":       "BTCUSDT",
+		"BTC-USDT-SWAP":  "BTCUSDT",
+		"BTCUSDT-PERP":   "BTCUSDT",
+		"BTC-USD-240329": "BTCUSD240329",
+		" eth-usdt ":     "ETHUSDT",
+	} {
+		if got := canonicalSymbol(in); got != want {
+			t.Errorf("canonicalSymbol(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestMatchSymbol(t *testing.T) {
+	okx := []string{"BTC-USDT-SWAP", "ETH-USDT-SWAP"}
+	if got, ok := matchSymbol(okx, "BTCUSDT"); !ok || got != "BTC-USDT-SWAP" {
+		t.Fatalf("okx BTCUSDT: %q, %v", got, ok)
+	}
+	if got, ok := matchSymbol([]string{"BTCUSDT"}, "BTC/USDT"); !ok || got != "BTCUSDT" {
+		t.Fatalf("binance BTC/USDT: %q, %v", got, ok)
+	}
+	if _, ok := matchSymbol(okx, "SOLUSDT"); ok {
+		t.Fatal("unlisted symbol matched")
+	}
+	// spot and swap share a canonical form; only an exact match settles it
+	both := []string{"BTC-USDT", "BTC-USDT-SWAP"}
+	if _, ok := matchSymbol(both, "BTCUSDT"); ok {
+		t.Fatal("ambiguous symbol matched")
+	}
+	if got, ok := matchSymbol(both, "btc-usdt-swap"); !ok || got != "BTC-USDT-SWAP" {
+		t.Fatalf("exact match: %q, %v", got, ok)
+	}
+}
+
+func TestResolveStoredSymbol(t *testing.T) {
+	db, err := dbstore.NewDBStore("sqlite", filepath.Join(t.TempDir(), "kline.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	candles := []*trademodel.Candle{{Start: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).Unix(), Open: 1, High: 1, Low: 1, Close: 1}}
+	for _, sym := range []string{"BTC-USDT-SWAP", "ETHUSDT"} {
+		if _, err := saveFetchedKlines(db, "okx", sym, "1m", candles); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := resolveStoredSymbol(db, "okx", "BTCUSDT"); got != "BTC-USDT-SWAP" {
+		t.Fatalf("BTCUSDT resolved to %q", got)
+	}
+	if got := resolveStoredSymbol(db, "okx", "ETH/USDT"); got != "ETHUSDT" {
+		t.Fatalf("ETH/USDT resolved to %q", got)
+	}
+	if got := resolveStoredSymbol(db, "binance", "BTCUSDT"); got != "BTCUSDT" {
+		t.Fatalf("other exchange resolved to %q", got)
+	}
+}
+
+func TestLookupSymbol(t *testing.T) {
+	symbols := []trademodel.Symbol{{Symbol: "BTC-USDT"}, {Symbol: "BTC-USDT-SWAP"}, {Symbol: "ETH-USDT-SWAP", AmountStep: 0.01}}
+	if sym, _ := lookupSymbol(symbols, "eth/usdt"); sym == nil || sym.AmountStep != 0.01 {
+		t.Fatalf("ETH: %+v", sym)
+	}
+	if sym, _ := lookupSymbol(symbols, "BTC-USDT-SWAP"); sym == nil || sym.Symbol != "BTC-USDT-SWAP" {
+		t.Fatalf("exact: %+v", sym)
+	}
+	if sym, candidates := lookupSymbol(symbols, "BTCUSDT"); sym != nil || len(candidates) != 2 {
+		t.Fatalf("ambiguous: %+v, %v", sym, candidates)
+	}
+	if sym, candidates := lookupSymbol(symbols, "SOLUSDT"); sym != nil || len(candidates) != 0 {
+		t.Fatalf("missing: %+v, %v", sym, candidates)
+	}
+}
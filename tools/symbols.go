@@ -5,47 +5,45 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
+	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
-	"github.com/ztrade/exchange"
+	"github.com/ztrade/trademodel"
 )
 
 func registerListSymbols(s *server.MCPServer, cfg *viper.Viper) {
 	tool := mcp.NewTool("list_symbols",
-		mcp.WithDescription("List available trading symbols (pairs) from an exchange. Returns symbol name, precision, price/amount step, and other details."),
+		mcp.WithDescription("List available trading symbols (pairs) from an exchange. Returns the native symbol, its canonical form (e.g. BTCUSDT for BTC-USDT-SWAP, accepted by fetch_kline/download_kline/query_kline on any exchange), precision, price/amount step, and other details."),
 		mcp.WithString("exchange", mcp.Required(), mcp.Description("Exchange config name (e.g., binance, okx). Must be configured in the config file.")),
 		mcp.WithString("keyword", mcp.Description("Filter symbols by keyword (e.g., BTC, ETH, USDT). Case insensitive. Optional.")),
+		mcp.WithBoolean("refresh", mcp.Description("Fetch the symbol list from the exchange even if a cached copy is still fresh (cached for mcp.symbolCacheTTL, default 5m). Default: false")),
 	)
 
 	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		cfg := exchangeConfig(cfg)
 		exchangeName := req.GetString("exchange", "")
 
 		// Get exchange type from config
 		exchangeType := cfg.GetString(fmt.Sprintf("exchanges.%s.type", exchangeName))
 		if exchangeType == "" {
-			return mcp.NewToolResultError(fmt.Sprintf("exchange '%s' not found in config. Use list_exchanges to see configured exchanges.", exchangeName)), nil
+			return toolErrorf(codeNotFound, "exchange '%s' not found in config. Use list_exchanges to see configured exchanges.", exchangeName), nil
 		}
 
-		// Create exchange client
-		exchangeCfg := exchange.WrapViper(cfg)
-		ex, err := exchange.NewExchange(exchangeType, exchangeCfg, exchangeName)
+		symbols, fetched, err := exchangeSymbols.get(cfg, exchangeName, req.GetBool("refresh", false))
 		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("failed to create exchange client: %s", err.Error())), nil
+			return toolError(codeUpstream, err.Error()), nil
 		}
 
-		// Fetch symbols
-		symbols, err := ex.Symbols()
-		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("failed to fetch symbols: %s", err.Error())), nil
-		}
-
-		// Apply keyword filter
+		// Apply keyword filter, in any notation: BTC-USDT also finds BTCUSDT
 		keyword := strings.ToUpper(req.GetString("keyword", ""))
+		canonicalKeyword := canonicalSymbol(keyword)
 
 		type symbolEntry struct {
 			Symbol          string  `json:"symbol"`
+			Canonical       string  `json:"canonical"`
 			Name            string  `json:"name,omitempty"`
 			Type            string  `json:"type,omitempty"`
 			Precision       int     `json:"precision"`
@@ -56,12 +54,14 @@ func registerListSymbols(s *server.MCPServer, cfg *viper.Viper) {
 
 		var entries []symbolEntry
 		for _, sym := range symbols {
+			canonical := canonicalSymbol(sym.Symbol)
 			if keyword != "" && !strings.Contains(strings.ToUpper(sym.Symbol), keyword) &&
-				!strings.Contains(strings.ToUpper(sym.Name), keyword) {
+				!strings.Contains(strings.ToUpper(sym.Name), keyword) && !strings.Contains(canonical, canonicalKeyword) {
 				continue
 			}
 			entries = append(entries, symbolEntry{
 				Symbol:          sym.Symbol,
+				Canonical:       canonical,
 				Name:            sym.Name,
 				Type:            sym.Type,
 				Precision:       sym.Precision,
@@ -72,11 +72,46 @@ func registerListSymbols(s *server.MCPServer, cfg *viper.Viper) {
 		}
 
 		result := map[string]interface{}{
-			"exchange": exchangeName,
-			"total":    len(entries),
-			"symbols":  entries,
+			"exchange":  exchangeName,
+			"total":     len(entries),
+			"symbols":   entries,
+			"fetchedAt": fetched.Format(time.RFC3339),
 		}
 		data, _ := json.MarshalIndent(result, "", "  ")
 		return mcp.NewToolResultText(string(data)), nil
 	})
 }
+
+// findSymbol returns the trading rules of symbol on a configured exchange,
+// from the symbol cache when it is fresh.
+func findSymbol(cfg *viper.Viper, exchangeName, symbol string) (*trademodel.Symbol, error) {
+	cfg = exchangeConfig(cfg)
+	exchangeType := cfg.GetString(fmt.Sprintf("exchanges.%s.type", exchangeName))
+	if exchangeType == "" {
+		return nil, fmt.Errorf("exchange '%s' not found in config", exchangeName)
+	}
+	symbols, _, err := exchangeSymbols.get(cfg, exchangeName, false)
+	if err != nil {
+		return nil, err
+	}
+	if sym, _ := lookupSymbol(symbols, symbol); sym != nil {
+		return sym, nil
+	}
+	return nil, fmt.Errorf("symbol %s not listed on %s", symbol, exchangeName)
+}
+
+// loadSymbolRules applies the symbolRules tool argument: unless lotSize was
+// given, the exchange's amount step for symbol becomes the cost model's lot
+// size.
+func loadSymbolRules(ctx context.Context, cfg *viper.Viper, req mcp.CallToolRequest, costs *TradingCostModel, exchangeName, symbol string) error {
+	if !req.GetBool("symbolRules", false) || costs.AmountStep > 0 {
+		return nil
+	}
+	sym, err := findSymbol(cfg, exchangeName, symbol)
+	if err != nil {
+		return err
+	}
+	costs.AmountStep = sym.AmountStep
+	log.WithContext(ctx).WithFields(log.Fields{"symbol": symbol, "amountStep": sym.AmountStep}).Info("loaded symbol trading rules")
+	return nil
+}
@@ -1,13 +1,20 @@
 package tools
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"math"
+	"net/url"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/ztrade/ztrade-mcp/auth"
 )
 
 // TaskStatus represents the current state of an async task.
@@ -38,18 +45,29 @@ type Task struct {
 	CreatedAt time.Time         `json:"createdAt"`
 	StartedAt *time.Time        `json:"startedAt,omitempty"`
 	EndedAt   *time.Time        `json:"endedAt,omitempty"`
+	Retries   int               `json:"retries,omitempty"` // times restarted by retry_task
+
+	done      chan struct{} // closed when the task reaches a terminal state
+	dedupeKey string        // see CreateTaskOnce
+}
+
+// Terminal reports whether the task has completed or failed.
+func (t *Task) Terminal() bool {
+	return t.Status == TaskStatusCompleted || t.Status == TaskStatusFailed
 }
 
 // TaskManager manages async tasks.
 type TaskManager struct {
-	mu    sync.RWMutex
-	tasks map[string]*Task
+	mu       sync.RWMutex
+	tasks    map[string]*Task
+	inflight map[string]string // dedupe key -> ID of a pending or running task
 }
 
 // NewTaskManager creates a new task manager.
 func NewTaskManager() *TaskManager {
 	return &TaskManager{
-		tasks: make(map[string]*Task),
+		tasks:    make(map[string]*Task),
+		inflight: make(map[string]string),
 	}
 }
 
@@ -57,7 +75,29 @@ func NewTaskManager() *TaskManager {
 func (tm *TaskManager) CreateTask(taskType string, params map[string]string) string {
 	tm.mu.Lock()
 	defer tm.mu.Unlock()
+	return tm.createTaskLocked(taskType, params)
+}
+
+// CreateTaskOnce is CreateTask for tasks identified by key, usually from
+// taskDedupeKey: while a task created with the same key is pending or
+// running, it returns that task's ID and false instead of creating one. An
+// empty key always creates a task.
+func (tm *TaskManager) CreateTaskOnce(taskType string, params map[string]string, key string) (string, bool) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	if id, ok := tm.inflight[key]; ok && key != "" {
+		return id, false
+	}
+	id := tm.createTaskLocked(taskType, params)
+	if key != "" {
+		tm.tasks[id].dedupeKey = key
+		tm.inflight[key] = id
+	}
+	return id, true
+}
 
+func (tm *TaskManager) createTaskLocked(taskType string, params map[string]string) string {
 	id := uuid.New().String()[:8]
 	task := &Task{
 		ID:        id,
@@ -67,6 +107,7 @@ func (tm *TaskManager) CreateTask(taskType string, params map[string]string) str
 		Percent:   0,
 		Params:    params,
 		CreatedAt: time.Now(),
+		done:      make(chan struct{}),
 	}
 	tm.tasks[id] = task
 	return id
@@ -101,13 +142,15 @@ func (tm *TaskManager) CompleteTask(id string, result string) {
 	tm.mu.Lock()
 	defer tm.mu.Unlock()
 
-	if t, ok := tm.tasks[id]; ok {
+	if t, ok := tm.tasks[id]; ok && !t.Terminal() {
 		t.Status = TaskStatusCompleted
 		t.Result = result
 		t.Progress = "completed"
 		t.Percent = 100
 		now := time.Now()
 		t.EndedAt = &now
+		close(t.done)
+		tm.releaseLocked(t)
 	}
 }
 
@@ -116,15 +159,84 @@ func (tm *TaskManager) FailTask(id string, errMsg string) {
 	tm.mu.Lock()
 	defer tm.mu.Unlock()
 
-	if t, ok := tm.tasks[id]; ok {
+	if t, ok := tm.tasks[id]; ok && !t.Terminal() {
 		t.Status = TaskStatusFailed
 		t.Error = errMsg
 		t.Progress = "failed"
 		now := time.Now()
 		t.EndedAt = &now
+		close(t.done)
+		tm.releaseLocked(t)
 	}
 }
 
+// RetryTask puts a failed task back to pending so it can be run again
+// under the same ID and params, and returns how many times it has been
+// retried. The task takes its dedupe key back unless an identical task was
+// started in the meantime.
+func (tm *TaskManager) RetryTask(id string) (int, error) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	t, ok := tm.tasks[id]
+	if !ok {
+		return 0, fmt.Errorf("task '%s' not found", id)
+	}
+	if t.Status != TaskStatusFailed {
+		return 0, fmt.Errorf("task '%s' is %s, only failed tasks can be retried", id, t.Status)
+	}
+	if t.dedupeKey != "" {
+		if other, ok := tm.inflight[t.dedupeKey]; ok {
+			return 0, fmt.Errorf("an identical task '%s' is already in flight", other)
+		}
+		tm.inflight[t.dedupeKey] = id
+	}
+	t.Status = TaskStatusPending
+	t.Progress = "waiting to retry"
+	t.Percent = 0
+	t.Error = ""
+	t.StartedAt = nil
+	t.EndedAt = nil
+	t.Retries++
+	t.done = make(chan struct{})
+	return t.Retries, nil
+}
+
+// releaseLocked lets a finished task's dedupe key start a new task.
+func (tm *TaskManager) releaseLocked(t *Task) {
+	if t.dedupeKey != "" && tm.inflight[t.dedupeKey] == t.ID {
+		delete(tm.inflight, t.dedupeKey)
+	}
+}
+
+// taskDedupeKey identifies an async task started by req: the client's
+// idempotencyKey if given, else a hash of all arguments, so an identical
+// retry maps to the same key. Keys are scoped to the authenticated caller,
+// so users never share each other's tasks or idempotency keys.
+func taskDedupeKey(ctx context.Context, taskType string, req mcp.CallToolRequest) string {
+	caller := ""
+	if user := auth.UserFromContext(ctx); user != nil {
+		caller = user.Name
+	}
+	prefix := taskType + "/" + url.PathEscape(caller)
+	if key := req.GetString("idempotencyKey", ""); key != "" {
+		return prefix + "/key/" + key
+	}
+	args := make(map[string]interface{}, len(req.GetArguments()))
+	for k, v := range req.GetArguments() {
+		if k != "idempotencyKey" {
+			args[k] = v
+		}
+	}
+	// map keys are marshalled in sorted order
+	data, err := json.Marshal(args)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return prefix + "/args/" + hex.EncodeToString(sum[:])
+}
+
 // GetTask returns a task by ID.
 func (tm *TaskManager) GetTask(id string) (*Task, error) {
 	tm.mu.RLock()
@@ -137,22 +249,132 @@ func (tm *TaskManager) GetTask(id string) (*Task, error) {
 	return t, nil
 }
 
-// ListTasks returns all tasks, optionally filtered by type and status.
-func (tm *TaskManager) ListTasks(taskType string, status string) []*Task {
+// WaitTask blocks until the task reaches a terminal state, timeout elapses
+// or ctx is done, then returns the task in whatever state it is.
+func (tm *TaskManager) WaitTask(ctx context.Context, id string, timeout time.Duration) (*Task, error) {
 	tm.mu.RLock()
-	defer tm.mu.RUnlock()
+	t, ok := tm.tasks[id]
+	if !ok {
+		tm.mu.RUnlock()
+		return nil, fmt.Errorf("task '%s' not found", id)
+	}
+	// RetryTask replaces the channel of a failed task
+	done := t.done
+	tm.mu.RUnlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+	return t, nil
+}
+
+// TaskFilter selects tasks for ListTasks. Zero fields match everything.
+type TaskFilter struct {
+	Type   string
+	Status string
+	Since  time.Time // created at or after
+	Until  time.Time // created before
+	Offset int
+	Limit  int // 0 returns all remaining tasks
+}
 
+// ListTasks returns the tasks matching filter, newest first, and how many
+// matched before pagination.
+func (tm *TaskManager) ListTasks(filter TaskFilter) ([]*Task, int) {
+	tm.mu.RLock()
 	var result []*Task
 	for _, t := range tm.tasks {
-		if taskType != "" && t.Type != taskType {
+		if filter.Type != "" && t.Type != filter.Type {
+			continue
+		}
+		if filter.Status != "" && string(t.Status) != filter.Status {
+			continue
+		}
+		if !filter.Since.IsZero() && t.CreatedAt.Before(filter.Since) {
 			continue
 		}
-		if status != "" && string(t.Status) != status {
+		if !filter.Until.IsZero() && !t.CreatedAt.Before(filter.Until) {
 			continue
 		}
 		result = append(result, t)
 	}
-	return result
+	tm.mu.RUnlock()
+
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].CreatedAt.Equal(result[j].CreatedAt) {
+			return result[i].CreatedAt.After(result[j].CreatedAt)
+		}
+		return result[i].ID < result[j].ID
+	})
+	total := len(result)
+	if filter.Offset >= total {
+		return nil, total
+	}
+	result = result[filter.Offset:]
+	if filter.Limit > 0 && filter.Limit < len(result) {
+		result = result[:filter.Limit]
+	}
+	return result, total
+}
+
+// RunningTask is a running task as summarized by Stats.
+type RunningTask struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Percent  int    `json:"percent"`
+	Progress string `json:"progress"`
+	Running  string `json:"running"`       // time since it started
+	ETA      string `json:"eta,omitempty"` // remaining time extrapolated from Percent
+}
+
+// TaskStats is the in-memory aggregate of all tasks.
+type TaskStats struct {
+	Counts        map[TaskStatus]int `json:"counts"`
+	Running       []RunningTask      `json:"running"`
+	OldestRunning *RunningTask       `json:"oldestRunning,omitempty"`
+}
+
+// Stats counts the tasks by status and summarizes the running ones, oldest
+// first, as of now. The remaining time of a running task is extrapolated
+// from its elapsed time and percent, which for most tasks is itself the
+// ProgressEstimator's guess.
+func (tm *TaskManager) Stats(now time.Time) TaskStats {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+
+	stats := TaskStats{Running: []RunningTask{}, Counts: map[TaskStatus]int{
+		TaskStatusPending: 0, TaskStatusRunning: 0, TaskStatusCompleted: 0, TaskStatusFailed: 0,
+	}}
+	var running []*Task
+	for _, t := range tm.tasks {
+		stats.Counts[t.Status]++
+		if t.Status == TaskStatusRunning && t.StartedAt != nil {
+			running = append(running, t)
+		}
+	}
+	sort.Slice(running, func(i, j int) bool {
+		if !running[i].StartedAt.Equal(*running[j].StartedAt) {
+			return running[i].StartedAt.Before(*running[j].StartedAt)
+		}
+		return running[i].ID < running[j].ID
+	})
+	for _, t := range running {
+		elapsed := now.Sub(*t.StartedAt)
+		rt := RunningTask{ID: t.ID, Type: t.Type, Percent: t.Percent, Progress: t.Progress, Running: elapsed.Truncate(time.Second).String()}
+		if t.Percent > 0 && t.Percent < 100 {
+			rt.ETA = (elapsed * time.Duration(100-t.Percent) / time.Duration(t.Percent)).Truncate(time.Second).String()
+		}
+		stats.Running = append(stats.Running, rt)
+	}
+	if len(stats.Running) > 0 {
+		oldest := stats.Running[0]
+		stats.OldestRunning = &oldest
+	}
+	return stats
 }
 
 // ShouldRunAsync determines if a task should run asynchronously
@@ -171,9 +393,11 @@ func TaskResultJSON(task *Task) string {
 // EstimatedSecondsPerDay is the rough estimation of how long (in seconds)
 // it takes to process one day of data. Tuned per task type.
 var estimatedSecondsPerDay = map[string]float64{
-	"backtest":         0.5, // backtest is compute-heavy but data is local
-	"backtest_managed": 0.5,
-	"download":         2.0, // download is network-bound, slower per day
+	"backtest":           0.5, // backtest is compute-heavy but data is local
+	"backtest_managed":   0.5,
+	"benchmark":          1.0, // strategy plus baseline backtest
+	"portfolio_backtest": 2.0, // one backtest per leg
+	"download":           2.0, // download is network-bound, slower per day
 }
 
 // ProgressEstimator runs a background ticker that updates the task's progress
@@ -0,0 +1,228 @@
+package tools
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/ztrade/ztrade-mcp/auth"
+)
+
+func TestListTasksOrderAndPagination(t *testing.T) {
+	tm := NewTaskManager()
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local)
+	var ids []string
+	for i := 0; i < 5; i++ {
+		typ := "backtest"
+		if i%2 == 1 {
+			typ = "download"
+		}
+		id := tm.CreateTask(typ, nil)
+		tm.tasks[id].CreatedAt = base.Add(time.Duration(i) * time.Hour)
+		ids = append(ids, id)
+	}
+
+	all, total := tm.ListTasks(TaskFilter{})
+	if total != 5 || len(all) != 5 {
+		t.Fatalf("got %d of %d tasks", len(all), total)
+	}
+	for i, task := range all {
+		if task.ID != ids[4-i] {
+			t.Fatalf("task %d = %s, want newest first", i, task.ID)
+		}
+	}
+
+	page, total := tm.ListTasks(TaskFilter{Offset: 1, Limit: 2})
+	if total != 5 || len(page) != 2 || page[0].ID != ids[3] || page[1].ID != ids[2] {
+		t.Fatalf("page: %d tasks of %d", len(page), total)
+	}
+	if page, _ := tm.ListTasks(TaskFilter{Offset: 10}); len(page) != 0 {
+		t.Fatalf("offset past end returned %d tasks", len(page))
+	}
+
+	ranged, total := tm.ListTasks(TaskFilter{Type: "backtest", Since: base.Add(time.Hour), Until: base.Add(4 * time.Hour)})
+	if total != 1 || ranged[0].ID != ids[2] {
+		t.Fatalf("filtered: %d tasks", total)
+	}
+}
+
+func TestWaitTask(t *testing.T) {
+	tm := NewTaskManager()
+	ctx := context.Background()
+
+	id := tm.CreateTask("backtest", nil)
+	if task, err := tm.WaitTask(ctx, id, 10*time.Millisecond); err != nil || task.Terminal() {
+		t.Fatalf("timeout: %v, %v", task, err)
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		tm.CompleteTask(id, "{}")
+	}()
+	task, err := tm.WaitTask(ctx, id, 5*time.Second)
+	if err != nil || task.Status != TaskStatusCompleted {
+		t.Fatalf("completed: %v, %v", task, err)
+	}
+	// a finished task stays finished and returns at once
+	tm.FailTask(id, "late")
+	if task, _ := tm.WaitTask(ctx, id, time.Hour); task.Status != TaskStatusCompleted {
+		t.Fatalf("status after late FailTask: %s", task.Status)
+	}
+
+	if _, err := tm.WaitTask(ctx, "missing", time.Millisecond); err == nil {
+		t.Fatal("missing task: no error")
+	}
+}
+
+func TestCreateTaskOnce(t *testing.T) {
+	tm := NewTaskManager()
+	req := func(args map[string]any) mcp.CallToolRequest {
+		var r mcp.CallToolRequest
+		r.Params.Arguments = args
+		return r
+	}
+	ctx := context.Background()
+	a := taskDedupeKey(ctx, "backtest", req(map[string]any{"symbol": "BTCUSDT", "start": "2024-01-01"}))
+	if b := taskDedupeKey(ctx, "backtest", req(map[string]any{"start": "2024-01-01", "symbol": "BTCUSDT"})); a != b {
+		t.Fatalf("same arguments, different keys: %s, %s", a, b)
+	}
+	if b := taskDedupeKey(ctx, "download", req(map[string]any{"symbol": "BTCUSDT", "start": "2024-01-01"})); a == b {
+		t.Fatal("task type not part of the key")
+	}
+	if b := taskDedupeKey(ctx, "backtest", req(map[string]any{"symbol": "ETHUSDT", "start": "2024-01-01"})); a == b {
+		t.Fatal("different arguments share a key")
+	}
+	k1 := taskDedupeKey(ctx, "backtest", req(map[string]any{"symbol": "BTCUSDT", "idempotencyKey": "x"}))
+	if k2 := taskDedupeKey(ctx, "backtest", req(map[string]any{"symbol": "ETHUSDT", "idempotencyKey": "x"})); k1 != k2 {
+		t.Fatal("idempotencyKey does not override the arguments")
+	}
+	alice := auth.ContextWithUser(ctx, &auth.User{Name: "alice", Role: "trader"})
+	bob := auth.ContextWithUser(ctx, &auth.User{Name: "bob", Role: "trader"})
+	args := map[string]any{"symbol": "BTCUSDT", "start": "2024-01-01"}
+	if taskDedupeKey(alice, "backtest", req(args)) == taskDedupeKey(bob, "backtest", req(args)) {
+		t.Fatal("users share a key for the same arguments")
+	}
+	keyed := map[string]any{"idempotencyKey": "x"}
+	if taskDedupeKey(alice, "backtest", req(keyed)) == taskDedupeKey(bob, "backtest", req(keyed)) {
+		t.Fatal("users share an idempotencyKey")
+	}
+
+	first, created := tm.CreateTaskOnce("backtest", nil, a)
+	if !created {
+		t.Fatal("first task not created")
+	}
+	tm.StartTask(first)
+	if id, created := tm.CreateTaskOnce("backtest", nil, a); created || id != first {
+		t.Fatalf("retry while running: %s, %v", id, created)
+	}
+	if _, created := tm.CreateTaskOnce("backtest", nil, ""); !created {
+		t.Fatal("empty key deduplicated")
+	}
+	tm.CompleteTask(first, "{}")
+	if id, created := tm.CreateTaskOnce("backtest", nil, a); !created || id == first {
+		t.Fatalf("retry after completion: %s, %v", id, created)
+	}
+
+	// concurrent retries start a single task
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	ids := map[string]bool{}
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			id, _ := tm.CreateTaskOnce("download", nil, "k")
+			mu.Lock()
+			ids[id] = true
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	if len(ids) != 1 {
+		t.Fatalf("concurrent retries created %d tasks", len(ids))
+	}
+}
+
+func TestRetryTask(t *testing.T) {
+	tm := NewTaskManager()
+	id, _ := tm.CreateTaskOnce("download", map[string]string{"symbol": "BTCUSDT"}, "k")
+	if _, err := tm.RetryTask(id); err == nil {
+		t.Fatal("pending task retried")
+	}
+	tm.StartTask(id)
+	tm.FailTask(id, "network error")
+
+	retries, err := tm.RetryTask(id)
+	if err != nil || retries != 1 {
+		t.Fatalf("retry: %d, %v", retries, err)
+	}
+	task, _ := tm.GetTask(id)
+	if task.Status != TaskStatusPending || task.Error != "" || task.EndedAt != nil || task.Params["symbol"] != "BTCUSDT" {
+		t.Fatalf("task after retry: %+v", task)
+	}
+	// the retried task is in flight again under its key
+	if other, created := tm.CreateTaskOnce("download", nil, "k"); created || other != id {
+		t.Fatalf("identical task while retrying: %s, %v", other, created)
+	}
+	// and can be waited for again
+	tm.StartTask(id)
+	go tm.CompleteTask(id, "{}")
+	if task, _ := tm.WaitTask(context.Background(), id, time.Second); task.Status != TaskStatusCompleted {
+		t.Fatalf("status after retried run: %s", task.Status)
+	}
+	if _, err := tm.RetryTask(id); err == nil {
+		t.Fatal("completed task retried")
+	}
+
+	// a failed task whose key was reused meanwhile cannot take it back
+	id, _ = tm.CreateTaskOnce("download", nil, "j")
+	tm.FailTask(id, "network error")
+	tm.CreateTaskOnce("download", nil, "j")
+	if _, err := tm.RetryTask(id); err == nil {
+		t.Fatal("retry while an identical task is in flight")
+	}
+	if _, err := tm.RetryTask("missing"); err == nil {
+		t.Fatal("missing task retried")
+	}
+}
+
+func TestTaskStats(t *testing.T) {
+	tm := NewTaskManager()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	at := func(id string, ago time.Duration, percent int) {
+		tm.StartTask(id)
+		started := now.Add(-ago)
+		tm.tasks[id].StartedAt = &started
+		tm.UpdateProgress(id, "running", percent)
+	}
+	old := tm.CreateTask("download", nil)
+	at(old, 30*time.Minute, 75)
+	recent := tm.CreateTask("backtest", nil)
+	at(recent, time.Minute, 0)
+	tm.CreateTask("backtest", nil)
+	failed := tm.CreateTask("backtest", nil)
+	tm.FailTask(failed, "boom")
+
+	stats := tm.Stats(now)
+	want := map[TaskStatus]int{TaskStatusPending: 1, TaskStatusRunning: 2, TaskStatusCompleted: 0, TaskStatusFailed: 1}
+	for status, n := range want {
+		if stats.Counts[status] != n {
+			t.Fatalf("counts: %v", stats.Counts)
+		}
+	}
+	if len(stats.Running) != 2 || stats.Running[0].ID != old || stats.OldestRunning == nil || stats.OldestRunning.ID != old {
+		t.Fatalf("running: %+v", stats.Running)
+	}
+	// 75% after 30m leaves 10m
+	if stats.Running[0].Running != "30m0s" || stats.Running[0].ETA != "10m0s" {
+		t.Fatalf("oldest: %+v", stats.Running[0])
+	}
+	if stats.Running[1].ETA != "" {
+		t.Fatalf("ETA without progress: %+v", stats.Running[1])
+	}
+	if empty := NewTaskManager().Stats(now); empty.Running == nil || empty.OldestRunning != nil {
+		t.Fatalf("no tasks: %+v", empty)
+	}
+}
@@ -4,11 +4,34 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 )
 
+const (
+	taskWaitDefaultSec = 30
+	taskWaitMaxSec     = 300
+)
+
+// idempotencyKeyOption declares the argument read by taskDedupeKey.
+func idempotencyKeyOption() mcp.ToolOption {
+	return mcp.WithString("idempotencyKey", mcp.Description("Async runs only: while a task started with the same key is pending or running, return its taskId instead of starting another. Default: a hash of all other arguments, so identical retries are deduplicated"))
+}
+
+// dedupedTaskResult answers a call whose task is already in flight.
+func dedupedTaskResult(taskID string) *mcp.CallToolResult {
+	result := map[string]interface{}{
+		"async":        true,
+		"taskId":       taskID,
+		"deduplicated": true,
+		"message":      fmt.Sprintf("An identical task is already in flight; returning its taskId '%s' instead of starting another. Use get_task_status or get_task_result with it.", taskID),
+	}
+	data, _ := json.MarshalIndent(result, "", "  ")
+	return mcp.NewToolResultText(string(data))
+}
+
 func registerGetTaskStatus(s *server.MCPServer, tm *TaskManager) {
 	tool := mcp.NewTool("get_task_status",
 		mcp.WithDescription("Get the current status and progress of an async task (backtest or download). Returns task status (pending/running/completed/failed), progress description and completion percentage."),
@@ -20,7 +43,7 @@ func registerGetTaskStatus(s *server.MCPServer, tm *TaskManager) {
 
 		task, err := tm.GetTask(taskID)
 		if err != nil {
-			return mcp.NewToolResultError(err.Error()), nil
+			return toolError(codeNotFound, err.Error()), nil
 		}
 
 		status := map[string]interface{}{
@@ -41,6 +64,9 @@ func registerGetTaskStatus(s *server.MCPServer, tm *TaskManager) {
 		if task.Status == TaskStatusFailed {
 			status["error"] = task.Error
 		}
+		if task.Retries > 0 {
+			status["retries"] = task.Retries
+		}
 
 		data, _ := json.MarshalIndent(status, "", "  ")
 		return mcp.NewToolResultText(string(data)), nil
@@ -49,16 +75,29 @@ func registerGetTaskStatus(s *server.MCPServer, tm *TaskManager) {
 
 func registerGetTaskResult(s *server.MCPServer, tm *TaskManager) {
 	tool := mcp.NewTool("get_task_result",
-		mcp.WithDescription("Get the final result of a completed async task (backtest or download). Returns the full result data if the task is completed, or current status if still running."),
+		mcp.WithDescription("Get the final result of a completed async task (backtest or download). Returns the full result data if the task is completed, or current status if still running. "+
+			"With wait=true the call blocks until the task completes or fails, or timeoutSec elapses, instead of returning immediately."),
 		mcp.WithString("taskId", mcp.Required(), mcp.Description("The task ID returned by an async backtest or download call")),
+		mcp.WithBoolean("wait", mcp.Description("Long-poll: wait server-side for the task to finish before returning. Default: false")),
+		mcp.WithNumber("timeoutSec", mcp.Description(fmt.Sprintf("With wait: maximum seconds to wait, up to %d. Default: %d", taskWaitMaxSec, taskWaitDefaultSec))),
 	)
 
 	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		taskID := req.GetString("taskId", "")
 
-		task, err := tm.GetTask(taskID)
+		var task *Task
+		var err error
+		if req.GetBool("wait", false) {
+			timeoutSec := req.GetFloat("timeoutSec", taskWaitDefaultSec)
+			if timeoutSec <= 0 || timeoutSec > taskWaitMaxSec {
+				return toolErrorf(codeInvalidArgument, "timeoutSec must be in (0, %d]", taskWaitMaxSec), nil
+			}
+			task, err = tm.WaitTask(ctx, taskID, time.Duration(timeoutSec*float64(time.Second)))
+		} else {
+			task, err = tm.GetTask(taskID)
+		}
 		if err != nil {
-			return mcp.NewToolResultError(err.Error()), nil
+			return toolError(codeNotFound, err.Error()), nil
 		}
 
 		switch task.Status {
@@ -88,7 +127,7 @@ func registerGetTaskResult(s *server.MCPServer, tm *TaskManager) {
 				"taskId": task.ID,
 				"type":   task.Type,
 				"status": task.Status,
-				"error":  task.Error,
+				"error":  toolErrorBody{Code: codeTaskFailed, Message: task.Error},
 			}
 			data, _ := json.MarshalIndent(result, "", "  ")
 			return mcp.NewToolResultError(string(data)), nil
@@ -101,7 +140,7 @@ func registerGetTaskResult(s *server.MCPServer, tm *TaskManager) {
 				"status":   task.Status,
 				"progress": task.Progress,
 				"percent":  task.Percent,
-				"message":  fmt.Sprintf("Task is still %s. Use get_task_status to continue polling.", task.Status),
+				"message":  fmt.Sprintf("Task is still %s. Call get_task_result with wait=true to wait for it, or get_task_status to poll.", task.Status),
 			}
 			data, _ := json.MarshalIndent(result, "", "  ")
 			return mcp.NewToolResultText(string(data)), nil
@@ -109,18 +148,59 @@ func registerGetTaskResult(s *server.MCPServer, tm *TaskManager) {
 	})
 }
 
+// parseLocalDateTime is parseDateTime in the server's time zone, which task
+// creation times are reported in.
+func parseLocalDateTime(s string) (time.Time, error) {
+	t, err := parseDateTime(s)
+	if err != nil {
+		return t, err
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.Local), nil
+}
+
 func registerListTasks(s *server.MCPServer, tm *TaskManager) {
 	tool := mcp.NewTool("list_tasks",
-		mcp.WithDescription("List all async tasks. Optionally filter by type (backtest/download) and status (pending/running/completed/failed)."),
+		mcp.WithDescription("List async tasks, newest first. Optionally filter by type (backtest/download), status (pending/running/completed/failed) and creation time; paginate with offset/limit."),
 		mcp.WithString("type", mcp.Description("Filter by task type: 'backtest' or 'download'")),
 		mcp.WithString("status", mcp.Description("Filter by status: 'pending', 'running', 'completed', 'failed'")),
+		mcp.WithString("since", mcp.Description("Only tasks created at or after this time, '2006-01-02' or '2006-01-02 15:04:05'")),
+		mcp.WithString("until", mcp.Description("Only tasks created before this time, '2006-01-02' or '2006-01-02 15:04:05'")),
+		mcp.WithNumber("offset", mcp.Description("Number of tasks to skip. Default: 0")),
+		mcp.WithNumber("limit", mcp.Description("Maximum number of tasks to return. Default: 50, Max: 500")),
 	)
 
 	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-		taskType := req.GetString("type", "")
-		status := req.GetString("status", "")
+		filter := TaskFilter{
+			Type:   req.GetString("type", ""),
+			Status: req.GetString("status", ""),
+			Offset: int(req.GetFloat("offset", 0)),
+			Limit:  int(req.GetFloat("limit", 0)),
+		}
+		if v := req.GetString("since", ""); v != "" {
+			t, err := parseLocalDateTime(v)
+			if err != nil {
+				return toolErrorf(codeInvalidArgument, "invalid since time: %s", err.Error()), nil
+			}
+			filter.Since = t
+		}
+		if v := req.GetString("until", ""); v != "" {
+			t, err := parseLocalDateTime(v)
+			if err != nil {
+				return toolErrorf(codeInvalidArgument, "invalid until time: %s", err.Error()), nil
+			}
+			filter.Until = t
+		}
+		if filter.Offset < 0 || filter.Limit < 0 {
+			return toolError(codeInvalidArgument, "offset and limit must not be negative"), nil
+		}
+		if filter.Limit == 0 {
+			filter.Limit = 50
+		}
+		if filter.Limit > 500 {
+			filter.Limit = 500
+		}
 
-		tasks := tm.ListTasks(taskType, status)
+		tasks, total := tm.ListTasks(filter)
 
 		type taskSummary struct {
 			ID        string     `json:"id"`
@@ -132,7 +212,7 @@ func registerListTasks(s *server.MCPServer, tm *TaskManager) {
 			Duration  string     `json:"duration,omitempty"`
 		}
 
-		var summaries []taskSummary
+		summaries := make([]taskSummary, 0, len(tasks))
 		for _, t := range tasks {
 			s := taskSummary{
 				ID:        t.ID,
@@ -149,8 +229,10 @@ func registerListTasks(s *server.MCPServer, tm *TaskManager) {
 		}
 
 		result := map[string]interface{}{
-			"total": len(summaries),
-			"tasks": summaries,
+			"total":    total,
+			"offset":   filter.Offset,
+			"returned": len(summaries),
+			"tasks":    summaries,
 		}
 		data, _ := json.MarshalIndent(result, "", "  ")
 		return mcp.NewToolResultText(string(data)), nil
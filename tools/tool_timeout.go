@@ -0,0 +1,108 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// defaultToolTimeout bounds a tool call when neither mcp.toolTimeouts.default
+// nor a setting for the tool is configured.
+const defaultToolTimeout = 10 * time.Minute
+
+// timeoutExemptTools are never cut by ToolTimeoutMiddleware: get_task_result
+// waits for async tasks on purpose and bounds the wait with its own
+// timeoutSec. The async tasks of download_kline, run_backtest and the like
+// run detached from the request and are not affected either; only their
+// synchronous calls are bounded.
+var timeoutExemptTools = map[string]bool{
+	"get_task_result": true,
+}
+
+// toolTimeouts is the mcp.toolTimeouts config: a default and per-tool
+// durations keyed by tool name. 0 disables the timeout.
+type toolTimeouts struct {
+	def     time.Duration
+	perTool map[string]time.Duration
+}
+
+// toolTimeoutsFromConfig reads mcp.toolTimeouts.{default,<tool>}.
+func toolTimeoutsFromConfig(cfg *viper.Viper) toolTimeouts {
+	t := toolTimeouts{def: defaultToolTimeout, perTool: map[string]time.Duration{}}
+	if cfg == nil {
+		return t
+	}
+	for name := range cfg.GetStringMap("mcp.toolTimeouts") {
+		d := cfg.GetDuration("mcp.toolTimeouts." + name)
+		if name == "default" {
+			t.def = d
+		} else {
+			t.perTool[name] = d
+		}
+	}
+	return t
+}
+
+// forTool returns the timeout of a tool; 0 or less means none.
+func (t toolTimeouts) forTool(name string) time.Duration {
+	if timeoutExemptTools[name] {
+		return 0
+	}
+	if d, ok := t.perTool[name]; ok {
+		return d
+	}
+	return t.def
+}
+
+// ToolTimeoutMiddleware bounds each tool call by its mcp.toolTimeouts
+// setting, so one slow exchange or a huge synchronous range cannot hang the
+// client session. The handler's context is cancelled at the deadline and the
+// call returns a deadline_exceeded error right away; a handler that ignores
+// its context finishes in the background and its result is dropped.
+func ToolTimeoutMiddleware(cfg *viper.Viper) server.ToolHandlerMiddleware {
+	timeouts := toolTimeoutsFromConfig(cfg)
+	return func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+		return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			name := req.Params.Name
+			timeout := timeouts.forTool(name)
+			if timeout <= 0 {
+				return next(ctx, req)
+			}
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			type outcome struct {
+				result *mcp.CallToolResult
+				err    error
+			}
+			done := make(chan outcome, 1)
+			go func() {
+				// the recovery middleware cannot see panics of this goroutine
+				defer func() {
+					if r := recover(); r != nil {
+						done <- outcome{err: fmt.Errorf("panic recovered in %s tool handler: %v", name, r)}
+					}
+				}()
+				result, err := next(ctx, req)
+				done <- outcome{result, err}
+			}()
+
+			select {
+			case o := <-done:
+				return o.result, o.err
+			case <-ctx.Done():
+				if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+					return nil, ctx.Err()
+				}
+				log.WithContext(ctx).Warnf("tool %s timed out after %s", name, timeout)
+				return toolErrorf(codeDeadlineExceeded, "tool %s timed out after %s; narrow the request (shorter time range, fewer symbols) or use its async mode, or raise mcp.toolTimeouts.%s", name, timeout, name), nil
+			}
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/spf13/viper"
+)
+
+func TestToolTimeoutsFromConfig(t *testing.T) {
+	if d := toolTimeoutsFromConfig(nil).forTool("fetch_kline"); d != defaultToolTimeout {
+		t.Fatalf("unconfigured timeout %s", d)
+	}
+	cfg := viper.New()
+	cfg.Set("mcp.toolTimeouts", map[string]interface{}{"default": "2m", "list_symbols": "30s", "fetch_kline": "0"})
+	timeouts := toolTimeoutsFromConfig(cfg)
+	for name, want := range map[string]time.Duration{
+		"query_kline":     2 * time.Minute,
+		"list_symbols":    30 * time.Second,
+		"fetch_kline":     0,
+		"get_task_result": 0,
+	} {
+		if got := timeouts.forTool(name); got != want {
+			t.Errorf("%s: %s, want %s", name, got, want)
+		}
+	}
+}
+
+func TestToolTimeoutMiddleware(t *testing.T) {
+	slow := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		select {
+		case <-ctx.Done():
+		case <-time.After(time.Second):
+		}
+		return mcp.NewToolResultText("done"), nil
+	}
+	call := func(name string) *mcp.CallToolResult {
+		var req mcp.CallToolRequest
+		req.Params.Name = name
+		cfg := viper.New()
+		cfg.Set("mcp.toolTimeouts", map[string]interface{}{"default": "20ms", "list_data": "0"})
+		res, err := ToolTimeoutMiddleware(cfg)(slow)(context.Background(), req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	res := call("fetch_kline")
+	if !res.IsError || !strings.Contains(res.Content[0].(mcp.TextContent).Text, codeDeadlineExceeded) {
+		t.Fatalf("slow call not timed out: %+v", res.Content)
+	}
+	for _, name := range []string{"list_data", "get_task_result"} {
+		if res := call(name); res.IsError {
+			t.Fatalf("%s timed out", name)
+		}
+	}
+
+	panicking := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		panic("boom")
+	}
+	if _, err := ToolTimeoutMiddleware(nil)(panicking)(context.Background(), mcp.CallToolRequest{}); err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Fatalf("panic not recovered: %v", err)
+	}
+}
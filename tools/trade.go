@@ -3,16 +3,22 @@ package tools
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/ztrade/ztrade-mcp/store"
 	"github.com/ztrade/ztrade/pkg/ctl"
 
+	bfutures "github.com/adshao/go-binance/v2/futures"
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
+	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 	"github.com/ztrade/exchange"
 )
@@ -21,6 +27,25 @@ import (
 type tradeManager struct {
 	mu     sync.RWMutex
 	trades map[string]*tradeInstance
+	// launching holds the IDs of trades being started, so a second start or
+	// attach of the same ID is refused before it runs a second engine.
+	launching map[string]bool
+}
+
+// errTradeManaged is returned by launchTrade when the trade ID is already
+// running or being started in this process.
+var errTradeManaged = errors.New("live trade is already managed by this process")
+
+// reserve claims id for a launch. It fails when a trade with that ID runs
+// or is being started.
+func (m *tradeManager) reserve(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.trades[id]; ok || m.launching[id] {
+		return fmt.Errorf("%w: %s", errTradeManaged, id)
+	}
+	m.launching[id] = true
+	return nil
 }
 
 type tradeInstance struct {
@@ -28,28 +53,130 @@ type tradeInstance struct {
 	Exchange string    `json:"exchange"`
 	Symbol   string    `json:"symbol"`
 	Script   string    `json:"script"`
+	ScriptID int64     `json:"scriptId,omitempty"` // set when started from a stored strategy
 	Started  time.Time `json:"started"`
 	trade    *ctl.Trade
+	reporter *liveReporter
 }
 
 var manager = &tradeManager{
-	trades: make(map[string]*tradeInstance),
+	trades:    make(map[string]*tradeInstance),
+	launching: make(map[string]bool),
+}
+
+// tradesOfScript returns the IDs of running trades started from the stored
+// strategy scriptID.
+func (m *tradeManager) tradesOfScript(scriptID int64) []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var ids []string
+	for id, inst := range m.trades {
+		if inst.ScriptID == scriptID {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// symbolTradesLocked returns the IDs of running trades other than except on
+// symbol of exchange. The caller holds m.mu.
+func (m *tradeManager) symbolTradesLocked(exchangeName, symbol, except string) []string {
+	var ids []string
+	for id, inst := range m.trades {
+		if id != except && inst.Exchange == exchangeName && strings.EqualFold(inst.Symbol, symbol) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// buildStoredScript writes a stored strategy to a temp file and compiles it
+// into a plugin, returning the plugin path.
+func buildStoredScript(sc *store.Script) (string, error) {
+	goPath := fmt.Sprintf("/tmp/ztrade_plugins/%s_v%d.go", sc.Name, sc.Version)
+	soPath := fmt.Sprintf("/tmp/ztrade_plugins/%s_v%d.so", sc.Name, sc.Version)
+	if err := os.MkdirAll(filepath.Dir(goPath), 0755); err != nil {
+		return "", fmt.Errorf("failed to create plugin temp dir: %w", err)
+	}
+	if err := writeFile(goPath, sc.Content); err != nil {
+		return "", fmt.Errorf("failed to write temp go file: %w", err)
+	}
+	if err := buildPlugin(goPath, soPath, pluginBuildFlags{}); err != nil {
+		return "", fmt.Errorf("build failed: %w", err)
+	}
+	return soPath, nil
+}
+
+// launchErrorCode is the code for an error of launchTrade.
+func launchErrorCode(err error) string {
+	if errors.Is(err, errPluginMismatch) {
+		return codeBuildFailed
+	}
+	if errors.Is(err, errTradeManaged) {
+		return codeFailedPrecondition
+	}
+	return codeInternal
 }
 
-func registerStartTrade(s *server.MCPServer, cfg *viper.Viper) {
+// launchTrade creates and starts a live trade for an instance and registers
+// it with the manager. position is the net position the instance already
+// holds; fills are tracked from there and persisted when st is set. The
+// instance ID is reserved first, so concurrent launches of one trade start
+// a single engine.
+func launchTrade(cfg *viper.Viper, st *store.Store, instance *tradeInstance, param string, recentDays int, position float64) error {
+	if err := manager.reserve(instance.ID); err != nil {
+		return err
+	}
+	defer func() {
+		manager.mu.Lock()
+		delete(manager.launching, instance.ID)
+		manager.mu.Unlock()
+	}()
+	if err := checkPluginToolchain(instance.Script); err != nil {
+		return err
+	}
+	exchangeCfg := exchange.WrapViper(exchangeConfig(cfg))
+	trade, err := ctl.NewTradeWithConfig(exchangeCfg, instance.Exchange, instance.Symbol)
+	if err != nil {
+		return fmt.Errorf("failed to create trade: %w", err)
+	}
+
+	trade.SetLoadRecent(time.Duration(recentDays) * 24 * time.Hour)
+	reporter := newLiveReporter(st, instance.ID, position)
+	trade.SetReporter(reporter)
+
+	scriptName := filepath.Base(instance.Script)
+	if err := trade.AddScript(scriptName, instance.Script, param); err != nil {
+		return fmt.Errorf("failed to add script: %w", explainPluginError(err))
+	}
+	if err := trade.Start(); err != nil {
+		return fmt.Errorf("failed to start trade: %w", err)
+	}
+	instance.trade = trade
+	instance.reporter = reporter
+
+	manager.mu.Lock()
+	manager.trades[instance.ID] = instance
+	manager.mu.Unlock()
+	return nil
+}
+
+func registerStartTrade(s *server.MCPServer, cfg *viper.Viper, st *store.Store) {
 	tool := mcp.NewTool("start_trade",
 		mcp.WithDescription("Start a live trading instance with a strategy. Requires exchange API credentials in config. Returns a trade ID for monitoring and stopping."),
 		mcp.WithString("script", mcp.Required(), mcp.Description("Strategy file path (.go or .so)")),
 		mcp.WithString("exchange", mcp.Required(), mcp.Description("Exchange name (e.g., binance)")),
 		mcp.WithString("symbol", mcp.Required(), mcp.Description("Trading pair (e.g., BTCUSDT)")),
-		mcp.WithString("param", mcp.Description("Strategy parameters as JSON string")),
+		mcp.WithString("param", mcp.Description("Strategy parameters as JSON string. Default for a stored strategy: its parameter set from apply_param_set, if any")),
 		mcp.WithNumber("recentDays", mcp.Description("Load recent N days of historical data. Default: 1")),
 	)
 
 	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		// Safety check
 		if !cfg.GetBool("mcp.enableLiveTrade") {
-			return mcp.NewToolResultError("live trading is disabled. Set mcp.enableLiveTrade: true in config to enable"), nil
+			return toolError(codeFailedPrecondition, "live trading is disabled. Set mcp.enableLiveTrade: true in config to enable"), nil
 		}
 
 		script := req.GetString("script", "")
@@ -59,36 +186,25 @@ func registerStartTrade(s *server.MCPServer, cfg *viper.Viper) {
 		recentDaysF := req.GetFloat("recentDays", 0)
 
 		// --- 自动从数据库读取策略并编译为so ---
-		var soPath string
-		var goPath string
-		st := getStoreFromContext(ctx)
+		var scriptID int64
+		var scriptVersion int
+		var paramSet string
 		if st != nil && script != "" && (isLikelyID(script) || isLikelyName(script)) {
-			var s *store.Script
-			var err error
-			if isLikelyID(script) {
-				id, _ := parseID(script)
-				s, err = st.GetScript(id)
-			} else {
-				s, err = st.GetScriptByName(script)
-			}
+			s, err := accessibleScriptRef(ctx, st, script, false)
 			if err != nil {
-				return mcp.NewToolResultError("strategy not found: " + err.Error()), nil
+				return toolErrorf(storeErrorCode(err), "strategy not found: %s", err.Error()), nil
 			}
-			goPath = fmt.Sprintf("/tmp/ztrade_plugins/%s_v%d.go", s.Name, s.Version)
-			soPath = fmt.Sprintf("/tmp/ztrade_plugins/%s_v%d.so", s.Name, s.Version)
-			if err := writeFile(goPath, s.Content); err != nil {
-				return mcp.NewToolResultError("failed to write temp go file: " + err.Error()), nil
-			}
-			builder := ctl.NewBuilder(goPath, soPath)
-			if err := builder.Build(); err != nil {
-				return mcp.NewToolResultError("build failed: " + err.Error()), nil
+			scriptID, scriptVersion = s.ID, s.Version
+			param, paramSet = defaultParam(s, param)
+			script, err = buildStoredScript(s)
+			if err != nil {
+				return toolError(codeBuildFailed, err.Error()), nil
 			}
-			script = soPath
 		}
 
 		script, err := ensurePluginScript(script)
 		if err != nil {
-			return mcp.NewToolResultError(err.Error()), nil
+			return toolError(codeBuildFailed, err.Error()), nil
 		}
 
 		recentDays := int(recentDaysF)
@@ -96,37 +212,41 @@ func registerStartTrade(s *server.MCPServer, cfg *viper.Viper) {
 			recentDays = 1
 		}
 
-		exchangeCfg := exchange.WrapViper(cfg)
-		trade, err := ctl.NewTradeWithConfig(exchangeCfg, exchangeName, symbol)
-		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("failed to create trade: %s", err.Error())), nil
-		}
-
-		trade.SetLoadRecent(time.Duration(recentDays) * 24 * time.Hour)
-
-		scriptName := filepath.Base(script)
-		err = trade.AddScript(scriptName, script, param)
-		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("failed to add script: %s", err.Error())), nil
-		}
-
-		err = trade.Start()
-		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("failed to start trade: %s", err.Error())), nil
-		}
 		tradeID := fmt.Sprintf("%s_%s_%d", exchangeName, symbol, time.Now().Unix())
 		instance := &tradeInstance{
 			ID:       tradeID,
 			Exchange: exchangeName,
 			Symbol:   symbol,
 			Script:   script,
+			ScriptID: scriptID,
 			Started:  time.Now(),
-			trade:    trade,
 		}
-
-		manager.mu.Lock()
-		manager.trades[tradeID] = instance
-		manager.mu.Unlock()
+		// persisted before the engine starts, so the first fills find the
+		// row and the trade can always be reattached
+		if st != nil {
+			err := st.SaveLiveTrade(&store.LiveTrade{
+				TradeID:       tradeID,
+				Exchange:      exchangeName,
+				Symbol:        symbol,
+				Script:        script,
+				ScriptID:      scriptID,
+				ScriptVersion: scriptVersion,
+				Param:         param,
+				RecentDays:    recentDays,
+				StartedAt:     instance.Started,
+			})
+			if err != nil {
+				return toolErrorf(codeInternal, "failed to persist live trade, not started: %s", err.Error()), nil
+			}
+		}
+		if err := launchTrade(cfg, st, instance, param, recentDays, 0); err != nil {
+			if st != nil {
+				if err := st.MarkLiveTradeStopped(tradeID); err != nil {
+					log.WithContext(ctx).WithError(err).WithField("tradeId", tradeID).Error("mark unstarted live trade stopped failed")
+				}
+			}
+			return toolError(launchErrorCode(err), err.Error()), nil
+		}
 
 		result := map[string]interface{}{
 			"status":   "started",
@@ -135,61 +255,102 @@ func registerStartTrade(s *server.MCPServer, cfg *viper.Viper) {
 			"symbol":   symbol,
 			"script":   script,
 		}
+		if paramSet != "" {
+			result["param"] = param
+			result["paramSet"] = paramSet
+		}
 		data, _ := json.MarshalIndent(result, "", "  ")
 		return mcp.NewToolResultText(string(data)), nil
 	})
 }
 
-func registerStopTrade(s *server.MCPServer) {
+func registerStopTrade(s *server.MCPServer, cfg *viper.Viper, st *store.Store) {
 	tool := mcp.NewTool("stop_trade",
-		mcp.WithDescription("Stop a running live trading instance by its trade ID."),
+		mcp.WithDescription("Stop a running live trading instance by its trade ID. With closePositions the strategy is stopped first, so it cannot reopen anything, then the symbol's open orders are canceled and the position the instance holds is closed with a market order, confirmed with the exchange (binance futures only). A position on the symbol the instance did not open is left as it is; closePositions is refused while another instance trades the same symbol on the exchange."),
 		mcp.WithString("tradeId", mcp.Required(), mcp.Description("Trade instance ID returned by start_trade")),
+		mcp.WithBoolean("closePositions", mcp.Description("After stopping, cancel the symbol's open orders and close the instance's position at market, confirming it with the exchange. Default: false (leave orders and position as they are)")),
 	)
 
 	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		tradeID := req.GetString("tradeId", "")
+		closePositions := req.GetBool("closePositions", false)
 
 		manager.mu.Lock()
 		instance, ok := manager.trades[tradeID]
 		if !ok {
 			manager.mu.Unlock()
-			return mcp.NewToolResultError(fmt.Sprintf("trade instance not found: %s", tradeID)), nil
+			return toolErrorf(codeNotFound, "trade instance not found: %s", tradeID), nil
+		}
+		var client *bfutures.Client
+		if closePositions {
+			// canceling orders is symbol-wide, and the exchange position
+			// cannot be split between instances
+			if others := manager.symbolTradesLocked(instance.Exchange, instance.Symbol, tradeID); len(others) > 0 {
+				manager.mu.Unlock()
+				return toolErrorf(codeFailedPrecondition, "cannot close positions of trade %s, not stopped: trades %s also trade %s on %s; stop them first or stop without closePositions",
+					tradeID, strings.Join(others, ", "), instance.Symbol, instance.Exchange), nil
+			}
+			var err error
+			if client, err = binanceFuturesClient(cfg, instance.Exchange); err != nil {
+				manager.mu.Unlock()
+				return toolErrorf(codeFailedPrecondition, "cannot close positions of trade %s, not stopped: %s", tradeID, err.Error()), nil
+			}
 		}
 		delete(manager.trades, tradeID)
 		manager.mu.Unlock()
 
 		err := instance.trade.Stop()
 		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("failed to stop trade: %s", err.Error())), nil
+			return toolErrorf(codeInternal, "failed to stop trade: %s", err.Error()), nil
 		}
 
 		_ = instance.trade.Wait()
 
+		if st != nil {
+			if err := st.MarkLiveTradeStopped(tradeID); err != nil {
+				log.WithContext(ctx).WithError(err).WithField("tradeId", tradeID).Error("mark live trade stopped failed")
+			}
+		}
+
 		result := map[string]interface{}{
 			"status":  "stopped",
 			"tradeId": tradeID,
 		}
+		if closePositions {
+			report, err := flattenBinanceFutures(ctx, client, instance.Symbol, instance.reporter.Position(), flattenPollInterval)
+			logger := log.WithContext(ctx).WithFields(log.Fields{"tradeId": tradeID, "symbol": instance.Symbol})
+			if err != nil {
+				logger.WithError(err).Error("closing position of stopped trade failed")
+				return toolErrorf(codeUpstream, "trade %s stopped, but closing its position failed: %s; check get_open_orders and the exchange position", tradeID, err.Error()), nil
+			}
+			logger.WithField("closed", report.ClosedPosition).Info("position of stopped trade closed")
+			result["closePositions"] = report
+			if !report.Flat {
+				result["warning"] = fmt.Sprintf("the exchange still reports a position of %v after closing; check it manually", report.Position)
+			} else if report.Untracked != 0 {
+				result["warning"] = fmt.Sprintf("a position of %v on %s was not opened by this trade and was left open", report.Untracked, instance.Symbol)
+			}
+		}
 		data, _ := json.MarshalIndent(result, "", "  ")
 		return mcp.NewToolResultText(string(data)), nil
 	})
 }
 
-func registerTradeStatus(s *server.MCPServer) {
+func registerTradeStatus(s *server.MCPServer, cfg *viper.Viper, st *store.Store) {
 	tool := mcp.NewTool("trade_status",
-		mcp.WithDescription("Get status of live trading instances. If tradeId is provided, returns status of that specific instance. Otherwise returns all running instances."),
+		mcp.WithDescription("Get status of live trading instances. If tradeId is provided, returns status of that specific instance, including the realized PnL of its fills, the funding paid on its position for perpetual contracts and the funding-adjusted netPnl. Otherwise returns all running instances."),
 		mcp.WithString("tradeId", mcp.Description("Optional: specific trade instance ID")),
 	)
 
 	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		tradeID := req.GetString("tradeId", "")
 
-		manager.mu.RLock()
-		defer manager.mu.RUnlock()
-
 		if tradeID != "" {
+			manager.mu.RLock()
 			instance, ok := manager.trades[tradeID]
+			manager.mu.RUnlock()
 			if !ok {
-				return mcp.NewToolResultError(fmt.Sprintf("trade instance not found: %s", tradeID)), nil
+				return toolErrorf(codeNotFound, "trade instance not found: %s", tradeID), nil
 			}
 			result := map[string]interface{}{
 				"tradeId":  instance.ID,
@@ -199,10 +360,17 @@ func registerTradeStatus(s *server.MCPServer) {
 				"started":  instance.Started.Format("2006-01-02 15:04:05"),
 				"running":  true,
 			}
+			// funding rates may come from the exchange, so the manager is not locked here
+			for k, v := range liveTradePnl(ctx, cfg, st, instance, time.Now()) {
+				result[k] = v
+			}
 			data, _ := json.MarshalIndent(result, "", "  ")
 			return mcp.NewToolResultText(string(data)), nil
 		}
 
+		manager.mu.RLock()
+		defer manager.mu.RUnlock()
+
 		var instances []map[string]interface{}
 		for _, inst := range manager.trades {
 			instances = append(instances, map[string]interface{}{
@@ -219,6 +387,10 @@ func registerTradeStatus(s *server.MCPServer) {
 			"totalInstances": len(instances),
 			"instances":      instances,
 		}
+		if orphaned := orphanedLiveTrades(st); len(orphaned) > 0 {
+			result["orphaned"] = orphaned
+			result["hint"] = "orphaned trades were running before a restart; use attach_trade to resume managing them"
+		}
 		data, _ := json.MarshalIndent(result, "", "  ")
 		return mcp.NewToolResultText(string(data)), nil
 	})
@@ -0,0 +1,185 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/ztrade/ztrade-mcp/internal/stats"
+	"github.com/ztrade/ztrade-mcp/store"
+)
+
+const (
+	defaultDistributionBuckets = 10
+	maxDistributionBuckets     = 200
+)
+
+// histogramBucket counts the values in [From, To); the last bucket of a
+// histogram also holds values equal to its To.
+type histogramBucket struct {
+	From  float64 `json:"from"`
+	To    float64 `json:"to"`
+	Count int     `json:"count"`
+}
+
+// histogram sorts xs into buckets. With width > 0 the bucket edges are
+// multiples of width, so 0 is always an edge and wins and losses never share
+// a bucket; otherwise [min, max] is split into count equal buckets.
+func histogram(xs []float64, count int, width float64) ([]histogramBucket, error) {
+	if len(xs) == 0 {
+		return nil, nil
+	}
+	lo, hi := xs[0], xs[0]
+	for _, x := range xs {
+		lo = math.Min(lo, x)
+		hi = math.Max(hi, x)
+	}
+	if width > 0 {
+		lo = math.Floor(lo/width) * width
+		n := int(math.Floor((hi-lo)/width)) + 1
+		if n > maxDistributionBuckets {
+			return nil, fmt.Errorf("bucketWidth %g gives %d buckets, max %d", width, n, maxDistributionBuckets)
+		}
+		count = n
+	} else {
+		if hi == lo {
+			count = 1
+		}
+		width = (hi - lo) / float64(count)
+		if width == 0 {
+			width = 1
+		}
+	}
+
+	buckets := make([]histogramBucket, count)
+	for i := range buckets {
+		buckets[i].From = lo + float64(i)*width
+		buckets[i].To = lo + float64(i+1)*width
+	}
+	for _, x := range xs {
+		i := int(math.Floor((x - lo) / width))
+		if i >= count {
+			i = count - 1
+		}
+		if i < 0 {
+			i = 0
+		}
+		buckets[i].Count++
+	}
+	return buckets, nil
+}
+
+// tradeReturns returns the realized result of each closed trade, as profit
+// or, with percent, as a percentage of the equity before the trade. Trades
+// with zero profit are opening trades, as in the report's trade summary.
+func tradeReturns(trades []store.BacktestTrade, percent bool) []float64 {
+	var xs []float64
+	for _, t := range trades {
+		if t.Profit == 0 {
+			continue
+		}
+		if !percent {
+			xs = append(xs, t.Profit)
+			continue
+		}
+		if before := t.Equity - t.Profit; before > 0 {
+			xs = append(xs, t.Profit/before*100)
+		}
+	}
+	return xs
+}
+
+// tradeDistribution summarizes per-trade returns: win/loss averages and
+// the shape of the distribution next to its histogram.
+func tradeDistribution(xs []float64, buckets []histogramBucket) map[string]interface{} {
+	var wins, losses []float64
+	var largestWin, largestLoss float64
+	for _, x := range xs {
+		if x > 0 {
+			wins = append(wins, x)
+			largestWin = math.Max(largestWin, x)
+		} else {
+			losses = append(losses, x)
+			largestLoss = math.Min(largestLoss, x)
+		}
+	}
+	avgWin, avgLoss := stats.Mean(wins), stats.Mean(losses)
+	var winRate, winLossRatio float64
+	if len(xs) > 0 {
+		winRate = float64(len(wins)) / float64(len(xs))
+	}
+	if avgLoss < 0 {
+		winLossRatio = avgWin / -avgLoss
+	}
+	return map[string]interface{}{
+		"closedTrades": len(xs),
+		"wins":         len(wins),
+		"losses":       len(losses),
+		"winRate":      winRate,
+		"mean":         stats.Mean(xs),
+		"stdDev":       stats.StdDev(xs),
+		"avgWin":       avgWin,
+		"avgLoss":      avgLoss,
+		"winLossRatio": winLossRatio,
+		"largestWin":   largestWin,
+		"largestLoss":  largestLoss,
+		"skewness":     stats.Skewness(xs),
+		"kurtosis":     stats.ExcessKurtosis(xs),
+		"histogram":    buckets,
+	}
+}
+
+func registerTradeDistribution(s *server.MCPServer, st *store.Store) {
+	tool := mcp.NewTool("trade_distribution",
+		mcp.WithDescription("Distribution of per-trade results of a saved backtest record (run_backtest_managed): histogram, average win/loss, win/loss ratio, skewness and excess kurtosis. Negative skew and high kurtosis reveal fat-tailed risk, such as a few large losses, that the win rate hides."),
+		mcp.WithNumber("recordId", mcp.Required(), mcp.Description("Backtest record ID")),
+		mcp.WithString("unit", mcp.Description("'pnl' for realized profit in quote currency, or 'percent' for profit as a percentage of the equity before the trade. Default: pnl")),
+		mcp.WithNumber("buckets", mcp.Description(fmt.Sprintf("Number of equal-width histogram buckets between the smallest and largest result. Default: %d, max %d", defaultDistributionBuckets, maxDistributionBuckets))),
+		mcp.WithNumber("bucketWidth", mcp.Description("Fixed bucket width in the chosen unit; bucket edges are multiples of it, so wins and losses never share a bucket. Overrides buckets")),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if st == nil {
+			return toolError(codeUnavailable, "script store not initialized (check database config)"), nil
+		}
+
+		recordID := int64(req.GetFloat("recordId", 0))
+		unit := req.GetString("unit", "pnl")
+		if unit != "pnl" && unit != "percent" {
+			return toolErrorf(codeInvalidArgument, "invalid unit %s, supported: pnl, percent", unit), nil
+		}
+		count := int(req.GetFloat("buckets", defaultDistributionBuckets))
+		if count < 1 || count > maxDistributionBuckets {
+			return toolErrorf(codeInvalidArgument, "buckets must be between 1 and %d", maxDistributionBuckets), nil
+		}
+		width := req.GetFloat("bucketWidth", 0)
+		if width < 0 {
+			return toolError(codeInvalidArgument, "bucketWidth must not be negative"), nil
+		}
+
+		if _, _, err := accessibleRecord(ctx, st, recordID); err != nil {
+			return toolErrorf(storeErrorCode(err), "failed to get record: %s", err.Error()), nil
+		}
+		trades, err := st.ListBacktestTrades(recordID)
+		if err != nil {
+			return toolErrorf(storeErrorCode(err), "failed to get trades: %s", err.Error()), nil
+		}
+		xs := tradeReturns(trades, unit == "percent")
+		buckets, err := histogram(xs, count, width)
+		if err != nil {
+			return toolError(codeInvalidArgument, err.Error()), nil
+		}
+
+		result := tradeDistribution(xs, buckets)
+		result["recordId"] = recordID
+		result["unit"] = unit
+		if len(trades) == 0 {
+			result["warning"] = "no trades stored for this record (records created before trade persistence have none)"
+		}
+		data, _ := json.MarshalIndent(result, "", "  ")
+		return mcp.NewToolResultText(string(data)), nil
+	})
+}
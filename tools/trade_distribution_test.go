@@ -0,0 +1,65 @@
+package tools
+
+import (
+	"math"
+	"testing"
+
+	"github.com/ztrade/ztrade-mcp/store"
+)
+
+func TestHistogram(t *testing.T) {
+	xs := []float64{-10, -1, 0.5, 2, 3, 10}
+
+	buckets, err := histogram(xs, 4, 0)
+	if err != nil || len(buckets) != 4 {
+		t.Fatalf("by count: %+v, %v", buckets, err)
+	}
+	wantCounts := []int{1, 1, 3, 1}
+	for i, b := range buckets {
+		if b.Count != wantCounts[i] {
+			t.Fatalf("bucket %d = %+v, want count %d", i, b, wantCounts[i])
+		}
+	}
+	if buckets[0].From != -10 || buckets[3].To != 10 {
+		t.Errorf("range: %v .. %v", buckets[0].From, buckets[3].To)
+	}
+
+	// with a width, 0 is an edge: -1 and 0.5 fall on either side
+	buckets, err = histogram(xs, 0, 5)
+	if err != nil || len(buckets) != 5 || buckets[0].From != -10 || buckets[1].Count != 1 || buckets[2].From != 0 || buckets[2].Count != 3 {
+		t.Fatalf("by width: %+v, %v", buckets, err)
+	}
+	if _, err := histogram(xs, 0, 0.01); err == nil {
+		t.Error("too many buckets accepted")
+	}
+
+	if buckets, _ := histogram([]float64{2, 2}, 10, 0); len(buckets) != 1 || buckets[0].Count != 2 {
+		t.Errorf("constant: %+v", buckets)
+	}
+}
+
+func TestTradeDistribution(t *testing.T) {
+	trades := []store.BacktestTrade{
+		{Action: "OpenLong", Equity: 1000},
+		{Action: "CloseLong", Profit: 100, Equity: 1100},
+		{Action: "OpenLong", Equity: 1100},
+		{Action: "CloseLong", Profit: -110, Equity: 990},
+		{Action: "OpenShort", Equity: 990},
+		{Action: "CloseShort", Profit: 300, Equity: 1290},
+	}
+	if pct := tradeReturns(trades, true); len(pct) != 3 || math.Abs(pct[0]-10) > 1e-9 || math.Abs(pct[1]+10) > 1e-9 {
+		t.Fatalf("percent returns: %v", pct)
+	}
+
+	xs := tradeReturns(trades, false)
+	got := tradeDistribution(xs, nil)
+	if got["closedTrades"] != 3 || got["wins"] != 2 || got["avgWin"] != 200.0 || got["avgLoss"] != -110.0 {
+		t.Fatalf("distribution: %+v", got)
+	}
+	if r := got["winLossRatio"].(float64); math.Abs(r-200.0/110) > 1e-9 {
+		t.Errorf("win/loss ratio = %v", r)
+	}
+	if got["largestWin"] != 300.0 || got["largestLoss"] != -110.0 {
+		t.Errorf("extremes: %+v", got)
+	}
+}
@@ -0,0 +1,64 @@
+package tools
+
+import (
+	"math"
+
+	"github.com/ztrade/trademodel"
+	"github.com/ztrade/ztrade-mcp/internal/safenum"
+	"github.com/ztrade/ztrade/pkg/report"
+)
+
+// sideStats is the profitability of the trades closing one side, long or
+// short, of a backtest.
+type sideStats struct {
+	Trades       int     `json:"trades"`
+	WinRate      float64 `json:"winRate"`
+	ProfitFactor float64 `json:"profitFactor"` // gross profit / gross loss
+	NetProfit    float64 `json:"netProfit"`
+	NetReturn    float64 `json:"netReturn"` // NetProfit / initial balance
+}
+
+// sideBreakdown splits the closing trades of a backtest by the side they
+// close, as the report counts LongTrades and ShortTrades. Unlike the
+// report's profit factor, which sums profit rates, the per-side profit
+// factor sums profits; without losing trades it is clamped like other
+// non-finite metrics.
+func sideBreakdown(actions []*report.RptAct, initBalance float64) (long, short sideStats) {
+	var gross [2][2]float64 // side -> profit, loss
+	var wins [2]int
+	sides := [2]*sideStats{&long, &short}
+	for _, act := range actions {
+		if act == nil || !act.IsFinish {
+			continue
+		}
+		side := 1
+		if act.Action&trademodel.DirectLong == trademodel.DirectLong {
+			side = 0
+		}
+		s := sides[side]
+		s.Trades++
+		s.NetProfit += act.Profit
+		if act.Profit > 0 {
+			wins[side]++
+			gross[side][0] += act.Profit
+		} else {
+			gross[side][1] -= act.Profit
+		}
+	}
+	for i, s := range sides {
+		if s.Trades == 0 {
+			continue
+		}
+		s.WinRate = float64(wins[i]) / float64(s.Trades)
+		switch {
+		case gross[i][1] > 0:
+			s.ProfitFactor = gross[i][0] / gross[i][1]
+		case gross[i][0] > 0:
+			s.ProfitFactor, _ = safenum.ClampFloat64ForStorage(math.Inf(1))
+		}
+		if initBalance > 0 {
+			s.NetReturn = s.NetProfit / initBalance
+		}
+	}
+	return long, short
+}
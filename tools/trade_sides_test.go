@@ -0,0 +1,39 @@
+package tools
+
+import (
+	"math"
+	"testing"
+
+	"github.com/ztrade/trademodel"
+	"github.com/ztrade/ztrade/pkg/report"
+)
+
+func TestSideBreakdown(t *testing.T) {
+	act := func(action trademodel.TradeType, profit float64, finish bool) *report.RptAct {
+		return &report.RptAct{Trade: trademodel.Trade{Action: action}, Profit: profit, IsFinish: finish}
+	}
+	actions := []*report.RptAct{
+		act(trademodel.OpenLong, 0, false),
+		act(trademodel.CloseLong, 300, true),
+		act(trademodel.OpenLong, 0, false),
+		act(trademodel.StopLong, -100, true),
+		act(trademodel.OpenShort, 0, false),
+		act(trademodel.CloseShort, -50, true),
+		act(trademodel.OpenShort, 0, false),
+		act(trademodel.StopShort, -150, true),
+		nil,
+	}
+	long, short := sideBreakdown(actions, 1000)
+	if long.Trades != 2 || long.WinRate != 0.5 || long.ProfitFactor != 3 || long.NetProfit != 200 || math.Abs(long.NetReturn-0.2) > 1e-12 {
+		t.Errorf("long: %+v", long)
+	}
+	if short.Trades != 2 || short.WinRate != 0 || short.ProfitFactor != 0 || short.NetProfit != -200 || math.Abs(short.NetReturn+0.2) > 1e-12 {
+		t.Errorf("short: %+v", short)
+	}
+
+	// only winners: the profit factor is clamped, not infinite
+	long, short = sideBreakdown(actions[:2], 1000)
+	if math.IsInf(long.ProfitFactor, 0) || long.ProfitFactor <= 0 || short.Trades != 0 || short.WinRate != 0 {
+		t.Errorf("winners only: long %+v short %+v", long, short)
+	}
+}
@@ -0,0 +1,271 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"os"
+	"path/filepath"
+	"plugin"
+	"reflect"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/ztrade/ztrade-mcp/store"
+	goplugin "github.com/ztrade/ztrade/pkg/process/goscript/plugin"
+)
+
+// strategyRunnerType is the interface the engine requires of the value
+// returned by a strategy plugin's NewStrategy.
+var strategyRunnerType = reflect.TypeOf((*goplugin.Runner)(nil)).Elem()
+
+// strategyIssue is a method validate_strategy found missing or with the
+// wrong signature.
+type strategyIssue struct {
+	Method  string `json:"method"`
+	Problem string `json:"problem"` // missing or mismatched
+	Want    string `json:"want"`
+	Got     string `json:"got,omitempty"`
+}
+
+// checkStrategySource finds the strategy type the way the plugin builder
+// does, as the receiver of Init, and checks its constructor and methods
+// against strategyRunnerType by parameter and result count. It returns the
+// type name, empty if there is no Init method.
+func checkStrategySource(content string) (string, []strategyIssue, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "strategy.go", content, 0)
+	if err != nil {
+		return "", nil, err
+	}
+	receiver := func(fn *ast.FuncDecl) string {
+		if fn.Recv == nil || len(fn.Recv.List) == 0 {
+			return ""
+		}
+		t := fn.Recv.List[0].Type
+		if star, ok := t.(*ast.StarExpr); ok {
+			t = star.X
+		}
+		if ident, ok := t.(*ast.Ident); ok {
+			return ident.Name
+		}
+		return ""
+	}
+
+	var name string
+	for _, decl := range file.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Name.Name == "Init" && receiver(fn) != "" {
+			name = receiver(fn)
+			break
+		}
+	}
+	if name == "" {
+		m, _ := strategyRunnerType.MethodByName("Init")
+		return "", []strategyIssue{{Method: "Init", Problem: "missing", Want: m.Type.String()}}, nil
+	}
+
+	var ctor *ast.FuncDecl
+	methods := make(map[string]*ast.FuncType)
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok {
+			continue
+		}
+		switch {
+		case fn.Recv == nil && fn.Name.Name == "New"+name:
+			ctor = fn
+		case receiver(fn) == name:
+			methods[fn.Name.Name] = fn.Type
+		}
+	}
+
+	funcString := func(t *ast.FuncType) string {
+		var buf bytes.Buffer
+		printer.Fprint(&buf, fset, t)
+		return buf.String()
+	}
+	var issues []strategyIssue
+	want := fmt.Sprintf("func() *%s", name)
+	if ctor == nil {
+		issues = append(issues, strategyIssue{Method: "New" + name, Problem: "missing", Want: want})
+	} else if fieldCount(ctor.Type.Params) != 0 || fieldCount(ctor.Type.Results) != 1 {
+		issues = append(issues, strategyIssue{Method: "New" + name, Problem: "mismatched", Want: want, Got: funcString(ctor.Type)})
+	}
+	for i := 0; i < strategyRunnerType.NumMethod(); i++ {
+		m := strategyRunnerType.Method(i)
+		t, ok := methods[m.Name]
+		if !ok {
+			issues = append(issues, strategyIssue{Method: m.Name, Problem: "missing", Want: m.Type.String()})
+			continue
+		}
+		if fieldCount(t.Params) != m.Type.NumIn() || fieldCount(t.Results) != m.Type.NumOut() {
+			issues = append(issues, strategyIssue{Method: m.Name, Problem: "mismatched", Want: m.Type.String(), Got: funcString(t)})
+		}
+	}
+	return name, issues, nil
+}
+
+// fieldCount returns the number of parameters or results in fields.
+func fieldCount(fields *ast.FieldList) int {
+	if fields == nil {
+		return 0
+	}
+	n := 0
+	for _, f := range fields.List {
+		if len(f.Names) == 0 {
+			n++
+		} else {
+			n += len(f.Names)
+		}
+	}
+	return n
+}
+
+// checkStrategyValue compares the method set of a constructed strategy with
+// strategyRunnerType using the exact method types.
+func checkStrategyValue(v interface{}) []strategyIssue {
+	rv := reflect.ValueOf(v)
+	var issues []strategyIssue
+	for i := 0; i < strategyRunnerType.NumMethod(); i++ {
+		m := strategyRunnerType.Method(i)
+		got := rv.MethodByName(m.Name)
+		switch {
+		case !got.IsValid():
+			issues = append(issues, strategyIssue{Method: m.Name, Problem: "missing", Want: m.Type.String()})
+		case got.Type() != m.Type:
+			issues = append(issues, strategyIssue{Method: m.Name, Problem: "mismatched", Want: m.Type.String(), Got: got.Type().String()})
+		}
+	}
+	return issues
+}
+
+// loadStrategyPlugin opens a compiled strategy and calls its NewStrategy,
+// as the engine does when a backtest starts. Plugins built for another
+// server fail with errPluginMismatch.
+func loadStrategyPlugin(soPath string) (interface{}, error) {
+	if err := checkPluginToolchain(soPath); err != nil {
+		return nil, err
+	}
+	pl, err := plugin.Open(soPath)
+	if err != nil {
+		return nil, explainPluginError(err)
+	}
+	sym, err := pl.Lookup("NewStrategy")
+	if err != nil {
+		return nil, err
+	}
+	fn := reflect.ValueOf(sym)
+	if fn.Kind() == reflect.Ptr {
+		fn = fn.Elem()
+	}
+	if fn.Kind() != reflect.Func || fn.Type().NumIn() != 0 || fn.Type().NumOut() != 1 {
+		return nil, fmt.Errorf("NewStrategy is %s, want a func() constructor", fn.Type())
+	}
+	return fn.Call(nil)[0].Interface(), nil
+}
+
+// strategySourceFromRequest returns the strategy source given by the
+// content argument or, failing that, the managed strategy named by id or
+// name, which the caller must be allowed to see. On failure it returns the
+// tool error to respond with.
+func strategySourceFromRequest(ctx context.Context, req mcp.CallToolRequest, st *store.Store) (string, *mcp.CallToolResult) {
+	if content := req.GetString("content", ""); content != "" {
+		return content, nil
+	}
+	idF := req.GetFloat("id", 0)
+	name := req.GetString("name", "")
+	if idF <= 0 && name == "" {
+		return "", toolError(codeInvalidArgument, "one of 'content', 'id' or 'name' must be provided")
+	}
+	if st == nil {
+		return "", toolError(codeUnavailable, "script store not initialized (check database config)")
+	}
+	var script *store.Script
+	var err error
+	if idF > 0 {
+		script, err = accessibleScript(ctx, st, int64(idF), false)
+	} else {
+		script, err = accessibleScriptByName(ctx, st, name, false)
+	}
+	if err != nil {
+		return "", toolErrorf(storeErrorCode(err), "failed to get script: %s", err.Error())
+	}
+	return script.Content, nil
+}
+
+func registerValidateStrategy(s *server.MCPServer, st *store.Store) {
+	tool := mcp.NewTool("validate_strategy",
+		mcp.WithDescription("Check that a strategy implements the engine's strategy interface (NewXxx constructor and Param/Init/OnCandle/OnPosition/OnTrade/OnTradeMarket/OnDepth). Checks the source first, then compiles it, loads the plugin and verifies the method set by reflection. Returns the missing or mismatched methods instead of a generic build error."),
+		mcp.WithString("content", mcp.Description("Strategy source code to validate")),
+		mcp.WithNumber("id", mcp.Description("ID of a managed strategy to validate. Used if content is not provided.")),
+		mcp.WithString("name", mcp.Description("Name of a managed strategy to validate. Used if content and id are not provided.")),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		content, errResult := strategySourceFromRequest(ctx, req, st)
+		if errResult != nil {
+			return errResult, nil
+		}
+
+		result := map[string]interface{}{"valid": false}
+		respond := func() (*mcp.CallToolResult, error) {
+			data, _ := json.MarshalIndent(result, "", "  ")
+			return mcp.NewToolResultText(string(data)), nil
+		}
+
+		name, issues, err := checkStrategySource(content)
+		if err != nil {
+			result["stage"] = "parse"
+			result["error"] = err.Error()
+			return respond()
+		}
+		result["strategy"] = name
+		if len(issues) > 0 {
+			// the build would fail on the same methods with a less clear error
+			result["stage"] = "source"
+			result["issues"] = issues
+			return respond()
+		}
+		if issues := mergeTimeframeIssues(content, nil); len(issues) > 0 {
+			result["stage"] = "timeframes"
+			result["issues"] = issues
+			return respond()
+		}
+
+		if err := os.MkdirAll("/tmp/ztrade_plugins", 0755); err != nil {
+			return toolError(codeInternal, "failed to create plugin temp dir: "+err.Error()), nil
+		}
+		sum := sha1.Sum([]byte(content))
+		goPath := filepath.Join("/tmp/ztrade_plugins", fmt.Sprintf("validate_%x.go", sum[:6]))
+		soPath := goPath[:len(goPath)-3] + ".so"
+		if err := writeFile(goPath, content); err != nil {
+			return toolError(codeInternal, "failed to write temp go file: "+err.Error()), nil
+		}
+		if err := buildPlugin(goPath, soPath, pluginBuildFlags{}); err != nil {
+			result["stage"] = "build"
+			result["error"] = err.Error()
+			return respond()
+		}
+
+		v, err := loadStrategyPlugin(soPath)
+		if err != nil {
+			result["stage"] = "load"
+			result["error"] = err.Error()
+			return respond()
+		}
+		if issues := checkStrategyValue(v); len(issues) > 0 {
+			result["stage"] = "methods"
+			result["issues"] = issues
+			return respond()
+		}
+		result["valid"] = true
+		result["plugin"] = soPath
+		return respond()
+	})
+}
@@ -0,0 +1,76 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ztrade/base/common"
+	"github.com/ztrade/base/engine"
+	"github.com/ztrade/trademodel"
+)
+
+func TestCheckStrategySource(t *testing.T) {
+	src, err := renderStrategyTemplate(strategyData{Name: "Demo", Description: "demo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	name, issues, err := checkStrategySource(src)
+	if err != nil || name != "Demo" || len(issues) != 0 {
+		t.Fatalf("template: %s, %+v, %v", name, issues, err)
+	}
+
+	broken := strings.Replace(src, "func (s *Demo) OnDepth(depth *Depth) {", "func (s *Demo) onDepth(depth *Depth) {", 1)
+	broken = strings.Replace(broken, "func (s *Demo) OnPosition(pos, price float64) {", "func (s *Demo) OnPosition(pos float64) {", 1)
+	_, issues, err = checkStrategySource(broken)
+	if err != nil || len(issues) != 2 {
+		t.Fatalf("broken: %+v, %v", issues, err)
+	}
+	got := map[string]string{}
+	for _, is := range issues {
+		got[is.Method] = is.Problem
+	}
+	if got["OnDepth"] != "missing" || got["OnPosition"] != "mismatched" {
+		t.Errorf("issues: %+v", issues)
+	}
+
+	if _, issues, _ := checkStrategySource("package strategy\n\ntype X struct{}\n"); len(issues) != 1 || issues[0].Method != "Init" {
+		t.Errorf("no Init: %+v", issues)
+	}
+	if _, _, err := checkStrategySource("package strategy\nfunc ("); err == nil {
+		t.Error("syntax error accepted")
+	}
+}
+
+type partialStrategy struct{}
+
+func (partialStrategy) Param() []common.Param                      { return nil }
+func (partialStrategy) Init(engine.Engine, common.ParamData) error { return nil }
+func (partialStrategy) OnCandle(*trademodel.Candle)                {}
+func (partialStrategy) OnPosition(pos float64)                     {}
+func (partialStrategy) OnTrade(*trademodel.Trade)                  {}
+func (partialStrategy) OnTradeMarket(*trademodel.Trade)            {}
+func (partialStrategy) OnDepthUpdate(*trademodel.Depth)            {}
+
+func TestCheckStrategyValue(t *testing.T) {
+	issues := checkStrategyValue(partialStrategy{})
+	if len(issues) != 2 || issues[0].Method != "OnDepth" || issues[0].Problem != "missing" ||
+		issues[1].Method != "OnPosition" || issues[1].Got != "func(float64)" {
+		t.Fatalf("issues: %+v", issues)
+	}
+}
+
+func TestPreflightPluginScript(t *testing.T) {
+	dir := t.TempDir()
+	if err := preflightPluginScript(filepath.Join(dir, "missing.so")); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("missing plugin: %v", err)
+	}
+	junk := filepath.Join(dir, "junk.so")
+	if err := os.WriteFile(junk, []byte("not a plugin"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := preflightPluginScript(junk); err == nil || !strings.Contains(err.Error(), "failed to load plugin") {
+		t.Fatalf("junk plugin: %v", err)
+	}
+}
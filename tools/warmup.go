@@ -0,0 +1,116 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	basecommon "github.com/ztrade/base/common"
+	"github.com/ztrade/ztrade-mcp/internal/indicatorspec"
+	"github.com/ztrade/ztrade-mcp/internal/strategysrc"
+	"github.com/ztrade/ztrade-mcp/store"
+)
+
+// warmupEstimate is the history a strategy needs before its indicators are
+// meaningful, derived from its AddIndicator and Merge calls.
+type warmupEstimate struct {
+	LookbackBars int     `json:"lookbackBars"` // longest indicator lookback, in bars of BarSize
+	BarSize      string  `json:"barSize"`      // longest merged period, or 1m without merges
+	WarmupBars   int     `json:"warmupBars"`   // LookbackBars in 1m candles, the unit of run_backtest's warmupBars
+	Warmup       string  `json:"warmup"`
+	WarmupDays   float64 `json:"warmupDays"`
+	RecentDays   int     `json:"recentDays"` // whole days, for start_trade's recentDays
+}
+
+// estimateWarmup assumes the indicators are fed the longest merged period,
+// as strategies that merge candles usually do, so the estimate errs on the
+// side of too much history. Merge periods that are not valid bin sizes are
+// returned as issues.
+func estimateWarmup(wc strategysrc.WarmupCalls) (warmupEstimate, []string) {
+	var issues []string
+	est := warmupEstimate{BarSize: queryBaseBinSize}
+	for _, ic := range wc.Indicators {
+		est.LookbackBars = max(est.LookbackBars, indicatorspec.Lookback(ic.Name, ic.Args))
+		if len(ic.Unresolved) > 0 {
+			issues = append(issues, fmt.Sprintf("%s: could not resolve arguments %v; pass them with param or check the estimate", ic.Name, ic.Unresolved))
+		}
+	}
+	barDur := time.Minute
+	for _, m := range wc.Merges {
+		if m.Unresolved != "" {
+			issues = append(issues, fmt.Sprintf("Merge: could not resolve period %s", m.Unresolved))
+			continue
+		}
+		d, err := basecommon.GetBinSizeDuration(m.Period)
+		if err != nil {
+			issues = append(issues, fmt.Sprintf("Merge: invalid period %q: %s", m.Period, err.Error()))
+			continue
+		}
+		if d > barDur {
+			barDur, est.BarSize = d, m.Period
+		}
+	}
+	warmup := time.Duration(est.LookbackBars) * barDur
+	est.WarmupBars = int(warmup / time.Minute)
+	est.Warmup = warmup.String()
+	est.WarmupDays = warmup.Hours() / 24
+	est.RecentDays = int(math.Ceil(est.WarmupDays))
+	return est, issues
+}
+
+func registerEstimateWarmup(s *server.MCPServer, st *store.Store) {
+	tool := mcp.NewTool("estimate_warmup",
+		mcp.WithDescription("Estimate how much history a strategy needs before its indicators are meaningful, from the AddIndicator and Merge calls in its source: the longest indicator lookback in bars of the longest merged period, the equivalent warmupBars (1m candles) for run_backtest / run_backtest_managed, the whole days for start_trade's recentDays and, with start, the earlier start that covers the warmup. Arguments bound to strategy parameters are resolved from param or the parameter defaults."),
+		mcp.WithString("content", mcp.Description("Strategy source code to inspect")),
+		mcp.WithNumber("id", mcp.Description("ID of a managed strategy. Used if content is not provided.")),
+		mcp.WithString("name", mcp.Description("Name of a managed strategy. Used if content and id are not provided.")),
+		mcp.WithString("param", mcp.Description("Strategy parameters JSON, as passed to the backtest. Default: the parameter defaults")),
+		mcp.WithString("start", mcp.Description("Planned backtest start (2006-01-02 15:04:05); returns adjustedStart, the start moved back by the warmup")),
+	)
+
+	s.AddTool(tool, func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		content, errResult := strategySourceFromRequest(ctx, req, st)
+		if errResult != nil {
+			return errResult, nil
+		}
+		var params map[string]interface{}
+		if param := req.GetString("param", ""); param != "" {
+			if err := json.Unmarshal([]byte(param), &params); err != nil {
+				return toolErrorf(codeInvalidArgument, "invalid param JSON: %s", err.Error()), nil
+			}
+		}
+		var start time.Time
+		if startStr := req.GetString("start", ""); startStr != "" {
+			var err error
+			if start, err = time.Parse("2006-01-02 15:04:05", startStr); err != nil {
+				return toolErrorf(codeInvalidArgument, "invalid start time: %s", err.Error()), nil
+			}
+		}
+
+		wc, err := strategysrc.AnalyzeWarmup(content, params)
+		if err != nil {
+			return toolErrorf(codeInvalidArgument, "failed to parse strategy source: %s", err.Error()), nil
+		}
+		est, issues := estimateWarmup(wc)
+		result := map[string]interface{}{
+			"indicators": wc.Indicators,
+			"merges":     wc.Merges,
+			"estimate":   est,
+		}
+		if !start.IsZero() {
+			result["adjustedStart"] = start.Add(-time.Duration(est.WarmupBars) * time.Minute).Format("2006-01-02 15:04:05")
+		}
+		if len(wc.Indicators) == 0 {
+			issues = append(issues, "no AddIndicator calls found; indicators computed by hand are not detected")
+		}
+		if len(issues) > 0 {
+			result["warnings"] = issues
+		}
+		data, _ := json.MarshalIndent(result, "", "  ")
+		return mcp.NewToolResultText(string(data)), nil
+	})
+}
@@ -0,0 +1,30 @@
+package tools
+
+import (
+	"testing"
+
+	"github.com/ztrade/ztrade-mcp/internal/strategysrc"
+)
+
+func TestEstimateWarmup(t *testing.T) {
+	wc := strategysrc.WarmupCalls{
+		Indicators: []strategysrc.IndicatorCall{
+			{Name: "EMA", Args: []int{12, 26}},
+			{Name: "MACD", Args: []int{12, 26, 9}},
+		},
+		Merges: []strategysrc.MergeCall{{Period: "15m"}, {Period: "1h"}, {Period: "bogus"}},
+	}
+	est, issues := estimateWarmup(wc)
+	if est.LookbackBars != 35 || est.BarSize != "1h" || est.WarmupBars != 35*60 || est.RecentDays != 2 {
+		t.Fatalf("estimate = %+v", est)
+	}
+	if len(issues) != 1 {
+		t.Fatalf("issues = %v", issues)
+	}
+
+	// without merges the indicators run on 1m candles
+	est, _ = estimateWarmup(strategysrc.WarmupCalls{Indicators: []strategysrc.IndicatorCall{{Name: "RSI", Args: []int{14}}}})
+	if est.BarSize != "1m" || est.WarmupBars != 14 || est.RecentDays != 1 {
+		t.Fatalf("1m estimate = %+v", est)
+	}
+}
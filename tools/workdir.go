@@ -0,0 +1,133 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// workDir returns the directory tools are allowed to write files into,
+// configured by mcp.workDir.
+func workDir(cfg *viper.Viper) string {
+	if cfg != nil {
+		if dir := cfg.GetString("mcp.workDir"); dir != "" {
+			return dir
+		}
+	}
+	return filepath.Join(os.TempDir(), "ztrade_workdir")
+}
+
+// resolveWorkDirPath resolves p (relative to the work dir, or absolute) to a
+// writable path and refuses anything that escapes the work dir, including via
+// symlinks. Parent directories are created as needed, once the part of the
+// path that already exists is verified to resolve inside the work dir.
+func resolveWorkDirPath(cfg *viper.Viper, p string) (string, error) {
+	if strings.TrimSpace(p) == "" {
+		return "", fmt.Errorf("path is empty")
+	}
+	root, err := filepath.Abs(workDir(cfg))
+	if err != nil {
+		return "", fmt.Errorf("invalid workDir: %w", err)
+	}
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return "", fmt.Errorf("failed to create workDir: %w", err)
+	}
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", fmt.Errorf("invalid workDir: %w", err)
+	}
+
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(root, p)
+	}
+	p = filepath.Clean(p)
+	if !isWithinDir(root, p) && !isWithinDir(realRoot, p) {
+		return "", fmt.Errorf("path %s is outside the allowed workDir %s", p, root)
+	}
+
+	inside := func(d string) bool { return d == realRoot || isWithinDir(realRoot, d) }
+	dir := filepath.Dir(p)
+	existing := dir
+	for {
+		if _, err := os.Lstat(existing); err == nil {
+			break
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			break
+		}
+		existing = parent
+	}
+	realExisting, err := filepath.EvalSymlinks(existing)
+	if err != nil {
+		return "", err
+	}
+	if !inside(realExisting) {
+		return "", fmt.Errorf("path %s is outside the allowed workDir %s", p, root)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create directory: %w", err)
+	}
+	realDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", err
+	}
+	if !inside(realDir) {
+		return "", fmt.Errorf("path %s is outside the allowed workDir %s", p, root)
+	}
+	if fi, err := os.Lstat(p); err == nil && fi.Mode()&os.ModeSymlink != 0 {
+		return "", fmt.Errorf("refusing to write through symlink %s", p)
+	}
+	return p, nil
+}
+
+// isWithinDir reports whether p is strictly inside dir.
+func isWithinDir(dir, p string) bool {
+	rel, err := filepath.Rel(dir, p)
+	if err != nil {
+		return false
+	}
+	return rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// writeWorkDirFile writes content to p inside the work dir and returns the
+// resolved path.
+func writeWorkDirFile(cfg *viper.Viper, p, content string) (string, error) {
+	path, err := resolveWorkDirPath(cfg, p)
+	if err != nil {
+		return "", err
+	}
+	if err := replaceFile(path, content); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// replaceFile writes content to a new temporary file next to path and
+// renames it over path. Unlike os.Create it never writes through a symlink
+// placed at path after resolveWorkDirPath checked it: the rename replaces
+// the link itself.
+func replaceFile(path, content string) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	_, err = f.WriteString(content)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp, 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
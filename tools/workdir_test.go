@@ -0,0 +1,80 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestResolveWorkDirPath(t *testing.T) {
+	root := t.TempDir()
+	cfg := viper.New()
+	cfg.Set("mcp.workDir", root)
+
+	p, err := resolveWorkDirPath(cfg, "exports/a.go")
+	if err != nil {
+		t.Fatalf("relative path: %v", err)
+	}
+	if p != filepath.Join(root, "exports", "a.go") {
+		t.Fatalf("unexpected path %s", p)
+	}
+	if _, err := resolveWorkDirPath(cfg, filepath.Join(root, "b.go")); err != nil {
+		t.Fatalf("absolute path inside workDir: %v", err)
+	}
+
+	for _, bad := range []string{"../escape.go", "/etc/passwd", "a/../../escape.go", ".", ""} {
+		if _, err := resolveWorkDirPath(cfg, bad); err == nil {
+			t.Fatalf("expected %q to be refused", bad)
+		}
+	}
+
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(root, "link")); err != nil {
+		t.Skipf("symlink not supported: %v", err)
+	}
+	if _, err := resolveWorkDirPath(cfg, "link/x.go"); err == nil {
+		t.Fatalf("expected symlinked directory escape to be refused")
+	}
+	if _, err := resolveWorkDirPath(cfg, "link/sub/x.go"); err == nil {
+		t.Fatalf("expected symlinked directory escape to be refused")
+	}
+	if _, err := os.Stat(filepath.Join(outside, "sub")); !os.IsNotExist(err) {
+		t.Fatalf("directory created outside the workDir: %v", err)
+	}
+}
+
+func TestWriteWorkDirFileReplacesSymlink(t *testing.T) {
+	root := t.TempDir()
+	cfg := viper.New()
+	cfg.Set("mcp.workDir", root)
+
+	target := filepath.Join(t.TempDir(), "target")
+	if err := os.WriteFile(target, []byte("keep"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	path, err := writeWorkDirFile(cfg, "out.go", "first")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a link swapped in after the path was checked
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(target, path); err != nil {
+		t.Skipf("symlink not supported: %v", err)
+	}
+	if err := replaceFile(path, "second"); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := os.ReadFile(target); string(b) != "keep" {
+		t.Fatalf("wrote through the symlink: target now %q", b)
+	}
+	if fi, err := os.Lstat(path); err != nil || !fi.Mode().IsRegular() {
+		t.Fatalf("path is not a regular file: %v", err)
+	}
+	if entries, _ := os.ReadDir(root); len(entries) != 1 {
+		t.Fatalf("temporary files left: %v", entries)
+	}
+}